@@ -15,10 +15,12 @@
 package client
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"math/rand"
 	"net"
@@ -231,6 +233,30 @@ type httpClient interface {
 	Do(context.Context, httpAction) (*http.Response, []byte, error)
 }
 
+// httpStreamClient is implemented by the httpClients able to return the
+// responses without reading their bodies, for the responses too large to
+// be held in memory.
+type httpStreamClient interface {
+	// DoStream is like Do, but returns the response with its body unread.
+	// The caller must close the body, which is also closed once ctx is
+	// done.
+	DoStream(context.Context, httpAction) (*http.Response, error)
+}
+
+// doStream calls DoStream if c implements httpStreamClient, or else Do with
+// the body read returned as the body of the response.
+func doStream(ctx context.Context, c httpClient, act httpAction) (*http.Response, error) {
+	if sc, ok := c.(httpStreamClient); ok {
+		return sc.DoStream(ctx, act)
+	}
+	resp, body, err := c.Do(ctx, act)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
+
 func newHTTPClientFactory(tr CancelableTransport, cr CheckRedirectFunc, headerTimeout time.Duration) httpClientFactory {
 	return func(ep url.URL) httpClient {
 		return &redirectFollowingHTTPClient{
@@ -330,6 +356,25 @@ func (c *httpClusterClient) SetEndpoints(eps []string) error {
 }
 
 func (c *httpClusterClient) Do(ctx context.Context, act httpAction) (*http.Response, []byte, error) {
+	return c.do(ctx, act, func(hc httpClient, action httpAction) (*http.Response, []byte, error) {
+		return hc.Do(ctx, action)
+	})
+}
+
+func (c *httpClusterClient) DoStream(ctx context.Context, act httpAction) (*http.Response, error) {
+	resp, _, err := c.do(ctx, act, func(hc httpClient, action httpAction) (*http.Response, []byte, error) {
+		resp, err := doStream(ctx, hc, action)
+		if err == nil && resp.StatusCode/100 == 5 {
+			// the endpoint is given up
+			resp.Body.Close()
+		}
+		return resp, nil, err
+	})
+	return resp, err
+}
+
+// do sends act to the endpoints, starting with the pinned one, with send.
+func (c *httpClusterClient) do(ctx context.Context, act httpAction, send func(hc httpClient, action httpAction) (*http.Response, []byte, error)) (*http.Response, []byte, error) {
 	action := act
 	c.RLock()
 	leps := len(c.endpoints)
@@ -362,7 +407,7 @@ func (c *httpClusterClient) Do(ctx context.Context, act httpAction) (*http.Respo
 	for i := pinned; i < leps+pinned; i++ {
 		k := i % leps
 		hc := c.clientFactory(eps[k])
-		resp, body, err = hc.Do(ctx, action)
+		resp, body, err = send(hc, action)
 		if err != nil {
 			cerr.Errors = append(cerr.Errors, err)
 			if err == ctx.Err() {
@@ -522,10 +567,71 @@ type simpleHTTPClient struct {
 }
 
 func (c *simpleHTTPClient) Do(ctx context.Context, act httpAction) (*http.Response, []byte, error) {
+	resp, err := c.roundTrip(ctx, act)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	var body []byte
+	done := make(chan struct{})
+	go func() {
+		body, err = ioutil.ReadAll(resp.Body)
+		done <- struct{}{}
+	}()
+
+	select {
+	case <-ctx.Done():
+		resp.Body.Close()
+		<-done
+		return nil, nil, ctx.Err()
+	case <-done:
+	}
+
+	return resp, body, err
+}
+
+func (c *simpleHTTPClient) DoStream(ctx context.Context, act httpAction) (*http.Response, error) {
+	resp, err := c.roundTrip(ctx, act)
+	if err != nil {
+		return nil, err
+	}
+	body := &ctxReadCloser{ReadCloser: resp.Body, donec: make(chan struct{})}
+	go func() {
+		select {
+		case <-ctx.Done():
+			body.Close()
+		case <-body.donec:
+		}
+	}()
+	resp.Body = body
+	return resp, nil
+}
+
+// ctxReadCloser is a response body closed by its reader or once the
+// context of its request is done, whichever comes first.
+type ctxReadCloser struct {
+	io.ReadCloser
+	once  sync.Once
+	donec chan struct{}
+}
+
+func (rc *ctxReadCloser) Close() error {
+	var err error
+	rc.once.Do(func() {
+		err = rc.ReadCloser.Close()
+		close(rc.donec)
+	})
+	return err
+}
+
+// roundTrip sends the request of act, and returns the response once its
+// headers are received. The caller must close its body.
+func (c *simpleHTTPClient) roundTrip(ctx context.Context, act httpAction) (*http.Response, error) {
 	req := act.HTTPRequest(c.endpoint)
 
 	if err := printcURL(req); err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
 	isWait := false
@@ -535,7 +641,7 @@ func (c *simpleHTTPClient) Do(ctx context.Context, act httpAction) (*http.Respon
 			var err error
 			isWait, err = strconv.ParseBool(ws)
 			if err != nil {
-				return nil, nil, fmt.Errorf("wrong wait value %s (%v for %+v)", ws, err, req)
+				return nil, fmt.Errorf("wrong wait value %s (%v for %+v)", ws, err, req)
 			}
 		}
 	}
@@ -579,34 +685,15 @@ func (c *simpleHTTPClient) Do(ctx context.Context, act httpAction) (*http.Respon
 		}
 	}
 
-	// always check for resp nil-ness to deal with possible
-	// race conditions between channels above
-	defer func() {
+	if err != nil {
+		// always check for resp nil-ness to deal with possible
+		// race conditions between channels above
 		if resp != nil {
 			resp.Body.Close()
 		}
-	}()
-
-	if err != nil {
-		return nil, nil, err
-	}
-
-	var body []byte
-	done := make(chan struct{})
-	go func() {
-		body, err = ioutil.ReadAll(resp.Body)
-		done <- struct{}{}
-	}()
-
-	select {
-	case <-ctx.Done():
-		resp.Body.Close()
-		<-done
-		return nil, nil, ctx.Err()
-	case <-done:
+		return nil, err
 	}
-
-	return resp, body, err
+	return resp, nil
 }
 
 type authedAction struct {
@@ -658,6 +745,40 @@ func (r *redirectFollowingHTTPClient) Do(ctx context.Context, act httpAction) (*
 	return nil, nil, errTooManyRedirectChecks
 }
 
+func (r *redirectFollowingHTTPClient) DoStream(ctx context.Context, act httpAction) (*http.Response, error) {
+	next := act
+	for i := 0; i < 100; i++ {
+		if i > 0 {
+			if err := r.checkRedirect(i); err != nil {
+				return nil, err
+			}
+		}
+		resp, err := doStream(ctx, r.client, next)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode/100 == 3 {
+			resp.Body.Close()
+			hdr := resp.Header.Get("Location")
+			if hdr == "" {
+				return nil, fmt.Errorf("Location header not set")
+			}
+			loc, err := url.Parse(hdr)
+			if err != nil {
+				return nil, fmt.Errorf("Location header not valid URL: %s", hdr)
+			}
+			next = &redirectedHTTPAction{
+				action:   act,
+				location: *loc,
+			}
+			continue
+		}
+		return resp, nil
+	}
+
+	return nil, errTooManyRedirectChecks
+}
+
 type redirectedHTTPAction struct {
 	action   httpAction
 	location url.URL
@@ -0,0 +1,108 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+)
+
+var (
+	defaultV2ExportPrefix = "/v2/admin/export"
+)
+
+const (
+	// ExportJSON is the format of the exports holding every key as a
+	// JSON Node, without its children, on its own line.
+	ExportJSON = "json"
+	// ExportProtobuf is the format of the exports holding every key as
+	// the etcdserverpb.Request setting it, prefixed by its length as a
+	// uvarint. It does not hold the indexes of the keys.
+	ExportProtobuf = "protobuf"
+)
+
+// NewExportAPI constructs a new ExportAPI that uses HTTP to export the
+// keys of an etcd member. Exporting requires root access.
+func NewExportAPI(c Client) ExportAPI {
+	return &httpExportAPI{
+		client: c,
+	}
+}
+
+type ExportAPI interface {
+	// Export returns the keys at or under key, encoded in the given
+	// format, ExportJSON or ExportProtobuf. The parents are exported
+	// before their children.
+	Export(ctx context.Context, key, format string) (*ExportResponse, error)
+}
+
+type ExportResponse struct {
+	// Index is the etcd index the keys were exported at.
+	Index uint64
+	// Body streams the exported keys. The caller must close it.
+	Body io.ReadCloser
+}
+
+type httpExportAPI struct {
+	client httpClient
+}
+
+func (e *httpExportAPI) Export(ctx context.Context, key, format string) (*ExportResponse, error) {
+	// the export is streamed, since it may not fit in memory
+	resp, err := doStream(ctx, e.client, &exportAction{Key: key, Format: format})
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		// the errors about the keys are reported like by the keys API
+		var etcdErr Error
+		if json.NewDecoder(resp.Body).Decode(&etcdErr) == nil && etcdErr.Code != 0 {
+			return nil, etcdErr
+		}
+		return nil, assertStatusCode(resp.StatusCode, http.StatusOK)
+	}
+
+	er := &ExportResponse{Body: resp.Body}
+	if idx := resp.Header.Get("X-Etcd-Index"); idx != "" {
+		if er.Index, err = strconv.ParseUint(idx, 10, 64); err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+	}
+	return er, nil
+}
+
+type exportAction struct {
+	Key    string
+	Format string
+}
+
+func (a *exportAction) HTTPRequest(ep url.URL) *http.Request {
+	ep.Path = path.Join(ep.Path, defaultV2ExportPrefix)
+	params := ep.Query()
+	params.Set("key", a.Key)
+	if a.Format != "" {
+		params.Set("format", a.Format)
+	}
+	ep.RawQuery = params.Encode()
+	req, _ := http.NewRequest("GET", ep.String(), nil)
+	return req
+}
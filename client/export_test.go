@@ -0,0 +1,148 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestExportAction(t *testing.T) {
+	ep := url.URL{Scheme: "http", Host: "example.com"}
+	tests := []struct {
+		act  exportAction
+		wurl string
+	}{
+		{
+			act:  exportAction{Key: "/"},
+			wurl: "http://example.com/v2/admin/export?key=%2F",
+		},
+		{
+			act:  exportAction{Key: "/foo", Format: ExportProtobuf},
+			wurl: "http://example.com/v2/admin/export?format=protobuf&key=%2Ffoo",
+		},
+	}
+
+	for i, tt := range tests {
+		req := tt.act.HTTPRequest(ep)
+		if req.Method != "GET" {
+			t.Errorf("#%d: method = %s, want GET", i, req.Method)
+		}
+		if g := req.URL.String(); g != tt.wurl {
+			t.Errorf("#%d: url = %s, want %s", i, g, tt.wurl)
+		}
+	}
+}
+
+func TestHTTPExportAPIExport(t *testing.T) {
+	body := []byte(`{"key":"/foo","value":"bar","modifiedIndex":3,"createdIndex":3}` + "\n")
+	eAPI := &httpExportAPI{
+		client: &actionAssertingHTTPClient{
+			t:   t,
+			act: &exportAction{Key: "/foo", Format: ExportJSON},
+			resp: http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"X-Etcd-Index": []string{"5"}},
+			},
+			body: body,
+		},
+	}
+
+	resp, err := eAPI.Export(context.Background(), "/foo", ExportJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.Index != 5 {
+		t.Errorf("index = %d, want 5", resp.Index)
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(b, body) {
+		t.Errorf("body = %q, want %q", b, body)
+	}
+}
+
+// TestHTTPExportAPIExportStream ensures the export is returned before its
+// body is received, and its body closed once the context is done.
+func TestHTTPExportAPIExportStream(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	tr := newFakeTransport()
+	tr.respchan <- &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"X-Etcd-Index": []string{"5"}},
+		Body:       pr,
+	}
+	eAPI := &httpExportAPI{client: &simpleHTTPClient{transport: tr}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	resp, err := eAPI.Export(ctx, "/foo", ExportJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go pw.Write([]byte("{}\n"))
+	var b [3]byte
+	if _, err = io.ReadFull(resp.Body, b[:]); err != nil {
+		t.Fatal(err)
+	}
+
+	cancel()
+	if _, err = resp.Body.Read(b[:]); err == nil {
+		t.Fatal("body not closed once the context is done")
+	}
+}
+
+func TestHTTPExportAPIExportError(t *testing.T) {
+	tests := []struct {
+		client httpClient
+		werr   error
+	}{
+		{
+			client: &staticHTTPClient{err: errors.New("fail!")},
+			werr:   errors.New("fail!"),
+		},
+		{
+			client: &staticHTTPClient{
+				resp: http.Response{StatusCode: http.StatusNotFound},
+				body: []byte(`{"errorCode":100,"message":"Key not found","cause":"/foo","index":5}`),
+			},
+			werr: Error{Code: ErrorCodeKeyNotFound, Message: "Key not found", Cause: "/foo", Index: 5},
+		},
+		{
+			client: &staticHTTPClient{
+				resp: http.Response{StatusCode: http.StatusUnauthorized},
+				body: []byte(`{"message":"Insufficient credentials"}`),
+			},
+			werr: errors.New("unexpected status code 401"),
+		},
+	}
+
+	for i, tt := range tests {
+		eAPI := &httpExportAPI{client: tt.client}
+		_, err := eAPI.Export(context.Background(), "/foo", ExportJSON)
+		if !reflect.DeepEqual(err, tt.werr) {
+			t.Errorf("#%d: err = %#v, want %#v", i, err, tt.werr)
+		}
+	}
+}
@@ -153,6 +153,12 @@ type CreateInOrderOptions struct {
 	// that the zero-value is ignored, TTL cannot be used to set
 	// a TTL of 0.
 	TTL time.Duration
+
+	// RequestID, if set, identifies the request across its retries: the
+	// cluster applies the request once even if it is retried, for instance
+	// on another endpoint, within a few minutes. It must be unique, e.g.
+	// a random UUID.
+	RequestID string
 }
 
 type SetOptions struct {
@@ -195,6 +201,12 @@ type SetOptions struct {
 	// NoValueOnSuccess specifies whether the response contains the current value of the Node.
 	// If set, the response will only contain the current value when the request fails.
 	NoValueOnSuccess bool
+
+	// RequestID, if set, identifies the request across its retries: the
+	// cluster applies the request once even if it is retried, for instance
+	// on another endpoint, within a few minutes. It must be unique, e.g.
+	// a random UUID.
+	RequestID string
 }
 
 type GetOptions struct {
@@ -239,6 +251,12 @@ type DeleteOptions struct {
 
 	// Dir specifies whether or not this Node should be removed as a directory.
 	Dir bool
+
+	// RequestID, if set, identifies the request across its retries: the
+	// cluster applies the request once even if it is retried, for instance
+	// on another endpoint, within a few minutes. It must be unique, e.g.
+	// a random UUID.
+	RequestID string
 }
 
 type Watcher interface {
@@ -344,10 +362,12 @@ func (k *httpKeysAPI) Set(ctx context.Context, key, val string, opts *SetOptions
 		act.Refresh = opts.Refresh
 		act.Dir = opts.Dir
 		act.NoValueOnSuccess = opts.NoValueOnSuccess
+		act.RequestID = opts.RequestID
 	}
 
 	doCtx := ctx
-	if act.PrevExist == PrevNoExist {
+	// a create is not retried, unless the request ID makes it safe to
+	if act.PrevExist == PrevNoExist && act.RequestID == "" {
 		doCtx = context.WithValue(doCtx, &oneShotCtxValue, &oneShotCtxValue)
 	}
 	resp, body, err := k.client.Do(doCtx, act)
@@ -371,6 +391,7 @@ func (k *httpKeysAPI) CreateInOrder(ctx context.Context, dir, val string, opts *
 
 	if opts != nil {
 		act.TTL = opts.TTL
+		act.RequestID = opts.RequestID
 	}
 
 	resp, body, err := k.client.Do(ctx, act)
@@ -396,9 +417,13 @@ func (k *httpKeysAPI) Delete(ctx context.Context, key string, opts *DeleteOption
 		act.PrevIndex = opts.PrevIndex
 		act.Dir = opts.Dir
 		act.Recursive = opts.Recursive
+		act.RequestID = opts.RequestID
 	}
 
-	doCtx := context.WithValue(ctx, &oneShotCtxValue, &oneShotCtxValue)
+	doCtx := ctx
+	if act.RequestID == "" {
+		doCtx = context.WithValue(ctx, &oneShotCtxValue, &oneShotCtxValue)
+	}
 	resp, body, err := k.client.Do(doCtx, act)
 	if err != nil {
 		return nil, err
@@ -542,6 +567,7 @@ type setAction struct {
 	Refresh          bool
 	Dir              bool
 	NoValueOnSuccess bool
+	RequestID        string
 }
 
 func (a *setAction) HTTPRequest(ep url.URL) *http.Request {
@@ -578,6 +604,9 @@ func (a *setAction) HTTPRequest(ep url.URL) *http.Request {
 	if a.NoValueOnSuccess {
 		params.Set("noValueOnSuccess", strconv.FormatBool(a.NoValueOnSuccess))
 	}
+	if a.RequestID != "" {
+		params.Set("requestId", a.RequestID)
+	}
 
 	u.RawQuery = params.Encode()
 	body := strings.NewReader(form.Encode())
@@ -595,6 +624,7 @@ type deleteAction struct {
 	PrevIndex uint64
 	Dir       bool
 	Recursive bool
+	RequestID string
 }
 
 func (a *deleteAction) HTTPRequest(ep url.URL) *http.Request {
@@ -613,6 +643,9 @@ func (a *deleteAction) HTTPRequest(ep url.URL) *http.Request {
 	if a.Recursive {
 		params.Set("recursive", "true")
 	}
+	if a.RequestID != "" {
+		params.Set("requestId", a.RequestID)
+	}
 	u.RawQuery = params.Encode()
 
 	req, _ := http.NewRequest("DELETE", u.String(), nil)
@@ -622,14 +655,20 @@ func (a *deleteAction) HTTPRequest(ep url.URL) *http.Request {
 }
 
 type createInOrderAction struct {
-	Prefix string
-	Dir    string
-	Value  string
-	TTL    time.Duration
+	Prefix    string
+	Dir       string
+	Value     string
+	TTL       time.Duration
+	RequestID string
 }
 
 func (a *createInOrderAction) HTTPRequest(ep url.URL) *http.Request {
 	u := v2KeysURL(ep, a.Prefix, a.Dir)
+	if a.RequestID != "" {
+		params := u.Query()
+		params.Set("requestId", a.RequestID)
+		u.RawQuery = params.Encode()
+	}
 
 	form := url.Values{}
 	form.Add("value", a.Value)
@@ -415,6 +415,15 @@ func TestSetAction(t *testing.T) {
 			wantURL:  "http://example.com/foo?noValueOnSuccess=true",
 			wantBody: "value=",
 		},
+		// RequestID is set
+		{
+			act: setAction{
+				Key:       "foo",
+				RequestID: "c0ffee",
+			},
+			wantURL:  "http://example.com/foo?requestId=c0ffee",
+			wantBody: "value=",
+		},
 	}
 
 	for i, tt := range tests {
@@ -516,6 +525,15 @@ func TestCreateInOrderAction(t *testing.T) {
 			wantURL:  "http://example.com/foo",
 			wantBody: "ttl=180&value=",
 		},
+		// RequestID is set
+		{
+			act: createInOrderAction{
+				Dir:       "foo",
+				RequestID: "c0ffee",
+			},
+			wantURL:  "http://example.com/foo?requestId=c0ffee",
+			wantBody: "value=",
+		},
 	}
 
 	for i, tt := range tests {
@@ -618,6 +636,15 @@ func TestDeleteAction(t *testing.T) {
 			},
 			wantURL: "http://example.com/foo?prevIndex=12",
 		},
+
+		// RequestID is set
+		{
+			act: deleteAction{
+				Key:       "foo",
+				RequestID: "c0ffee",
+			},
+			wantURL: "http://example.com/foo?requestId=c0ffee",
+		},
 	}
 
 	for i, tt := range tests {
@@ -45,6 +45,10 @@ type Member struct {
 	// ClientURLs represents the HTTP(S) endpoints on which this Member
 	// serves its client-facing APIs.
 	ClientURLs []string `json:"clientURLs"`
+
+	// Metadata holds the operator-defined key/value pairs of this Member,
+	// e.g. its zone or rack.
+	Metadata map[string]string `json:"metadata,omitempty"`
 }
 
 type memberCollection []Member
@@ -100,6 +104,10 @@ type MembersAPI interface {
 	// Add instructs etcd to accept a new Member into the cluster.
 	Add(ctx context.Context, peerURL string) (*Member, error)
 
+	// AddWithPeerURLs instructs etcd to accept a new Member with several
+	// peer URLs into the cluster.
+	AddWithPeerURLs(ctx context.Context, peerURLs []string) (*Member, error)
+
 	// Remove demotes an existing Member out of the cluster.
 	Remove(ctx context.Context, mID string) error
 
@@ -134,7 +142,11 @@ func (m *httpMembersAPI) List(ctx context.Context) ([]Member, error) {
 }
 
 func (m *httpMembersAPI) Add(ctx context.Context, peerURL string) (*Member, error) {
-	urls, err := types.NewURLs([]string{peerURL})
+	return m.AddWithPeerURLs(ctx, []string{peerURL})
+}
+
+func (m *httpMembersAPI) AddWithPeerURLs(ctx context.Context, peerURLs []string) (*Member, error) {
+	urls, err := types.NewURLs(peerURLs)
 	if err != nil {
 		return nil, err
 	}
@@ -353,6 +353,39 @@ func TestHTTPMembersAPIAddSuccess(t *testing.T) {
 	}
 }
 
+func TestHTTPMembersAPIAddWithPeerURLsSuccess(t *testing.T) {
+	wantAction := &membersAPIActionAdd{
+		peerURLs: types.URLs([]url.URL{
+			{Scheme: "http", Host: "127.0.0.1:7002"},
+			{Scheme: "http", Host: "127.0.0.1:7003"},
+		}),
+	}
+
+	mAPI := &httpMembersAPI{
+		client: &actionAssertingHTTPClient{
+			t:   t,
+			act: wantAction,
+			resp: http.Response{
+				StatusCode: http.StatusCreated,
+			},
+			body: []byte(`{"id":"94088180e21eb87b","peerURLs":["http://127.0.0.1:7002","http://127.0.0.1:7003"]}`),
+		},
+	}
+
+	wantResponseMember := &Member{
+		ID:       "94088180e21eb87b",
+		PeerURLs: []string{"http://127.0.0.1:7002", "http://127.0.0.1:7003"},
+	}
+
+	m, err := mAPI.AddWithPeerURLs(context.Background(), []string{"http://127.0.0.1:7003", "http://127.0.0.1:7002"})
+	if err != nil {
+		t.Errorf("got non-nil err: %#v", err)
+	}
+	if !reflect.DeepEqual(wantResponseMember, m) {
+		t.Errorf("incorrect Member: want=%#v got=%#v", wantResponseMember, m)
+	}
+}
+
 func TestHTTPMembersAPIAddError(t *testing.T) {
 	okPeer := "http://example.com:2379"
 	tests := []struct {
@@ -419,6 +419,14 @@ func WithRequireLeader(ctx context.Context) context.Context {
 	return metadata.NewOutgoingContext(ctx, md)
 }
 
+// WithDeadMember makes a member remove request with the returned context
+// only remove a member the serving member has not seen for deadFor, even
+// if the strict reconfiguration check would reject it. The removal still
+// fails if the remaining members would not have an active quorum.
+func WithDeadMember(ctx context.Context, deadFor time.Duration) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, rpctypes.MetadataDeadForKey, deadFor.String())
+}
+
 func newClient(cfg *Config) (*Client, error) {
 	if cfg == nil {
 		cfg = &Config{}
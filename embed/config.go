@@ -15,6 +15,7 @@
 package embed
 
 import (
+	"bytes"
 	"crypto/tls"
 	"fmt"
 	"io/ioutil"
@@ -28,13 +29,18 @@ import (
 	"time"
 
 	"go.etcd.io/etcd/etcdserver"
+	"go.etcd.io/etcd/etcdserver/api/etcdhttp"
+	"go.etcd.io/etcd/etcdserver/api/rafthttp"
+	"go.etcd.io/etcd/etcdserver/api/snap"
 	"go.etcd.io/etcd/etcdserver/api/v3compactor"
 	"go.etcd.io/etcd/pkg/flags"
 	"go.etcd.io/etcd/pkg/netutil"
 	"go.etcd.io/etcd/pkg/srv"
+	"go.etcd.io/etcd/pkg/statsd"
 	"go.etcd.io/etcd/pkg/tlsutil"
 	"go.etcd.io/etcd/pkg/transport"
 	"go.etcd.io/etcd/pkg/types"
+	"go.etcd.io/etcd/wal"
 
 	"github.com/ghodss/yaml"
 	bolt "go.etcd.io/bbolt"
@@ -72,6 +78,8 @@ const (
 	// v2 is enabled by default.
 	// TODO: disable v2 when deprecated.
 	DefaultEnableV2 = true
+	// DefaultMetricsStatsdInterval is the default value for "--metrics-statsd-interval" flag.
+	DefaultMetricsStatsdInterval = 10 * time.Second
 
 	// maxElectionMs specifies the maximum value of election timeout.
 	// More details are listed in ../Documentation/tuning.md#time-parameters.
@@ -120,6 +128,10 @@ type Config struct {
 	WalDir string `json:"wal-dir"`
 
 	SnapshotCount uint64 `json:"snapshot-count"`
+	// SnapshotCountAuto adapts the snapshot trigger to the cost of the
+	// applied entries and of the snapshots, to bound the recovery time.
+	// SnapshotCount is then the most entries between two snapshots.
+	SnapshotCountAuto bool `json:"snapshot-count-auto"`
 
 	// SnapshotCatchUpEntries is the number of entries for a slow follower
 	// to catch-up after compacting the raft storage entries.
@@ -133,6 +145,11 @@ type Config struct {
 	MaxSnapFiles uint `json:"max-snapshots"`
 	MaxWalFiles  uint `json:"max-wals"`
 
+	// WALPreallocateBytes is the size preallocated for each WAL segment
+	// file, so that appends do not extend the file. 0 preallocates the whole
+	// segment; a negative value disables preallocation.
+	WALPreallocateBytes int64 `json:"wal-preallocate-bytes"`
+
 	// TickMs is the number of milliseconds between heartbeat ticks.
 	// TODO: decouple tickMs and heartbeat tick (current heartbeat tick = 1).
 	// make ticks a cluster wide configuration.
@@ -175,6 +192,20 @@ type Config struct {
 	QuotaBackendBytes int64 `json:"quota-backend-bytes"`
 	MaxTxnOps         uint  `json:"max-txn-ops"`
 	MaxRequestBytes   uint  `json:"max-request-bytes"`
+	// MaxRecursiveKeys is the maximum number of keys a v2 recursive get or
+	// delete may touch. 0 means unlimited.
+	MaxRecursiveKeys uint `json:"max-recursive-keys"`
+	// MaxWatchersPerClient is the maximum number of v2 watchers a client,
+	// identified by the host of its remote address, may hold at once. 0
+	// means unlimited.
+	MaxWatchersPerClient uint `json:"max-watchers-per-client"`
+	// MaxWatchersPerKey is the maximum number of v2 watchers a key may
+	// have at once. 0 means unlimited.
+	MaxWatchersPerKey uint `json:"max-watchers-per-key"`
+	// MemoryLimitBytes is the estimated memory usage of the v2 store and
+	// the raft log above which the writes adding data are refused. 0 means
+	// unlimited.
+	MemoryLimitBytes uint64 `json:"memory-limit-bytes"`
 
 	LPUrls, LCUrls []url.URL
 	APUrls, ACUrls []url.URL
@@ -183,11 +214,49 @@ type Config struct {
 	PeerTLSInfo    transport.TLSInfo
 	PeerAutoTLS    bool
 
+	// ClientTLSOverrides holds the TLS settings of the listen client and
+	// admin URLs not served with ClientTLSInfo, keyed by URL; e.g. to
+	// require client certificates only on the external address.
+	ClientTLSOverrides map[string]transport.TLSInfo
+
+	// PeerSharedSecretFile is the path to a file holding a secret shared
+	// by all members. If set, requests between peers are signed with it,
+	// and unsigned requests to the peer listeners are rejected.
+	PeerSharedSecretFile string `json:"peer-shared-secret-file"`
+
+	// PeerTransport is the protocol the raft messages are sent to the
+	// peers with, "http" or "grpc", which requires peer TLS with client
+	// certificate authentication. It is replicated: the leader proposes
+	// its own to the cluster.
+	PeerTransport string `json:"peer-transport"`
+	// PeerDialTimeout is the timeout of the dials to the peers. 0 derives
+	// it from the election timeout.
+	PeerDialTimeout time.Duration `json:"peer-dial-timeout"`
+	// PeerRequestTimeout is the timeout of the requests sending raft
+	// messages to the peers. 0 derives it from the election timeout.
+	PeerRequestTimeout time.Duration `json:"peer-request-timeout"`
+
+	// PeerAllowedHosts, if not empty, holds the CIDRs of the networks of
+	// the peers. Connections to the peer listeners from other hosts are
+	// closed, and other hosts are not dialed as peers.
+	PeerAllowedHosts []string `json:"peer-allowed-hosts"`
+
 	// CipherSuites is a list of supported TLS cipher suites between
 	// client/server and peers. If empty, Go auto-populates the list.
 	// Note that cipher suites are prioritized in the given order.
 	CipherSuites []string `json:"cipher-suites"`
 
+	// TLSMinVersion is the minimum TLS version accepted between
+	// client/server and peers, "TLS1.2" or "TLS1.3" (Go 1.12+ builds only). If empty, TLS 1.2.
+	TLSMinVersion string `json:"tls-min-version"`
+	// TLSSessionTicketsDisabled disables the resumption of TLS sessions
+	// with session tickets between client/server and peers.
+	TLSSessionTicketsDisabled bool `json:"tls-session-tickets-disabled"`
+	// TLSClientSessionCacheSize is the number of TLS sessions cached to
+	// resume them when reconnecting to peers and to the own client
+	// listeners. If 0, the sessions are not cached.
+	TLSClientSessionCacheSize int `json:"tls-client-session-cache-size"`
+
 	ClusterState          string `json:"initial-cluster-state"`
 	DNSCluster            string `json:"discovery-srv"`
 	DNSClusterServiceName string `json:"discovery-srv-name"`
@@ -198,6 +267,27 @@ type Config struct {
 	StrictReconfigCheck   bool   `json:"strict-reconfig-check"`
 	EnableV2              bool   `json:"enable-v2"`
 
+	// V2CacheControl is the Cache-Control header value set on v2 key
+	// GET responses. No header is set if empty.
+	V2CacheControl string `json:"v2-cache-control"`
+	// V2ETag is true to set a weak ETag, derived from the modified index of
+	// the key, on v2 key GET responses and to reply to matching
+	// If-None-Match requests with 304 Not Modified.
+	V2ETag bool `json:"v2-etag"`
+	// V2ImplicitDirs is true if the v2 PUT and POST requests create the
+	// missing parent directories of their key, unless they set
+	// implicitDirs=false. Otherwise they fail unless they set
+	// implicitDirs=true.
+	V2ImplicitDirs bool `json:"v2-implicit-dirs"`
+	// V2TombstoneRetention is how long the deleted v2 keys are retained
+	// as tombstones, for late watchers and undeletes. 0 disables the
+	// tombstones. The value of the leader applies to the whole cluster.
+	V2TombstoneRetention time.Duration `json:"v2-tombstone-retention"`
+	// V2ExpiredHistoryRetention is how long the events of the expired v2
+	// keys stay in the event history before they are pruned. 0 never
+	// prunes them. The value of the leader applies to the whole cluster.
+	V2ExpiredHistoryRetention time.Duration `json:"v2-expired-history-retention"`
+
 	// AutoCompactionMode is either 'periodic' or 'revision'.
 	AutoCompactionMode string `json:"auto-compaction-mode"`
 	// AutoCompactionRetention is either duration string with time unit
@@ -228,7 +318,30 @@ type Config struct {
 	// TODO: enable by default in 3.5.
 	PreVote bool `json:"pre-vote"`
 
+	// ElectionPriority biases leadership toward this member. The leader
+	// transfers leadership to the active member with the highest priority
+	// when it is higher than its own. It can be changed at runtime.
+	ElectionPriority uint `json:"election-priority"`
+
+	// Witness makes this member a witness: it votes and persists the raft
+	// log, but never serves client requests nor stays leader. Useful as a
+	// tie-breaker for clusters spread over two sites.
+	Witness bool `json:"witness"`
+
+	// LeaderPlacement constrains the metadata of the members that may stay
+	// leader, as comma-separated "key=v1|v2" and "key!=v1|v2" constraints,
+	// e.g. "zone=a|b". A leader it does not allow transfers leadership to
+	// a member it allows. The leader replicates its own to the cluster, so
+	// it should be the same on every member.
+	LeaderPlacement string `json:"leader-placement"`
+
 	CORS map[string]struct{}
+	// CORSExposeHeaders lists the response headers, such as X-Etcd-Index,
+	// that browsers may expose to cross-origin scripts.
+	CORSExposeHeaders []string `json:"cors-expose-headers"`
+	// CORSMaxAge is how long browsers may cache the response to a CORS
+	// preflight request. It is not advertised if 0.
+	CORSMaxAge time.Duration `json:"cors-max-age"`
 
 	// HostWhitelist lists acceptable hostnames from HTTP client requests.
 	// Client origin policy protects against "DNS Rebinding" attacks
@@ -256,6 +369,17 @@ type Config struct {
 	// - https://github.com/etcd-io/etcd/issues/9353
 	HostWhitelist map[string]struct{}
 
+	// User and Group, each given by name or ID, are the user and group
+	// that etcd serves as. If only User is set, its primary group is used.
+	// StartEtcd fails unless the process already runs as them. The etcd
+	// binary, when started as root, binds the TCP listeners, hands the
+	// data and WAL directories to them, and re-executes itself as them
+	// with the listeners, so that privileged ports can be used without
+	// serving as root. The TLS cert and key files are reloaded at runtime,
+	// so they must be readable by them.
+	User  string `json:"user"`
+	Group string `json:"group"`
+
 	// UserHandlers is for registering users handlers and only used for
 	// embedding etcd into other applications.
 	// The map key is the route path for the handler, and
@@ -269,6 +393,11 @@ type Config struct {
 	//	}
 	//	embed.StartEtcd(cfg)
 	ServiceRegister func(*grpc.Server) `json:"-"`
+	// ClientMiddlewares wraps the handler of the client HTTP requests, the
+	// gRPC gateway included, for embedding etcd into other applications,
+	// e.g. to check, log or rewrite the requests. They see the requests
+	// after the host whitelist check and the CORS middleware, in order.
+	ClientMiddlewares []etcdhttp.Middleware `json:"-"`
 
 	AuthToken  string `json:"auth-token"`
 	BcryptCost uint   `json:"bcrypt-cost"`
@@ -276,6 +405,58 @@ type Config struct {
 	ExperimentalInitialCorruptCheck bool          `json:"experimental-initial-corrupt-check"`
 	ExperimentalCorruptCheckTime    time.Duration `json:"experimental-corrupt-check-time"`
 	ExperimentalEnableV2V3          string        `json:"experimental-enable-v2v3"`
+	// ExperimentalDiskLatencyThreshold is the average WAL save or backend commit
+	// latency above which the member is marked degraded (0 disables the check).
+	ExperimentalDiskLatencyThreshold time.Duration `json:"experimental-disk-latency-threshold"`
+	// ExperimentalDiskDegradedTransferLeadership transfers leadership away from a degraded member.
+	ExperimentalDiskDegradedTransferLeadership bool `json:"experimental-disk-degraded-transfer-leadership"`
+	// ExperimentalMaxApplyLag is the number of committed but not yet applied entries above which
+	// the member refuses serializable v2 reads, e.g. while replaying the log after a restart.
+	ExperimentalMaxApplyLag uint64 `json:"experimental-max-apply-lag"`
+	// ExperimentalMaxPendingProposals is the number of local proposals waiting to be applied at
+	// which the member sheds client requests, with 503 or ResourceExhausted errors.
+	ExperimentalMaxPendingProposals int `json:"experimental-max-pending-proposals"`
+	// ExperimentalMaxForwardedProposals is the number of local proposals forwarded to the leader
+	// and waiting to be applied at which a follower refuses new proposals with a retriable error.
+	ExperimentalMaxForwardedProposals int `json:"experimental-max-forwarded-proposals"`
+	// ExperimentalV2WatchReplayEntries is the maximum number of committed entries replayed to
+	// recover the event of a v2 watch from an index cleared from the event history.
+	ExperimentalV2WatchReplayEntries int `json:"experimental-v2-watch-replay-entries"`
+	// ExperimentalMaxApplyBacklog is the number of committed but not yet applied entries above
+	// which the member sheds client requests.
+	ExperimentalMaxApplyBacklog uint64 `json:"experimental-max-apply-backlog"`
+	// ExperimentalMaxUnstableEntries is the number of entries of a raft Ready to persist above
+	// which the member sheds client requests, as its disk falls behind the proposals.
+	ExperimentalMaxUnstableEntries int `json:"experimental-max-unstable-entries"`
+	// ExperimentalSnapshotCompression is the compression of the written .snap files, "" or "flate".
+	ExperimentalSnapshotCompression string `json:"experimental-snapshot-compression"`
+	// ExperimentalSnapshotEncryptionKeyFiles, if not empty, are the files of the keys encrypting
+	// the written .snap files. The first key encrypts, and every key decrypts.
+	ExperimentalSnapshotEncryptionKeyFiles []string `json:"experimental-snapshot-encryption-key-files"`
+	// ExperimentalLeaseRead serves the linearizable reads from the lease of the leader,
+	// without the ReadIndex round trip to a quorum of members.
+	ExperimentalLeaseRead bool `json:"experimental-lease-read"`
+	// ExperimentalApplyAuditEntries is the number of last applied entries whose hashes are
+	// recorded, to find the entry from which two members diverged. 0 disables it.
+	ExperimentalApplyAuditEntries int `json:"experimental-apply-audit-entries"`
+	// ExperimentalFlightRecorderEntries is the number of last client mutations recorded with
+	// their responses, to reconstruct what happened before an incident. 0 disables it.
+	ExperimentalFlightRecorderEntries int `json:"experimental-flight-recorder-entries"`
+	// ExperimentalClientListenSockets is the number of sockets bound with SO_REUSEPORT, each with
+	// its own accept goroutine, per TCP client listen address. 0 or 1 opens a single socket.
+	ExperimentalClientListenSockets int `json:"experimental-client-listen-sockets"`
+	// ExperimentalApplyTimeout is the time after which the member stops, with a dump of the
+	// entry, if applying it has not returned. 0 disables it.
+	ExperimentalApplyTimeout time.Duration `json:"experimental-apply-timeout"`
+	// ExperimentalApplyMaxAttempts is the number of times in a row applying an entry may panic
+	// or time out before the member refuses to replay it on restart. 0 disables it.
+	ExperimentalApplyMaxAttempts int `json:"experimental-apply-max-attempts"`
+	// ExperimentalJoinSeed seeds the data directory of a member joining an existing cluster from
+	// the latest snapshot of a member, and only then adds the member to the cluster.
+	ExperimentalJoinSeed bool `json:"experimental-join-seed"`
+	// ExperimentalClientIdentityHeaders returns the identity and the remote address the member
+	// attributes a client request to in the headers of its response.
+	ExperimentalClientIdentityHeaders bool `json:"experimental-client-identity-headers"`
 	// ExperimentalBackendFreelistType specifies the type of freelist that boltdb backend uses (array and map are supported types).
 	ExperimentalBackendFreelistType string `json:"experimental-backend-bbolt-freelist-type"`
 
@@ -287,6 +468,29 @@ type Config struct {
 	ListenMetricsUrls     []url.URL
 	ListenMetricsUrlsJSON string `json:"listen-metrics-urls"`
 
+	// MetricsStatsdAddr, if set, is the "host:port" address of a statsd
+	// server the metrics are pushed to over UDP every MetricsStatsdInterval,
+	// in the MetricsStatsdFormat format ("statsd" or "dogstatsd").
+	MetricsStatsdAddr     string        `json:"metrics-statsd-addr"`
+	MetricsStatsdFormat   string        `json:"metrics-statsd-format"`
+	MetricsStatsdInterval time.Duration `json:"metrics-statsd-interval"`
+
+	// ListenAdminUrls, if set, are the only listeners serving the admin
+	// endpoints: member management, compaction, snapshot, defragment,
+	// alarms and leader transfer, pprof and the runtime information.
+	// The client and peer listeners refuse them.
+	ListenAdminUrls     []url.URL
+	ListenAdminUrlsJSON string `json:"listen-admin-urls"`
+
+	// ConfiguredFlags holds the flags, with secrets redacted, the
+	// configuration was built from. It is logged at startup and reported
+	// with the runtime information under "/debug/runtime".
+	ConfiguredFlags map[string]string `json:"-"`
+
+	// EnableDashboard serves a web UI for the v2 API under
+	// the "/dashboard/" path of client URLs.
+	EnableDashboard bool `json:"enable-dashboard"`
+
 	// Logger is logger options: "zap", "capnslog".
 	// WARN: "capnslog" is being deprecated in v3.5.
 	Logger string `json:"logger"`
@@ -352,6 +556,8 @@ type configJSON struct {
 
 	ClientSecurityJSON securityConfig `json:"client-transport-security"`
 	PeerSecurityJSON   securityConfig `json:"peer-transport-security"`
+
+	ClientSecurityOverridesJSON map[string]securityConfig `json:"client-transport-security-overrides"`
 }
 
 type securityConfig struct {
@@ -372,6 +578,8 @@ func NewConfig() *Config {
 		MaxSnapFiles: DefaultMaxSnapshots,
 		MaxWalFiles:  DefaultMaxWALs,
 
+		WALPreallocateBytes: wal.SegmentSizeBytes,
+
 		Name: DefaultName,
 
 		SnapshotCount:          etcdserver.DefaultSnapshotCount,
@@ -396,9 +604,15 @@ func NewConfig() *Config {
 		ClusterState:        ClusterStateFlagNew,
 		InitialClusterToken: "etcd-cluster",
 
+		PeerTransport: rafthttp.ProtocolHTTP,
+
 		StrictReconfigCheck: DefaultStrictReconfigCheck,
 		Metrics:             "basic",
 		EnableV2:            DefaultEnableV2,
+		V2ImplicitDirs:      true,
+
+		MetricsStatsdFormat:   statsd.FormatStatsd,
+		MetricsStatsdInterval: DefaultMetricsStatsdInterval,
 
 		CORS:          map[string]struct{}{"*": {}},
 		HostWhitelist: map[string]struct{}{"*": {}},
@@ -499,6 +713,15 @@ func (cfg *configYAML) configFromFile(path string) error {
 		cfg.ListenMetricsUrls = []url.URL(u)
 	}
 
+	if cfg.ListenAdminUrlsJSON != "" {
+		u, err := types.NewURLs(strings.Split(cfg.ListenAdminUrlsJSON, ","))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "unexpected error setting up listen-admin-urls: %v\n", err)
+			os.Exit(1)
+		}
+		cfg.ListenAdminUrls = []url.URL(u)
+	}
+
 	if cfg.CORSJSON != "" {
 		uv := flags.NewUniqueURLsWithExceptions(cfg.CORSJSON, "*")
 		cfg.CORS = uv.Values
@@ -528,9 +751,75 @@ func (cfg *configYAML) configFromFile(path string) error {
 	cfg.ClientAutoTLS = cfg.ClientSecurityJSON.AutoTLS
 	cfg.PeerAutoTLS = cfg.PeerSecurityJSON.AutoTLS
 
+	if len(cfg.ClientSecurityOverridesJSON) > 0 {
+		cfg.ClientTLSOverrides = make(map[string]transport.TLSInfo)
+	}
+	for s, ysc := range cfg.ClientSecurityOverridesJSON {
+		u, err := url.Parse(s)
+		if err != nil {
+			return fmt.Errorf("unexpected error setting up client-transport-security-overrides: %v", err)
+		}
+		if ysc.AutoTLS {
+			return fmt.Errorf("auto-tls is not supported in client-transport-security-overrides (given %q)", s)
+		}
+		var tls transport.TLSInfo
+		copySecurityDetails(&tls, &ysc)
+		cfg.ClientTLSOverrides[u.String()] = tls
+	}
+
 	return cfg.Validate()
 }
 
+// isClientListenURL returns true if s is one of the listen client or admin
+// URLs.
+func (cfg *Config) isClientListenURL(s string) bool {
+	for _, u := range append(append([]url.URL{}, cfg.LCUrls...), cfg.ListenAdminUrls...) {
+		if u.String() == s {
+			return true
+		}
+	}
+	return false
+}
+
+// sharedClientURL returns the listen client URL whose address u shares, if
+// any. A listen peer or metrics URL sharing the address of a client URL is
+// served on its listener, telling the requests apart by their protocol and
+// path.
+func (cfg *Config) sharedClientURL(u url.URL) (url.URL, bool) {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return url.URL{}, false
+	}
+	for _, cu := range cfg.LCUrls {
+		if (cu.Scheme == "http" || cu.Scheme == "https") && cu.Host == u.Host {
+			return cu, true
+		}
+	}
+	return url.URL{}, false
+}
+
+// clientTLSInfo returns the TLS settings of the listen client or admin URL
+// u, which are ClientTLSInfo unless overridden.
+func (cfg *Config) clientTLSInfo(u url.URL) *transport.TLSInfo {
+	if tls, ok := cfg.ClientTLSOverrides[u.String()]; ok {
+		return &tls
+	}
+	return &cfg.ClientTLSInfo
+}
+
+// clientCertAuth returns true if any of the client listeners verifies
+// the client certificates.
+func (cfg *Config) clientCertAuth() bool {
+	if cfg.ClientTLSInfo.ClientCertAuth {
+		return true
+	}
+	for _, tls := range cfg.ClientTLSOverrides {
+		if tls.ClientCertAuth {
+			return true
+		}
+	}
+	return false
+}
+
 func updateCipherSuites(tls *transport.TLSInfo, ss []string) error {
 	if len(tls.CipherSuites) > 0 && len(ss) > 0 {
 		return fmt.Errorf("TLSInfo.CipherSuites is already specified (given %v)", ss)
@@ -549,6 +838,27 @@ func updateCipherSuites(tls *transport.TLSInfo, ss []string) error {
 	return nil
 }
 
+// updateTLSInfo sets the cipher suites and the TLS tuning of cfg in tls.
+func (cfg *Config) updateTLSInfo(tls *transport.TLSInfo) error {
+	if err := updateCipherSuites(tls, cfg.CipherSuites); err != nil {
+		return err
+	}
+	if cfg.TLSMinVersion != "" {
+		v, ok := tlsutil.GetTLSVersion(cfg.TLSMinVersion)
+		if !ok {
+			return fmt.Errorf("unexpected TLS version %q (expected \"TLS1.2\", or \"TLS1.3\" when built with Go 1.12+)", cfg.TLSMinVersion)
+		}
+		tls.MinVersion = v
+	}
+	if cfg.TLSSessionTicketsDisabled {
+		tls.SessionTicketsDisabled = true
+	}
+	if cfg.TLSClientSessionCacheSize > 0 {
+		tls.ClientSessionCacheSize = cfg.TLSClientSessionCacheSize
+	}
+	return nil
+}
+
 // Validate ensures that '*embed.Config' fields are properly configured.
 func (cfg *Config) Validate() error {
 	if err := cfg.setupLogging(); err != nil {
@@ -563,6 +873,26 @@ func (cfg *Config) Validate() error {
 	if err := checkBindURLs(cfg.ListenMetricsUrls); err != nil {
 		return err
 	}
+	if err := checkBindURLs(cfg.ListenAdminUrls); err != nil {
+		return err
+	}
+	for _, au := range cfg.ListenAdminUrls {
+		for _, cu := range cfg.LCUrls {
+			if au.Host == cu.Host {
+				return fmt.Errorf("--listen-admin-urls %q must not use the address of a client URL", au.String())
+			}
+		}
+	}
+	for _, u := range append(append([]url.URL{}, cfg.LPUrls...), cfg.ListenMetricsUrls...) {
+		if cu, ok := cfg.sharedClientURL(u); ok && cu.Scheme != u.Scheme {
+			return fmt.Errorf("URL %q shares the address of the client URL %q with another scheme", u.String(), cu.String())
+		}
+	}
+	for s := range cfg.ClientTLSOverrides {
+		if !cfg.isClientListenURL(s) {
+			return fmt.Errorf("client TLS override %q must be one of the listen client or admin URLs", s)
+		}
+	}
 	if err := checkHostURLs(cfg.APUrls); err != nil {
 		addrs := cfg.getAPURLs()
 		return fmt.Errorf(`--initial-advertise-peer-urls %q must be "host:port" (%v)`, strings.Join(addrs, ","), err)
@@ -587,6 +917,15 @@ func (cfg *Config) Validate() error {
 		return ErrConflictBootstrapFlags
 	}
 
+	if cfg.TLSMinVersion != "" {
+		if _, ok := tlsutil.GetTLSVersion(cfg.TLSMinVersion); !ok {
+			return fmt.Errorf("--tls-min-version %q must be \"TLS1.2\", or \"TLS1.3\" when built with Go 1.12+", cfg.TLSMinVersion)
+		}
+	}
+	if cfg.TLSClientSessionCacheSize < 0 {
+		return fmt.Errorf("--tls-client-session-cache-size must be >=0 (set to %d)", cfg.TLSClientSessionCacheSize)
+	}
+
 	if cfg.TickMs <= 0 {
 		return fmt.Errorf("--heartbeat-interval must be >0 (set to %dms)", cfg.TickMs)
 	}
@@ -599,12 +938,46 @@ func (cfg *Config) Validate() error {
 	if cfg.ElectionMs > maxElectionMs {
 		return fmt.Errorf("--election-timeout[%vms] is too long, and should be set less than %vms", cfg.ElectionMs, maxElectionMs)
 	}
+	if cfg.WALPreallocateBytes > wal.SegmentSizeBytes {
+		return fmt.Errorf("--wal-preallocate-bytes[%v] must not exceed the WAL segment size %v", cfg.WALPreallocateBytes, wal.SegmentSizeBytes)
+	}
 
 	// check this last since proxying in etcdmain may make this OK
 	if cfg.LCUrls != nil && cfg.ACUrls == nil {
 		return ErrUnsetAdvertiseClientURLsFlag
 	}
 
+	switch cfg.PeerTransport {
+	case "", rafthttp.ProtocolHTTP:
+	case rafthttp.ProtocolGRPC:
+		// the gRPC streams are authenticated by the client certificates
+		if !cfg.PeerTLSInfo.ClientCertAuth {
+			return fmt.Errorf("peer-transport %q requires peer-client-cert-auth", cfg.PeerTransport)
+		}
+	default:
+		return fmt.Errorf("unknown peer-transport %q", cfg.PeerTransport)
+	}
+	if cfg.PeerDialTimeout < 0 || cfg.PeerRequestTimeout < 0 {
+		return fmt.Errorf("negative peer-dial-timeout %v or peer-request-timeout %v", cfg.PeerDialTimeout, cfg.PeerRequestTimeout)
+	}
+	if err := snap.ValidateCompression(cfg.ExperimentalSnapshotCompression); err != nil {
+		return fmt.Errorf("invalid experimental-snapshot-compression (%v)", err)
+	}
+	if cfg.MetricsStatsdAddr != "" {
+		if err := statsd.ValidateFormat(cfg.MetricsStatsdFormat); err != nil {
+			return err
+		}
+		if cfg.MetricsStatsdInterval <= 0 {
+			return fmt.Errorf("metrics-statsd-interval %v must be positive", cfg.MetricsStatsdInterval)
+		}
+	}
+	if _, err := cfg.LeaderPlacementConstraints(); err != nil {
+		return err
+	}
+	if _, err := cfg.PeerAllowedNetworks(); err != nil {
+		return err
+	}
+
 	switch cfg.AutoCompactionMode {
 	case "":
 	case CompactorModeRevision, CompactorModePeriodic:
@@ -761,7 +1134,7 @@ func (cfg *Config) ClientSelfCert() (err error) {
 	if err != nil {
 		return err
 	}
-	return updateCipherSuites(&cfg.ClientTLSInfo, cfg.CipherSuites)
+	return cfg.updateTLSInfo(&cfg.ClientTLSInfo)
 }
 
 func (cfg *Config) PeerSelfCert() (err error) {
@@ -784,7 +1157,58 @@ func (cfg *Config) PeerSelfCert() (err error) {
 	if err != nil {
 		return err
 	}
-	return updateCipherSuites(&cfg.PeerTLSInfo, cfg.CipherSuites)
+	return cfg.updateTLSInfo(&cfg.PeerTLSInfo)
+}
+
+// PeerSharedSecret returns the secret read from PeerSharedSecretFile, with
+// surrounding whitespace trimmed, or nil if no file is configured.
+func (cfg *Config) PeerSharedSecret() ([]byte, error) {
+	if cfg.PeerSharedSecretFile == "" {
+		return nil, nil
+	}
+	b, err := ioutil.ReadFile(cfg.PeerSharedSecretFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read peer shared secret (%v)", err)
+	}
+	b = bytes.TrimSpace(b)
+	if len(b) == 0 {
+		return nil, fmt.Errorf("peer shared secret file %q is empty", cfg.PeerSharedSecretFile)
+	}
+	return b, nil
+}
+
+// PeerAllowedNetworks returns the networks parsed from PeerAllowedHosts.
+func (cfg *Config) PeerAllowedNetworks() (transport.AllowedNetworks, error) {
+	ns, err := transport.ParseAllowedNetworks(cfg.PeerAllowedHosts)
+	if err != nil {
+		return nil, fmt.Errorf("invalid peer-allowed-hosts (%v)", err)
+	}
+	return ns, nil
+}
+
+// SnapshotCodec returns the codec of the written .snap files, reading the
+// encryption keys from ExperimentalSnapshotEncryptionKeyFiles.
+func (cfg *Config) SnapshotCodec() (snap.Codec, error) {
+	c := snap.Codec{Compression: cfg.ExperimentalSnapshotCompression}
+	if len(cfg.ExperimentalSnapshotEncryptionKeyFiles) == 0 {
+		return c, nil
+	}
+	keys, err := snap.NewFileKeyProvider(cfg.ExperimentalSnapshotEncryptionKeyFiles)
+	if err != nil {
+		return c, fmt.Errorf("invalid experimental-snapshot-encryption-key-files (%v)", err)
+	}
+	c.Keys = keys
+	return c, nil
+}
+
+// LeaderPlacementConstraints returns the constraints parsed from
+// LeaderPlacement.
+func (cfg *Config) LeaderPlacementConstraints() (etcdserver.LeaderPlacement, error) {
+	lp, err := etcdserver.ParseLeaderPlacement(cfg.LeaderPlacement)
+	if err != nil {
+		return nil, fmt.Errorf("invalid leader-placement (%v)", err)
+	}
+	return lp, nil
 }
 
 // UpdateDefaultClusterFromName updates cluster advertise URLs with, if available, default host,
@@ -902,6 +1326,14 @@ func (cfg *Config) getMetricsURLs() (ss []string) {
 	return ss
 }
 
+func (cfg *Config) getAdminURLs() (ss []string) {
+	ss = make([]string, len(cfg.ListenAdminUrls))
+	for i := range cfg.ListenAdminUrls {
+		ss[i] = cfg.ListenAdminUrls[i].String()
+	}
+	return ss
+}
+
 func parseBackendFreelistType(freelistType string) bolt.FreelistType {
 	if freelistType == freelistMapType {
 		return bolt.FreelistMapType
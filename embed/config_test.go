@@ -71,6 +71,56 @@ func TestConfigFileOtherFields(t *testing.T) {
 	}
 }
 
+func TestConfigFileClientTLSOverrides(t *testing.T) {
+	ctls := securityConfig{TrustedCAFile: "cca", CertFile: "ccert", KeyFile: "ckey"}
+	otls := securityConfig{TrustedCAFile: "oca", CertFile: "ocert", KeyFile: "okey", CertAuth: true}
+	yc := struct {
+		ListenClientUrls        string                    `json:"listen-client-urls"`
+		ClientSecurityCfgFile   securityConfig            `json:"client-transport-security"`
+		ClientSecurityOverrides map[string]securityConfig `json:"client-transport-security-overrides"`
+		Logger                  string                    `json:"logger"`
+		LogOutputs              []string                  `json:"log-outputs"`
+	}{
+		"http://localhost:2379,https://10.0.0.1:2379",
+		ctls,
+		map[string]securityConfig{"https://10.0.0.1:2379": otls},
+		"zap",
+		[]string{"/dev/null"},
+	}
+
+	b, err := yaml.Marshal(&yc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpfile := mustCreateCfgFile(t, b)
+	defer os.Remove(tmpfile.Name())
+
+	cfg, err := ConfigFromFile(tmpfile.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tls := cfg.clientTLSInfo(cfg.LCUrls[0]); !ctls.equals(tls) {
+		t.Errorf("ClientTLS of %s = %v, want %v", cfg.LCUrls[0].String(), tls, ctls)
+	}
+	if tls := cfg.clientTLSInfo(cfg.LCUrls[1]); !otls.equals(tls) {
+		t.Errorf("ClientTLS of %s = %v, want %v", cfg.LCUrls[1].String(), tls, otls)
+	}
+	if !cfg.clientCertAuth() {
+		t.Errorf("clientCertAuth() = false, want true")
+	}
+
+	// overrides must be of a listen URL
+	yc.ClientSecurityOverrides = map[string]securityConfig{"https://10.0.0.2:2379": otls}
+	if b, err = yaml.Marshal(&yc); err != nil {
+		t.Fatal(err)
+	}
+	tmpfile2 := mustCreateCfgFile(t, b)
+	defer os.Remove(tmpfile2.Name())
+	if _, err = ConfigFromFile(tmpfile2.Name()); err == nil {
+		t.Errorf("expected error on the override of an unknown URL")
+	}
+}
+
 // TestUpdateDefaultClusterFromName ensures that etcd can start with 'etcd --name=abc'.
 func TestUpdateDefaultClusterFromName(t *testing.T) {
 	cfg := NewConfig()
@@ -167,6 +217,62 @@ func TestAutoCompactionModeInvalid(t *testing.T) {
 	}
 }
 
+func TestListenAdminURLsValidate(t *testing.T) {
+	tests := []struct {
+		admin string
+		werr  bool
+	}{
+		{"http://127.0.0.1:2381", false},
+		{"unix://localhost:2381", false},
+		// same address as the default client URL
+		{"http://localhost:2379", true},
+		{"http://example.com:2381", true},
+	}
+	for i, tt := range tests {
+		cfg := NewConfig()
+		cfg.Logger = "zap"
+		cfg.LogOutputs = []string{"/dev/null"}
+		u, err := url.Parse(tt.admin)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg.ListenAdminUrls = []url.URL{*u}
+		if err = cfg.Validate(); (err != nil) != tt.werr {
+			t.Errorf("#%d: validate error = %v, want error %v", i, err, tt.werr)
+		}
+	}
+}
+
+func TestSharedClientURLValidate(t *testing.T) {
+	tests := []struct {
+		peer, metrics string
+		werr          bool
+	}{
+		{"http://localhost:2379", "http://localhost:2381", false},
+		{"http://localhost:2380", "http://localhost:2379", false},
+		// same address as the default client URL, with another scheme
+		{"https://localhost:2379", "http://localhost:2381", true},
+		{"http://localhost:2380", "https://localhost:2379", true},
+	}
+	for i, tt := range tests {
+		cfg := NewConfig()
+		cfg.Logger = "zap"
+		cfg.LogOutputs = []string{"/dev/null"}
+		pu, err := url.Parse(tt.peer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mu, err := url.Parse(tt.metrics)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg.LPUrls, cfg.ListenMetricsUrls = []url.URL{*pu}, []url.URL{*mu}
+		if err = cfg.Validate(); (err != nil) != tt.werr {
+			t.Errorf("#%d: validate error = %v, want error %v", i, err, tt.werr)
+		}
+	}
+}
+
 func TestAutoCompactionModeParse(t *testing.T) {
 	tests := []struct {
 		mode      string
@@ -23,6 +23,7 @@ import (
 	"net"
 	"net/http"
 	"net/url"
+	"os"
 	"runtime"
 	"sort"
 	"strconv"
@@ -30,6 +31,7 @@ import (
 	"time"
 
 	"go.etcd.io/etcd/etcdserver"
+	"go.etcd.io/etcd/etcdserver/api/dashboard"
 	"go.etcd.io/etcd/etcdserver/api/etcdhttp"
 	"go.etcd.io/etcd/etcdserver/api/rafthttp"
 	"go.etcd.io/etcd/etcdserver/api/v2http"
@@ -37,13 +39,16 @@ import (
 	"go.etcd.io/etcd/etcdserver/api/v3client"
 	"go.etcd.io/etcd/etcdserver/api/v3rpc"
 	"go.etcd.io/etcd/pkg/debugutil"
+	"go.etcd.io/etcd/pkg/osutil"
 	runtimeutil "go.etcd.io/etcd/pkg/runtime"
+	"go.etcd.io/etcd/pkg/statsd"
 	"go.etcd.io/etcd/pkg/transport"
 	"go.etcd.io/etcd/pkg/types"
 	"go.etcd.io/etcd/version"
 
 	"github.com/coreos/pkg/capnslog"
 	grpc_prometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/soheilhy/cmux"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
@@ -64,6 +69,11 @@ const (
 	// in a cluster, so it should reserve 96.
 	// For the safety, we set the total reserved number to 150.
 	reservedInternalFDNum = 150
+
+	// recommendedClientFDNum is the number of file descriptors recommended
+	// for the connections of every client listener. Every client connection
+	// holds one, and the clients of the watchers tend to keep many open.
+	recommendedClientFDNum = 1024
 )
 
 // Etcd contains a running etcd server and its listeners.
@@ -73,6 +83,7 @@ type Etcd struct {
 	// a map of contexts for the servers that serves client requests.
 	sctxs            map[string]*serveCtx
 	metricsListeners []net.Listener
+	statsd           *statsd.Exporter
 
 	Server *etcdserver.EtcdServer
 
@@ -85,8 +96,10 @@ type Etcd struct {
 
 type peerListener struct {
 	net.Listener
-	serve func() error
-	close func(context.Context) error
+	// secure is true if the listener terminates TLS
+	secure bool
+	serve  func() error
+	close  func(context.Context) error
 }
 
 // StartEtcd launches the etcd server and HTTP handlers for client/server communication.
@@ -113,6 +126,8 @@ func StartEtcd(inCfg *Config) (e *Etcd, err error) {
 		e = nil
 	}()
 
+	checkFDLimit(cfg)
+
 	if e.cfg.logger != nil {
 		e.cfg.logger.Info(
 			"configuring peer listeners",
@@ -134,7 +149,13 @@ func StartEtcd(inCfg *Config) (e *Etcd, err error) {
 	}
 
 	for _, sctx := range e.sctxs {
-		e.Clients = append(e.Clients, sctx.l)
+		e.Clients = append(e.Clients, sctx.ls...)
+	}
+
+	if cfg.User != "" || cfg.Group != "" {
+		if err = checkUserGroup(cfg); err != nil {
+			return e, err
+		}
 	}
 
 	var (
@@ -161,49 +182,103 @@ func StartEtcd(inCfg *Config) (e *Etcd, err error) {
 
 	backendFreelistType := parseBackendFreelistType(cfg.ExperimentalBackendFreelistType)
 
+	peerSharedSecret, err := cfg.PeerSharedSecret()
+	if err != nil {
+		return e, err
+	}
+	peerAllowedNetworks, err := cfg.PeerAllowedNetworks()
+	if err != nil {
+		return e, err
+	}
+	leaderPlacement, err := cfg.LeaderPlacementConstraints()
+	if err != nil {
+		return e, err
+	}
+	snapshotCodec, err := cfg.SnapshotCodec()
+	if err != nil {
+		return e, err
+	}
+
 	srvcfg := etcdserver.ServerConfig{
-		Name:                       cfg.Name,
-		ClientURLs:                 cfg.ACUrls,
-		PeerURLs:                   cfg.APUrls,
-		DataDir:                    cfg.Dir,
-		DedicatedWALDir:            cfg.WalDir,
-		SnapshotCount:              cfg.SnapshotCount,
-		SnapshotCatchUpEntries:     cfg.SnapshotCatchUpEntries,
-		MaxSnapFiles:               cfg.MaxSnapFiles,
-		MaxWALFiles:                cfg.MaxWalFiles,
-		InitialPeerURLsMap:         urlsmap,
-		InitialClusterToken:        token,
-		DiscoveryURL:               cfg.Durl,
-		DiscoveryProxy:             cfg.Dproxy,
-		NewCluster:                 cfg.IsNewCluster(),
-		PeerTLSInfo:                cfg.PeerTLSInfo,
-		TickMs:                     cfg.TickMs,
-		ElectionTicks:              cfg.ElectionTicks(),
-		InitialElectionTickAdvance: cfg.InitialElectionTickAdvance,
-		AutoCompactionRetention:    autoCompactionRetention,
-		AutoCompactionMode:         cfg.AutoCompactionMode,
-		QuotaBackendBytes:          cfg.QuotaBackendBytes,
-		BackendBatchLimit:          cfg.BackendBatchLimit,
-		BackendFreelistType:        backendFreelistType,
-		BackendBatchInterval:       cfg.BackendBatchInterval,
-		MaxTxnOps:                  cfg.MaxTxnOps,
-		MaxRequestBytes:            cfg.MaxRequestBytes,
-		StrictReconfigCheck:        cfg.StrictReconfigCheck,
-		ClientCertAuthEnabled:      cfg.ClientTLSInfo.ClientCertAuth,
-		AuthToken:                  cfg.AuthToken,
-		BcryptCost:                 cfg.BcryptCost,
-		CORS:                       cfg.CORS,
-		HostWhitelist:              cfg.HostWhitelist,
-		InitialCorruptCheck:        cfg.ExperimentalInitialCorruptCheck,
-		CorruptCheckTime:           cfg.ExperimentalCorruptCheckTime,
-		PreVote:                    cfg.PreVote,
-		Logger:                     cfg.logger,
-		LoggerConfig:               cfg.loggerConfig,
-		LoggerCore:                 cfg.loggerCore,
-		LoggerWriteSyncer:          cfg.loggerWriteSyncer,
-		Debug:                      cfg.Debug,
-		ForceNewCluster:            cfg.ForceNewCluster,
-		EnableGRPCGateway:          cfg.EnableGRPCGateway,
+		Name:                           cfg.Name,
+		ClientURLs:                     cfg.ACUrls,
+		PeerURLs:                       cfg.APUrls,
+		DataDir:                        cfg.Dir,
+		DedicatedWALDir:                cfg.WalDir,
+		SnapshotCount:                  cfg.SnapshotCount,
+		SnapshotCountAuto:              cfg.SnapshotCountAuto,
+		SnapshotCatchUpEntries:         cfg.SnapshotCatchUpEntries,
+		MaxSnapFiles:                   cfg.MaxSnapFiles,
+		MaxWALFiles:                    cfg.MaxWalFiles,
+		WALPreallocateBytes:            cfg.WALPreallocateBytes,
+		InitialPeerURLsMap:             urlsmap,
+		InitialClusterToken:            token,
+		DiscoveryURL:                   cfg.Durl,
+		DiscoveryProxy:                 cfg.Dproxy,
+		NewCluster:                     cfg.IsNewCluster(),
+		PeerTLSInfo:                    cfg.PeerTLSInfo,
+		PeerSharedSecret:               peerSharedSecret,
+		PeerAllowedNetworks:            peerAllowedNetworks,
+		PeerTransport:                  cfg.PeerTransport,
+		PeerDialTimeout:                cfg.PeerDialTimeout,
+		PeerRequestTimeout:             cfg.PeerRequestTimeout,
+		TickMs:                         cfg.TickMs,
+		ElectionTicks:                  cfg.ElectionTicks(),
+		InitialElectionTickAdvance:     cfg.InitialElectionTickAdvance,
+		AutoCompactionRetention:        autoCompactionRetention,
+		AutoCompactionMode:             cfg.AutoCompactionMode,
+		QuotaBackendBytes:              cfg.QuotaBackendBytes,
+		BackendBatchLimit:              cfg.BackendBatchLimit,
+		BackendFreelistType:            backendFreelistType,
+		BackendBatchInterval:           cfg.BackendBatchInterval,
+		MaxTxnOps:                      cfg.MaxTxnOps,
+		MaxRecursiveKeys:               cfg.MaxRecursiveKeys,
+		MaxWatchersPerClient:           cfg.MaxWatchersPerClient,
+		MaxWatchersPerKey:              cfg.MaxWatchersPerKey,
+		MemoryLimitBytes:               cfg.MemoryLimitBytes,
+		MaxRequestBytes:                cfg.MaxRequestBytes,
+		StrictReconfigCheck:            cfg.StrictReconfigCheck,
+		ClientCertAuthEnabled:          cfg.clientCertAuth(),
+		V2CacheControl:                 cfg.V2CacheControl,
+		V2ETag:                         cfg.V2ETag,
+		V2ExplicitDirs:                 !cfg.V2ImplicitDirs,
+		V2TombstoneRetention:           cfg.V2TombstoneRetention,
+		V2ExpiredHistoryRetention:      cfg.V2ExpiredHistoryRetention,
+		AuthToken:                      cfg.AuthToken,
+		BcryptCost:                     cfg.BcryptCost,
+		CORS:                           cfg.CORS,
+		CORSExposeHeaders:              cfg.CORSExposeHeaders,
+		CORSMaxAge:                     cfg.CORSMaxAge,
+		HostWhitelist:                  cfg.HostWhitelist,
+		InitialCorruptCheck:            cfg.ExperimentalInitialCorruptCheck,
+		CorruptCheckTime:               cfg.ExperimentalCorruptCheckTime,
+		DiskLatencyThreshold:           cfg.ExperimentalDiskLatencyThreshold,
+		DiskDegradedTransferLeadership: cfg.ExperimentalDiskDegradedTransferLeadership,
+		MaxApplyLag:                    cfg.ExperimentalMaxApplyLag,
+		MaxPendingProposals:            cfg.ExperimentalMaxPendingProposals,
+		MaxForwardedProposals:          cfg.ExperimentalMaxForwardedProposals,
+		V2WatchReplayEntries:           cfg.ExperimentalV2WatchReplayEntries,
+		MaxApplyBacklog:                cfg.ExperimentalMaxApplyBacklog,
+		MaxUnstableEntries:             cfg.ExperimentalMaxUnstableEntries,
+		SnapshotCodec:                  snapshotCodec,
+		LeaseRead:                      cfg.ExperimentalLeaseRead,
+		ApplyAuditEntries:              cfg.ExperimentalApplyAuditEntries,
+		FlightRecorderEntries:          cfg.ExperimentalFlightRecorderEntries,
+		ApplyTimeout:                   cfg.ExperimentalApplyTimeout,
+		ApplyMaxAttempts:               cfg.ExperimentalApplyMaxAttempts,
+		JoinSeed:                       cfg.ExperimentalJoinSeed,
+		ClientIdentityHeaders:          cfg.ExperimentalClientIdentityHeaders,
+		PreVote:                        cfg.PreVote,
+		ElectionPriority:               cfg.ElectionPriority,
+		Witness:                        cfg.Witness,
+		LeaderPlacement:                leaderPlacement,
+		Logger:                         cfg.logger,
+		LoggerConfig:                   cfg.loggerConfig,
+		LoggerCore:                     cfg.loggerCore,
+		LoggerWriteSyncer:              cfg.loggerWriteSyncer,
+		Debug:                          cfg.Debug,
+		ForceNewCluster:                cfg.ForceNewCluster,
+		EnableGRPCGateway:              cfg.EnableGRPCGateway,
 	}
 	print(e.cfg.logger, *cfg, srvcfg, memberInitialized)
 	if e.Server, err = etcdserver.NewServer(srvcfg); err != nil {
@@ -244,6 +319,7 @@ func StartEtcd(inCfg *Config) (e *Etcd, err error) {
 			zap.Strings("advertise-client-urls", e.cfg.getACURLs()),
 			zap.Strings("listen-client-urls", e.cfg.getLCURLs()),
 			zap.Strings("listen-metrics-urls", e.cfg.getMetricsURLs()),
+			zap.Strings("listen-admin-urls", e.cfg.getAdminURLs()),
 		)
 	}
 	serving = true
@@ -264,7 +340,7 @@ func print(lg *zap.Logger, ec Config, sc etcdserver.ServerConfig, memberInitiali
 		}
 		plog.Infof("heartbeat = %dms", sc.TickMs)
 		plog.Infof("election = %dms", sc.ElectionTicks*int(sc.TickMs))
-		plog.Infof("snapshot count = %d", sc.SnapshotCount)
+		plog.Infof("snapshot count = %d (auto = %v)", sc.SnapshotCount, sc.SnapshotCountAuto)
 		if len(sc.DiscoveryURL) != 0 {
 			plog.Infof("discovery URL= %s", sc.DiscoveryURL)
 			if len(sc.DiscoveryProxy) != 0 {
@@ -276,6 +352,9 @@ func print(lg *zap.Logger, ec Config, sc etcdserver.ServerConfig, memberInitiali
 			plog.Infof("initial advertise peer URLs = %s", sc.PeerURLs)
 			plog.Infof("initial cluster = %s", sc.InitialPeerURLsMap)
 		}
+		if len(ec.ConfiguredFlags) != 0 {
+			plog.Infof("configured flags = %v", ec.ConfiguredFlags)
+		}
 	} else {
 		cors := make([]string, 0, len(ec.CORS))
 		for v := range ec.CORS {
@@ -314,19 +393,26 @@ func print(lg *zap.Logger, ec Config, sc etcdserver.ServerConfig, memberInitiali
 			zap.String("election-timeout", fmt.Sprintf("%v", time.Duration(sc.ElectionTicks*int(sc.TickMs))*time.Millisecond)),
 			zap.Bool("initial-election-tick-advance", sc.InitialElectionTickAdvance),
 			zap.Uint64("snapshot-count", sc.SnapshotCount),
+			zap.Bool("snapshot-count-auto", sc.SnapshotCountAuto),
 			zap.Uint64("snapshot-catchup-entries", sc.SnapshotCatchUpEntries),
 			zap.Strings("initial-advertise-peer-urls", ec.getAPURLs()),
 			zap.Strings("listen-peer-urls", ec.getLPURLs()),
 			zap.Strings("advertise-client-urls", ec.getACURLs()),
 			zap.Strings("listen-client-urls", ec.getLCURLs()),
 			zap.Strings("listen-metrics-urls", ec.getMetricsURLs()),
+			zap.Strings("listen-admin-urls", ec.getAdminURLs()),
 			zap.Strings("cors", cors),
+			zap.Strings("cors-expose-headers", ec.CORSExposeHeaders),
+			zap.Duration("cors-max-age", ec.CORSMaxAge),
 			zap.Strings("host-whitelist", hss),
 			zap.String("initial-cluster", sc.InitialPeerURLsMap.String()),
 			zap.String("initial-cluster-state", ec.ClusterState),
 			zap.String("initial-cluster-token", sc.InitialClusterToken),
 			zap.Int64("quota-size-bytes", quota),
 			zap.Bool("pre-vote", sc.PreVote),
+			zap.Bool("lease-read", sc.LeaseRead),
+			zap.Int("apply-audit-entries", sc.ApplyAuditEntries),
+			zap.Int("flight-recorder-entries", sc.FlightRecorderEntries),
 			zap.Bool("initial-corrupt-check", sc.InitialCorruptCheck),
 			zap.String("corrupt-check-time-interval", sc.CorruptCheckTime.String()),
 			zap.String("auto-compaction-mode", sc.AutoCompactionMode),
@@ -334,6 +420,7 @@ func print(lg *zap.Logger, ec Config, sc etcdserver.ServerConfig, memberInitiali
 			zap.String("auto-compaction-interval", sc.AutoCompactionRetention.String()),
 			zap.String("discovery-url", sc.DiscoveryURL),
 			zap.String("discovery-proxy", sc.DiscoveryProxy),
+			zap.Any("configured-flags", ec.ConfiguredFlags),
 		)
 	}
 }
@@ -381,6 +468,9 @@ func (e *Etcd) Close() {
 
 	for _, sctx := range e.sctxs {
 		sctx.cancel()
+		if sctx.peer != nil && sctx.peer.grpc != nil {
+			sctx.peer.grpc.Stop()
+		}
 	}
 
 	for i := range e.Clients {
@@ -392,6 +482,9 @@ func (e *Etcd) Close() {
 	for i := range e.metricsListeners {
 		e.metricsListeners[i].Close()
 	}
+	if e.statsd != nil {
+		e.statsd.Stop()
+	}
 
 	// close rafthttp transports
 	if e.Server != nil {
@@ -447,8 +540,33 @@ func stopServers(ctx context.Context, ss *servers) {
 
 func (e *Etcd) Err() <-chan error { return e.errc }
 
+// checkFDLimit warns when the file descriptor limit leaves less than
+// recommendedClientFDNum descriptors to the connections of every client
+// listener: once it is reached, the connections are held by the listeners
+// without being served.
+func checkFDLimit(cfg *Config) {
+	fdLimit, err := runtimeutil.FDLimit()
+	if err != nil {
+		return
+	}
+	recommended := uint64(reservedInternalFDNum + recommendedClientFDNum*len(cfg.LCUrls))
+	if fdLimit >= recommended {
+		return
+	}
+	if cfg.logger != nil {
+		cfg.logger.Warn(
+			"file descriptor limit of etcd process is low for the client listeners; please set higher",
+			zap.Uint64("limit", fdLimit),
+			zap.Uint64("recommended-limit", recommended),
+			zap.Int("client-listeners", len(cfg.LCUrls)),
+		)
+	} else {
+		plog.Warningf("file descriptor limit[%d] of etcd process is low for %d client listener(s), and should be set at least to %d", fdLimit, len(cfg.LCUrls), recommended)
+	}
+}
+
 func configurePeerListeners(cfg *Config) (peers []*peerListener, err error) {
-	if err = updateCipherSuites(&cfg.PeerTLSInfo, cfg.CipherSuites); err != nil {
+	if err = cfg.updateTLSInfo(&cfg.PeerTLSInfo); err != nil {
 		return nil, err
 	}
 	if err = cfg.PeerSelfCert(); err != nil {
@@ -470,6 +588,18 @@ func configurePeerListeners(cfg *Config) (peers []*peerListener, err error) {
 		}
 	}
 
+	allowed, err := cfg.PeerAllowedNetworks()
+	if err != nil {
+		return nil, err
+	}
+	if len(allowed) > 0 {
+		if cfg.logger != nil {
+			cfg.logger.Info("restricting peers to the allowed hosts", zap.Strings("peer-allowed-hosts", cfg.PeerAllowedHosts))
+		} else {
+			plog.Infof("restricting peers to the allowed hosts %v", cfg.PeerAllowedHosts)
+		}
+	}
+
 	peers = make([]*peerListener, len(cfg.LPUrls))
 	defer func() {
 		if err == nil {
@@ -494,6 +624,18 @@ func configurePeerListeners(cfg *Config) (peers []*peerListener, err error) {
 	}()
 
 	for i, u := range cfg.LPUrls {
+		if cu, ok := cfg.sharedClientURL(u); ok {
+			// served on the client listener, see servePeers
+			if u.Scheme == "https" && !sameTLSSettings(&cfg.PeerTLSInfo, cfg.clientTLSInfo(cu)) {
+				return nil, fmt.Errorf("peer URL %s sharing the address of client URL %s must have the same TLS settings", u.String(), cu.String())
+			}
+			if cfg.logger != nil {
+				cfg.logger.Info("serving peer traffic on the client listener", zap.String("peer-url", u.String()), zap.String("client-url", cu.String()))
+			} else {
+				plog.Infof("serving peer traffic of %s on the client listener of %s", u.String(), cu.String())
+			}
+			continue
+		}
 		if u.Scheme == "http" {
 			if !cfg.PeerTLSInfo.Empty() {
 				if cfg.logger != nil {
@@ -510,22 +652,38 @@ func configurePeerListeners(cfg *Config) (peers []*peerListener, err error) {
 				}
 			}
 		}
-		peers[i] = &peerListener{close: func(context.Context) error { return nil }}
+		peers[i] = &peerListener{secure: u.Scheme == "https" || u.Scheme == "unixs", close: func(context.Context) error { return nil }}
 		peers[i].Listener, err = rafthttp.NewListener(u, &cfg.PeerTLSInfo)
 		if err != nil {
 			return nil, err
 		}
+		peers[i].Listener = allowed.NewListener(peers[i].Listener)
 		// once serve, overwrite with 'http.Server.Shutdown'
 		peers[i].close = func(context.Context) error {
 			return peers[i].Listener.Close()
 		}
 	}
-	return peers, nil
+	// drop the URLs served on the client listeners
+	listened := peers[:0]
+	for _, p := range peers {
+		if p != nil {
+			listened = append(listened, p)
+		}
+	}
+	return listened, nil
+}
+
+// sameTLSSettings returns true if a and b verify and present the same
+// certificates, so that a listener can be served with either.
+func sameTLSSettings(a, b *transport.TLSInfo) bool {
+	return a.CertFile == b.CertFile && a.KeyFile == b.KeyFile &&
+		a.TrustedCAFile == b.TrustedCAFile && a.ClientCertAuth == b.ClientCertAuth &&
+		a.CRLFile == b.CRLFile && a.AllowedCN == b.AllowedCN
 }
 
 // configure peer handlers after rafthttp.Transport started
 func (e *Etcd) servePeers() (err error) {
-	ph := etcdhttp.NewPeerHandler(e.GetLogger(), e.Server)
+	ph := rafthttp.NewSharedSecretHandler(etcdhttp.NewPeerHandler(e.GetLogger(), e.Server), e.Server.Cfg.PeerSharedSecret)
 	var peerTLScfg *tls.Config
 	if !e.cfg.PeerTLSInfo.Empty() {
 		if peerTLScfg, err = e.cfg.PeerTLSInfo.ServerConfig(); err != nil {
@@ -535,8 +693,23 @@ func (e *Etcd) servePeers() (err error) {
 
 	for _, p := range e.Peers {
 		u := p.Listener.Addr().String()
-		gs := v3rpc.Server(e.Server, peerTLScfg)
+		var gs *grpc.Server
+		if len(e.cfg.ListenAdminUrls) > 0 {
+			gs = v3rpc.NonAdminServer(e.Server, peerTLScfg)
+		} else {
+			gs = v3rpc.Server(e.Server, peerTLScfg)
+		}
 		m := cmux.New(p.Listener)
+		// the raft messages streamed over gRPC are served apart from the
+		// v3 API, on connections of their own, authenticated by their
+		// client certificates
+		var rgs *grpc.Server
+		if p.secure {
+			rgs = e.Server.RaftGRPCServer()
+		}
+		if rgs != nil {
+			go rgs.Serve(m.Match(cmux.HTTP2HeaderField(":path", rafthttp.RaftGRPCStreamPath)))
+		}
 		go gs.Serve(m.Match(cmux.HTTP2()))
 		srv := &http.Server{
 			Handler:     grpcHandlerFunc(gs, ph),
@@ -555,6 +728,9 @@ func (e *Etcd) servePeers() (err error) {
 					zap.String("address", u),
 				)
 			}
+			if rgs != nil {
+				rgs.Stop()
+			}
 			stopServers(ctx, &servers{secure: peerTLScfg != nil, grpc: gs, http: srv})
 			if e.cfg.logger != nil {
 				e.cfg.logger.Info(
@@ -566,6 +742,18 @@ func (e *Etcd) servePeers() (err error) {
 		}
 	}
 
+	// the peer URLs sharing the address of a client URL are served by its
+	// serveCtx, from before the member is ready
+	for _, u := range e.cfg.LPUrls {
+		if _, ok := e.cfg.sharedClientURL(u); ok {
+			e.sctxs[u.Host].peer = &sharedPeer{
+				handler: ph,
+				grpc:    e.Server.RaftGRPCServer(),
+				allowed: e.Server.Cfg.PeerAllowedNetworks,
+			}
+		}
+	}
+
 	// start peer servers in a goroutine
 	for _, pl := range e.Peers {
 		go func(l *peerListener) {
@@ -585,7 +773,7 @@ func (e *Etcd) servePeers() (err error) {
 }
 
 func configureClientListeners(cfg *Config) (sctxs map[string]*serveCtx, err error) {
-	if err = updateCipherSuites(&cfg.ClientTLSInfo, cfg.CipherSuites); err != nil {
+	if err = cfg.updateTLSInfo(&cfg.ClientTLSInfo); err != nil {
 		return nil, err
 	}
 	if err = cfg.ClientSelfCert(); err != nil {
@@ -602,19 +790,44 @@ func configureClientListeners(cfg *Config) (sctxs map[string]*serveCtx, err erro
 			plog.Infof("pprof is enabled under %s", debugutil.HTTPPrefixPProf)
 		}
 	}
+	if cfg.EnableDashboard {
+		if cfg.logger != nil {
+			cfg.logger.Info("dashboard is enabled", zap.String("path", dashboard.HTTPPrefix), zap.Bool("enable-v2", cfg.EnableV2))
+		} else {
+			plog.Infof("dashboard is enabled under %s", dashboard.HTTPPrefix)
+		}
+		if !cfg.EnableV2 {
+			if cfg.logger != nil {
+				cfg.logger.Warn("dashboard requires the v2 API; set --enable-v2")
+			} else {
+				plog.Warningf("dashboard requires the v2 API; set --enable-v2")
+			}
+		}
+	}
 
+	// the admin URLs are served like the client URLs, and are then the
+	// only ones serving the admin endpoints
+	urls := append(append([]url.URL{}, cfg.LCUrls...), cfg.ListenAdminUrls...)
 	sctxs = make(map[string]*serveCtx)
-	for _, u := range cfg.LCUrls {
+	for i, u := range urls {
 		sctx := newServeCtx(cfg.logger)
+		sctx.admin = i >= len(cfg.LCUrls)
+		sctx.refuseAdmin = !sctx.admin && len(cfg.ListenAdminUrls) > 0
+		sctx.tlsinfo = cfg.clientTLSInfo(u)
+		if sctx.tlsinfo != &cfg.ClientTLSInfo {
+			if err = cfg.updateTLSInfo(sctx.tlsinfo); err != nil {
+				return nil, err
+			}
+		}
 		if u.Scheme == "http" || u.Scheme == "unix" {
-			if !cfg.ClientTLSInfo.Empty() {
+			if !sctx.tlsinfo.Empty() {
 				if cfg.logger != nil {
 					cfg.logger.Warn("scheme is HTTP while key and cert files are present; ignoring key and cert files", zap.String("client-url", u.String()))
 				} else {
 					plog.Warningf("The scheme of client url %s is HTTP while peer key/cert files are presented. Ignored key/cert files.", u.String())
 				}
 			}
-			if cfg.ClientTLSInfo.ClientCertAuth {
+			if sctx.tlsinfo.ClientCertAuth {
 				if cfg.logger != nil {
 					cfg.logger.Warn("scheme is HTTP while --client-cert-auth is enabled; ignoring client cert auth for this URL", zap.String("client-url", u.String()))
 				} else {
@@ -622,7 +835,7 @@ func configureClientListeners(cfg *Config) (sctxs map[string]*serveCtx, err erro
 				}
 			}
 		}
-		if (u.Scheme == "https" || u.Scheme == "unixs") && cfg.ClientTLSInfo.Empty() {
+		if (u.Scheme == "https" || u.Scheme == "unixs") && sctx.tlsinfo.Empty() {
 			return nil, fmt.Errorf("TLS key/cert (--cert-file, --key-file) must be provided for client url %s with HTTPs scheme", u.String())
 		}
 
@@ -637,44 +850,55 @@ func configureClientListeners(cfg *Config) (sctxs map[string]*serveCtx, err erro
 		sctx.secure = u.Scheme == "https" || u.Scheme == "unixs"
 		sctx.insecure = !sctx.secure
 		if oldctx := sctxs[addr]; oldctx != nil {
+			if sctx.secure {
+				// the URLs sharing the address are served with the
+				// TLS settings of the secure one
+				oldctx.tlsinfo = sctx.tlsinfo
+			}
 			oldctx.secure = oldctx.secure || sctx.secure
 			oldctx.insecure = oldctx.insecure || sctx.insecure
 			continue
 		}
 
-		if sctx.l, err = net.Listen(network, addr); err != nil {
+		if sctx.ls, err = transport.ListenReusePort(network, addr, cfg.ExperimentalClientListenSockets); err != nil {
 			return nil, err
 		}
 		// net.Listener will rewrite ipv4 0.0.0.0 to ipv6 [::], breaking
 		// hosts that disable ipv6. So, use the address given by the user.
 		sctx.addr = addr
 
-		if fdLimit, fderr := runtimeutil.FDLimit(); fderr == nil {
-			if fdLimit <= reservedInternalFDNum {
-				if cfg.logger != nil {
-					cfg.logger.Fatal(
-						"file descriptor limit of etcd process is too low; please set higher",
-						zap.Uint64("limit", fdLimit),
-						zap.Int("recommended-limit", reservedInternalFDNum),
-					)
-				} else {
-					plog.Fatalf("file descriptor limit[%d] of etcd process is too low, and should be set higher than %d to ensure internal usage", fdLimit, reservedInternalFDNum)
+		for i := range sctx.ls {
+			if fdLimit, fderr := runtimeutil.FDLimit(); fderr == nil {
+				if fdLimit <= reservedInternalFDNum {
+					if cfg.logger != nil {
+						cfg.logger.Fatal(
+							"file descriptor limit of etcd process is too low; please set higher",
+							zap.Uint64("limit", fdLimit),
+							zap.Int("recommended-limit", reservedInternalFDNum),
+						)
+					} else {
+						plog.Fatalf("file descriptor limit[%d] of etcd process is too low, and should be set higher than %d to ensure internal usage", fdLimit, reservedInternalFDNum)
+					}
 				}
+				// the sockets of the address share the limit
+				sctx.ls[i] = transport.LimitListener(sctx.ls[i], int(fdLimit-reservedInternalFDNum)/len(sctx.ls))
 			}
-			sctx.l = transport.LimitListener(sctx.l, int(fdLimit-reservedInternalFDNum))
-		}
 
-		if network == "tcp" {
-			if sctx.l, err = transport.NewKeepAliveListener(sctx.l, network, nil); err != nil {
-				return nil, err
+			if network == "tcp" {
+				if sctx.ls[i], err = transport.NewKeepAliveListener(sctx.ls[i], network, nil); err != nil {
+					return nil, err
+				}
 			}
 		}
+		sctx.l = sctx.ls[0]
 
 		defer func() {
 			if err == nil {
 				return
 			}
-			sctx.l.Close()
+			for _, l := range sctx.ls {
+				l.Close()
+			}
 			if cfg.logger != nil {
 				cfg.logger.Warn(
 					"closing peer listener",
@@ -689,12 +913,17 @@ func configureClientListeners(cfg *Config) (sctxs map[string]*serveCtx, err erro
 			sctx.userHandlers[k] = cfg.UserHandlers[k]
 		}
 		sctx.serviceRegister = cfg.ServiceRegister
-		if cfg.EnablePprof || cfg.Debug {
+		sctx.middlewares = cfg.ClientMiddlewares
+		if (cfg.EnablePprof || cfg.Debug) && !sctx.refuseAdmin {
 			sctx.registerPprof()
+			sctx.registerRuntimeInfo(cfg)
 		}
-		if cfg.Debug {
+		if cfg.Debug && !sctx.refuseAdmin {
 			sctx.registerTrace()
 		}
+		if cfg.EnableDashboard {
+			sctx.registerUserHandler(dashboard.HTTPPrefix, dashboard.NewHandler())
+		}
 		sctxs[addr] = sctx
 	}
 	return sctxs, nil
@@ -712,21 +941,40 @@ func (e *Etcd) serveClients() (err error) {
 			plog.Infof("ClientTLS: %s", e.cfg.ClientTLSInfo)
 		}
 	}
+	for u, tls := range e.cfg.ClientTLSOverrides {
+		if e.cfg.logger != nil {
+			e.cfg.logger.Info(
+				"starting with client TLS override",
+				zap.String("listen-url", u),
+				zap.String("tls-info", fmt.Sprintf("%+v", tls)),
+			)
+		} else {
+			plog.Infof("ClientTLS of %s: %s", u, tls)
+		}
+	}
 
 	// Start a client server goroutine for each listen address
-	var h http.Handler
+	var srv etcdserver.ServerPeer = e.Server
+	if e.Config().EnableV2 && len(e.Config().ExperimentalEnableV2V3) > 0 {
+		srv = v2v3.NewServer(e.cfg.logger, v3client.New(e.Server), e.cfg.ExperimentalEnableV2V3)
+	}
+	var basic http.Handler
 	if e.Config().EnableV2 {
-		if len(e.Config().ExperimentalEnableV2V3) > 0 {
-			srv := v2v3.NewServer(e.cfg.logger, v3client.New(e.Server), e.cfg.ExperimentalEnableV2V3)
-			h = v2http.NewClientHandler(e.GetLogger(), srv, e.Server.Cfg.ReqTimeout())
-		} else {
-			h = v2http.NewClientHandler(e.GetLogger(), e.Server, e.Server.Cfg.ReqTimeout())
-		}
+		basic = v2http.NewBasicHandler(e.GetLogger(), srv)
 	} else {
 		mux := http.NewServeMux()
-		etcdhttp.HandleBasic(mux, e.Server)
-		h = mux
+		etcdhttp.HandleBasic(mux, srv)
+		basic = mux
+	}
+	vm := etcdhttp.NewVersionMux(basic)
+	if e.Config().EnableV2 {
+		v2http.HandleVersion(e.GetLogger(), vm, srv, e.Server.Cfg.ReqTimeout())
+	}
+	if e.cfg.EnableGRPCGateway {
+		// the gateway is served apart on every listener, see createMux
+		vm.Handle(v3GatewayAPI, nil)
 	}
+	var h http.Handler = vm
 
 	gopts := []grpc.ServerOption{}
 	if e.cfg.GRPCKeepAliveMinTime > time.Duration(0) {
@@ -746,7 +994,7 @@ func (e *Etcd) serveClients() (err error) {
 	// start client servers in each goroutine
 	for _, sctx := range e.sctxs {
 		go func(s *serveCtx) {
-			e.errHandler(s.serve(e.Server, &e.cfg.ClientTLSInfo, h, e.errHandler, gopts...))
+			e.errHandler(s.serve(e.Server, s.tlsinfo, h, e.errHandler, gopts...))
 		}(sctx)
 	}
 	return nil
@@ -762,6 +1010,19 @@ func (e *Etcd) serveMetrics() (err error) {
 		etcdhttp.HandleMetricsHealth(metricsMux, e.Server)
 
 		for _, murl := range e.cfg.ListenMetricsUrls {
+			if cu, ok := e.cfg.sharedClientURL(murl); ok {
+				// the client listeners serve the metrics already
+				if e.cfg.logger != nil {
+					e.cfg.logger.Info(
+						"serving metrics on the client listener",
+						zap.String("address", murl.String()),
+						zap.String("client-url", cu.String()),
+					)
+				} else {
+					plog.Infof("listening for metrics of %s on the client listener of %s", murl.String(), cu.String())
+				}
+				continue
+			}
 			tlsInfo := &e.cfg.ClientTLSInfo
 			if murl.Scheme == "http" {
 				tlsInfo = nil
@@ -784,6 +1045,38 @@ func (e *Etcd) serveMetrics() (err error) {
 			}(murl, ml)
 		}
 	}
+
+	if e.cfg.MetricsStatsdAddr != "" {
+		if e.statsd, err = statsd.NewExporter(e.cfg.logger, e.cfg.MetricsStatsdAddr, e.cfg.MetricsStatsdFormat, e.cfg.MetricsStatsdInterval, prometheus.DefaultGatherer); err != nil {
+			return err
+		}
+		e.statsd.Start()
+		if e.cfg.logger != nil {
+			e.cfg.logger.Info(
+				"pushing metrics to statsd",
+				zap.String("address", e.cfg.MetricsStatsdAddr),
+				zap.String("format", e.cfg.MetricsStatsdFormat),
+				zap.Duration("interval", e.cfg.MetricsStatsdInterval),
+			)
+		} else {
+			plog.Infof("pushing metrics to statsd at %s every %v", e.cfg.MetricsStatsdAddr, e.cfg.MetricsStatsdInterval)
+		}
+	}
+	return nil
+}
+
+// checkUserGroup checks that the process runs as the configured user and
+// group. A Go process cannot switch all of its threads to another user,
+// so the etcd binary re-executes itself as them before starting the
+// server; see etcdmain.
+func checkUserGroup(cfg *Config) error {
+	uid, gid, err := osutil.LookupUserGroup(cfg.User, cfg.Group)
+	if err != nil {
+		return err
+	}
+	if os.Geteuid() != uid || os.Getegid() != gid {
+		return fmt.Errorf("etcd runs as uid %d, gid %d instead of uid %d, gid %d given by --user and --group", os.Geteuid(), os.Getegid(), uid, gid)
+	}
 	return nil
 }
 
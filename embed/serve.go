@@ -16,14 +16,18 @@ package embed
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	defaultLog "log"
 	"net"
 	"net/http"
+	"runtime"
 	"strings"
 
 	"go.etcd.io/etcd/etcdserver"
+	"go.etcd.io/etcd/etcdserver/api/etcdhttp"
+	"go.etcd.io/etcd/etcdserver/api/rafthttp"
 	"go.etcd.io/etcd/etcdserver/api/v3client"
 	"go.etcd.io/etcd/etcdserver/api/v3election"
 	"go.etcd.io/etcd/etcdserver/api/v3election/v3electionpb"
@@ -36,6 +40,7 @@ import (
 	"go.etcd.io/etcd/pkg/debugutil"
 	"go.etcd.io/etcd/pkg/httputil"
 	"go.etcd.io/etcd/pkg/transport"
+	"go.etcd.io/etcd/version"
 
 	gw "github.com/grpc-ecosystem/grpc-gateway/runtime"
 	"github.com/soheilhy/cmux"
@@ -47,17 +52,30 @@ import (
 )
 
 type serveCtx struct {
-	lg       *zap.Logger
+	lg *zap.Logger
+	// ls holds the listeners of the sockets bound to the address, each
+	// served by its own goroutines; l is the first one.
+	ls       []net.Listener
 	l        net.Listener
 	addr     string
 	network  string
 	secure   bool
 	insecure bool
+	// tlsinfo is the TLS settings of the secure listener.
+	tlsinfo *transport.TLSInfo
+	// admin is true if the listener is one of the admin URLs.
+	admin bool
+	// refuseAdmin is true if the admin endpoints are served on dedicated
+	// listeners, and so are refused on this one.
+	refuseAdmin bool
+	// peer, if not nil, serves the peer URL sharing the listener.
+	peer *sharedPeer
 
 	ctx    context.Context
 	cancel context.CancelFunc
 
 	userHandlers    map[string]http.Handler
+	middlewares     []etcdhttp.Middleware
 	serviceRegister func(*grpc.Server)
 	serversC        chan *servers
 }
@@ -89,14 +107,23 @@ func (sctx *serveCtx) serve(
 	errHandler func(error),
 	gopts ...grpc.ServerOption) (err error) {
 	logger := defaultLog.New(ioutil.Discard, "etcdhttp", 0)
-	<-s.ReadyNotify()
-
-	if sctx.lg == nil {
-		plog.Info("ready to serve client requests")
+	if sctx.peer == nil {
+		<-s.ReadyNotify()
+		if sctx.lg == nil {
+			plog.Info("ready to serve client requests")
+		}
 	}
 
-	m := cmux.New(sctx.l)
+	ms := make([]cmux.CMux, len(sctx.ls))
+	for i, l := range sctx.ls {
+		ms[i] = cmux.New(l)
+	}
 	v3c := v3client.New(s)
+	newGRPCServer := v3rpc.Server
+	if sctx.refuseAdmin {
+		newGRPCServer = v3rpc.NonAdminServer
+		handler = refuseAdminHandler(handler)
+	}
 	servElection := v3election.NewElectionServer(v3c)
 	servLock := v3lock.NewLockServer(v3c)
 
@@ -108,14 +135,25 @@ func (sctx *serveCtx) serve(
 	}()
 
 	if sctx.insecure {
-		gs = v3rpc.Server(s, nil, gopts...)
+		gs = newGRPCServer(s, nil, gopts...)
 		v3electionpb.RegisterElectionServer(gs, servElection)
 		v3lockpb.RegisterLockServer(gs, servLock)
 		if sctx.serviceRegister != nil {
 			sctx.serviceRegister(gs)
 		}
-		grpcl := m.Match(cmux.HTTP2())
-		go func() { errHandler(gs.Serve(grpcl)) }()
+		for _, m := range ms {
+			if sctx.peer != nil && sctx.peer.grpc != nil {
+				rgs := sctx.peer.grpc
+				rl := sctx.peer.allowed.NewListener(m.Match(cmux.HTTP2HeaderField(":path", rafthttp.RaftGRPCStreamPath)))
+				go rgs.Serve(rl)
+			}
+			grpcl := m.Match(cmux.HTTP2())
+			go func() {
+				// the client requests wait for the member to be ready
+				<-s.ReadyNotify()
+				errHandler(gs.Serve(grpcl))
+			}()
+		}
 
 		var gwmux *gw.ServeMux
 		if s.Cfg.EnableGRPCGateway {
@@ -128,11 +166,13 @@ func (sctx *serveCtx) serve(
 		httpmux := sctx.createMux(gwmux, handler)
 
 		srvhttp := &http.Server{
-			Handler:  createAccessController(sctx.lg, s, httpmux),
+			Handler:  sctx.peerHandler(createAccessController(sctx.lg, s, httpmux, sctx.middlewares), s.ReadyNotify()),
 			ErrorLog: logger, // do not log user error
 		}
-		httpl := m.Match(cmux.HTTP1())
-		go func() { errHandler(srvhttp.Serve(httpl)) }()
+		for _, m := range ms {
+			httpl := m.Match(cmux.HTTP1())
+			go func() { errHandler(srvhttp.Serve(httpl)) }()
+		}
 
 		sctx.serversC <- &servers{grpc: gs, http: srvhttp}
 		if sctx.lg != nil {
@@ -150,7 +190,7 @@ func (sctx *serveCtx) serve(
 		if tlsErr != nil {
 			return tlsErr
 		}
-		gs = v3rpc.Server(s, tlscfg, gopts...)
+		gs = newGRPCServer(s, tlscfg, gopts...)
 		v3electionpb.RegisterElectionServer(gs, servElection)
 		v3lockpb.RegisterLockServer(gs, servLock)
 		if sctx.serviceRegister != nil {
@@ -171,20 +211,24 @@ func (sctx *serveCtx) serve(
 			}
 		}
 
-		var tlsl net.Listener
-		tlsl, err = transport.NewTLSListener(m.Match(cmux.Any()), tlsinfo)
-		if err != nil {
-			return err
+		tlsls := make([]net.Listener, len(ms))
+		for i, m := range ms {
+			tlsls[i], err = transport.NewTLSListener(m.Match(cmux.Any()), tlsinfo)
+			if err != nil {
+				return err
+			}
 		}
 		// TODO: add debug flag; enable logging when debug flag is set
 		httpmux := sctx.createMux(gwmux, handler)
 
 		srv := &http.Server{
-			Handler:   createAccessController(sctx.lg, s, httpmux),
+			Handler:   sctx.peerHandler(createAccessController(sctx.lg, s, httpmux, sctx.middlewares), s.ReadyNotify()),
 			TLSConfig: tlscfg,
 			ErrorLog:  logger, // do not log user error
 		}
-		go func() { errHandler(srv.Serve(tlsl)) }()
+		for _, tlsl := range tlsls {
+			go func(tlsl net.Listener) { errHandler(srv.Serve(tlsl)) }(tlsl)
+		}
 
 		sctx.serversC <- &servers{secure: true, grpc: gs, http: srv}
 		if sctx.lg != nil {
@@ -198,7 +242,75 @@ func (sctx *serveCtx) serve(
 	}
 
 	close(sctx.serversC)
-	return m.Serve()
+	for _, m := range ms[1:] {
+		go func(m cmux.CMux) { errHandler(m.Serve()) }(m)
+	}
+	return ms[0].Serve()
+}
+
+// sharedPeer serves the peer requests received on a client listener.
+type sharedPeer struct {
+	handler http.Handler
+	// grpc, if not nil, serves the raft message streams over gRPC.
+	grpc    *grpc.Server
+	allowed transport.AllowedNetworks
+}
+
+// peerHandler returns an http.Handler serving the peer requests with the
+// shared peer, if any, and the other ones with h once readyc is closed.
+// The peer requests are served right away, since the member gets ready by
+// reaching its peers.
+func (sctx *serveCtx) peerHandler(h http.Handler, readyc <-chan struct{}) http.Handler {
+	sp := sctx.peer
+	if sp == nil {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raftStream := sp.grpc != nil && r.ProtoMajor == 2 && r.URL.Path == rafthttp.RaftGRPCStreamPath
+		if raftStream || etcdhttp.IsPeerPath(r.URL.Path) {
+			if !sp.allows(r.RemoteAddr) {
+				http.Error(w, "host not allowed", http.StatusForbidden)
+				return
+			}
+			if raftStream {
+				sp.grpc.ServeHTTP(w, r)
+			} else {
+				sp.handler.ServeHTTP(w, r)
+			}
+			return
+		}
+		select {
+		case <-readyc:
+		case <-r.Context().Done():
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// allows returns true if the peer at the remote address addr is in the
+// allowed networks.
+func (sp *sharedPeer) allows(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	ip := net.ParseIP(host)
+	return ip == nil || sp.allowed.Allows(&net.TCPAddr{IP: ip})
+}
+
+// refuseAdminHandler returns an http.Handler refusing the v2 admin
+// endpoints and membership changes, and passing other requests to h.
+func refuseAdminHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := r.URL.Path
+		if p == "/v2/admin" || strings.HasPrefix(p, "/v2/admin/") ||
+			((p == "/v2/members" || strings.HasPrefix(p, "/v2/members/")) && r.Method != "GET" && r.Method != "HEAD") {
+			http.Error(w, "only served on the admin URLs", http.StatusForbidden)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
 }
 
 // grpcHandlerFunc returns an http.Handler that delegates to grpcServer on incoming gRPC
@@ -218,6 +330,12 @@ func grpcHandlerFunc(grpcServer *grpc.Server, otherHandler http.Handler) http.Ha
 	})
 }
 
+// v3GatewayAPI is the v3 API served by the gRPC gateway.
+var v3GatewayAPI = etcdhttp.APIVersion{
+	Version:      "v3",
+	Capabilities: []string{"kv", "watch", "lease", "cluster", "maintenance", "auth", "lock", "election"},
+}
+
 type registerHandlerFunc func(context.Context, *gw.ServeMux, *grpc.ClientConn) error
 
 func (sctx *serveCtx) registerGateway(opts []grpc.DialOption) (*gw.ServeMux, error) {
@@ -298,15 +416,22 @@ func (sctx *serveCtx) createMux(gwmux *gw.ServeMux, handler http.Handler) *http.
 // createAccessController wraps HTTP multiplexer:
 // - mutate gRPC gateway request paths
 // - check hostname whitelist
+// - pass the requests through the CORS, client identity and backpressure middlewares, then the given ones
 // client HTTP requests goes here first
-func createAccessController(lg *zap.Logger, s *etcdserver.EtcdServer, mux *http.ServeMux) http.Handler {
-	return &accessController{lg: lg, s: s, mux: mux}
+func createAccessController(lg *zap.Logger, s *etcdserver.EtcdServer, mux *http.ServeMux, mws []etcdhttp.Middleware) http.Handler {
+	chain := []etcdhttp.Middleware{etcdhttp.CORS(s.AccessController)}
+	if s.Cfg.ClientIdentityHeaders {
+		chain = append(chain, etcdhttp.ClientIdentityHeaders())
+	}
+	chain = append(chain, etcdhttp.Backpressure(s))
+	chain = append(chain, mws...)
+	return &accessController{lg: lg, s: s, next: etcdhttp.Chain(mux, chain...)}
 }
 
 type accessController struct {
-	lg  *zap.Logger
-	s   *etcdserver.EtcdServer
-	mux *http.ServeMux
+	lg   *zap.Logger
+	s    *etcdserver.EtcdServer
+	next http.Handler
 }
 
 func (ac *accessController) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
@@ -344,26 +469,7 @@ func (ac *accessController) ServeHTTP(rw http.ResponseWriter, req *http.Request)
 		}
 	}
 
-	// Write CORS header.
-	if ac.s.AccessController.OriginAllowed("*") {
-		addCORSHeader(rw, "*")
-	} else if origin := req.Header.Get("Origin"); ac.s.OriginAllowed(origin) {
-		addCORSHeader(rw, origin)
-	}
-
-	if req.Method == "OPTIONS" {
-		rw.WriteHeader(http.StatusOK)
-		return
-	}
-
-	ac.mux.ServeHTTP(rw, req)
-}
-
-// addCORSHeader adds the correct cors headers given an origin
-func addCORSHeader(w http.ResponseWriter, origin string) {
-	w.Header().Add("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
-	w.Header().Add("Access-Control-Allow-Origin", origin)
-	w.Header().Add("Access-Control-Allow-Headers", "accept, content-type, authorization")
+	ac.next.ServeHTTP(rw, req)
 }
 
 // https://github.com/transmission/transmission/pull/468
@@ -383,30 +489,7 @@ This requirement has been added to help prevent "DNS Rebinding" attacks (CVE-201
 // WrapCORS wraps existing handler with CORS.
 // TODO: deprecate this after v2 proxy deprecate
 func WrapCORS(cors map[string]struct{}, h http.Handler) http.Handler {
-	return &corsHandler{
-		ac: &etcdserver.AccessController{CORS: cors},
-		h:  h,
-	}
-}
-
-type corsHandler struct {
-	ac *etcdserver.AccessController
-	h  http.Handler
-}
-
-func (ch *corsHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
-	if ch.ac.OriginAllowed("*") {
-		addCORSHeader(rw, "*")
-	} else if origin := req.Header.Get("Origin"); ch.ac.OriginAllowed(origin) {
-		addCORSHeader(rw, origin)
-	}
-
-	if req.Method == "OPTIONS" {
-		rw.WriteHeader(http.StatusOK)
-		return
-	}
-
-	ch.h.ServeHTTP(rw, req)
+	return etcdhttp.Chain(h, etcdhttp.CORS(&etcdserver.AccessController{CORS: cors}))
 }
 
 func (sctx *serveCtx) registerUserHandler(s string, h http.Handler) {
@@ -427,6 +510,61 @@ func (sctx *serveCtx) registerPprof() {
 	}
 }
 
+// runtimeInfoPath serves the runtime information of the member.
+const runtimeInfoPath = "/debug/runtime"
+
+// runtimeInfo describes the build, runtime and configuration of a member,
+// in the spirit of expvar.
+type runtimeInfo struct {
+	EtcdVersion     string            `json:"etcd-version"`
+	GitSHA          string            `json:"git-sha"`
+	GoVersion       string            `json:"go-version"`
+	GoOS            string            `json:"go-os"`
+	GoArch          string            `json:"go-arch"`
+	MaxCPUSet       int               `json:"max-cpu-set"`
+	MaxCPUAvailable int               `json:"max-cpu-available"`
+	NumGoroutine    int               `json:"num-goroutine"`
+	Name            string            `json:"name"`
+	DataDir         string            `json:"data-dir"`
+	WALDir          string            `json:"wal-dir"`
+	ConfiguredFlags map[string]string `json:"configured-flags"`
+	MemStats        runtime.MemStats  `json:"memstats"`
+}
+
+func (sctx *serveCtx) registerRuntimeInfo(cfg *Config) {
+	h := func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		ri := runtimeInfo{
+			EtcdVersion:     version.Version,
+			GitSHA:          version.GitSHA,
+			GoVersion:       runtime.Version(),
+			GoOS:            runtime.GOOS,
+			GoArch:          runtime.GOARCH,
+			MaxCPUSet:       runtime.GOMAXPROCS(0),
+			MaxCPUAvailable: runtime.NumCPU(),
+			NumGoroutine:    runtime.NumGoroutine(),
+			Name:            cfg.Name,
+			DataDir:         cfg.Dir,
+			WALDir:          cfg.WalDir,
+			ConfiguredFlags: cfg.ConfiguredFlags,
+		}
+		runtime.ReadMemStats(&ri.MemStats)
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(ri); err != nil {
+			if sctx.lg != nil {
+				sctx.lg.Warn("failed to encode runtime information", zap.Error(err))
+			} else {
+				plog.Warningf("failed to encode runtime information (%v)", err)
+			}
+		}
+	}
+	sctx.registerUserHandler(runtimeInfoPath, http.HandlerFunc(h))
+}
+
 func (sctx *serveCtx) registerTrace() {
 	reqf := func(w http.ResponseWriter, r *http.Request) { trace.Render(w, r, true) }
 	sctx.registerUserHandler("/debug/requests", http.HandlerFunc(reqf))
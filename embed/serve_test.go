@@ -15,11 +15,16 @@
 package embed
 
 import (
+	"context"
+	"encoding/json"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 
 	"go.etcd.io/etcd/auth"
+	"go.etcd.io/etcd/pkg/transport"
 )
 
 // TestStartEtcdWrongToken ensures that StartEtcd with wrong configs returns with error.
@@ -36,3 +41,109 @@ func TestStartEtcdWrongToken(t *testing.T) {
 		t.Fatalf("expected %v, got %v", auth.ErrInvalidAuthOpts, err)
 	}
 }
+
+func TestRuntimeInfo(t *testing.T) {
+	cfg := NewConfig()
+	cfg.Dir = "/var/lib/etcd"
+	cfg.ConfiguredFlags = map[string]string{"name": "infra1"}
+	sctx := newServeCtx(nil)
+	sctx.registerRuntimeInfo(cfg)
+
+	req, err := http.NewRequest("GET", runtimeInfoPath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rw := httptest.NewRecorder()
+	sctx.userHandlers[runtimeInfoPath].ServeHTTP(rw, req)
+
+	if rw.Code != http.StatusOK {
+		t.Fatalf("code = %d, want %d", rw.Code, http.StatusOK)
+	}
+	var ri runtimeInfo
+	if err := json.Unmarshal(rw.Body.Bytes(), &ri); err != nil {
+		t.Fatal(err)
+	}
+	if ri.DataDir != cfg.Dir || ri.ConfiguredFlags["name"] != "infra1" || ri.MaxCPUSet == 0 {
+		t.Errorf("runtime info = %+v, want data dir %q and configured flags", ri, cfg.Dir)
+	}
+
+	// the expvar variables served by the client handler are not shadowed
+	served := ""
+	mux := sctx.createMux(nil, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { served = r.URL.Path }))
+	req, err = http.NewRequest("GET", "/debug/vars", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux.ServeHTTP(httptest.NewRecorder(), req)
+	if served != "/debug/vars" {
+		t.Errorf("/debug/vars not served by the client handler")
+	}
+}
+
+func TestRefuseAdminHandler(t *testing.T) {
+	h := refuseAdminHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	tests := []struct {
+		method, path string
+		wcode        int
+	}{
+		{"GET", "/v2/keys/foo", http.StatusOK},
+		{"PUT", "/v2/keys/foo", http.StatusOK},
+		{"GET", "/v2/members", http.StatusOK},
+		{"GET", "/v2/members/leader", http.StatusOK},
+		{"POST", "/v2/members", http.StatusForbidden},
+		{"PUT", "/v2/members/1234", http.StatusForbidden},
+		{"DELETE", "/v2/members/1234", http.StatusForbidden},
+		{"GET", "/v2/admin/alarms", http.StatusForbidden},
+		{"DELETE", "/v2/admin/alarms", http.StatusForbidden},
+	}
+	for i, tt := range tests {
+		rw := httptest.NewRecorder()
+		h.ServeHTTP(rw, httptest.NewRequest(tt.method, tt.path, nil))
+		if rw.Code != tt.wcode {
+			t.Errorf("#%d: %s %s code = %d, want %d", i, tt.method, tt.path, rw.Code, tt.wcode)
+		}
+	}
+}
+
+func TestSharedPeerHandler(t *testing.T) {
+	nets, err := transport.ParseAllowedNetworks([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sctx := newServeCtx(nil)
+	sctx.peer = &sharedPeer{
+		handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusAccepted) }),
+		allowed: nets,
+	}
+	readyc := make(chan struct{})
+	h := sctx.peerHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("client")) }), readyc)
+
+	// the client requests wait for the member to be ready
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest("GET", "/v2/keys/foo", nil).WithContext(ctx))
+	if rw.Body.Len() != 0 {
+		t.Fatalf("client request served before the member is ready")
+	}
+	close(readyc)
+
+	tests := []struct {
+		path, remote string
+		wcode        int
+	}{
+		{"/raft/stream/msgapp/1", "10.0.0.1:2380", http.StatusAccepted},
+		{"/members", "10.0.0.1:2380", http.StatusAccepted},
+		{"/raft", "192.168.0.1:2380", http.StatusForbidden},
+		{"/v2/keys/foo", "192.168.0.1:2380", http.StatusOK},
+	}
+	for i, tt := range tests {
+		req := httptest.NewRequest("GET", tt.path, nil)
+		req.RemoteAddr = tt.remote
+		rw := httptest.NewRecorder()
+		h.ServeHTTP(rw, req)
+		if rw.Code != tt.wcode {
+			t.Errorf("#%d: %s code = %d, want %d", i, tt.path, rw.Code, tt.wcode)
+		}
+	}
+}
@@ -49,6 +49,7 @@ func NewBackupCommand() cli.Command {
 			cli.StringFlag{Name: "backup-dir", Value: "", Usage: "Path to the backup dir"},
 			cli.StringFlag{Name: "backup-wal-dir", Value: "", Usage: "Path to the backup wal dir"},
 			cli.BoolFlag{Name: "with-v3", Usage: "Backup v3 backend data"},
+			cli.StringSliceFlag{Name: "snapshot-encryption-key-files", Usage: "Paths to the key files of the encrypted snap files; the backup is encrypted with the first one"},
 		},
 		Action: handleBackup,
 	}
@@ -75,11 +76,20 @@ func handleBackup(c *cli.Context) error {
 		destWAL = filepath.Join(c.String("backup-dir"), "member", "wal")
 	}
 
+	var codec snap.Codec
+	if keyFiles := c.StringSlice("snapshot-encryption-key-files"); len(keyFiles) > 0 {
+		keys, err := snap.NewFileKeyProvider(keyFiles)
+		if err != nil {
+			log.Fatalf("failed reading snapshot encryption keys: %v", err)
+		}
+		codec.Keys = keys
+	}
+
 	if err := fileutil.CreateDirAll(destSnap); err != nil {
 		log.Fatalf("failed creating backup snapshot dir %v: %v", destSnap, err)
 	}
 
-	walsnap := saveSnap(destSnap, srcSnap)
+	walsnap := saveSnap(destSnap, srcSnap, codec)
 	metadata, state, ents := loadWAL(srcWAL, walsnap, withV3)
 	saveDB(filepath.Join(destSnap, "db"), filepath.Join(srcSnap, "db"), state.Commit, withV3)
 
@@ -102,15 +112,15 @@ func handleBackup(c *cli.Context) error {
 	return nil
 }
 
-func saveSnap(destSnap, srcSnap string) (walsnap walpb.Snapshot) {
-	ss := snap.New(zap.NewExample(), srcSnap)
+func saveSnap(destSnap, srcSnap string, codec snap.Codec) (walsnap walpb.Snapshot) {
+	ss := snap.NewWithCodec(zap.NewExample(), srcSnap, codec)
 	snapshot, err := ss.Load()
 	if err != nil && err != snap.ErrNoSnapshot {
 		log.Fatal(err)
 	}
 	if snapshot != nil {
 		walsnap.Index, walsnap.Term = snapshot.Metadata.Index, snapshot.Metadata.Term
-		newss := snap.New(zap.NewExample(), destSnap)
+		newss := snap.NewWithCodec(zap.NewExample(), destSnap, codec)
 		if err = newss.SaveSnap(*snapshot); err != nil {
 			log.Fatal(err)
 		}
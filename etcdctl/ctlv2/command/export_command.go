@@ -0,0 +1,66 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/urfave/cli"
+	"go.etcd.io/etcd/client"
+)
+
+// NewExportCommand returns the CLI command for "export".
+func NewExportCommand() cli.Command {
+	return cli.Command{
+		Name:      "export",
+		Usage:     "export the keys under a directory, for the import command",
+		ArgsUsage: "[key]",
+		Description: `Export writes the keys at or under the given key, "/" by default,
+   to the standard output. The export is taken from the state of the member
+   serving the request, and requires root access.`,
+		Flags: []cli.Flag{
+			cli.StringFlag{Name: "format", Value: client.ExportJSON, Usage: "format of the export (`json` or `protobuf`)"},
+		},
+		Action: func(c *cli.Context) error {
+			exportCommandFunc(c, client.NewExportAPI(mustNewClient(c)))
+			return nil
+		},
+	}
+}
+
+// exportCommandFunc executes the "export" command.
+func exportCommandFunc(c *cli.Context, ea client.ExportAPI) {
+	key := "/"
+	if len(c.Args()) != 0 {
+		key = c.Args()[0]
+	}
+
+	// the export is streamed, so that the total timeout does not bound it,
+	// but the member must still answer within the request timeout
+	resp, err := ea.Export(context.TODO(), key, c.String("format"))
+	if err != nil {
+		handleError(c, ExitServerError, err)
+	}
+
+	_, err = io.Copy(os.Stdout, resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		handleError(c, ExitServerError, err)
+	}
+	fmt.Fprintf(os.Stderr, "exported keys at index %d\n", resp.Index)
+}
@@ -0,0 +1,141 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/urfave/cli"
+	"go.etcd.io/etcd/client"
+	pb "go.etcd.io/etcd/etcdserver/etcdserverpb"
+)
+
+// NewImportCommand returns the CLI command for "import".
+func NewImportCommand() cli.Command {
+	return cli.Command{
+		Name:      "import",
+		Usage:     "import the keys written by the export command",
+		ArgsUsage: "[file]",
+		Description: `Import sets the keys read from the given file, or from the standard
+   input, as written by the export command. Existing keys are overwritten,
+   and existing directories are kept. The keys keep what remains of their
+   TTL; the expired ones are skipped.`,
+		Flags: []cli.Flag{
+			cli.StringFlag{Name: "format", Value: client.ExportJSON, Usage: "format of the export (`json` or `protobuf`)"},
+		},
+		Action: func(c *cli.Context) error {
+			importCommandFunc(c, mustNewKeyAPI(c))
+			return nil
+		},
+	}
+}
+
+// importCommandFunc executes the "import" command.
+func importCommandFunc(c *cli.Context, ki client.KeysAPI) {
+	var r io.Reader = os.Stdin
+	if len(c.Args()) != 0 {
+		f, err := os.Open(c.Args()[0])
+		if err != nil {
+			handleError(c, ExitBadArgs, err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	n := 0
+	err := readExport(r, c.String("format"), func(node *client.Node) error {
+		opts := &client.SetOptions{Dir: node.Dir}
+		if node.Expiration != nil {
+			ttl := time.Until(*node.Expiration)
+			if ttl <= 0 {
+				return nil
+			}
+			// the TTLs are set in whole seconds, so round it up
+			opts.TTL = (ttl + time.Second - 1).Truncate(time.Second)
+		}
+		if node.Dir {
+			opts.PrevExist = client.PrevNoExist
+		}
+		ctx, cancel := contextWithTotalTimeout(c)
+		_, err := ki.Set(ctx, node.Key, node.Value, opts)
+		cancel()
+		if cerr, ok := err.(client.Error); ok && node.Dir && cerr.Code == client.ErrorCodeNodeExist {
+			err = nil
+		}
+		if err != nil {
+			return err
+		}
+		n++
+		return nil
+	})
+	if err != nil {
+		handleError(c, ExitServerError, err)
+	}
+	fmt.Printf("imported %d keys\n", n)
+}
+
+// readExport calls f on the keys of the export read from r, in the order
+// they were exported.
+func readExport(r io.Reader, format string, f func(node *client.Node) error) error {
+	switch format {
+	case client.ExportJSON:
+		dec := json.NewDecoder(r)
+		for {
+			var node client.Node
+			if err := dec.Decode(&node); err == io.EOF {
+				return nil
+			} else if err != nil {
+				return err
+			}
+			if err := f(&node); err != nil {
+				return err
+			}
+		}
+
+	case client.ExportProtobuf:
+		br := bufio.NewReader(r)
+		for {
+			l, err := binary.ReadUvarint(br)
+			if err == io.EOF {
+				return nil
+			} else if err != nil {
+				return err
+			}
+			b := make([]byte, l)
+			if _, err = io.ReadFull(br, b); err != nil {
+				return err
+			}
+			var req pb.Request
+			if err = req.Unmarshal(b); err != nil {
+				return err
+			}
+			node := &client.Node{Key: req.Path, Dir: req.Dir, Value: req.Val}
+			if req.Expiration != 0 {
+				exp := time.Unix(0, req.Expiration)
+				node.Expiration = &exp
+			}
+			if err = f(node); err != nil {
+				return err
+			}
+		}
+	}
+	return fmt.Errorf("unknown format %q", format)
+}
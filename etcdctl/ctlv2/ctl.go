@@ -67,6 +67,8 @@ func Start() {
 		command.NewUpdateDirCommand(),
 		command.NewWatchCommand(),
 		command.NewExecWatchCommand(),
+		command.NewExportCommand(),
+		command.NewImportCommand(),
 		command.NewMemberCommand(),
 		command.NewUserCommands(),
 		command.NewRoleCommands(),
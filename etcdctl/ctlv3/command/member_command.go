@@ -19,11 +19,18 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
+
+	"go.etcd.io/etcd/clientv3"
 
 	"github.com/spf13/cobra"
 )
 
-var memberPeerURLs string
+var (
+	memberPeerURLs  string
+	memberForceDead bool
+	memberDeadFor   time.Duration
+)
 
 // NewMemberCommand returns the cobra command for "member".
 func NewMemberCommand() *cobra.Command {
@@ -57,12 +64,15 @@ func NewMemberAddCommand() *cobra.Command {
 // NewMemberRemoveCommand returns the cobra command for "member remove".
 func NewMemberRemoveCommand() *cobra.Command {
 	cc := &cobra.Command{
-		Use:   "remove <memberID>",
+		Use:   "remove <memberID> [options]",
 		Short: "Removes a member from the cluster",
 
 		Run: memberRemoveCommandFunc,
 	}
 
+	cc.Flags().BoolVar(&memberForceDead, "force-dead", false, "remove a dead member, not seen for --dead-for, even if the strict reconfiguration check rejects it.")
+	cc.Flags().DurationVar(&memberDeadFor, "dead-for", time.Hour, "minimum time the dead member must not have been seen for by the serving member.")
+
 	return cc
 }
 
@@ -178,7 +188,14 @@ func memberRemoveCommandFunc(cmd *cobra.Command, args []string) {
 		ExitWithError(ExitBadArgs, fmt.Errorf("bad member ID arg (%v), expecting ID in Hex", err))
 	}
 
+	if memberForceDead && memberDeadFor <= 0 {
+		ExitWithError(ExitBadArgs, fmt.Errorf("--dead-for must be positive"))
+	}
+
 	ctx, cancel := commandCtx(cmd)
+	if memberForceDead {
+		ctx = clientv3.WithDeadMember(ctx, memberDeadFor)
+	}
 	resp, err := mustClientFromCmd(cmd).MemberRemove(ctx, id)
 	cancel()
 	if err != nil {
@@ -51,6 +51,7 @@ var (
 	migrateDatadir       string
 	migrateWALdir        string
 	migrateTransformer   string
+	migrateKeyFiles      []string
 )
 
 // NewMigrateCommand returns the cobra command for "migrate".
@@ -65,6 +66,7 @@ func NewMigrateCommand() *cobra.Command {
 	mc.Flags().StringVar(&migrateDatadir, "data-dir", "", "Path to the data directory")
 	mc.Flags().StringVar(&migrateWALdir, "wal-dir", "", "Path to the WAL directory")
 	mc.Flags().StringVar(&migrateTransformer, "transformer", "", "Path to the user-provided transformer program")
+	mc.Flags().StringSliceVar(&migrateKeyFiles, "snapshot-encryption-key-files", nil, "Paths to the key files decrypting the snap files, if encrypted")
 	return mc
 }
 
@@ -136,7 +138,15 @@ func rebuildStoreV2() (v2store.Store, uint64) {
 	}
 	snapdir := filepath.Join(migrateDatadir, "member", "snap")
 
-	ss := snap.New(zap.NewExample(), snapdir)
+	var codec snap.Codec
+	if len(migrateKeyFiles) > 0 {
+		keys, err := snap.NewFileKeyProvider(migrateKeyFiles)
+		if err != nil {
+			ExitWithError(ExitBadArgs, err)
+		}
+		codec.Keys = keys
+	}
+	ss := snap.NewWithCodec(zap.NewExample(), snapdir, codec)
 	snapshot, err := ss.Load()
 	if err != nil && err != snap.ErrNoSnapshot {
 		ExitWithError(ExitError, err)
@@ -170,7 +180,7 @@ func rebuildStoreV2() (v2store.Store, uint64) {
 	cl.SetStore(st)
 	cl.Recover(api.UpdateCapability)
 
-	applier := etcdserver.NewApplierV2(zap.NewExample(), st, cl)
+	rp := etcdserver.NewV2Replayer(zap.NewExample(), st, cl)
 	for _, ent := range ents {
 		if ent.Type == raftpb.EntryConfChange {
 			var cc raftpb.ConfChange
@@ -183,12 +193,12 @@ func rebuildStoreV2() (v2store.Store, uint64) {
 		if !pbutil.MaybeUnmarshal(&raftReq, ent.Data) { // backward compatible
 			var r pb.Request
 			pbutil.MustUnmarshal(&r, ent.Data)
-			applyRequest(&r, applier)
-		} else {
-			if raftReq.V2 != nil {
-				req := raftReq.V2
-				applyRequest(req, applier)
-			}
+			err = applyRequest(&r, rp)
+		} else if raftReq.V2 != nil {
+			err = applyRequest(raftReq.V2, rp)
+		}
+		if err != nil {
+			ExitWithError(ExitError, err)
 		}
 		if ent.Index > index {
 			index = ent.Index
@@ -203,7 +213,7 @@ func applyConf(cc raftpb.ConfChange, cl *membership.RaftCluster) {
 		return
 	}
 	switch cc.Type {
-	case raftpb.ConfChangeAddNode:
+	case raftpb.ConfChangeAddNode, raftpb.ConfChangeAddLearnerNode:
 		m := new(membership.Member)
 		if err := json.Unmarshal(cc.Context, m); err != nil {
 			panic(err)
@@ -220,23 +230,13 @@ func applyConf(cc raftpb.ConfChange, cl *membership.RaftCluster) {
 	}
 }
 
-func applyRequest(req *pb.Request, applyV2 etcdserver.ApplierV2) {
-	r := (*etcdserver.RequestV2)(req)
-	r.TTLOptions()
-	switch r.Method {
-	case "POST":
-		applyV2.Post(r)
-	case "PUT":
-		applyV2.Put(r)
-	case "DELETE":
-		applyV2.Delete(r)
-	case "QGET":
-		applyV2.QGet(r)
-	case "SYNC":
-		applyV2.Sync(r)
-	default:
-		panic("unknown command")
+// applyRequest applies req as a member does, or returns an error if its
+// method is unknown.
+func applyRequest(req *pb.Request, rp *etcdserver.V2Replayer) error {
+	if err := rp.Apply(req); err != nil {
+		return fmt.Errorf("failed to apply request %x %s %q (%v)", req.ID, req.Method, req.Path, err)
 	}
+	return nil
 }
 
 func writeStore(w io.Writer, st v2store.Store) uint64 {
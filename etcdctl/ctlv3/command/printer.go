@@ -51,6 +51,7 @@ type printer interface {
 
 	Alarm(v3.AlarmResponse)
 	DBStatus(snapshot.Status)
+	DataDirStatus(dataDirStatus)
 
 	RoleAdd(role string, r v3.AuthRoleAddResponse)
 	RoleGet(role string, r v3.AuthRoleGetResponse)
@@ -150,10 +151,11 @@ func newPrinterUnsupported(n string) printer {
 	return &printerUnsupported{printerRPC{nil, f}}
 }
 
-func (p *printerUnsupported) EndpointHealth([]epHealth) { p.p(nil) }
-func (p *printerUnsupported) EndpointStatus([]epStatus) { p.p(nil) }
-func (p *printerUnsupported) EndpointHashKV([]epHashKV) { p.p(nil) }
-func (p *printerUnsupported) DBStatus(snapshot.Status)  { p.p(nil) }
+func (p *printerUnsupported) EndpointHealth([]epHealth)   { p.p(nil) }
+func (p *printerUnsupported) EndpointStatus([]epStatus)   { p.p(nil) }
+func (p *printerUnsupported) EndpointHashKV([]epHashKV)   { p.p(nil) }
+func (p *printerUnsupported) DBStatus(snapshot.Status)    { p.p(nil) }
+func (p *printerUnsupported) DataDirStatus(dataDirStatus) { p.p(nil) }
 
 func (p *printerUnsupported) MoveLeader(leader, target uint64, r v3.MoveLeaderResponse) { p.p(nil) }
 
@@ -217,6 +219,25 @@ func makeEndpointHashKVTable(hashList []epHashKV) (hdr []string, rows [][]string
 	return hdr, rows
 }
 
+func makeDataDirStatusTable(ds dataDirStatus) (hdr []string, rows [][]string) {
+	hdr = []string{"member", "cluster", "last index", "last term", "commit index", "members", "v2 keys", "v2 hash", "revision", "total keys", "hash", "total size"}
+	rows = append(rows, []string{
+		ds.MemberID.String(),
+		ds.ClusterID.String(),
+		fmt.Sprint(ds.LastIndex),
+		fmt.Sprint(ds.LastTerm),
+		fmt.Sprint(ds.CommitIndex),
+		fmt.Sprint(len(ds.Members)),
+		fmt.Sprint(ds.V2Keys),
+		fmt.Sprintf("%x", ds.V2Hash),
+		fmt.Sprint(ds.DB.Revision),
+		fmt.Sprint(ds.DB.TotalKey),
+		fmt.Sprintf("%x", ds.DB.Hash),
+		humanize.Bytes(uint64(ds.DB.TotalSize)),
+	})
+	return hdr, rows
+}
+
 func makeDBStatusTable(ds snapshot.Status) (hdr []string, rows [][]string) {
 	hdr = []string{"hash", "revision", "total keys", "total size"}
 	rows = append(rows, []string{
@@ -191,6 +191,30 @@ func (p *fieldsPrinter) DBStatus(r snapshot.Status) {
 	fmt.Println(`"Size" :`, r.TotalSize)
 }
 
+func (p *fieldsPrinter) DataDirStatus(r dataDirStatus) {
+	fmt.Println(`"MemberID" :`, r.MemberID)
+	fmt.Println(`"ClusterID" :`, r.ClusterID)
+	fmt.Println(`"SnapshotIndex" :`, r.SnapshotIndex)
+	fmt.Println(`"CommitIndex" :`, r.CommitIndex)
+	fmt.Println(`"LastIndex" :`, r.LastIndex)
+	fmt.Println(`"LastTerm" :`, r.LastTerm)
+	for _, m := range r.Members {
+		fmt.Println(`"ID" :`, m.ID)
+		fmt.Printf("\"Name\" : %q\n", m.Name)
+		for _, u := range m.PeerURLs {
+			fmt.Printf("\"PeerURL\" : %q\n", u)
+		}
+		fmt.Println(`"IsLearner" :`, m.IsLearner)
+	}
+	fmt.Println(`"V2Keys" :`, r.V2Keys)
+	fmt.Println(`"V2Hash" :`, r.V2Hash)
+	fmt.Println(`"Hash" :`, r.DB.Hash)
+	fmt.Println(`"Revision" :`, r.DB.Revision)
+	fmt.Println(`"Keys" :`, r.DB.TotalKey)
+	fmt.Println(`"Size" :`, r.DB.TotalSize)
+	fmt.Println(`"ConsistentIndex" :`, r.ConsistentIndex)
+}
+
 func (p *fieldsPrinter) RoleAdd(role string, r v3.AuthRoleAddResponse) { p.hdr(r.Header) }
 func (p *fieldsPrinter) RoleGet(role string, r v3.AuthRoleGetResponse) {
 	p.hdr(r.Header)
@@ -30,10 +30,11 @@ func newJSONPrinter() printer {
 	}
 }
 
-func (p *jsonPrinter) EndpointHealth(r []epHealth) { printJSON(r) }
-func (p *jsonPrinter) EndpointStatus(r []epStatus) { printJSON(r) }
-func (p *jsonPrinter) EndpointHashKV(r []epHashKV) { printJSON(r) }
-func (p *jsonPrinter) DBStatus(r snapshot.Status)  { printJSON(r) }
+func (p *jsonPrinter) EndpointHealth(r []epHealth)   { printJSON(r) }
+func (p *jsonPrinter) EndpointStatus(r []epStatus)   { printJSON(r) }
+func (p *jsonPrinter) EndpointHashKV(r []epHashKV)   { printJSON(r) }
+func (p *jsonPrinter) DBStatus(r snapshot.Status)    { printJSON(r) }
+func (p *jsonPrinter) DataDirStatus(r dataDirStatus) { printJSON(r) }
 
 func printJSON(v interface{}) {
 	b, err := json.Marshal(v)
@@ -174,6 +174,13 @@ func (s *simplePrinter) DBStatus(ds snapshot.Status) {
 	}
 }
 
+func (s *simplePrinter) DataDirStatus(ds dataDirStatus) {
+	_, rows := makeDataDirStatusTable(ds)
+	for _, row := range rows {
+		fmt.Println(strings.Join(row, ", "))
+	}
+}
+
 func (s *simplePrinter) MoveLeader(leader, target uint64, r v3.MoveLeaderResponse) {
 	fmt.Printf("Leadership transferred from %s to %s\n", types.ID(leader), types.ID(target))
 }
@@ -0,0 +1,280 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"go.etcd.io/etcd/clientv3/snapshot"
+	"go.etcd.io/etcd/etcdserver"
+	"go.etcd.io/etcd/etcdserver/api"
+	"go.etcd.io/etcd/etcdserver/api/membership"
+	"go.etcd.io/etcd/etcdserver/api/snap"
+	"go.etcd.io/etcd/etcdserver/api/v2error"
+	"go.etcd.io/etcd/etcdserver/api/v2store"
+	pb "go.etcd.io/etcd/etcdserver/etcdserverpb"
+	"go.etcd.io/etcd/pkg/pbutil"
+	"go.etcd.io/etcd/pkg/types"
+	"go.etcd.io/etcd/raft/raftpb"
+	"go.etcd.io/etcd/wal"
+	"go.etcd.io/etcd/wal/walpb"
+
+	"github.com/spf13/cobra"
+	bolt "go.etcd.io/bbolt"
+	"go.uber.org/zap"
+)
+
+var (
+	verifyDataDir          string
+	verifyWALDir           string
+	verifySnapshotKeyFiles []string
+)
+
+// NewVerifyCommand returns the cobra command for "verify".
+func NewVerifyCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Loads a data directory not in use by etcd and reports its state",
+		Run:   verifyCommandFunc,
+	}
+	cmd.Flags().StringVar(&verifyDataDir, "data-dir", "", "Path to the data directory")
+	cmd.Flags().StringVar(&verifyWALDir, "wal-dir", "", "Path to the WAL directory, if not in the data directory")
+	cmd.Flags().StringSliceVar(&verifySnapshotKeyFiles, "snapshot-encryption-key-files", nil, "Paths to the key files decrypting the snap files, if encrypted")
+	return cmd
+}
+
+// dataDirStatus is the state of a data directory, as it would be recovered
+// by a member starting from it.
+type dataDirStatus struct {
+	MemberID  types.ID `json:"memberID"`
+	ClusterID types.ID `json:"clusterID"`
+	// SnapshotIndex is the index of the last snap file, from which the
+	// entries of the WAL are replayed.
+	SnapshotIndex uint64 `json:"snapshotIndex"`
+	// CommitIndex is the last committed index recorded in the WAL.
+	CommitIndex uint64 `json:"commitIndex"`
+	LastIndex   uint64 `json:"lastIndex"`
+	LastTerm    uint64 `json:"lastTerm"`
+
+	Members []*membership.Member `json:"members"`
+
+	// V2Keys and V2Hash are the number and the hash of the v2 keys, hidden
+	// ones included, once the committed entries are replayed.
+	V2Keys int    `json:"v2Keys"`
+	V2Hash uint32 `json:"v2Hash"`
+
+	// DB is the status of the backend, and ConsistentIndex the last index
+	// applied to it.
+	DB              snapshot.Status `json:"db"`
+	ConsistentIndex uint64          `json:"consistentIndex"`
+}
+
+func verifyCommandFunc(cmd *cobra.Command, args []string) {
+	if len(verifyDataDir) == 0 {
+		ExitWithError(ExitBadArgs, fmt.Errorf("verify requires --data-dir"))
+	}
+	initDisplayFromCmd(cmd)
+
+	ds, err := verifyDataDirectory(verifyDataDir, verifyWALDir, verifySnapshotKeyFiles)
+	if err != nil {
+		ExitWithError(ExitError, err)
+	}
+	display.DataDirStatus(ds)
+}
+
+// verifyDataDirectory loads the data directory without changing it, and
+// returns its state, or the first inconsistency found.
+func verifyDataDirectory(dataDir, walDir string, keyFiles []string) (ds dataDirStatus, err error) {
+	lg := zap.NewNop()
+	snapDir := filepath.Join(dataDir, "member", "snap")
+	if len(walDir) == 0 {
+		walDir = filepath.Join(dataDir, "member", "wal")
+	}
+	if !wal.Exist(walDir) {
+		return ds, fmt.Errorf("no WAL found in %q", walDir)
+	}
+
+	var keys snap.KeyProvider
+	if len(keyFiles) > 0 {
+		if keys, err = snap.NewFileKeyProvider(keyFiles); err != nil {
+			return ds, err
+		}
+	}
+	// the snap files are read directly: loading them through a Snapshotter
+	// renames the broken ones
+	names, err := filepath.Glob(filepath.Join(snapDir, "*.snap"))
+	if err != nil {
+		return ds, err
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(names)))
+	var raftSnap *raftpb.Snapshot
+	if len(names) > 0 {
+		if raftSnap, err = snap.ReadWithKeys(lg, names[0], keys); err != nil {
+			return ds, fmt.Errorf("failed to read the snap file %q (%v)", names[0], err)
+		}
+	}
+
+	var walsnap walpb.Snapshot
+	if raftSnap != nil {
+		walsnap.Index, walsnap.Term = raftSnap.Metadata.Index, raftSnap.Metadata.Term
+		ds.SnapshotIndex = raftSnap.Metadata.Index
+		ds.LastIndex, ds.LastTerm = walsnap.Index, walsnap.Term
+	}
+	w, err := wal.OpenForRead(lg, walDir, walsnap)
+	if err != nil {
+		return ds, err
+	}
+	defer w.Close()
+	wmetadata, st, ents, err := w.ReadAll()
+	if err != nil {
+		return ds, fmt.Errorf("failed to read the WAL (%v)", err)
+	}
+	var metadata pb.Metadata
+	pbutil.MustUnmarshal(&metadata, wmetadata)
+	ds.MemberID, ds.ClusterID = types.ID(metadata.NodeID), types.ID(metadata.ClusterID)
+	ds.CommitIndex = st.Commit
+	if n := len(ents); n > 0 {
+		ds.LastIndex, ds.LastTerm = ents[n-1].Index, ents[n-1].Term
+	}
+
+	// replay the committed entries onto the snapshot, as a starting member
+	v2st := v2store.New(etcdserver.StoreClusterPrefix, etcdserver.StoreKeysPrefix)
+	if raftSnap != nil {
+		if err = v2st.Recovery(raftSnap.Data); err != nil {
+			return ds, fmt.Errorf("failed to recover the v2 store from the snap file (%v)", err)
+		}
+	}
+	cl := membership.NewCluster(lg, "")
+	cl.SetStore(v2st)
+	cl.Recover(api.UpdateCapability)
+	rp := etcdserver.NewV2Replayer(lg, v2st, cl)
+	for _, ent := range ents {
+		if ent.Index > st.Commit {
+			break
+		}
+		switch ent.Type {
+		case raftpb.EntryConfChange:
+			var cc raftpb.ConfChange
+			pbutil.MustUnmarshal(&cc, ent.Data)
+			applyConf(cc, cl)
+		case raftpb.EntryNormal:
+			var raftReq pb.InternalRaftRequest
+			if !pbutil.MaybeUnmarshal(&raftReq, ent.Data) { // backward compatible
+				var r pb.Request
+				pbutil.MustUnmarshal(&r, ent.Data)
+				err = applyRequest(&r, rp)
+			} else if raftReq.V2 != nil {
+				err = applyRequest(raftReq.V2, rp)
+			}
+			if err != nil {
+				return ds, err
+			}
+		}
+	}
+	ds.Members = cl.Members()
+	if ds.V2Keys, ds.V2Hash, err = hashStoreV2(v2st); err != nil {
+		return ds, err
+	}
+
+	dbPath := filepath.Join(snapDir, "db")
+	if ds.DB, ds.ConsistentIndex, err = verifyBackend(dbPath); err != nil {
+		return ds, err
+	}
+	if ds.ConsistentIndex > ds.LastIndex {
+		return ds, fmt.Errorf("the backend applied index %d is beyond the last index %d of the WAL", ds.ConsistentIndex, ds.LastIndex)
+	}
+	return ds, nil
+}
+
+// hashStoreV2 returns the number of keys of st, hidden ones included, and
+// their hash. The hash does not depend on the time, so that it can be
+// compared between the copies of the data directory.
+func hashStoreV2(st v2store.Store) (int, uint32, error) {
+	ex, err := st.Export(etcdserver.StoreKeysPrefix)
+	if err != nil {
+		if verr, ok := err.(*v2error.Error); ok && verr.ErrorCode == v2error.EcodeKeyNotFound {
+			return 0, 0, nil
+		}
+		return 0, 0, err
+	}
+	h := crc32.New(crc32.MakeTable(crc32.Castagnoli))
+	keys := 0
+	var buf [8]byte
+	writeUint := func(v uint64) {
+		binary.BigEndian.PutUint64(buf[:], v)
+		h.Write(buf[:])
+	}
+	err = ex.Walk(func(n *v2store.NodeExtern) error {
+		h.Write([]byte(n.Key))
+		if n.Dir {
+			h.Write([]byte{1})
+		} else {
+			keys++
+			h.Write([]byte{0})
+			h.Write([]byte(*n.Value))
+		}
+		writeUint(n.CreatedIndex)
+		writeUint(n.ModifiedIndex)
+		if n.Expiration != nil {
+			writeUint(uint64(n.Expiration.UnixNano()))
+		}
+		return nil
+	})
+	return keys, h.Sum32(), err
+}
+
+// verifyBackend checks the integrity of the backend at dbPath, and returns
+// its status and consistent index.
+func verifyBackend(dbPath string) (ds snapshot.Status, index uint64, err error) {
+	if _, err = os.Stat(dbPath); err != nil {
+		return ds, 0, err
+	}
+	bch := make(chan struct{})
+	go func() {
+		defer close(bch)
+		ds, err = snapshot.NewV3(zap.NewNop()).Status(dbPath)
+	}()
+	select {
+	case <-bch:
+	case <-time.After(time.Second):
+		fmt.Fprintf(os.Stderr, "waiting for etcd to close and release its lock on %q. "+
+			"To verify a running etcd instance, stop it or verify a copy of its data directory.\n", dbPath)
+		<-bch
+	}
+	if err != nil {
+		return ds, 0, err
+	}
+
+	db, err := bolt.Open(dbPath, 0400, &bolt.Options{ReadOnly: true})
+	if err != nil {
+		return ds, 0, err
+	}
+	defer db.Close()
+	err = db.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket([]byte("meta")); b != nil {
+			if v := b.Get([]byte("consistent_index")); len(v) == 8 {
+				index = binary.BigEndian.Uint64(v)
+			}
+		}
+		return nil
+	})
+	return ds, index, err
+}
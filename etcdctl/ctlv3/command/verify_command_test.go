@@ -0,0 +1,132 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.etcd.io/etcd/clientv3"
+	"go.etcd.io/etcd/embed"
+	pb "go.etcd.io/etcd/etcdserver/etcdserverpb"
+)
+
+func TestVerifyDataDirectory(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "verify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cfg := embed.NewConfig()
+	cfg.Logger = "zap"
+	cfg.LogOutputs = []string{"/dev/null"}
+	cfg.Name = "default"
+	rand.Seed(time.Now().UnixNano())
+	port := rand.Intn(45000)
+	cURL, _ := url.Parse(fmt.Sprintf("unix://localhost:%d", port))
+	pURL, _ := url.Parse(fmt.Sprintf("unix://localhost:%d", port+1))
+	cfg.LCUrls, cfg.ACUrls = []url.URL{*cURL}, []url.URL{*cURL}
+	cfg.LPUrls, cfg.APUrls = []url.URL{*pURL}, []url.URL{*pURL}
+	cfg.InitialCluster = fmt.Sprintf("%s=%s", cfg.Name, pURL.String())
+	cfg.Dir = filepath.Join(dir, "default.etcd")
+	srv, err := embed.StartEtcd(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-srv.Server.ReadyNotify():
+	case <-time.After(10 * time.Second):
+		srv.Close()
+		t.Fatal("failed to start embed.Etcd")
+	}
+	id := srv.Server.ID()
+
+	cli, err := clientv3.New(clientv3.Config{Endpoints: []string{cURL.String()}})
+	if err != nil {
+		srv.Close()
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		_, err = cli.Put(ctx, fmt.Sprintf("foo%d", i), "bar")
+		cancel()
+		if err != nil {
+			cli.Close()
+			srv.Close()
+			t.Fatal(err)
+		}
+	}
+	cli.Close()
+	// the v2 writes replayed as a member applies them: the retries are
+	// deduplicated, and the claims applied
+	v2reqs := []pb.Request{
+		{Method: "POST", Path: "/1/queue", Val: "a"},
+		{Method: "POST", Path: "/1/queue", Val: "b"},
+		{Method: "PUT", Path: "/1/foo", Val: "bar", ClientRequestID: "c0ffee"},
+		{Method: "PUT", Path: "/1/foo", Val: "bar", ClientRequestID: "c0ffee"},
+		{Method: "CLAIM", Path: "/1/queue", Recursive: true},
+	}
+	for _, r := range v2reqs {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		_, err = srv.Server.Do(ctx, r)
+		cancel()
+		if err != nil {
+			srv.Close()
+			t.Fatal(err)
+		}
+	}
+	srv.Close()
+
+	ds, err := verifyDataDirectory(cfg.Dir, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ds.MemberID != id {
+		t.Errorf("member ID = %s, want %s", ds.MemberID, id)
+	}
+	if len(ds.Members) != 1 || ds.Members[0].ID != id {
+		t.Errorf("members = %+v, want the member %s", ds.Members, id)
+	}
+	if ds.V2Keys != 2 {
+		t.Errorf("v2 keys = %d, want 2", ds.V2Keys)
+	}
+	if ds.DB.Revision != 4 {
+		t.Errorf("revision = %d, want 4", ds.DB.Revision)
+	}
+	if ds.ConsistentIndex == 0 || ds.ConsistentIndex > ds.CommitIndex || ds.CommitIndex > ds.LastIndex {
+		t.Errorf("consistent index %d, commit index %d, last index %d out of order", ds.ConsistentIndex, ds.CommitIndex, ds.LastIndex)
+	}
+
+	// the state does not depend on when it is verified
+	ds2, err := verifyDataDirectory(cfg.Dir, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ds2.V2Hash != ds.V2Hash || ds2.DB.Hash != ds.DB.Hash {
+		t.Errorf("hashes = %x, %x, want %x, %x", ds2.V2Hash, ds2.DB.Hash, ds.V2Hash, ds.DB.Hash)
+	}
+
+	if _, err = verifyDataDirectory(filepath.Join(dir, "nope"), "", nil); err == nil {
+		t.Error("verified a missing data directory")
+	}
+}
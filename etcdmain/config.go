@@ -17,17 +17,21 @@
 package etcdmain
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"net/url"
 	"os"
+	"regexp"
 	"runtime"
+	"strconv"
 	"strings"
 
 	"go.etcd.io/etcd/embed"
 	"go.etcd.io/etcd/pkg/flags"
+	"go.etcd.io/etcd/pkg/transport"
 	"go.etcd.io/etcd/pkg/types"
 	"go.etcd.io/etcd/version"
 
@@ -43,6 +47,12 @@ var (
 	fallbackFlagExit  = "exit"
 	fallbackFlagProxy = "proxy"
 
+	unknownMemberFlagExit  = "exit"
+	unknownMemberFlagProxy = "proxy"
+
+	errProxyClientCAWithoutCert = errors.New("--proxy-client-trusted-ca-file requires a cert served to proxy clients (--proxy-client-cert-file, --cert-file or --auto-tls)")
+	errStandbyWithoutRefreshInterval = errors.New("--proxy-standby-active-size requires a positive --proxy-refresh-interval")
+
 	ignored = []string{
 		"cluster-active-size",
 		"cluster-remove-delay",
@@ -69,10 +79,28 @@ type configProxy struct {
 	ProxyDialTimeoutMs     uint `json:"proxy-dial-timeout"`
 	ProxyWriteTimeoutMs    uint `json:"proxy-write-timeout"`
 	ProxyReadTimeoutMs     uint `json:"proxy-read-timeout"`
+	ProxyStandbyActiveSize uint `json:"proxy-standby-active-size"`
+	ProxyRetryBudget       uint `json:"proxy-retry-budget"`
+	ProxyStaleCacheSize    uint `json:"proxy-stale-cache-size"`
+	ProxyHedgeDelayMs      uint `json:"proxy-hedge-delay"`
+	ProxyRequireAuth       bool `json:"proxy-require-auth"`
+	ProxyAuthCacheTTLMs    uint `json:"proxy-auth-cache-ttl"`
 	Fallback               string
 	Proxy                  string
 	ProxyJSON              string `json:"proxy"`
 	FallbackJSON           string `json:"discovery-fallback"`
+	OnUnknownMember        string
+	OnUnknownMemberJSON    string `json:"on-unknown-member"`
+
+	// ProxyClient* are served to clients of the proxy and ProxyBackend*
+	// are used to dial the cluster members, in place of the client
+	// server TLS configuration.
+	ProxyClientCertFile       string `json:"proxy-client-cert-file"`
+	ProxyClientKeyFile        string `json:"proxy-client-key-file"`
+	ProxyClientTrustedCAFile  string `json:"proxy-client-trusted-ca-file"`
+	ProxyBackendCertFile      string `json:"proxy-backend-cert-file"`
+	ProxyBackendKeyFile       string `json:"proxy-backend-key-file"`
+	ProxyBackendTrustedCAFile string `json:"proxy-backend-trusted-ca-file"`
 }
 
 // config holds the config for a command line invocation of etcd
@@ -82,6 +110,7 @@ type config struct {
 	cf           configFlags
 	configFile   string
 	printVersion bool
+	validateOnly bool
 	ignored      []string
 }
 
@@ -91,6 +120,9 @@ type configFlags struct {
 	clusterState *flags.SelectiveStringValue
 	fallback     *flags.SelectiveStringValue
 	proxy        *flags.SelectiveStringValue
+	// onUnknownMember is the behavior when the local name is not in the
+	// initial cluster configuration.
+	onUnknownMember *flags.SelectiveStringValue
 }
 
 func newConfig() *config {
@@ -120,6 +152,10 @@ func newConfig() *config {
 			proxyFlagReadonly,
 			proxyFlagOn,
 		),
+		onUnknownMember: flags.NewSelectiveStringValue(
+			unknownMemberFlagExit,
+			unknownMemberFlagProxy,
+		),
 	}
 
 	fs := cfg.cf.flagSet
@@ -146,17 +182,27 @@ func newConfig() *config {
 		"listen-metrics-urls",
 		"List of URLs to listen on for the metrics and health endpoints.",
 	)
+	fs.Var(
+		flags.NewUniqueURLsWithExceptions("", ""),
+		"listen-admin-urls",
+		"List of URLs to listen on for the admin endpoints, which are then refused on the client and peer URLs.",
+	)
 	fs.UintVar(&cfg.ec.MaxSnapFiles, "max-snapshots", cfg.ec.MaxSnapFiles, "Maximum number of snapshot files to retain (0 is unlimited).")
 	fs.UintVar(&cfg.ec.MaxWalFiles, "max-wals", cfg.ec.MaxWalFiles, "Maximum number of wal files to retain (0 is unlimited).")
+	fs.Int64Var(&cfg.ec.WALPreallocateBytes, "wal-preallocate-bytes", cfg.ec.WALPreallocateBytes, "Size to preallocate for each wal file (0 preallocates the whole file, a negative value disables preallocation).")
 	fs.StringVar(&cfg.ec.Name, "name", cfg.ec.Name, "Human-readable name for this member.")
-	fs.Uint64Var(&cfg.ec.SnapshotCount, "snapshot-count", cfg.ec.SnapshotCount, "Number of committed transactions to trigger a snapshot to disk.")
+	fs.Var(&snapshotCountValue{ec: &cfg.ec}, "snapshot-count", "Number of committed transactions to trigger a snapshot to disk, or 'auto' to adapt it to the cost of the entries and snapshots.")
 	fs.UintVar(&cfg.ec.TickMs, "heartbeat-interval", cfg.ec.TickMs, "Time (in milliseconds) of a heartbeat interval.")
 	fs.UintVar(&cfg.ec.ElectionMs, "election-timeout", cfg.ec.ElectionMs, "Time (in milliseconds) for an election to timeout.")
 	fs.BoolVar(&cfg.ec.InitialElectionTickAdvance, "initial-election-tick-advance", cfg.ec.InitialElectionTickAdvance, "Whether to fast-forward initial election ticks on boot for faster election.")
 	fs.Int64Var(&cfg.ec.QuotaBackendBytes, "quota-backend-bytes", cfg.ec.QuotaBackendBytes, "Raise alarms when backend size exceeds the given quota. 0 means use the default quota.")
+	fs.Uint64Var(&cfg.ec.MemoryLimitBytes, "memory-limit-bytes", cfg.ec.MemoryLimitBytes, "Estimated memory usage of the V2 store and the raft log above which the writes adding data are refused (0 is unlimited).")
 	fs.DurationVar(&cfg.ec.BackendBatchInterval, "backend-batch-interval", cfg.ec.BackendBatchInterval, "BackendBatchInterval is the maximum time before commit the backend transaction.")
 	fs.IntVar(&cfg.ec.BackendBatchLimit, "backend-batch-limit", cfg.ec.BackendBatchLimit, "BackendBatchLimit is the maximum operations before commit the backend transaction.")
 	fs.UintVar(&cfg.ec.MaxTxnOps, "max-txn-ops", cfg.ec.MaxTxnOps, "Maximum number of operations permitted in a transaction.")
+	fs.UintVar(&cfg.ec.MaxRecursiveKeys, "max-recursive-keys", cfg.ec.MaxRecursiveKeys, "Maximum number of keys a V2 recursive get or delete may touch (0 is unlimited).")
+	fs.UintVar(&cfg.ec.MaxWatchersPerClient, "max-watchers-per-client", cfg.ec.MaxWatchersPerClient, "Maximum number of V2 watchers a client, identified by its remote host, may hold at once (0 is unlimited).")
+	fs.UintVar(&cfg.ec.MaxWatchersPerKey, "max-watchers-per-key", cfg.ec.MaxWatchersPerKey, "Maximum number of V2 watchers a key may have at once (0 is unlimited).")
 	fs.UintVar(&cfg.ec.MaxRequestBytes, "max-request-bytes", cfg.ec.MaxRequestBytes, "Maximum client request size in bytes the server will accept.")
 	fs.DurationVar(&cfg.ec.GRPCKeepAliveMinTime, "grpc-keepalive-min-time", cfg.ec.GRPCKeepAliveMinTime, "Minimum interval duration that a client should wait before pinging server.")
 	fs.DurationVar(&cfg.ec.GRPCKeepAliveInterval, "grpc-keepalive-interval", cfg.ec.GRPCKeepAliveInterval, "Frequency duration of server-to-client ping to check if a connection is alive (0 to disable).")
@@ -185,15 +231,36 @@ func newConfig() *config {
 
 	fs.BoolVar(&cfg.ec.StrictReconfigCheck, "strict-reconfig-check", cfg.ec.StrictReconfigCheck, "Reject reconfiguration requests that would cause quorum loss.")
 	fs.BoolVar(&cfg.ec.EnableV2, "enable-v2", cfg.ec.EnableV2, "Accept etcd V2 client requests.")
+	fs.StringVar(&cfg.ec.V2CacheControl, "v2-cache-control", cfg.ec.V2CacheControl, "Cache-Control header value of V2 key GET responses.")
+	fs.BoolVar(&cfg.ec.V2ETag, "v2-etag", cfg.ec.V2ETag, "Set ETags on V2 key GET responses and honor If-None-Match.")
+	fs.BoolVar(&cfg.ec.V2ImplicitDirs, "v2-implicit-dirs", cfg.ec.V2ImplicitDirs, "Create the missing parent directories of the V2 keys set by PUT and POST requests, unless they set implicitDirs=false.")
+	fs.DurationVar(&cfg.ec.V2TombstoneRetention, "v2-tombstone-retention", cfg.ec.V2TombstoneRetention, "How long deleted V2 keys are retained as tombstones (0 disables tombstones). The value of the leader applies to the whole cluster.")
+	fs.DurationVar(&cfg.ec.V2ExpiredHistoryRetention, "v2-expired-history-retention", cfg.ec.V2ExpiredHistoryRetention, "How long the events of expired V2 keys stay in the event history (0 never prunes them). The value of the leader applies to the whole cluster.")
 	fs.BoolVar(&cfg.ec.PreVote, "pre-vote", cfg.ec.PreVote, "Enable to run an additional Raft election phase.")
+	fs.BoolVar(&cfg.ec.Witness, "witness", cfg.ec.Witness, "Only vote and persist the raft log; never serve clients nor stay leader.")
+	fs.UintVar(&cfg.ec.ElectionPriority, "election-priority", cfg.ec.ElectionPriority, "Priority of this member to become leader; leadership moves to the active member with the highest priority.")
+	fs.StringVar(&cfg.ec.LeaderPlacement, "leader-placement", cfg.ec.LeaderPlacement, "Comma-separated 'key=v1|v2' or 'key!=v1|v2' constraints on the metadata of the members that may stay leader.")
 
 	// proxy
 	fs.Var(cfg.cf.proxy, "proxy", fmt.Sprintf("Valid values include %q", cfg.cf.proxy.Valids()))
+	fs.Var(cfg.cf.onUnknownMember, "on-unknown-member", fmt.Sprintf("Expected behavior when the local name is not in the initial cluster. Valid values include %q", cfg.cf.onUnknownMember.Valids()))
 	fs.UintVar(&cfg.cp.ProxyFailureWaitMs, "proxy-failure-wait", cfg.cp.ProxyFailureWaitMs, "Time (in milliseconds) an endpoint will be held in a failed state.")
 	fs.UintVar(&cfg.cp.ProxyRefreshIntervalMs, "proxy-refresh-interval", cfg.cp.ProxyRefreshIntervalMs, "Time (in milliseconds) of the endpoints refresh interval.")
 	fs.UintVar(&cfg.cp.ProxyDialTimeoutMs, "proxy-dial-timeout", cfg.cp.ProxyDialTimeoutMs, "Time (in milliseconds) for a dial to timeout.")
 	fs.UintVar(&cfg.cp.ProxyWriteTimeoutMs, "proxy-write-timeout", cfg.cp.ProxyWriteTimeoutMs, "Time (in milliseconds) for a write to timeout.")
 	fs.UintVar(&cfg.cp.ProxyReadTimeoutMs, "proxy-read-timeout", cfg.cp.ProxyReadTimeoutMs, "Time (in milliseconds) for a read to timeout.")
+	fs.UintVar(&cfg.cp.ProxyRetryBudget, "proxy-retry-budget", cfg.cp.ProxyRetryBudget, "Maximum number of endpoints a request is sent to before failing. 0 to try all endpoints.")
+	fs.UintVar(&cfg.cp.ProxyStaleCacheSize, "proxy-stale-cache-size", cfg.cp.ProxyStaleCacheSize, "Maximum bytes of GET responses kept to serve, marked stale, when the cluster is unreachable. 0 to disable.")
+	fs.UintVar(&cfg.cp.ProxyHedgeDelayMs, "proxy-hedge-delay", cfg.cp.ProxyHedgeDelayMs, "Time (in milliseconds) after which a read not yet answered is also sent to a second endpoint. 0 to disable.")
+	fs.BoolVar(&cfg.cp.ProxyRequireAuth, "proxy-require-auth", cfg.cp.ProxyRequireAuth, "Reject the API requests without credentials instead of forwarding them to the cluster.")
+	fs.UintVar(&cfg.cp.ProxyAuthCacheTTLMs, "proxy-auth-cache-ttl", cfg.cp.ProxyAuthCacheTTLMs, "Time (in milliseconds) the credentials rejected by the cluster are rejected by the proxy. 0 to disable.")
+	fs.UintVar(&cfg.cp.ProxyStandbyActiveSize, "proxy-standby-active-size", cfg.cp.ProxyStandbyActiveSize, "Number of cluster members below which the proxy promotes itself to a member. 0 to disable.")
+	fs.StringVar(&cfg.cp.ProxyClientCertFile, "proxy-client-cert-file", "", "Path to the TLS cert file served to proxy clients. Overrides --cert-file.")
+	fs.StringVar(&cfg.cp.ProxyClientKeyFile, "proxy-client-key-file", "", "Path to the TLS key file served to proxy clients. Overrides --key-file.")
+	fs.StringVar(&cfg.cp.ProxyClientTrustedCAFile, "proxy-client-trusted-ca-file", "", "Path to the CA file verifying the certs of proxy clients, which must then present one. Overrides --trusted-ca-file.")
+	fs.StringVar(&cfg.cp.ProxyBackendCertFile, "proxy-backend-cert-file", "", "Path to the TLS cert file the proxy presents to cluster members.")
+	fs.StringVar(&cfg.cp.ProxyBackendKeyFile, "proxy-backend-key-file", "", "Path to the TLS key file the proxy presents to cluster members.")
+	fs.StringVar(&cfg.cp.ProxyBackendTrustedCAFile, "proxy-backend-trusted-ca-file", "", "Path to the CA file verifying the certs of cluster members.")
 
 	// security
 	fs.StringVar(&cfg.ec.ClientTLSInfo.CertFile, "cert-file", "", "Path to the client server TLS cert file.")
@@ -209,13 +276,26 @@ func newConfig() *config {
 	fs.BoolVar(&cfg.ec.PeerAutoTLS, "peer-auto-tls", false, "Peer TLS using generated certificates")
 	fs.StringVar(&cfg.ec.PeerTLSInfo.CRLFile, "peer-crl-file", "", "Path to the peer certificate revocation list file.")
 	fs.StringVar(&cfg.ec.PeerTLSInfo.AllowedCN, "peer-cert-allowed-cn", "", "Allowed CN for inter peer authentication.")
+	fs.StringVar(&cfg.ec.PeerSharedSecretFile, "peer-shared-secret-file", "", "Path to a file holding a secret shared by all members to authenticate peer requests.")
+	fs.Var(flags.NewStringsValue(""), "peer-allowed-hosts", "Comma-separated list of the CIDRs of the peers. Other hosts are neither accepted nor dialed as peers.")
+	fs.StringVar(&cfg.ec.PeerTransport, "peer-transport", cfg.ec.PeerTransport, "Protocol the raft messages are sent to peers with, 'http' or 'grpc' (requires --peer-client-cert-auth). Replicated from the leader to the cluster.")
+	fs.DurationVar(&cfg.ec.PeerDialTimeout, "peer-dial-timeout", cfg.ec.PeerDialTimeout, "Timeout of the dials to peers (0 derives it from the election timeout).")
+	fs.DurationVar(&cfg.ec.PeerRequestTimeout, "peer-request-timeout", cfg.ec.PeerRequestTimeout, "Timeout of the requests sending raft messages to peers (0 derives it from the election timeout).")
 	fs.Var(flags.NewStringsValue(""), "cipher-suites", "Comma-separated list of supported TLS cipher suites between client/server and peers (empty will be auto-populated by Go).")
+	fs.StringVar(&cfg.ec.TLSMinVersion, "tls-min-version", "", "Minimum TLS version accepted between client/server and peers, 'TLS1.2' or 'TLS1.3' (Go 1.12+ builds only; empty means 'TLS1.2').")
+	fs.BoolVar(&cfg.ec.TLSSessionTicketsDisabled, "tls-session-tickets-disabled", false, "Disable the resumption of TLS sessions with session tickets between client/server and peers.")
+	fs.IntVar(&cfg.ec.TLSClientSessionCacheSize, "tls-client-session-cache-size", 0, "Number of TLS sessions cached to resume them when reconnecting to peers (0 disables the cache).")
+
+	fs.StringVar(&cfg.ec.User, "user", "", "User, by name or ID, to re-execute etcd as after binding the TCP listeners. The TLS files must be readable by it.")
+	fs.StringVar(&cfg.ec.Group, "group", "", "Group, by name or ID, to re-execute etcd as after binding the TCP listeners (defaults to the primary group of --user).")
 
 	fs.Var(
 		flags.NewUniqueURLsWithExceptions("*", "*"),
 		"cors",
 		"Comma-separated white list of origins for CORS, or cross-origin resource sharing, (empty or * means allow all)",
 	)
+	fs.Var(flags.NewStringsValue(""), "cors-expose-headers", "Comma-separated list of response headers exposed to CORS origins (e.g. X-Etcd-Index).")
+	fs.DurationVar(&cfg.ec.CORSMaxAge, "cors-max-age", cfg.ec.CORSMaxAge, "Duration browsers may cache CORS preflight responses (0 to not advertise it).")
 	fs.Var(flags.NewUniqueStringsValue("*"), "host-whitelist", "Comma-separated acceptable hostnames from HTTP client requests, if server is not secure (empty means allow all).")
 
 	// logging
@@ -227,15 +307,20 @@ func newConfig() *config {
 
 	// version
 	fs.BoolVar(&cfg.printVersion, "version", false, "Print the version and exit.")
+	fs.BoolVar(&cfg.validateOnly, "validate-only", false, "Validate the configuration, TLS files, data directory and listen addresses, print a report and exit.")
 
 	fs.StringVar(&cfg.ec.AutoCompactionRetention, "auto-compaction-retention", "0", "Auto compaction retention for mvcc key value store. 0 means disable auto compaction.")
 	fs.StringVar(&cfg.ec.AutoCompactionMode, "auto-compaction-mode", "periodic", "interpret 'auto-compaction-retention' one of: periodic|revision. 'periodic' for duration based retention, defaulting to hours if no time unit is provided (e.g. '5m'). 'revision' for revision number based retention.")
 
 	// pprof profiler via HTTP
 	fs.BoolVar(&cfg.ec.EnablePprof, "enable-pprof", false, "Enable runtime profiling data via HTTP server. Address is at client URL + \"/debug/pprof/\"")
+	fs.BoolVar(&cfg.ec.EnableDashboard, "enable-dashboard", false, "Enable the web dashboard for the v2 API. Address is at client URL + \"/dashboard/\"")
 
 	// additional metrics
 	fs.StringVar(&cfg.ec.Metrics, "metrics", cfg.ec.Metrics, "Set level of detail for exported metrics, specify 'extensive' to include histogram metrics")
+	fs.StringVar(&cfg.ec.MetricsStatsdAddr, "metrics-statsd-addr", cfg.ec.MetricsStatsdAddr, "Address (host:port) of a statsd server to push the metrics to over UDP.")
+	fs.StringVar(&cfg.ec.MetricsStatsdFormat, "metrics-statsd-format", cfg.ec.MetricsStatsdFormat, "Format of the metrics pushed to statsd, 'statsd' or 'dogstatsd' to send the labels as tags.")
+	fs.DurationVar(&cfg.ec.MetricsStatsdInterval, "metrics-statsd-interval", cfg.ec.MetricsStatsdInterval, "Interval between two pushes of the metrics to statsd.")
 
 	// auth
 	fs.StringVar(&cfg.ec.AuthToken, "auth-token", cfg.ec.AuthToken, "Specify auth token specific options.")
@@ -248,6 +333,24 @@ func newConfig() *config {
 	fs.BoolVar(&cfg.ec.ExperimentalInitialCorruptCheck, "experimental-initial-corrupt-check", cfg.ec.ExperimentalInitialCorruptCheck, "Enable to check data corruption before serving any client/peer traffic.")
 	fs.DurationVar(&cfg.ec.ExperimentalCorruptCheckTime, "experimental-corrupt-check-time", cfg.ec.ExperimentalCorruptCheckTime, "Duration of time between cluster corruption check passes.")
 	fs.StringVar(&cfg.ec.ExperimentalEnableV2V3, "experimental-enable-v2v3", cfg.ec.ExperimentalEnableV2V3, "v3 prefix for serving emulated v2 state.")
+	fs.DurationVar(&cfg.ec.ExperimentalDiskLatencyThreshold, "experimental-disk-latency-threshold", cfg.ec.ExperimentalDiskLatencyThreshold, "Average WAL save or backend commit latency above which the member is marked degraded (0 to disable).")
+	fs.BoolVar(&cfg.ec.ExperimentalDiskDegradedTransferLeadership, "experimental-disk-degraded-transfer-leadership", cfg.ec.ExperimentalDiskDegradedTransferLeadership, "Transfer leadership away from the member while its disk is degraded.")
+	fs.Uint64Var(&cfg.ec.ExperimentalMaxApplyLag, "experimental-max-apply-lag", cfg.ec.ExperimentalMaxApplyLag, "Number of committed but unapplied entries above which serializable V2 reads are refused (0 to disable).")
+	fs.IntVar(&cfg.ec.ExperimentalMaxPendingProposals, "experimental-max-pending-proposals", cfg.ec.ExperimentalMaxPendingProposals, "Number of pending local proposals at which client requests are shed (0 to disable).")
+	fs.IntVar(&cfg.ec.ExperimentalV2WatchReplayEntries, "experimental-v2-watch-replay-entries", cfg.ec.ExperimentalV2WatchReplayEntries, "Maximum number of committed entries replayed to serve a V2 watch from a cleared index (0 to disable).")
+	fs.IntVar(&cfg.ec.ExperimentalMaxForwardedProposals, "experimental-max-forwarded-proposals", cfg.ec.ExperimentalMaxForwardedProposals, "Number of local proposals forwarded to the leader at which a follower refuses new proposals (0 to disable).")
+	fs.Uint64Var(&cfg.ec.ExperimentalMaxApplyBacklog, "experimental-max-apply-backlog", cfg.ec.ExperimentalMaxApplyBacklog, "Number of committed but unapplied entries above which client requests are shed (0 to disable).")
+	fs.IntVar(&cfg.ec.ExperimentalMaxUnstableEntries, "experimental-max-unstable-entries", cfg.ec.ExperimentalMaxUnstableEntries, "Number of entries of a raft Ready to persist above which client requests are shed (0 to disable).")
+	fs.StringVar(&cfg.ec.ExperimentalSnapshotCompression, "experimental-snapshot-compression", cfg.ec.ExperimentalSnapshotCompression, "Compression of the written .snap files, '' or 'flate'.")
+	fs.Var(flags.NewStringsValue(""), "experimental-snapshot-encryption-key-files", "Comma-separated list of the files of the 32-byte keys encrypting the .snap files; the first key encrypts, every key decrypts.")
+	fs.BoolVar(&cfg.ec.ExperimentalLeaseRead, "experimental-lease-read", cfg.ec.ExperimentalLeaseRead, "Serve linearizable reads from the leader lease instead of confirming the leadership to a quorum (ReadIndex).")
+	fs.IntVar(&cfg.ec.ExperimentalApplyAuditEntries, "experimental-apply-audit-entries", cfg.ec.ExperimentalApplyAuditEntries, "Number of last applied entries whose hashes are recorded to compare them with another member (0 to disable).")
+	fs.IntVar(&cfg.ec.ExperimentalFlightRecorderEntries, "experimental-flight-recorder-entries", cfg.ec.ExperimentalFlightRecorderEntries, "Number of last client mutations recorded with their responses (0 to disable).")
+	fs.IntVar(&cfg.ec.ExperimentalClientListenSockets, "experimental-client-listen-sockets", cfg.ec.ExperimentalClientListenSockets, "Number of sockets bound with SO_REUSEPORT, each with its own accept loop, per TCP client listen address (Linux only; 0 or 1 for a single socket).")
+	fs.DurationVar(&cfg.ec.ExperimentalApplyTimeout, "experimental-apply-timeout", cfg.ec.ExperimentalApplyTimeout, "Time after which the member stops if applying an entry has not returned (0 to disable).")
+	fs.IntVar(&cfg.ec.ExperimentalApplyMaxAttempts, "experimental-apply-max-attempts", cfg.ec.ExperimentalApplyMaxAttempts, "Number of times in a row applying an entry may panic or time out before the member refuses to replay it (0 to disable).")
+	fs.BoolVar(&cfg.ec.ExperimentalJoinSeed, "experimental-join-seed", cfg.ec.ExperimentalJoinSeed, "Seed the data directory of a member joining an existing cluster from a snapshot, then add the member to the cluster.")
+	fs.BoolVar(&cfg.ec.ExperimentalClientIdentityHeaders, "experimental-client-identity-headers", cfg.ec.ExperimentalClientIdentityHeaders, "Return the identity and the remote address a client request is attributed to in the response headers.")
 	fs.StringVar(&cfg.ec.ExperimentalBackendFreelistType, "experimental-backend-bbolt-freelist-type", cfg.ec.ExperimentalBackendFreelistType, "ExperimentalBackendFreelistType specifies the type of freelist that boltdb backend uses(array and map are supported types)")
 
 	// unsafe
@@ -293,13 +396,28 @@ func (cfg *config) parse(arguments []string) error {
 		} else {
 			plog.Infof("Loading server configuration from %q. Other configuration command line flags and environment variables will be ignored if provided.", cfg.configFile)
 		}
+		cfg.ec.ConfiguredFlags = map[string]string{"config-file": cfg.configFile}
 	} else {
 		err = cfg.configFromCmdLine()
+		cfg.ec.ConfiguredFlags = configuredFlags(cfg.cf.flagSet)
 	}
 	// now logger is set up
 	return err
 }
 
+// urlPassword matches the password in the user information of URLs.
+var urlPassword = regexp.MustCompile(`(://[^/@:,]*):[^/@,]*@`)
+
+// configuredFlags returns the flags set on the command line or through
+// environment variables, with the passwords of URLs redacted.
+func configuredFlags(fs *flag.FlagSet) map[string]string {
+	fl := make(map[string]string)
+	fs.Visit(func(f *flag.Flag) {
+		fl[f.Name] = urlPassword.ReplaceAllString(f.Value.String(), "$1:xxxxx@")
+	})
+	return fl
+}
+
 func (cfg *config) configFromCmdLine() error {
 	err := flags.SetFlagsFromEnv("ETCD", cfg.cf.flagSet)
 	if err != nil {
@@ -311,11 +429,15 @@ func (cfg *config) configFromCmdLine() error {
 	cfg.ec.LCUrls = flags.UniqueURLsFromFlag(cfg.cf.flagSet, "listen-client-urls")
 	cfg.ec.ACUrls = flags.UniqueURLsFromFlag(cfg.cf.flagSet, "advertise-client-urls")
 	cfg.ec.ListenMetricsUrls = flags.UniqueURLsFromFlag(cfg.cf.flagSet, "listen-metrics-urls")
+	cfg.ec.ListenAdminUrls = flags.UniqueURLsFromFlag(cfg.cf.flagSet, "listen-admin-urls")
 
 	cfg.ec.CORS = flags.UniqueURLsMapFromFlag(cfg.cf.flagSet, "cors")
 	cfg.ec.HostWhitelist = flags.UniqueStringsMapFromFlag(cfg.cf.flagSet, "host-whitelist")
 
 	cfg.ec.CipherSuites = flags.StringsFromFlag(cfg.cf.flagSet, "cipher-suites")
+	cfg.ec.PeerAllowedHosts = flags.StringsFromFlag(cfg.cf.flagSet, "peer-allowed-hosts")
+	cfg.ec.ExperimentalSnapshotEncryptionKeyFiles = flags.StringsFromFlag(cfg.cf.flagSet, "experimental-snapshot-encryption-key-files")
+	cfg.ec.CORSExposeHeaders = flags.StringsFromFlag(cfg.cf.flagSet, "cors-expose-headers")
 
 	// TODO: remove this in v3.5
 	cfg.ec.DeprecatedLogOutput = flags.UniqueStringsFromFlag(cfg.cf.flagSet, "log-output")
@@ -324,6 +446,7 @@ func (cfg *config) configFromCmdLine() error {
 	cfg.ec.ClusterState = cfg.cf.clusterState.String()
 	cfg.cp.Fallback = cfg.cf.fallback.String()
 	cfg.cp.Proxy = cfg.cf.proxy.String()
+	cfg.cp.OnUnknownMember = cfg.cf.onUnknownMember.String()
 
 	// disable default advertise-client-urls if lcurls is set
 	missingAC := flags.IsSet(cfg.cf.flagSet, "listen-client-urls") && !flags.IsSet(cfg.cf.flagSet, "advertise-client-urls")
@@ -363,6 +486,14 @@ func (cfg *config) configFromFile(path string) error {
 		cfg.ec.ListenMetricsUrls = []url.URL(us)
 	}
 
+	if cfg.ec.ListenAdminUrlsJSON != "" {
+		us, err := types.NewURLs(strings.Split(cfg.ec.ListenAdminUrlsJSON, ","))
+		if err != nil {
+			log.Fatalf("unexpected error setting up listen-admin-urls: %v", err)
+		}
+		cfg.ec.ListenAdminUrls = []url.URL(us)
+	}
+
 	if cfg.cp.FallbackJSON != "" {
 		if err := cfg.cf.fallback.Set(cfg.cp.FallbackJSON); err != nil {
 			log.Fatalf("unexpected error setting up discovery-fallback flag: %v", err)
@@ -376,15 +507,54 @@ func (cfg *config) configFromFile(path string) error {
 		}
 		cfg.cp.Proxy = cfg.cf.proxy.String()
 	}
+
+	if cfg.cp.OnUnknownMemberJSON != "" {
+		if err := cfg.cf.onUnknownMember.Set(cfg.cp.OnUnknownMemberJSON); err != nil {
+			log.Fatalf("unexpected error setting up on-unknown-member flag: %v", err)
+		}
+		cfg.cp.OnUnknownMember = cfg.cf.onUnknownMember.String()
+	}
 	return nil
 }
 
+// listenerTLSInfo returns the TLS configuration served to proxy clients:
+// base, the client server configuration, with the files set by the
+// proxy client flags in place of its own. A trusted CA file requires the
+// proxy clients to present certs it verifies.
+func (cp *configProxy) listenerTLSInfo(base transport.TLSInfo) transport.TLSInfo {
+	info := base
+	if cp.ProxyClientCertFile != "" || cp.ProxyClientKeyFile != "" {
+		info.CertFile = cp.ProxyClientCertFile
+		info.KeyFile = cp.ProxyClientKeyFile
+	}
+	if cp.ProxyClientTrustedCAFile != "" {
+		info.TrustedCAFile = cp.ProxyClientTrustedCAFile
+		info.ClientCertAuth = true
+	}
+	return info
+}
+
+// backendTLSInfo returns the TLS configuration used to dial cluster members.
+func (cp *configProxy) backendTLSInfo() transport.TLSInfo {
+	return transport.TLSInfo{
+		CertFile:      cp.ProxyBackendCertFile,
+		KeyFile:       cp.ProxyBackendKeyFile,
+		TrustedCAFile: cp.ProxyBackendTrustedCAFile,
+	}
+}
+
 func (cfg *config) mayBeProxy() bool {
 	mayFallbackToProxy := cfg.ec.Durl != "" && cfg.cp.Fallback == fallbackFlagProxy
 	return cfg.cp.Proxy != proxyFlagOff || mayFallbackToProxy
 }
 
 func (cfg *config) validate() error {
+	if cfg.cp.ProxyClientTrustedCAFile != "" && cfg.cp.ProxyClientCertFile == "" && cfg.ec.ClientTLSInfo.CertFile == "" && !cfg.ec.ClientAutoTLS {
+		return errProxyClientCAWithoutCert
+	}
+	if cfg.cp.ProxyStandbyActiveSize > 0 && cfg.cp.ProxyRefreshIntervalMs == 0 {
+		return errStandbyWithoutRefreshInterval
+	}
 	err := cfg.ec.Validate()
 	// TODO(yichengq): check this for joining through discovery service case
 	if err == embed.ErrUnsetAdvertiseClientURLsFlag && cfg.mayBeProxy() {
@@ -396,3 +566,36 @@ func (cfg *config) validate() error {
 func (cfg config) isProxy() bool               { return cfg.cf.proxy.String() != proxyFlagOff }
 func (cfg config) isReadonlyProxy() bool       { return cfg.cf.proxy.String() == proxyFlagReadonly }
 func (cfg config) shouldFallbackToProxy() bool { return cfg.cf.fallback.String() == fallbackFlagProxy }
+func (cfg config) shouldProxyOnUnknownMember() bool {
+	return cfg.cf.onUnknownMember.String() == unknownMemberFlagProxy
+}
+
+// snapshotCountValue is the value of the snapshot-count flag: a number of
+// entries, or "auto" to adapt the snapshot trigger, up to the configured
+// snapshot count.
+type snapshotCountValue struct {
+	ec *embed.Config
+}
+
+func (v *snapshotCountValue) Set(s string) error {
+	if s == "auto" {
+		v.ec.SnapshotCountAuto = true
+		return nil
+	}
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return err
+	}
+	v.ec.SnapshotCount, v.ec.SnapshotCountAuto = n, false
+	return nil
+}
+
+func (v *snapshotCountValue) String() string {
+	if v.ec == nil {
+		return ""
+	}
+	if v.ec.SnapshotCountAuto {
+		return "auto"
+	}
+	return strconv.FormatUint(v.ec.SnapshotCount, 10)
+}
@@ -18,17 +18,21 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"reflect"
 	"runtime"
 	"strings"
+	"sync"
 	"time"
 
 	"go.etcd.io/etcd/embed"
 	"go.etcd.io/etcd/etcdserver"
 	"go.etcd.io/etcd/etcdserver/api/etcdhttp"
+	"go.etcd.io/etcd/etcdserver/api/rafthttp"
 	"go.etcd.io/etcd/etcdserver/api/v2discovery"
 	"go.etcd.io/etcd/pkg/fileutil"
 	pkgioutil "go.etcd.io/etcd/pkg/ioutil"
@@ -75,9 +79,14 @@ func startEtcdOrProxyV2() {
 				plog.Errorf("When listening on specific address(es), this etcd process must advertise accessible url(s) to each connected client.")
 			}
 		}
+		if cfg.validateOnly {
+			printValidations(os.Stdout, []validation{{check: "configuration", err: err}})
+		}
 		os.Exit(1)
 	}
 
+	setProcessLimits(lg)
+
 	if lg == nil {
 		// TODO: remove in 3.5
 		plog.Infof("etcd Version: %s\n", version.Version)
@@ -125,6 +134,23 @@ func startEtcdOrProxyV2() {
 		}
 	}
 
+	if cfg.validateOnly {
+		if !printValidations(os.Stdout, validateConfig(cfg)) {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	if cfg.ec.User != "" || cfg.ec.Group != "" {
+		if err = execAsUserGroup(&cfg.ec); err != nil {
+			if lg != nil {
+				lg.Fatal("failed to run as user and group", zap.String("user", cfg.ec.User), zap.String("group", cfg.ec.Group), zap.Error(err))
+			} else {
+				plog.Fatalf("cannot run as user %q, group %q (%v)", cfg.ec.User, cfg.ec.Group, err)
+			}
+		}
+	}
+
 	var stopped <-chan struct{}
 	var errc <-chan error
 
@@ -171,6 +197,24 @@ func startEtcdOrProxyV2() {
 					}
 					shouldProxy = true
 				}
+			} else if _, ok := err.(*etcdserver.UnknownMemberError); ok && cfg.shouldProxyOnUnknownMember() {
+				if lg != nil {
+					lg.Warn(
+						"local member is not in the initial cluster, starting as proxy",
+						zap.String("name", cfg.ec.Name),
+						zap.Error(err),
+					)
+				} else {
+					plog.Noticef("%v, starting as proxy", err)
+				}
+				// the data dir was empty, so the member dir was created by
+				// the failed start and would otherwise be taken for a member
+				// data dir on restart
+				if rerr := os.RemoveAll(filepath.Join(cfg.ec.Dir, string(dirMember))); rerr != nil {
+					err = rerr
+				} else {
+					shouldProxy = true
+				}
 			} else if err != nil {
 				if lg != nil {
 					lg.Warn("failed to start etcd", zap.Error(err))
@@ -329,8 +373,14 @@ func startProxy(cfg *config) error {
 	clientTLSInfo.InsecureSkipVerify = cfg.ec.ClientAutoTLS
 	cfg.ec.PeerTLSInfo.InsecureSkipVerify = cfg.ec.PeerAutoTLS
 
+	backendTLSInfo := clientTLSInfo
+	if bt := cfg.cp.backendTLSInfo(); !bt.Empty() || bt.TrustedCAFile != "" {
+		backendTLSInfo = bt
+		backendTLSInfo.Logger = lg
+	}
+
 	pt, err := transport.NewTimeoutTransport(
-		clientTLSInfo,
+		backendTLSInfo,
 		time.Duration(cfg.cp.ProxyDialTimeoutMs)*time.Millisecond,
 		time.Duration(cfg.cp.ProxyReadTimeoutMs)*time.Millisecond,
 		time.Duration(cfg.cp.ProxyWriteTimeoutMs)*time.Millisecond,
@@ -347,7 +397,7 @@ func startProxy(cfg *config) error {
 			plog.Fatalf("could not get certs (%v)", err)
 		}
 	}
-	tr, err := transport.NewTimeoutTransport(
+	ptr, err := transport.NewTimeoutTransport(
 		cfg.ec.PeerTLSInfo,
 		time.Duration(cfg.cp.ProxyDialTimeoutMs)*time.Millisecond,
 		time.Duration(cfg.cp.ProxyReadTimeoutMs)*time.Millisecond,
@@ -356,6 +406,11 @@ func startProxy(cfg *config) error {
 	if err != nil {
 		return err
 	}
+	peerSharedSecret, err := cfg.ec.PeerSharedSecret()
+	if err != nil {
+		return err
+	}
+	tr := rafthttp.NewSharedSecretRoundTripper(ptr, peerSharedSecret)
 
 	cfg.ec.Dir = filepath.Join(cfg.ec.Dir, "proxy")
 	err = os.MkdirAll(cfg.ec.Dir, fileutil.PrivateDirMode)
@@ -433,6 +488,7 @@ func startProxy(cfg *config) error {
 		return err
 	}
 
+	var peerMu sync.Mutex
 	clientURLs := []string{}
 	uf := func() []string {
 		gcls, gerr := etcdserver.GetClusterFromRemotePeers(lg, peerURLs, tr)
@@ -494,11 +550,13 @@ func startProxy(cfg *config) error {
 				plog.Noticef("proxy: updated peer urls in cluster file from %v to %v", peerURLs, gcls.PeerURLs())
 			}
 		}
+		peerMu.Lock()
 		peerURLs = gcls.PeerURLs()
+		peerMu.Unlock()
 
 		return clientURLs
 	}
-	ph := httpproxy.NewHandler(pt, uf, time.Duration(cfg.cp.ProxyFailureWaitMs)*time.Millisecond, time.Duration(cfg.cp.ProxyRefreshIntervalMs)*time.Millisecond)
+	ph := httpproxy.NewHandler(pt, uf, time.Duration(cfg.cp.ProxyFailureWaitMs)*time.Millisecond, time.Duration(cfg.cp.ProxyRefreshIntervalMs)*time.Millisecond, int(cfg.cp.ProxyRetryBudget), int(cfg.cp.ProxyStaleCacheSize), time.Duration(cfg.cp.ProxyHedgeDelayMs)*time.Millisecond, cfg.cp.ProxyRequireAuth, time.Duration(cfg.cp.ProxyAuthCacheTTLMs)*time.Millisecond)
 	ph = embed.WrapCORS(cfg.ec.CORS, ph)
 
 	if cfg.isReadonlyProxy() {
@@ -516,7 +574,7 @@ func startProxy(cfg *config) error {
 		cTLS = cTLS || u.Scheme == "https"
 	}
 	listenerTLS := cfg.ec.ClientTLSInfo
-	if cfg.ec.ClientAutoTLS && cTLS {
+	if cfg.cp.ProxyClientCertFile == "" && cfg.ec.ClientAutoTLS && cTLS {
 		listenerTLS, err = transport.SelfCert(cfg.ec.GetLogger(), filepath.Join(cfg.ec.Dir, "clientCerts"), cHosts)
 		if err != nil {
 			if lg != nil {
@@ -526,13 +584,53 @@ func startProxy(cfg *config) error {
 			}
 		}
 	}
+	listenerTLS = cfg.cp.listenerTLSInfo(listenerTLS)
+	if listenerTLS.Logger == nil {
+		listenerTLS.Logger = lg
+	}
 
-	// Start a proxy server goroutine for each listen address
-	for _, u := range cfg.ec.LCUrls {
+	sb := &standby{
+		cfg: cfg,
+		pt:  pt,
+		tr:  tr,
+		peerURLs: func() []string {
+			peerMu.Lock()
+			defer peerMu.Unlock()
+			return peerURLs
+		},
+	}
+
+	// Start a proxy server goroutine for each listen address; the admin
+	// URLs are served like the client URLs, and are then the only ones
+	// serving the promotion of the proxy
+	urls := append(append([]url.URL{}, cfg.ec.LCUrls...), cfg.ec.ListenAdminUrls...)
+	// listen on every address before serving any, so that a failure does
+	// not leave servers running
+	ls := make([]net.Listener, 0, len(urls))
+	for _, u := range urls {
 		l, err := transport.NewListener(u.Host, u.Scheme, &listenerTLS)
 		if err != nil {
+			for _, l := range ls {
+				l.Close()
+			}
 			return err
 		}
+		ls = append(ls, l)
+	}
+	for i, u := range urls {
+		l := ls[i]
+		mux := http.NewServeMux()
+		etcdhttp.HandlePrometheus(mux) // v2 proxy just uses the same port
+		mux.Handle("/", ph)
+		if i >= len(cfg.ec.LCUrls) || len(cfg.ec.ListenAdminUrls) == 0 {
+			mux.HandleFunc(standbyPromotePath, sb.servePromote)
+		} else {
+			mux.HandleFunc(standbyPromotePath, func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "only served on the admin URLs", http.StatusForbidden)
+			})
+		}
+		srv := &http.Server{Handler: mux}
+		sb.srvs = append(sb.srvs, srv)
 
 		host := u.String()
 		go func() {
@@ -541,12 +639,15 @@ func startProxy(cfg *config) error {
 			} else {
 				plog.Infof("v2 proxy started listening on client requests on %q", host)
 			}
-			mux := http.NewServeMux()
-			etcdhttp.HandlePrometheus(mux) // v2 proxy just uses the same port
-			mux.Handle("/", ph)
-			plog.Fatal(http.Serve(l, mux))
+			if err := srv.Serve(l); err != http.ErrServerClosed {
+				plog.Fatal(err)
+			}
 		}()
 	}
+
+	if cfg.cp.ProxyStandbyActiveSize > 0 {
+		go sb.run()
+	}
 	return nil
 }
 
@@ -84,6 +84,9 @@ var (
 	grpcProxyEnablePprof    bool
 	grpcProxyEnableOrdering bool
 
+	grpcProxyCacheWatch         bool
+	grpcProxyCacheWatchPrefixes []string
+
 	grpcProxyDebug bool
 )
 
@@ -142,6 +145,8 @@ func newGRPCProxyStartCommand() *cobra.Command {
 	// experimental flags
 	cmd.Flags().BoolVar(&grpcProxyEnableOrdering, "experimental-serializable-ordering", false, "Ensure serializable reads have monotonically increasing store revisions across endpoints.")
 	cmd.Flags().StringVar(&grpcProxyLeasing, "experimental-leasing-prefix", "", "leasing metadata prefix for disconnected linearized reads.")
+	cmd.Flags().BoolVar(&grpcProxyCacheWatch, "experimental-cache-watch", false, "Watch the cluster to invalidate cached ranges changed by other clients.")
+	cmd.Flags().StringSliceVar(&grpcProxyCacheWatchPrefixes, "experimental-cache-watch-prefixes", nil, "comma separated key prefixes to watch for cache invalidation (whole keyspace if empty).")
 
 	cmd.Flags().BoolVar(&grpcProxyDebug, "debug", false, "Enable debug-level logging for grpc-proxy.")
 
@@ -345,7 +350,14 @@ func newGRPCProxyServer(lg *zap.Logger, client *clientv3.Client) *grpc.Server {
 		client.KV, _, _ = leasing.NewKV(client, grpcProxyLeasing)
 	}
 
-	kvp, _ := grpcproxy.NewKvProxy(client)
+	var cacheWatchPrefixes []string
+	if grpcProxyCacheWatch {
+		cacheWatchPrefixes = grpcProxyCacheWatchPrefixes
+		if len(cacheWatchPrefixes) == 0 {
+			cacheWatchPrefixes = []string{""}
+		}
+	}
+	kvp, _ := grpcproxy.NewKvProxy(client, cacheWatchPrefixes...)
 	watchp, _ := grpcproxy.NewWatchProxy(client)
 	if grpcProxyResolverPrefix != "" {
 		grpcproxy.Register(client, grpcProxyResolverPrefix, grpcProxyAdvertiseClientURL, grpcProxyResolverTTL)
@@ -19,6 +19,8 @@ import (
 	"strconv"
 
 	"go.etcd.io/etcd/embed"
+	"go.etcd.io/etcd/wal"
+
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -31,6 +33,9 @@ var (
   etcd --version
     Show the version of etcd.
 
+  etcd --validate-only [flags]
+    Validate the configuration, TLS files, data directory and listen addresses, print a report and exit.
+
   etcd -h | --help
     Show the help information about etcd.
 
@@ -52,7 +57,7 @@ Member:
   --wal-dir ''
     Path to the dedicated wal directory.
   --snapshot-count '100000'
-    Number of committed transactions to trigger a snapshot to disk.
+    Number of committed transactions to trigger a snapshot to disk, or 'auto' to adapt it to the cost of the entries and snapshots.
   --heartbeat-interval '100'
     Time (in milliseconds) of a heartbeat interval.
   --election-timeout '1000'
@@ -60,21 +65,31 @@ Member:
   --initial-election-tick-advance 'true'
     Whether to fast-forward initial election ticks on boot for faster election.
   --listen-peer-urls 'http://localhost:2380'
-    List of URLs to listen on for peer traffic.
+    List of URLs to listen on for peer traffic. A URL with the address of a client URL shares its port.
   --listen-client-urls 'http://localhost:2379'
     List of URLs to listen on for client traffic.
   --max-snapshots '` + strconv.Itoa(embed.DefaultMaxSnapshots) + `'
     Maximum number of snapshot files to retain (0 is unlimited).
   --max-wals '` + strconv.Itoa(embed.DefaultMaxWALs) + `'
     Maximum number of wal files to retain (0 is unlimited).
+  --wal-preallocate-bytes '` + strconv.FormatInt(wal.SegmentSizeBytes, 10) + `'
+    Size to preallocate for each wal file (0 preallocates the whole file, a negative value disables preallocation).
   --quota-backend-bytes '0'
     Raise alarms when backend size exceeds the given quota (0 defaults to low space quota).
+  --memory-limit-bytes '0'
+    Estimated memory usage of the V2 store and the raft log above which the writes adding data are refused (0 is unlimited).
   --backend-batch-interval ''
     BackendBatchInterval is the maximum time before commit the backend transaction.
   --backend-batch-limit '0'
     BackendBatchLimit is the maximum operations before commit the backend transaction.
   --max-txn-ops '128'
     Maximum number of operations permitted in a transaction.
+  --max-recursive-keys '0'
+    Maximum number of keys a V2 recursive get or delete may touch (0 is unlimited).
+  --max-watchers-per-client '0'
+    Maximum number of V2 watchers a client, identified by its remote host, may hold at once (0 is unlimited).
+  --max-watchers-per-key '0'
+    Maximum number of V2 watchers a key may have at once (0 is unlimited).
   --max-request-bytes '1572864'
     Maximum client request size in bytes the server will accept.
   --grpc-keepalive-min-time '5s'
@@ -112,12 +127,28 @@ Clustering:
     Reject reconfiguration requests that would cause quorum loss.
   --pre-vote 'false'
     Enable to run an additional Raft election phase.
+  --election-priority '0'
+    Priority of this member to become leader; leadership moves to the active member with the highest priority.
+  --witness 'false'
+    Only vote and persist the raft log; never serve clients nor stay leader.
+  --leader-placement ''
+    Comma-separated 'key=v1|v2' or 'key!=v1|v2' constraints on the metadata of the members that may stay leader.
   --auto-compaction-retention '0'
     Auto compaction retention length. 0 means disable auto compaction.
   --auto-compaction-mode 'periodic'
     Interpret 'auto-compaction-retention' one of: periodic|revision. 'periodic' for duration based retention, defaulting to hours if no time unit is provided (e.g. '5m'). 'revision' for revision number based retention.
   --enable-v2 '` + strconv.FormatBool(embed.DefaultEnableV2) + `'
     Accept etcd V2 client requests.
+  --v2-cache-control ''
+    Cache-Control header value of V2 key GET responses (e.g. 'max-age=5').
+  --v2-etag 'false'
+    Set ETags on V2 key GET responses and honor If-None-Match.
+  --v2-implicit-dirs 'true'
+    Create the missing parent directories of the V2 keys set by PUT and POST requests, unless they set implicitDirs=false.
+  --v2-tombstone-retention '0s'
+    How long deleted V2 keys are retained as tombstones (0 disables tombstones). The value of the leader applies to the whole cluster.
+  --v2-expired-history-retention '0s'
+    How long the events of expired V2 keys stay in the event history (0 never prunes them). The value of the leader applies to the whole cluster.
 
 Security:
   --cert-file ''
@@ -146,12 +177,36 @@ Security:
     Peer TLS using self-generated certificates if --peer-key-file and --peer-cert-file are not provided.
   --peer-crl-file ''
     Path to the peer certificate revocation list file.
+  --peer-shared-secret-file ''
+    Path to a file holding a secret shared by all members to sign and authenticate peer requests, for deployments without peer TLS.
+  --peer-allowed-hosts ''
+    Comma-separated list of the CIDRs of the peers. Connections to the peer listeners from other hosts are closed, and other hosts are not dialed as peers.
+  --peer-transport 'http'
+    Protocol the raft messages are sent to peers with, 'http' or 'grpc' (streams over HTTP/2 with flow control, requires --peer-client-cert-auth). Replicated from the leader to the cluster.
+  --peer-dial-timeout '0s'
+    Timeout of the dials to peers (0 derives it from the election timeout: 1s + election timeout).
+  --peer-request-timeout '0s'
+    Timeout of the requests sending raft messages to peers (0 derives it from the election timeout: 5s + election timeout).
   --cipher-suites ''
     Comma-separated list of supported TLS cipher suites between client/server and peers (empty will be auto-populated by Go).
+  --tls-min-version ''
+    Minimum TLS version accepted between client/server and peers, 'TLS1.2' or 'TLS1.3' (Go 1.12+ builds only; empty means 'TLS1.2').
+  --tls-session-tickets-disabled 'false'
+    Disable the resumption of TLS sessions with session tickets between client/server and peers.
+  --tls-client-session-cache-size 0
+    Number of TLS sessions cached to resume them when reconnecting to peers (0 disables the cache).
   --cors '*'
     Comma-separated whitelist of origins for CORS, or cross-origin resource sharing, (empty or * means allow all).
+  --cors-expose-headers ''
+    Comma-separated list of response headers exposed to CORS origins (e.g. X-Etcd-Index).
+  --cors-max-age 0s
+    Duration browsers may cache CORS preflight responses (0 to not advertise it).
   --host-whitelist '*'
     Acceptable hostnames from HTTP client requests, if server is not secure (empty or * means allow all).
+  --user ''
+    User, by name or ID, to re-execute etcd as after binding the TCP listeners. The TLS files must be readable by it.
+  --group ''
+    Group, by name or ID, to re-execute etcd as after binding the TCP listeners (defaults to the primary group of --user).
 
 Auth:
   --auth-token 'simple'
@@ -162,10 +217,20 @@ Auth:
 Profiling and Monitoring:
   --enable-pprof 'false'
     Enable runtime profiling data via HTTP server. Address is at client URL + "/debug/pprof/"
+  --enable-dashboard 'false'
+    Enable the web dashboard for the v2 API. Address is at client URL + "/dashboard/"
   --metrics 'basic'
     Set level of detail for exported metrics, specify 'extensive' to include histogram metrics.
   --listen-metrics-urls ''
-    List of URLs to listen on for the metrics and health endpoints.
+    List of URLs to listen on for the metrics and health endpoints. A URL with the address of a client URL shares its port.
+  --metrics-statsd-addr ''
+    Address (host:port) of a statsd server to push the metrics to over UDP.
+  --metrics-statsd-format 'statsd'
+    Format of the metrics pushed to statsd, 'statsd' or 'dogstatsd' to send the labels as tags.
+  --metrics-statsd-interval '10s'
+    Interval between two pushes of the metrics to statsd.
+  --listen-admin-urls ''
+    List of URLs to listen on for the admin endpoints (member management, compaction, snapshot, defragment, alarms, leader transfer, pprof), which are then refused on the client and peer URLs.
 
 Logging:
   --logger 'capnslog'
@@ -182,6 +247,9 @@ Logging (to be deprecated in v3.5):
 v2 Proxy (to be deprecated in v4):
   --proxy 'off'
     Proxy mode setting ('off', 'readonly' or 'on').
+  --on-unknown-member 'exit'
+    Expected behavior ('exit' or 'proxy') when the local name is not in the initial cluster configuration.
+    "proxy" starts a v2 proxy to the listed members instead of a member.
   --proxy-failure-wait 5000
     Time (in milliseconds) an endpoint will be held in a failed state.
   --proxy-refresh-interval 30000
@@ -192,6 +260,30 @@ v2 Proxy (to be deprecated in v4):
     Time (in milliseconds) for a write to timeout.
   --proxy-read-timeout 0
     Time (in milliseconds) for a read to timeout.
+  --proxy-retry-budget 0
+    Maximum number of endpoints a request is sent to before failing. 0 to try all endpoints.
+  --proxy-stale-cache-size 0
+    Maximum bytes of GET responses kept to serve, marked stale, when the cluster is unreachable. 0 to disable.
+  --proxy-hedge-delay 0
+    Time (in milliseconds) after which a read not yet answered is also sent to a second endpoint. 0 to disable.
+  --proxy-require-auth 'false'
+    Reject the API requests without credentials instead of forwarding them to the cluster.
+  --proxy-auth-cache-ttl 0
+    Time (in milliseconds) the credentials rejected by the cluster are rejected by the proxy. 0 to disable.
+  --proxy-standby-active-size 0
+    Number of cluster members below which the proxy promotes itself to a member. 0 to disable.
+  --proxy-client-cert-file ''
+    Path to the TLS cert file served to proxy clients. Overrides --cert-file.
+  --proxy-client-key-file ''
+    Path to the TLS key file served to proxy clients. Overrides --key-file.
+  --proxy-client-trusted-ca-file ''
+    Path to the CA file verifying the certs of proxy clients, which must then present one. Overrides --trusted-ca-file.
+  --proxy-backend-cert-file ''
+    Path to the TLS cert file the proxy presents to cluster members.
+  --proxy-backend-key-file ''
+    Path to the TLS key file the proxy presents to cluster members.
+  --proxy-backend-trusted-ca-file ''
+    Path to the CA file verifying the certs of cluster members.
 
 Experimental feature:
   --experimental-initial-corrupt-check 'false'
@@ -200,6 +292,42 @@ Experimental feature:
     Duration of time between cluster corruption check passes.
   --experimental-enable-v2v3 ''
     Serve v2 requests through the v3 backend under a given prefix.
+  --experimental-disk-latency-threshold '0s'
+    Average WAL save or backend commit latency above which the member is marked degraded (0 to disable).
+  --experimental-disk-degraded-transfer-leadership 'false'
+    Transfer leadership away from the member while its disk is degraded.
+  --experimental-max-apply-lag '0'
+    Number of committed but unapplied entries above which serializable V2 reads are refused (0 to disable).
+  --experimental-max-pending-proposals '0'
+    Number of pending local proposals at which client requests are shed (0 to disable).
+  --experimental-v2-watch-replay-entries '0'
+    Maximum number of committed entries replayed to serve a V2 watch from a cleared index (0 to disable).
+  --experimental-max-forwarded-proposals '0'
+    Number of local proposals forwarded to the leader at which a follower refuses new proposals (0 to disable).
+  --experimental-max-apply-backlog '0'
+    Number of committed but unapplied entries above which client requests are shed (0 to disable).
+  --experimental-max-unstable-entries '0'
+    Number of entries of a raft Ready to persist above which client requests are shed (0 to disable).
+  --experimental-snapshot-compression ''
+    Compression of the written .snap files, '' or 'flate'.
+  --experimental-snapshot-encryption-key-files ''
+    Comma-separated list of the files of the 32-byte keys encrypting the .snap files; the first key encrypts, every key decrypts.
+  --experimental-lease-read 'false'
+    Serve linearizable reads from the leader lease instead of confirming the leadership to a quorum (ReadIndex).
+  --experimental-apply-audit-entries '0'
+    Number of last applied entries whose hashes are recorded to compare them with another member (0 to disable).
+  --experimental-flight-recorder-entries '0'
+    Number of last client mutations recorded with their responses (0 to disable).
+  --experimental-client-listen-sockets '0'
+    Number of sockets bound with SO_REUSEPORT, each with its own accept loop, per TCP client listen address (Linux only; 0 or 1 for a single socket).
+  --experimental-apply-timeout '0s'
+    Time after which the member stops if applying an entry has not returned (0 to disable).
+  --experimental-apply-max-attempts '0'
+    Number of times in a row applying an entry may panic or time out before the member refuses to replay it (0 to disable).
+  --experimental-join-seed 'false'
+    Seed the data directory of a member joining an existing cluster from a snapshot, then add the member to the cluster.
+  --experimental-client-identity-headers 'false'
+    Return the identity and the remote address a client request is attributed to in the response headers.
   --experimental-backend-bbolt-freelist-type
     ExperimentalBackendFreelistType specifies the type of freelist that boltdb backend uses(array and map are supported types).
 
@@ -0,0 +1,87 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdmain
+
+import (
+	"os"
+	"runtime"
+
+	runtimeutil "go.etcd.io/etcd/pkg/runtime"
+
+	"go.uber.org/zap"
+)
+
+// setProcessLimits adapts the limits of the process to its environment,
+// before the listeners are opened and the server is started.
+func setProcessLimits(lg *zap.Logger) {
+	setMaxProcs(lg)
+	raiseFDLimit(lg)
+}
+
+// setMaxProcs lowers GOMAXPROCS to the CPU quota of the cgroup of the
+// process, unless GOMAXPROCS is set in the environment. Otherwise, the
+// runtime schedules on every CPU of the host and the process is throttled
+// by the quota.
+func setMaxProcs(lg *zap.Logger) {
+	if os.Getenv("GOMAXPROCS") != "" {
+		return
+	}
+	quota, err := runtimeutil.CPUQuota()
+	if err != nil || quota == 0 {
+		return
+	}
+	procs := int(quota)
+	if procs < 1 {
+		procs = 1
+	}
+	if prev := runtime.GOMAXPROCS(0); procs < prev {
+		runtime.GOMAXPROCS(procs)
+		if lg != nil {
+			lg.Info(
+				"set GOMAXPROCS to CPU quota",
+				zap.Float64("cpu-quota", quota),
+				zap.Int("previous-max-procs", prev),
+				zap.Int("max-procs", procs),
+			)
+		} else {
+			plog.Infof("set GOMAXPROCS to %d to match the CPU quota %.2f (was %d)", procs, quota, prev)
+		}
+	}
+}
+
+// raiseFDLimit raises the soft limit of the open file descriptors to the
+// hard limit; every client connection and watch stream holds one.
+func raiseFDLimit(lg *zap.Logger) {
+	prev, err := runtimeutil.FDLimit()
+	if err != nil {
+		return
+	}
+	limit, err := runtimeutil.RaiseFDLimit()
+	if err != nil {
+		if lg != nil {
+			lg.Warn("failed to raise file descriptor limit", zap.Uint64("limit", prev), zap.Error(err))
+		} else {
+			plog.Warningf("cannot raise the file descriptor limit %d (%v)", prev, err)
+		}
+		return
+	}
+	if limit > prev {
+		if lg != nil {
+			lg.Info("raised file descriptor limit", zap.Uint64("previous-limit", prev), zap.Uint64("limit", limit))
+		} else {
+			plog.Infof("raised the file descriptor limit from %d to %d", prev, limit)
+		}
+	}
+}
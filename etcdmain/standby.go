@@ -0,0 +1,284 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdmain
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.etcd.io/etcd/client"
+	"go.etcd.io/etcd/embed"
+	"go.etcd.io/etcd/etcdserver"
+	"go.etcd.io/etcd/etcdserver/api/membership"
+	"go.etcd.io/etcd/pkg/osutil"
+	"go.etcd.io/etcd/pkg/types"
+
+	"go.uber.org/zap"
+)
+
+// standbyPromotePath is the admin endpoint of a proxy promoting it.
+const standbyPromotePath = "/v2/admin/promote"
+
+var errPromoting = errors.New("proxy is already being promoted")
+
+// standby promotes a v2 proxy to a full member of the cluster it forwards
+// to: it adds the proxy to the cluster and restarts it as a member. The
+// promotion is requested through the admin API of the proxy or, with an
+// active size, once the number of members drops below it.
+type standby struct {
+	cfg *config
+	// pt is the transport used to talk to client URLs; tr to peer URLs.
+	pt *http.Transport
+	tr http.RoundTripper
+	// peerURLs returns the latest known peer URLs of the cluster.
+	peerURLs func() []string
+	// srvs serve client requests while the node is a proxy; only the
+	// servers started are added.
+	srvs []*http.Server
+
+	mu        sync.Mutex
+	promoting bool
+}
+
+func (sb *standby) run() {
+	lg := sb.cfg.ec.GetLogger()
+	interval := time.Duration(sb.cfg.cp.ProxyRefreshIntervalMs) * time.Millisecond
+	if lg != nil {
+		lg.Info(
+			"proxy running as standby",
+			zap.Uint("active-size", sb.cfg.cp.ProxyStandbyActiveSize),
+			zap.Duration("check-interval", interval),
+		)
+	} else {
+		plog.Infof("proxy: running as standby for an active size of %d", sb.cfg.cp.ProxyStandbyActiveSize)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		sb.mu.Lock()
+		promoting := sb.promoting
+		sb.mu.Unlock()
+		if promoting {
+			// promoted through the admin API
+			return
+		}
+		cl, err := etcdserver.GetClusterFromRemotePeers(lg, sb.peerURLs(), sb.tr)
+		if err != nil {
+			// the proxy logs the same failure on refresh
+			continue
+		}
+		n := len(cl.Members())
+		if uint(n) >= sb.cfg.cp.ProxyStandbyActiveSize {
+			continue
+		}
+		if lg != nil {
+			lg.Info(
+				"cluster is below active size; promoting standby",
+				zap.Int("members", n),
+				zap.Uint("active-size", sb.cfg.cp.ProxyStandbyActiveSize),
+			)
+		} else {
+			plog.Noticef("proxy: cluster has %d members, below active size %d; promoting standby", n, sb.cfg.cp.ProxyStandbyActiveSize)
+		}
+		if err = sb.promote(cl); err != nil {
+			if err == errPromoting {
+				return
+			}
+			if lg != nil {
+				lg.Warn("failed to promote standby", zap.Error(err))
+			} else {
+				plog.Warningf("proxy: failed to promote standby (%v)", err)
+			}
+			continue
+		}
+		return
+	}
+}
+
+// promote adds this node to the cluster and restarts it as a member.
+// It only returns on failure to join the cluster.
+func (sb *standby) promote(cl *membership.RaftCluster) error {
+	m, err := sb.join(cl, "", "")
+	if err != nil {
+		return err
+	}
+	sb.restart(cl, m)
+	return nil
+}
+
+// join adds this node to the cluster as a member, authenticating to the
+// cluster with the given credentials if any. The node must then restart
+// as the member.
+func (sb *standby) join(cl *membership.RaftCluster, username, password string) (*client.Member, error) {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	if sb.promoting {
+		return nil, errPromoting
+	}
+
+	c, err := client.New(client.Config{
+		Endpoints: cl.ClientURLs(),
+		Transport: sb.pt,
+		Username:  username,
+		Password:  password,
+	})
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	// add the member with all its peer URLs at once: once added, it is
+	// counted in the quorum, which it cannot join before it restarts
+	m, err := client.NewMembersAPI(c).AddWithPeerURLs(ctx, types.URLs(sb.cfg.ec.APUrls).StringSlice())
+	if err != nil {
+		return nil, err
+	}
+	sb.promoting = true
+	return m, nil
+}
+
+// restart stops serving as a proxy, hands over the data directory and
+// restarts the node as the member m of the cluster. The data directory
+// of the member is initialized from the snapshot the leader sends to new
+// members. It never returns.
+func (sb *standby) restart(cl *membership.RaftCluster, m *client.Member) {
+	lg := sb.cfg.ec.GetLogger()
+
+	// let the requests in flight, and the promotion request itself, complete
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	for _, srv := range sb.srvs {
+		srv.Shutdown(ctx)
+	}
+	cancel()
+	proxyDir := sb.cfg.ec.Dir
+	if err := os.RemoveAll(proxyDir); err != nil {
+		if lg != nil {
+			lg.Fatal("failed to remove proxy directory", zap.String("path", proxyDir), zap.Error(err))
+		} else {
+			plog.Fatalf("proxy: could not remove proxy directory %q (%v)", proxyDir, err)
+		}
+	}
+
+	ec := &sb.cfg.ec
+	ec.Dir = filepath.Dir(proxyDir)
+	ec.Durl, ec.DNSCluster = "", ""
+	ec.ClusterState = embed.ClusterStateFlagExisting
+	ec.InitialCluster = standbyInitialCluster(cl.Members(), ec.Name, m.PeerURLs)
+
+	stopped, errc, err := startEtcd(ec)
+	if err != nil {
+		if lg != nil {
+			lg.Fatal("failed to start promoted standby", zap.Error(err))
+		} else {
+			plog.Fatalf("proxy: could not start promoted standby (%v)", err)
+		}
+	}
+	if lg != nil {
+		lg.Info("standby promoted to member", zap.String("name", ec.Name), zap.String("member-id", m.ID))
+	} else {
+		plog.Noticef("proxy: standby promoted to member %s (%s)", ec.Name, m.ID)
+	}
+
+	select {
+	case lerr := <-errc:
+		if lg != nil {
+			lg.Fatal("listener failed", zap.Error(lerr))
+		} else {
+			plog.Fatal(lerr)
+		}
+	case <-stopped:
+	}
+	osutil.Exit(0)
+}
+
+// servePromote promotes the proxy on POST. The credentials of the request
+// are used to add the member, so the cluster authorizes the promotion. It
+// responds with the added member, and restarts as that member once the
+// response is sent.
+func (sb *standby) servePromote(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	lg := sb.cfg.ec.GetLogger()
+	cl, err := etcdserver.GetClusterFromRemotePeers(lg, sb.peerURLs(), sb.tr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	username, password, _ := r.BasicAuth()
+	m, err := sb.join(cl, username, password)
+	switch {
+	case err == errPromoting:
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		if lg != nil {
+			lg.Warn("failed to promote standby", zap.Error(err))
+		} else {
+			plog.Warningf("proxy: failed to promote standby (%v)", err)
+		}
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	if lg != nil {
+		lg.Info("promoting standby on request", zap.String("member-id", m.ID), zap.String("remote-addr", r.RemoteAddr))
+	} else {
+		plog.Noticef("proxy: promoting standby on request from %s", r.RemoteAddr)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	if err = json.NewEncoder(w).Encode(m); err != nil {
+		if lg != nil {
+			lg.Warn("failed to encode promoted member", zap.Error(err))
+		} else {
+			plog.Warningf("proxy: failed to encode promoted member (%v)", err)
+		}
+	}
+	// restart waits for this response to complete before shutting down
+	go sb.restart(cl, m)
+}
+
+// standbyInitialCluster returns the initial cluster of a standby that
+// joins as member name with the given peer URLs. Members that have not
+// started yet have no name and are keyed by ID instead; only their peer
+// URLs are checked when joining an existing cluster.
+func standbyInitialCluster(membs []*membership.Member, name string, peerURLs []string) string {
+	m := make(types.URLsMap)
+	for _, memb := range membs {
+		n := memb.Name
+		if n == "" {
+			n = memb.ID.String()
+		}
+		urls, err := types.NewURLs(memb.PeerURLs)
+		if err != nil {
+			continue
+		}
+		m[n] = urls
+	}
+	if urls, err := types.NewURLs(peerURLs); err == nil {
+		m[name] = urls
+	}
+	return m.String()
+}
@@ -0,0 +1,93 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdmain
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.etcd.io/etcd/embed"
+	"go.etcd.io/etcd/etcdserver/api/membership"
+	"go.etcd.io/etcd/pkg/types"
+)
+
+func TestStandbyInitialCluster(t *testing.T) {
+	membs := []*membership.Member{
+		{ID: 1, RaftAttributes: membership.RaftAttributes{PeerURLs: []string{"http://10.0.0.1:2380"}}, Attributes: membership.Attributes{Name: "infra1"}},
+		// added but not yet started
+		{ID: 0x2a, RaftAttributes: membership.RaftAttributes{PeerURLs: []string{"http://10.0.0.2:2380"}}},
+	}
+	w := "2a=http://10.0.0.2:2380,infra1=http://10.0.0.1:2380,standby=http://10.0.0.3:2380,standby=http://10.0.0.3:2381"
+	g := standbyInitialCluster(membs, "standby", []string{"http://10.0.0.3:2380", "http://10.0.0.3:2381"})
+	if g != w {
+		t.Errorf("initial cluster = %s, want %s", g, w)
+	}
+	if _, err := types.NewURLsMap(g); err != nil {
+		t.Errorf("unexpected error parsing %s: %v", g, err)
+	}
+}
+
+// TestStandbyServePromote ensures the promotion of a proxy adds the member
+// with the credentials of the request, and is refused while in progress.
+func TestStandbyServePromote(t *testing.T) {
+	var auth string
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	mux.HandleFunc("/members", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Etcd-Cluster-ID", "1")
+		json.NewEncoder(w).Encode([]*membership.Member{
+			{ID: 1, RaftAttributes: membership.RaftAttributes{PeerURLs: []string{srv.URL}}, Attributes: membership.Attributes{Name: "infra1", ClientURLs: []string{srv.URL}}},
+		})
+	})
+	mux.HandleFunc("/v2/members", func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+
+	cfg := newConfig()
+	cfg.ec = *embed.NewConfig()
+	sb := &standby{
+		cfg:      cfg,
+		pt:       &http.Transport{},
+		tr:       http.DefaultTransport,
+		peerURLs: func() []string { return []string{srv.URL} },
+	}
+	promote := func(method string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, standbyPromotePath, nil)
+		req.SetBasicAuth("root", "pass")
+		rw := httptest.NewRecorder()
+		sb.servePromote(rw, req)
+		return rw
+	}
+
+	if rw := promote("GET"); rw.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET code = %d, want %d", rw.Code, http.StatusMethodNotAllowed)
+	}
+	if rw := promote("POST"); rw.Code != http.StatusBadGateway {
+		t.Errorf("refused promotion code = %d, want %d", rw.Code, http.StatusBadGateway)
+	}
+	// root:pass
+	if wauth := "Basic cm9vdDpwYXNz"; auth != wauth {
+		t.Errorf("authorization = %q, want %q", auth, wauth)
+	}
+
+	sb.promoting = true
+	if rw := promote("POST"); rw.Code != http.StatusConflict {
+		t.Errorf("concurrent promotion code = %d, want %d", rw.Code, http.StatusConflict)
+	}
+}
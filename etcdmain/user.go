@@ -0,0 +1,126 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdmain
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+
+	"go.etcd.io/etcd/embed"
+	"go.etcd.io/etcd/pkg/fileutil"
+	"go.etcd.io/etcd/pkg/osutil"
+	"go.etcd.io/etcd/pkg/transport"
+
+	"go.uber.org/zap"
+)
+
+// execAsUserGroup re-executes etcd as the user and group given by --user
+// and --group, unless it already runs as them, and exits with the exit
+// status of the new process. A Go process cannot switch all of its own
+// threads to another user, so the switch is done on exec. The TCP
+// listeners are bound first and passed to the new process, so that
+// privileged ports can be used, and the data and WAL directories are
+// handed to the user and group.
+func execAsUserGroup(ec *embed.Config) error {
+	uid, gid, err := osutil.LookupUserGroup(ec.User, ec.Group)
+	if err != nil {
+		return err
+	}
+	if os.Geteuid() == uid && os.Getegid() == gid {
+		return nil
+	}
+
+	dirs := []string{ec.Dir}
+	if ec.WalDir != "" {
+		dirs = append(dirs, ec.WalDir)
+	}
+	for _, dir := range dirs {
+		if err = fileutil.TouchDirAll(dir); err != nil {
+			return err
+		}
+		if err = osutil.ChownAll(dir, uid, gid); err != nil {
+			return fmt.Errorf("cannot change owner of %q (%v)", dir, err)
+		}
+	}
+
+	ls, err := listenTCPURLs(ec.LPUrls, ec.LCUrls, ec.ListenAdminUrls, ec.ListenMetricsUrls)
+	defer func() {
+		for _, l := range ls {
+			l.Close()
+		}
+	}()
+	if err != nil {
+		return err
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err = transport.PassListeners(cmd, ls); err != nil {
+		return err
+	}
+
+	if lg := ec.GetLogger(); lg != nil {
+		lg.Info(
+			"re-executing as user and group",
+			zap.String("user", ec.User),
+			zap.String("group", ec.Group),
+			zap.Int("uid", uid),
+			zap.Int("gid", gid),
+			zap.Int("listeners", len(ls)),
+		)
+	} else {
+		plog.Infof("re-executing as uid %d, gid %d with %d listeners", uid, gid, len(ls))
+	}
+	err = osutil.RunAsUserGroup(cmd, uid, gid)
+	if eerr, ok := err.(*exec.ExitError); ok {
+		code := 1
+		if st, ok := eerr.Sys().(interface{ ExitStatus() int }); ok && st.ExitStatus() > 0 {
+			code = st.ExitStatus()
+		}
+		os.Exit(code)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot run as uid %d, gid %d (%v)", uid, gid, err)
+	}
+	os.Exit(0)
+	return nil
+}
+
+// listenTCPURLs binds every distinct host of the http and https URLs.
+// It returns the listeners bound so far along with any error.
+func listenTCPURLs(urlss ...[]url.URL) (ls []net.Listener, err error) {
+	bound := make(map[string]bool)
+	for _, urls := range urlss {
+		for _, u := range urls {
+			if (u.Scheme != "http" && u.Scheme != "https") || bound[u.Host] {
+				continue
+			}
+			l, err := transport.Listen("tcp", u.Host)
+			if err != nil {
+				return ls, err
+			}
+			ls = append(ls, l)
+			bound[u.Host] = true
+		}
+	}
+	return ls, nil
+}
@@ -0,0 +1,243 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdmain
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"go.etcd.io/etcd/etcdserver/api/membership"
+	"go.etcd.io/etcd/etcdserver/api/snap"
+	pb "go.etcd.io/etcd/etcdserver/etcdserverpb"
+	"go.etcd.io/etcd/pkg/fileutil"
+	"go.etcd.io/etcd/pkg/transport"
+	"go.etcd.io/etcd/pkg/types"
+	"go.etcd.io/etcd/wal"
+	"go.etcd.io/etcd/wal/walpb"
+
+	"go.uber.org/zap"
+)
+
+// validatePeerDialTimeout is the time given to initial cluster members
+// to accept a connection when checking their reachability.
+const validatePeerDialTimeout = time.Second
+
+// validation is the outcome of one check of a validate-only run.
+type validation struct {
+	check string
+	err   error
+	// warn is true if err does not prevent etcd from starting.
+	warn bool
+}
+
+// validateConfig checks, without starting etcd nor changing any file,
+// that the parsed configuration can be used to start a member.
+func validateConfig(cfg *config) []validation {
+	vs := []validation{{check: "configuration"}}
+	vs = append(vs, validateDataDir(cfg)...)
+	vs = append(vs, validateTLS("client TLS", cfg.ec.ClientTLSInfo)...)
+	vs = append(vs, validateTLS("peer TLS", cfg.ec.PeerTLSInfo)...)
+	if cfg.ec.PeerSharedSecretFile != "" {
+		_, err := cfg.ec.PeerSharedSecret()
+		vs = append(vs, validation{check: "peer shared secret", err: err})
+	}
+	if !cfg.isProxy() {
+		for _, u := range cfg.ec.LPUrls {
+			vs = append(vs, validateListen("listen peer URL "+u.String(), u))
+		}
+	}
+	for _, u := range cfg.ec.LCUrls {
+		vs = append(vs, validateListen("listen client URL "+u.String(), u))
+	}
+	for _, u := range cfg.ec.ListenMetricsUrls {
+		vs = append(vs, validateListen("listen metrics URL "+u.String(), u))
+	}
+	for _, u := range cfg.ec.ListenAdminUrls {
+		vs = append(vs, validateListen("listen admin URL "+u.String(), u))
+	}
+	vs = append(vs, validatePeers(cfg)...)
+	return vs
+}
+
+// printValidations writes one line per check to w, and returns false if
+// any check failed.
+func printValidations(w io.Writer, vs []validation) bool {
+	ok := true
+	for _, v := range vs {
+		switch {
+		case v.err == nil:
+			fmt.Fprintf(w, "ok\t%s\n", v.check)
+		case v.warn:
+			fmt.Fprintf(w, "warning\t%s: %v\n", v.check, v.err)
+		default:
+			fmt.Fprintf(w, "FAIL\t%s: %v\n", v.check, v.err)
+			ok = false
+		}
+	}
+	return ok
+}
+
+func validateDataDir(cfg *config) []validation {
+	dir := cfg.ec.Dir
+	check := "data dir " + dir
+	fi, err := os.Stat(dir)
+	switch {
+	case os.IsNotExist(err):
+		// the data dir is created on start
+		parent := filepath.Dir(filepath.Clean(dir))
+		if err = fileutil.IsDirWriteable(parent); err != nil {
+			err = fmt.Errorf("cannot be created in %s (%v)", parent, err)
+		}
+		return []validation{{check: check, err: err}}
+	case err != nil:
+		return []validation{{check: check, err: err}}
+	case !fi.IsDir():
+		return []validation{{check: check, err: fmt.Errorf("not a directory")}}
+	}
+	if _, err = fileutil.ReadDir(dir); err != nil {
+		return []validation{{check: check, err: err}}
+	}
+	if err = fileutil.IsDirWriteable(dir); err != nil {
+		return []validation{{check: check, err: err}}
+	}
+	vs := []validation{{check: check}}
+
+	walDir := filepath.Join(dir, "member", "wal")
+	if cfg.ec.WalDir != "" {
+		walDir = cfg.ec.WalDir
+	}
+	if !cfg.isProxy() && wal.Exist(walDir) {
+		vs = append(vs, validation{check: "data dir cluster", err: validateDataDirCluster(cfg, walDir)})
+	}
+	return vs
+}
+
+// validateDataDirCluster ensures a data dir bootstrapped for a new cluster
+// belongs to the cluster described by the configuration. The ID of a
+// cluster being joined is only known to its members, so it is not checked.
+func validateDataDirCluster(cfg *config, walDir string) error {
+	lg := cfg.ec.GetLogger()
+	if lg == nil {
+		lg = zap.NewNop()
+	}
+	codec, err := cfg.ec.SnapshotCodec()
+	if err != nil {
+		return err
+	}
+	var walsnap walpb.Snapshot
+	ss := snap.NewWithCodec(lg, filepath.Join(cfg.ec.Dir, "member", "snap"), codec)
+	snapshot, err := ss.Load()
+	switch err {
+	case nil:
+		walsnap.Index, walsnap.Term = snapshot.Metadata.Index, snapshot.Metadata.Term
+	case snap.ErrNoSnapshot:
+	default:
+		return fmt.Errorf("cannot load snapshot (%v)", err)
+	}
+	w, err := wal.OpenForRead(lg, walDir, walsnap)
+	if err != nil {
+		return fmt.Errorf("cannot open WAL (%v)", err)
+	}
+	wmetadata, _, _, err := w.ReadAll()
+	w.Close()
+	if err != nil {
+		return fmt.Errorf("cannot read WAL (%v)", err)
+	}
+	var metadata pb.Metadata
+	if err = metadata.Unmarshal(wmetadata); err != nil {
+		return fmt.Errorf("cannot decode WAL metadata (%v)", err)
+	}
+
+	if !cfg.ec.IsNewCluster() || cfg.ec.Durl != "" || cfg.ec.DNSCluster != "" {
+		return nil
+	}
+	urlsmap, token, err := cfg.ec.PeerURLsMapAndToken("etcd")
+	if err != nil {
+		return err
+	}
+	cl, err := membership.NewClusterFromURLsMap(lg, token, urlsmap)
+	if err != nil {
+		return err
+	}
+	if cid := types.ID(metadata.ClusterID); cid != cl.ID() {
+		return fmt.Errorf("belongs to cluster %s, but the initial cluster and token describe cluster %s", cid, cl.ID())
+	}
+	return nil
+}
+
+func validateTLS(check string, info transport.TLSInfo) []validation {
+	if info.Empty() {
+		return nil
+	}
+	_, err := info.ServerConfig()
+	if err == nil {
+		_, err = info.ClientConfig()
+	}
+	return []validation{{check: check, err: err}}
+}
+
+// validateListen checks the address of u can be bound.
+func validateListen(check string, u url.URL) validation {
+	if u.Scheme == "unix" || u.Scheme == "unixs" {
+		return validation{check: check}
+	}
+	l, err := net.Listen("tcp", u.Host)
+	if err != nil {
+		return validation{check: check, err: err}
+	}
+	l.Close()
+	return validation{check: check}
+}
+
+// validatePeers reports the initial cluster members that cannot be
+// reached. Members of a new cluster may not be started yet, so it only
+// warns.
+func validatePeers(cfg *config) []validation {
+	if cfg.isProxy() || cfg.ec.Durl != "" || cfg.ec.DNSCluster != "" {
+		return nil
+	}
+	urlsmap, err := types.NewURLsMap(cfg.ec.InitialCluster)
+	if err != nil {
+		return nil
+	}
+	names := make([]string, 0, len(urlsmap))
+	for name := range urlsmap {
+		if name != cfg.ec.Name {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var vs []validation
+	for _, name := range names {
+		for _, u := range urlsmap[name] {
+			check := fmt.Sprintf("peer URL %s of member %s", u.String(), name)
+			conn, err := net.DialTimeout("tcp", u.Host, validatePeerDialTimeout)
+			if err != nil {
+				vs = append(vs, validation{check: check, err: fmt.Errorf("unreachable (%v)", err), warn: true})
+				continue
+			}
+			conn.Close()
+			vs = append(vs, validation{check: check})
+		}
+	}
+	return vs
+}
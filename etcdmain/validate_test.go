@@ -0,0 +1,167 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdmain
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.etcd.io/etcd/etcdserver/api/membership"
+	"go.etcd.io/etcd/etcdserver/api/snap"
+	pb "go.etcd.io/etcd/etcdserver/etcdserverpb"
+	"go.etcd.io/etcd/pkg/pbutil"
+	"go.etcd.io/etcd/raft/raftpb"
+	"go.etcd.io/etcd/wal"
+	"go.etcd.io/etcd/wal/walpb"
+
+	"go.uber.org/zap"
+)
+
+func TestValidateDataDir(t *testing.T) {
+	tdir, err := ioutil.TempDir(os.TempDir(), "validate-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tdir)
+	file := filepath.Join(tdir, "file")
+	if err = ioutil.WriteFile(file, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		dir  string
+		werr bool
+	}{
+		{tdir, false},
+		{filepath.Join(tdir, "new"), false},
+		{file, true},
+		{filepath.Join(tdir, "missing", "new"), true},
+	}
+	for i, tt := range tests {
+		cfg := newConfig()
+		cfg.ec.Dir = tt.dir
+		vs := validateDataDir(cfg)
+		if len(vs) != 1 {
+			t.Fatalf("#%d: validations = %+v, want 1", i, vs)
+		}
+		if (vs[0].err != nil) != tt.werr {
+			t.Errorf("#%d: err = %v, want error %v", i, vs[0].err, tt.werr)
+		}
+	}
+}
+
+// TestValidateDataDirEncryptedSnapshot ensures the data dir cluster is
+// validated from the snap files encrypted with the configured keys.
+func TestValidateDataDirEncryptedSnapshot(t *testing.T) {
+	tdir, err := ioutil.TempDir(os.TempDir(), "validate-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tdir)
+	keyFile := filepath.Join(tdir, "key")
+	if err = ioutil.WriteFile(keyFile, bytes.Repeat([]byte{1}, 32), 0600); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := snap.NewFileKeyProvider([]string{keyFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := newConfig()
+	cfg.ec.Dir = filepath.Join(tdir, "data")
+	urlsmap, token, err := cfg.ec.PeerURLsMapAndToken("etcd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cl, err := membership.NewClusterFromURLsMap(zap.NewExample(), token, urlsmap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapDir := filepath.Join(cfg.ec.Dir, "member", "snap")
+	if err = os.MkdirAll(snapDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	ss := snap.NewWithCodec(zap.NewExample(), snapDir, snap.Codec{Keys: keys})
+	if err = ss.SaveSnap(raftpb.Snapshot{Data: []byte("v2"), Metadata: raftpb.SnapshotMetadata{Index: 1, Term: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	walDir := filepath.Join(cfg.ec.Dir, "member", "wal")
+	w, err := wal.Create(zap.NewExample(), walDir, pbutil.MustMarshal(&pb.Metadata{NodeID: 1, ClusterID: uint64(cl.ID())}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = w.SaveSnapshot(walpb.Snapshot{Index: 1, Term: 1}); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	if err = validateDataDirCluster(cfg, walDir); err == nil {
+		t.Errorf("validated an encrypted snapshot without its key")
+	}
+	cfg.ec.ExperimentalSnapshotEncryptionKeyFiles = []string{keyFile}
+	if err = validateDataDirCluster(cfg, walDir); err != nil {
+		t.Errorf("err = %v, want nil with the snapshot key", err)
+	}
+}
+
+func TestValidateListen(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	if v := validateListen("in use", url.URL{Scheme: "http", Host: l.Addr().String()}); v.err == nil {
+		t.Errorf("expected error listening on %s", l.Addr())
+	}
+	if v := validateListen("free", url.URL{Scheme: "http", Host: "127.0.0.1:0"}); v.err != nil {
+		t.Errorf("unexpected error %v", v.err)
+	}
+}
+
+func TestPrintValidations(t *testing.T) {
+	tests := []struct {
+		vs []validation
+
+		wout string
+		wok  bool
+	}{
+		{
+			[]validation{{check: "a"}, {check: "b", err: errors.New("unreachable"), warn: true}},
+			"ok\ta\nwarning\tb: unreachable\n",
+			true,
+		},
+		{
+			[]validation{{check: "a", err: errors.New("bad")}, {check: "b"}},
+			"FAIL\ta: bad\nok\tb\n",
+			false,
+		},
+	}
+	for i, tt := range tests {
+		var buf bytes.Buffer
+		if ok := printValidations(&buf, tt.vs); ok != tt.wok {
+			t.Errorf("#%d: ok = %v, want %v", i, ok, tt.wok)
+		}
+		if buf.String() != tt.wout {
+			t.Errorf("#%d: output = %q, want %q", i, buf.String(), tt.wout)
+		}
+	}
+}
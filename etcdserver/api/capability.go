@@ -29,6 +29,19 @@ type Capability string
 const (
 	AuthCapability  Capability = "auth"
 	V3rpcCapability Capability = "v3rpc"
+	// V2WriteOptionsCapability covers the v2 write options carried by
+	// the request fields that older members ignore when applying the
+	// writes, which would make them apply the writes differently. It is
+	// enabled from the cluster version of the members of this tree, 3.3.
+	V2WriteOptionsCapability Capability = "v2writeoptions"
+	// MemberMetadataCapability covers the member metadata, which is
+	// replicated under a member key that older members fail to recover
+	// from their snapshots. It is enabled from 3.3 like the above.
+	MemberMetadataCapability Capability = "membermetadata"
+	// V2ExpiredKeysCapability covers the expired keys listed by the SYNC
+	// requests, which older members ignore, expiring the keys by the time
+	// of the requests instead. It is enabled from 3.3 like the above.
+	V2ExpiredKeysCapability Capability = "v2expiredkeys"
 )
 
 var (
@@ -39,7 +52,8 @@ var (
 		"3.0.0": {AuthCapability: true, V3rpcCapability: true},
 		"3.1.0": {AuthCapability: true, V3rpcCapability: true},
 		"3.2.0": {AuthCapability: true, V3rpcCapability: true},
-		"3.3.0": {AuthCapability: true, V3rpcCapability: true},
+		"3.3.0": {AuthCapability: true, V3rpcCapability: true, V2WriteOptionsCapability: true, MemberMetadataCapability: true, V2ExpiredKeysCapability: true},
+		"3.4.0": {AuthCapability: true, V3rpcCapability: true, V2WriteOptionsCapability: true, MemberMetadataCapability: true, V2ExpiredKeysCapability: true},
 	}
 
 	enableMapMu sync.RWMutex
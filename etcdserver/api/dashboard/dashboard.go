@@ -0,0 +1,241 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package dashboard serves a small web UI to browse the v2 keyspace,
+// inspect the members and statistics of the cluster, and watch keys.
+// The page only uses the v2 HTTP API of the member serving it, so every
+// request is subject to the usual authentication and authorization.
+package dashboard
+
+import (
+	"net/http"
+	"strings"
+)
+
+// HTTPPrefix is the path the dashboard is served under.
+const HTTPPrefix = "/dashboard/"
+
+// NewHandler returns a handler serving the dashboard under HTTPPrefix.
+func NewHandler() http.Handler {
+	return http.HandlerFunc(serveIndex)
+}
+
+func serveIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != HTTPPrefix {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != "GET" && r.Method != "HEAD" {
+		w.Header().Set("Allow", "GET,HEAD")
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	h := w.Header()
+	h.Set("Content-Type", "text/html; charset=utf-8")
+	h.Set("X-Content-Type-Options", "nosniff")
+	h.Set("X-Frame-Options", "DENY")
+	h.Set("Content-Security-Policy", "default-src 'self'; script-src 'self' 'unsafe-inline'; style-src 'unsafe-inline'")
+	if r.Method == "HEAD" {
+		return
+	}
+	w.Write([]byte(strings.TrimSpace(indexHTML)))
+}
+
+// indexHTML is the whole dashboard. Values read from the cluster are
+// only ever inserted as text, never as markup.
+const indexHTML = `
+<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>etcd dashboard</title>
+<style>
+body { font-family: sans-serif; margin: 0 2em; color: #333; }
+nav a { margin-right: 1em; cursor: pointer; color: #1a6fb0; }
+nav a.active { font-weight: bold; color: #333; }
+table { border-collapse: collapse; margin-top: 1em; }
+td, th { border-bottom: 1px solid #ddd; padding: 0.3em 0.8em; text-align: left; vertical-align: top; }
+pre { background: #f6f6f6; padding: 0.5em; white-space: pre-wrap; word-break: break-all; }
+.error { color: #b00; }
+.dir { cursor: pointer; color: #1a6fb0; }
+</style>
+</head>
+<body>
+<h1>etcd</h1>
+<nav>
+<a id="nav-keys" data-page="keys">Keys</a>
+<a id="nav-members" data-page="members">Members</a>
+<a id="nav-stats" data-page="stats">Stats</a>
+<a id="nav-watch" data-page="watch">Watch</a>
+</nav>
+<p id="error" class="error"></p>
+
+<section id="page-keys">
+<p>Directory: <input id="keys-dir" value="/" size="60"> <button id="keys-go">List</button></p>
+<table><thead><tr><th>Key</th><th>Value</th><th>Modified index</th><th>TTL</th></tr></thead><tbody id="keys-body"></tbody></table>
+</section>
+
+<section id="page-members">
+<table><thead><tr><th>ID</th><th>Name</th><th>Peer URLs</th><th>Client URLs</th></tr></thead><tbody id="members-body"></tbody></table>
+</section>
+
+<section id="page-stats">
+<h3>Self</h3><pre id="stats-self"></pre>
+<h3>Store</h3><pre id="stats-store"></pre>
+</section>
+
+<section id="page-watch">
+<p>Key: <input id="watch-key" value="/" size="60"> <label><input id="watch-recursive" type="checkbox" checked> recursive</label>
+<button id="watch-start">Watch</button> <button id="watch-stop">Stop</button></p>
+<table><thead><tr><th>Index</th><th>Action</th><th>Key</th><th>Value</th></tr></thead><tbody id="watch-body"></tbody></table>
+</section>
+
+<script>
+(function() {
+  "use strict";
+
+  var pages = ["keys", "members", "stats", "watch"];
+  var watchGen = 0;
+
+  function $(id) { return document.getElementById(id); }
+
+  function showError(msg) { $("error").textContent = msg || ""; }
+
+  function get(url) {
+    return fetch(url, {credentials: "same-origin"}).then(function(resp) {
+      return resp.json().then(function(body) {
+        if (!resp.ok) {
+          throw new Error(body.message || body.cause || resp.statusText);
+        }
+        return body;
+      });
+    });
+  }
+
+  function keyURL(key) {
+    return "/v2/keys" + key.split("/").map(encodeURIComponent).join("/");
+  }
+
+  function row(tbody, cells) {
+    var tr = document.createElement("tr");
+    cells.forEach(function(c) {
+      var td = document.createElement("td");
+      if (c instanceof Node) {
+        td.appendChild(c);
+      } else {
+        td.textContent = c === undefined ? "" : String(c);
+      }
+      tr.appendChild(td);
+    });
+    tbody.appendChild(tr);
+  }
+
+  function clear(el) { while (el.firstChild) { el.removeChild(el.firstChild); } }
+
+  function listKeys() {
+    var dir = $("keys-dir").value || "/";
+    showError();
+    get(keyURL(dir) + "?sorted=true").then(function(body) {
+      var tbody = $("keys-body");
+      clear(tbody);
+      var nodes = body.node.dir ? (body.node.nodes || []) : [body.node];
+      nodes.forEach(function(n) {
+        var key = n.key;
+        if (n.dir) {
+          key = document.createElement("span");
+          key.className = "dir";
+          key.textContent = n.key + "/";
+          key.addEventListener("click", function() {
+            $("keys-dir").value = n.key;
+            listKeys();
+          });
+        }
+        row(tbody, [key, n.dir ? "" : n.value, n.modifiedIndex, n.ttl]);
+      });
+    }).catch(function(err) { showError(dir + ": " + err.message); });
+  }
+
+  function listMembers() {
+    showError();
+    get("/v2/members").then(function(body) {
+      var tbody = $("members-body");
+      clear(tbody);
+      (body.members || []).forEach(function(m) {
+        row(tbody, [m.id, m.name, (m.peerURLs || []).join(", "), (m.clientURLs || []).join(", ")]);
+      });
+    }).catch(function(err) { showError("members: " + err.message); });
+  }
+
+  function showStats() {
+    showError();
+    [["self", "/v2/stats/self"], ["store", "/v2/stats/store"]].forEach(function(s) {
+      get(s[1]).then(function(body) {
+        $("stats-" + s[0]).textContent = JSON.stringify(body, null, 2);
+      }).catch(function(err) { showError(s[0] + " stats: " + err.message); });
+    });
+  }
+
+  function watch(gen, key, recursive, waitIndex) {
+    var url = keyURL(key) + "?wait=true" + (recursive ? "&recursive=true" : "");
+    if (waitIndex) {
+      url += "&waitIndex=" + waitIndex;
+    }
+    get(url).then(function(body) {
+      if (gen !== watchGen) {
+        return;
+      }
+      var n = body.node || {};
+      var tbody = $("watch-body");
+      var tr = tbody.firstChild;
+      row(tbody, [n.modifiedIndex, body.action, n.key, n.value]);
+      if (tr) {
+        tbody.insertBefore(tbody.lastChild, tr);
+      }
+      watch(gen, key, recursive, n.modifiedIndex + 1);
+    }).catch(function(err) {
+      if (gen === watchGen) {
+        showError("watch " + key + ": " + err.message);
+      }
+    });
+  }
+
+  function startWatch() {
+    showError();
+    watchGen++;
+    clear($("watch-body"));
+    watch(watchGen, $("watch-key").value || "/", $("watch-recursive").checked, 0);
+  }
+
+  function show(page) {
+    pages.forEach(function(p) {
+      $("page-" + p).style.display = p === page ? "" : "none";
+      $("nav-" + p).className = p === page ? "active" : "";
+    });
+    if (page === "keys") { listKeys(); }
+    if (page === "members") { listMembers(); }
+    if (page === "stats") { showStats(); }
+  }
+
+  pages.forEach(function(p) {
+    $("nav-" + p).addEventListener("click", function() { show(p); });
+  });
+  $("keys-go").addEventListener("click", listKeys);
+  $("watch-start").addEventListener("click", startWatch);
+  $("watch-stop").addEventListener("click", function() { watchGen++; });
+  show("keys");
+})();
+</script>
+</body>
+</html>
+`
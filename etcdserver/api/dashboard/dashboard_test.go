@@ -0,0 +1,57 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dashboard
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServeIndex(t *testing.T) {
+	tests := []struct {
+		method string
+		path   string
+
+		wcode int
+		wbody bool
+	}{
+		{"GET", HTTPPrefix, http.StatusOK, true},
+		{"HEAD", HTTPPrefix, http.StatusOK, false},
+		{"POST", HTTPPrefix, http.StatusMethodNotAllowed, false},
+		{"GET", HTTPPrefix + "foo", http.StatusNotFound, false},
+	}
+	for i, tt := range tests {
+		req, err := http.NewRequest(tt.method, tt.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rw := httptest.NewRecorder()
+		NewHandler().ServeHTTP(rw, req)
+
+		if rw.Code != tt.wcode {
+			t.Errorf("#%d: code = %d, want %d", i, rw.Code, tt.wcode)
+		}
+		if g := strings.HasPrefix(rw.Body.String(), "<!DOCTYPE html>"); g != tt.wbody {
+			t.Errorf("#%d: has page = %v, want %v", i, g, tt.wbody)
+		}
+		if tt.wcode == http.StatusOK {
+			if ct := rw.Header().Get("Content-Type"); ct != "text/html; charset=utf-8" {
+				t.Errorf("#%d: content type = %q, want html", i, ct)
+			}
+		}
+	}
+}
@@ -89,6 +89,11 @@ type Health struct {
 	Health string `json:"health"`
 }
 
+// diskDegradedReporter is implemented by servers that track disk latency.
+type diskDegradedReporter interface {
+	DiskDegraded() bool
+}
+
 // TODO: server NOSPACE, etcdserver.ErrNoLeader in health API
 
 func checkHealth(srv etcdserver.ServerV2) Health {
@@ -105,6 +110,12 @@ func checkHealth(srv etcdserver.ServerV2) Health {
 		}
 	}
 
+	if h.Health == "true" {
+		if dd, ok := srv.(diskDegradedReporter); ok && dd.DiskDegraded() {
+			h.Health = "false"
+		}
+	}
+
 	if h.Health == "true" {
 		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
 		_, err := srv.Do(ctx, etcdserverpb.Request{Method: "QGET"})
@@ -0,0 +1,235 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdhttp
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.etcd.io/etcd/etcdserver"
+)
+
+// Middleware wraps the handler of the client requests, e.g. to check,
+// log or rewrite them before they are routed. It may answer a request
+// itself instead of passing it to next.
+type Middleware func(next http.Handler) http.Handler
+
+// Chain returns h wrapped in the given middlewares; the first one sees
+// the requests first.
+func Chain(h http.Handler, mws ...Middleware) http.Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
+// CORS returns the middleware writing the CORS headers of the requests
+// from the origins allowed by ac, and answering the preflight requests.
+func CORS(ac *etcdserver.AccessController) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if ac.OriginAllowed("*") {
+				addCORSHeader(w, "*", ac)
+			} else if origin := r.Header.Get("Origin"); ac.OriginAllowed(origin) {
+				addCORSHeader(w, origin, ac)
+			}
+
+			if r.Method == "OPTIONS" {
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ClientIdentityHeaders returns the middleware writing the identity and
+// the remote address of the client in the headers of the responses, so
+// that a client or a proxy in front of the member can tell how the
+// member attributes its requests. The headers are written along with the
+// status of the response, once the handler authenticated the client.
+func ClientIdentityHeaders() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r = WithClientIdentity(r)
+			next.ServeHTTP(&identityHeaderWriter{ResponseWriter: w, r: r}, r)
+		})
+	}
+}
+
+// identityHeaderWriter writes the identity headers of the client before
+// the status of the response.
+type identityHeaderWriter struct {
+	http.ResponseWriter
+	r       *http.Request
+	written bool
+}
+
+func (w *identityHeaderWriter) WriteHeader(code int) {
+	if !w.written {
+		w.written = true
+		if id := ClientIdentity(w.r); id != "" {
+			w.Header().Set("X-Etcd-Client-Identity", id)
+		}
+		w.Header().Set("X-Etcd-Client-Remote", w.r.RemoteAddr)
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *identityHeaderWriter) Write(b []byte) (int, error) {
+	if !w.written {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *identityHeaderWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		if !w.written {
+			w.WriteHeader(http.StatusOK)
+		}
+		f.Flush()
+	}
+}
+
+func (w *identityHeaderWriter) CloseNotify() <-chan bool {
+	if cn, ok := w.ResponseWriter.(http.CloseNotifier); ok {
+		return cn.CloseNotify()
+	}
+	// never notified, like a response writer without the notifications
+	return make(chan bool)
+}
+
+type authenticatedUserKey struct{}
+
+// authenticatedUser is the user a request is authenticated as.
+type authenticatedUser struct {
+	mu   sync.Mutex
+	name string
+}
+
+// WithClientIdentity returns r able to record the user it is authenticated
+// as while being served, see SetAuthenticatedUser.
+func WithClientIdentity(r *http.Request) *http.Request {
+	if _, ok := r.Context().Value(authenticatedUserKey{}).(*authenticatedUser); ok {
+		return r
+	}
+	return r.WithContext(context.WithValue(r.Context(), authenticatedUserKey{}, &authenticatedUser{}))
+}
+
+// SetAuthenticatedUser records that r is authenticated as the user name,
+// once its credentials are checked. It does nothing if r is not able to
+// record it, see WithClientIdentity.
+func SetAuthenticatedUser(r *http.Request, name string) {
+	if u, ok := r.Context().Value(authenticatedUserKey{}).(*authenticatedUser); ok {
+		u.mu.Lock()
+		u.name = name
+		u.mu.Unlock()
+	}
+}
+
+// ClientIdentity returns the user r is authenticated as, see
+// SetAuthenticatedUser, or else the common name of the verified TLS
+// certificate of the client. The user name of the basic authentication is
+// only returned once the password is checked, since any client may send
+// any name. It returns an empty string for an anonymous client.
+func ClientIdentity(r *http.Request) string {
+	if u, ok := r.Context().Value(authenticatedUserKey{}).(*authenticatedUser); ok {
+		u.mu.Lock()
+		name := u.name
+		u.mu.Unlock()
+		if name != "" {
+			return name
+		}
+	}
+	if r.TLS != nil {
+		for _, chain := range r.TLS.VerifiedChains {
+			if len(chain) > 0 && chain[0].Subject.CommonName != "" {
+				return chain[0].Subject.CommonName
+			}
+		}
+	}
+	return ""
+}
+
+// backpressureSignaler is implemented by servers that signal when they
+// are overloaded.
+type backpressureSignaler interface {
+	// Backpressure returns the reason the server is overloaded, or an
+	// empty string if it is not.
+	Backpressure() string
+	// ShedClientRequest returns true if a client request must be refused.
+	ShedClientRequest() bool
+}
+
+// Backpressure returns the middleware writing the reason the member is
+// overloaded in the X-Etcd-Backpressure header of the responses, so that
+// the clients and proxies in front of the member can send their requests
+// elsewhere, and refusing the sheddable requests with 503 and a Retry-After
+// header meanwhile. The V2 keys requests are shed by their handler, so
+// that each request is counted once.
+func Backpressure(bs backpressureSignaler) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			reason := bs.Backpressure()
+			if reason == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Set("X-Etcd-Backpressure", reason)
+			if sheddablePath(r.URL.Path) && bs.ShedClientRequest() {
+				w.Header().Set("Retry-After", "1")
+				http.Error(w, "member is overloaded", http.StatusServiceUnavailable)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// sheddablePath returns true if the client HTTP requests to the given path
+// are refused by the middleware while the member is overloaded: the V3
+// gateway requests, but the membership, maintenance and lease keep-alive
+// ones, as the gRPC requests.
+func sheddablePath(p string) bool {
+	if !strings.HasPrefix(p, "/v3/") {
+		return false
+	}
+	return !strings.HasPrefix(p, "/v3/cluster/") &&
+		!strings.HasPrefix(p, "/v3/maintenance/") &&
+		!strings.HasPrefix(p, "/v3/lease/keepalive")
+}
+
+// addCORSHeader adds the correct cors headers given an origin
+func addCORSHeader(w http.ResponseWriter, origin string, ac *etcdserver.AccessController) {
+	w.Header().Add("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
+	w.Header().Add("Access-Control-Allow-Origin", origin)
+	w.Header().Add("Access-Control-Allow-Headers", "accept, content-type, authorization")
+	if origin != "*" {
+		// responses differ by origin
+		w.Header().Add("Vary", "Origin")
+	}
+	if len(ac.CORSExposeHeaders) > 0 {
+		w.Header().Add("Access-Control-Expose-Headers", strings.Join(ac.CORSExposeHeaders, ", "))
+	}
+	if ac.CORSMaxAge > 0 {
+		w.Header().Add("Access-Control-Max-Age", strconv.Itoa(int(ac.CORSMaxAge/time.Second)))
+	}
+}
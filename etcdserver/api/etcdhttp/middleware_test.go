@@ -0,0 +1,206 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdhttp
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.etcd.io/etcd/etcdserver"
+)
+
+func TestChain(t *testing.T) {
+	var order []string
+	mw := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { order = append(order, "handler") })
+	Chain(h, mw("a"), mw("b")).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if g := strings.Join(order, ","); g != "a,b,handler" {
+		t.Errorf("order = %q, want %q", g, "a,b,handler")
+	}
+}
+
+func TestCORS(t *testing.T) {
+	served := false
+	h := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { served = true }),
+		CORS(&etcdserver.AccessController{CORS: map[string]struct{}{"https://a.example.com": {}}}))
+
+	tests := []struct {
+		method, origin string
+
+		wallow  string
+		wserved bool
+	}{
+		{"GET", "https://a.example.com", "https://a.example.com", true},
+		{"GET", "https://b.example.com", "", true},
+		// preflight requests are answered by the middleware
+		{"OPTIONS", "https://a.example.com", "https://a.example.com", false},
+	}
+	for i, tt := range tests {
+		served = false
+		rw := httptest.NewRecorder()
+		r := httptest.NewRequest(tt.method, "/v2/keys", nil)
+		r.Header.Set("Origin", tt.origin)
+		h.ServeHTTP(rw, r)
+		if g := rw.Header().Get("Access-Control-Allow-Origin"); g != tt.wallow {
+			t.Errorf("#%d: allowed origin = %q, want %q", i, g, tt.wallow)
+		}
+		if served != tt.wserved {
+			t.Errorf("#%d: served = %v, want %v", i, served, tt.wserved)
+		}
+	}
+}
+
+func TestAddCORSHeader(t *testing.T) {
+	tests := []struct {
+		origin string
+		ac     *etcdserver.AccessController
+
+		wexpose, wmaxAge, wvary string
+	}{
+		{"*", &etcdserver.AccessController{}, "", "", ""},
+		{"https://a.example.com", &etcdserver.AccessController{}, "", "", "Origin"},
+		{
+			"*",
+			&etcdserver.AccessController{CORSExposeHeaders: []string{"X-Etcd-Index", "X-Raft-Term"}, CORSMaxAge: 10 * time.Minute},
+			"X-Etcd-Index, X-Raft-Term", "600", "",
+		},
+	}
+	for i, tt := range tests {
+		rw := httptest.NewRecorder()
+		addCORSHeader(rw, tt.origin, tt.ac)
+
+		if g := rw.Header().Get("Access-Control-Allow-Origin"); g != tt.origin {
+			t.Errorf("#%d: allowed origin = %q, want %q", i, g, tt.origin)
+		}
+		if g := rw.Header().Get("Access-Control-Expose-Headers"); g != tt.wexpose {
+			t.Errorf("#%d: exposed headers = %q, want %q", i, g, tt.wexpose)
+		}
+		if g := rw.Header().Get("Access-Control-Max-Age"); g != tt.wmaxAge {
+			t.Errorf("#%d: max age = %q, want %q", i, g, tt.wmaxAge)
+		}
+		if g := rw.Header().Get("Vary"); g != tt.wvary {
+			t.Errorf("#%d: vary = %q, want %q", i, g, tt.wvary)
+		}
+	}
+}
+
+func TestClientIdentityHeaders(t *testing.T) {
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "app-a"}}
+	h := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the handler authenticates the users whose password is "pass"
+		if user, pass, ok := r.BasicAuth(); ok && pass == "pass" {
+			SetAuthenticatedUser(r, user)
+		}
+		w.Write([]byte("ok"))
+	}), ClientIdentityHeaders())
+
+	tests := []struct {
+		user string
+		pass string
+		tls  *tls.ConnectionState
+
+		wid string
+	}{
+		{"", "", nil, ""},
+		{"root", "pass", nil, "root"},
+		// the users not authenticated are not reported
+		{"root", "guess", nil, ""},
+		{"", "", &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}, "app-a"},
+		// the authenticated user wins over the certificate
+		{"root", "pass", &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}, "root"},
+		{"root", "guess", &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}, "app-a"},
+	}
+	for i, tt := range tests {
+		rw := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/v2/keys", nil)
+		r.RemoteAddr = "10.0.0.1:4001"
+		r.TLS = tt.tls
+		if tt.user != "" {
+			r.SetBasicAuth(tt.user, tt.pass)
+		}
+		h.ServeHTTP(rw, r)
+		if g := rw.Header().Get("X-Etcd-Client-Identity"); g != tt.wid {
+			t.Errorf("#%d: identity = %q, want %q", i, g, tt.wid)
+		}
+		if g := rw.Header().Get("X-Etcd-Client-Remote"); g != "10.0.0.1:4001" {
+			t.Errorf("#%d: remote = %q, want %q", i, g, "10.0.0.1:4001")
+		}
+	}
+}
+
+type fakeBackpressureSignaler struct {
+	reason string
+	shed   int
+}
+
+func (s *fakeBackpressureSignaler) Backpressure() string { return s.reason }
+
+func (s *fakeBackpressureSignaler) ShedClientRequest() bool {
+	if s.reason == "" {
+		return false
+	}
+	s.shed++
+	return true
+}
+
+func TestBackpressure(t *testing.T) {
+	tests := []struct {
+		reason string
+		path   string
+
+		wcode   int
+		wheader string
+	}{
+		{"", "/v2/keys/foo", http.StatusOK, ""},
+		// shed by the keys handler
+		{"apply-backlog", "/v2/keys/foo", http.StatusOK, "apply-backlog"},
+		{"apply-backlog", "/v3/kv/put", http.StatusServiceUnavailable, "apply-backlog"},
+		{"apply-backlog", "/v3/lease/keepalive", http.StatusOK, "apply-backlog"},
+		{"apply-backlog", "/v3/maintenance/status", http.StatusOK, "apply-backlog"},
+		{"apply-backlog", "/health", http.StatusOK, "apply-backlog"},
+		{"apply-backlog", "/v2/members", http.StatusOK, "apply-backlog"},
+	}
+	for i, tt := range tests {
+		bs := &fakeBackpressureSignaler{reason: tt.reason}
+		h := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), Backpressure(bs))
+		rw := httptest.NewRecorder()
+		h.ServeHTTP(rw, httptest.NewRequest("POST", tt.path, nil))
+		if rw.Code != tt.wcode {
+			t.Errorf("#%d: code = %d, want %d", i, rw.Code, tt.wcode)
+		}
+		if g := rw.Header().Get("X-Etcd-Backpressure"); g != tt.wheader {
+			t.Errorf("#%d: backpressure = %q, want %q", i, g, tt.wheader)
+		}
+		if wshed := tt.wcode == http.StatusServiceUnavailable; (bs.shed == 1) != wshed {
+			t.Errorf("#%d: shed %d requests, want shed %v", i, bs.shed, wshed)
+		}
+		if tt.wcode == http.StatusServiceUnavailable && rw.Header().Get("Retry-After") != "1" {
+			t.Errorf("#%d: Retry-After = %q, want 1", i, rw.Header().Get("Retry-After"))
+		}
+	}
+}
@@ -15,27 +15,45 @@
 package etcdhttp
 
 import (
+	"context"
+	"encoding/binary"
 	"encoding/json"
+	"io"
 	"net/http"
+	"strings"
+	"time"
 
 	"go.etcd.io/etcd/etcdserver"
 	"go.etcd.io/etcd/etcdserver/api"
+	"go.etcd.io/etcd/etcdserver/api/membership"
 	"go.etcd.io/etcd/etcdserver/api/rafthttp"
+	"go.etcd.io/etcd/etcdserver/api/snap"
 	"go.etcd.io/etcd/lease/leasehttp"
+	"go.etcd.io/etcd/pkg/pbutil"
+	"go.etcd.io/etcd/pkg/types"
 
+	humanize "github.com/dustin/go-humanize"
 	"go.uber.org/zap"
 )
 
 const (
 	peerMembersPrefix = "/members"
+	peerSnapshotPath  = etcdserver.PeerSnapshotPath
+	peerJoinPath      = etcdserver.PeerJoinPath
+
+	// peerJoinTimeout bounds the time the conf change adding a joining
+	// member takes to be applied.
+	peerJoinTimeout = 10 * time.Second
 )
 
 // NewPeerHandler generates an http.Handler to handle etcd peer requests.
 func NewPeerHandler(lg *zap.Logger, s etcdserver.ServerPeer) http.Handler {
-	return newPeerHandler(lg, s.Cluster(), s.RaftHandler(), s.LeaseHandler())
+	ps, _ := s.(etcdserver.PeerSnapshotter)
+	pj, _ := s.(etcdserver.PeerJoiner)
+	return newPeerHandler(lg, s.Cluster(), s.RaftHandler(), s.LeaseHandler(), ps, pj)
 }
 
-func newPeerHandler(lg *zap.Logger, cluster api.Cluster, raftHandler http.Handler, leaseHandler http.Handler) http.Handler {
+func newPeerHandler(lg *zap.Logger, cluster api.Cluster, raftHandler http.Handler, leaseHandler http.Handler, ps etcdserver.PeerSnapshotter, pj etcdserver.PeerJoiner) http.Handler {
 	mh := &peerMembersHandler{
 		lg:      lg,
 		cluster: cluster,
@@ -50,10 +68,26 @@ func newPeerHandler(lg *zap.Logger, cluster api.Cluster, raftHandler http.Handle
 		mux.Handle(leasehttp.LeasePrefix, leaseHandler)
 		mux.Handle(leasehttp.LeaseInternalPrefix, leaseHandler)
 	}
+	if ps != nil {
+		mux.Handle(peerSnapshotPath, &peerSnapshotHandler{lg: lg, cluster: cluster, ps: ps})
+	}
+	if pj != nil {
+		mux.Handle(peerJoinPath, &peerJoinHandler{lg: lg, cluster: cluster, pj: pj})
+	}
 	mux.HandleFunc(versionPath, versionHandler(cluster, serveVersion))
 	return mux
 }
 
+// IsPeerPath returns true if the path p is served to the peers alone, so
+// that the peer requests can be told apart from the client ones.
+func IsPeerPath(p string) bool {
+	switch p {
+	case rafthttp.RaftPrefix, peerMembersPrefix, leasehttp.LeasePrefix, leasehttp.LeaseInternalPrefix, peerSnapshotPath, peerJoinPath:
+		return true
+	}
+	return strings.HasPrefix(p, rafthttp.RaftPrefix+"/")
+}
+
 type peerMembersHandler struct {
 	lg      *zap.Logger
 	cluster api.Cluster
@@ -79,3 +113,162 @@ func (h *peerMembersHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 }
+
+// peerSnapshotHandler streams the latest snapshot of the member to the
+// other members of the cluster, so that a new member can load it rather
+// than replaying the whole raft log. The body is in the format of the
+// snapshots sent on rafthttp.RaftSnapshotPrefix: the length and encoding
+// of a MsgSnap message, followed by the v3 backend database.
+//
+// A member joining the cluster fetches it to seed its data directory
+// before it is added. As the headers naming the sender can be set by
+// anyone reaching the peer listener, the snapshot is only served to the
+// peers that authenticated, with a peer TLS client certificate or with
+// the secret shared by the members.
+type peerSnapshotHandler struct {
+	lg      *zap.Logger
+	cluster api.Cluster
+	ps      etcdserver.PeerSnapshotter
+}
+
+func (h *peerSnapshotHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, "GET") {
+		return
+	}
+	w.Header().Set("X-Etcd-Cluster-ID", h.cluster.ID().String())
+
+	if r.Header.Get("X-Etcd-Cluster-ID") != h.cluster.ID().String() {
+		http.Error(w, "cluster ID mismatch", http.StatusPreconditionFailed)
+		return
+	}
+	if !peerCertAuthenticated(r) && !rafthttp.IsSignedPeerRequest(r) {
+		http.Error(w, "peer client certificate or shared secret required", http.StatusForbidden)
+		return
+	}
+	from, err := types.IDFromString(r.Header.Get("X-Server-From"))
+	if err != nil {
+		http.Error(w, "bad X-Server-From", http.StatusBadRequest)
+		return
+	}
+
+	m, err := h.ps.LatestSnapshot()
+	switch {
+	case err == snap.ErrNoSnapshot:
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case err != nil:
+		if h.lg != nil {
+			h.lg.Warn("failed to get latest snapshot", zap.Error(err))
+		} else {
+			plog.Warningf("failed to get latest snapshot (%v)", err)
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer m.ReadCloser.Close()
+
+	index := m.Snapshot.Metadata.Index
+	if h.lg != nil {
+		h.lg.Info(
+			"sending latest snapshot to peer",
+			zap.String("remote-peer-id", from.String()),
+			zap.Uint64("snapshot-index", index),
+			zap.Int64("bytes", m.TotalSize),
+			zap.String("size", humanize.Bytes(uint64(m.TotalSize))),
+		)
+	} else {
+		plog.Infof("sending latest snapshot [index: %d] to %s", index, from)
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	if err = binary.Write(w, binary.BigEndian, uint64(m.Message.Size())); err == nil {
+		if _, err = w.Write(pbutil.MustMarshal(&m.Message)); err == nil {
+			_, err = io.Copy(w, m.ReadCloser)
+		}
+	}
+	if err != nil {
+		if h.lg != nil {
+			h.lg.Warn(
+				"failed to send latest snapshot to peer",
+				zap.String("remote-peer-id", from.String()),
+				zap.Uint64("snapshot-index", index),
+				zap.Error(err),
+			)
+		} else {
+			plog.Warningf("failed to send latest snapshot [index: %d] to %s (%v)", index, from, err)
+		}
+	}
+}
+
+// peerJoinHandler adds the members joining the cluster with a data
+// directory seeded from peerSnapshotPath, on their own request. As the
+// joining member is not a member yet, it must authenticate with a peer TLS
+// client certificate.
+type peerJoinHandler struct {
+	lg      *zap.Logger
+	cluster api.Cluster
+	pj      etcdserver.PeerJoiner
+}
+
+func (h *peerJoinHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, "POST") {
+		return
+	}
+	w.Header().Set("X-Etcd-Cluster-ID", h.cluster.ID().String())
+
+	if r.Header.Get("X-Etcd-Cluster-ID") != h.cluster.ID().String() {
+		http.Error(w, "cluster ID mismatch", http.StatusPreconditionFailed)
+		return
+	}
+	if !peerCertAuthenticated(r) {
+		http.Error(w, "peer client certificate required", http.StatusForbidden)
+		return
+	}
+
+	var m membership.Member
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+		http.Error(w, "bad member: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if m.ID == 0 || len(m.PeerURLs) == 0 || m.IsLearner {
+		http.Error(w, "bad member: ID and peer URLs of a voting member required", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), peerJoinTimeout)
+	ms, err := h.pj.JoinMember(ctx, m)
+	cancel()
+	switch err {
+	case nil:
+	case membership.ErrIDExists, membership.ErrPeerURLexists:
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case etcdserver.ErrNotEnoughStartedMembers, etcdserver.ErrUnhealthy:
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	default:
+		if h.lg != nil {
+			h.lg.Warn("failed to add joining member", zap.String("member-id", m.ID.String()), zap.Error(err))
+		} else {
+			plog.Warningf("failed to add joining member %s (%v)", m.ID, err)
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(ms); err != nil {
+		if h.lg != nil {
+			h.lg.Warn("failed to encode membership members", zap.Error(err))
+		} else {
+			plog.Warningf("failed to encode members response (%v)", err)
+		}
+	}
+}
+
+// peerCertAuthenticated returns true if r was sent over TLS with a client
+// certificate verified by the peer listener.
+func peerCertAuthenticated(r *http.Request) bool {
+	return r.TLS != nil && len(r.TLS.VerifiedChains) > 0
+}
@@ -15,6 +15,11 @@
 package etcdhttp
 
 import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
@@ -26,12 +31,35 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/coreos/go-semver/semver"
+	"go.etcd.io/etcd/etcdserver"
 	"go.etcd.io/etcd/etcdserver/api/membership"
 	"go.etcd.io/etcd/etcdserver/api/rafthttp"
+	"go.etcd.io/etcd/etcdserver/api/snap"
+	"go.etcd.io/etcd/pkg/pbutil"
 	"go.etcd.io/etcd/pkg/testutil"
 	"go.etcd.io/etcd/pkg/types"
+	"go.etcd.io/etcd/raft/raftpb"
 )
 
+func TestIsPeerPath(t *testing.T) {
+	tests := map[string]bool{
+		"/raft":               true,
+		"/raft/stream/msgapp": true,
+		"/members":            true,
+		"/leases/internal":    true,
+		"/v2/admin/join":      true,
+		"/version":            false,
+		"/v2/members":         false,
+		"/v2/admin/alarms":    false,
+		"/raftx":              false,
+	}
+	for p, w := range tests {
+		if g := IsPeerPath(p); g != w {
+			t.Errorf("IsPeerPath(%q) = %t, want %t", p, g, w)
+		}
+	}
+}
+
 type fakeCluster struct {
 	id         uint64
 	clientURLs []string
@@ -57,7 +85,7 @@ func TestNewPeerHandlerOnRaftPrefix(t *testing.T) {
 	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte("test data"))
 	})
-	ph := newPeerHandler(zap.NewExample(), &fakeCluster{}, h, nil)
+	ph := newPeerHandler(zap.NewExample(), &fakeCluster{}, h, nil, nil, nil)
 	srv := httptest.NewServer(ph)
 	defer srv.Close()
 
@@ -156,3 +184,170 @@ func TestServeMembersGet(t *testing.T) {
 		}
 	}
 }
+
+type fakePeerSnapshotter struct {
+	m   raftpb.Message
+	db  []byte
+	err error
+}
+
+func (ps *fakePeerSnapshotter) LatestSnapshot() (*snap.Message, error) {
+	if ps.err != nil {
+		return nil, ps.err
+	}
+	return snap.NewMessage(ps.m, ioutil.NopCloser(bytes.NewReader(ps.db)), int64(len(ps.db))), nil
+}
+
+func TestServePeerSnapshot(t *testing.T) {
+	cluster := &fakeCluster{
+		id:      1,
+		members: map[uint64]*membership.Member{2: {ID: 2}},
+	}
+	m := raftpb.Message{
+		Type:     raftpb.MsgSnap,
+		From:     1,
+		Snapshot: raftpb.Snapshot{Metadata: raftpb.SnapshotMetadata{Index: 10, Term: 2}, Data: []byte("v2")},
+	}
+	ps := &fakePeerSnapshotter{m: m, db: []byte("database")}
+	ph := newPeerHandler(zap.NewExample(), cluster, http.NotFoundHandler(), nil, ps, nil)
+	secret := []byte("secret")
+	sph := rafthttp.NewSharedSecretHandler(ph, secret)
+
+	wbody := new(bytes.Buffer)
+	binary.Write(wbody, binary.BigEndian, uint64(m.Size()))
+	wbody.Write(pbutil.MustMarshal(&m))
+	wbody.WriteString("database")
+
+	const (
+		noAuth = iota
+		certAuth
+		secretAuth
+		badSecretAuth
+	)
+	tests := []struct {
+		method string
+		cid    string
+		from   string
+		auth   int
+		err    error
+
+		wcode int
+		wbody string
+	}{
+		{"GET", "1", "2", certAuth, nil, http.StatusOK, wbody.String()},
+		{"GET", "1", "2", secretAuth, nil, http.StatusOK, wbody.String()},
+		{"POST", "1", "2", certAuth, nil, http.StatusMethodNotAllowed, "Method Not Allowed\n"},
+		// another cluster
+		{"GET", "3", "2", certAuth, nil, http.StatusPreconditionFailed, "cluster ID mismatch\n"},
+		// the headers of a member, spoofed by an unauthenticated client
+		{"GET", "1", "2", noAuth, nil, http.StatusForbidden, "peer client certificate or shared secret required\n"},
+		{"GET", "1", "2", badSecretAuth, nil, http.StatusUnauthorized, "unauthorized peer request\n"},
+		// a joining member, not a member yet
+		{"GET", "1", "3", certAuth, nil, http.StatusOK, wbody.String()},
+		{"GET", "1", "3", secretAuth, nil, http.StatusOK, wbody.String()},
+		{"GET", "1", "", certAuth, nil, http.StatusBadRequest, "bad X-Server-From\n"},
+		{"GET", "1", "2", certAuth, snap.ErrNoSnapshot, http.StatusNotFound, snap.ErrNoSnapshot.Error() + "\n"},
+	}
+	for i, tt := range tests {
+		ps.err = tt.err
+		req := httptest.NewRequest(tt.method, peerSnapshotPath, nil)
+		req.Header.Set("X-Etcd-Cluster-ID", tt.cid)
+		req.Header.Set("X-Server-From", tt.from)
+		h := ph
+		switch tt.auth {
+		case certAuth:
+			req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{}}}}
+		case secretAuth:
+			h = sph
+			signPeerRequest(t, req, secret)
+		case badSecretAuth:
+			h = sph
+			signPeerRequest(t, req, []byte("other"))
+		}
+		rw := httptest.NewRecorder()
+		h.ServeHTTP(rw, req)
+
+		if rw.Code != tt.wcode {
+			t.Errorf("#%d: code = %d, want %d", i, rw.Code, tt.wcode)
+		}
+		if rw.Body.String() != tt.wbody {
+			t.Errorf("#%d: body = %q, want %q", i, rw.Body.String(), tt.wbody)
+		}
+	}
+}
+
+// signPeerRequest sets the signature headers of a peer holding secret on r.
+func signPeerRequest(t *testing.T, r *http.Request, secret []byte) {
+	var signed *http.Request
+	rt := rafthttp.NewSharedSecretRoundTripper(roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		signed = r
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(new(bytes.Buffer))}, nil
+	}), secret)
+	if _, err := rt.RoundTrip(r); err != nil {
+		t.Fatal(err)
+	}
+	r.Header = signed.Header
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+type fakePeerJoiner struct {
+	m   membership.Member
+	err error
+}
+
+func (pj *fakePeerJoiner) JoinMember(ctx context.Context, m membership.Member) ([]*membership.Member, error) {
+	if pj.err != nil {
+		return nil, pj.err
+	}
+	pj.m = m
+	return []*membership.Member{{ID: 2}, &m}, nil
+}
+
+func TestServePeerJoin(t *testing.T) {
+	cluster := &fakeCluster{
+		id:      1,
+		members: map[uint64]*membership.Member{2: {ID: 2}},
+	}
+	pj := &fakePeerJoiner{}
+	ph := newPeerHandler(zap.NewExample(), cluster, http.NotFoundHandler(), nil, nil, pj)
+
+	tests := []struct {
+		method string
+		cid    string
+		cert   bool
+		body   string
+		err    error
+
+		wcode int
+	}{
+		{"POST", "1", true, `{"id":3,"peerURLs":["https://10.0.0.3:2380"]}`, nil, http.StatusCreated},
+		{"GET", "1", true, "", nil, http.StatusMethodNotAllowed},
+		{"POST", "3", true, `{"id":3,"peerURLs":["https://10.0.0.3:2380"]}`, nil, http.StatusPreconditionFailed},
+		// the joining member must present a peer client certificate
+		{"POST", "1", false, `{"id":3,"peerURLs":["https://10.0.0.3:2380"]}`, nil, http.StatusForbidden},
+		{"POST", "1", true, `{"id":3}`, nil, http.StatusBadRequest},
+		{"POST", "1", true, `{"id":3,"peerURLs":["https://10.0.0.3:2380"],"isLearner":true}`, nil, http.StatusBadRequest},
+		{"POST", "1", true, `{"id":3,"peerURLs":["https://10.0.0.3:2380"]}`, membership.ErrPeerURLexists, http.StatusConflict},
+		{"POST", "1", true, `{"id":3,"peerURLs":["https://10.0.0.3:2380"]}`, etcdserver.ErrUnhealthy, http.StatusServiceUnavailable},
+	}
+	for i, tt := range tests {
+		pj.m, pj.err = membership.Member{}, tt.err
+		req := httptest.NewRequest(tt.method, peerJoinPath, bytes.NewBufferString(tt.body))
+		req.Header.Set("X-Etcd-Cluster-ID", tt.cid)
+		if tt.cert {
+			req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{}}}}
+		}
+		rw := httptest.NewRecorder()
+		ph.ServeHTTP(rw, req)
+
+		if rw.Code != tt.wcode {
+			t.Errorf("#%d: code = %d, want %d", i, rw.Code, tt.wcode)
+		}
+		if tt.wcode == http.StatusCreated && pj.m.ID != 3 {
+			t.Errorf("#%d: joined member ID = %s, want %s", i, pj.m.ID, types.ID(3))
+		}
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdhttp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"go.etcd.io/etcd/etcdserver/api/v2http/httptypes"
+)
+
+// PathAPIs is the path of the API versions served, with their
+// capabilities.
+const PathAPIs = "/apis"
+
+// apiVersionRegexp matches the first path element of the versioned APIs.
+var apiVersionRegexp = regexp.MustCompile(`^v[0-9]+[a-z0-9]*$`)
+
+// APIVersion describes a version of the client API.
+type APIVersion struct {
+	// Version is the first element of the paths of the API, e.g. "v2".
+	Version string `json:"version"`
+	// Capabilities lists the resources or features the API serves.
+	Capabilities []string `json:"capabilities"`
+}
+
+// VersionMux routes the client requests by the version prefix of their
+// path, e.g. "/v2/", to the handler of that API version. The requests for
+// an unknown version are rejected, and the unversioned ones (version,
+// health, metrics) are passed to the basic handler.
+type VersionMux struct {
+	basic    http.Handler
+	handlers map[string]http.Handler
+	apis     map[string]APIVersion
+}
+
+// NewVersionMux returns a VersionMux passing the unversioned requests to
+// basic.
+func NewVersionMux(basic http.Handler) *VersionMux {
+	return &VersionMux{
+		basic:    basic,
+		handlers: make(map[string]http.Handler),
+		apis:     make(map[string]APIVersion),
+	}
+}
+
+// Handle registers the handler of the given API version, and advertises
+// its capabilities on PathAPIs. A nil handler advertises a version served
+// apart, on the same listeners; its requests reaching the VersionMux are
+// not found. Handle must be called before serving requests.
+func (m *VersionMux) Handle(api APIVersion, h http.Handler) {
+	if !apiVersionRegexp.MatchString(api.Version) {
+		panic(fmt.Sprintf("etcdhttp: invalid API version %q", api.Version))
+	}
+	if h == nil {
+		h = http.HandlerFunc(http.NotFound)
+	}
+	m.handlers[api.Version] = h
+	m.apis[api.Version] = api
+}
+
+// APIs returns the advertised API versions, by version.
+func (m *VersionMux) APIs() []APIVersion {
+	apis := make([]APIVersion, 0, len(m.apis))
+	for _, api := range m.apis {
+		apis = append(apis, api)
+	}
+	sort.Slice(apis, func(i, j int) bool { return apis[i].Version < apis[j].Version })
+	return apis
+}
+
+func (m *VersionMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == PathAPIs {
+		m.serveAPIs(w, r)
+		return
+	}
+	v := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)[0]
+	if !apiVersionRegexp.MatchString(v) {
+		m.basic.ServeHTTP(w, r)
+		return
+	}
+	h, ok := m.handlers[v]
+	if !ok {
+		WriteError(nil, w, r, httptypes.NewHTTPError(http.StatusNotFound, fmt.Sprintf("API version %s is not served", v)))
+		return
+	}
+	h.ServeHTTP(w, r)
+}
+
+func (m *VersionMux) serveAPIs(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, "GET") {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(m.APIs()); err != nil {
+		plog.Warningf("failed to encode API versions (%v)", err)
+	}
+}
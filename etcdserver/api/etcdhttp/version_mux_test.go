@@ -0,0 +1,74 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdhttp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestVersionMux(t *testing.T) {
+	named := func(name string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(name))
+		})
+	}
+	vm := NewVersionMux(named("basic"))
+	vm.Handle(APIVersion{Version: "v2", Capabilities: []string{"keys"}}, named("v2"))
+	vm.Handle(APIVersion{Version: "v3", Capabilities: []string{"kv"}}, nil)
+
+	tests := []struct {
+		path string
+
+		wcode int
+		wbody string
+	}{
+		{"/v2/keys/foo", http.StatusOK, "v2"},
+		{"/v2", http.StatusOK, "v2"},
+		{"/version", http.StatusOK, "basic"},
+		{"/health", http.StatusOK, "basic"},
+		// the v3 API is served apart
+		{"/v3/kv/range", http.StatusNotFound, ""},
+		{"/v4/kv/range", http.StatusNotFound, ""},
+		{"/v3beta/kv/range", http.StatusNotFound, ""},
+	}
+	for i, tt := range tests {
+		rw := httptest.NewRecorder()
+		vm.ServeHTTP(rw, httptest.NewRequest("GET", tt.path, nil))
+		if rw.Code != tt.wcode {
+			t.Errorf("#%d: code = %d, want %d", i, rw.Code, tt.wcode)
+		}
+		if tt.wbody != "" && rw.Body.String() != tt.wbody {
+			t.Errorf("#%d: body = %q, want %q", i, rw.Body.String(), tt.wbody)
+		}
+	}
+
+	rw := httptest.NewRecorder()
+	vm.ServeHTTP(rw, httptest.NewRequest("GET", PathAPIs, nil))
+	if rw.Code != http.StatusOK {
+		t.Fatalf("code = %d, want %d", rw.Code, http.StatusOK)
+	}
+	var apis []APIVersion
+	if err := json.Unmarshal(rw.Body.Bytes(), &apis); err != nil {
+		t.Fatal(err)
+	}
+	wapis := []APIVersion{{Version: "v2", Capabilities: []string{"keys"}}, {Version: "v3", Capabilities: []string{"kv"}}}
+	if !reflect.DeepEqual(apis, wapis) {
+		t.Errorf("apis = %+v, want %+v", apis, wapis)
+	}
+}
@@ -106,6 +106,21 @@ func (c *RaftCluster) Members() []*Member {
 	return []*Member(ms)
 }
 
+// VotingMembers returns a slice of the members which are not learners,
+// sorted by their ID.
+func (c *RaftCluster) VotingMembers() []*Member {
+	c.Lock()
+	defer c.Unlock()
+	var ms MembersByID
+	for _, m := range c.members {
+		if !m.IsLearner {
+			ms = append(ms, m.Clone())
+		}
+	}
+	sort.Sort(ms)
+	return []*Member(ms)
+}
+
 func (c *RaftCluster) Member(id types.ID) *Member {
 	c.Lock()
 	defer c.Unlock()
@@ -259,7 +274,7 @@ func (c *RaftCluster) ValidateConfigurationChange(cc raftpb.ConfChange) error {
 		return ErrIDRemoved
 	}
 	switch cc.Type {
-	case raftpb.ConfChangeAddNode:
+	case raftpb.ConfChangeAddNode, raftpb.ConfChangeAddLearnerNode:
 		if members[id] != nil {
 			return ErrIDExists
 		}
@@ -314,12 +329,15 @@ func (c *RaftCluster) ValidateConfigurationChange(cc raftpb.ConfChange) error {
 				return ErrPeerURLexists
 			}
 		}
+		if ValidateMetadata(m.Metadata) != nil {
+			return ErrInvalidMetadata
+		}
 
 	default:
 		if c.lg != nil {
 			c.lg.Panic("unknown ConfChange type", zap.String("type", cc.Type.String()))
 		} else {
-			plog.Panicf("ConfChange type should be either AddNode, RemoveNode or UpdateNode")
+			plog.Panicf("ConfChange type should be either AddNode, AddLearnerNode, RemoveNode or UpdateNode")
 		}
 	}
 	return nil
@@ -432,6 +450,50 @@ func (c *RaftCluster) UpdateAttributes(id types.ID, attr Attributes) {
 	}
 }
 
+// UpdateMetadata replaces the metadata of the member. It is applied from
+// its own request, so that it does not revert nor is reverted by the
+// updates of the other attributes of the member.
+func (c *RaftCluster) UpdateMetadata(id types.ID, md map[string]string) {
+	c.Lock()
+	defer c.Unlock()
+
+	m, ok := c.members[id]
+	if !ok {
+		if c.lg != nil {
+			c.lg.Warn(
+				"skipped metadata update of unknown or removed member",
+				zap.String("cluster-id", c.cid.String()),
+				zap.String("local-member-id", c.localID.String()),
+				zap.String("updated-peer-id", id.String()),
+			)
+		} else {
+			plog.Warningf("skipped updating metadata of unknown or removed member %s", id)
+		}
+		return
+	}
+	if len(md) == 0 {
+		md = nil
+	}
+	m.Metadata = md
+	if c.v2store != nil {
+		mustUpdateMemberMetadataInStore(c.v2store, m)
+	}
+	if c.be != nil {
+		mustSaveMemberToBackend(c.be, m)
+	}
+
+	if c.lg != nil {
+		c.lg.Info(
+			"updated member metadata",
+			zap.String("cluster-id", c.cid.String()),
+			zap.String("local-member-id", c.localID.String()),
+			zap.String("updated-remote-peer-id", id.String()),
+		)
+	} else {
+		plog.Noticef("updated metadata of member %s in cluster %s", id, c.cid)
+	}
+}
+
 func (c *RaftCluster) UpdateRaftAttributes(id types.ID, raftAttr RaftAttributes) {
 	c.Lock()
 	defer c.Unlock()
@@ -505,11 +567,17 @@ func (c *RaftCluster) SetVersion(ver *semver.Version, onSet func(*zap.Logger, *s
 	onSet(c.lg, ver)
 }
 
+// IsReadyToAddNewMember returns true if the quorum is still met by the
+// started members once a new voting member is added. The learners do not
+// count toward the quorum.
 func (c *RaftCluster) IsReadyToAddNewMember() bool {
 	nmembers := 1
 	nstarted := 0
 
 	for _, member := range c.members {
+		if member.IsLearner {
+			continue
+		}
 		if member.IsStarted() {
 			nstarted++
 		}
@@ -546,12 +614,21 @@ func (c *RaftCluster) IsReadyToAddNewMember() bool {
 	return true
 }
 
+// IsReadyToRemoveMember returns true if the quorum is still met by the
+// started members once the member is removed. Removing a learner never
+// affects the quorum.
 func (c *RaftCluster) IsReadyToRemoveMember(id uint64) bool {
 	nmembers := 0
 	nstarted := 0
 
 	for _, member := range c.members {
 		if uint64(member.ID) == id {
+			if member.IsLearner {
+				return true
+			}
+			continue
+		}
+		if member.IsLearner {
 			continue
 		}
 
@@ -80,6 +80,21 @@ func TestClusterMemberByName(t *testing.T) {
 	}
 }
 
+func TestClusterVotingMembers(t *testing.T) {
+	cls := newTestCluster([]*Member{
+		newTestMember(2, nil, "", nil),
+		newTestLearner(3, nil, "", nil),
+		newTestMember(1, nil, "", nil),
+	})
+	w := []*Member{
+		newTestMember(1, nil, "", nil),
+		newTestMember(2, nil, "", nil),
+	}
+	if g := cls.VotingMembers(); !reflect.DeepEqual(g, w) {
+		t.Errorf("members = %+v, want %+v", g, w)
+	}
+}
+
 func TestClusterMemberIDs(t *testing.T) {
 	c := newTestCluster([]*Member{
 		newTestMember(1, nil, "", nil),
@@ -355,6 +370,21 @@ func TestClusterValidateConfigurationChange(t *testing.T) {
 			},
 			ErrIDNotFound,
 		},
+		{
+			raftpb.ConfChange{
+				Type:    raftpb.ConfChangeAddLearnerNode,
+				NodeID:  5,
+				Context: ctx,
+			},
+			ErrPeerURLexists,
+		},
+		{
+			raftpb.ConfChange{
+				Type:   raftpb.ConfChangeAddLearnerNode,
+				NodeID: 1,
+			},
+			ErrIDExists,
+		},
 		{
 			raftpb.ConfChange{
 				Type:    raftpb.ConfChangeAddNode,
@@ -363,6 +393,14 @@ func TestClusterValidateConfigurationChange(t *testing.T) {
 			},
 			nil,
 		},
+		{
+			raftpb.ConfChange{
+				Type:    raftpb.ConfChangeAddLearnerNode,
+				NodeID:  5,
+				Context: ctx5,
+			},
+			nil,
+		},
 		{
 			raftpb.ConfChange{
 				Type:    raftpb.ConfChangeUpdateNode,
@@ -417,6 +455,41 @@ func TestClusterGenID(t *testing.T) {
 	}
 }
 
+// TestClusterGenIDToken ensures that clusters bootstrapped from the same
+// initial cluster configuration but with different initial cluster tokens
+// get different cluster IDs, so that their members refuse each other's
+// peer traffic.
+func TestClusterGenIDToken(t *testing.T) {
+	urlsmap, err := types.NewURLsMap("infra1=http://10.0.0.1:2380,infra2=http://10.0.0.2:2380")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c1, err := NewClusterFromURLsMap(zap.NewExample(), "etcd-cluster-1", urlsmap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c2, err := NewClusterFromURLsMap(zap.NewExample(), "etcd-cluster-1", urlsmap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c1.ID() != c2.ID() {
+		t.Fatalf("cluster.ID = %v, want %v", c2.ID(), c1.ID())
+	}
+
+	c3, err := NewClusterFromURLsMap(zap.NewExample(), "etcd-cluster-2", urlsmap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c1.ID() == c3.ID() {
+		t.Fatalf("cluster.ID = %v, want not %v", c3.ID(), c1.ID())
+	}
+	for _, m := range c1.Members() {
+		if c3.Member(m.ID) != nil {
+			t.Fatalf("member %s exists in clusters with different tokens", m.ID)
+		}
+	}
+}
+
 func TestNodeToMemberBad(t *testing.T) {
 	tests := []*v2store.NodeExtern{
 		{Key: "/1234", Nodes: []*v2store.NodeExtern{
@@ -494,6 +567,37 @@ func TestClusterMembers(t *testing.T) {
 	}
 }
 
+// TestClusterMembersElectionPriority ensures the election priority of the
+// members is listed with them.
+func TestClusterMembersElectionPriority(t *testing.T) {
+	cls := newTestCluster([]*Member{
+		newTestMember(1, nil, "node1", nil),
+		{ID: 2, Attributes: Attributes{Name: "node2", ElectionPriority: 10}},
+	})
+	ms := cls.Members()
+	if ms[0].ElectionPriority != 0 || ms[1].ElectionPriority != 10 {
+		t.Errorf("priorities = %d, %d, want 0, 10", ms[0].ElectionPriority, ms[1].ElectionPriority)
+	}
+	if m := cls.Member(2); m.ElectionPriority != 10 {
+		t.Errorf("priority = %d, want 10", m.ElectionPriority)
+	}
+}
+
+// TestClusterMembersWitness ensures the witnesses are listed as such.
+func TestClusterMembersWitness(t *testing.T) {
+	cls := newTestCluster([]*Member{
+		newTestMember(1, nil, "node1", nil),
+		{ID: 2, Attributes: Attributes{Name: "node2", Witness: true}},
+	})
+	ms := cls.Members()
+	if ms[0].Witness || !ms[1].Witness {
+		t.Errorf("witnesses = %v, %v, want false, true", ms[0].Witness, ms[1].Witness)
+	}
+	if m := cls.Member(2); !m.Witness {
+		t.Error("member 2 is not listed as a witness")
+	}
+}
+
 func TestClusterRemoveMember(t *testing.T) {
 	st := mockstore.NewRecorder()
 	c := newTestCluster(nil)
@@ -545,6 +649,39 @@ func TestClusterUpdateAttributes(t *testing.T) {
 	}
 }
 
+// TestClusterUpdateMetadata ensures the metadata is kept by the updates
+// of the peer URLs, and is recovered from the store.
+func TestClusterUpdateMetadata(t *testing.T) {
+	st := v2store.New()
+	c := newTestCluster(nil)
+	c.SetStore(st)
+	c.AddMember(newTestMember(1, []string{"http://10.0.0.1:2380"}, "node1", nil))
+
+	md := map[string]string{"zone": "a"}
+	c.UpdateMetadata(1, md)
+	c.UpdateRaftAttributes(1, RaftAttributes{PeerURLs: []string{"http://10.0.0.2:2380"}})
+	if g := c.Member(1).Metadata; !reflect.DeepEqual(g, md) {
+		t.Errorf("metadata = %v, want %v", g, md)
+	}
+	membs, _ := membersFromStore(c.lg, st)
+	if g := membs[1].Metadata; !reflect.DeepEqual(g, md) {
+		t.Errorf("recovered metadata = %v, want %v", g, md)
+	}
+
+	c.UpdateMetadata(1, nil)
+	if _, err := st.Get(MemberMetadataStorePath(1), false, false); !isKeyNotFound(err) {
+		t.Errorf("err = %v, want key not found", err)
+	}
+	membs, _ = membersFromStore(c.lg, st)
+	if g := membs[1].Metadata; g != nil {
+		t.Errorf("recovered metadata = %v, want nil", g)
+	}
+
+	// updating a removed member is skipped
+	c.RemoveMember(1)
+	c.UpdateMetadata(1, md)
+}
+
 func TestNodeToMember(t *testing.T) {
 	n := &v2store.NodeExtern{Key: "/1234", Nodes: []*v2store.NodeExtern{
 		{Key: "/1234/attributes", Value: stringp(`{"name":"node1","clientURLs":null}`)},
@@ -575,6 +712,15 @@ func TestIsReadyToAddNewMember(t *testing.T) {
 		members []*Member
 		want    bool
 	}{
+		{
+			// 2/2 voting members ready, the learner does not count toward the quorum
+			[]*Member{
+				newTestMember(1, nil, "1", nil),
+				newTestMember(2, nil, "2", nil),
+				newTestLearner(3, nil, "", nil),
+			},
+			true,
+		},
 		{
 			// 0/3 members ready, should fail
 			[]*Member{
@@ -726,6 +872,26 @@ func TestIsReadyToRemoveMember(t *testing.T) {
 			4,
 			true,
 		},
+		{
+			// 1/2 voting members ready, the learner does not count toward the quorum
+			[]*Member{
+				newTestMember(1, nil, "1", nil),
+				newTestMember(2, nil, "", nil),
+				newTestLearner(3, nil, "3", nil),
+			},
+			1,
+			false,
+		},
+		{
+			// removing a learner never breaks the quorum
+			[]*Member{
+				newTestMember(1, nil, "1", nil),
+				newTestMember(2, nil, "", nil),
+				newTestLearner(3, nil, "3", nil),
+			},
+			3,
+			true,
+		},
 	}
 	for i, tt := range tests {
 		c := newTestCluster(tt.members)
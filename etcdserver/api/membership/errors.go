@@ -25,6 +25,8 @@ var (
 	ErrIDExists      = errors.New("membership: ID exists")
 	ErrIDNotFound    = errors.New("membership: ID not found")
 	ErrPeerURLexists = errors.New("membership: peerURL exists")
+
+	ErrInvalidMetadata = errors.New("membership: invalid member metadata")
 )
 
 func isKeyNotFound(err error) bool {
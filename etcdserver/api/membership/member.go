@@ -35,25 +35,50 @@ type RaftAttributes struct {
 	// PeerURLs is the list of peers in the raft cluster.
 	// TODO(philips): ensure these are URLs
 	PeerURLs []string `json:"peerURLs"`
+	// IsLearner is true if the member is a raft learner: a read-only
+	// replica which receives the log but does not vote, so it does not
+	// count toward the quorum.
+	IsLearner bool `json:"isLearner,omitempty"`
 }
 
 // Attributes represents all the non-raft related attributes of an etcd member.
 type Attributes struct {
 	Name       string   `json:"name,omitempty"`
 	ClientURLs []string `json:"clientURLs,omitempty"`
+	// ElectionPriority biases leadership toward the member; the leader
+	// hands leadership over to an active member with a higher priority.
+	ElectionPriority uint `json:"electionPriority,omitempty"`
+	// Witness is true if the member only votes and persists the log; it
+	// never serves client requests nor stays leader.
+	Witness bool `json:"witness,omitempty"`
 }
 
 type Member struct {
 	ID types.ID `json:"id"`
 	RaftAttributes
 	Attributes
+	// Metadata holds operator-defined key/value pairs describing the
+	// member, e.g. its zone or rack. It is replicated on its own, so that
+	// the updates of the attributes keep it; see ValidateMetadata for its
+	// limits.
+	Metadata map[string]string `json:"metadata,omitempty"`
 }
 
 // NewMember creates a Member without an ID and generates one based on the
 // cluster name, peer URLs, and time. This is used for bootstrapping/adding new member.
 func NewMember(name string, peerURLs types.URLs, clusterName string, now *time.Time) *Member {
+	return newMember(name, peerURLs, clusterName, now, false)
+}
+
+// NewMemberAsLearner creates a learner Member without an ID and generates
+// one like NewMember. This is used for adding read-only replicas.
+func NewMemberAsLearner(name string, peerURLs types.URLs, clusterName string, now *time.Time) *Member {
+	return newMember(name, peerURLs, clusterName, now, true)
+}
+
+func newMember(name string, peerURLs types.URLs, clusterName string, now *time.Time, isLearner bool) *Member {
 	m := &Member{
-		RaftAttributes: RaftAttributes{PeerURLs: peerURLs.StringSlice()},
+		RaftAttributes: RaftAttributes{PeerURLs: peerURLs.StringSlice(), IsLearner: isLearner},
 		Attributes:     Attributes{Name: name},
 	}
 
@@ -87,16 +112,17 @@ func (m *Member) Clone() *Member {
 		return nil
 	}
 	mm := &Member{
-		ID: m.ID,
-		Attributes: Attributes{
-			Name: m.Name,
-		},
+		ID:             m.ID,
+		RaftAttributes: RaftAttributes{IsLearner: m.IsLearner},
+		Attributes:     m.Attributes,
+		Metadata:       cloneMetadata(m.Metadata),
 	}
 	if m.PeerURLs != nil {
 		mm.PeerURLs = make([]string, len(m.PeerURLs))
 		copy(mm.PeerURLs, m.PeerURLs)
 	}
 	if m.ClientURLs != nil {
+		// do not share the client URLs of the copied attributes
 		mm.ClientURLs = make([]string, len(m.ClientURLs))
 		copy(mm.ClientURLs, m.ClientURLs)
 	}
@@ -0,0 +1,73 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package membership
+
+import "fmt"
+
+const (
+	// MaxMetadataEntries is the maximum number of metadata entries of a
+	// member. The metadata is replicated with every update of the member,
+	// so it is kept small.
+	MaxMetadataEntries = 32
+	// MaxMetadataKeyLength is the maximum length of a metadata key.
+	MaxMetadataKeyLength = 63
+	// MaxMetadataValueLength is the maximum length of a metadata value.
+	MaxMetadataValueLength = 256
+)
+
+// ValidateMetadata returns ErrInvalidMetadata if the member metadata md
+// has too many entries, or an entry whose key is empty, too long or not
+// made of letters, digits, '.', '_', '-' and '/', or whose value is too
+// long.
+func ValidateMetadata(md map[string]string) error {
+	if len(md) > MaxMetadataEntries {
+		return fmt.Errorf("%v: %d entries, above the maximum of %d", ErrInvalidMetadata, len(md), MaxMetadataEntries)
+	}
+	for k, v := range md {
+		if k == "" || len(k) > MaxMetadataKeyLength {
+			return fmt.Errorf("%v: key %q must have 1 to %d characters", ErrInvalidMetadata, k, MaxMetadataKeyLength)
+		}
+		for _, c := range k {
+			if !isMetadataKeyChar(c) {
+				return fmt.Errorf("%v: key %q has invalid character %q", ErrInvalidMetadata, k, c)
+			}
+		}
+		if len(v) > MaxMetadataValueLength {
+			return fmt.Errorf("%v: value of key %q has more than %d characters", ErrInvalidMetadata, k, MaxMetadataValueLength)
+		}
+	}
+	return nil
+}
+
+func isMetadataKeyChar(c rune) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
+	case c == '.', c == '_', c == '-', c == '/':
+		return true
+	}
+	return false
+}
+
+func cloneMetadata(md map[string]string) map[string]string {
+	if md == nil {
+		return nil
+	}
+	c := make(map[string]string, len(md))
+	for k, v := range md {
+		c[k] = v
+	}
+	return c
+}
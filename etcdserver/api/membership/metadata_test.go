@@ -0,0 +1,48 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package membership
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestValidateMetadata(t *testing.T) {
+	tooMany := make(map[string]string)
+	for i := 0; i <= MaxMetadataEntries; i++ {
+		tooMany[fmt.Sprintf("k%d", i)] = "v"
+	}
+	tests := []struct {
+		md map[string]string
+
+		werr bool
+	}{
+		{nil, false},
+		{map[string]string{"zone": "us-east-1a", "topology.example.com/rack": "r1", "role": ""}, false},
+		{map[string]string{"": "v"}, true},
+		{map[string]string{"zone a": "v"}, true},
+		{map[string]string{"zone=": "v"}, true},
+		{map[string]string{strings.Repeat("k", MaxMetadataKeyLength+1): "v"}, true},
+		{map[string]string{"k": strings.Repeat("v", MaxMetadataValueLength+1)}, true},
+		{tooMany, true},
+	}
+	for i, tt := range tests {
+		err := ValidateMetadata(tt.md)
+		if (err != nil) != tt.werr {
+			t.Errorf("#%d: err = %v, want error %v", i, err, tt.werr)
+		}
+	}
+}
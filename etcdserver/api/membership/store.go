@@ -29,6 +29,7 @@ import (
 const (
 	attributesSuffix     = "attributes"
 	raftAttributesSuffix = "raftAttributes"
+	metadataSuffix       = "metadata"
 
 	// the prefix for stroing membership related information in store provided by store pkg.
 	storePrefix = "/0"
@@ -84,6 +85,9 @@ func mustSaveMemberToStore(s v2store.Store, m *Member) {
 	if _, err := s.Create(p, false, string(b), false, v2store.TTLOptionSet{ExpireTime: v2store.Permanent}); err != nil {
 		plog.Panicf("create raftAttributes should never fail: %v", err)
 	}
+	if len(m.Metadata) > 0 {
+		mustUpdateMemberMetadataInStore(s, m)
+	}
 }
 
 func mustDeleteMemberFromStore(s v2store.Store, id types.ID) {
@@ -117,6 +121,25 @@ func mustUpdateMemberAttrInStore(s v2store.Store, m *Member) {
 	}
 }
 
+// mustUpdateMemberMetadataInStore saves the metadata of the member, and
+// deletes its key once the metadata is empty.
+func mustUpdateMemberMetadataInStore(s v2store.Store, m *Member) {
+	p := path.Join(MemberStoreKey(m.ID), metadataSuffix)
+	if len(m.Metadata) == 0 {
+		if _, err := s.Delete(p, false, false); err != nil && !isKeyNotFound(err) {
+			plog.Panicf("delete metadata should never fail: %v", err)
+		}
+		return
+	}
+	b, err := json.Marshal(m.Metadata)
+	if err != nil {
+		plog.Panicf("marshal metadata should never fail: %v", err)
+	}
+	if _, err := s.Set(p, false, string(b), v2store.TTLOptionSet{ExpireTime: v2store.Permanent}); err != nil {
+		plog.Panicf("update metadata should never fail: %v", err)
+	}
+}
+
 func mustSaveClusterVersionToStore(s v2store.Store, ver *semver.Version) {
 	if _, err := s.Set(StoreClusterVersionKey(), false, ver.String(), v2store.TTLOptionSet{ExpireTime: v2store.Permanent}); err != nil {
 		plog.Panicf("save cluster version should never fail: %v", err)
@@ -130,8 +153,9 @@ func nodeToMember(n *v2store.NodeExtern) (*Member, error) {
 	attrs := make(map[string][]byte)
 	raftAttrKey := path.Join(n.Key, raftAttributesSuffix)
 	attrKey := path.Join(n.Key, attributesSuffix)
+	metadataKey := path.Join(n.Key, metadataSuffix)
 	for _, nn := range n.Nodes {
-		if nn.Key != raftAttrKey && nn.Key != attrKey {
+		if nn.Key != raftAttrKey && nn.Key != attrKey && nn.Key != metadataKey {
 			return nil, fmt.Errorf("unknown key %q", nn.Key)
 		}
 		attrs[nn.Key] = []byte(*nn.Value)
@@ -148,6 +172,11 @@ func nodeToMember(n *v2store.NodeExtern) (*Member, error) {
 			return m, fmt.Errorf("unmarshal attributes error: %v", err)
 		}
 	}
+	if data := attrs[metadataKey]; data != nil {
+		if err := json.Unmarshal(data, &m.Metadata); err != nil {
+			return m, fmt.Errorf("unmarshal metadata error: %v", err)
+		}
+	}
 	return m, nil
 }
 
@@ -180,6 +209,12 @@ func MemberAttributesStorePath(id types.ID) string {
 	return path.Join(MemberStoreKey(id), attributesSuffix)
 }
 
+// MemberMetadataStorePath returns the store key of the metadata of the
+// member; see RaftCluster.UpdateMetadata.
+func MemberMetadataStorePath(id types.ID) string {
+	return path.Join(MemberStoreKey(id), metadataSuffix)
+}
+
 func MustParseMemberIDFromKey(key string) types.ID {
 	id, err := types.IDFromString(path.Base(key))
 	if err != nil {
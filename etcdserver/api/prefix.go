@@ -0,0 +1,104 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	// DefaultMaxPrefixLabels is the default number of distinct key
+	// prefixes tracked by a PrefixLabeler.
+	DefaultMaxPrefixLabels = 64
+
+	// OtherPrefixLabel labels keys whose prefix is not tracked.
+	OtherPrefixLabel = "other"
+)
+
+// PrefixLabeler maps keys to their top-level prefix, for use as a metric
+// label. The first max distinct prefixes seen are tracked; any other
+// prefix is labeled OtherPrefixLabel to keep metric cardinality bounded.
+type PrefixLabeler struct {
+	mu       sync.RWMutex
+	max      int
+	prefixes map[string]struct{}
+}
+
+func NewPrefixLabeler(max int) *PrefixLabeler {
+	return &PrefixLabeler{max: max, prefixes: make(map[string]struct{})}
+}
+
+// Label returns the metric label of the given key.
+func (pl *PrefixLabeler) Label(key string) string {
+	p := toValidUTF8(topLevelPrefix(key))
+
+	pl.mu.RLock()
+	_, ok := pl.prefixes[p]
+	pl.mu.RUnlock()
+	if ok {
+		return p
+	}
+
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+	if _, ok = pl.prefixes[p]; ok {
+		return p
+	}
+	if len(pl.prefixes) >= pl.max {
+		return OtherPrefixLabel
+	}
+	pl.prefixes[p] = struct{}{}
+	return p
+}
+
+// topLevelPrefix returns the first component of a slash separated key,
+// including the leading slash if any. A key without separators is its
+// own prefix.
+func topLevelPrefix(key string) string {
+	i := 0
+	if strings.HasPrefix(key, "/") {
+		i = 1
+	}
+	if j := strings.IndexByte(key[i:], '/'); j >= 0 {
+		return key[:i+j]
+	}
+	return key
+}
+
+// toValidUTF8 replaces each run of invalid UTF-8 bytes in s with the
+// Unicode replacement character, since metric labels must be valid UTF-8.
+func toValidUTF8(s string) string {
+	if utf8.ValidString(s) {
+		return s
+	}
+	var b strings.Builder
+	invalid := false
+	for i := 0; i < len(s); {
+		r, n := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && n == 1 {
+			if !invalid {
+				b.WriteRune(utf8.RuneError)
+			}
+			invalid = true
+		} else {
+			b.WriteString(s[i : i+n])
+			invalid = false
+		}
+		i += n
+	}
+	return b.String()
+}
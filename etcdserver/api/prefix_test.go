@@ -0,0 +1,63 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import "testing"
+
+func TestPrefixLabeler(t *testing.T) {
+	tests := []struct {
+		key string
+		w   string
+	}{
+		{"/registry/pods/a", "/registry"},
+		{"/registry", "/registry"},
+		{"app/config", "app"},
+		{"foo", "foo"},
+		{"/", "/"},
+		{"", ""},
+		// no more prefixes are tracked
+		{"/other/key", OtherPrefixLabel},
+		{"/registry/services/b", "/registry"},
+		{"\xff/a", OtherPrefixLabel},
+	}
+
+	pl := NewPrefixLabeler(5)
+	for i, tt := range tests {
+		if g := pl.Label(tt.key); g != tt.w {
+			t.Errorf("#%d: label of %q = %q, want %q", i, tt.key, g, tt.w)
+		}
+	}
+}
+
+func TestToValidUTF8(t *testing.T) {
+	tests := []struct {
+		s string
+		w string
+	}{
+		{"", ""},
+		{"/registry", "/registry"},
+		{"/日本", "/日本"},
+		{"\xff", "�"},
+		{"/a\xff\xfeb", "/a�b"},
+		{"\xffa\xff", "�a�"},
+		// a truncated multi-byte sequence
+		{"/\xe6\x97", "/�"},
+	}
+	for i, tt := range tests {
+		if g := toValidUTF8(tt.s); g != tt.w {
+			t.Errorf("#%d: toValidUTF8(%q) = %q, want %q", i, tt.s, g, tt.w)
+		}
+	}
+}
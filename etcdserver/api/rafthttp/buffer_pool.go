@@ -0,0 +1,82 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rafthttp
+
+import (
+	"bytes"
+	"sync"
+)
+
+const (
+	// minPooledBufferSize is the capacity of the buffers allocated for the
+	// pool, enough to receive most heartbeats and small appends.
+	minPooledBufferSize = 4 * 1024
+	// maxPooledBufferSize is the capacity above which a buffer is left to
+	// the garbage collector rather than kept in the pool, so that a few
+	// large messages do not pin their memory.
+	maxPooledBufferSize = 4 * 1024 * 1024
+)
+
+// recvBufPool holds the buffers receiving the encoded peer messages. The
+// messages are decoded from the buffers, then the buffers are reused: the
+// generated Unmarshal methods copy the bytes fields out of the buffer, so
+// that a decoded message never aliases it.
+var recvBufPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, minPooledBufferSize)
+		return &b
+	},
+}
+
+// getRecvBuf returns a pooled buffer of length n. It is returned to the
+// pool with putRecvBuf once the message it holds is decoded.
+func getRecvBuf(n int) *[]byte {
+	if n > maxPooledBufferSize {
+		b := make([]byte, n)
+		return &b
+	}
+	bp := recvBufPool.Get().(*[]byte)
+	if cap(*bp) < n {
+		*bp = make([]byte, n)
+	}
+	*bp = (*bp)[:n]
+	return bp
+}
+
+func putRecvBuf(bp *[]byte) {
+	if cap(*bp) > maxPooledBufferSize {
+		return
+	}
+	recvBufPool.Put(bp)
+}
+
+// recvBytesBufPool holds the buffers receiving the bodies of the pipeline
+// requests, whose length is not known in advance.
+var recvBytesBufPool = sync.Pool{
+	New: func() interface{} { return bytes.NewBuffer(make([]byte, 0, minPooledBufferSize)) },
+}
+
+func getRecvBytesBuf() *bytes.Buffer {
+	buf := recvBytesBufPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+func putRecvBytesBuf(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBufferSize {
+		return
+	}
+	recvBytesBufPool.Put(buf)
+}
@@ -0,0 +1,522 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rafthttp
+
+import (
+	"context"
+	"io"
+	"math"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+
+	"go.etcd.io/etcd/etcdserver/api/snap"
+	stats "go.etcd.io/etcd/etcdserver/api/v2stats"
+	"go.etcd.io/etcd/pkg/types"
+	"go.etcd.io/etcd/raft/raftpb"
+
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// ProtocolHTTP sends the raft messages to the peers over HTTP streams
+	// and pipelines.
+	ProtocolHTTP = "http"
+	// ProtocolGRPC sends the raft messages to every peer over a gRPC
+	// stream, with the flow control of HTTP/2. The snapshots are still
+	// sent over HTTP. Every member of a cluster must use the same protocol.
+	ProtocolGRPC = "grpc"
+
+	// RaftGRPCStreamPath is the path of the gRPC method streaming the raft
+	// messages of a peer. The peers open a connection of their own for
+	// it, whose first request is to this path.
+	RaftGRPCStreamPath = "/rafthttp.Raft/Stream"
+
+	streamGRPC = "streamGRPC"
+
+	grpcClusterIDKey = "x-etcd-cluster-id"
+	grpcFromKey      = "x-server-from"
+	grpcToKey        = "x-raft-to"
+	grpcPeerAuthKey  = "x-etcd-peer-auth"
+)
+
+var raftServiceDesc = grpc.ServiceDesc{
+	ServiceName: "rafthttp.Raft",
+	HandlerType: (*interface{})(nil),
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName: "Stream",
+			Handler: func(srv interface{}, stream grpc.ServerStream) error {
+				return srv.(*Transport).serveGRPCStream(stream)
+			},
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "rafthttp",
+}
+
+// GRPCServer returns a gRPC server receiving the raft messages streamed by
+// the peers using ProtocolGRPC. It must serve the connections of the peer
+// listeners whose first request is to RaftGRPCStreamPath; those listeners
+// terminate TLS, and the streams are authenticated by the verified client
+// certificates of their connections. It returns nil unless the peers
+// authenticate with client certificates.
+func (t *Transport) GRPCServer() *grpc.Server {
+	if !t.TLSInfo.ClientCertAuth {
+		return nil
+	}
+	gs := grpc.NewServer(grpc.MaxRecvMsgSize(math.MaxInt32))
+	gs.RegisterService(&raftServiceDesc, t)
+	return gs
+}
+
+func (t *Transport) serveGRPCStream(s grpc.ServerStream) error {
+	md, _ := metadata.FromIncomingContext(s.Context())
+	get := func(k string) string {
+		if v := md.Get(k); len(v) > 0 {
+			return v[0]
+		}
+		return ""
+	}
+
+	if t.authVerifier != nil {
+		if _, ok := t.authVerifier.verify(get(grpcPeerAuthKey), "POST", RaftGRPCStreamPath, false, time.Now()); !ok {
+			return status.Error(codes.Unauthenticated, "unauthorized peer request")
+		}
+	}
+	if gcid := get(grpcClusterIDKey); gcid != t.ClusterID.String() {
+		if t.Logger != nil {
+			t.Logger.Warn(
+				"request cluster ID mismatch",
+				zap.String("local-member-id", t.ID.String()),
+				zap.String("local-member-cluster-id", t.ClusterID.String()),
+				zap.String("remote-peer-cluster-id", gcid),
+			)
+		} else {
+			plog.Errorf("request cluster ID mismatch (got %s want %s)", gcid, t.ClusterID)
+		}
+		return status.Error(codes.FailedPrecondition, errClusterIDMismatch.Error())
+	}
+	from, err := types.IDFromString(get(grpcFromKey))
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid from %q", get(grpcFromKey))
+	}
+	if t.Raft.IsIDRemoved(uint64(from)) {
+		return status.Error(codes.PermissionDenied, errMemberRemoved.Error())
+	}
+	if to := get(grpcToKey); to != t.ID.String() {
+		return status.Errorf(codes.FailedPrecondition, "raft to %s does not match local member %s", to, t.ID)
+	}
+	p, ok := t.Get(from).(*grpcPeer)
+	if !ok {
+		return status.Errorf(codes.NotFound, "peer %s not found or not streaming over gRPC", from)
+	}
+
+	// acknowledge the stream, so that the peer starts sending
+	if err = s.SendMsg(&linkHeartbeatMessage); err != nil {
+		return err
+	}
+	for {
+		var m raftpb.Message
+		if err = s.RecvMsg(&m); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		p.receive(m)
+	}
+}
+
+// grpcPeer is a peer sending the raft messages over a gRPC stream, on a
+// connection of its own, and receiving the messages of the remote peer on
+// the stream the remote opens. The snapshots are sent over HTTP, and so
+// are the other messages while the stream is down.
+type grpcPeer struct {
+	lg *zap.Logger
+	tr *Transport
+
+	localID types.ID
+	// id of the remote raft peer node
+	id types.ID
+
+	r Raft
+
+	status *peerStatus
+	picker *urlPicker
+
+	pipeline   *pipeline
+	snapSender *snapshotSender
+
+	msgc  chan raftpb.Message
+	recvc chan raftpb.Message
+	propc chan raftpb.Message
+
+	mu     sync.Mutex
+	paused bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func startGRPCPeer(t *Transport, urls types.URLs, peerID types.ID, fs *stats.FollowerStats) *grpcPeer {
+	if t.Logger != nil {
+		t.Logger.Info("starting remote peer", zap.String("remote-peer-id", peerID.String()), zap.String("protocol", ProtocolGRPC))
+	} else {
+		plog.Infof("starting peer %s over gRPC...", peerID)
+	}
+
+	status := newPeerStatus(t.Logger, t.ID, peerID)
+	picker := newURLPicker(urls)
+	pipeline := &pipeline{
+		peerID:        peerID,
+		tr:            t,
+		picker:        picker,
+		status:        status,
+		followerStats: fs,
+		raft:          t.Raft,
+		errorc:        t.ErrorC,
+	}
+	pipeline.start()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &grpcPeer{
+		lg:         t.Logger,
+		tr:         t,
+		localID:    t.ID,
+		id:         peerID,
+		r:          t.Raft,
+		status:     status,
+		picker:     picker,
+		pipeline:   pipeline,
+		snapSender: newSnapshotSender(t, picker, peerID, status),
+		msgc:       make(chan raftpb.Message, streamBufSize),
+		recvc:      make(chan raftpb.Message, recvBufSize),
+		propc:      make(chan raftpb.Message, maxPendingProposals),
+		ctx:        ctx,
+		cancel:     cancel,
+		done:       make(chan struct{}),
+	}
+
+	// proposals are processed apart, since r.Process may block on them
+	// when there is no leader
+	for _, c := range []chan raftpb.Message{p.recvc, p.propc} {
+		go func(c chan raftpb.Message) {
+			for {
+				select {
+				case mm := <-c:
+					if err := p.r.Process(ctx, mm); err != nil {
+						if p.lg != nil {
+							p.lg.Warn("failed to process Raft message", zap.Error(err))
+						} else {
+							plog.Warningf("failed to process raft message (%v)", err)
+						}
+					}
+				case <-ctx.Done():
+					return
+				}
+			}
+		}(c)
+	}
+	go p.run()
+	return p
+}
+
+func (p *grpcPeer) send(m raftpb.Message) {
+	p.mu.Lock()
+	paused := p.paused
+	p.mu.Unlock()
+
+	if paused {
+		return
+	}
+
+	writec, name := p.msgc, streamGRPC
+	// Considering MsgSnap may have a big size, only send it over one of
+	// the pipelines, like the messages sent while the stream is down.
+	if isMsgSnap(m) || !p.status.isActive() {
+		writec, name = p.pipeline.msgc, pipelineMsg
+	}
+	select {
+	case writec <- m:
+	default:
+		reportSendDropped(p.lg, p.r, p.status, p.pipeline.followerStats, p.localID, p.id, m, name)
+	}
+}
+
+// receive passes a message received on the stream of the remote peer to
+// raft.
+func (p *grpcPeer) receive(m raftpb.Message) {
+	receivedBytes.WithLabelValues(types.ID(m.From).String()).Add(float64(m.Size()))
+
+	p.mu.Lock()
+	paused := p.paused
+	p.mu.Unlock()
+
+	// raft is not interested in link layer heartbeat message
+	if paused || isLinkHeartbeatMessage(&m) {
+		return
+	}
+
+	recvc := p.recvc
+	if m.Type == raftpb.MsgProp {
+		recvc = p.propc
+	}
+	select {
+	case recvc <- m:
+	default:
+		if p.lg != nil {
+			p.lg.Warn(
+				"dropped Raft message since receiving buffer is full (overloaded network)",
+				zap.String("message-type", m.Type.String()),
+				zap.String("local-member-id", p.localID.String()),
+				zap.String("from", types.ID(m.From).String()),
+				zap.Bool("remote-peer-active", p.status.isActive()),
+			)
+		} else {
+			plog.MergeWarningf("dropped internal raft message from %s since receiving buffer is full (overloaded network)", types.ID(m.From))
+		}
+		recvFailures.WithLabelValues(types.ID(m.From).String()).Inc()
+		recvDropped.WithLabelValues(types.ID(m.From).String(), m.Type.String()).Inc()
+	}
+}
+
+func (p *grpcPeer) run() {
+	defer close(p.done)
+
+	rl := rate.NewLimiter(p.tr.DialRetryFrequency, 1)
+	for {
+		if err := rl.Wait(p.ctx); err != nil {
+			return
+		}
+
+		u := p.picker.pick()
+		conn, s, err := p.dial(u)
+		if err != nil {
+			if p.ctx.Err() != nil {
+				return
+			}
+			p.picker.unreachable(u)
+			p.status.deactivate(failureType{source: streamGRPC, action: "dial"}, err.Error())
+			if status.Code(err) == codes.PermissionDenied {
+				reportCriticalError(errMemberRemoved, p.tr.ErrorC)
+			}
+			continue
+		}
+
+		p.status.activate()
+		if p.lg != nil {
+			p.lg.Info(
+				"established gRPC streaming connection with remote peer",
+				zap.String("local-member-id", p.localID.String()),
+				zap.String("remote-peer-id", p.id.String()),
+			)
+		} else {
+			plog.Infof("established a gRPC streaming connection with peer %s", p.id)
+		}
+
+		err = p.stream(s)
+		conn.Close()
+		if p.ctx.Err() != nil {
+			return
+		}
+		p.status.deactivate(failureType{source: streamGRPC, action: "write"}, err.Error())
+		if p.lg != nil {
+			p.lg.Warn(
+				"lost gRPC streaming connection with remote peer",
+				zap.String("local-member-id", p.localID.String()),
+				zap.String("remote-peer-id", p.id.String()),
+				zap.Error(err),
+			)
+		} else {
+			plog.Warningf("lost the gRPC streaming connection with peer %s (%v)", p.id, err)
+		}
+	}
+}
+
+// dial opens a connection to the peer at u, and the stream of raft messages
+// on it. It returns once the peer accepted the stream.
+func (p *grpcPeer) dial(u url.URL) (*grpc.ClientConn, grpc.ClientStream, error) {
+	network := "tcp"
+	if u.Scheme == "unix" || u.Scheme == "unixs" {
+		network = "unix"
+	}
+	opts := []grpc.DialOption{
+		grpc.WithBlock(),
+		grpc.WithDialer(func(addr string, timeout time.Duration) (net.Conn, error) {
+			return p.tr.AllowedNetworks.Check(net.DialTimeout(network, addr, timeout))
+		}),
+	}
+	if u.Scheme == "https" || u.Scheme == "unixs" {
+		cfg, err := p.tr.TLSInfo.ClientConfig()
+		if err != nil {
+			return nil, nil, err
+		}
+		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(cfg)))
+	} else {
+		opts = append(opts, grpc.WithInsecure())
+	}
+
+	dctx, cancel := context.WithCancel(p.ctx)
+	if p.tr.DialTimeout > 0 {
+		dctx, cancel = context.WithTimeout(p.ctx, p.tr.DialTimeout)
+	}
+	conn, err := grpc.DialContext(dctx, u.Host, opts...)
+	cancel()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	md := metadata.Pairs(
+		grpcClusterIDKey, p.tr.ClusterID.String(),
+		grpcFromKey, p.localID.String(),
+		grpcToKey, p.id.String(),
+	)
+	if len(p.tr.SharedSecret) > 0 {
+		auth, _ := peerAuth(p.tr.SharedSecret, "POST", RaftGRPCStreamPath, false, time.Now())
+		md.Set(grpcPeerAuthKey, auth)
+	}
+	s, err := conn.NewStream(metadata.NewOutgoingContext(p.ctx, md), &raftServiceDesc.Streams[0], RaftGRPCStreamPath, grpc.MaxCallSendMsgSize(math.MaxInt32))
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	ackc := make(chan error, 1)
+	go func() {
+		var ack raftpb.Message
+		ackc <- s.RecvMsg(&ack)
+	}()
+	timeout := ConnReadTimeout
+	if p.tr.RequestTimeout > 0 {
+		timeout = p.tr.RequestTimeout
+	}
+	select {
+	case err = <-ackc:
+	case <-time.After(timeout):
+		err = status.Error(codes.DeadlineExceeded, "stream not acknowledged by the remote peer")
+	}
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	return conn, s, nil
+}
+
+// stream sends the messages to the peer on s until it fails. A link
+// heartbeat is sent when there is no message, so that the timeout
+// listener of the peer does not close the connection.
+func (p *grpcPeer) stream(s grpc.ClientStream) error {
+	tickc := time.NewTicker(ConnReadTimeout / 3)
+	defer tickc.Stop()
+
+	send := func(m *raftpb.Message) error {
+		if err := s.SendMsg(m); err != nil {
+			if err == io.EOF {
+				// the status of the stream is returned on receive
+				var ack raftpb.Message
+				err = s.RecvMsg(&ack)
+			}
+			sentFailures.WithLabelValues(p.id.String()).Inc()
+			return err
+		}
+		sentBytes.WithLabelValues(p.id.String()).Add(float64(m.Size()))
+		return nil
+	}
+	for {
+		select {
+		case <-tickc.C:
+			if err := send(&linkHeartbeatMessage); err != nil {
+				return err
+			}
+		case m := <-p.msgc:
+			if err := send(&m); err != nil {
+				p.r.ReportUnreachable(m.To)
+				return err
+			}
+		case <-p.ctx.Done():
+			return p.ctx.Err()
+		}
+	}
+}
+
+func (p *grpcPeer) sendSnap(m snap.Message) {
+	go p.snapSender.send(m)
+}
+
+func (p *grpcPeer) update(urls types.URLs) {
+	p.picker.update(urls)
+}
+
+// attachOutgoingConn closes the HTTP streams opened by a peer using
+// ProtocolHTTP; the messages are only streamed over gRPC.
+func (p *grpcPeer) attachOutgoingConn(conn *outgoingConn) {
+	if p.lg != nil {
+		p.lg.Warn(
+			"rejected HTTP stream of remote peer; peers stream over gRPC",
+			zap.String("local-member-id", p.localID.String()),
+			zap.String("remote-peer-id", p.id.String()),
+		)
+	} else {
+		plog.Warningf("rejected the HTTP stream of peer %s (peers stream over gRPC)", p.id)
+	}
+	conn.Close()
+}
+
+func (p *grpcPeer) activeSince() time.Time { return p.status.activeSince() }
+func (p *grpcPeer) lastSeen() time.Time    { return p.status.lastSeen() }
+
+// Pause pauses the peer. The peer will simply drops all incoming
+// messages without returning an error.
+func (p *grpcPeer) Pause() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.paused = true
+}
+
+// Resume resumes a paused peer.
+func (p *grpcPeer) Resume() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.paused = false
+}
+
+func (p *grpcPeer) stop() {
+	if p.lg != nil {
+		p.lg.Info("stopping remote peer", zap.String("remote-peer-id", p.id.String()))
+	} else {
+		plog.Infof("stopping peer %s...", p.id)
+	}
+
+	p.cancel()
+	<-p.done
+	p.pipeline.stop()
+	p.snapSender.stop()
+
+	if p.lg != nil {
+		p.lg.Info("stopped remote peer", zap.String("remote-peer-id", p.id.String()))
+	} else {
+		plog.Infof("stopped peer %s", p.id)
+	}
+}
@@ -0,0 +1,230 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rafthttp
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	stats "go.etcd.io/etcd/etcdserver/api/v2stats"
+	"go.etcd.io/etcd/pkg/transport"
+	"go.etcd.io/etcd/pkg/types"
+	"go.etcd.io/etcd/raft/raftpb"
+
+	"github.com/soheilhy/cmux"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// grpcTLSInfo is the peer TLS of the transports streaming over gRPC, which
+// authenticates the peers by their client certificates.
+var grpcTLSInfo = transport.TLSInfo{
+	CertFile:       "../../../integration/fixtures/server.crt",
+	KeyFile:        "../../../integration/fixtures/server.key.insecure",
+	TrustedCAFile:  "../../../integration/fixtures/ca.crt",
+	ClientCertAuth: true,
+}
+
+// startGRPCTransport starts a transport streaming over gRPC, served like
+// on the peer listeners of etcd.
+func startGRPCTransport(t *testing.T, id, cid types.ID, r Raft, secret []byte) (*Transport, string, func()) {
+	tr := &Transport{
+		ID:           id,
+		ClusterID:    cid,
+		Raft:         r,
+		TLSInfo:      grpcTLSInfo,
+		SharedSecret: secret,
+		ServerStats:  newServerStats(),
+		LeaderStats:  stats.NewLeaderStats(id.String()),
+	}
+	tr.Start()
+	tr.SetProtocol(ProtocolGRPC)
+
+	cfg, err := grpcTLSInfo.ServerConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := cmux.New(tls.NewListener(ln, cfg))
+	gs := tr.GRPCServer()
+	go gs.Serve(m.Match(cmux.HTTP2HeaderField(":path", RaftGRPCStreamPath)))
+	hs := &http.Server{Handler: NewSharedSecretHandler(tr.Handler(), secret)}
+	go hs.Serve(m.Match(cmux.Any()))
+	go m.Serve()
+	stop := func() {
+		tr.Stop()
+		gs.Stop()
+		hs.Close()
+		ln.Close()
+	}
+	return tr, "https://" + ln.Addr().String(), stop
+}
+
+func waitGRPCStreamActive(p Peer) bool {
+	for i := 0; i < 1000; i++ {
+		if p.(*grpcPeer).status.isActive() {
+			return true
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return false
+}
+
+func TestGRPCSendMessage(t *testing.T) {
+	recvc1 := make(chan raftpb.Message, 1)
+	tr1, u1, stop1 := startGRPCTransport(t, 1, 1, &fakeRaft{recvc: recvc1}, nil)
+	defer stop1()
+	recvc2 := make(chan raftpb.Message, 1)
+	tr2, u2, stop2 := startGRPCTransport(t, 2, 1, &fakeRaft{recvc: recvc2}, nil)
+	defer stop2()
+
+	tr1.AddPeer(2, []string{u2})
+	tr2.AddPeer(1, []string{u1})
+	if !waitGRPCStreamActive(tr1.Get(2)) || !waitGRPCStreamActive(tr2.Get(1)) {
+		t.Fatal("gRPC streams are not active")
+	}
+
+	data := []byte("some data")
+	tests := []raftpb.Message{
+		{Type: raftpb.MsgProp, From: 1, To: 2, Entries: []raftpb.Entry{{Data: data}}},
+		{Type: raftpb.MsgApp, From: 1, To: 2, Term: 1, Index: 3, LogTerm: 0, Entries: []raftpb.Entry{{Index: 4, Term: 1, Data: data}}, Commit: 3},
+		{Type: raftpb.MsgAppResp, From: 2, To: 1, Term: 1, Index: 3},
+		{Type: raftpb.MsgVote, From: 1, To: 2, Term: 1, Index: 3, LogTerm: 0},
+		{Type: raftpb.MsgVoteResp, From: 2, To: 1, Term: 1},
+		// sent over HTTP
+		{Type: raftpb.MsgSnap, From: 1, To: 2, Term: 1, Snapshot: raftpb.Snapshot{Metadata: raftpb.SnapshotMetadata{Index: 1000, Term: 1}, Data: data}},
+		{Type: raftpb.MsgHeartbeat, From: 1, To: 2, Term: 1, Commit: 3},
+		{Type: raftpb.MsgHeartbeatResp, From: 2, To: 1, Term: 1},
+	}
+	for i, tt := range tests {
+		tr, recvc := tr1, recvc2
+		if tt.From == 2 {
+			tr, recvc = tr2, recvc1
+		}
+		tr.Send([]raftpb.Message{tt})
+		select {
+		case msg := <-recvc:
+			if !reflect.DeepEqual(msg, tt) {
+				t.Errorf("#%d: msg = %+v, want %+v", i, msg, tt)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("#%d: message not received", i)
+		}
+	}
+}
+
+// TestGRPCStreamRejected ensures the streams of the peers from another
+// cluster, without the shared secret, or without a client certificate, are
+// rejected.
+func TestGRPCStreamRejected(t *testing.T) {
+	tr2, u2, stop2 := startGRPCTransport(t, 2, 1, &fakeRaft{}, []byte("secret"))
+	defer stop2()
+	tr2.AddPeer(1, []string{"http://127.0.0.1:1"})
+	pu, err := url.Parse(u2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	noCert := transport.TLSInfo{TrustedCAFile: grpcTLSInfo.TrustedCAFile}
+	tests := []struct {
+		cid    types.ID
+		secret []byte
+		tls    transport.TLSInfo
+		wcode  codes.Code
+	}{
+		{1, []byte("secret"), grpcTLSInfo, codes.OK},
+		{1, nil, grpcTLSInfo, codes.Unauthenticated},
+		{1, []byte("wrong"), grpcTLSInfo, codes.Unauthenticated},
+		{3, []byte("secret"), grpcTLSInfo, codes.FailedPrecondition},
+		// the listener closes the connection
+		{1, []byte("secret"), noCert, codes.Unavailable},
+	}
+	for i, tt := range tests {
+		tr1 := &Transport{
+			ID:           1,
+			ClusterID:    tt.cid,
+			Raft:         &fakeRaft{},
+			TLSInfo:      tt.tls,
+			SharedSecret: tt.secret,
+			Protocol:     ProtocolGRPC,
+			DialTimeout:  time.Second,
+			ServerStats:  newServerStats(),
+			LeaderStats:  stats.NewLeaderStats("1"),
+		}
+		tr1.Start()
+		// the peer is not started, so that it does not dial on its own
+		p := &grpcPeer{tr: tr1, localID: 1, id: 2, ctx: context.Background(), msgc: make(chan raftpb.Message)}
+		conn, _, err := p.dial(*pu)
+		if code := status.Code(err); code != tt.wcode {
+			t.Errorf("#%d: code = %v, want %v (%v)", i, code, tt.wcode, err)
+		}
+		if conn != nil {
+			conn.Close()
+		}
+		tr1.Stop()
+	}
+}
+
+// TestTransportSetProtocol ensures the peers are restarted with the protocol
+// set, and that gRPC is only used with client certificate authentication.
+func TestTransportSetProtocol(t *testing.T) {
+	tests := []struct {
+		tls   transport.TLSInfo
+		wgrpc bool
+	}{
+		{grpcTLSInfo, true},
+		{transport.TLSInfo{}, false},
+	}
+	for i, tt := range tests {
+		tr := &Transport{
+			ID:          1,
+			ClusterID:   1,
+			Raft:        &fakeRaft{},
+			TLSInfo:     tt.tls,
+			ServerStats: newServerStats(),
+			LeaderStats: stats.NewLeaderStats("1"),
+		}
+		tr.Start()
+		tr.AddPeer(2, []string{"https://127.0.0.1:1"})
+		if _, ok := tr.Get(2).(*peer); !ok {
+			t.Errorf("#%d: peer = %T, want *peer", i, tr.Get(2))
+		}
+		if gs := tr.GRPCServer(); (gs != nil) != tt.wgrpc {
+			t.Errorf("#%d: gRPC server = %v, want served %v", i, gs, tt.wgrpc)
+		}
+
+		tr.SetProtocol(ProtocolGRPC)
+		if _, ok := tr.Get(2).(*grpcPeer); ok != tt.wgrpc {
+			t.Errorf("#%d: peer = %T, want over gRPC %v", i, tr.Get(2), tt.wgrpc)
+		}
+		tr.SetProtocol(ProtocolHTTP)
+		if _, ok := tr.Get(2).(*peer); !ok {
+			t.Errorf("#%d: peer = %T, want *peer", i, tr.Get(2))
+		}
+		if tr.Get(2) == nil || len(tr.LeaderStats.Followers) != 1 {
+			t.Errorf("#%d: followers = %v, want peer 2", i, tr.LeaderStats.Followers)
+		}
+		tr.Stop()
+	}
+}
@@ -18,13 +18,13 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"net/http"
 	"path"
 	"strings"
 	"time"
 
 	"go.etcd.io/etcd/etcdserver/api/snap"
+	stats "go.etcd.io/etcd/etcdserver/api/v2stats"
 	pioutil "go.etcd.io/etcd/pkg/ioutil"
 	"go.etcd.io/etcd/pkg/types"
 	"go.etcd.io/etcd/raft/raftpb"
@@ -92,6 +92,8 @@ func (h *pipelineHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	w.Header().Set("X-Server-Version", version.Version)
+	w.Header().Set("X-Min-Cluster-Version", version.MinClusterVersion)
 	w.Header().Set("X-Etcd-Cluster-ID", h.cid.String())
 
 	if err := checkClusterCompatibilityFromHeader(h.lg, h.localID, r.Header, h.cid); err != nil {
@@ -104,7 +106,9 @@ func (h *pipelineHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// Limit the data size that could be read from the request body, which ensures that read from
 	// connection will not time out accidentally due to possible blocking in underlying implementation.
 	limitedr := pioutil.NewLimitedBufferReader(r.Body, connReadLimitByte)
-	b, err := ioutil.ReadAll(limitedr)
+	buf := getRecvBytesBuf()
+	defer putRecvBytesBuf(buf)
+	_, err := buf.ReadFrom(limitedr)
 	if err != nil {
 		if h.lg != nil {
 			h.lg.Warn(
@@ -121,7 +125,7 @@ func (h *pipelineHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var m raftpb.Message
-	if err := m.Unmarshal(b); err != nil {
+	if err := m.Unmarshal(buf.Bytes()); err != nil {
 		if h.lg != nil {
 			h.lg.Warn(
 				"failed to unmarshal Raft message",
@@ -136,7 +140,7 @@ func (h *pipelineHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	receivedBytes.WithLabelValues(types.ID(m.From).String()).Add(float64(len(b)))
+	receivedBytes.WithLabelValues(types.ID(m.From).String()).Add(float64(buf.Len()))
 
 	if err := h.r.Process(context.TODO(), m); err != nil {
 		switch v := err.(type) {
@@ -170,6 +174,7 @@ type snapshotHandler struct {
 	tr          Transporter
 	r           Raft
 	snapshotter *snap.Snapshotter
+	stats       *stats.ServerStats
 
 	localID types.ID
 	cid     types.ID
@@ -181,6 +186,7 @@ func newSnapshotHandler(t *Transport, r Raft, snapshotter *snap.Snapshotter, cid
 		tr:          t,
 		r:           r,
 		snapshotter: snapshotter,
+		stats:       t.ServerStats,
 		localID:     t.ID,
 		cid:         cid,
 	}
@@ -207,6 +213,8 @@ func (h *snapshotHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	w.Header().Set("X-Server-Version", version.Version)
+	w.Header().Set("X-Min-Cluster-Version", version.MinClusterVersion)
 	w.Header().Set("X-Etcd-Cluster-ID", h.cid.String())
 
 	if err := checkClusterCompatibilityFromHeader(h.lg, h.localID, r.Header, h.cid); err != nil {
@@ -272,7 +280,9 @@ func (h *snapshotHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// save incoming database snapshot.
-	n, err := h.snapshotter.SaveDBFrom(r.Body, m.Snapshot.Metadata.Index)
+	pr := newProgressReader(r.Body, h.lg, h.stats, h.localID, types.ID(m.From), m.Snapshot.Metadata.Index, snapshotSizeFromHeader(r.Header))
+	n, err := h.snapshotter.SaveDBFrom(pr, m.Snapshot.Metadata.Index)
+	pr.end(err)
 	if err != nil {
 		msg := fmt.Sprintf("failed to save KV snapshot (%v)", err)
 		if h.lg != nil {
@@ -366,6 +376,7 @@ func (h *streamHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("X-Server-Version", version.Version)
+	w.Header().Set("X-Min-Cluster-Version", version.MinClusterVersion)
 	w.Header().Set("X-Etcd-Cluster-ID", h.cid.String())
 
 	if err := checkClusterCompatibilityFromHeader(h.lg, h.tr.ID, r.Header, h.cid); err != nil {
@@ -379,6 +390,8 @@ func (h *streamHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		t = streamTypeMsgAppV2
 	case streamTypeMessage.endpoint():
 		t = streamTypeMessage
+	case streamTypeHeartbeat.endpoint():
+		t = streamTypeHeartbeat
 	default:
 		if h.lg != nil {
 			h.lg.Debug(
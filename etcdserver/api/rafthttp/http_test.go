@@ -185,6 +185,10 @@ func TestServeRaftStreamPrefix(t *testing.T) {
 			RaftStreamPrefix + "/msgapp/1",
 			streamTypeMsgAppV2,
 		},
+		{
+			RaftStreamPrefix + "/heartbeat/1",
+			streamTypeHeartbeat,
+		},
 	}
 	for i, tt := range tests {
 		req, err := http.NewRequest("GET", "http://localhost:2380"+tt.path, nil)
@@ -389,6 +393,7 @@ func (pr *fakePeer) sendSnap(m snap.Message) {
 func (pr *fakePeer) update(urls types.URLs)                { pr.peerURLs = urls }
 func (pr *fakePeer) attachOutgoingConn(conn *outgoingConn) { pr.connc <- conn }
 func (pr *fakePeer) activeSince() time.Time                { return time.Time{} }
+func (pr *fakePeer) lastSeen() time.Time                   { return time.Time{} }
 func (pr *fakePeer) stop()                                 {}
 func (pr *fakePeer) Pause()                                { pr.paused = true }
 func (pr *fakePeer) Resume()                               { pr.paused = false }
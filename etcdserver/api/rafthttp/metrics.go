@@ -71,6 +71,24 @@ var (
 		[]string{"From"},
 	)
 
+	sentDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "etcd",
+		Subsystem: "network",
+		Name:      "peer_sent_dropped_messages_total",
+		Help:      "The total number of messages to peers dropped since the sending buffer is full.",
+	},
+		[]string{"To", "Type"},
+	)
+
+	recvDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "etcd",
+		Subsystem: "network",
+		Name:      "peer_received_dropped_messages_total",
+		Help:      "The total number of messages from peers dropped since the receiving buffer is full.",
+	},
+		[]string{"From", "Type"},
+	)
+
 	snapshotSend = prometheus.NewCounterVec(prometheus.CounterOpts{
 		Namespace: "etcd",
 		Subsystem: "network",
@@ -154,6 +172,8 @@ func init() {
 	prometheus.MustRegister(receivedBytes)
 	prometheus.MustRegister(sentFailures)
 	prometheus.MustRegister(recvFailures)
+	prometheus.MustRegister(sentDropped)
+	prometheus.MustRegister(recvDropped)
 
 	prometheus.MustRegister(snapshotSend)
 	prometheus.MustRegister(snapshotSendFailures)
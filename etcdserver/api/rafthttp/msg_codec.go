@@ -40,6 +40,8 @@ func (enc *messageEncoder) encode(m *raftpb.Message) error {
 // messageDecoder is a decoder that can decode all kinds of messages.
 type messageDecoder struct {
 	r io.Reader
+	// lbuf receives the length prefixes, without allocating per message.
+	lbuf [8]byte
 }
 
 var (
@@ -53,16 +55,17 @@ func (dec *messageDecoder) decode() (raftpb.Message, error) {
 
 func (dec *messageDecoder) decodeLimit(numBytes uint64) (raftpb.Message, error) {
 	var m raftpb.Message
-	var l uint64
-	if err := binary.Read(dec.r, binary.BigEndian, &l); err != nil {
+	if _, err := io.ReadFull(dec.r, dec.lbuf[:]); err != nil {
 		return m, err
 	}
+	l := binary.BigEndian.Uint64(dec.lbuf[:])
 	if l > numBytes {
 		return m, ErrExceedSizeLimit
 	}
-	buf := make([]byte, int(l))
-	if _, err := io.ReadFull(dec.r, buf); err != nil {
+	bp := getRecvBuf(int(l))
+	defer putRecvBuf(bp)
+	if _, err := io.ReadFull(dec.r, *bp); err != nil {
 		return m, err
 	}
-	return m, m.Unmarshal(buf)
+	return m, m.Unmarshal(*bp)
 }
@@ -94,3 +94,60 @@ func TestMessage(t *testing.T) {
 		}
 	}
 }
+
+// TestMessageDecodePooledBuffers ensures the decoded messages do not alias
+// the pooled buffers they were received in.
+func TestMessageDecodePooledBuffers(t *testing.T) {
+	b := &bytes.Buffer{}
+	enc := &messageEncoder{w: b}
+	var msgs []raftpb.Message
+	for i := 0; i < 10; i++ {
+		m := raftpb.Message{
+			Type:    raftpb.MsgApp,
+			From:    1,
+			To:      2,
+			Index:   uint64(i),
+			Entries: []raftpb.Entry{{Index: uint64(i + 1), Data: bytes.Repeat([]byte{byte('a' + i)}, 100*(i+1))}},
+		}
+		if err := enc.encode(&m); err != nil {
+			t.Fatal(err)
+		}
+		msgs = append(msgs, m)
+	}
+
+	dec := &messageDecoder{r: b}
+	var got []raftpb.Message
+	for range msgs {
+		m, err := dec.decode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, m)
+	}
+	if !reflect.DeepEqual(got, msgs) {
+		t.Errorf("decoded messages differ from the encoded ones")
+	}
+}
+
+func BenchmarkMessageDecode(b *testing.B) {
+	m := raftpb.Message{
+		Type:    raftpb.MsgApp,
+		From:    1,
+		To:      2,
+		Entries: []raftpb.Entry{{Index: 1, Data: make([]byte, 1024)}, {Index: 2, Data: make([]byte, 1024)}},
+	}
+	buf := &bytes.Buffer{}
+	enc := &messageEncoder{w: buf}
+	for i := 0; i < b.N; i++ {
+		enc.encode(&m)
+	}
+	dec := &messageDecoder{r: buf}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := dec.decode(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
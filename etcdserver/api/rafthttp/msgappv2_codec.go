@@ -204,21 +204,23 @@ func (dec *msgAppV2Decoder) decode() (raftpb.Message, error) {
 				return m, err
 			}
 			size := binary.BigEndian.Uint64(dec.uint64buf)
-			var buf []byte
 			if size < msgAppV2BufSize {
-				buf = dec.buf[:size]
+				buf := dec.buf[:size]
 				if _, err := io.ReadFull(dec.r, buf); err != nil {
 					return m, err
 				}
+				// 1 alloc
+				pbutil.MustUnmarshal(&m.Entries[i], buf)
 			} else {
-				buf = make([]byte, int(size))
-				if _, err := io.ReadFull(dec.r, buf); err != nil {
+				bp := getRecvBuf(int(size))
+				if _, err := io.ReadFull(dec.r, *bp); err != nil {
+					putRecvBuf(bp)
 					return m, err
 				}
+				pbutil.MustUnmarshal(&m.Entries[i], *bp)
+				putRecvBuf(bp)
 			}
 			dec.index++
-			// 1 alloc
-			pbutil.MustUnmarshal(&m.Entries[i], buf)
 		}
 		// decode commit index
 		if _, err := io.ReadFull(dec.r, dec.uint64buf); err != nil {
@@ -226,15 +228,16 @@ func (dec *msgAppV2Decoder) decode() (raftpb.Message, error) {
 		}
 		m.Commit = binary.BigEndian.Uint64(dec.uint64buf)
 	case msgTypeApp:
-		var size uint64
-		if err := binary.Read(dec.r, binary.BigEndian, &size); err != nil {
+		if _, err := io.ReadFull(dec.r, dec.uint64buf); err != nil {
 			return m, err
 		}
-		buf := make([]byte, int(size))
-		if _, err := io.ReadFull(dec.r, buf); err != nil {
+		bp := getRecvBuf(int(binary.BigEndian.Uint64(dec.uint64buf)))
+		if _, err := io.ReadFull(dec.r, *bp); err != nil {
+			putRecvBuf(bp)
 			return m, err
 		}
-		pbutil.MustUnmarshal(&m, buf)
+		pbutil.MustUnmarshal(&m, *bp)
+		putRecvBuf(bp)
 
 		dec.term = m.Term
 		dec.index = m.Index
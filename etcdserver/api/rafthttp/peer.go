@@ -49,10 +49,11 @@ const (
 	// to hold all proposals.
 	maxPendingProposals = 4096
 
-	streamAppV2 = "streamMsgAppV2"
-	streamMsg   = "streamMsg"
-	pipelineMsg = "pipeline"
-	sendSnap    = "sendMsgSnap"
+	streamAppV2     = "streamMsgAppV2"
+	streamMsg       = "streamMsg"
+	streamHeartbeat = "streamHeartbeat"
+	pipelineMsg     = "pipeline"
+	sendSnap        = "sendMsgSnap"
 )
 
 type Peer interface {
@@ -77,6 +78,9 @@ type Peer interface {
 	// activeSince returns the time that the connection with the
 	// peer becomes active.
 	activeSince() time.Time
+	// lastSeen returns the time the connection with the peer was
+	// last active.
+	lastSeen() time.Time
 	// stop performs any necessary finalization and terminates the peer
 	// elegantly.
 	stop()
@@ -90,7 +94,9 @@ type Peer interface {
 // is always open to transfer messages. Besides general stream, peer also has
 // a optimized stream for sending msgApp since msgApp accounts for large part
 // of all messages. Only raft leader uses the optimized stream to send msgApp
-// to the remote follower node.
+// to the remote follower node. The heartbeats and the votes are sent on a
+// stream of their own, so that they are not delayed by large messages and
+// the followers do not start spurious elections.
 // A pipeline is a series of http clients that send http requests to the remote.
 // It is only used when the stream has not been established.
 type peer struct {
@@ -106,12 +112,14 @@ type peer struct {
 
 	picker *urlPicker
 
-	msgAppV2Writer *streamWriter
-	writer         *streamWriter
-	pipeline       *pipeline
-	snapSender     *snapshotSender // snapshot sender to send v3 snapshot messages
-	msgAppV2Reader *streamReader
-	msgAppReader   *streamReader
+	msgAppV2Writer  *streamWriter
+	writer          *streamWriter
+	heartbeatWriter *streamWriter
+	pipeline        *pipeline
+	snapSender      *snapshotSender // snapshot sender to send v3 snapshot messages
+	msgAppV2Reader  *streamReader
+	msgAppReader    *streamReader
+	heartbeatReader *streamReader
 
 	recvc chan raftpb.Message
 	propc chan raftpb.Message
@@ -153,19 +161,20 @@ func startPeer(t *Transport, urls types.URLs, peerID types.ID, fs *stats.Followe
 	pipeline.start()
 
 	p := &peer{
-		lg:             t.Logger,
-		localID:        t.ID,
-		id:             peerID,
-		r:              r,
-		status:         status,
-		picker:         picker,
-		msgAppV2Writer: startStreamWriter(t.Logger, t.ID, peerID, status, fs, r),
-		writer:         startStreamWriter(t.Logger, t.ID, peerID, status, fs, r),
-		pipeline:       pipeline,
-		snapSender:     newSnapshotSender(t, picker, peerID, status),
-		recvc:          make(chan raftpb.Message, recvBufSize),
-		propc:          make(chan raftpb.Message, maxPendingProposals),
-		stopc:          make(chan struct{}),
+		lg:              t.Logger,
+		localID:         t.ID,
+		id:              peerID,
+		r:               r,
+		status:          status,
+		picker:          picker,
+		msgAppV2Writer:  startStreamWriter(t.Logger, t.ID, peerID, status, fs, r),
+		writer:          startStreamWriter(t.Logger, t.ID, peerID, status, fs, r),
+		heartbeatWriter: startStreamWriter(t.Logger, t.ID, peerID, status, fs, r),
+		pipeline:        pipeline,
+		snapSender:      newSnapshotSender(t, picker, peerID, status),
+		recvc:           make(chan raftpb.Message, recvBufSize),
+		propc:           make(chan raftpb.Message, maxPendingProposals),
+		stopc:           make(chan struct{}),
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
@@ -225,9 +234,21 @@ func startPeer(t *Transport, urls types.URLs, peerID types.ID, fs *stats.Followe
 		propc:  p.propc,
 		rl:     rate.NewLimiter(t.DialRetryFrequency, 1),
 	}
+	p.heartbeatReader = &streamReader{
+		lg:     t.Logger,
+		peerID: peerID,
+		typ:    streamTypeHeartbeat,
+		tr:     t,
+		picker: picker,
+		status: status,
+		recvc:  p.recvc,
+		propc:  p.propc,
+		rl:     rate.NewLimiter(t.DialRetryFrequency, 1),
+	}
 
 	p.msgAppV2Reader.start()
 	p.msgAppReader.start()
+	p.heartbeatReader.start()
 
 	return p
 }
@@ -245,39 +266,50 @@ func (p *peer) send(m raftpb.Message) {
 	select {
 	case writec <- m:
 	default:
-		p.r.ReportUnreachable(m.To)
-		if isMsgSnap(m) {
-			p.r.ReportSnapshot(m.To, raft.SnapshotFailure)
+		reportSendDropped(p.lg, p.r, p.status, p.pipeline.followerStats, p.localID, p.id, m, name)
+	}
+}
+
+// reportSendDropped reports to raft a message to peerID dropped because
+// the named sending buffer is full, and counts it in the follower stats
+// of the peer.
+func reportSendDropped(lg *zap.Logger, r Raft, status *peerStatus, fs *stats.FollowerStats, localID, peerID types.ID, m raftpb.Message, name string) {
+	r.ReportUnreachable(m.To)
+	if isMsgSnap(m) {
+		r.ReportSnapshot(m.To, raft.SnapshotFailure)
+	}
+	if status.isActive() {
+		if lg != nil {
+			lg.Warn(
+				"dropped internal Raft message since sending buffer is full (overloaded network)",
+				zap.String("message-type", m.Type.String()),
+				zap.String("local-member-id", localID.String()),
+				zap.String("from", types.ID(m.From).String()),
+				zap.String("remote-peer-id", peerID.String()),
+				zap.Bool("remote-peer-active", status.isActive()),
+			)
+		} else {
+			plog.MergeWarningf("dropped internal raft message to %s since %s's sending buffer is full (bad/overloaded network)", peerID, name)
 		}
-		if p.status.isActive() {
-			if p.lg != nil {
-				p.lg.Warn(
-					"dropped internal Raft message since sending buffer is full (overloaded network)",
-					zap.String("message-type", m.Type.String()),
-					zap.String("local-member-id", p.localID.String()),
-					zap.String("from", types.ID(m.From).String()),
-					zap.String("remote-peer-id", p.id.String()),
-					zap.Bool("remote-peer-active", p.status.isActive()),
-				)
-			} else {
-				plog.MergeWarningf("dropped internal raft message to %s since %s's sending buffer is full (bad/overloaded network)", p.id, name)
-			}
+	} else {
+		if lg != nil {
+			lg.Warn(
+				"dropped internal Raft message since sending buffer is full (overloaded network)",
+				zap.String("message-type", m.Type.String()),
+				zap.String("local-member-id", localID.String()),
+				zap.String("from", types.ID(m.From).String()),
+				zap.String("remote-peer-id", peerID.String()),
+				zap.Bool("remote-peer-active", status.isActive()),
+			)
 		} else {
-			if p.lg != nil {
-				p.lg.Warn(
-					"dropped internal Raft message since sending buffer is full (overloaded network)",
-					zap.String("message-type", m.Type.String()),
-					zap.String("local-member-id", p.localID.String()),
-					zap.String("from", types.ID(m.From).String()),
-					zap.String("remote-peer-id", p.id.String()),
-					zap.Bool("remote-peer-active", p.status.isActive()),
-				)
-			} else {
-				plog.Debugf("dropped %s to %s since %s's sending buffer is full", m.Type, p.id, name)
-			}
+			plog.Debugf("dropped %s to %s since %s's sending buffer is full", m.Type, peerID, name)
 		}
-		sentFailures.WithLabelValues(types.ID(m.To).String()).Inc()
 	}
+	if fs != nil {
+		fs.Drop()
+	}
+	sentFailures.WithLabelValues(types.ID(m.To).String()).Inc()
+	sentDropped.WithLabelValues(types.ID(m.To).String(), m.Type.String()).Inc()
 }
 
 func (p *peer) sendSnap(m snap.Message) {
@@ -295,6 +327,8 @@ func (p *peer) attachOutgoingConn(conn *outgoingConn) {
 		ok = p.msgAppV2Writer.attach(conn)
 	case streamTypeMessage:
 		ok = p.writer.attach(conn)
+	case streamTypeHeartbeat:
+		ok = p.heartbeatWriter.attach(conn)
 	default:
 		if p.lg != nil {
 			p.lg.Panic("unknown stream type", zap.String("type", conn.t.String()))
@@ -309,6 +343,8 @@ func (p *peer) attachOutgoingConn(conn *outgoingConn) {
 
 func (p *peer) activeSince() time.Time { return p.status.activeSince() }
 
+func (p *peer) lastSeen() time.Time { return p.status.lastSeen() }
+
 // Pause pauses the peer. The peer will simply drops all incoming
 // messages without returning an error.
 func (p *peer) Pause() {
@@ -317,6 +353,7 @@ func (p *peer) Pause() {
 	p.paused = true
 	p.msgAppReader.pause()
 	p.msgAppV2Reader.pause()
+	p.heartbeatReader.pause()
 }
 
 // Resume resumes a paused peer.
@@ -326,6 +363,7 @@ func (p *peer) Resume() {
 	p.paused = false
 	p.msgAppReader.resume()
 	p.msgAppV2Reader.resume()
+	p.heartbeatReader.resume()
 }
 
 func (p *peer) stop() {
@@ -347,10 +385,12 @@ func (p *peer) stop() {
 	p.cancel()
 	p.msgAppV2Writer.stop()
 	p.writer.stop()
+	p.heartbeatWriter.stop()
 	p.pipeline.stop()
 	p.snapSender.stop()
 	p.msgAppV2Reader.stop()
 	p.msgAppReader.stop()
+	p.heartbeatReader.stop()
 }
 
 // pick picks a chan for sending the given message. The picked chan and the picked chan
@@ -363,6 +403,8 @@ func (p *peer) pick(m raftpb.Message) (writec chan<- raftpb.Message, picked stri
 		return p.pipeline.msgc, pipelineMsg
 	} else if writec, ok = p.msgAppV2Writer.writec(); ok && isMsgApp(m) {
 		return writec, streamAppV2
+	} else if writec, ok = p.heartbeatWriter.writec(); ok && isMsgHeartbeatOrVote(m) {
+		return writec, streamHeartbeat
 	} else if writec, ok = p.writer.writec(); ok {
 		return writec, streamMsg
 	}
@@ -372,3 +414,13 @@ func (p *peer) pick(m raftpb.Message) (writec chan<- raftpb.Message, picked stri
 func isMsgApp(m raftpb.Message) bool { return m.Type == raftpb.MsgApp }
 
 func isMsgSnap(m raftpb.Message) bool { return m.Type == raftpb.MsgSnap }
+
+func isMsgHeartbeatOrVote(m raftpb.Message) bool {
+	switch m.Type {
+	case raftpb.MsgHeartbeat, raftpb.MsgHeartbeatResp,
+		raftpb.MsgVote, raftpb.MsgVoteResp,
+		raftpb.MsgPreVote, raftpb.MsgPreVoteResp:
+		return true
+	}
+	return false
+}
@@ -37,10 +37,13 @@ type peerStatus struct {
 	mu     sync.Mutex // protect variables below
 	active bool
 	since  time.Time
+	// seen is the time the peer was last active, or was added if it
+	// never was.
+	seen time.Time
 }
 
 func newPeerStatus(lg *zap.Logger, local, id types.ID) *peerStatus {
-	return &peerStatus{lg: lg, local: local, id: id}
+	return &peerStatus{lg: lg, local: local, id: id, seen: time.Now()}
 }
 
 func (s *peerStatus) activate() {
@@ -72,6 +75,7 @@ func (s *peerStatus) deactivate(failure failureType, reason string) {
 		}
 		s.active = false
 		s.since = time.Time{}
+		s.seen = time.Now()
 
 		activePeers.WithLabelValues(s.local.String(), s.id.String()).Dec()
 		disconnectedPeers.WithLabelValues(s.local.String(), s.id.String()).Inc()
@@ -94,3 +98,12 @@ func (s *peerStatus) activeSince() time.Time {
 	defer s.mu.Unlock()
 	return s.since
 }
+
+func (s *peerStatus) lastSeen() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.active {
+		return time.Now()
+	}
+	return s.seen
+}
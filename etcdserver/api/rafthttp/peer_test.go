@@ -17,6 +17,8 @@ package rafthttp
 import (
 	"testing"
 
+	stats "go.etcd.io/etcd/etcdserver/api/v2stats"
+	"go.etcd.io/etcd/pkg/types"
 	"go.etcd.io/etcd/raft/raftpb"
 )
 
@@ -75,9 +77,10 @@ func TestPeerPick(t *testing.T) {
 	}
 	for i, tt := range tests {
 		peer := &peer{
-			msgAppV2Writer: &streamWriter{working: tt.msgappWorking},
-			writer:         &streamWriter{working: tt.messageWorking},
-			pipeline:       &pipeline{},
+			msgAppV2Writer:  &streamWriter{working: tt.msgappWorking},
+			writer:          &streamWriter{working: tt.messageWorking},
+			heartbeatWriter: &streamWriter{},
+			pipeline:        &pipeline{},
 		}
 		_, picked := peer.pick(tt.m)
 		if picked != tt.wpicked {
@@ -85,3 +88,48 @@ func TestPeerPick(t *testing.T) {
 		}
 	}
 }
+
+func TestPeerPickHeartbeat(t *testing.T) {
+	tests := []struct {
+		heartbeatWorking bool
+		m                raftpb.Message
+		wpicked          string
+	}{
+		{true, raftpb.Message{Type: raftpb.MsgHeartbeat}, streamHeartbeat},
+		{true, raftpb.Message{Type: raftpb.MsgHeartbeatResp}, streamHeartbeat},
+		{true, raftpb.Message{Type: raftpb.MsgVote}, streamHeartbeat},
+		{true, raftpb.Message{Type: raftpb.MsgPreVoteResp}, streamHeartbeat},
+		{true, raftpb.Message{Type: raftpb.MsgApp}, streamAppV2},
+		{true, raftpb.Message{Type: raftpb.MsgAppResp}, streamMsg},
+		{true, raftpb.Message{Type: raftpb.MsgSnap}, pipelineMsg},
+		// peers not serving the heartbeat stream
+		{false, raftpb.Message{Type: raftpb.MsgHeartbeat}, streamMsg},
+		{false, raftpb.Message{Type: raftpb.MsgVote}, streamMsg},
+	}
+	for i, tt := range tests {
+		peer := &peer{
+			msgAppV2Writer:  &streamWriter{working: true},
+			writer:          &streamWriter{working: true},
+			heartbeatWriter: &streamWriter{working: tt.heartbeatWorking},
+			pipeline:        &pipeline{},
+		}
+		_, picked := peer.pick(tt.m)
+		if picked != tt.wpicked {
+			t.Errorf("#%d: picked = %v, want %v", i, picked, tt.wpicked)
+		}
+	}
+}
+
+func TestReportSendDropped(t *testing.T) {
+	fs := &stats.FollowerStats{}
+	status := newPeerStatus(nil, types.ID(1), types.ID(2))
+	m := raftpb.Message{Type: raftpb.MsgApp, From: 1, To: 2}
+	for i := 0; i < 2; i++ {
+		reportSendDropped(nil, &fakeRaft{}, status, fs, types.ID(1), types.ID(2), m, streamMsg)
+	}
+	if fs.Counts.Dropped != 2 {
+		t.Errorf("dropped = %d, want 2", fs.Counts.Dropped)
+	}
+	// messages to members not tracked as followers are dropped all the same
+	reportSendDropped(nil, &fakeRaft{}, status, nil, types.ID(1), types.ID(2), m, streamMsg)
+}
@@ -214,6 +214,26 @@ func TestPipelinePostErrorc(t *testing.T) {
 	}
 }
 
+// TestPipelinePostTimeout tests that a post to a peer not answering fails
+// once the request timeout expires.
+func TestPipelinePostTimeout(t *testing.T) {
+	picker := mustNewURLPicker(t, []string{"http://localhost:2380"})
+	tp := &Transport{pipelineRt: newRoundTripperBlocker(), RequestTimeout: 10 * time.Millisecond}
+	p := startTestPipeline(tp, picker)
+	defer p.stop()
+
+	errc := make(chan error, 1)
+	go func() { errc <- p.post([]byte("some data")) }()
+	select {
+	case err := <-errc:
+		if err == nil {
+			t.Fatalf("post error = nil, want timeout error")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("post is not timed out in 1s")
+	}
+}
+
 func TestStopBlockedPipeline(t *testing.T) {
 	picker := mustNewURLPicker(t, []string{"http://localhost:2380"})
 	tp := &Transport{pipelineRt: newRoundTripperBlocker()}
@@ -83,6 +83,7 @@ func (g *remote) send(m raftpb.Message) {
 			}
 		}
 		sentFailures.WithLabelValues(types.ID(m.To).String()).Inc()
+		sentDropped.WithLabelValues(types.ID(m.To).String(), m.Type.String()).Inc()
 	}
 }
 
@@ -0,0 +1,262 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rafthttp
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// PeerAuthHeader is the header carrying the signature of a peer
+	// request when the members of a cluster share a secret.
+	PeerAuthHeader = "X-Etcd-Peer-Auth"
+	// PeerBodyAuthHeader is the trailer carrying the signature of the body
+	// of a signed peer request, computed while the body is sent.
+	PeerBodyAuthHeader = "X-Etcd-Peer-Body-Auth"
+)
+
+// maxPeerAuthSkew is the maximum difference between the time a peer
+// request was signed and the time it is verified.
+var maxPeerAuthSkew = 5 * time.Minute
+
+var errPeerBodyAuth = errors.New("rafthttp: unauthorized peer request body")
+
+// NewSharedSecretRoundTripper returns a roundTripper signing every request
+// sent through rt with secret, to be verified by a handler returned by
+// NewSharedSecretHandler on the remote peer. It returns rt if secret is
+// empty.
+func NewSharedSecretRoundTripper(rt http.RoundTripper, secret []byte) http.RoundTripper {
+	if len(secret) == 0 {
+		return rt
+	}
+	return &sharedSecretRoundTripper{rt: rt, secret: secret}
+}
+
+type idleConnectionsCloser interface {
+	CloseIdleConnections()
+}
+
+type sharedSecretRoundTripper struct {
+	rt     http.RoundTripper
+	secret []byte
+}
+
+func (s *sharedSecretRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	// a RoundTripper must not modify the given request
+	r := *req
+	r.Header = make(http.Header, len(req.Header)+1)
+	for k, vv := range req.Header {
+		r.Header[k] = append([]string(nil), vv...)
+	}
+	body := req.Body != nil && req.Body != http.NoBody
+	auth, sig := peerAuth(s.secret, r.Method, r.URL.Path, body, time.Now())
+	r.Header.Set(PeerAuthHeader, auth)
+	if body {
+		// the body is signed in a trailer as it is sent, so that it is
+		// read once; the trailers are only sent with a chunked body
+		r.ContentLength = -1
+		r.GetBody = nil
+		r.Trailer = http.Header{PeerBodyAuthHeader: nil}
+		r.Body = &signedBody{ReadCloser: req.Body, mac: newBodyMAC(s.secret, sig), trailer: r.Trailer}
+	}
+	return s.rt.RoundTrip(&r)
+}
+
+// CloseIdleConnections closes the idle connections of the wrapped
+// roundTripper, if it supports it.
+func (s *sharedSecretRoundTripper) CloseIdleConnections() {
+	if c, ok := s.rt.(idleConnectionsCloser); ok {
+		c.CloseIdleConnections()
+	}
+}
+
+// NewSharedSecretHandler returns a handler rejecting the requests that were
+// not signed with secret by a roundTripper returned by
+// NewSharedSecretRoundTripper, and passing the others to h. It returns h if
+// secret is empty.
+//
+// The signature covers the method, the path, the time and a nonce of the
+// request, and its body, if any. The nonces are remembered for as long as
+// the signatures are valid, so a captured request cannot be replayed. The
+// body is verified once read to its end, from which h gets an error if it
+// does not match its signature. Peer TLS should be preferred when the
+// network is not trusted, since the requests are not encrypted.
+func NewSharedSecretHandler(h http.Handler, secret []byte) http.Handler {
+	if len(secret) == 0 {
+		return h
+	}
+	v := newPeerAuthVerifier(secret)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := r.Body != nil && r.Body != http.NoBody
+		sig, ok := v.verify(r.Header.Get(PeerAuthHeader), r.Method, r.URL.Path, body, time.Now())
+		if !ok {
+			http.Error(w, "unauthorized peer request", http.StatusUnauthorized)
+			return
+		}
+		r = r.WithContext(context.WithValue(r.Context(), signedPeerRequestKey{}, true))
+		if body {
+			// the trailers of r are read along with the end of its body
+			r.Body = &verifiedBody{ReadCloser: r.Body, mac: newBodyMAC(secret, sig), trailer: r.Trailer}
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+type signedPeerRequestKey struct{}
+
+// IsSignedPeerRequest returns true if r was verified by a handler returned
+// by NewSharedSecretHandler, that is, if it was sent by a peer holding the
+// shared secret.
+func IsSignedPeerRequest(r *http.Request) bool {
+	signed, _ := r.Context().Value(signedPeerRequestKey{}).(bool)
+	return signed
+}
+
+func signPeerRequest(secret []byte, method, path, ts, nonce string, body bool) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(method + " " + path + " " + ts + " " + nonce))
+	if body {
+		mac.Write([]byte(" body"))
+	}
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// peerAuth returns the signature of a peer request to path, with its time
+// and a new nonce, as sent in PeerAuthHeader, along with the signature
+// alone, which the signature of the body, if any, is chained to.
+func peerAuth(secret []byte, method, path string, body bool, now time.Time) (auth, sig string) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		plog.Panicf("failed to read random nonce (%v)", err)
+	}
+	ts, nonce := strconv.FormatInt(now.Unix(), 10), hex.EncodeToString(b[:])
+	sig = signPeerRequest(secret, method, path, ts, nonce, body)
+	return ts + ":" + nonce + ":" + sig, sig
+}
+
+// peerAuthVerifier verifies the signatures of peer requests, as returned by
+// peerAuth, once each.
+type peerAuthVerifier struct {
+	secret []byte
+
+	mu sync.Mutex
+	// the nonces of the verified signatures, in two generations each
+	// spanning the time a signature is valid, so that a nonce is kept at
+	// least as long as its signature is valid
+	nonces, prevNonces map[string]struct{}
+	rotated            time.Time
+}
+
+func newPeerAuthVerifier(secret []byte) *peerAuthVerifier {
+	return &peerAuthVerifier{secret: secret}
+}
+
+// verify verifies auth, the signature of a peer request to path with its
+// time and nonce, and returns the signature alone. It returns false if the
+// signature is invalid, expired, or was already verified.
+func (v *peerAuthVerifier) verify(auth, method, path string, body bool, now time.Time) (string, bool) {
+	f := strings.SplitN(auth, ":", 3)
+	if len(f) != 3 {
+		return "", false
+	}
+	ts, nonce, sig := f[0], f[1], f[2]
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return "", false
+	}
+	if d := now.Sub(time.Unix(sec, 0)); d > maxPeerAuthSkew || d < -maxPeerAuthSkew {
+		return "", false
+	}
+	expected := signPeerRequest(v.secret, method, path, ts, nonce, body)
+	if !hmac.Equal([]byte(sig), []byte(expected)) {
+		return "", false
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if now.Sub(v.rotated) > 2*maxPeerAuthSkew {
+		v.prevNonces, v.nonces, v.rotated = v.nonces, make(map[string]struct{}), now
+	}
+	if _, ok := v.nonces[nonce]; ok {
+		return "", false
+	}
+	if _, ok := v.prevNonces[nonce]; ok {
+		return "", false
+	}
+	v.nonces[nonce] = struct{}{}
+	return sig, true
+}
+
+// newBodyMAC returns the MAC of the body of a peer request whose signature
+// is sig.
+func newBodyMAC(secret []byte, sig string) hash.Hash {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(sig))
+	return mac
+}
+
+// signedBody is the body of a signed peer request, setting its signature
+// in the trailer of the request once read to its end.
+type signedBody struct {
+	io.ReadCloser
+	mac     hash.Hash
+	trailer http.Header
+}
+
+func (b *signedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.mac.Write(p[:n])
+	if err == io.EOF {
+		b.trailer.Set(PeerBodyAuthHeader, hex.EncodeToString(b.mac.Sum(nil)))
+	}
+	return n, err
+}
+
+// verifiedBody is the body of a verified peer request, failing once read
+// to its end if it does not match the signature in the trailer.
+type verifiedBody struct {
+	io.ReadCloser
+	mac     hash.Hash
+	trailer http.Header
+	err     error
+}
+
+func (b *verifiedBody) Read(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.mac.Write(p[:n])
+	if err == io.EOF {
+		expected := hex.EncodeToString(b.mac.Sum(nil))
+		if !hmac.Equal([]byte(b.trailer.Get(PeerBodyAuthHeader)), []byte(expected)) {
+			b.err = errPeerBodyAuth
+			return n, b.err
+		}
+	}
+	return n, err
+}
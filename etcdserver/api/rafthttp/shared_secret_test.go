@@ -0,0 +1,191 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rafthttp
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSharedSecret(t *testing.T) {
+	h := NewSharedSecretHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), []byte("secret"))
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	tests := []struct {
+		secret []byte
+		wcode  int
+	}{
+		{[]byte("secret"), http.StatusOK},
+		{[]byte("other"), http.StatusUnauthorized},
+		{nil, http.StatusUnauthorized},
+	}
+	for i, tt := range tests {
+		rt := NewSharedSecretRoundTripper(&http.Transport{}, tt.secret)
+		req, err := http.NewRequest("POST", srv.URL+RaftPrefix, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := rt.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("#%d: unexpected error %v", i, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.wcode {
+			t.Errorf("#%d: code = %d, want %d", i, resp.StatusCode, tt.wcode)
+		}
+		if req.Header.Get(PeerAuthHeader) != "" {
+			t.Errorf("#%d: request passed to RoundTrip was modified", i)
+		}
+	}
+}
+
+func TestSharedSecretBody(t *testing.T) {
+	secret := []byte("secret")
+	var got []byte
+	h := NewSharedSecretHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		got = b
+	}), secret)
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	rt := NewSharedSecretRoundTripper(&http.Transport{}, secret)
+	req, err := http.NewRequest("POST", srv.URL+RaftPrefix, strings.NewReader("msg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(got) != "msg" {
+		t.Fatalf("code = %d, body = %q, want %d, %q", resp.StatusCode, got, http.StatusOK, "msg")
+	}
+
+	// the signed request, as sent
+	var signed *http.Request
+	var body []byte
+	rt = NewSharedSecretRoundTripper(roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		signed = r
+		body, _ = ioutil.ReadAll(r.Body)
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(new(bytes.Buffer))}, nil
+	}), secret)
+	if req, err = http.NewRequest("POST", "http://localhost"+RaftPrefix, strings.NewReader("msg")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = rt.RoundTrip(req); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		body    string
+		trailer http.Header
+		wcode   int
+	}{
+		{string(body), signed.Trailer, http.StatusOK},
+		// replayed
+		{string(body), signed.Trailer, http.StatusUnauthorized},
+		// another body
+		{"other", signed.Trailer, http.StatusBadRequest},
+		// no body signature
+		{string(body), nil, http.StatusBadRequest},
+	}
+	for i, tt := range tests {
+		if i > 1 {
+			// sign another request for every case past the replay
+			if _, err = rt.RoundTrip(req); err != nil {
+				t.Fatal(err)
+			}
+			if tt.trailer != nil {
+				tt.trailer = signed.Trailer
+			}
+		}
+		r := httptest.NewRequest("POST", RaftPrefix, strings.NewReader(tt.body))
+		r.Header = signed.Header
+		r.Trailer = tt.trailer
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != tt.wcode {
+			t.Errorf("#%d: code = %d, want %d", i, w.Code, tt.wcode)
+		}
+	}
+}
+
+func TestVerifyPeerRequest(t *testing.T) {
+	secret := []byte("secret")
+	now := time.Unix(1000000, 0)
+	ts := strconv.FormatInt(now.Unix(), 10)
+	old := strconv.FormatInt(now.Add(-2*maxPeerAuthSkew).Unix(), 10)
+	auth := func(method, path, ts, nonce string, body bool) string {
+		return ts + ":" + nonce + ":" + signPeerRequest(secret, method, path, ts, nonce, body)
+	}
+
+	tests := []struct {
+		method, path string
+		body         bool
+		auth         string
+		w            bool
+	}{
+		{"POST", "/raft", false, auth("POST", "/raft", ts, "n1", false), true},
+		// replayed
+		{"POST", "/raft", false, auth("POST", "/raft", ts, "n1", false), false},
+		{"POST", "/raft", true, auth("POST", "/raft", ts, "n2", true), true},
+		// signed for another path
+		{"POST", "/raft/snapshot", false, auth("POST", "/raft", ts, "n3", false), false},
+		// signed for another method
+		{"GET", "/raft", false, auth("POST", "/raft", ts, "n4", false), false},
+		// signed without a body
+		{"POST", "/raft", true, auth("POST", "/raft", ts, "n5", false), false},
+		// signed with a body
+		{"POST", "/raft", false, auth("POST", "/raft", ts, "n6", true), false},
+		// signed for another nonce
+		{"POST", "/raft", false, ts + ":n7:" + signPeerRequest(secret, "POST", "/raft", ts, "n8", false), false},
+		// signed too long ago
+		{"POST", "/raft", false, auth("POST", "/raft", old, "n9", false), false},
+		{"POST", "/raft", false, signPeerRequest(secret, "POST", "/raft", ts, "n10", false), false},
+		{"POST", "/raft", false, "", false},
+	}
+	v := newPeerAuthVerifier(secret)
+	for i, tt := range tests {
+		if _, g := v.verify(tt.auth, tt.method, tt.path, tt.body, now); g != tt.w {
+			t.Errorf("#%d: verified = %v, want %v", i, g, tt.w)
+		}
+	}
+
+	// the nonces are forgotten once their signatures expired
+	for _, d := range []time.Duration{2 * maxPeerAuthSkew, 5 * maxPeerAuthSkew} {
+		later := now.Add(d + time.Second)
+		lts := strconv.FormatInt(later.Unix(), 10)
+		if _, g := v.verify(auth("POST", "/raft", lts, "n1", false), "POST", "/raft", false, later); g != (d > 2*maxPeerAuthSkew) {
+			t.Errorf("after %v: verified = %v, want %v", d, g, d > 2*maxPeerAuthSkew)
+		}
+	}
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
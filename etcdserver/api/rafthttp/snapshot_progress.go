@@ -0,0 +1,130 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rafthttp
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	stats "go.etcd.io/etcd/etcdserver/api/v2stats"
+	"go.etcd.io/etcd/pkg/types"
+
+	humanize "github.com/dustin/go-humanize"
+	"go.uber.org/zap"
+)
+
+const (
+	// snapshotSizeHeader carries the size of the database snapshot following
+	// the raft message in the body of a snapshot request.
+	snapshotSizeHeader = "X-Etcd-Snapshot-Size"
+
+	// snapshotProgressInterval is the interval between two logs of the
+	// progress of a snapshot download.
+	snapshotProgressInterval = 10 * time.Second
+)
+
+// snapshotSizeFromHeader returns the database snapshot size given by the
+// sender, or zero if unknown, e.g. from an older member.
+func snapshotSizeFromHeader(h http.Header) int64 {
+	n, err := strconv.ParseInt(h.Get(snapshotSizeHeader), 10, 64)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
+
+// progressReader reads a database snapshot being downloaded, reporting the
+// bytes read to the server stats and logging the progress of the download
+// every snapshotProgressInterval.
+type progressReader struct {
+	r     io.Reader
+	lg    *zap.Logger
+	stats *stats.ServerStats
+
+	localID types.ID
+	from    types.ID
+	index   uint64
+	total   int64
+
+	read  int64
+	start time.Time
+	last  time.Time
+}
+
+func newProgressReader(r io.Reader, lg *zap.Logger, ss *stats.ServerStats, localID, from types.ID, index uint64, total int64) *progressReader {
+	now := time.Now()
+	if ss != nil {
+		ss.SnapshotReceiveStart(from.String(), index, total)
+	}
+	return &progressReader{
+		r:       r,
+		lg:      lg,
+		stats:   ss,
+		localID: localID,
+		from:    from,
+		index:   index,
+		total:   total,
+		start:   now,
+		last:    now,
+	}
+}
+
+func (pr *progressReader) Read(p []byte) (int, error) {
+	n, err := pr.r.Read(p)
+	pr.read += int64(n)
+	if pr.stats != nil {
+		pr.stats.SnapshotReceived(int64(n))
+	}
+	if now := time.Now(); now.Sub(pr.last) >= snapshotProgressInterval {
+		pr.last = now
+		pr.logProgress(now.Sub(pr.start))
+	}
+	return n, err
+}
+
+// end records the end of the download, failed if err is not nil.
+func (pr *progressReader) end(err error) {
+	if pr.stats != nil {
+		pr.stats.SnapshotReceiveEnd(err)
+	}
+}
+
+func (pr *progressReader) logProgress(elapsed time.Duration) {
+	rate := float64(pr.read) / elapsed.Seconds()
+	var eta time.Duration
+	if pr.total > pr.read && pr.read > 0 {
+		eta = time.Duration(float64(elapsed) * float64(pr.total-pr.read) / float64(pr.read))
+	}
+	if pr.lg != nil {
+		pr.lg.Info(
+			"receiving database snapshot in progress",
+			zap.String("local-member-id", pr.localID.String()),
+			zap.String("remote-snapshot-sender-id", pr.from.String()),
+			zap.Uint64("incoming-snapshot-index", pr.index),
+			zap.Int64("received-bytes", pr.read),
+			zap.String("received-size", humanize.Bytes(uint64(pr.read))),
+			zap.Int64("total-bytes", pr.total),
+			zap.String("rate", humanize.Bytes(uint64(rate))+"/s"),
+			zap.Duration("took", elapsed),
+			zap.Duration("eta", eta),
+		)
+	} else if pr.total > 0 {
+		plog.Infof("receiving database snapshot [index: %d, from: %s]: %s of %s at %s/s, about %v left", pr.index, pr.from, humanize.Bytes(uint64(pr.read)), humanize.Bytes(uint64(pr.total)), humanize.Bytes(uint64(rate)), eta.Round(time.Second))
+	} else {
+		plog.Infof("receiving database snapshot [index: %d, from: %s]: %s at %s/s", pr.index, pr.from, humanize.Bytes(uint64(pr.read)), humanize.Bytes(uint64(rate)))
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"strconv"
 	"time"
 
 	"go.etcd.io/etcd/etcdserver/api/snap"
@@ -77,6 +78,7 @@ func (s *snapshotSender) send(merged snap.Message) {
 
 	u := s.picker.pick()
 	req := createPostRequest(u, RaftSnapshotPrefix, body, "application/octet-stream", s.tr.URLs, s.from, s.cid)
+	req.Header.Set(snapshotSizeHeader, strconv.FormatInt(merged.TotalSize-int64(m.Size()), 10))
 
 	if s.tr.Logger != nil {
 		s.tr.Logger.Info(
@@ -168,7 +170,11 @@ func (s *snapshotSender) post(req *http.Request) (err error) {
 		// close the response body when timeouts.
 		// prevents from reading the body forever when the other side dies right after
 		// successfully receives the request body.
-		time.AfterFunc(snapResponseReadTimeout, func() { httputil.GracefulClose(resp) })
+		timeout := snapResponseReadTimeout
+		if s.tr.RequestTimeout > 0 {
+			timeout = s.tr.RequestTimeout
+		}
+		time.AfterFunc(timeout, func() { httputil.GracefulClose(resp) })
 		body, err := ioutil.ReadAll(resp.Body)
 		result <- responseAndError{resp, body, err}
 	}()
@@ -26,6 +26,7 @@ import (
 	"time"
 
 	"go.etcd.io/etcd/etcdserver/api/snap"
+	stats "go.etcd.io/etcd/etcdserver/api/v2stats"
 	"go.etcd.io/etcd/pkg/types"
 	"go.etcd.io/etcd/raft/raftpb"
 
@@ -131,6 +132,64 @@ func testSnapshotSend(t *testing.T, sm *snap.Message) (bool, []os.FileInfo) {
 	return sent, files
 }
 
+func TestSnapshotSendProgress(t *testing.T) {
+	d, err := ioutil.TempDir(os.TempDir(), "snapdir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(d)
+
+	r := &fakeRaft{}
+	ss := stats.NewServerStats("", "")
+	tr := &Transport{pipelineRt: &http.Transport{}, ClusterID: types.ID(1), Raft: r, ServerStats: ss}
+	ch := make(chan struct{}, 1)
+	h := &syncHandler{newSnapshotHandler(tr, r, snap.New(zap.NewExample(), d), types.ID(1)), ch}
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	picker := mustNewURLPicker(t, []string{srv.URL})
+	snapsend := newSnapshotSender(tr, picker, types.ID(1), newPeerStatus(zap.NewExample(), types.ID(0), types.ID(1)))
+	defer snapsend.stop()
+
+	m := raftpb.Message{Type: raftpb.MsgSnap, From: 2, To: 1, Snapshot: raftpb.Snapshot{Metadata: raftpb.SnapshotMetadata{Index: 10}}}
+	sm := snap.NewMessage(m, strReaderCloser{strings.NewReader("hello")}, 5)
+	snapsend.send(*sm)
+	select {
+	case <-time.After(time.Second):
+		t.Fatalf("timed out sending snapshot")
+	case <-sm.CloseNotify():
+	}
+	<-ch
+
+	sp, ok := ss.SnapshotProgress()
+	if !ok {
+		t.Fatal("expected snapshot progress")
+	}
+	if sp.Stage != stats.SnapshotInstalling {
+		t.Errorf("stage = %q, want %q", sp.Stage, stats.SnapshotInstalling)
+	}
+	if sp.From != types.ID(2).String() || sp.Index != 10 {
+		t.Errorf("from, index = %s, %d, want %s, 10", sp.From, sp.Index, types.ID(2))
+	}
+	if sp.BytesReceived != 5 || sp.BytesTotal != 5 {
+		t.Errorf("bytes received, total = %d, %d, want 5, 5", sp.BytesReceived, sp.BytesTotal)
+	}
+
+	ss.SnapshotInstalled(10)
+	if ss.SnapshotApplyProgress(12, 15) {
+		t.Error("caught up with 3 entries pending")
+	}
+	if sp, _ = ss.SnapshotProgress(); sp.EntriesApplied != 2 || sp.EntriesPending != 3 {
+		t.Errorf("entries applied, pending = %d, %d, want 2, 3", sp.EntriesApplied, sp.EntriesPending)
+	}
+	if !ss.SnapshotApplyProgress(15, 15) {
+		t.Error("not caught up with all entries applied")
+	}
+	if sp, _ = ss.SnapshotProgress(); sp.Stage != stats.SnapshotDone {
+		t.Errorf("stage = %q, want %q", sp.Stage, stats.SnapshotDone)
+	}
+}
+
 type errReadCloser struct{ err error }
 
 func (s *errReadCloser) Read(p []byte) (int, error) { return 0, s.err }
@@ -40,6 +40,9 @@ import (
 const (
 	streamTypeMessage  streamType = "message"
 	streamTypeMsgAppV2 streamType = "msgappv2"
+	// streamTypeHeartbeat carries the messages that keep the leadership,
+	// so that they are not queued behind large appends.
+	streamTypeHeartbeat streamType = "heartbeat"
 
 	streamBufSize = 4096
 )
@@ -68,6 +71,8 @@ func (t streamType) endpoint() string {
 		return path.Join(RaftStreamPrefix, "msgapp")
 	case streamTypeMessage:
 		return path.Join(RaftStreamPrefix, "message")
+	case streamTypeHeartbeat:
+		return path.Join(RaftStreamPrefix, "heartbeat")
 	default:
 		plog.Panicf("unhandled stream type %v", t)
 		return ""
@@ -80,6 +85,8 @@ func (t streamType) String() string {
 		return "stream MsgApp v2"
 	case streamTypeMessage:
 		return "stream Message"
+	case streamTypeHeartbeat:
+		return "stream Heartbeat"
 	default:
 		return "unknown stream"
 	}
@@ -240,7 +247,7 @@ func (cw *streamWriter) run() {
 			switch conn.t {
 			case streamTypeMsgAppV2:
 				enc = newMsgAppV2Encoder(conn.Writer, cw.fs)
-			case streamTypeMessage:
+			case streamTypeMessage, streamTypeHeartbeat:
 				enc = &messageEncoder{w: conn.Writer}
 			default:
 				plog.Panicf("unhandled stream type %s", conn.t)
@@ -489,7 +496,7 @@ func (cr *streamReader) decodeLoop(rc io.ReadCloser, t streamType) error {
 	switch t {
 	case streamTypeMsgAppV2:
 		dec = newMsgAppV2Decoder(rc, cr.tr.ID, cr.peerID)
-	case streamTypeMessage:
+	case streamTypeMessage, streamTypeHeartbeat:
 		dec = &messageDecoder{r: rc}
 	default:
 		if cr.lg != nil {
@@ -575,6 +582,7 @@ func (cr *streamReader) decodeLoop(rc io.ReadCloser, t streamType) error {
 				}
 			}
 			recvFailures.WithLabelValues(types.ID(m.From).String()).Inc()
+			recvDropped.WithLabelValues(types.ID(m.From).String(), m.Type.String()).Inc()
 		}
 	}
 }
@@ -631,6 +639,11 @@ func (cr *streamReader) dial(t streamType) (io.ReadCloser, error) {
 	}
 
 	rv := serverVersion(resp.Header)
+	if rv == nil {
+		httputil.GracefulClose(resp)
+		cr.picker.unreachable(u)
+		return nil, errIncompatibleVersion
+	}
 	lv := semver.Must(semver.NewVersion(version.Version))
 	if compareMajorMinorVersion(rv, lv) == -1 && !checkStreamSupport(rv, t) {
 		httputil.GracefulClose(resp)
@@ -649,7 +662,13 @@ func (cr *streamReader) dial(t streamType) (io.ReadCloser, error) {
 		return resp.Body, nil
 
 	case http.StatusNotFound:
+		b, _ := ioutil.ReadAll(resp.Body)
 		httputil.GracefulClose(resp)
+		if t == streamTypeHeartbeat && strings.TrimSuffix(string(b), "\n") == "invalid path" {
+			// the peer does not serve the heartbeat stream, so the
+			// heartbeats are sent on the message stream
+			return nil, errUnsupportedStreamType
+		}
 		cr.picker.unreachable(u)
 		return nil, fmt.Errorf("peer %s failed to find local node %s", cr.peerID, cr.tr.ID)
 
@@ -668,11 +687,15 @@ func (cr *streamReader) dial(t streamType) (io.ReadCloser, error) {
 				cr.lg.Warn(
 					"request sent was ignored by remote peer due to server version incompatibility",
 					zap.String("local-member-id", cr.tr.ID.String()),
+					zap.String("local-member-server-version", version.Version),
 					zap.String("remote-peer-id", cr.peerID.String()),
+					zap.String("remote-peer-server-version", resp.Header.Get("X-Server-Version")),
+					zap.String("remote-peer-server-minimum-cluster-version", resp.Header.Get("X-Min-Cluster-Version")),
 					zap.Error(errIncompatibleVersion),
 				)
 			} else {
-				plog.Errorf("request sent was ignored by peer %s (server version incompatible)", cr.peerID)
+				plog.Errorf("request sent was ignored by peer %s (server version incompatible: remote=%s, local=%s)",
+					cr.peerID, resp.Header.Get("X-Server-Version"), version.Version)
 			}
 			return nil, errIncompatibleVersion
 
@@ -111,7 +111,7 @@ func TestStreamWriterAttachBadOutgoingConn(t *testing.T) {
 }
 
 func TestStreamReaderDialRequest(t *testing.T) {
-	for i, tt := range []streamType{streamTypeMessage, streamTypeMsgAppV2} {
+	for i, tt := range []streamType{streamTypeMessage, streamTypeMsgAppV2, streamTypeHeartbeat} {
 		tr := &roundTripperRecorder{rec: &testutil.RecorderBuffered{}}
 		sr := &streamReader{
 			peerID: types.ID(2),
@@ -185,6 +185,38 @@ func TestStreamReaderDialResult(t *testing.T) {
 	}
 }
 
+// TestStreamReaderDialUnsupportedHeartbeat tests that a peer which does not
+// serve the heartbeat stream is not reported as unreachable.
+func TestStreamReaderDialUnsupportedHeartbeat(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Server-Version", version.Version)
+		http.Error(w, "invalid path", http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	picker := mustNewURLPicker(t, []string{srv.URL, "http://localhost:2380"})
+	sr := &streamReader{
+		peerID: types.ID(2),
+		tr:     &Transport{streamRt: &http.Transport{}, ClusterID: types.ID(1)},
+		picker: picker,
+		errorc: make(chan error, 1),
+		ctx:    context.Background(),
+	}
+	if _, err := sr.dial(streamTypeHeartbeat); err != errUnsupportedStreamType {
+		t.Errorf("err = %v, want %v", err, errUnsupportedStreamType)
+	}
+	if picker.picked != 0 {
+		t.Errorf("picked = %d, want 0", picker.picked)
+	}
+	// other streams are expected to be served
+	if _, err := sr.dial(streamTypeMessage); err == nil || err == errUnsupportedStreamType {
+		t.Errorf("err = %v, want a dial error", err)
+	}
+	if picker.picked != 1 {
+		t.Errorf("picked = %d, want 1", picker.picked)
+	}
+}
+
 // TestStreamReaderStopOnDial tests a stream reader closes the connection on stop.
 func TestStreamReaderStopOnDial(t *testing.T) {
 	defer testutil.AfterTest(t)
@@ -298,6 +330,11 @@ func TestStream(t *testing.T) {
 			msgapp,
 			recvc,
 		},
+		{
+			streamTypeHeartbeat,
+			raftpb.Message{Type: raftpb.MsgHeartbeat, From: 2, To: 1, Term: 1},
+			recvc,
+		},
 	}
 	for i, tt := range tests {
 		h := &fakeStreamHandler{t: tt.t}
@@ -86,6 +86,11 @@ type Transporter interface {
 	// If the connection is active since peer was added, it returns the adding time.
 	// If the connection is currently inactive, it returns zero time.
 	ActiveSince(id types.ID) time.Time
+	// LastSeen returns the time that the connection with the peer of the
+	// given id was last active, which is now if it is active, or the adding
+	// time if it never was.
+	// If the peer is unknown, it returns zero time.
+	LastSeen(id types.ID) time.Time
 	// ActivePeers returns the number of active peers.
 	ActivePeers() int
 	// Stop closes the connections and stops the transporter.
@@ -102,11 +107,26 @@ type Transport struct {
 	Logger *zap.Logger
 
 	DialTimeout time.Duration // maximum duration before timing out dial of the request
+	// RequestTimeout is the maximum duration of a request sending messages
+	// to a peer, from the dial to the read of the response, or of the
+	// read of the response to a snapshot. 0 means no limit, and the
+	// snapshot responses are read for at most 5 seconds.
+	RequestTimeout time.Duration
 	// DialRetryFrequency defines the frequency of streamReader dial retrial attempts;
 	// a distinct rate limiter is created per every peer (default value: 10 events/sec)
 	DialRetryFrequency rate.Limit
 
 	TLSInfo transport.TLSInfo // TLS information used when creating connection
+	// SharedSecret, if not empty, is used to sign the requests sent to
+	// peers, which verify them with NewSharedSecretHandler.
+	SharedSecret []byte
+	// AllowedNetworks, if not empty, holds the networks of the peers that
+	// may be dialed.
+	AllowedNetworks transport.AllowedNetworks
+	// Protocol is the protocol the raft messages are sent to the peers
+	// with, ProtocolHTTP or ProtocolGRPC. It defaults to ProtocolHTTP, and
+	// is changed with SetProtocol once the transport started.
+	Protocol string
 
 	ID          types.ID   // local member ID
 	URLs        types.URLs // local peer URLs
@@ -126,6 +146,8 @@ type Transport struct {
 	streamRt   http.RoundTripper // roundTripper used by streams
 	pipelineRt http.RoundTripper // roundTripper used by pipelines
 
+	authVerifier *peerAuthVerifier // verifies the gRPC streams, with SharedSecret
+
 	mu      sync.RWMutex         // protect the remote and peer map
 	remotes map[types.ID]*remote // remotes map that helps newly joined member to catch up
 	peers   map[types.ID]Peer    // peers map
@@ -144,6 +166,13 @@ func (t *Transport) Start() error {
 	if err != nil {
 		return err
 	}
+	RestrictRoundTripper(t.streamRt, t.AllowedNetworks)
+	RestrictRoundTripper(t.pipelineRt, t.AllowedNetworks)
+	t.streamRt = NewSharedSecretRoundTripper(t.streamRt, t.SharedSecret)
+	t.pipelineRt = NewSharedSecretRoundTripper(t.pipelineRt, t.SharedSecret)
+	if len(t.SharedSecret) > 0 {
+		t.authVerifier = newPeerAuthVerifier(t.SharedSecret)
+	}
 	t.remotes = make(map[types.ID]*remote)
 	t.peers = make(map[types.ID]Peer)
 	t.pipelineProber = probing.NewProber(t.pipelineRt)
@@ -225,10 +254,10 @@ func (t *Transport) Stop() {
 	}
 	t.pipelineProber.RemoveAll()
 	t.streamProber.RemoveAll()
-	if tr, ok := t.streamRt.(*http.Transport); ok {
+	if tr, ok := t.streamRt.(idleConnectionsCloser); ok {
 		tr.CloseIdleConnections()
 	}
-	if tr, ok := t.pipelineRt.(*http.Transport); ok {
+	if tr, ok := t.pipelineRt.(idleConnectionsCloser); ok {
 		tr.CloseIdleConnections()
 	}
 	t.peers = nil
@@ -318,10 +347,7 @@ func (t *Transport) AddPeer(id types.ID, us []string) {
 			plog.Panicf("newURLs %+v should never fail: %+v", us, err)
 		}
 	}
-	fs := t.LeaderStats.Follower(id.String())
-	t.peers[id] = startPeer(t, urls, id, fs)
-	addPeerToProber(t.Logger, t.pipelineProber, id.String(), us, RoundTripperNameSnapshot, rttSec)
-	addPeerToProber(t.Logger, t.streamProber, id.String(), us, RoundTripperNameRaftMessage, rttSec)
+	t.addPeer(id, urls)
 
 	if t.Logger != nil {
 		t.Logger.Info(
@@ -335,6 +361,74 @@ func (t *Transport) AddPeer(id types.ID, us []string) {
 	}
 }
 
+// the caller of this function must have the peers mutex.
+func (t *Transport) addPeer(id types.ID, urls types.URLs) {
+	fs := t.LeaderStats.Follower(id.String())
+	if t.Protocol == ProtocolGRPC {
+		t.peers[id] = startGRPCPeer(t, urls, id, fs)
+	} else {
+		t.peers[id] = startPeer(t, urls, id, fs)
+	}
+	addPeerToProber(t.Logger, t.pipelineProber, id.String(), urls.StringSlice(), RoundTripperNameSnapshot, rttSec)
+	addPeerToProber(t.Logger, t.streamProber, id.String(), urls.StringSlice(), RoundTripperNameRaftMessage, rttSec)
+}
+
+// SetProtocol sets the protocol the raft messages are sent to the peers
+// with, restarting the peers sending with another one. ProtocolGRPC is only
+// used when the peers authenticate with TLS client certificates, which
+// authenticate the gRPC streams; ProtocolHTTP is used otherwise. While the
+// members switch, the messages the streams refuse are sent over the
+// pipelines.
+func (t *Transport) SetProtocol(protocol string) {
+	if protocol != ProtocolGRPC {
+		protocol = ProtocolHTTP
+	} else if !t.TLSInfo.ClientCertAuth {
+		if t.Logger != nil {
+			t.Logger.Warn(
+				"ignored gRPC peer transport without peer client certificate authentication",
+				zap.String("local-member-id", t.ID.String()),
+			)
+		} else {
+			plog.Warningf("ignored gRPC peer transport without peer client certificate authentication")
+		}
+		protocol = ProtocolHTTP
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	cur := t.Protocol
+	if cur == "" {
+		cur = ProtocolHTTP
+	}
+	if protocol == cur {
+		return
+	}
+	t.Protocol = protocol
+	if t.Logger != nil {
+		t.Logger.Info(
+			"switching peer transport",
+			zap.String("local-member-id", t.ID.String()),
+			zap.String("from", cur),
+			zap.String("to", protocol),
+		)
+	} else {
+		plog.Infof("switching peer transport from %s to %s", cur, protocol)
+	}
+	for id, p := range t.peers {
+		var urls types.URLs
+		switch p := p.(type) {
+		case *peer:
+			urls = p.picker.all()
+		case *grpcPeer:
+			urls = p.picker.all()
+		default:
+			continue
+		}
+		t.removePeer(id)
+		t.addPeer(id, urls)
+	}
+}
+
 func (t *Transport) RemovePeer(id types.ID) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
@@ -419,6 +513,15 @@ func (t *Transport) ActiveSince(id types.ID) time.Time {
 	return time.Time{}
 }
 
+func (t *Transport) LastSeen(id types.ID) time.Time {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if p, ok := t.peers[id]; ok {
+		return p.lastSeen()
+	}
+	return time.Time{}
+}
+
 func (t *Transport) SendSnapshot(m snap.Message) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
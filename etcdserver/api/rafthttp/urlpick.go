@@ -40,6 +40,12 @@ func (p *urlPicker) update(urls types.URLs) {
 	p.picked = 0
 }
 
+func (p *urlPicker) all() types.URLs {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.urls
+}
+
 func (p *urlPicker) pick() url.URL {
 	p.mu.Lock()
 	defer p.mu.Unlock()
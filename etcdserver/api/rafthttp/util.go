@@ -50,6 +50,14 @@ func NewRoundTripper(tlsInfo transport.TLSInfo, dialTimeout time.Duration) (http
 	return transport.NewTimeoutTransport(tlsInfo, dialTimeout, 0, 0)
 }
 
+// RestrictRoundTripper makes rt, returned by NewRoundTripper, only dial
+// the peers in the allowed networks.
+func RestrictRoundTripper(rt http.RoundTripper, ns transport.AllowedNetworks) {
+	if tr, ok := rt.(*http.Transport); ok {
+		ns.RestrictTransport(tr)
+	}
+}
+
 // newStreamRoundTripper returns a roundTripper used to send stream requests
 // to rafthttp listener of remote peers.
 // Read/write timeout is set for stream roundTripper to promptly
@@ -84,7 +92,8 @@ func checkPostResponse(resp *http.Response, body []byte, req *http.Request, to t
 	case http.StatusPreconditionFailed:
 		switch strings.TrimSuffix(string(body), "\n") {
 		case errIncompatibleVersion.Error():
-			plog.Errorf("request sent was ignored by peer %s (server version incompatible)", to)
+			plog.Errorf("request sent was ignored by peer %s (server version incompatible: remote=%s, local=%s)",
+				to, resp.Header.Get("X-Server-Version"), version.Version)
 			return errIncompatibleVersion
 		case errClusterIDMismatch.Error():
 			plog.Errorf("request sent was ignored (cluster ID mismatch: remote[%s]=%s, local=%s)",
@@ -131,23 +140,28 @@ func compareMajorMinorVersion(a, b *semver.Version) int {
 }
 
 // serverVersion returns the server version from the given header.
+// It returns nil if the version is malformed.
 func serverVersion(h http.Header) *semver.Version {
-	verStr := h.Get("X-Server-Version")
-	// backward compatibility with etcd 2.0
-	if verStr == "" {
-		verStr = "2.0.0"
-	}
-	return semver.Must(semver.NewVersion(verStr))
+	return headerVersion(h, "X-Server-Version")
 }
 
-// serverVersion returns the min cluster version from the given header.
+// minClusterVersion returns the min cluster version from the given header.
+// It returns nil if the version is malformed.
 func minClusterVersion(h http.Header) *semver.Version {
-	verStr := h.Get("X-Min-Cluster-Version")
+	return headerVersion(h, "X-Min-Cluster-Version")
+}
+
+func headerVersion(h http.Header, key string) *semver.Version {
+	verStr := h.Get(key)
 	// backward compatibility with etcd 2.0
 	if verStr == "" {
 		verStr = "2.0.0"
 	}
-	return semver.Must(semver.NewVersion(verStr))
+	v, err := semver.NewVersion(verStr)
+	if err != nil {
+		return nil
+	}
+	return v
 }
 
 // checkVersionCompatibility checks whether the given version is compatible
@@ -158,6 +172,9 @@ func checkVersionCompatibility(name string, server, minCluster *semver.Version)
 	err error) {
 	localServer = semver.Must(semver.NewVersion(version.Version))
 	localMinCluster = semver.Must(semver.NewVersion(version.MinClusterVersion))
+	if server == nil || minCluster == nil {
+		return localServer, localMinCluster, fmt.Errorf("remote version is malformed: remote[%s], local=%s", name, localServer)
+	}
 	if compareMajorMinorVersion(server, localMinCluster) == -1 {
 		return localServer, localMinCluster, fmt.Errorf("remote version is too low: remote[%s]=%s, local=%s", name, server, localServer)
 	}
@@ -112,9 +112,20 @@ func TestServerVersion(t *testing.T) {
 			http.Header{"X-Server-Version": []string{"2.1.0-alpha.0+git"}},
 			semver.Must(semver.NewVersion("2.1.0-alpha.0+git")),
 		},
+		// malformed version
+		{
+			http.Header{"X-Server-Version": []string{"not-a-version"}},
+			nil,
+		},
 	}
 	for i, tt := range tests {
 		v := serverVersion(tt.h)
+		if tt.wv == nil {
+			if v != nil {
+				t.Errorf("#%d: version = %s, want nil", i, v)
+			}
+			continue
+		}
 		if v.String() != tt.wv.String() {
 			t.Errorf("#%d: version = %s, want %s", i, v, tt.wv)
 		}
@@ -139,9 +150,20 @@ func TestMinClusterVersion(t *testing.T) {
 			http.Header{"X-Min-Cluster-Version": []string{"2.1.0-alpha.0+git"}},
 			semver.Must(semver.NewVersion("2.1.0-alpha.0+git")),
 		},
+		// malformed version
+		{
+			http.Header{"X-Min-Cluster-Version": []string{"not-a-version"}},
+			nil,
+		},
 	}
 	for i, tt := range tests {
 		v := minClusterVersion(tt.h)
+		if tt.wv == nil {
+			if v != nil {
+				t.Errorf("#%d: version = %s, want nil", i, v)
+			}
+			continue
+		}
 		if v.String() != tt.wv.String() {
 			t.Errorf("#%d: version = %s, want %s", i, v, tt.wv)
 		}
@@ -186,6 +208,12 @@ func TestCheckVersionCompatibility(t *testing.T) {
 			&semver.Version{Major: ls.Major + 1},
 			false,
 		},
+		// malformed version
+		{
+			nil,
+			lmc,
+			false,
+		},
 	}
 	for i, tt := range tests {
 		_, _, err := checkVersionCompatibility("", tt.server, tt.minCluster)
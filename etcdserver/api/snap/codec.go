@@ -0,0 +1,261 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snap
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+const (
+	// CompressionNone writes the snap files uncompressed.
+	CompressionNone = ""
+	// CompressionFlate compresses the snap files with DEFLATE.
+	CompressionFlate = "flate"
+)
+
+// encodedMagic starts the encoded snap files. A snap file written without
+// a codec is a serialized snappb.Snapshot, whose first byte is the tag of
+// a field and so never zero.
+var encodedMagic = []byte("\x00ESNP")
+
+const (
+	encodedVersion = 1
+
+	compressionIDNone  = 0
+	compressionIDFlate = 1
+)
+
+var (
+	ErrUnknownCompression = errors.New("snap: unknown compression")
+	// ErrNoKey is returned reading an encrypted snap file without the key
+	// it was encrypted with.
+	ErrNoKey = errors.New("snap: no key to decrypt the snap file")
+)
+
+// KeyProvider provides the keys encrypting the data at rest. The keys are
+// AES-256 keys, identified by an ID stored in the clear with the data
+// they encrypt.
+type KeyProvider interface {
+	// CurrentKey returns the key to encrypt new data with, and its ID.
+	CurrentKey() (id string, key []byte)
+	// Key returns the key of the given ID, or false if the provider does
+	// not hold it.
+	Key(id string) ([]byte, bool)
+}
+
+// Codec encodes the snap files. The zero value writes them as in previous
+// releases.
+type Codec struct {
+	// Compression is the compression of the written snap files, either
+	// CompressionNone or CompressionFlate.
+	Compression string
+	// Keys, if not nil, encrypts the written snap files with its current
+	// key, and decrypts the read ones.
+	Keys KeyProvider
+}
+
+// ValidateCompression returns ErrUnknownCompression if c is not a supported
+// compression.
+func ValidateCompression(c string) error {
+	_, err := compressionID(c)
+	return err
+}
+
+func compressionID(c string) (byte, error) {
+	switch c {
+	case CompressionNone:
+		return compressionIDNone, nil
+	case CompressionFlate:
+		return compressionIDFlate, nil
+	}
+	return 0, fmt.Errorf("%v %q", ErrUnknownCompression, c)
+}
+
+func (c Codec) isZero() bool { return c.Compression == CompressionNone && c.Keys == nil }
+
+// encode encodes the serialized snappb.Snapshot b as
+//
+//	magic | version | compression | key ID length | key ID | [nonce] | payload
+//
+// where the payload is b, compressed, then sealed with AES-GCM if encrypted.
+// The header is authenticated with the payload.
+func (c Codec) encode(b []byte) ([]byte, error) {
+	if c.isZero() {
+		return b, nil
+	}
+	cid, err := compressionID(c.Compression)
+	if err != nil {
+		return nil, err
+	}
+	var keyID string
+	var key []byte
+	if c.Keys != nil {
+		keyID, key = c.Keys.CurrentKey()
+		if len(keyID) > 255 {
+			return nil, fmt.Errorf("snap: key ID %q is too long", keyID)
+		}
+	}
+	hdr := make([]byte, 0, len(encodedMagic)+3+len(keyID))
+	hdr = append(hdr, encodedMagic...)
+	hdr = append(hdr, encodedVersion, cid, byte(len(keyID)))
+	hdr = append(hdr, keyID...)
+
+	if cid == compressionIDFlate {
+		var buf bytes.Buffer
+		w, werr := flate.NewWriter(&buf, flate.DefaultCompression)
+		if werr != nil {
+			return nil, werr
+		}
+		if _, werr = w.Write(b); werr != nil {
+			return nil, werr
+		}
+		if werr = w.Close(); werr != nil {
+			return nil, werr
+		}
+		b = buf.Bytes()
+	}
+	if key == nil {
+		return append(hdr, b...), nil
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	out := append(hdr, nonce...)
+	return aead.Seal(out, nonce, b, hdr), nil
+}
+
+// decode returns the serialized snappb.Snapshot encoded in d. Snap files
+// written without a codec are returned as they are.
+func decode(d []byte, keys KeyProvider) ([]byte, error) {
+	if !bytes.HasPrefix(d, encodedMagic) {
+		return d, nil
+	}
+	n := len(encodedMagic)
+	if len(d) < n+3 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if d[n] != encodedVersion {
+		return nil, fmt.Errorf("snap: unknown snap file version %d", d[n])
+	}
+	cid, idLen := d[n+1], int(d[n+2])
+	n += 3
+	if len(d) < n+idLen {
+		return nil, io.ErrUnexpectedEOF
+	}
+	keyID := string(d[n : n+idLen])
+	hdr, b := d[:n+idLen], d[n+idLen:]
+
+	if keyID != "" {
+		var key []byte
+		if keys != nil {
+			key, _ = keys.Key(keyID)
+		}
+		if key == nil {
+			return nil, ErrNoKey
+		}
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, err
+		}
+		if len(b) < aead.NonceSize() {
+			return nil, io.ErrUnexpectedEOF
+		}
+		nonce := b[:aead.NonceSize()]
+		if b, err = aead.Open(nil, nonce, b[aead.NonceSize():], hdr); err != nil {
+			return nil, err
+		}
+	}
+
+	switch cid {
+	case compressionIDNone:
+		return b, nil
+	case compressionIDFlate:
+		r := flate.NewReader(bytes.NewReader(b))
+		defer r.Close()
+		return ioutil.ReadAll(r)
+	}
+	return nil, fmt.Errorf("%v %d", ErrUnknownCompression, cid)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+type fileKeyProvider struct {
+	currentID string
+	keys      map[string][]byte
+}
+
+// NewFileKeyProvider returns a KeyProvider of the keys in the given files.
+// Each file holds a 32-byte key, raw or hex-encoded. The first key encrypts
+// new data, and every key decrypts, so that the keys can be rotated by
+// prepending the new key file. A key is identified by a prefix of its
+// SHA-256 hash.
+func NewFileKeyProvider(paths []string) (KeyProvider, error) {
+	if len(paths) == 0 {
+		return nil, errors.New("snap: no key file")
+	}
+	kp := &fileKeyProvider{keys: make(map[string][]byte, len(paths))}
+	for i, p := range paths {
+		b, err := ioutil.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		key := b
+		if s := strings.TrimSpace(string(b)); len(s) == 2*32 {
+			if hk, herr := hex.DecodeString(s); herr == nil {
+				key = hk
+			}
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("snap: key file %q must hold a 32-byte key, raw or hex-encoded", p)
+		}
+		sum := sha256.Sum256(key)
+		id := hex.EncodeToString(sum[:8])
+		if i == 0 {
+			kp.currentID = id
+		}
+		kp.keys[id] = key
+	}
+	return kp, nil
+}
+
+func (kp *fileKeyProvider) CurrentKey() (string, []byte) { return kp.currentID, kp.keys[kp.currentID] }
+
+func (kp *fileKeyProvider) Key(id string) ([]byte, bool) {
+	key, ok := kp.keys[id]
+	return key, ok
+}
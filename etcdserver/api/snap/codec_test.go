@@ -0,0 +1,145 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snap
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func writeKeyFiles(t *testing.T, dir string, keys ...[]byte) []string {
+	var paths []string
+	for i, key := range keys {
+		p := filepath.Join(dir, fmt.Sprintf("key%d", i))
+		if err := ioutil.WriteFile(p, key, 0600); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, p)
+	}
+	return paths
+}
+
+func TestSaveAndLoadWithCodec(t *testing.T) {
+	keyDir, err := ioutil.TempDir(os.TempDir(), "snapkeys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(keyDir)
+	key1 := bytes.Repeat([]byte{1}, 32)
+	key2 := []byte(hex.EncodeToString(bytes.Repeat([]byte{2}, 32)) + "\n")
+	keys1, err := NewFileKeyProvider(writeKeyFiles(t, keyDir, key1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// key 2 rotates key 1
+	keys21, err := NewFileKeyProvider(writeKeyFiles(t, keyDir, key2, key1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		wc Codec
+		rc Codec
+
+		werr error
+	}{
+		{Codec{}, Codec{}, nil},
+		{Codec{Compression: CompressionFlate}, Codec{}, nil},
+		{Codec{Keys: keys1}, Codec{Keys: keys1}, nil},
+		{Codec{Compression: CompressionFlate, Keys: keys1}, Codec{Keys: keys1}, nil},
+		// files written without a codec are read by any
+		{Codec{}, Codec{Compression: CompressionFlate, Keys: keys1}, nil},
+		{Codec{Keys: keys1}, Codec{Keys: keys21}, nil},
+		{Codec{Keys: keys21}, Codec{Keys: keys1}, ErrNoKey},
+		{Codec{Keys: keys1}, Codec{}, ErrNoKey},
+	}
+	for i, tt := range tests {
+		dir, err := ioutil.TempDir(os.TempDir(), "snapshot")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		if err = NewWithCodec(zap.NewExample(), dir, tt.wc).save(testSnap); err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		g, err := NewWithCodec(zap.NewExample(), dir, tt.rc).Load()
+		if err != tt.werr {
+			t.Errorf("#%d: err = %v, want %v", i, err, tt.werr)
+		}
+		if tt.werr != nil {
+			// a snap file is not broken for lack of its key
+			if _, serr := os.Stat(filepath.Join(dir, "0000000000000001-0000000000000001.snap")); serr != nil {
+				t.Errorf("#%d: %v", i, serr)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(g, testSnap) {
+			t.Errorf("#%d: snap = %#v, want %#v", i, g, testSnap)
+		}
+	}
+}
+
+func TestDecodeTampered(t *testing.T) {
+	keyDir, err := ioutil.TempDir(os.TempDir(), "snapkeys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(keyDir)
+	keys, err := NewFileKeyProvider(writeKeyFiles(t, keyDir, bytes.Repeat([]byte{1}, 32)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := Codec{Compression: CompressionFlate, Keys: keys}
+	d, err := c.encode([]byte("snapshot"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the header is authenticated as well as the payload
+	for _, i := range []int{len(encodedMagic) + 1, len(d) - 1} {
+		td := append([]byte{}, d...)
+		td[i] ^= 1
+		if _, err = decode(td, keys); err == nil {
+			t.Errorf("decode of a snap file with byte %d flipped succeeded", i)
+		}
+	}
+	b, err := decode(d, keys)
+	if err != nil || string(b) != "snapshot" {
+		t.Errorf("decode = %q, %v, want %q, nil", b, err, "snapshot")
+	}
+}
+
+func TestNewFileKeyProviderInvalid(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "snapkeys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for i, key := range [][]byte{[]byte("short"), []byte(hex.EncodeToString(make([]byte, 31)))} {
+		if _, err = NewFileKeyProvider(writeKeyFiles(t, dir, key)); err == nil {
+			t.Errorf("#%d: no error for an invalid key", i)
+		}
+	}
+	if _, err = NewFileKeyProvider([]string{filepath.Join(dir, "missing")}); err == nil {
+		t.Errorf("no error for a missing key file")
+	}
+}
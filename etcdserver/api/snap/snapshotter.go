@@ -52,8 +52,9 @@ var (
 )
 
 type Snapshotter struct {
-	lg  *zap.Logger
-	dir string
+	lg    *zap.Logger
+	dir   string
+	codec Codec
 }
 
 func New(lg *zap.Logger, dir string) *Snapshotter {
@@ -63,6 +64,17 @@ func New(lg *zap.Logger, dir string) *Snapshotter {
 	}
 }
 
+// NewWithCodec returns a Snapshotter writing the snap files encoded with
+// the given codec. It reads the snap files written with any codec whose
+// key the codec holds, and the ones written without a codec.
+func NewWithCodec(lg *zap.Logger, dir string, c Codec) *Snapshotter {
+	return &Snapshotter{
+		lg:    lg,
+		dir:   dir,
+		codec: c,
+	}
+}
+
 func (s *Snapshotter) SaveSnap(snapshot raftpb.Snapshot) error {
 	if raft.IsEmptySnap(snapshot) {
 		return nil
@@ -81,6 +93,9 @@ func (s *Snapshotter) save(snapshot *raftpb.Snapshot) error {
 	if err != nil {
 		return err
 	}
+	if d, err = s.codec.encode(d); err != nil {
+		return err
+	}
 	snapMarshallingSec.Observe(time.Since(start).Seconds())
 
 	spath := filepath.Join(s.dir, fname)
@@ -114,21 +129,28 @@ func (s *Snapshotter) Load() (*raftpb.Snapshot, error) {
 		return nil, err
 	}
 	var snap *raftpb.Snapshot
+	noKey := false
 	for _, name := range names {
-		if snap, err = loadSnap(s.lg, s.dir, name); err == nil {
+		if snap, err = loadSnap(s.lg, s.dir, name, s.codec.Keys); err == nil {
 			break
 		}
+		noKey = noKey || err == ErrNoKey
 	}
 	if err != nil {
+		if noKey {
+			// starting over without the snapshot would replay a
+			// compacted log
+			return nil, ErrNoKey
+		}
 		return nil, ErrNoSnapshot
 	}
 	return snap, nil
 }
 
-func loadSnap(lg *zap.Logger, dir, name string) (*raftpb.Snapshot, error) {
+func loadSnap(lg *zap.Logger, dir, name string, keys KeyProvider) (*raftpb.Snapshot, error) {
 	fpath := filepath.Join(dir, name)
-	snap, err := Read(lg, fpath)
-	if err != nil {
+	snap, err := ReadWithKeys(lg, fpath, keys)
+	if err != nil && err != ErrNoKey {
 		brokenPath := fpath + ".broken"
 		if lg != nil {
 			lg.Warn("failed to read a snap file", zap.String("path", fpath), zap.Error(err))
@@ -149,7 +171,14 @@ func loadSnap(lg *zap.Logger, dir, name string) (*raftpb.Snapshot, error) {
 }
 
 // Read reads the snapshot named by snapname and returns the snapshot.
+// The snapshot may be compressed, but not encrypted.
 func Read(lg *zap.Logger, snapname string) (*raftpb.Snapshot, error) {
+	return ReadWithKeys(lg, snapname, nil)
+}
+
+// ReadWithKeys reads the snapshot named by snapname like Read, decrypting
+// it with the keys if it is encrypted.
+func ReadWithKeys(lg *zap.Logger, snapname string, keys KeyProvider) (*raftpb.Snapshot, error) {
 	b, err := ioutil.ReadFile(snapname)
 	if err != nil {
 		if lg != nil {
@@ -169,6 +198,15 @@ func Read(lg *zap.Logger, snapname string) (*raftpb.Snapshot, error) {
 		return nil, ErrEmptySnapshot
 	}
 
+	if b, err = decode(b, keys); err != nil {
+		if lg != nil {
+			lg.Warn("failed to decode snap file", zap.String("path", snapname), zap.Error(err))
+		} else {
+			plog.Errorf("cannot decode snapshot file %v: %v", snapname, err)
+		}
+		return nil, err
+	}
+
 	var serializedSnap snappb.Snapshot
 	if err = serializedSnap.Unmarshal(b); err != nil {
 		if lg != nil {
@@ -113,6 +113,9 @@ type User struct {
 	Roles    []string `json:"roles"`
 	Grant    []string `json:"grant,omitempty"`
 	Revoke   []string `json:"revoke,omitempty"`
+	// Root is the virtual root of the user. Keys requested by the user are
+	// resolved under Root, so that it appears as "/" to the user.
+	Root string `json:"root,omitempty"`
 }
 
 type Role struct {
@@ -224,6 +227,11 @@ func (s *store) createUserInternal(user User) (User, error) {
 	if user.Password == "" {
 		return user, authErr(http.StatusBadRequest, "Cannot create user %s with an empty password", user.User)
 	}
+	root, err := cleanRoot(user.Root)
+	if err != nil {
+		return user, err
+	}
+	user.Root = root
 	hash, err := s.HashPassword(user.Password)
 	if err != nil {
 		return user, err
@@ -513,9 +521,32 @@ func (ou User) merge(lg *zap.Logger, nu User, s PasswordStore) (User, error) {
 	}
 	out.Roles = currentRoles.Values()
 	sort.Strings(out.Roles)
+	out.Root = ou.Root
+	if nu.Root != "" {
+		root, err := cleanRoot(nu.Root)
+		if err != nil {
+			return ou, err
+		}
+		out.Root = root
+	}
 	return out, nil
 }
 
+// cleanRoot validates the virtual root of a user and returns it in its
+// canonical form. The root "/" is the same as no virtual root.
+func cleanRoot(root string) (string, error) {
+	if root == "" {
+		return "", nil
+	}
+	if !path.IsAbs(root) {
+		return "", authErr(http.StatusBadRequest, "Virtual root %s must be an absolute path", root)
+	}
+	if root = path.Clean(root); root == "/" {
+		return "", nil
+	}
+	return root, nil
+}
+
 // merge for a role works the same as User above -- atomic Role application to
 // each of the substructures.
 func (r Role) merge(lg *zap.Logger, n Role) (Role, error) {
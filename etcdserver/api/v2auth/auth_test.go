@@ -94,6 +94,24 @@ func TestMergeUser(t *testing.T) {
 			User{User: "foo", Password: "foo", Roles: []string{}},
 			false,
 		},
+		{ // empty root will not overwrite the previous root
+			User{User: "foo", Root: "/tenants/a"},
+			User{User: "foo"},
+			User{User: "foo", Roles: []string{}, Root: "/tenants/a"},
+			false,
+		},
+		{
+			User{User: "foo"},
+			User{User: "foo", Root: "/tenants/b/"},
+			User{User: "foo", Roles: []string{}, Root: "/tenants/b"},
+			false,
+		},
+		{
+			User{User: "foo"},
+			User{User: "foo", Root: "tenants/b"},
+			User{},
+			true,
+		},
 	}
 
 	for i, tt := range tbl {
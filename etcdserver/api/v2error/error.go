@@ -21,6 +21,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"sort"
 )
 
 var errors = map[int]string{
@@ -36,6 +37,12 @@ var errors = map[int]string{
 	EcodeDirNotEmpty:      "Directory not empty",
 	ecodeExistingPeerAddr: "Peer address has existed",
 	EcodeUnauthorized:     "The request requires user authentication",
+	EcodeTooManyKeys:      "The recursive operation touches too many keys",
+	EcodeKeyRateLimited:   "The key is written faster than its rate limit",
+	EcodeKeyQuotaFull:     "The directory holds as many keys as its quota",
+	EcodeKeyWatchers:      "The key is watched by as many watchers as its limit",
+	EcodeClientWatchers:   "The client holds as many watchers as its limit",
+	EcodeMemoryLimit:      "The member is above its memory limit",
 
 	// Post form related errors
 	ecodeValueRequired:        "Value is Required in POST form",
@@ -68,14 +75,20 @@ var errors = map[int]string{
 }
 
 var errorStatus = map[int]int{
-	EcodeKeyNotFound:  http.StatusNotFound,
-	EcodeNotFile:      http.StatusForbidden,
-	EcodeDirNotEmpty:  http.StatusForbidden,
-	EcodeUnauthorized: http.StatusUnauthorized,
-	EcodeTestFailed:   http.StatusPreconditionFailed,
-	EcodeNodeExist:    http.StatusPreconditionFailed,
-	EcodeRaftInternal: http.StatusInternalServerError,
-	EcodeLeaderElect:  http.StatusInternalServerError,
+	EcodeKeyNotFound:    http.StatusNotFound,
+	EcodeNotFile:        http.StatusForbidden,
+	EcodeDirNotEmpty:    http.StatusForbidden,
+	EcodeUnauthorized:   http.StatusUnauthorized,
+	EcodeTooManyKeys:    http.StatusForbidden,
+	EcodeKeyRateLimited: http.StatusTooManyRequests,
+	EcodeKeyQuotaFull:   http.StatusForbidden,
+	EcodeKeyWatchers:    http.StatusTooManyRequests,
+	EcodeClientWatchers: http.StatusTooManyRequests,
+	EcodeMemoryLimit:    http.StatusInsufficientStorage,
+	EcodeTestFailed:     http.StatusPreconditionFailed,
+	EcodeNodeExist:      http.StatusPreconditionFailed,
+	EcodeRaftInternal:   http.StatusInternalServerError,
+	EcodeLeaderElect:    http.StatusInternalServerError,
 }
 
 const (
@@ -90,6 +103,12 @@ const (
 	EcodeDirNotEmpty      = 108
 	ecodeExistingPeerAddr = 109
 	EcodeUnauthorized     = 110
+	EcodeTooManyKeys      = 111
+	EcodeKeyRateLimited   = 112
+	EcodeKeyQuotaFull     = 113
+	EcodeKeyWatchers      = 114
+	EcodeClientWatchers   = 115
+	EcodeMemoryLimit      = 116
 
 	ecodeValueRequired        = 200
 	EcodePrevValueRequired    = 201
@@ -147,6 +166,16 @@ func (e Error) toJsonString() string {
 	return string(b)
 }
 
+// Codes returns the error codes, in increasing order.
+func Codes() []int {
+	codes := make([]int, 0, len(errors))
+	for code := range errors {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	return codes
+}
+
 func (e Error) StatusCode() int {
 	status, ok := errorStatus[e.ErrorCode]
 	if !ok {
@@ -48,3 +48,18 @@ func TestErrorWriteTo(t *testing.T) {
 	}
 
 }
+
+func TestCodes(t *testing.T) {
+	codes := Codes()
+	if len(codes) != len(errors) {
+		t.Fatalf("len(Codes()) = %d, want %d", len(codes), len(errors))
+	}
+	for i, code := range codes {
+		if i > 0 && code <= codes[i-1] {
+			t.Errorf("Codes() = %v, not in increasing order", codes)
+		}
+		if _, ok := errors[code]; !ok {
+			t.Errorf("code %d has no message", code)
+		}
+	}
+}
@@ -44,6 +44,7 @@ import (
 )
 
 const (
+	adminPrefix    = "/v2/admin"
 	authPrefix     = "/v2/auth"
 	keysPrefix     = "/v2/keys"
 	machinesPrefix = "/v2/machines"
@@ -52,13 +53,31 @@ const (
 )
 
 // NewClientHandler generates a muxed http.Handler with the given parameters to serve etcd client requests.
-func NewClientHandler(lg *zap.Logger, server etcdserver.ServerPeer, timeout time.Duration) http.Handler {
+// The requests pass through the given middlewares, in order, before being routed.
+func NewClientHandler(lg *zap.Logger, server etcdserver.ServerPeer, timeout time.Duration, mws ...etcdhttp.Middleware) http.Handler {
+	vm := etcdhttp.NewVersionMux(NewBasicHandler(lg, server))
+	HandleVersion(lg, vm, server, timeout)
+	return etcdhttp.Chain(vm, mws...)
+}
+
+// NewBasicHandler returns the handler of the basic endpoints, see
+// etcdhttp.HandleBasic, logging the requests like the v2 API does.
+func NewBasicHandler(lg *zap.Logger, server etcdserver.ServerPeer) http.Handler {
 	mux := http.NewServeMux()
 	etcdhttp.HandleBasic(mux, server)
-	handleV2(lg, mux, server, timeout)
 	return requestLogger(lg, mux)
 }
 
+// HandleVersion registers the v2 API on the given version mux.
+func HandleVersion(lg *zap.Logger, vm *etcdhttp.VersionMux, server etcdserver.ServerV2, timeout time.Duration) {
+	mux := http.NewServeMux()
+	handleV2(lg, mux, server, timeout)
+	vm.Handle(etcdhttp.APIVersion{
+		Version:      "v2",
+		Capabilities: []string{"keys", "members", "machines", "stats", "auth", "admin"},
+	}, requestLogger(lg, mux))
+}
+
 func handleV2(lg *zap.Logger, mux *http.ServeMux, server etcdserver.ServerV2, timeout time.Duration) {
 	sec := v2auth.NewStore(lg, server, timeout)
 	kh := &keysHandler{
@@ -69,10 +88,45 @@ func handleV2(lg *zap.Logger, mux *http.ServeMux, server etcdserver.ServerV2, ti
 		timeout:               timeout,
 		clientCertAuthEnabled: server.ClientCertAuthEnabled(),
 	}
+	if ch, ok := server.(cacheHeaderer); ok {
+		kh.cacheControl, kh.etag = ch.V2CacheHeaders()
+	}
+	if id, ok := server.(implicitDirser); ok {
+		kh.explicitDirs = !id.V2ImplicitDirs()
+	}
+	if wr, ok := server.(witnessReporter); ok {
+		kh.witness = wr.IsWitness()
+	}
+	if lr, ok := server.(learnerReporter); ok {
+		kh.learner = lr
+	}
+	if al, ok := server.(applyLagger); ok {
+		kh.applyLagger = al
+	}
+	if lr, ok := server.(leaderLagReporter); ok {
+		kh.lagReporter = lr
+	}
+	if ls, ok := server.(loadShedder); ok {
+		kh.shedder = ls
+	}
+	if bg, ok := server.(etcdserver.BatchGetter); ok {
+		kh.batcher = bg
+	}
+	if lt, ok := server.(leaderTimer); ok {
+		kh.clock = leaderClock{Clock: clockwork.NewRealClock(), lt: lt}
+	}
+	if fr, ok := server.(etcdserver.FlightRecorder); ok && fr.RecordsMutations() {
+		kh.recorder = fr
+	}
+	if wl, ok := server.(watcherLimiter); ok {
+		kh.watchers = newClientWatchers(wl.V2MaxWatchersPerClient())
+	}
 
 	sh := &statsHandler{
-		lg:    lg,
-		stats: server,
+		lg:                    lg,
+		sec:                   sec,
+		stats:                 server,
+		clientCertAuthEnabled: server.ClientCertAuthEnabled(),
 	}
 
 	mh := &membersHandler{
@@ -93,18 +147,113 @@ func handleV2(lg *zap.Logger, mux *http.ServeMux, server etcdserver.ServerV2, ti
 		cluster:               server.Cluster(),
 		clientCertAuthEnabled: server.ClientCertAuthEnabled(),
 	}
+	ah := &adminHandler{
+		lg:                    lg,
+		sec:                   sec,
+		cluster:               server.Cluster(),
+		timeout:               timeout,
+		clientCertAuthEnabled: server.ClientCertAuthEnabled(),
+	}
+	if rc, ok := server.(etcdserver.RuntimeConfigurer); ok {
+		ah.rc = rc
+	}
+	if rs, ok := server.(etcdserver.RaftStatusReporter); ok {
+		ah.rs = rs
+	}
+	if am, ok := server.(etcdserver.AlarmManager); ok {
+		ah.am = am
+	}
+	if tk, ok := server.(etcdserver.TombstoneKeeper); ok {
+		ah.tk = tk
+	}
+	if ke, ok := server.(etcdserver.KeyspaceExporter); ok {
+		ah.ke = ke
+	}
+	if aa, ok := server.(etcdserver.ApplyAuditor); ok {
+		ah.aa = aa
+	}
+	if oc, ok := server.(etcdserver.OperationCanceler); ok {
+		ah.oc = oc
+	}
+	if mu, ok := server.(etcdserver.MemberMetadataUpdater); ok {
+		ah.mu = mu
+	}
+	if fr, ok := server.(etcdserver.FlightRecorder); ok {
+		ah.fr = fr
+	}
+	if ki, ok := server.(etcdserver.KeyInspector); ok {
+		ah.ki = ki
+	}
+	if pl, ok := server.(etcdserver.ProposalLister); ok {
+		ah.pl = pl
+	}
 	mux.HandleFunc("/", http.NotFound)
-	mux.Handle(keysPrefix, kh)
-	mux.Handle(keysPrefix+"/", kh)
-	mux.HandleFunc(statsPrefix+"/store", sh.serveStore)
-	mux.HandleFunc(statsPrefix+"/self", sh.serveSelf)
-	mux.HandleFunc(statsPrefix+"/leader", sh.serveLeader)
-	mux.Handle(membersPrefix, mh)
-	mux.Handle(membersPrefix+"/", mh)
-	mux.Handle(machinesPrefix, mah)
-	handleAuth(mux, sech)
+	rr := &routeRecorder{mux: mux}
+	rr.Handle(keysPrefix, kh)
+	rr.Handle(keysPrefix+"/", kh)
+	rr.HandleFunc(statsPrefix+"/store", sh.serveStore)
+	rr.HandleFunc(statsPrefix+"/self", sh.serveSelf)
+	rr.HandleFunc(statsPrefix+"/leader", sh.serveLeader)
+	rr.Handle(membersPrefix, mh)
+	rr.Handle(membersPrefix+"/", mh)
+	rr.Handle(machinesPrefix, mah)
+	handleAuth(rr, sech)
+	handleAdmin(rr, ah)
+
+	// the schema is generated from the registered routes, itself included
+	sch := &schemaHandler{}
+	rr.Handle(schemaPath, sch)
+	sch.body, _ = json.Marshal(v2Schema(rr.patterns))
+}
+
+// cacheHeaderer is implemented by servers that configure HTTP caching
+// of key GET responses.
+type cacheHeaderer interface {
+	V2CacheHeaders() (cacheControl string, etag bool)
+}
+
+// witnessReporter is implemented by servers that can be witness members.
+type witnessReporter interface {
+	IsWitness() bool
+}
+
+// learnerReporter is implemented by servers that can be learner members.
+type learnerReporter interface {
+	IsLearner() bool
+}
+
+// applyLagger is implemented by servers that refuse local reads while
+// applying a backlog of committed entries.
+type applyLagger interface {
+	ApplyLagging() bool
+}
+
+// loadShedder is implemented by servers that refuse client requests
+// while overloaded.
+type loadShedder interface {
+	ShedClientRequest() bool
+}
+
+// implicitDirser is implemented by servers that configure whether the
+// writes create the missing parent directories of their key by default.
+type implicitDirser interface {
+	V2ImplicitDirs() bool
+}
+
+// leaderTimer is implemented by servers that estimate the time of the
+// leader, so that key expirations do not depend on the local clock.
+type leaderTimer interface {
+	LeaderTime() time.Time
+}
+
+// leaderClock is a clock returning the time of the leader.
+type leaderClock struct {
+	clockwork.Clock
+	lt leaderTimer
 }
 
+func (c leaderClock) Now() time.Time { return c.lt.LeaderTime() }
+
 type keysHandler struct {
 	lg                    *zap.Logger
 	sec                   v2auth.Store
@@ -112,6 +261,36 @@ type keysHandler struct {
 	cluster               api.Cluster
 	timeout               time.Duration
 	clientCertAuthEnabled bool
+
+	// cacheControl is the Cache-Control header value of GET responses.
+	cacheControl string
+	// etag is true to set ETags on GET responses of keys.
+	etag bool
+	// explicitDirs is true if the PUTs and POSTs must not create the
+	// missing parent directories of their key, unless they set
+	// implicitDirs=true.
+	explicitDirs bool
+	// witness is true if the member is a witness, which serves no keys.
+	witness bool
+	// learner, if set, reports whether the member is a learner, which
+	// redirects the writes to a voting member.
+	learner learnerReporter
+	// applyLagger, if set, refuses serializable reads while the member
+	// is far behind the committed index.
+	applyLagger applyLagger
+	// lagReporter, if set, reports the lag behind the leader bounding the
+	// stale reads.
+	lagReporter leaderLagReporter
+	// shedder, if set, refuses the requests while the member is overloaded.
+	shedder loadShedder
+	// batcher, if set, serves the batch gets.
+	batcher etcdserver.BatchGetter
+	// clock, if set, is the clock TTLs are converted to expirations with.
+	clock clockwork.Clock
+	// recorder, if set, records the mutations with their responses.
+	recorder etcdserver.FlightRecorder
+	// watchers, if set, limits the watchers held by every client.
+	watchers *clientWatchers
 }
 
 func (h *keysHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -121,33 +300,120 @@ func (h *keysHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("X-Etcd-Cluster-ID", h.cluster.ID().String())
 
+	if h.witness {
+		writeError(h.lg, w, r, httptypes.NewHTTPError(http.StatusServiceUnavailable, "member is a witness"))
+		return
+	}
+	if h.shedder != nil && h.shedder.ShedClientRequest() {
+		w.Header().Set("Retry-After", "1")
+		writeError(h.lg, w, r, httptypes.NewHTTPError(http.StatusServiceUnavailable, "member is overloaded"))
+		return
+	}
+	if r.Method == "POST" && r.URL.Query().Get("op") == "batchget" {
+		// a read, which learners serve too
+		h.serveBatchGet(w, r)
+		return
+	}
+	if r.Method != "GET" && r.Method != "HEAD" && h.learner != nil && h.learner.IsLearner() {
+		h.redirectToVoter(w, r)
+		return
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
 	defer cancel()
 	clock := clockwork.NewRealClock()
 	startTime := clock.Now()
+	if h.clock != nil {
+		clock = h.clock
+	}
 	rr, noValueOnSuccess, err := parseKeyRequest(r, clock)
 	if err != nil {
 		writeKeyError(h.lg, w, err)
 		return
 	}
+	lo, err := parseListOptions(r.Form)
+	if err != nil {
+		writeKeyError(h.lg, w, err)
+		return
+	}
+	if _, ok := r.Form["implicitDirs"]; !ok && h.explicitDirs && (rr.Method == "PUT" || rr.Method == "POST") {
+		rr.NoImplicitDirs = true
+	}
+	sb, err := parseStaleBound(r.Form)
+	if err != nil {
+		writeKeyError(h.lg, w, err)
+		return
+	}
+	if (rr.Method == "GET" || rr.Method == "HEAD") && !rr.Quorum && !sb.allow && h.applyLagger != nil && h.applyLagger.ApplyLagging() {
+		w.Header().Set("Retry-After", "1")
+		writeError(h.lg, w, r, httptypes.NewHTTPError(http.StatusServiceUnavailable, "member is applying committed entries"))
+		return
+	}
+	if (rr.Method == "GET" || rr.Method == "HEAD") && !rr.Wait && !h.checkStaleRead(w, r, sb) {
+		return
+	}
 	// The path must be valid at this point (we've parsed the request successfully).
-	if !hasKeyPrefixAccess(h.lg, h.sec, r, r.URL.Path[len(keysPrefix):], rr.Recursive, h.clientCertAuthEnabled) {
+	// a claim deletes a key under the requested directory
+	recursive := rr.Recursive || rr.Method == "CLAIM"
+	root, username, ok := authorizeKeyRequest(h.lg, h.sec, r, r.URL.Path[len(keysPrefix):], recursive, h.clientCertAuthEnabled)
+	if !ok {
 		writeKeyNoAuth(w)
 		return
 	}
+	if rr.NoImplicitDirs && !api.IsCapabilityEnabled(api.V2WriteOptionsCapability) {
+		// the members not knowing the option would create the directories
+		notCapable(w, r, api.V2WriteOptionsCapability)
+		return
+	}
+	if rr.ClientRequestID != "" {
+		if !api.IsCapabilityEnabled(api.V2WriteOptionsCapability) {
+			notCapable(w, r, api.V2WriteOptionsCapability)
+			return
+		}
+		// the retries of a write are only answered to the same user
+		rr.ClientRequestUser = username
+	}
+	// keys of users with a virtual root are stored under it
+	prefix := path.Join(etcdserver.StoreKeysPrefix, root)
+	rr.Path = path.Join(prefix, rr.Path[len(etcdserver.StoreKeysPrefix):])
 	if !rr.Wait {
 		reportRequestReceived(rr)
+	} else if h.watchers != nil {
+		host, ok := h.watchers.acquire(r.RemoteAddr)
+		if !ok {
+			reportWatcherRejected("client")
+			writeKeyError(h.lg, w, v2error.NewError(v2error.EcodeClientWatchers, host, 0))
+			return
+		}
+		// the watcher is held until its response is written
+		defer h.watchers.release(host)
+	}
+	var resp etcdserver.Response
+	if h.recorder != nil && isV2Mutation(rr.Method) {
+		sr := &statusRecorder{ResponseWriter: w}
+		w = sr
+		defer func() { h.recordMutation(r, rr.Method, startTime, sr.code, resp, err) }()
 	}
-	resp, err := h.server.Do(ctx, rr)
+	resp, err = h.server.Do(ctx, rr)
 	if err != nil {
-		err = trimErrorPrefix(err, etcdserver.StoreKeysPrefix)
+		if verr, ok := err.(*v2error.Error); ok && verr.ErrorCode == v2error.EcodeKeyWatchers {
+			reportWatcherRejected("key")
+		}
+		err = trimErrorPrefix(err, prefix)
 		writeKeyError(h.lg, w, err)
 		reportRequestFailed(rr, err)
 		return
 	}
 	switch {
 	case resp.Event != nil:
-		if err := writeKeyEvent(w, resp, noValueOnSuccess); err != nil {
+		if (rr.Method == "GET" || rr.Method == "HEAD") && h.writeCacheHeaders(w, r, resp.Event) {
+			reportRequestCompleted(rr, startTime)
+			return
+		}
+		if rr.Method == "GET" || rr.Method == "HEAD" {
+			resp.Event = lo.apply(resp.Event)
+		}
+		if err := writeKeyEvent(w, resp, prefix, noValueOnSuccess); err != nil {
 			// Should never be reached
 			if h.lg != nil {
 				h.lg.Warn("failed to write key event", zap.Error(err))
@@ -159,12 +425,70 @@ func (h *keysHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	case resp.Watcher != nil:
 		ctx, cancel := context.WithTimeout(context.Background(), defaultWatchTimeout)
 		defer cancel()
-		handleKeyWatch(ctx, h.lg, w, resp, rr.Stream)
+		// validated by parseKeyRequest
+		coalesce, _ := getBool(r.Form, "coalesce")
+		handleKeyWatch(ctx, h.lg, w, resp, prefix, rr.Stream, coalesce)
 	default:
 		writeKeyError(h.lg, w, errors.New("received response with no Event/Watcher"))
 	}
 }
 
+// redirectToVoter redirects a write sent to a learner to a voting member
+// serving clients, the leader if it is one.
+func (h *keysHandler) redirectToVoter(w http.ResponseWriter, r *http.Request) {
+	var to string
+	lead := h.server.Leader()
+	for _, m := range h.cluster.Members() {
+		if m.IsLearner || m.Witness || len(m.ClientURLs) == 0 {
+			continue
+		}
+		if to == "" || m.ID == lead {
+			to = m.ClientURLs[0]
+		}
+	}
+	if to == "" {
+		writeError(h.lg, w, r, httptypes.NewHTTPError(http.StatusServiceUnavailable, "member is a learner and knows no voting member"))
+		return
+	}
+	http.Redirect(w, r, strings.TrimSuffix(to, "/")+r.URL.RequestURI(), http.StatusTemporaryRedirect)
+}
+
+// writeCacheHeaders sets the configured caching headers of a GET
+// response. It returns true if the client already has the current
+// version of the key, in which case 304 Not Modified has been written.
+func (h *keysHandler) writeCacheHeaders(w http.ResponseWriter, r *http.Request, ev *v2store.Event) bool {
+	if h.cacheControl != "" {
+		w.Header().Set("Cache-Control", h.cacheControl)
+	}
+	// directories are not modified when their children are
+	if !h.etag || ev.Node == nil || ev.Node.Dir {
+		return false
+	}
+	// the body also depends on the request, e.g. on the sorting of the
+	// listed keys, so the modified index is only a weak validator
+	etag := fmt.Sprintf(`W/"%d"`, ev.Node.ModifiedIndex)
+	w.Header().Set("ETag", etag)
+	if !etagMatch(r.Header.Get("If-None-Match"), etag) {
+		return false
+	}
+	w.Header().Set("X-Etcd-Index", fmt.Sprint(ev.EtcdIndex))
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// etagMatch reports whether the If-None-Match header value matches etag,
+// using weak comparison.
+func etagMatch(ifNoneMatch, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, t := range strings.Split(ifNoneMatch, ",") {
+		t = strings.TrimPrefix(strings.TrimSpace(t), "W/")
+		if t == "*" || t == etag {
+			return true
+		}
+	}
+	return false
+}
+
 type machinesHandler struct {
 	cluster api.Cluster
 }
@@ -239,6 +563,9 @@ func (h *membersHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 		now := h.clock.Now()
 		m := membership.NewMember("", req.PeerURLs, "", &now)
+		if req.IsLearner {
+			m = membership.NewMemberAsLearner("", req.PeerURLs, "", &now)
+		}
 		_, err := h.server.AddMember(ctx, *m)
 		switch {
 		case err == membership.ErrIDExists || err == membership.ErrPeerURLexists:
@@ -306,6 +633,7 @@ func (h *membersHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		m := membership.Member{
 			ID:             id,
 			RaftAttributes: membership.RaftAttributes{PeerURLs: req.PeerURLs.StringSlice()},
+			Attributes:     membership.Attributes{Name: req.Name},
 		}
 		_, err := h.server.UpdateMember(ctx, m)
 		switch {
@@ -332,13 +660,21 @@ func (h *membersHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 type statsHandler struct {
 	lg    *zap.Logger
+	sec   v2auth.Store
 	stats stats.Stats
+
+	clientCertAuthEnabled bool
 }
 
 func (h *statsHandler) serveStore(w http.ResponseWriter, r *http.Request) {
 	if !allowMethod(w, r.Method, "GET") {
 		return
 	}
+	// the store stats name the keys holding the largest values
+	if !hasRootAccess(h.lg, h.sec, r, h.clientCertAuthEnabled) {
+		writeNoAuth(h.lg, w, r)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
 	w.Write(h.stats.StoreStats())
 }
@@ -401,7 +737,7 @@ func parseKeyRequest(r *http.Request, clock clockwork.Clock) (etcdserverpb.Reque
 		)
 	}
 
-	var rec, sort, wait, dir, quorum, stream bool
+	var rec, sort, wait, waitExisting, dir, quorum, stream, coalesce, claim bool
 	if rec, err = getBool(r.Form, "recursive"); err != nil {
 		return emptyReq, false, v2error.NewRequestError(
 			v2error.EcodeInvalidField,
@@ -420,6 +756,12 @@ func parseKeyRequest(r *http.Request, clock clockwork.Clock) (etcdserverpb.Reque
 			`invalid value for "wait"`,
 		)
 	}
+	if waitExisting, err = getBool(r.Form, "waitExisting"); err != nil {
+		return emptyReq, false, v2error.NewRequestError(
+			v2error.EcodeInvalidField,
+			`invalid value for "waitExisting"`,
+		)
+	}
 	// TODO(jonboulle): define what parameters dir is/isn't compatible with?
 	if dir, err = getBool(r.Form, "dir"); err != nil {
 		return emptyReq, false, v2error.NewRequestError(
@@ -439,6 +781,18 @@ func parseKeyRequest(r *http.Request, clock clockwork.Clock) (etcdserverpb.Reque
 			`invalid value for "stream"`,
 		)
 	}
+	if coalesce, err = getBool(r.Form, "coalesce"); err != nil {
+		return emptyReq, false, v2error.NewRequestError(
+			v2error.EcodeInvalidField,
+			`invalid value for "coalesce"`,
+		)
+	}
+	if claim, err = getBool(r.Form, "claim"); err != nil {
+		return emptyReq, false, v2error.NewRequestError(
+			v2error.EcodeInvalidField,
+			`invalid value for "claim"`,
+		)
+	}
 
 	if wait && r.Method != "GET" {
 		return emptyReq, false, v2error.NewRequestError(
@@ -447,6 +801,55 @@ func parseKeyRequest(r *http.Request, clock clockwork.Clock) (etcdserverpb.Reque
 		)
 	}
 
+	if waitExisting && (!wait || rec || stream || wIdx != 0) {
+		return emptyReq, false, v2error.NewRequestError(
+			v2error.EcodeInvalidField,
+			`"waitExisting" can only be used with "wait", and without "recursive", "stream" or "waitIndex"`,
+		)
+	}
+
+	if coalesce && (!wait || !stream) {
+		return emptyReq, false, v2error.NewRequestError(
+			v2error.EcodeInvalidField,
+			`"coalesce" can only be used with "wait" and "stream"`,
+		)
+	}
+
+	if claim && r.Method != "DELETE" {
+		return emptyReq, false, v2error.NewRequestError(
+			v2error.EcodeInvalidField,
+			`"claim" can only be used with DELETE requests`,
+		)
+	}
+
+	// implicitDirs is nullable, so that the member default applies if
+	// not specified
+	var implicitDirs *bool
+	if _, ok := r.Form["implicitDirs"]; ok {
+		bv, err := getBool(r.Form, "implicitDirs")
+		if err != nil {
+			return emptyReq, false, v2error.NewRequestError(
+				v2error.EcodeInvalidField,
+				`invalid value for "implicitDirs"`,
+			)
+		}
+		if r.Method != "PUT" && r.Method != "POST" {
+			return emptyReq, false, v2error.NewRequestError(
+				v2error.EcodeInvalidField,
+				`"implicitDirs" can only be used with PUT or POST requests`,
+			)
+		}
+		implicitDirs = &bv
+	}
+
+	reqID := r.FormValue("requestId")
+	if reqID != "" && (r.Method == "GET" || r.Method == "HEAD") {
+		return emptyReq, false, v2error.NewRequestError(
+			v2error.EcodeInvalidField,
+			`"requestId" can only be used with PUT, POST or DELETE requests`,
+		)
+	}
+
 	pV := r.FormValue("prevValue")
 	if _, ok := r.Form["prevValue"]; ok && pV == "" {
 		return emptyReq, false, v2error.NewRequestError(
@@ -518,19 +921,22 @@ func parseKeyRequest(r *http.Request, clock clockwork.Clock) (etcdserverpb.Reque
 	}
 
 	rr := etcdserverpb.Request{
-		Method:    r.Method,
-		Path:      p,
-		Val:       r.FormValue("value"),
-		Dir:       dir,
-		PrevValue: pV,
-		PrevIndex: pIdx,
-		PrevExist: pe,
-		Wait:      wait,
-		Since:     wIdx,
-		Recursive: rec,
-		Sorted:    sort,
-		Quorum:    quorum,
-		Stream:    stream,
+		Method:          r.Method,
+		Path:            p,
+		Val:             r.FormValue("value"),
+		Dir:             dir,
+		PrevValue:       pV,
+		PrevIndex:       pIdx,
+		PrevExist:       pe,
+		Wait:            wait,
+		Since:           wIdx,
+		Recursive:       rec,
+		Sorted:          sort,
+		Quorum:          quorum,
+		Stream:          stream,
+		WaitExisting:    waitExisting,
+		ClientRequestID: reqID,
+		NoImplicitDirs:  implicitDirs != nil && !*implicitDirs,
 	}
 
 	if pe != nil {
@@ -541,6 +947,11 @@ func parseKeyRequest(r *http.Request, clock clockwork.Clock) (etcdserverpb.Reque
 		rr.Refresh = refresh
 	}
 
+	// a claim deletes the oldest key under the directory at p
+	if claim {
+		rr.Method = "CLAIM"
+	}
+
 	// Null TTL is equivalent to unset Expiration
 	if ttl != nil {
 		expr := time.Duration(*ttl) * time.Second
@@ -550,10 +961,10 @@ func parseKeyRequest(r *http.Request, clock clockwork.Clock) (etcdserverpb.Reque
 	return rr, noValueOnSuccess, nil
 }
 
-// writeKeyEvent trims the prefix of key path in a single Event under
-// StoreKeysPrefix, serializes it and writes the resulting JSON to the given
-// ResponseWriter, along with the appropriate headers.
-func writeKeyEvent(w http.ResponseWriter, resp etcdserver.Response, noValueOnSuccess bool) error {
+// writeKeyEvent trims the given prefix of key path in a single Event,
+// serializes it and writes the resulting JSON to the given ResponseWriter,
+// along with the appropriate headers.
+func writeKeyEvent(w http.ResponseWriter, resp etcdserver.Response, prefix string, noValueOnSuccess bool) error {
 	ev := resp.Event
 	if ev == nil {
 		return errors.New("cannot write empty Event")
@@ -567,7 +978,7 @@ func writeKeyEvent(w http.ResponseWriter, resp etcdserver.Response, noValueOnSuc
 		w.WriteHeader(http.StatusCreated)
 	}
 
-	ev = trimEventPrefix(ev, etcdserver.StoreKeysPrefix)
+	ev = trimEventPrefix(ev, prefix)
 	if noValueOnSuccess &&
 		(ev.Action == v2store.Set || ev.Action == v2store.CompareAndSwap ||
 			ev.Action == v2store.Create || ev.Action == v2store.Update) {
@@ -602,6 +1013,11 @@ func writeKeyError(lg *zap.Logger, w http.ResponseWriter, err error) {
 			} else {
 				mlog.MergeError(err)
 			}
+		case etcdserver.ErrTooManyForwardedProposals:
+			// the request may be retried once the leader catches up
+			w.Header().Set("Retry-After", "1")
+			httptypes.NewHTTPError(http.StatusServiceUnavailable, err.Error()).WriteTo(w)
+			return
 		default:
 			if lg != nil {
 				lg.Warn(
@@ -617,7 +1033,10 @@ func writeKeyError(lg *zap.Logger, w http.ResponseWriter, err error) {
 	}
 }
 
-func handleKeyWatch(ctx context.Context, lg *zap.Logger, w http.ResponseWriter, resp etcdserver.Response, stream bool) {
+// handleKeyWatch writes the events of the watcher of resp. If coalesce is
+// set, the events pending while the previous ones were written are reduced
+// to the last one of each key.
+func handleKeyWatch(ctx context.Context, lg *zap.Logger, w http.ResponseWriter, resp etcdserver.Response, prefix string, stream, coalesce bool) {
 	wa := resp.Watcher
 	defer wa.Remove()
 	ech := wa.EventChan()
@@ -650,24 +1069,67 @@ func handleKeyWatch(ctx context.Context, lg *zap.Logger, w http.ResponseWriter,
 				// send to the client in time. Then we simply end streaming.
 				return
 			}
-			ev = trimEventPrefix(ev, etcdserver.StoreKeysPrefix)
-			if err := json.NewEncoder(w).Encode(ev); err != nil {
-				// Should never be reached
-				if lg != nil {
-					lg.Warn("failed to encode event", zap.Error(err))
-				} else {
-					plog.Warningf("error writing event (%v)", err)
+			evs := []*v2store.Event{ev}
+			if coalesce {
+				var pending []*v2store.Event
+				pending, ok = drainEvents(ech)
+				evs = coalesceEvents(append(evs, pending...))
+			}
+			enc := json.NewEncoder(w)
+			for _, ev := range evs {
+				if err := enc.Encode(trimEventPrefix(ev, prefix)); err != nil {
+					// Should never be reached
+					if lg != nil {
+						lg.Warn("failed to encode event", zap.Error(err))
+					} else {
+						plog.Warningf("error writing event (%v)", err)
+					}
+					return
 				}
-				return
 			}
 			if !stream {
 				return
 			}
 			w.(http.Flusher).Flush()
+			if !ok {
+				return
+			}
 		}
 	}
 }
 
+// drainEvents returns the events pending on ech without blocking, and false
+// if ech is closed.
+func drainEvents(ech <-chan *v2store.Event) ([]*v2store.Event, bool) {
+	var evs []*v2store.Event
+	for {
+		select {
+		case ev, ok := <-ech:
+			if !ok {
+				return evs, false
+			}
+			evs = append(evs, ev)
+		default:
+			return evs, true
+		}
+	}
+}
+
+// coalesceEvents keeps only the last of the events on each key, in order.
+func coalesceEvents(evs []*v2store.Event) []*v2store.Event {
+	last := make(map[string]int, len(evs))
+	for i, ev := range evs {
+		last[ev.Node.Key] = i
+	}
+	coalesced := evs[:0]
+	for i, ev := range evs {
+		if last[ev.Node.Key] == i {
+			coalesced = append(coalesced, ev)
+		}
+	}
+	return coalesced
+}
+
 func trimEventPrefix(ev *v2store.Event, prefix string) *v2store.Event {
 	if ev == nil {
 		return nil
@@ -779,10 +1241,17 @@ func newMember(m *membership.Member) httptypes.Member {
 		Name:       m.Name,
 		PeerURLs:   make([]string, len(m.PeerURLs)),
 		ClientURLs: make([]string, len(m.ClientURLs)),
+		IsLearner:  m.IsLearner,
 	}
 
 	copy(tm.PeerURLs, m.PeerURLs)
 	copy(tm.ClientURLs, m.ClientURLs)
+	if len(m.Metadata) > 0 {
+		tm.Metadata = make(map[string]string, len(m.Metadata))
+		for k, v := range m.Metadata {
+			tm.Metadata[k] = v
+		}
+	}
 
 	return tm
 }
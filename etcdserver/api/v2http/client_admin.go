@@ -0,0 +1,700 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v2http
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.etcd.io/etcd/etcdserver"
+	"go.etcd.io/etcd/etcdserver/api"
+	"go.etcd.io/etcd/etcdserver/api/membership"
+	"go.etcd.io/etcd/etcdserver/api/v2auth"
+	"go.etcd.io/etcd/etcdserver/api/v2http/httptypes"
+	"go.etcd.io/etcd/etcdserver/api/v2store"
+	"go.etcd.io/etcd/etcdserver/etcdserverpb"
+	"go.etcd.io/etcd/pkg/types"
+
+	"go.uber.org/zap"
+)
+
+// adminHandler serves member-local administrative endpoints under
+// adminPrefix. Every endpoint requires root access.
+type adminHandler struct {
+	lg                    *zap.Logger
+	sec                   v2auth.Store
+	cluster               api.Cluster
+	timeout               time.Duration
+	clientCertAuthEnabled bool
+
+	// rc is nil if the server does not support runtime configuration.
+	rc etcdserver.RuntimeConfigurer
+	// rs is nil if the server does not report its raft status.
+	rs etcdserver.RaftStatusReporter
+	// am is nil if the server does not manage alarms.
+	am etcdserver.AlarmManager
+	// tk is nil if the server does not retain tombstones.
+	tk etcdserver.TombstoneKeeper
+	// ke is nil if the server does not export its keys.
+	ke etcdserver.KeyspaceExporter
+	// aa is nil if the server does not audit the apply of the entries.
+	aa etcdserver.ApplyAuditor
+	// oc is nil if the server does not cancel its operations.
+	oc etcdserver.OperationCanceler
+	// mu is nil if the server does not update the metadata of the members.
+	mu etcdserver.MemberMetadataUpdater
+	// fr is nil if the server does not record the mutations.
+	fr etcdserver.FlightRecorder
+	// ki is nil if the server does not expose the internals of its store.
+	ki etcdserver.KeyInspector
+	// pl is nil if the server does not list the proposals waited for.
+	pl etcdserver.ProposalLister
+}
+
+func handleAdmin(mux router, ah *adminHandler) {
+	if ah.rc != nil {
+		mux.HandleFunc(adminPrefix+"/config", ah.serveConfig)
+	}
+	if ah.rs != nil {
+		mux.HandleFunc(adminPrefix+"/raft/status", ah.serveRaftStatus)
+	}
+	if ah.am != nil {
+		mux.HandleFunc(adminPrefix+"/alarms", ah.serveAlarms)
+	}
+	if ah.tk != nil {
+		mux.HandleFunc(adminPrefix+"/tombstones", ah.serveTombstones)
+	}
+	if ah.ke != nil {
+		mux.HandleFunc(adminPrefix+"/export", ah.serveExport)
+	}
+	if ah.aa != nil {
+		mux.HandleFunc(adminPrefix+"/apply-audit", ah.serveApplyAudit)
+	}
+	if ah.oc != nil {
+		mux.HandleFunc(adminPrefix+"/operations", ah.serveOperations)
+	}
+	if ah.mu != nil {
+		mux.HandleFunc(adminPrefix+"/members/", ah.serveMemberMetadata)
+	}
+	if ah.fr != nil {
+		mux.HandleFunc(adminPrefix+"/flight-recorder", ah.serveFlightRecorder)
+	}
+	if ah.ki != nil {
+		mux.HandleFunc(adminPrefix+"/inspect", ah.serveInspect)
+	}
+	if ah.pl != nil {
+		mux.HandleFunc(adminPrefix+"/proposals", ah.serveProposals)
+	}
+}
+
+func (ah *adminHandler) checkRootAccess(w http.ResponseWriter, r *http.Request) bool {
+	if !hasRootAccess(ah.lg, ah.sec, r, ah.clientCertAuthEnabled) {
+		writeNoAuth(ah.lg, w, r)
+		return false
+	}
+	w.Header().Set("X-Etcd-Cluster-ID", ah.cluster.ID().String())
+	return true
+}
+
+func (ah *adminHandler) serveConfig(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r.Method, "GET", "PATCH") {
+		return
+	}
+	if !ah.checkRootAccess(w, r) {
+		return
+	}
+
+	var rc etcdserver.RuntimeConfig
+	switch r.Method {
+	case "GET":
+		rc = ah.rc.RuntimeConfig()
+
+	case "PATCH":
+		var in etcdserver.RuntimeConfig
+		d := json.NewDecoder(r.Body)
+		// reject settings that cannot be changed at runtime
+		d.DisallowUnknownFields()
+		if err := d.Decode(&in); err != nil {
+			writeError(ah.lg, w, r, httptypes.NewHTTPError(http.StatusBadRequest, err.Error()))
+			return
+		}
+		var err error
+		rc, err = ah.rc.UpdateRuntimeConfig(in)
+		switch {
+		case err == etcdserver.ErrUnknownLogLevel, err == etcdserver.ErrInvalidKeyRateLimit, err == etcdserver.ErrInvalidKeyQuota:
+			writeError(ah.lg, w, r, httptypes.NewHTTPError(http.StatusBadRequest, err.Error()))
+			return
+		case err != nil:
+			if ah.lg != nil {
+				ah.lg.Warn("failed to update runtime configuration", zap.Error(err))
+			} else {
+				plog.Errorf("error updating runtime configuration (%v)", err)
+			}
+			writeError(ah.lg, w, r, err)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(rc); err != nil {
+		if ah.lg != nil {
+			ah.lg.Warn("failed to encode runtime configuration", zap.Error(err))
+		} else {
+			plog.Warningf("failed to encode runtime configuration (%v)", err)
+		}
+	}
+}
+
+func (ah *adminHandler) serveRaftStatus(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r.Method, "GET") {
+		return
+	}
+	if !ah.checkRootAccess(w, r) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(ah.rs.RaftStatus()); err != nil {
+		if ah.lg != nil {
+			ah.lg.Warn("failed to encode raft status", zap.Error(err))
+		} else {
+			plog.Warningf("failed to encode raft status (%v)", err)
+		}
+	}
+}
+
+// alarm is the JSON representation of an alarm raised by a member.
+type alarm struct {
+	MemberID string `json:"memberID"`
+	Alarm    string `json:"alarm"`
+}
+
+// serveAlarms lists the active alarms on GET. On DELETE, it disarms the
+// active alarms matching the optional "member" and "alarm" query
+// parameters, and lists the disarmed ones.
+func (ah *adminHandler) serveAlarms(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r.Method, "GET", "DELETE") {
+		return
+	}
+	if !ah.checkRootAccess(w, r) {
+		return
+	}
+
+	var (
+		member types.ID
+		typ    = etcdserverpb.AlarmType_NONE
+	)
+	if r.Method == "DELETE" {
+		q := r.URL.Query()
+		if m := q.Get("member"); m != "" {
+			id, err := types.IDFromString(m)
+			if err != nil {
+				writeError(ah.lg, w, r, httptypes.NewHTTPError(http.StatusBadRequest, "invalid member ID "+m))
+				return
+			}
+			member = id
+		}
+		if a := q.Get("alarm"); a != "" {
+			v, ok := etcdserverpb.AlarmType_value[a]
+			if !ok || v == int32(etcdserverpb.AlarmType_NONE) {
+				writeError(ah.lg, w, r, httptypes.NewHTTPError(http.StatusBadRequest, "unknown alarm "+a))
+				return
+			}
+			typ = etcdserverpb.AlarmType(v)
+		}
+	}
+
+	alarms := []alarm{}
+	for _, m := range ah.am.Alarms() {
+		if r.Method == "GET" {
+			alarms = append(alarms, alarm{types.ID(m.MemberID).String(), m.Alarm.String()})
+			continue
+		}
+		if (member != 0 && types.ID(m.MemberID) != member) || (typ != etcdserverpb.AlarmType_NONE && m.Alarm != typ) {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), ah.timeout)
+		_, err := ah.am.Alarm(ctx, &etcdserverpb.AlarmRequest{
+			Action:   etcdserverpb.AlarmRequest_DEACTIVATE,
+			MemberID: m.MemberID,
+			Alarm:    m.Alarm,
+		})
+		cancel()
+		if err != nil {
+			if ah.lg != nil {
+				ah.lg.Warn(
+					"failed to disarm alarm",
+					zap.String("member-id", types.ID(m.MemberID).String()),
+					zap.String("alarm", m.Alarm.String()),
+					zap.Error(err),
+				)
+			} else {
+				plog.Errorf("error disarming alarm %v of %s (%v)", m.Alarm, types.ID(m.MemberID), err)
+			}
+			writeError(ah.lg, w, r, err)
+			return
+		}
+		alarms = append(alarms, alarm{types.ID(m.MemberID).String(), m.Alarm.String()})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(alarms); err != nil {
+		if ah.lg != nil {
+			ah.lg.Warn("failed to encode alarms", zap.Error(err))
+		} else {
+			plog.Warningf("failed to encode alarms (%v)", err)
+		}
+	}
+}
+
+// serveTombstones lists on GET the retained tombstones of the keys at or
+// under the "key" query parameter. On POST, it undeletes the key from its
+// tombstone of the "index" query parameter, or from the last one if not
+// given.
+func (ah *adminHandler) serveTombstones(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r.Method, "GET", "POST") {
+		return
+	}
+	if !ah.checkRootAccess(w, r) {
+		return
+	}
+
+	q := r.URL.Query()
+	key := path.Join(etcdserver.StoreKeysPrefix, q.Get("key"))
+
+	if r.Method == "POST" {
+		var index uint64
+		if i := q.Get("index"); i != "" {
+			var err error
+			if index, err = strconv.ParseUint(i, 10, 64); err != nil {
+				writeError(ah.lg, w, r, httptypes.NewHTTPError(http.StatusBadRequest, "invalid index "+i))
+				return
+			}
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), ah.timeout)
+		defer cancel()
+		resp, err := ah.tk.Undelete(ctx, key, index)
+		if err == etcdserver.ErrTombstonesDisabled {
+			writeError(ah.lg, w, r, httptypes.NewHTTPError(http.StatusNotFound, err.Error()))
+			return
+		}
+		if err != nil {
+			writeKeyError(ah.lg, w, trimErrorPrefix(err, etcdserver.StoreKeysPrefix))
+			return
+		}
+		if err = writeKeyEvent(w, resp, etcdserver.StoreKeysPrefix, false); err != nil {
+			if ah.lg != nil {
+				ah.lg.Warn("failed to write key event", zap.Error(err))
+			} else {
+				plog.Errorf("error writing event (%v)", err)
+			}
+		}
+		return
+	}
+
+	tss, err := ah.tk.Tombstones(key)
+	if err == etcdserver.ErrTombstonesDisabled {
+		writeError(ah.lg, w, r, httptypes.NewHTTPError(http.StatusNotFound, err.Error()))
+		return
+	}
+	if err != nil {
+		writeError(ah.lg, w, r, err)
+		return
+	}
+	for i := range tss {
+		tss[i].Event = trimEventPrefix(tss[i].Event, etcdserver.StoreKeysPrefix)
+		tss[i].Node = tss[i].Node.Clone()
+		trimNodeExternPrefix(tss[i].Node, etcdserver.StoreKeysPrefix)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(tss); err != nil {
+		if ah.lg != nil {
+			ah.lg.Warn("failed to encode tombstones", zap.Error(err))
+		} else {
+			plog.Warningf("failed to encode tombstones (%v)", err)
+		}
+	}
+}
+
+// serveInspect returns the internal state of the store about the key of
+// the "key" query parameter, which may not exist: its indexes, its TTL
+// deadline and the watchers receiving its events.
+func (ah *adminHandler) serveInspect(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r.Method, "GET") {
+		return
+	}
+	if !ah.checkRootAccess(w, r) {
+		return
+	}
+
+	key := path.Join(etcdserver.StoreKeysPrefix, r.URL.Query().Get("key"))
+	ki, err := ah.ki.InspectKey(key)
+	if err != nil {
+		writeError(ah.lg, w, r, err)
+		return
+	}
+	trimKey := func(k string) string {
+		if k = strings.TrimPrefix(k, etcdserver.StoreKeysPrefix); k == "" {
+			return "/"
+		}
+		return k
+	}
+	ki.Key = trimKey(ki.Key)
+	ws := ki.Watchers[:0]
+	for _, wi := range ki.Watchers {
+		// the watchers of the store outside of the keys are internal
+		if wi.Key == etcdserver.StoreKeysPrefix || strings.HasPrefix(wi.Key, etcdserver.StoreKeysPrefix+"/") {
+			wi.Key = trimKey(wi.Key)
+			ws = append(ws, wi)
+		}
+	}
+	ki.Watchers = ws
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(ki); err != nil {
+		if ah.lg != nil {
+			ah.lg.Warn("failed to encode key internals", zap.Error(err))
+		} else {
+			plog.Warningf("failed to encode key internals (%v)", err)
+		}
+	}
+}
+
+// serveApplyAudit lists the records of the entries applied at or above the
+// "from" query parameter. Given the records of another member in the body
+// of a POST, it returns the first entry whose results differ instead.
+func (ah *adminHandler) serveApplyAudit(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r.Method, "GET", "POST") {
+		return
+	}
+	if !ah.checkRootAccess(w, r) {
+		return
+	}
+
+	var from uint64
+	if f := r.URL.Query().Get("from"); f != "" {
+		var err error
+		if from, err = strconv.ParseUint(f, 10, 64); err != nil {
+			writeError(ah.lg, w, r, httptypes.NewHTTPError(http.StatusBadRequest, "invalid from "+f))
+			return
+		}
+	}
+	var remote []etcdserver.ApplyAuditRecord
+	if r.Method == "POST" {
+		if err := json.NewDecoder(r.Body).Decode(&remote); err != nil {
+			writeError(ah.lg, w, r, httptypes.NewHTTPError(http.StatusBadRequest, err.Error()))
+			return
+		}
+	}
+
+	local, err := ah.aa.ApplyAudit(from)
+	if err == etcdserver.ErrApplyAuditDisabled {
+		writeError(ah.lg, w, r, httptypes.NewHTTPError(http.StatusNotFound, err.Error()))
+		return
+	}
+	if err != nil {
+		writeError(ah.lg, w, r, err)
+		return
+	}
+
+	var v interface{} = local
+	if r.Method == "POST" {
+		v = etcdserver.CompareApplyAudit(local, remote)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		if ah.lg != nil {
+			ah.lg.Warn("failed to encode apply audit", zap.Error(err))
+		} else {
+			plog.Warningf("failed to encode apply audit (%v)", err)
+		}
+	}
+}
+
+// serveOperations lists the expensive operations in flight on GET. On
+// DELETE, it cancels the operation of the "id" query parameter.
+func (ah *adminHandler) serveOperations(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r.Method, "GET", "DELETE") {
+		return
+	}
+	if !ah.checkRootAccess(w, r) {
+		return
+	}
+
+	if r.Method == "DELETE" {
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			writeError(ah.lg, w, r, httptypes.NewHTTPError(http.StatusBadRequest, "missing operation id"))
+			return
+		}
+		err := ah.oc.CancelOperation(id)
+		if err == etcdserver.ErrOperationNotFound {
+			writeError(ah.lg, w, r, httptypes.NewHTTPError(http.StatusNotFound, "operation "+id+" not found"))
+			return
+		}
+		if err == etcdserver.ErrOperationNotCancelable {
+			writeError(ah.lg, w, r, httptypes.NewHTTPError(http.StatusConflict, "operation "+id+" already proposed, not cancelable"))
+			return
+		}
+		if err != nil {
+			writeError(ah.lg, w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(ah.oc.Operations()); err != nil {
+		if ah.lg != nil {
+			ah.lg.Warn("failed to encode operations", zap.Error(err))
+		} else {
+			plog.Warningf("failed to encode operations (%v)", err)
+		}
+	}
+}
+
+// serveMemberMetadata serves the metadata of a member at
+// adminPrefix/members/<id>/metadata: GET returns it as a JSON object of
+// strings, and PUT replaces it with the JSON object of the body.
+func (ah *adminHandler) serveMemberMetadata(w http.ResponseWriter, r *http.Request) {
+	p := strings.TrimPrefix(r.URL.Path, adminPrefix+"/members/")
+	if !strings.HasSuffix(p, "/metadata") {
+		http.NotFound(w, r)
+		return
+	}
+	if !allowMethod(w, r.Method, "GET", "PUT") {
+		return
+	}
+	if !ah.checkRootAccess(w, r) {
+		return
+	}
+	idStr := strings.TrimSuffix(p, "/metadata")
+	id, err := types.IDFromString(idStr)
+	if err != nil {
+		writeError(ah.lg, w, r, httptypes.NewHTTPError(http.StatusNotFound, fmt.Sprintf("No such member: %s", idStr)))
+		return
+	}
+
+	if r.Method == "PUT" {
+		if !api.IsCapabilityEnabled(api.MemberMetadataCapability) {
+			// older members fail to recover the metadata from snapshots
+			notCapable(w, r, api.MemberMetadataCapability)
+			return
+		}
+		var md map[string]string
+		if err = json.NewDecoder(r.Body).Decode(&md); err != nil {
+			writeError(ah.lg, w, r, httptypes.NewHTTPError(http.StatusBadRequest, err.Error()))
+			return
+		}
+		if err = membership.ValidateMetadata(md); err != nil {
+			writeError(ah.lg, w, r, httptypes.NewHTTPError(http.StatusBadRequest, err.Error()))
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), ah.timeout)
+		defer cancel()
+		_, err = ah.mu.UpdateMemberMetadata(ctx, id, md)
+		switch {
+		case err == membership.ErrIDNotFound:
+			writeError(ah.lg, w, r, httptypes.NewHTTPError(http.StatusNotFound, fmt.Sprintf("No such member: %s", id)))
+			return
+		case err == membership.ErrInvalidMetadata:
+			writeError(ah.lg, w, r, httptypes.NewHTTPError(http.StatusBadRequest, err.Error()))
+			return
+		case err != nil:
+			if ah.lg != nil {
+				ah.lg.Warn(
+					"failed to update member metadata",
+					zap.String("member-id", id.String()),
+					zap.Error(err),
+				)
+			} else {
+				plog.Errorf("error updating metadata of member %s (%v)", id, err)
+			}
+			writeError(ah.lg, w, r, err)
+			return
+		}
+	}
+
+	m := ah.cluster.Member(id)
+	if m == nil {
+		writeError(ah.lg, w, r, httptypes.NewHTTPError(http.StatusNotFound, fmt.Sprintf("No such member: %s", id)))
+		return
+	}
+	md := m.Metadata
+	if md == nil {
+		md = map[string]string{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err = json.NewEncoder(w).Encode(md); err != nil {
+		if ah.lg != nil {
+			ah.lg.Warn("failed to encode member metadata", zap.Error(err))
+		} else {
+			plog.Warningf("failed to encode member metadata (%v)", err)
+		}
+	}
+}
+
+// serveFlightRecorder lists the records of the last mutations, oldest
+// first.
+func (ah *adminHandler) serveFlightRecorder(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r.Method, "GET") {
+		return
+	}
+	if !ah.checkRootAccess(w, r) {
+		return
+	}
+
+	rs, err := ah.fr.Mutations()
+	if err == etcdserver.ErrFlightRecorderDisabled {
+		writeError(ah.lg, w, r, httptypes.NewHTTPError(http.StatusNotFound, err.Error()))
+		return
+	}
+	if err != nil {
+		writeError(ah.lg, w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err = json.NewEncoder(w).Encode(rs); err != nil {
+		if ah.lg != nil {
+			ah.lg.Warn("failed to encode flight recorder", zap.Error(err))
+		} else {
+			plog.Warningf("failed to encode flight recorder (%v)", err)
+		}
+	}
+}
+
+// defaultProposalsLimit is the number of proposals listed by default.
+const defaultProposalsLimit = 100
+
+// serveProposals lists the oldest proposals waited for, at most "limit" of
+// them, to find the proposals whose waits leaked or that are stuck.
+func (ah *adminHandler) serveProposals(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r.Method, "GET") {
+		return
+	}
+	if !ah.checkRootAccess(w, r) {
+		return
+	}
+
+	limit := defaultProposalsLimit
+	if l := r.URL.Query().Get("limit"); l != "" {
+		var err error
+		if limit, err = strconv.Atoi(l); err != nil || limit < 0 {
+			writeError(ah.lg, w, r, httptypes.NewHTTPError(http.StatusBadRequest, "invalid limit "+l))
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(ah.pl.PendingProposals(limit)); err != nil {
+		if ah.lg != nil {
+			ah.lg.Warn("failed to encode pending proposals", zap.Error(err))
+		} else {
+			plog.Warningf("failed to encode pending proposals (%v)", err)
+		}
+	}
+}
+
+const (
+	exportFormatJSON     = "json"
+	exportFormatProtobuf = "protobuf"
+)
+
+// serveExport streams the keys at or under the "key" query parameter,
+// parents first, for importing them into another cluster.
+//
+// In the "json" format, the default, every key is a JSON node on its own
+// line. In the "protobuf" format, every key is the etcdserverpb.Request
+// setting it, prefixed by its length as a uvarint; the indexes of the keys
+// are not part of this format.
+func (ah *adminHandler) serveExport(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r.Method, "GET") {
+		return
+	}
+	if !ah.checkRootAccess(w, r) {
+		return
+	}
+
+	q := r.URL.Query()
+	format := q.Get("format")
+	switch format {
+	case "":
+		format = exportFormatJSON
+	case exportFormatJSON, exportFormatProtobuf:
+	default:
+		writeError(ah.lg, w, r, httptypes.NewHTTPError(http.StatusBadRequest, "unknown format "+format))
+		return
+	}
+
+	key := path.Join(etcdserver.StoreKeysPrefix, q.Get("key"))
+	ex, err := ah.ke.ExportKeys(key)
+	if err != nil {
+		writeKeyError(ah.lg, w, trimErrorPrefix(err, etcdserver.StoreKeysPrefix))
+		return
+	}
+
+	if format == exportFormatJSON {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	} else {
+		w.Header().Set("Content-Type", "application/x-protobuf")
+	}
+	w.Header().Set("X-Etcd-Index", fmt.Sprint(ex.Index))
+
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	var lbuf [binary.MaxVarintLen64]byte
+	err = ex.Walk(func(n *v2store.NodeExtern) error {
+		if n.Key == etcdserver.StoreKeysPrefix {
+			// the root of the keys is not a key
+			return nil
+		}
+		trimNodeExternPrefix(n, etcdserver.StoreKeysPrefix)
+		if format == exportFormatJSON {
+			return enc.Encode(n)
+		}
+		req := etcdserverpb.Request{Method: "PUT", Path: n.Key, Dir: n.Dir}
+		if n.Value != nil {
+			req.Val = *n.Value
+		}
+		if n.Expiration != nil {
+			req.Expiration = n.Expiration.UnixNano()
+		}
+		b, err := req.Marshal()
+		if err != nil {
+			return err
+		}
+		bw.Write(lbuf[:binary.PutUvarint(lbuf[:], uint64(len(b)))])
+		_, err = bw.Write(b)
+		return err
+	})
+	if err == nil {
+		err = bw.Flush()
+	}
+	if err != nil {
+		if ah.lg != nil {
+			ah.lg.Warn("failed to export keys", zap.String("key", key), zap.Error(err))
+		} else {
+			plog.Warningf("failed to export keys under %s (%v)", key, err)
+		}
+	}
+}
@@ -0,0 +1,1077 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v2http
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go.etcd.io/etcd/etcdserver"
+	"go.etcd.io/etcd/etcdserver/api"
+	"go.etcd.io/etcd/etcdserver/api/membership"
+	"go.etcd.io/etcd/etcdserver/api/v2store"
+	"go.etcd.io/etcd/etcdserver/etcdserverpb"
+	"go.etcd.io/etcd/pkg/types"
+	"go.etcd.io/etcd/raft"
+	"go.etcd.io/etcd/raft/raftpb"
+
+	"go.uber.org/zap"
+)
+
+type fakeRuntimeConfigurer struct {
+	rc  etcdserver.RuntimeConfig
+	err error
+}
+
+func (c *fakeRuntimeConfigurer) RuntimeConfig() etcdserver.RuntimeConfig { return c.rc }
+
+func (c *fakeRuntimeConfigurer) UpdateRuntimeConfig(rc etcdserver.RuntimeConfig) (etcdserver.RuntimeConfig, error) {
+	if c.err != nil {
+		return etcdserver.RuntimeConfig{}, c.err
+	}
+	if rc.LogLevel != "" {
+		c.rc.LogLevel = rc.LogLevel
+	}
+	if rc.SnapshotCount != 0 {
+		c.rc.SnapshotCount = rc.SnapshotCount
+	}
+	if len(rc.CORS) != 0 {
+		c.rc.CORS = rc.CORS
+	}
+	return c.rc, nil
+}
+
+type fakeRaftStatusReporter struct {
+	st raft.Status
+}
+
+func (r *fakeRaftStatusReporter) RaftStatus() raft.Status { return r.st }
+
+type fakeAlarmManager struct {
+	alarms []*etcdserverpb.AlarmMember
+}
+
+func (am *fakeAlarmManager) Alarms() []*etcdserverpb.AlarmMember { return am.alarms }
+
+func (am *fakeAlarmManager) Alarm(ctx context.Context, r *etcdserverpb.AlarmRequest) (*etcdserverpb.AlarmResponse, error) {
+	for i, m := range am.alarms {
+		if m.MemberID == r.MemberID && m.Alarm == r.Alarm {
+			am.alarms = append(am.alarms[:i:i], am.alarms[i+1:]...)
+			break
+		}
+	}
+	return &etcdserverpb.AlarmResponse{}, nil
+}
+
+type fakeTombstoneKeeper struct {
+	st       v2store.Store
+	disabled bool
+}
+
+func (tk *fakeTombstoneKeeper) Tombstones(key string) ([]v2store.Tombstone, error) {
+	if tk.disabled {
+		return nil, etcdserver.ErrTombstonesDisabled
+	}
+	return tk.st.ListTombstones(key), nil
+}
+
+func (tk *fakeTombstoneKeeper) Undelete(ctx context.Context, key string, index uint64) (etcdserver.Response, error) {
+	if tk.disabled {
+		return etcdserver.Response{}, etcdserver.ErrTombstonesDisabled
+	}
+	ev, err := tk.st.Undelete(key, index)
+	return etcdserver.Response{Event: ev}, err
+}
+
+type fakeApplyAuditor struct {
+	records  []etcdserver.ApplyAuditRecord
+	disabled bool
+}
+
+func (aa *fakeApplyAuditor) ApplyAudit(from uint64) ([]etcdserver.ApplyAuditRecord, error) {
+	if aa.disabled {
+		return nil, etcdserver.ErrApplyAuditDisabled
+	}
+	for i, r := range aa.records {
+		if r.Index >= from {
+			return aa.records[i:], nil
+		}
+	}
+	return nil, nil
+}
+
+type fakeKeyspaceExporter struct {
+	st v2store.Store
+}
+
+func (ke *fakeKeyspaceExporter) ExportKeys(key string) (*v2store.KeyExport, error) {
+	return ke.st.Export(key)
+}
+
+type fakeKeyInspector struct {
+	st v2store.Store
+}
+
+func (ki *fakeKeyInspector) InspectKey(key string) (*v2store.KeyInternals, error) {
+	return ki.st.Inspect(key)
+}
+
+type fakeOperationCanceler struct {
+	ops []etcdserver.Operation
+}
+
+func (oc *fakeOperationCanceler) Operations() []etcdserver.Operation { return oc.ops }
+
+func (oc *fakeOperationCanceler) CancelOperation(id string) error {
+	for i, op := range oc.ops {
+		if op.ID == id {
+			oc.ops = append(oc.ops[:i:i], oc.ops[i+1:]...)
+			return nil
+		}
+	}
+	return etcdserver.ErrOperationNotFound
+}
+
+type fakeProposalLister struct {
+	pps []etcdserver.PendingProposal
+}
+
+func (pl *fakeProposalLister) PendingProposals(limit int) []etcdserver.PendingProposal {
+	if limit > 0 && len(pl.pps) > limit {
+		return pl.pps[:limit]
+	}
+	return pl.pps
+}
+
+type fakeMemberMetadataUpdater struct {
+	cluster *fakeCluster
+}
+
+func (mu *fakeMemberMetadataUpdater) UpdateMemberMetadata(ctx context.Context, id types.ID, md map[string]string) ([]*membership.Member, error) {
+	m := mu.cluster.Member(id)
+	if m == nil {
+		return nil, membership.ErrIDNotFound
+	}
+	m.Metadata = md
+	return mu.cluster.Members(), nil
+}
+
+func TestServeAdminConfig(t *testing.T) {
+	tests := []struct {
+		method string
+		body   string
+		auth   bool
+		err    error
+
+		wcode int
+		wbody string
+		wrc   etcdserver.RuntimeConfig
+	}{
+		{
+			method: "GET",
+			wcode:  http.StatusOK,
+			wbody:  `{"log-level":"info","snapshot-count":100000}`,
+			wrc:    etcdserver.RuntimeConfig{LogLevel: "info", SnapshotCount: 100000},
+		},
+		{
+			method: "PATCH",
+			body:   `{"log-level":"debug","cors":["http://a.com"]}`,
+			wcode:  http.StatusOK,
+			wbody:  `{"log-level":"debug","snapshot-count":100000,"cors":["http://a.com"]}`,
+			wrc:    etcdserver.RuntimeConfig{LogLevel: "debug", SnapshotCount: 100000, CORS: []string{"http://a.com"}},
+		},
+		// settings that cannot be changed at runtime
+		{
+			method: "PATCH",
+			body:   `{"data-dir":"/tmp"}`,
+			wcode:  http.StatusBadRequest,
+			wrc:    etcdserver.RuntimeConfig{LogLevel: "info", SnapshotCount: 100000},
+		},
+		{
+			method: "PATCH",
+			body:   `{"log-level":"verbose"}`,
+			err:    etcdserver.ErrUnknownLogLevel,
+			wcode:  http.StatusBadRequest,
+			wrc:    etcdserver.RuntimeConfig{LogLevel: "info", SnapshotCount: 100000},
+		},
+		{
+			method: "PUT",
+			body:   `{"log-level":"debug"}`,
+			wcode:  http.StatusMethodNotAllowed,
+			wrc:    etcdserver.RuntimeConfig{LogLevel: "info", SnapshotCount: 100000},
+		},
+		{
+			method: "PATCH",
+			body:   `{"log-level":"debug"}`,
+			auth:   true,
+			wcode:  http.StatusUnauthorized,
+			wrc:    etcdserver.RuntimeConfig{LogLevel: "info", SnapshotCount: 100000},
+		},
+	}
+
+	for i, tt := range tests {
+		rc := &fakeRuntimeConfigurer{
+			rc:  etcdserver.RuntimeConfig{LogLevel: "info", SnapshotCount: 100000},
+			err: tt.err,
+		}
+		ah := &adminHandler{
+			lg:      zap.NewExample(),
+			sec:     &mockAuthStore{enabled: tt.auth},
+			cluster: &fakeCluster{id: 1},
+			rc:      rc,
+		}
+		req, err := http.NewRequest(tt.method, adminPrefix+"/config", strings.NewReader(tt.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rw := httptest.NewRecorder()
+		ah.serveConfig(rw, req)
+
+		if rw.Code != tt.wcode {
+			t.Errorf("#%d: code = %d, want %d", i, rw.Code, tt.wcode)
+		}
+		if tt.wbody != "" {
+			if g := strings.TrimSpace(rw.Body.String()); g != tt.wbody {
+				t.Errorf("#%d: body = %s, want %s", i, g, tt.wbody)
+			}
+			if gcid := rw.Header().Get("X-Etcd-Cluster-ID"); gcid != "1" {
+				t.Errorf("#%d: cid = %s, want %s", i, gcid, "1")
+			}
+		}
+		if !reflect.DeepEqual(rc.rc, tt.wrc) {
+			t.Errorf("#%d: runtime config = %+v, want %+v", i, rc.rc, tt.wrc)
+		}
+	}
+}
+
+func TestServeAdminRaftStatus(t *testing.T) {
+	st := raft.Status{
+		ID:        1,
+		HardState: raftpb.HardState{Term: 2, Vote: 1, Commit: 10},
+		SoftState: raft.SoftState{Lead: 1, RaftState: raft.StateLeader},
+		Applied:   9,
+		Progress:  map[uint64]raft.Progress{2: {Match: 8, Next: 9}},
+	}
+	tests := []struct {
+		method string
+		auth   bool
+
+		wcode int
+		wbody string
+	}{
+		{
+			method: "GET",
+			wcode:  http.StatusOK,
+			wbody:  `{"id":"1","term":2,"vote":"1","commit":10,"lead":"1","raftState":"StateLeader","applied":9,"progress":{"2":{"match":8,"next":9,"state":"ProgressStateProbe"}},"leadtransferee":"0"}`,
+		},
+		{
+			method: "PUT",
+			wcode:  http.StatusMethodNotAllowed,
+		},
+		{
+			method: "GET",
+			auth:   true,
+			wcode:  http.StatusUnauthorized,
+		},
+	}
+
+	for i, tt := range tests {
+		ah := &adminHandler{
+			lg:      zap.NewExample(),
+			sec:     &mockAuthStore{enabled: tt.auth},
+			cluster: &fakeCluster{id: 1},
+			rs:      &fakeRaftStatusReporter{st: st},
+		}
+		req, err := http.NewRequest(tt.method, adminPrefix+"/raft/status", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rw := httptest.NewRecorder()
+		ah.serveRaftStatus(rw, req)
+
+		if rw.Code != tt.wcode {
+			t.Errorf("#%d: code = %d, want %d", i, rw.Code, tt.wcode)
+		}
+		if tt.wbody != "" {
+			if g := strings.TrimSpace(rw.Body.String()); g != tt.wbody {
+				t.Errorf("#%d: body = %s, want %s", i, g, tt.wbody)
+			}
+		}
+	}
+}
+
+func TestServeAdminAlarms(t *testing.T) {
+	tests := []struct {
+		method string
+		query  string
+		auth   bool
+
+		wcode   int
+		wbody   string
+		wremain int
+	}{
+		{
+			method:  "GET",
+			wcode:   http.StatusOK,
+			wbody:   `[{"memberID":"1","alarm":"NOSPACE"},{"memberID":"2","alarm":"NOSPACE"},{"memberID":"2","alarm":"CORRUPT"}]`,
+			wremain: 3,
+		},
+		{
+			method:  "DELETE",
+			wcode:   http.StatusOK,
+			wbody:   `[{"memberID":"1","alarm":"NOSPACE"},{"memberID":"2","alarm":"NOSPACE"},{"memberID":"2","alarm":"CORRUPT"}]`,
+			wremain: 0,
+		},
+		{
+			method:  "DELETE",
+			query:   "?member=2",
+			wcode:   http.StatusOK,
+			wbody:   `[{"memberID":"2","alarm":"NOSPACE"},{"memberID":"2","alarm":"CORRUPT"}]`,
+			wremain: 1,
+		},
+		{
+			method:  "DELETE",
+			query:   "?alarm=NOSPACE",
+			wcode:   http.StatusOK,
+			wbody:   `[{"memberID":"1","alarm":"NOSPACE"},{"memberID":"2","alarm":"NOSPACE"}]`,
+			wremain: 1,
+		},
+		{
+			method:  "DELETE",
+			query:   "?member=2&alarm=CORRUPT",
+			wcode:   http.StatusOK,
+			wbody:   `[{"memberID":"2","alarm":"CORRUPT"}]`,
+			wremain: 2,
+		},
+		{
+			method:  "DELETE",
+			query:   "?alarm=NONE",
+			wcode:   http.StatusBadRequest,
+			wremain: 3,
+		},
+		{
+			method:  "DELETE",
+			query:   "?member=xyz",
+			wcode:   http.StatusBadRequest,
+			wremain: 3,
+		},
+		{
+			method:  "PUT",
+			wcode:   http.StatusMethodNotAllowed,
+			wremain: 3,
+		},
+		{
+			method:  "DELETE",
+			auth:    true,
+			wcode:   http.StatusUnauthorized,
+			wremain: 3,
+		},
+	}
+
+	for i, tt := range tests {
+		am := &fakeAlarmManager{alarms: []*etcdserverpb.AlarmMember{
+			{MemberID: 1, Alarm: etcdserverpb.AlarmType_NOSPACE},
+			{MemberID: 2, Alarm: etcdserverpb.AlarmType_NOSPACE},
+			{MemberID: 2, Alarm: etcdserverpb.AlarmType_CORRUPT},
+		}}
+		ah := &adminHandler{
+			lg:      zap.NewExample(),
+			sec:     &mockAuthStore{enabled: tt.auth},
+			cluster: &fakeCluster{id: 1},
+			timeout: time.Second,
+			am:      am,
+		}
+		req, err := http.NewRequest(tt.method, adminPrefix+"/alarms"+tt.query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rw := httptest.NewRecorder()
+		ah.serveAlarms(rw, req)
+
+		if rw.Code != tt.wcode {
+			t.Errorf("#%d: code = %d, want %d", i, rw.Code, tt.wcode)
+		}
+		if tt.wbody != "" {
+			if g := strings.TrimSpace(rw.Body.String()); g != tt.wbody {
+				t.Errorf("#%d: body = %s, want %s", i, g, tt.wbody)
+			}
+		}
+		if len(am.alarms) != tt.wremain {
+			t.Errorf("#%d: remaining alarms = %d, want %d", i, len(am.alarms), tt.wremain)
+		}
+	}
+}
+
+func TestServeAdminTombstones(t *testing.T) {
+	tests := []struct {
+		method   string
+		query    string
+		auth     bool
+		disabled bool
+
+		wcode int
+		// wkeys are the keys of the listed tombstones, or of the
+		// undeleted node
+		wkeys []string
+	}{
+		{
+			method: "GET",
+			wcode:  http.StatusOK,
+			wkeys:  []string{"/foo", "/dir"},
+		},
+		{
+			method: "GET",
+			query:  "?key=/dir",
+			wcode:  http.StatusOK,
+			wkeys:  []string{"/dir"},
+		},
+		{
+			method: "POST",
+			query:  "?key=/dir",
+			wcode:  http.StatusCreated,
+			wkeys:  []string{"/dir"},
+		},
+		{
+			method: "POST",
+			query:  "?key=/foo&index=2",
+			wcode:  http.StatusCreated,
+			wkeys:  []string{"/foo"},
+		},
+		{
+			method: "POST",
+			query:  "?key=/foo&index=3",
+			wcode:  http.StatusNotFound,
+		},
+		{
+			method: "POST",
+			query:  "?key=/foo&index=x",
+			wcode:  http.StatusBadRequest,
+		},
+		{
+			method:   "GET",
+			disabled: true,
+			wcode:    http.StatusNotFound,
+		},
+		{
+			method: "PUT",
+			wcode:  http.StatusMethodNotAllowed,
+		},
+		{
+			method: "GET",
+			auth:   true,
+			wcode:  http.StatusUnauthorized,
+		},
+	}
+
+	for i, tt := range tests {
+		st := v2store.NewWithTombstones(time.Hour, etcdserver.StoreKeysPrefix)
+		st.Create("/1/foo", false, "bar", false, v2store.TTLOptionSet{ExpireTime: v2store.Permanent})
+		st.Delete("/1/foo", false, false)
+		st.Create("/1/dir/a", false, "b", false, v2store.TTLOptionSet{ExpireTime: v2store.Permanent})
+		st.Delete("/1/dir", true, true)
+
+		ah := &adminHandler{
+			lg:      zap.NewExample(),
+			sec:     &mockAuthStore{enabled: tt.auth},
+			cluster: &fakeCluster{id: 1},
+			timeout: time.Second,
+			tk:      &fakeTombstoneKeeper{st: st, disabled: tt.disabled},
+		}
+		req, err := http.NewRequest(tt.method, adminPrefix+"/tombstones"+tt.query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rw := httptest.NewRecorder()
+		ah.serveTombstones(rw, req)
+
+		if rw.Code != tt.wcode {
+			t.Errorf("#%d: code = %d, want %d", i, rw.Code, tt.wcode)
+			continue
+		}
+		if tt.wkeys == nil {
+			continue
+		}
+		var keys []string
+		if tt.method == "GET" {
+			var tss []v2store.Tombstone
+			if err := json.Unmarshal(rw.Body.Bytes(), &tss); err != nil {
+				t.Fatalf("#%d: %v", i, err)
+			}
+			for _, ts := range tss {
+				keys = append(keys, ts.Node.Key)
+			}
+		} else {
+			var ev v2store.Event
+			if err := json.Unmarshal(rw.Body.Bytes(), &ev); err != nil {
+				t.Fatalf("#%d: %v", i, err)
+			}
+			keys = append(keys, ev.Node.Key)
+			if _, err := st.Get(path.Join("/1", ev.Node.Key), true, false); err != nil {
+				t.Errorf("#%d: undeleted key not found (%v)", i, err)
+			}
+		}
+		if !reflect.DeepEqual(keys, tt.wkeys) {
+			t.Errorf("#%d: keys = %v, want %v", i, keys, tt.wkeys)
+		}
+	}
+}
+
+func TestServeAdminExport(t *testing.T) {
+	st := v2store.New(etcdserver.StoreKeysPrefix)
+	st.Create("/1/dir/a", false, "1", false, v2store.TTLOptionSet{ExpireTime: v2store.Permanent})
+	exp := time.Now().Add(time.Hour).Round(0)
+	st.Create("/1/foo", false, "bar", false, v2store.TTLOptionSet{ExpireTime: exp})
+
+	tests := []struct {
+		method string
+		query  string
+		auth   bool
+
+		wcode int
+		wkeys []string
+	}{
+		{method: "GET", wcode: http.StatusOK, wkeys: []string{"/dir", "/dir/a", "/foo"}},
+		{method: "GET", query: "?key=/dir&format=json", wcode: http.StatusOK, wkeys: []string{"/dir", "/dir/a"}},
+		{method: "GET", query: "?format=protobuf", wcode: http.StatusOK, wkeys: []string{"/dir", "/dir/a", "/foo"}},
+		{method: "GET", query: "?format=xml", wcode: http.StatusBadRequest},
+		{method: "GET", query: "?key=/nope", wcode: http.StatusNotFound},
+		{method: "POST", wcode: http.StatusMethodNotAllowed},
+		{method: "GET", auth: true, wcode: http.StatusUnauthorized},
+	}
+
+	for i, tt := range tests {
+		ah := &adminHandler{
+			lg:      zap.NewExample(),
+			sec:     &mockAuthStore{enabled: tt.auth},
+			cluster: &fakeCluster{id: 1},
+			timeout: time.Second,
+			ke:      &fakeKeyspaceExporter{st: st},
+		}
+		req, err := http.NewRequest(tt.method, adminPrefix+"/export"+tt.query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rw := httptest.NewRecorder()
+		ah.serveExport(rw, req)
+
+		if rw.Code != tt.wcode {
+			t.Errorf("#%d: code = %d, want %d", i, rw.Code, tt.wcode)
+			continue
+		}
+		if tt.wkeys == nil {
+			continue
+		}
+		if g := rw.Header().Get("X-Etcd-Index"); g != "2" {
+			t.Errorf("#%d: X-Etcd-Index = %s, want 2", i, g)
+		}
+
+		var reqs []etcdserverpb.Request
+		if strings.Contains(tt.query, "protobuf") {
+			b := rw.Body.Bytes()
+			for len(b) > 0 {
+				l, n := binary.Uvarint(b)
+				var r etcdserverpb.Request
+				if err := r.Unmarshal(b[n : n+int(l)]); err != nil {
+					t.Fatalf("#%d: %v", i, err)
+				}
+				reqs = append(reqs, r)
+				b = b[n+int(l):]
+			}
+		} else {
+			dec := json.NewDecoder(rw.Body)
+			for dec.More() {
+				var n v2store.NodeExtern
+				if err := dec.Decode(&n); err != nil {
+					t.Fatalf("#%d: %v", i, err)
+				}
+				r := etcdserverpb.Request{Method: "PUT", Path: n.Key, Dir: n.Dir}
+				if n.Value != nil {
+					r.Val = *n.Value
+				}
+				if n.Expiration != nil {
+					r.Expiration = n.Expiration.UnixNano()
+				}
+				reqs = append(reqs, r)
+			}
+		}
+
+		var keys []string
+		for _, r := range reqs {
+			keys = append(keys, r.Path)
+			if r.Path == "/foo" && (r.Val != "bar" || r.Expiration != exp.UnixNano()) {
+				t.Errorf("#%d: /foo exported as %+v, want bar expiring at %v", i, r, exp)
+			}
+		}
+		if !reflect.DeepEqual(keys, tt.wkeys) {
+			t.Errorf("#%d: keys = %v, want %v", i, keys, tt.wkeys)
+		}
+	}
+}
+
+func TestServeAdminProposals(t *testing.T) {
+	reg := time.Now().Add(-time.Minute).Round(0).UTC()
+	pps := []etcdserver.PendingProposal{
+		{ID: "1", Registered: reg, Age: "1m0s"},
+		{ID: "2", Registered: reg.Add(time.Second), Age: "59s"},
+	}
+	tests := []struct {
+		method string
+		query  string
+		auth   bool
+
+		wcode int
+		wpps  []etcdserver.PendingProposal
+	}{
+		{method: "GET", wcode: http.StatusOK, wpps: pps},
+		{method: "GET", query: "?limit=1", wcode: http.StatusOK, wpps: pps[:1]},
+		{method: "GET", query: "?limit=0", wcode: http.StatusOK, wpps: pps},
+		{method: "GET", query: "?limit=-1", wcode: http.StatusBadRequest},
+		{method: "GET", query: "?limit=a", wcode: http.StatusBadRequest},
+		{method: "DELETE", wcode: http.StatusMethodNotAllowed},
+		{method: "GET", auth: true, wcode: http.StatusUnauthorized},
+	}
+
+	for i, tt := range tests {
+		ah := &adminHandler{
+			lg:      zap.NewExample(),
+			sec:     &mockAuthStore{enabled: tt.auth},
+			cluster: &fakeCluster{id: 1},
+			timeout: time.Second,
+			pl:      &fakeProposalLister{pps: pps},
+		}
+		req, err := http.NewRequest(tt.method, adminPrefix+"/proposals"+tt.query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rw := httptest.NewRecorder()
+		ah.serveProposals(rw, req)
+
+		if rw.Code != tt.wcode {
+			t.Errorf("#%d: code = %d, want %d", i, rw.Code, tt.wcode)
+			continue
+		}
+		if tt.wcode != http.StatusOK {
+			continue
+		}
+		var g []etcdserver.PendingProposal
+		if err := json.NewDecoder(rw.Body).Decode(&g); err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if !reflect.DeepEqual(g, tt.wpps) {
+			t.Errorf("#%d: proposals = %+v, want %+v", i, g, tt.wpps)
+		}
+	}
+}
+
+func TestServeAdminInspect(t *testing.T) {
+	st := v2store.New(etcdserver.StoreKeysPrefix)
+	exp := time.Now().Add(time.Hour).Round(0).UTC()
+	st.Create("/1/dir/foo", false, "bar", false, v2store.TTLOptionSet{ExpireTime: exp})
+	if _, err := st.Watch("/1/dir", true, false, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.Watch("/1/dir/foo", false, true, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		method string
+		query  string
+		auth   bool
+
+		wcode int
+		wki   v2store.KeyInternals
+	}{
+		{
+			method: "GET", query: "?key=/dir/foo", wcode: http.StatusOK,
+			wki: v2store.KeyInternals{
+				Key: "/dir/foo", Exists: true, CreatedIndex: 1, ModifiedIndex: 1, Expiration: &exp,
+				Watchers: []v2store.WatcherInternals{
+					{Key: "/dir/foo", Stream: true, SinceIndex: 2, StartIndex: 1},
+					{Key: "/dir", Recursive: true, SinceIndex: 2, StartIndex: 1},
+				},
+				CurrentIndex: 1, HistoryStartIndex: 1, HistoryLastIndex: 1,
+			},
+		},
+		{
+			method: "GET", query: "?key=/nope", wcode: http.StatusOK,
+			wki: v2store.KeyInternals{
+				Key: "/nope", Watchers: []v2store.WatcherInternals{},
+				CurrentIndex: 1, HistoryStartIndex: 1, HistoryLastIndex: 1,
+			},
+		},
+		{method: "POST", wcode: http.StatusMethodNotAllowed},
+		{method: "GET", auth: true, wcode: http.StatusUnauthorized},
+	}
+
+	for i, tt := range tests {
+		ah := &adminHandler{
+			lg:      zap.NewExample(),
+			sec:     &mockAuthStore{enabled: tt.auth},
+			cluster: &fakeCluster{id: 1},
+			timeout: time.Second,
+			ki:      &fakeKeyInspector{st: st},
+		}
+		req, err := http.NewRequest(tt.method, adminPrefix+"/inspect"+tt.query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rw := httptest.NewRecorder()
+		ah.serveInspect(rw, req)
+
+		if rw.Code != tt.wcode {
+			t.Errorf("#%d: code = %d, want %d", i, rw.Code, tt.wcode)
+			continue
+		}
+		if tt.wcode != http.StatusOK {
+			continue
+		}
+		var ki v2store.KeyInternals
+		if err := json.NewDecoder(rw.Body).Decode(&ki); err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if !reflect.DeepEqual(ki, tt.wki) {
+			t.Errorf("#%d: internals = %+v, want %+v", i, ki, tt.wki)
+		}
+	}
+}
+
+func TestServeAdminApplyAudit(t *testing.T) {
+	records := []etcdserver.ApplyAuditRecord{
+		{Index: 5, Op: "put", EntryHash: 1},
+		{Index: 6, Op: "put", EntryHash: 2},
+		{Index: 7, Op: "txn", EntryHash: 3},
+	}
+	tests := []struct {
+		method   string
+		query    string
+		body     string
+		auth     bool
+		disabled bool
+
+		wcode int
+		// windexes are the indexes of the listed records
+		windexes []uint64
+		// wdivergence is the index of the first diverging entry, if posted
+		wdivergence uint64
+	}{
+		{
+			method:   "GET",
+			wcode:    http.StatusOK,
+			windexes: []uint64{5, 6, 7},
+		},
+		{
+			method:   "GET",
+			query:    "?from=6",
+			wcode:    http.StatusOK,
+			windexes: []uint64{6, 7},
+		},
+		{
+			method: "GET",
+			query:  "?from=x",
+			wcode:  http.StatusBadRequest,
+		},
+		{
+			method: "POST",
+			body:   `[{"index":6,"entryHash":2},{"index":7,"entryHash":3}]`,
+			wcode:  http.StatusOK,
+		},
+		{
+			method:      "POST",
+			body:        `[{"index":6,"entryHash":2},{"index":7,"entryHash":4}]`,
+			wcode:       http.StatusOK,
+			wdivergence: 7,
+		},
+		{
+			method: "POST",
+			body:   `{`,
+			wcode:  http.StatusBadRequest,
+		},
+		{
+			method:   "GET",
+			disabled: true,
+			wcode:    http.StatusNotFound,
+		},
+		{
+			method: "PUT",
+			wcode:  http.StatusMethodNotAllowed,
+		},
+		{
+			method: "GET",
+			auth:   true,
+			wcode:  http.StatusUnauthorized,
+		},
+	}
+
+	for i, tt := range tests {
+		ah := &adminHandler{
+			lg:      zap.NewExample(),
+			sec:     &mockAuthStore{enabled: tt.auth},
+			cluster: &fakeCluster{id: 1},
+			aa:      &fakeApplyAuditor{records: records, disabled: tt.disabled},
+		}
+		req, err := http.NewRequest(tt.method, adminPrefix+"/apply-audit"+tt.query, strings.NewReader(tt.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rw := httptest.NewRecorder()
+		ah.serveApplyAudit(rw, req)
+
+		if rw.Code != tt.wcode {
+			t.Errorf("#%d: code = %d, want %d", i, rw.Code, tt.wcode)
+			continue
+		}
+		if rw.Code != http.StatusOK {
+			continue
+		}
+		if tt.method == "GET" {
+			var rs []etcdserver.ApplyAuditRecord
+			if err := json.Unmarshal(rw.Body.Bytes(), &rs); err != nil {
+				t.Fatalf("#%d: %v", i, err)
+			}
+			var idxs []uint64
+			for _, r := range rs {
+				idxs = append(idxs, r.Index)
+			}
+			if !reflect.DeepEqual(idxs, tt.windexes) {
+				t.Errorf("#%d: indexes = %v, want %v", i, idxs, tt.windexes)
+			}
+			continue
+		}
+		var d etcdserver.ApplyAuditDivergence
+		if err := json.Unmarshal(rw.Body.Bytes(), &d); err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if d.FirstIndex != 6 || d.LastIndex != 7 {
+			t.Errorf("#%d: compared range = [%d, %d], want [6, 7]", i, d.FirstIndex, d.LastIndex)
+		}
+		var idx uint64
+		if d.Local != nil {
+			idx = d.Local.Index
+		}
+		if idx != tt.wdivergence {
+			t.Errorf("#%d: diverging index = %d, want %d", i, idx, tt.wdivergence)
+		}
+	}
+}
+
+func TestServeAdminOperations(t *testing.T) {
+	tests := []struct {
+		method string
+		query  string
+		auth   bool
+
+		wcode int
+		// wids are the IDs of the operations left in flight
+		wids []string
+	}{
+		{
+			method: "GET",
+			wcode:  http.StatusOK,
+			wids:   []string{"compaction-10", "recursive-delete-1"},
+		},
+		{
+			method: "DELETE",
+			query:  "?id=compaction-10",
+			wcode:  http.StatusNoContent,
+			wids:   []string{"recursive-delete-1"},
+		},
+		{
+			method: "DELETE",
+			query:  "?id=compaction-11",
+			wcode:  http.StatusNotFound,
+			wids:   []string{"compaction-10", "recursive-delete-1"},
+		},
+		{
+			method: "DELETE",
+			wcode:  http.StatusBadRequest,
+			wids:   []string{"compaction-10", "recursive-delete-1"},
+		},
+		{
+			method: "POST",
+			wcode:  http.StatusMethodNotAllowed,
+			wids:   []string{"compaction-10", "recursive-delete-1"},
+		},
+		{
+			method: "DELETE",
+			query:  "?id=compaction-10",
+			auth:   true,
+			wcode:  http.StatusUnauthorized,
+			wids:   []string{"compaction-10", "recursive-delete-1"},
+		},
+	}
+
+	for i, tt := range tests {
+		oc := &fakeOperationCanceler{ops: []etcdserver.Operation{
+			{ID: "compaction-10", Kind: etcdserver.OperationCompaction, Detail: "revision 10"},
+			{ID: "recursive-delete-1", Kind: etcdserver.OperationRecursiveDelete, Detail: "/foo"},
+		}}
+		ah := &adminHandler{
+			lg:      zap.NewExample(),
+			sec:     &mockAuthStore{enabled: tt.auth},
+			cluster: &fakeCluster{id: 1},
+			oc:      oc,
+		}
+		req, err := http.NewRequest(tt.method, adminPrefix+"/operations"+tt.query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rw := httptest.NewRecorder()
+		ah.serveOperations(rw, req)
+
+		if rw.Code != tt.wcode {
+			t.Errorf("#%d: code = %d, want %d", i, rw.Code, tt.wcode)
+		}
+		var ids []string
+		for _, op := range oc.ops {
+			ids = append(ids, op.ID)
+		}
+		if !reflect.DeepEqual(ids, tt.wids) {
+			t.Errorf("#%d: operations = %v, want %v", i, ids, tt.wids)
+		}
+		if tt.method != "GET" || rw.Code != http.StatusOK {
+			continue
+		}
+		var ops []etcdserver.Operation
+		if err := json.Unmarshal(rw.Body.Bytes(), &ops); err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if len(ops) != 2 || ops[1].Kind != etcdserver.OperationRecursiveDelete || ops[1].Detail != "/foo" {
+			t.Errorf("#%d: listed operations = %+v, want the two in flight", i, ops)
+		}
+	}
+}
+
+func TestServeAdminMemberMetadata(t *testing.T) {
+	api.EnableCapability(api.MemberMetadataCapability)
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		auth   bool
+
+		wcode int
+		// wmd is the metadata of member 1 after the request
+		wmd map[string]string
+	}{
+		{
+			method: "GET",
+			path:   "/members/1/metadata",
+			wcode:  http.StatusOK,
+			wmd:    map[string]string{"zone": "a"},
+		},
+		{
+			method: "PUT",
+			path:   "/members/1/metadata",
+			body:   `{"zone":"b","rack":"r1"}`,
+			wcode:  http.StatusOK,
+			wmd:    map[string]string{"zone": "b", "rack": "r1"},
+		},
+		{
+			method: "PUT",
+			path:   "/members/1/metadata",
+			body:   `{"bad key":"b"}`,
+			wcode:  http.StatusBadRequest,
+			wmd:    map[string]string{"zone": "a"},
+		},
+		{
+			method: "PUT",
+			path:   "/members/1/metadata",
+			body:   `{"zone":1}`,
+			wcode:  http.StatusBadRequest,
+			wmd:    map[string]string{"zone": "a"},
+		},
+		{
+			method: "GET",
+			path:   "/members/2/metadata",
+			wcode:  http.StatusNotFound,
+			wmd:    map[string]string{"zone": "a"},
+		},
+		{
+			method: "PUT",
+			path:   "/members/2/metadata",
+			body:   `{"zone":"b"}`,
+			wcode:  http.StatusNotFound,
+			wmd:    map[string]string{"zone": "a"},
+		},
+		{
+			method: "GET",
+			path:   "/members/1",
+			wcode:  http.StatusNotFound,
+			wmd:    map[string]string{"zone": "a"},
+		},
+		{
+			method: "DELETE",
+			path:   "/members/1/metadata",
+			wcode:  http.StatusMethodNotAllowed,
+			wmd:    map[string]string{"zone": "a"},
+		},
+		{
+			method: "PUT",
+			path:   "/members/1/metadata",
+			body:   `{"zone":"b"}`,
+			auth:   true,
+			wcode:  http.StatusUnauthorized,
+			wmd:    map[string]string{"zone": "a"},
+		},
+	}
+
+	for i, tt := range tests {
+		cl := &fakeCluster{
+			id: 1,
+			members: map[uint64]*membership.Member{
+				1: {ID: 1, Metadata: map[string]string{"zone": "a"}},
+			},
+		}
+		ah := &adminHandler{
+			lg:      zap.NewExample(),
+			sec:     &mockAuthStore{enabled: tt.auth},
+			cluster: cl,
+			timeout: time.Second,
+			mu:      &fakeMemberMetadataUpdater{cluster: cl},
+		}
+		req, err := http.NewRequest(tt.method, adminPrefix+tt.path, strings.NewReader(tt.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rw := httptest.NewRecorder()
+		ah.serveMemberMetadata(rw, req)
+
+		if rw.Code != tt.wcode {
+			t.Errorf("#%d: code = %d, want %d", i, rw.Code, tt.wcode)
+		}
+		if g := cl.members[1].Metadata; !reflect.DeepEqual(g, tt.wmd) {
+			t.Errorf("#%d: metadata = %v, want %v", i, g, tt.wmd)
+		}
+		if rw.Code != http.StatusOK {
+			continue
+		}
+		var md map[string]string
+		if err = json.NewDecoder(rw.Body).Decode(&md); err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if !reflect.DeepEqual(md, tt.wmd) {
+			t.Errorf("#%d: served metadata = %v, want %v", i, md, tt.wmd)
+		}
+	}
+}
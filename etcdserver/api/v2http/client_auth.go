@@ -21,6 +21,7 @@ import (
 	"strings"
 
 	"go.etcd.io/etcd/etcdserver/api"
+	"go.etcd.io/etcd/etcdserver/api/etcdhttp"
 	"go.etcd.io/etcd/etcdserver/api/v2auth"
 	"go.etcd.io/etcd/etcdserver/api/v2http/httptypes"
 
@@ -65,6 +66,7 @@ func userFromBasicAuth(lg *zap.Logger, sec v2auth.Store, r *http.Request) *v2aut
 		}
 		return nil
 	}
+	etcdhttp.SetAuthenticatedUser(r, username)
 	return &user
 }
 
@@ -140,12 +142,21 @@ func hasRootAccess(lg *zap.Logger, sec v2auth.Store, r *http.Request, clientCert
 }
 
 func hasKeyPrefixAccess(lg *zap.Logger, sec v2auth.Store, r *http.Request, key string, recursive, clientCertAuthEnabled bool) bool {
+	_, _, ok := authorizeKeyRequest(lg, sec, r, key, recursive, clientCertAuthEnabled)
+	return ok
+}
+
+// authorizeKeyRequest checks that the user issuing r may access key, and
+// returns the virtual root and the name of the user, empty for the guests
+// or if auth is disabled. The key is resolved under the virtual root
+// before the roles of the user are checked.
+func authorizeKeyRequest(lg *zap.Logger, sec v2auth.Store, r *http.Request, key string, recursive, clientCertAuthEnabled bool) (root, username string, ok bool) {
 	if sec == nil {
 		// No store means no auth available, eg, tests.
-		return true
+		return "", "", true
 	}
 	if !sec.AuthEnabled() {
-		return true
+		return "", "", true
 	}
 
 	var user *v2auth.User
@@ -154,14 +165,17 @@ func hasKeyPrefixAccess(lg *zap.Logger, sec v2auth.Store, r *http.Request, key s
 			user = userFromClientCertificate(lg, sec, r)
 		}
 		if user == nil {
-			return hasGuestAccess(lg, sec, r, key)
+			return "", "", hasGuestAccess(lg, sec, r, key)
 		}
 	} else {
 		user = userFromBasicAuth(lg, sec, r)
 		if user == nil {
-			return false
+			return "", "", false
 		}
 	}
+	if user.Root != "" {
+		key = path.Join(user.Root, key)
+	}
 
 	writeAccess := r.Method != "GET" && r.Method != "HEAD"
 	for _, roleName := range user.Roles {
@@ -171,10 +185,10 @@ func hasKeyPrefixAccess(lg *zap.Logger, sec v2auth.Store, r *http.Request, key s
 		}
 		if recursive {
 			if role.HasRecursiveAccess(key, writeAccess) {
-				return true
+				return user.Root, user.User, true
 			}
 		} else if role.HasKeyAccess(key, writeAccess) {
-			return true
+			return user.Root, user.User, true
 		}
 	}
 
@@ -187,7 +201,7 @@ func hasKeyPrefixAccess(lg *zap.Logger, sec v2auth.Store, r *http.Request, key s
 	} else {
 		plog.Warningf("auth: invalid access for user %s on key %s.", user.User, key)
 	}
-	return false
+	return "", "", false
 }
 
 func hasGuestAccess(lg *zap.Logger, sec v2auth.Store, r *http.Request, key string) bool {
@@ -227,7 +241,7 @@ func writeNoAuth(lg *zap.Logger, w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func handleAuth(mux *http.ServeMux, sh *authHandler) {
+func handleAuth(mux router, sh *authHandler) {
 	mux.HandleFunc(authPrefix+"/roles", authCapabilityHandler(sh.baseRoles))
 	mux.HandleFunc(authPrefix+"/roles/", authCapabilityHandler(sh.handleRoles))
 	mux.HandleFunc(authPrefix+"/users", authCapabilityHandler(sh.baseUsers))
@@ -403,6 +417,7 @@ func (sh *authHandler) forRole(w http.ResponseWriter, r *http.Request, role stri
 type userWithRoles struct {
 	User  string        `json:"user"`
 	Roles []v2auth.Role `json:"roles,omitempty"`
+	Root  string        `json:"root,omitempty"`
 }
 
 type usersCollections struct {
@@ -444,7 +459,7 @@ func (sh *authHandler) baseUsers(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		uwr := userWithRoles{User: user.User}
+		uwr := userWithRoles{User: user.User, Root: user.Root}
 		for _, roleName := range user.Roles {
 			var role v2auth.Role
 			role, err = sh.sec.GetRole(roleName)
@@ -514,7 +529,7 @@ func (sh *authHandler) forUser(w http.ResponseWriter, r *http.Request, user stri
 			return
 		}
 
-		uwr := userWithRoles{User: u.User}
+		uwr := userWithRoles{User: u.User, Root: u.Root}
 		for _, roleName := range u.Roles {
 			var role v2auth.Role
 			role, err = sh.sec.GetRole(roleName)
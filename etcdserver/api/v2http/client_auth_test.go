@@ -15,6 +15,7 @@
 package v2http
 
 import (
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/json"
@@ -29,9 +30,13 @@ import (
 	"sort"
 	"strings"
 	"testing"
+	"time"
 
+	"go.etcd.io/etcd/etcdserver"
 	"go.etcd.io/etcd/etcdserver/api"
 	"go.etcd.io/etcd/etcdserver/api/v2auth"
+	"go.etcd.io/etcd/etcdserver/api/v2store"
+	"go.etcd.io/etcd/etcdserver/etcdserverpb"
 
 	"go.uber.org/zap"
 )
@@ -911,3 +916,76 @@ func TestUserFromBasicAuth(t *testing.T) {
 		}
 	}
 }
+
+// pathServer records the path of the last request and returns it as the key
+// of the response.
+type pathServer struct {
+	resServer
+	path string
+}
+
+func (ps *pathServer) Do(_ context.Context, r etcdserverpb.Request) (etcdserver.Response, error) {
+	ps.path = r.Path
+	return etcdserver.Response{Event: &v2store.Event{Action: v2store.Get, Node: &v2store.NodeExtern{Key: r.Path}}}, nil
+}
+
+func TestServeKeysVirtualRoot(t *testing.T) {
+	sec := &mockAuthStore{
+		users: map[string]*v2auth.User{
+			"alice": {User: "alice", Password: "pass", Roles: []string{"tenant-a"}, Root: "/tenants/a"},
+			"bob":   {User: "bob", Password: "pass", Roles: []string{"tenant-a"}},
+		},
+		roles: map[string]*v2auth.Role{
+			"tenant-a": {
+				Role: "tenant-a",
+				Permissions: v2auth.Permissions{
+					KV: v2auth.RWPermission{Read: []string{"/tenants/a/*"}},
+				},
+			},
+		},
+		enabled: true,
+	}
+
+	tests := []struct {
+		user string
+
+		wcode int
+		wpath string
+		wkey  string
+	}{
+		{"alice", http.StatusOK, "/1/tenants/a/foo", "/foo"},
+		{"bob", http.StatusUnauthorized, "", ""},
+	}
+	for i, tt := range tests {
+		server := &pathServer{}
+		h := &keysHandler{
+			lg:      zap.NewExample(),
+			sec:     sec,
+			timeout: time.Hour,
+			server:  server,
+			cluster: &fakeCluster{id: 1},
+		}
+		req := mustNewRequest(t, "foo")
+		req.Header = make(http.Header)
+		req.SetBasicAuth(tt.user, "pass")
+		rw := httptest.NewRecorder()
+		h.ServeHTTP(rw, req)
+
+		if rw.Code != tt.wcode {
+			t.Errorf("#%d: code = %d, want %d", i, rw.Code, tt.wcode)
+		}
+		if server.path != tt.wpath {
+			t.Errorf("#%d: path = %q, want %q", i, server.path, tt.wpath)
+		}
+		if tt.wkey == "" {
+			continue
+		}
+		var ev v2store.Event
+		if err := json.NewDecoder(rw.Body).Decode(&ev); err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if ev.Node.Key != tt.wkey {
+			t.Errorf("#%d: key = %q, want %q", i, ev.Node.Key, tt.wkey)
+		}
+	}
+}
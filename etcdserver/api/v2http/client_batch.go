@@ -0,0 +1,120 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v2http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+
+	"go.etcd.io/etcd/etcdserver"
+	"go.etcd.io/etcd/etcdserver/api/v2error"
+	"go.etcd.io/etcd/etcdserver/api/v2http/httptypes"
+	"go.etcd.io/etcd/etcdserver/api/v2store"
+)
+
+// maxBatchGetKeys is the maximum number of keys of a batch get.
+const maxBatchGetKeys = 1000
+
+// batchGetResult is the node of a key of a batch get, or the error
+// getting it.
+type batchGetResult struct {
+	Key   string              `json:"key"`
+	Node  *v2store.NodeExtern `json:"node,omitempty"`
+	Error *v2error.Error      `json:"error,omitempty"`
+}
+
+type batchGetResponse struct {
+	Action  string           `json:"action"`
+	Index   uint64           `json:"index"`
+	Results []batchGetResult `json:"results"`
+}
+
+// serveBatchGet reads the keys of the "key" form values, relative to the
+// key of the request, at a single index. The directories are listed
+// without recursion, and the keys that cannot be read have their error
+// in place of their node.
+func (h *keysHandler) serveBatchGet(w http.ResponseWriter, r *http.Request) {
+	if h.batcher == nil {
+		writeError(h.lg, w, r, httptypes.NewHTTPError(http.StatusNotImplemented, "batch get is not supported"))
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeKeyError(h.lg, w, v2error.NewRequestError(v2error.EcodeInvalidForm, err.Error()))
+		return
+	}
+	keys := r.Form["key"]
+	if len(keys) == 0 {
+		writeKeyError(h.lg, w, v2error.NewRequestError(v2error.EcodeInvalidForm, `missing "key"`))
+		return
+	}
+	if len(keys) > maxBatchGetKeys {
+		writeKeyError(h.lg, w, v2error.NewRequestError(v2error.EcodeInvalidForm, fmt.Sprintf("at most %d keys can be read at once", maxBatchGetKeys)))
+		return
+	}
+	quorum, err := getBool(r.Form, "quorum")
+	if err != nil {
+		writeKeyError(h.lg, w, v2error.NewRequestError(v2error.EcodeInvalidField, `invalid value for "quorum"`))
+		return
+	}
+	if !quorum && h.applyLagger != nil && h.applyLagger.ApplyLagging() {
+		w.Header().Set("Retry-After", "1")
+		writeError(h.lg, w, r, httptypes.NewHTTPError(http.StatusServiceUnavailable, "member is applying committed entries"))
+		return
+	}
+
+	var prefix string
+	base := r.URL.Path[len(keysPrefix):]
+	paths := make([]string, len(keys))
+	for i, k := range keys {
+		p := path.Join("/", base, k)
+		root, _, ok := authorizeKeyRequest(h.lg, h.sec, r, p, false, h.clientCertAuthEnabled)
+		if !ok {
+			writeKeyNoAuth(w)
+			return
+		}
+		// keys of users with a virtual root are stored under it
+		prefix = path.Join(etcdserver.StoreKeysPrefix, root)
+		paths[i] = path.Join(prefix, p)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+	defer cancel()
+	evs, errs, err := h.batcher.BatchGet(ctx, paths, quorum)
+	if err != nil {
+		writeKeyError(h.lg, w, err)
+		return
+	}
+	resp := batchGetResponse{Action: "batchget", Results: make([]batchGetResult, len(keys))}
+	for i := range keys {
+		resp.Results[i].Key = path.Join("/", base, keys[i])
+		if errs[i] != nil {
+			e, ok := trimErrorPrefix(errs[i], prefix).(*v2error.Error)
+			if !ok {
+				e = v2error.NewError(v2error.EcodeRaftInternal, errs[i].Error(), 0)
+			}
+			resp.Results[i].Error = e
+			resp.Index = e.Index
+			continue
+		}
+		resp.Results[i].Node = trimEventPrefix(evs[i], prefix).Node
+		resp.Index = evs[i].EtcdIndex
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Etcd-Index", fmt.Sprint(resp.Index))
+	json.NewEncoder(w).Encode(resp)
+}
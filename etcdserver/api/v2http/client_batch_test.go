@@ -0,0 +1,87 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v2http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.etcd.io/etcd/etcdserver/api/v2error"
+	"go.etcd.io/etcd/etcdserver/api/v2store"
+
+	"go.uber.org/zap"
+)
+
+type storeBatcher struct {
+	st v2store.Store
+}
+
+func (b *storeBatcher) BatchGet(ctx context.Context, paths []string, quorum bool) ([]*v2store.Event, []error, error) {
+	evs, errs := b.st.GetMulti(paths, false)
+	return evs, errs, nil
+}
+
+func TestServeBatchGet(t *testing.T) {
+	st := v2store.New()
+	st.Set("/1/dir/a", false, "va", v2store.TTLOptionSet{ExpireTime: v2store.Permanent})
+	st.Set("/1/dir/b", false, "vb", v2store.TTLOptionSet{ExpireTime: v2store.Permanent})
+
+	h := &keysHandler{
+		lg:      zap.NewExample(),
+		timeout: time.Hour,
+		server:  &resServer{},
+		cluster: &fakeCluster{id: 1},
+		batcher: &storeBatcher{st: st},
+	}
+	req, _ := http.NewRequest("POST", "http://example.com/v2/keys/dir?op=batchget", strings.NewReader("key=a&key=b&key=c"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, req)
+	if rw.Code != http.StatusOK {
+		t.Fatalf("code = %d, want %d (%s)", rw.Code, http.StatusOK, rw.Body.String())
+	}
+
+	var resp batchGetResponse
+	if err := json.Unmarshal(rw.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Index != st.Index() || rw.Header().Get("X-Etcd-Index") != "2" {
+		t.Errorf("index = %d (header %q), want %d", resp.Index, rw.Header().Get("X-Etcd-Index"), st.Index())
+	}
+	if len(resp.Results) != 3 {
+		t.Fatalf("len(results) = %d, want 3", len(resp.Results))
+	}
+	for i, w := range []string{"va", "vb"} {
+		r := resp.Results[i]
+		if r.Node == nil || r.Node.Value == nil || *r.Node.Value != w || r.Node.Key != r.Key {
+			t.Errorf("#%d: result = %+v, want key %s with value %q", i, r, r.Key, w)
+		}
+	}
+	if r := resp.Results[2]; r.Key != "/dir/c" || r.Error == nil || r.Error.ErrorCode != v2error.EcodeKeyNotFound || r.Error.Cause != "/dir/c" {
+		t.Errorf("result = %+v, want key not found error for /dir/c", r)
+	}
+
+	req, _ = http.NewRequest("POST", "http://example.com/v2/keys?op=batchget", nil)
+	rw = httptest.NewRecorder()
+	h.ServeHTTP(rw, req)
+	if rw.Code != http.StatusBadRequest {
+		t.Errorf("code without keys = %d, want %d", rw.Code, http.StatusBadRequest)
+	}
+}
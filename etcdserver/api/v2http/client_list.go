@@ -0,0 +1,107 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v2http
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+
+	"go.etcd.io/etcd/etcdserver/api/v2error"
+	"go.etcd.io/etcd/etcdserver/api/v2store"
+)
+
+// listOptions sort and filter the nodes of a directory GET before they
+// are written, so that clients do not have to read the whole directory to
+// find the few nodes they need.
+type listOptions struct {
+	// sortBy is "key", "modifiedIndex" or "createdIndex", or empty to
+	// keep the order of the store.
+	sortBy string
+	desc   bool
+	// valuePrefix, if not empty, drops the keys whose value does not
+	// start with it. Directories are kept.
+	valuePrefix string
+}
+
+func parseListOptions(form url.Values) (listOptions, error) {
+	lo := listOptions{sortBy: form.Get("sortBy"), valuePrefix: form.Get("valuePrefix")}
+	switch lo.sortBy {
+	case "", "key", "modifiedIndex", "createdIndex":
+	default:
+		return lo, v2error.NewRequestError(v2error.EcodeInvalidField, `invalid value for "sortBy", expected "key", "modifiedIndex" or "createdIndex"`)
+	}
+	switch form.Get("order") {
+	case "", "asc":
+	case "desc":
+		lo.desc = true
+	default:
+		return lo, v2error.NewRequestError(v2error.EcodeInvalidField, `invalid value for "order", expected "asc" or "desc"`)
+	}
+	if lo.desc && lo.sortBy == "" {
+		lo.sortBy = "key"
+	}
+	return lo, nil
+}
+
+func (lo listOptions) empty() bool { return lo.sortBy == "" && lo.valuePrefix == "" }
+
+// apply returns a copy of the event of a directory GET with the nodes of
+// the directory, and of its subdirectories, sorted and filtered.
+func (lo listOptions) apply(ev *v2store.Event) *v2store.Event {
+	if lo.empty() || ev.Node == nil || !ev.Node.Dir {
+		return ev
+	}
+	ev = ev.Clone()
+	lo.applyNode(ev.Node)
+	return ev
+}
+
+func (lo listOptions) applyNode(n *v2store.NodeExtern) {
+	if lo.valuePrefix != "" {
+		nodes := n.Nodes[:0]
+		for _, c := range n.Nodes {
+			if c.Dir || (c.Value != nil && strings.HasPrefix(*c.Value, lo.valuePrefix)) {
+				nodes = append(nodes, c)
+			}
+		}
+		n.Nodes = nodes
+	}
+	for _, c := range n.Nodes {
+		if c.Dir {
+			lo.applyNode(c)
+		}
+	}
+	if lo.sortBy == "" {
+		return
+	}
+	sort.SliceStable(n.Nodes, func(i, j int) bool {
+		a, b := n.Nodes[i], n.Nodes[j]
+		if lo.desc {
+			a, b = b, a
+		}
+		switch lo.sortBy {
+		case "modifiedIndex":
+			if a.ModifiedIndex != b.ModifiedIndex {
+				return a.ModifiedIndex < b.ModifiedIndex
+			}
+		case "createdIndex":
+			if a.CreatedIndex != b.CreatedIndex {
+				return a.CreatedIndex < b.CreatedIndex
+			}
+		}
+		return a.Key < b.Key
+	})
+}
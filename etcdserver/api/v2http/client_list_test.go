@@ -0,0 +1,74 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v2http
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+
+	"go.etcd.io/etcd/etcdserver/api/v2store"
+)
+
+func TestListOptions(t *testing.T) {
+	val := func(s string) *string { return &s }
+	ev := &v2store.Event{
+		Action: v2store.Get,
+		Node: &v2store.NodeExtern{Key: "/d", Dir: true, Nodes: v2store.NodeExterns{
+			{Key: "/d/b", Value: val("on"), ModifiedIndex: 5, CreatedIndex: 2},
+			{Key: "/d/a", Value: val("off"), ModifiedIndex: 3, CreatedIndex: 3},
+			{Key: "/d/c", Value: val("on"), ModifiedIndex: 4, CreatedIndex: 4},
+			{Key: "/d/s", Dir: true, ModifiedIndex: 1, CreatedIndex: 1},
+		}},
+	}
+
+	tests := []struct {
+		query string
+
+		wkeys []string
+		werr  bool
+	}{
+		{"", []string{"/d/b", "/d/a", "/d/c", "/d/s"}, false},
+		{"sortBy=key", []string{"/d/a", "/d/b", "/d/c", "/d/s"}, false},
+		{"order=desc", []string{"/d/s", "/d/c", "/d/b", "/d/a"}, false},
+		{"sortBy=modifiedIndex&order=desc", []string{"/d/b", "/d/c", "/d/a", "/d/s"}, false},
+		{"sortBy=createdIndex", []string{"/d/s", "/d/b", "/d/a", "/d/c"}, false},
+		{"valuePrefix=o&sortBy=key", []string{"/d/a", "/d/b", "/d/c", "/d/s"}, false},
+		{"valuePrefix=on", []string{"/d/b", "/d/c", "/d/s"}, false},
+		{"sortBy=value", nil, true},
+		{"order=up", nil, true},
+	}
+	for i, tt := range tests {
+		form, _ := url.ParseQuery(tt.query)
+		lo, err := parseListOptions(form)
+		if (err != nil) != tt.werr {
+			t.Fatalf("#%d: err = %v, want error %v", i, err, tt.werr)
+		}
+		if tt.werr {
+			continue
+		}
+		got := lo.apply(ev)
+		var keys []string
+		for _, n := range got.Node.Nodes {
+			keys = append(keys, n.Key)
+		}
+		if !reflect.DeepEqual(keys, tt.wkeys) {
+			t.Errorf("#%d: keys = %v, want %v", i, keys, tt.wkeys)
+		}
+	}
+	if len(ev.Node.Nodes) != 4 || ev.Node.Nodes[0].Key != "/d/b" {
+		t.Errorf("the event of the store was modified")
+	}
+}
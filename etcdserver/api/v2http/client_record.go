@@ -0,0 +1,79 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v2http
+
+import (
+	"net/http"
+	"time"
+
+	"go.etcd.io/etcd/etcdserver"
+	"go.etcd.io/etcd/etcdserver/api/v2error"
+)
+
+// statusRecorder records the status code of a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+func (sr *statusRecorder) WriteHeader(code int) {
+	if sr.code == 0 {
+		sr.code = code
+	}
+	sr.ResponseWriter.WriteHeader(code)
+}
+
+func (sr *statusRecorder) Write(b []byte) (int, error) {
+	if sr.code == 0 {
+		sr.code = http.StatusOK
+	}
+	return sr.ResponseWriter.Write(b)
+}
+
+func isV2Mutation(method string) bool {
+	switch method {
+	case "POST", "PUT", "DELETE", "CLAIM", "UNDELETE":
+		return true
+	}
+	return false
+}
+
+// recordMutation records the mutation of the key request r, received at
+// start, with its response: the status code written, and the response or
+// the error of the server.
+func (h *keysHandler) recordMutation(r *http.Request, method string, start time.Time, code int, resp etcdserver.Response, err error) {
+	rec := etcdserver.MutationRecord{
+		Time:   start,
+		API:    "v2",
+		Method: method,
+		Key:    r.URL.Path[len(keysPrefix):],
+		// the form holds the body and the query parameters, in key order
+		BodyHash: etcdserver.MutationBodyHash([]byte(r.Form.Encode())),
+		Code:     code,
+		Took:     time.Since(start),
+	}
+	switch {
+	case err != nil:
+		rec.Error = err.Error()
+		if e, ok := err.(*v2error.Error); ok {
+			rec.Index = e.Index
+		}
+	case resp.Event != nil:
+		rec.Index = resp.Event.Index()
+	default:
+		rec.Index = resp.Index
+	}
+	h.recorder.RecordMutation(rec)
+}
@@ -0,0 +1,116 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v2http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"go.etcd.io/etcd/etcdserver"
+	"go.etcd.io/etcd/etcdserver/api/v2error"
+	"go.etcd.io/etcd/etcdserver/api/v2store"
+
+	"go.uber.org/zap"
+)
+
+type fakeFlightRecorder struct {
+	records []etcdserver.MutationRecord
+}
+
+func (fr *fakeFlightRecorder) RecordsMutations() bool { return true }
+
+func (fr *fakeFlightRecorder) RecordMutation(r etcdserver.MutationRecord) {
+	fr.records = append(fr.records, r)
+}
+
+func (fr *fakeFlightRecorder) Mutations() ([]etcdserver.MutationRecord, error) {
+	return fr.records, nil
+}
+
+func TestServeKeysRecordMutations(t *testing.T) {
+	vals := url.Values{"value": {"bar"}}
+	tests := []struct {
+		req    *http.Request
+		server etcdserver.ServerV2
+
+		wrecorded bool
+		wcode     int
+		windex    uint64
+		werr      bool
+	}{
+		{
+			mustNewForm(t, "foo", vals),
+			&resServer{res: etcdserver.Response{Event: &v2store.Event{Action: v2store.Create, Node: &v2store.NodeExtern{Key: "/foo", ModifiedIndex: 7}}}},
+			true, http.StatusCreated, 7, false,
+		},
+		{
+			mustNewForm(t, "foo", vals),
+			&errServer{err: v2error.NewError(v2error.EcodeNodeExist, "/foo", 9)},
+			true, http.StatusPreconditionFailed, 9, true,
+		},
+		{
+			mustNewMethodRequest(t, "DELETE", "foo"),
+			&resServer{res: etcdserver.Response{Event: &v2store.Event{Action: v2store.Delete, Node: &v2store.NodeExtern{Key: "/foo", ModifiedIndex: 8}}}},
+			true, http.StatusOK, 8, false,
+		},
+		// reads are not recorded
+		{
+			mustNewRequest(t, "foo"),
+			&resServer{res: etcdserver.Response{Event: &v2store.Event{Action: v2store.Get, Node: &v2store.NodeExtern{Key: "/foo"}}}},
+			false, 0, 0, false,
+		},
+	}
+	for i, tt := range tests {
+		fr := &fakeFlightRecorder{}
+		h := &keysHandler{
+			lg:       zap.NewExample(),
+			timeout:  time.Hour,
+			server:   tt.server,
+			cluster:  &fakeCluster{id: 1},
+			recorder: fr,
+		}
+		rw := httptest.NewRecorder()
+		h.ServeHTTP(rw, tt.req)
+
+		if !tt.wrecorded {
+			if len(fr.records) != 0 {
+				t.Errorf("#%d: records = %+v, want none", i, fr.records)
+			}
+			continue
+		}
+		if len(fr.records) != 1 {
+			t.Fatalf("#%d: records = %+v, want one", i, fr.records)
+		}
+		r := fr.records[0]
+		if r.API != "v2" || r.Method != tt.req.Method || r.Key != "/foo" {
+			t.Errorf("#%d: record = %+v, want v2 %s /foo", i, r, tt.req.Method)
+		}
+		if r.Code != tt.wcode || r.Code != rw.Code {
+			t.Errorf("#%d: code = %d, want %d (written %d)", i, r.Code, tt.wcode, rw.Code)
+		}
+		if r.Index != tt.windex {
+			t.Errorf("#%d: index = %d, want %d", i, r.Index, tt.windex)
+		}
+		if (r.Error != "") != tt.werr {
+			t.Errorf("#%d: error = %q, want error %v", i, r.Error, tt.werr)
+		}
+		if r.BodyHash == "" {
+			t.Errorf("#%d: body hash is empty", i)
+		}
+	}
+}
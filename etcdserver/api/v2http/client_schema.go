@@ -0,0 +1,307 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v2http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"go.etcd.io/etcd/etcdserver/api/v2error"
+	"go.etcd.io/etcd/version"
+)
+
+const schemaPath = "/v2/schema"
+
+// router registers the handlers of the v2 API.
+type router interface {
+	Handle(pattern string, handler http.Handler)
+	HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request))
+}
+
+// routeRecorder is a router recording the registered patterns, from which
+// the schema of the v2 API is generated.
+type routeRecorder struct {
+	mux      *http.ServeMux
+	patterns []string
+}
+
+func (rr *routeRecorder) Handle(pattern string, handler http.Handler) {
+	rr.patterns = append(rr.patterns, pattern)
+	rr.mux.Handle(pattern, handler)
+}
+
+func (rr *routeRecorder) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	rr.Handle(pattern, http.HandlerFunc(handler))
+}
+
+// routeSchema describes the route registered on a pattern.
+type routeSchema struct {
+	// path is the OpenAPI path template of the route.
+	path    string
+	summary string
+	methods []string
+	params  []paramSchema
+}
+
+// paramSchema describes a parameter of a route.
+type paramSchema struct {
+	name string
+	// in is "path" or "query"; the query parameters of the keys API are
+	// also accepted in a form body.
+	in   string
+	typ  string
+	enum []string
+	desc string
+	// methods are the methods taking the parameter, all if empty.
+	methods []string
+}
+
+func queryParam(name, typ, desc string, methods ...string) paramSchema {
+	return paramSchema{name: name, in: "query", typ: typ, desc: desc, methods: methods}
+}
+
+func enumParam(name, desc string, enum []string, methods ...string) paramSchema {
+	return paramSchema{name: name, in: "query", typ: "string", enum: enum, desc: desc, methods: methods}
+}
+
+func pathParam(name, desc string) paramSchema {
+	return paramSchema{name: name, in: "path", typ: "string", desc: desc}
+}
+
+var keyParams = []paramSchema{
+	pathParam("key", "key, which may contain slashes"),
+	queryParam("value", "string", "value to set", "PUT", "POST"),
+	queryParam("ttl", "integer", "time to live of the key, in seconds", "PUT", "POST"),
+	queryParam("dir", "boolean", "whether the key is a directory", "PUT", "POST", "DELETE"),
+	queryParam("prevValue", "string", "value the key must have", "PUT", "DELETE"),
+	queryParam("prevIndex", "integer", "modified index the key must have", "PUT", "DELETE"),
+	queryParam("prevExist", "boolean", "whether the key must exist", "PUT"),
+	queryParam("refresh", "boolean", "refresh the TTL without notifying the watchers", "PUT"),
+	queryParam("noValueOnSuccess", "boolean", "omit the node from a successful response", "PUT", "POST", "DELETE"),
+	queryParam("implicitDirs", "boolean", "create the missing parent directories", "PUT", "POST"),
+	queryParam("requestId", "string", "ID making a retried write idempotent", "PUT", "POST", "DELETE"),
+	queryParam("claim", "boolean", "claim the oldest key of the directory, deleting and returning it", "DELETE"),
+	queryParam("wait", "boolean", "wait for a change of the key", "GET"),
+	queryParam("waitIndex", "integer", "index to watch from", "GET"),
+	queryParam("waitExisting", "boolean", "wait for a change of the key as it exists", "GET"),
+	queryParam("stream", "boolean", "stream the changes", "GET"),
+	queryParam("coalesce", "boolean", "coalesce the streamed changes", "GET"),
+	queryParam("recursive", "boolean", "apply to the keys of the directory", "GET", "HEAD", "DELETE"),
+	queryParam("sorted", "boolean", "sort the keys of the directory", "GET", "HEAD"),
+	enumParam("sortBy", "order of the listed keys", []string{"key", "modifiedIndex", "createdIndex"}, "GET", "HEAD"),
+	enumParam("order", "direction of the listing order", []string{"asc", "desc"}, "GET", "HEAD"),
+	queryParam("valuePrefix", "string", "list only the keys whose value has the prefix", "GET", "HEAD"),
+	queryParam("quorum", "boolean", "serve the read through consensus", "GET", "HEAD", "POST"),
+	enumParam("stale", "serve the read locally even if the member lags", []string{"allow"}, "GET", "HEAD"),
+	queryParam("max-lag", "integer", "number of entries a stale read may lag behind the leader", "GET", "HEAD"),
+	enumParam("op", "batch operation", []string{"batchget"}, "POST"),
+	queryParam("key", "string", "key of a batch get, repeated", "POST"),
+}
+
+var (
+	getOnly     = []string{"GET"}
+	authMethods = []string{"GET", "PUT", "DELETE"}
+	keysMethods = []string{"HEAD", "GET", "PUT", "POST", "DELETE"}
+)
+
+// v2Routes describes the routes of the v2 API, by registered pattern.
+var v2Routes = map[string]routeSchema{
+	keysPrefix:              {path: keysPrefix, summary: "Root directory of the keys", methods: keysMethods, params: keyParams[1:]},
+	keysPrefix + "/":        {path: keysPrefix + "/{key}", summary: "Keys", methods: keysMethods, params: keyParams},
+	statsPrefix + "/store":  {path: statsPrefix + "/store", summary: "Store statistics", methods: getOnly},
+	statsPrefix + "/self":   {path: statsPrefix + "/self", summary: "Member statistics", methods: getOnly},
+	statsPrefix + "/leader": {path: statsPrefix + "/leader", summary: "Leader statistics", methods: getOnly},
+	membersPrefix:           {path: membersPrefix, summary: "Cluster members", methods: []string{"GET", "POST"}},
+	membersPrefix + "/": {
+		path: membersPrefix + "/{id}", summary: "Cluster member", methods: []string{"GET", "DELETE", "PUT"},
+		params: []paramSchema{pathParam("id", `member ID, or "leader" with GET`)},
+	},
+	machinesPrefix:               {path: machinesPrefix, summary: "Client URLs of the members", methods: []string{"GET", "HEAD"}},
+	authPrefix + "/roles":        {path: authPrefix + "/roles", summary: "Roles", methods: getOnly},
+	authPrefix + "/roles/":       {path: authPrefix + "/roles/{role}", summary: "Role", methods: authMethods, params: []paramSchema{pathParam("role", "role name")}},
+	authPrefix + "/users":        {path: authPrefix + "/users", summary: "Users", methods: getOnly},
+	authPrefix + "/users/":       {path: authPrefix + "/users/{user}", summary: "User", methods: authMethods, params: []paramSchema{pathParam("user", "user name")}},
+	authPrefix + "/enable":       {path: authPrefix + "/enable", summary: "Authentication status", methods: authMethods},
+	adminPrefix + "/config":      {path: adminPrefix + "/config", summary: "Runtime configuration", methods: []string{"GET", "PATCH"}},
+	adminPrefix + "/raft/status": {path: adminPrefix + "/raft/status", summary: "Raft status of the member", methods: getOnly},
+	adminPrefix + "/alarms": {
+		path: adminPrefix + "/alarms", summary: "Alarms", methods: []string{"GET", "DELETE"},
+		params: []paramSchema{
+			queryParam("member", "string", "member of the alarms to disarm", "DELETE"),
+			queryParam("alarm", "string", "type of the alarms to disarm", "DELETE"),
+		},
+	},
+	adminPrefix + "/tombstones": {
+		path: adminPrefix + "/tombstones", summary: "Tombstones of the deleted keys", methods: []string{"GET", "POST"},
+		params: []paramSchema{
+			queryParam("key", "string", "key of the tombstones"),
+			queryParam("index", "integer", "index to purge the tombstones up to", "POST"),
+		},
+	},
+	adminPrefix + "/export": {
+		path: adminPrefix + "/export", summary: "Export of the keys", methods: getOnly,
+		params: []paramSchema{
+			enumParam("format", "format of the export", []string{exportFormatJSON, exportFormatProtobuf}),
+			queryParam("key", "string", "directory to export"),
+		},
+	},
+	adminPrefix + "/apply-audit": {
+		path: adminPrefix + "/apply-audit", summary: "Audit of the applied entries", methods: []string{"GET", "POST"},
+		params: []paramSchema{queryParam("from", "integer", "index to audit from")},
+	},
+	adminPrefix + "/operations": {
+		path: adminPrefix + "/operations", summary: "Long-running operations", methods: []string{"GET", "DELETE"},
+		params: []paramSchema{queryParam("id", "string", "ID of the operation to cancel", "DELETE")},
+	},
+	adminPrefix + "/members/": {
+		path: adminPrefix + "/members/{id}", summary: "Member metadata", methods: []string{"GET", "PUT"},
+		params: []paramSchema{pathParam("id", "member ID")},
+	},
+	adminPrefix + "/flight-recorder": {path: adminPrefix + "/flight-recorder", summary: "Recorded client mutations", methods: getOnly},
+	adminPrefix + "/inspect": {
+		path: adminPrefix + "/inspect", summary: "Indexes, TTL and watchers of a key", methods: getOnly,
+		params: []paramSchema{queryParam("key", "string", "key to inspect")},
+	},
+	adminPrefix + "/proposals": {
+		path: adminPrefix + "/proposals", summary: "Oldest proposals waited for", methods: getOnly,
+		params: []paramSchema{queryParam("limit", "integer", "maximum number of proposals listed, all if 0")},
+	},
+	schemaPath: {path: schemaPath, summary: "OpenAPI description of the v2 API", methods: getOnly},
+}
+
+type openAPIDoc struct {
+	OpenAPI    string                                 `json:"openapi"`
+	Info       openAPIInfo                            `json:"info"`
+	Paths      map[string]map[string]openAPIOperation `json:"paths"`
+	Components openAPIComponents                      `json:"components"`
+	// ErrorCodes are the codes of the errors returned by the v2 API.
+	ErrorCodes []errorCodeSchema `json:"x-etcd-error-codes"`
+}
+
+type openAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type openAPIOperation struct {
+	Summary    string                     `json:"summary"`
+	Parameters []openAPIParameter         `json:"parameters,omitempty"`
+	Responses  map[string]json.RawMessage `json:"responses"`
+}
+
+type openAPIParameter struct {
+	Name        string        `json:"name"`
+	In          string        `json:"in"`
+	Description string        `json:"description,omitempty"`
+	Required    bool          `json:"required,omitempty"`
+	Schema      openAPISchema `json:"schema"`
+}
+
+type openAPISchema struct {
+	Type       string                   `json:"type"`
+	Enum       []string                 `json:"enum,omitempty"`
+	Properties map[string]openAPISchema `json:"properties,omitempty"`
+}
+
+type openAPIComponents struct {
+	Schemas map[string]openAPISchema `json:"schemas"`
+}
+
+type errorCodeSchema struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Status  int    `json:"status"`
+}
+
+var schemaResponses = map[string]json.RawMessage{
+	"200": json.RawMessage(`{"description":"OK"}`),
+	"default": json.RawMessage(`{"description":"Error","content":{"application/json":` +
+		`{"schema":{"$ref":"#/components/schemas/Error"}}}}`),
+}
+
+// v2Schema returns the OpenAPI description of the routes registered on the
+// given patterns.
+func v2Schema(patterns []string) *openAPIDoc {
+	doc := &openAPIDoc{
+		OpenAPI: "3.0.0",
+		Info:    openAPIInfo{Title: "etcd v2 API", Version: version.Version},
+		Paths:   make(map[string]map[string]openAPIOperation),
+		Components: openAPIComponents{Schemas: map[string]openAPISchema{
+			"Error": {Type: "object", Properties: map[string]openAPISchema{
+				"errorCode": {Type: "integer"},
+				"message":   {Type: "string"},
+				"cause":     {Type: "string"},
+				"index":     {Type: "integer"},
+			}},
+		}},
+	}
+	for _, p := range patterns {
+		rs, ok := v2Routes[p]
+		if !ok {
+			// not described, so only its path is known
+			rs = routeSchema{path: p}
+		}
+		ops := make(map[string]openAPIOperation, len(rs.methods))
+		for _, m := range rs.methods {
+			op := openAPIOperation{Summary: rs.summary, Responses: schemaResponses}
+			for _, ps := range rs.params {
+				if !takesParam(ps, m) {
+					continue
+				}
+				op.Parameters = append(op.Parameters, openAPIParameter{
+					Name:        ps.name,
+					In:          ps.in,
+					Description: ps.desc,
+					Required:    ps.in == "path",
+					Schema:      openAPISchema{Type: ps.typ, Enum: ps.enum},
+				})
+			}
+			ops[strings.ToLower(m)] = op
+		}
+		doc.Paths[rs.path] = ops
+	}
+	for _, code := range v2error.Codes() {
+		err := v2error.NewError(code, "", 0)
+		doc.ErrorCodes = append(doc.ErrorCodes, errorCodeSchema{Code: code, Message: err.Message, Status: err.StatusCode()})
+	}
+	return doc
+}
+
+func takesParam(ps paramSchema, method string) bool {
+	if len(ps.methods) == 0 {
+		return true
+	}
+	for _, m := range ps.methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// schemaHandler serves the OpenAPI description of the v2 API, so that the
+// clients in other languages can be generated from it.
+type schemaHandler struct {
+	body []byte
+}
+
+func (h *schemaHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r.Method, "GET") {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(h.body)
+}
@@ -0,0 +1,116 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v2http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.etcd.io/etcd/etcdserver/api/v2error"
+
+	"go.uber.org/zap"
+)
+
+// TestV2RoutesDescribed ensures that every route of the v2 API is described
+// in its schema, so that the schema stays in sync with the router.
+func TestV2RoutesDescribed(t *testing.T) {
+	rr := &routeRecorder{mux: http.NewServeMux()}
+	handleAuth(rr, &authHandler{})
+	handleAdmin(rr, &adminHandler{
+		rc: &fakeRuntimeConfigurer{},
+		rs: &fakeRaftStatusReporter{},
+		am: &fakeAlarmManager{},
+		tk: &fakeTombstoneKeeper{},
+		ke: &fakeKeyspaceExporter{},
+		aa: &fakeApplyAuditor{},
+		oc: &fakeOperationCanceler{},
+		mu: &fakeMemberMetadataUpdater{},
+		fr: &fakeFlightRecorder{},
+		ki: &fakeKeyInspector{},
+		pl: &fakeProposalLister{},
+	})
+	if len(rr.patterns) != 16 {
+		t.Fatalf("registered %d auth and admin routes, want 16", len(rr.patterns))
+	}
+
+	mux := http.NewServeMux()
+	handleV2(zap.NewExample(), mux, &resServer{}, time.Hour)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + schemaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var doc openAPIDoc
+	if err = json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range rr.patterns {
+		if _, ok := v2Routes[p]; !ok {
+			t.Errorf("route %q is not described", p)
+		}
+	}
+	for _, p := range []string{keysPrefix + "/{key}", membersPrefix, authPrefix + "/enable", schemaPath} {
+		if _, ok := doc.Paths[p]; !ok {
+			t.Errorf("path %q missing from the schema", p)
+		}
+	}
+	for p, ops := range doc.Paths {
+		if len(ops) == 0 {
+			t.Errorf("path %q is not described", p)
+		}
+	}
+	if _, ok := doc.Paths[adminPrefix+"/config"]; ok {
+		t.Errorf("path %q not supported by the server is in the schema", adminPrefix+"/config")
+	}
+	if len(doc.ErrorCodes) == 0 || doc.ErrorCodes[0].Code != v2error.EcodeKeyNotFound || doc.ErrorCodes[0].Status != http.StatusNotFound {
+		t.Errorf("error codes = %+v, want key not found first", doc.ErrorCodes)
+	}
+}
+
+func TestV2SchemaParams(t *testing.T) {
+	doc := v2Schema([]string{keysPrefix + "/"})
+	ops := doc.Paths[keysPrefix+"/{key}"]
+	has := func(method, name string) bool {
+		for _, p := range ops[method].Parameters {
+			if p.Name == name {
+				return true
+			}
+		}
+		return false
+	}
+	tests := []struct {
+		method, name string
+		w            bool
+	}{
+		{"get", "key", true},
+		{"get", "wait", true},
+		{"put", "wait", false},
+		{"put", "prevExist", true},
+		{"delete", "claim", true},
+		{"get", "claim", false},
+		{"head", "recursive", true},
+	}
+	for i, tt := range tests {
+		if g := has(tt.method, tt.name); g != tt.w {
+			t.Errorf("#%d: %s takes %q = %v, want %v", i, tt.method, tt.name, g, tt.w)
+		}
+	}
+}
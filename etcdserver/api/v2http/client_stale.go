@@ -0,0 +1,99 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v2http
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"go.etcd.io/etcd/etcdserver/api/v2error"
+	"go.etcd.io/etcd/etcdserver/api/v2http/httptypes"
+	"go.etcd.io/etcd/raft"
+)
+
+// leaderLagReporter is implemented by servers that report how many
+// entries their applied index lags behind the commit index of the leader.
+type leaderLagReporter interface {
+	LeaderLag() uint64
+}
+
+// staleBound is the staleness a GET allows with "stale=allow": it is
+// served locally if the member is at most maxLag entries behind the
+// commit index of the leader, as last known to the member.
+type staleBound struct {
+	allow bool
+	// maxLag is only set if bounded is true.
+	bounded bool
+	maxLag  uint64
+}
+
+func parseStaleBound(form url.Values) (staleBound, error) {
+	var sb staleBound
+	switch form.Get("stale") {
+	case "":
+	case "allow":
+		sb.allow = true
+	default:
+		return sb, v2error.NewRequestError(v2error.EcodeInvalidField, `invalid value for "stale", expected "allow"`)
+	}
+	if ml := form.Get("max-lag"); ml != "" {
+		if !sb.allow {
+			return sb, v2error.NewRequestError(v2error.EcodeInvalidField, `"max-lag" requires "stale=allow"`)
+		}
+		n, err := strconv.ParseUint(ml, 10, 64)
+		if err != nil {
+			return sb, v2error.NewRequestError(v2error.EcodeInvalidField, `invalid value for "max-lag"`)
+		}
+		sb.bounded, sb.maxLag = true, n
+	}
+	if sb.allow {
+		if q, _ := getBool(form, "quorum"); q {
+			return sb, v2error.NewRequestError(v2error.EcodeInvalidField, `"stale=allow" conflicts with "quorum=true"`)
+		}
+	}
+	return sb, nil
+}
+
+// checkStaleRead returns true if the member may serve the GET allowing sb
+// locally. Otherwise it refuses the request with 503, so that the client
+// retries it on another member or with a quorum read. On a served bounded
+// read, it writes the number of entries the member lags behind in the
+// X-Etcd-Apply-Lag header.
+func (h *keysHandler) checkStaleRead(w http.ResponseWriter, r *http.Request, sb staleBound) bool {
+	if !sb.bounded {
+		return true
+	}
+	if h.lagReporter == nil {
+		writeError(h.lg, w, r, httptypes.NewHTTPError(http.StatusNotImplemented, "member does not report its lag behind the leader"))
+		return false
+	}
+	if uint64(h.server.Leader()) == raft.None {
+		// the last known commit index of the leader may be arbitrarily old
+		w.Header().Set("Retry-After", "1")
+		writeError(h.lg, w, r, httptypes.NewHTTPError(http.StatusServiceUnavailable, "member has no leader"))
+		return false
+	}
+	lag := h.lagReporter.LeaderLag()
+	if lag > sb.maxLag {
+		w.Header().Set("Retry-After", "1")
+		writeError(h.lg, w, r, httptypes.NewHTTPError(http.StatusServiceUnavailable,
+			fmt.Sprintf("member lags %d entries behind the leader, above max-lag %d", lag, sb.maxLag)))
+		return false
+	}
+	w.Header().Set("X-Etcd-Apply-Lag", strconv.FormatUint(lag, 10))
+	return true
+}
@@ -126,6 +126,10 @@ func (s *serverRecorder) RemoveMember(_ context.Context, id uint64) ([]*membersh
 	s.actions = append(s.actions, action{name: "RemoveMember", params: []interface{}{id}})
 	return nil, nil
 }
+func (s *serverRecorder) RemoveDeadMember(_ context.Context, id uint64, deadFor time.Duration) ([]*membership.Member, error) {
+	s.actions = append(s.actions, action{name: "RemoveDeadMember", params: []interface{}{id, deadFor}})
+	return nil, nil
+}
 
 func (s *serverRecorder) UpdateMember(_ context.Context, m membership.Member) ([]*membership.Member, error) {
 	s.actions = append(s.actions, action{name: "UpdateMember", params: []interface{}{m}})
@@ -165,6 +169,9 @@ func (rs *resServer) AddMember(_ context.Context, _ membership.Member) ([]*membe
 func (rs *resServer) RemoveMember(_ context.Context, _ uint64) ([]*membership.Member, error) {
 	return nil, nil
 }
+func (rs *resServer) RemoveDeadMember(_ context.Context, _ uint64, _ time.Duration) ([]*membership.Member, error) {
+	return nil, nil
+}
 func (rs *resServer) UpdateMember(_ context.Context, _ membership.Member) ([]*membership.Member, error) {
 	return nil, nil
 }
@@ -346,6 +353,59 @@ func TestBadParseRequest(t *testing.T) {
 			mustNewMethodRequest(t, "HEAD", "foo?wait=true"),
 			v2error.EcodeInvalidField,
 		},
+		// waitExisting is only valid with a non recursive wait
+		{
+			mustNewRequest(t, "foo?waitExisting=true"),
+			v2error.EcodeInvalidField,
+		},
+		{
+			mustNewRequest(t, "foo?wait=true&waitExisting=true&recursive=true"),
+			v2error.EcodeInvalidField,
+		},
+		{
+			mustNewRequest(t, "foo?wait=true&waitExisting=true&waitIndex=2"),
+			v2error.EcodeInvalidField,
+		},
+		{
+			mustNewRequest(t, "foo?waitExisting=bad"),
+			v2error.EcodeInvalidField,
+		},
+		// coalesce is only valid with a streaming wait
+		{
+			mustNewRequest(t, "foo?wait=true&coalesce=true"),
+			v2error.EcodeInvalidField,
+		},
+		{
+			mustNewRequest(t, "foo?stream=true&coalesce=true"),
+			v2error.EcodeInvalidField,
+		},
+		{
+			mustNewRequest(t, "foo?wait=true&stream=true&coalesce=bad"),
+			v2error.EcodeInvalidField,
+		},
+		// claim is only valid with DELETE requests
+		{
+			mustNewRequest(t, "foo?claim=true"),
+			v2error.EcodeInvalidField,
+		},
+		// requestId is only valid with writes
+		{
+			mustNewRequest(t, "foo?requestId=c0ffee"),
+			v2error.EcodeInvalidField,
+		},
+		// implicitDirs is only valid with PUT and POST requests
+		{
+			mustNewRequest(t, "foo?implicitDirs=false"),
+			v2error.EcodeInvalidField,
+		},
+		{
+			mustNewMethodRequest(t, "DELETE", "foo?implicitDirs=false"),
+			v2error.EcodeInvalidField,
+		},
+		{
+			mustNewForm(t, "foo", url.Values{"implicitDirs": []string{"bad"}}),
+			v2error.EcodeInvalidField,
+		},
 		// query values are considered
 		{
 			mustNewRequest(t, "foo?prevExist=wrong"),
@@ -491,6 +551,53 @@ func TestGoodParseRequest(t *testing.T) {
 			},
 			false,
 		},
+		{
+			// waitExisting specified
+			mustNewRequest(t, "foo?wait=true&waitExisting=true"),
+			etcdserverpb.Request{
+				Method:       "GET",
+				Wait:         true,
+				WaitExisting: true,
+				Path:         path.Join(etcdserver.StoreKeysPrefix, "/foo"),
+			},
+			false,
+		},
+		{
+			// requestId specified
+			mustNewPostForm(t, "foo", url.Values{"requestId": []string{"c0ffee"}}),
+			etcdserverpb.Request{
+				Method:          "POST",
+				Path:            path.Join(etcdserver.StoreKeysPrefix, "/foo"),
+				ClientRequestID: "c0ffee",
+			},
+			false,
+		},
+		{
+			mustNewForm(t, "foo", url.Values{"implicitDirs": []string{"false"}}),
+			etcdserverpb.Request{
+				Method:         "PUT",
+				NoImplicitDirs: true,
+				Path:           path.Join(etcdserver.StoreKeysPrefix, "/foo"),
+			},
+			false,
+		},
+		{
+			mustNewPostForm(t, "foo", url.Values{"implicitDirs": []string{"true"}}),
+			etcdserverpb.Request{
+				Method: "POST",
+				Path:   path.Join(etcdserver.StoreKeysPrefix, "/foo"),
+			},
+			false,
+		},
+		{
+			// claim specified
+			mustNewMethodRequest(t, "DELETE", "foo?claim=true"),
+			etcdserverpb.Request{
+				Method: "CLAIM",
+				Path:   path.Join(etcdserver.StoreKeysPrefix, "/foo"),
+			},
+			false,
+		},
 		{
 			// empty TTL specified
 			mustNewRequest(t, "foo?ttl="),
@@ -844,7 +951,7 @@ func TestServeMembersDelete(t *testing.T) {
 
 func TestServeMembersUpdate(t *testing.T) {
 	u := testutil.MustNewURL(t, path.Join(membersPrefix, "1"))
-	b := []byte(`{"peerURLs":["http://127.0.0.1:1"]}`)
+	b := []byte(`{"peerURLs":["http://127.0.0.1:1"],"name":"node1"}`)
 	req, err := http.NewRequest("PUT", u.String(), bytes.NewReader(b))
 	if err != nil {
 		t.Fatal(err)
@@ -877,6 +984,7 @@ func TestServeMembersUpdate(t *testing.T) {
 		RaftAttributes: membership.RaftAttributes{
 			PeerURLs: []string{"http://127.0.0.1:1"},
 		},
+		Attributes: membership.Attributes{Name: "node1"},
 	}
 
 	wactions := []action{{name: "UpdateMember", params: []interface{}{wm}}}
@@ -1168,7 +1276,7 @@ func TestServeMembersFail(t *testing.T) {
 func TestWriteEvent(t *testing.T) {
 	// nil event should not panic
 	rec := httptest.NewRecorder()
-	writeKeyEvent(rec, etcdserver.Response{}, false)
+	writeKeyEvent(rec, etcdserver.Response{}, etcdserver.StoreKeysPrefix, false)
 	h := rec.Header()
 	if len(h) > 0 {
 		t.Fatalf("unexpected non-empty headers: %#v", h)
@@ -1215,7 +1323,7 @@ func TestWriteEvent(t *testing.T) {
 	for i, tt := range tests {
 		rw := httptest.NewRecorder()
 		resp := etcdserver.Response{Event: tt.ev, Term: 5, Index: 100}
-		writeKeyEvent(rw, resp, tt.noValue)
+		writeKeyEvent(rw, resp, etcdserver.StoreKeysPrefix, tt.noValue)
 		if gct := rw.Header().Get("Content-Type"); gct != "application/json" {
 			t.Errorf("case %d: bad Content-Type: got %q, want application/json", i, gct)
 		}
@@ -1424,6 +1532,20 @@ func TestServeStoreStats(t *testing.T) {
 
 }
 
+// TestServeStoreStatsAuth ensures the store stats, which name keys, are only
+// served to root once auth is enabled.
+func TestServeStoreStatsAuth(t *testing.T) {
+	sh := &statsHandler{
+		sec:   &mockAuthStore{enabled: true},
+		stats: &dummyStats{data: []byte("some statistics")},
+	}
+	rw := httptest.NewRecorder()
+	sh.serveStore(rw, &http.Request{Method: "GET", Header: http.Header{}})
+	if rw.Code != http.StatusUnauthorized {
+		t.Errorf("code = %d, want %d", rw.Code, http.StatusUnauthorized)
+	}
+}
+
 func TestBadServeKeys(t *testing.T) {
 	testBadCases := []struct {
 		req    *http.Request
@@ -1642,6 +1764,61 @@ func TestServeKeysEvent(t *testing.T) {
 	}
 }
 
+func TestServeKeysCacheHeaders(t *testing.T) {
+	key := &v2store.NodeExtern{Key: "/foo", ModifiedIndex: 5}
+	dir := &v2store.NodeExtern{Key: "/dir", Dir: true, ModifiedIndex: 5}
+	tests := []struct {
+		node        *v2store.NodeExtern
+		ifNoneMatch string
+
+		wcode int
+		wetag string
+	}{
+		{key, "", http.StatusOK, `W/"5"`},
+		{key, `W/"4"`, http.StatusOK, `W/"5"`},
+		{key, `W/"5"`, http.StatusNotModified, `W/"5"`},
+		{key, `"5"`, http.StatusNotModified, `W/"5"`},
+		{key, `"4", W/"5"`, http.StatusNotModified, `W/"5"`},
+		{key, "*", http.StatusNotModified, `W/"5"`},
+		// directories have no ETag
+		{dir, `"5"`, http.StatusOK, ""},
+	}
+
+	server := &resServer{}
+	h := &keysHandler{
+		lg:           zap.NewExample(),
+		timeout:      time.Hour,
+		server:       server,
+		cluster:      &fakeCluster{id: 1},
+		cacheControl: "max-age=5",
+		etag:         true,
+	}
+
+	for i, tt := range tests {
+		server.res = etcdserver.Response{Event: &v2store.Event{Action: v2store.Get, Node: tt.node}}
+		req := mustNewRequest(t, "foo")
+		req.Header = http.Header{}
+		if tt.ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", tt.ifNoneMatch)
+		}
+		rw := httptest.NewRecorder()
+		h.ServeHTTP(rw, req)
+
+		if rw.Code != tt.wcode {
+			t.Errorf("#%d: code = %d, want %d", i, rw.Code, tt.wcode)
+		}
+		if g := rw.Header().Get("ETag"); g != tt.wetag {
+			t.Errorf("#%d: etag = %s, want %s", i, g, tt.wetag)
+		}
+		if g := rw.Header().Get("Cache-Control"); g != "max-age=5" {
+			t.Errorf("#%d: cache-control = %s, want %s", i, g, "max-age=5")
+		}
+		if tt.wcode == http.StatusNotModified && rw.Body.Len() != 0 {
+			t.Errorf("#%d: body = %q, want empty", i, rw.Body.String())
+		}
+	}
+}
+
 func TestServeKeysWatch(t *testing.T) {
 	req := mustNewRequest(t, "/foo/bar")
 	ec := make(chan *v2store.Event)
@@ -1692,6 +1869,59 @@ func TestServeKeysWatch(t *testing.T) {
 	}
 }
 
+func TestServeKeysWatchClientLimit(t *testing.T) {
+	ec := make(chan *v2store.Event)
+	h := &keysHandler{
+		lg:       zap.NewExample(),
+		timeout:  time.Hour,
+		server:   &resServer{res: etcdserver.Response{Watcher: &dummyWatcher{echan: ec}}},
+		cluster:  &fakeCluster{id: 1},
+		watchers: newClientWatchers(1),
+	}
+	watch := func(remote string) *httptest.ResponseRecorder {
+		req := mustNewRequest(t, "foo?wait=true")
+		req.RemoteAddr = remote
+		rw := httptest.NewRecorder()
+		h.ServeHTTP(rw, req)
+		return rw
+	}
+
+	donec := make(chan *httptest.ResponseRecorder)
+	go func() { donec <- watch("10.0.0.1:4001") }()
+	for {
+		h.watchers.mu.Lock()
+		n := h.watchers.counts["10.0.0.1"]
+		h.watchers.mu.Unlock()
+		if n == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// another connection of the same host
+	rw := watch("10.0.0.1:4002")
+	if rw.Code != http.StatusTooManyRequests {
+		t.Fatalf("code = %d, want %d", rw.Code, http.StatusTooManyRequests)
+	}
+	var verr v2error.Error
+	if err := json.Unmarshal(rw.Body.Bytes(), &verr); err != nil {
+		t.Fatal(err)
+	}
+	if verr.ErrorCode != v2error.EcodeClientWatchers || verr.Cause != "10.0.0.1" {
+		t.Errorf("error = %+v, want code %d for 10.0.0.1", verr, v2error.EcodeClientWatchers)
+	}
+
+	// the place is released once the watch is answered
+	ec <- &v2store.Event{Action: v2store.Get, Node: &v2store.NodeExtern{}}
+	if rw = <-donec; rw.Code != http.StatusOK {
+		t.Fatalf("code = %d, want %d", rw.Code, http.StatusOK)
+	}
+	go func() { ec <- &v2store.Event{Action: v2store.Get, Node: &v2store.NodeExtern{}} }()
+	if rw = watch("10.0.0.1:4003"); rw.Code != http.StatusOK {
+		t.Errorf("code = %d, want %d", rw.Code, http.StatusOK)
+	}
+}
+
 type recordingCloseNotifier struct {
 	*httptest.ResponseRecorder
 	cn chan bool
@@ -1782,7 +2012,7 @@ func TestHandleWatch(t *testing.T) {
 		tt.doToChan(wa.echan)
 
 		resp := etcdserver.Response{Term: 5, Index: 100, Watcher: wa}
-		handleKeyWatch(tt.getCtx(), zap.NewExample(), rw, resp, false)
+		handleKeyWatch(tt.getCtx(), zap.NewExample(), rw, resp, etcdserver.StoreKeysPrefix, false, false)
 
 		wcode := http.StatusOK
 		wct := "application/json"
@@ -1813,6 +2043,30 @@ func TestHandleWatch(t *testing.T) {
 	}
 }
 
+func TestCoalesceEvents(t *testing.T) {
+	ev := func(key string, idx uint64) *v2store.Event {
+		return &v2store.Event{Action: v2store.Set, Node: &v2store.NodeExtern{Key: key, ModifiedIndex: idx}}
+	}
+	tests := []struct {
+		evs  []*v2store.Event
+		widx []uint64
+	}{
+		{[]*v2store.Event{ev("/a", 1)}, []uint64{1}},
+		{[]*v2store.Event{ev("/a", 1), ev("/a", 2), ev("/a", 3)}, []uint64{3}},
+		{[]*v2store.Event{ev("/a", 1), ev("/b", 2), ev("/a", 3)}, []uint64{2, 3}},
+		{[]*v2store.Event{ev("/a", 1), ev("/b", 2), ev("/c", 3), ev("/b", 4)}, []uint64{1, 3, 4}},
+	}
+	for i, tt := range tests {
+		var idx []uint64
+		for _, ev := range coalesceEvents(tt.evs) {
+			idx = append(idx, ev.Node.ModifiedIndex)
+		}
+		if !reflect.DeepEqual(idx, tt.widx) {
+			t.Errorf("#%d: indexes = %v, want %v", i, idx, tt.widx)
+		}
+	}
+}
+
 func TestHandleWatchStreaming(t *testing.T) {
 	rw := &flushingRecorder{
 		httptest.NewRecorder(),
@@ -1827,7 +2081,7 @@ func TestHandleWatchStreaming(t *testing.T) {
 	done := make(chan struct{})
 	go func() {
 		resp := etcdserver.Response{Watcher: wa}
-		handleKeyWatch(ctx, zap.NewExample(), rw, resp, true)
+		handleKeyWatch(ctx, zap.NewExample(), rw, resp, etcdserver.StoreKeysPrefix, true, false)
 		close(done)
 	}()
 
@@ -2066,3 +2320,340 @@ func TestNewMember(t *testing.T) {
 		t.Fatalf("newMember failure: want=%#v, got=%#v", want, got)
 	}
 }
+
+func TestServeKeysWitness(t *testing.T) {
+	server := &resServer{res: etcdserver.Response{Event: &v2store.Event{Action: v2store.Get, Node: &v2store.NodeExtern{Key: "/foo"}}}}
+	h := &keysHandler{
+		lg:      zap.NewExample(),
+		timeout: time.Hour,
+		server:  server,
+		cluster: &fakeCluster{id: 1},
+		witness: true,
+	}
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, mustNewRequest(t, "foo"))
+	if rw.Code != http.StatusServiceUnavailable {
+		t.Errorf("code = %d, want %d", rw.Code, http.StatusServiceUnavailable)
+	}
+}
+
+type lagServer struct {
+	resServer
+	lagging bool
+}
+
+func (ls *lagServer) ApplyLagging() bool { return ls.lagging }
+
+func TestServeKeysApplyLag(t *testing.T) {
+	tests := []struct {
+		req     *http.Request
+		lagging bool
+
+		wcode int
+	}{
+		{mustNewRequest(t, "foo"), true, http.StatusServiceUnavailable},
+		{mustNewMethodRequest(t, "HEAD", "foo"), true, http.StatusServiceUnavailable},
+		{mustNewRequest(t, "foo?quorum=true"), true, http.StatusOK},
+		{mustNewRequest(t, "foo"), false, http.StatusOK},
+	}
+	for i, tt := range tests {
+		server := &lagServer{
+			resServer: resServer{res: etcdserver.Response{Event: &v2store.Event{Action: v2store.Get, Node: &v2store.NodeExtern{Key: "/foo"}}}},
+			lagging:   tt.lagging,
+		}
+		h := &keysHandler{
+			lg:          zap.NewExample(),
+			timeout:     time.Hour,
+			server:      server,
+			cluster:     &fakeCluster{id: 1},
+			applyLagger: server,
+		}
+		rw := httptest.NewRecorder()
+		h.ServeHTTP(rw, tt.req)
+		if rw.Code != tt.wcode {
+			t.Errorf("#%d: code = %d, want %d", i, rw.Code, tt.wcode)
+		}
+		if tt.wcode == http.StatusServiceUnavailable && rw.Header().Get("Retry-After") == "" {
+			t.Errorf("#%d: missing Retry-After header", i)
+		}
+	}
+}
+
+type staleLagServer struct {
+	resServer
+	lag    uint64
+	leader types.ID
+}
+
+func (ls *staleLagServer) LeaderLag() uint64  { return ls.lag }
+func (ls *staleLagServer) Leader() types.ID   { return ls.leader }
+func (ls *staleLagServer) ApplyLagging() bool { return ls.lag > 0 }
+
+func TestServeKeysStaleRead(t *testing.T) {
+	tests := []struct {
+		query  string
+		lag    uint64
+		leader types.ID
+
+		wcode int
+		wlag  string
+	}{
+		{"stale=allow&max-lag=10", 10, 1, http.StatusOK, "10"},
+		{"stale=allow&max-lag=10", 11, 1, http.StatusServiceUnavailable, ""},
+		{"stale=allow&max-lag=10", 0, 0, http.StatusServiceUnavailable, ""},
+		// unbounded stale reads are served whatever the lag
+		{"stale=allow", 1000, 0, http.StatusOK, ""},
+		// without stale=allow, the member refuses local reads while lagging
+		{"", 1, 1, http.StatusServiceUnavailable, ""},
+		{"max-lag=10", 0, 1, http.StatusBadRequest, ""},
+		{"stale=yes", 0, 1, http.StatusBadRequest, ""},
+		{"stale=allow&max-lag=x", 0, 1, http.StatusBadRequest, ""},
+		{"stale=allow&quorum=true", 0, 1, http.StatusBadRequest, ""},
+		// watches wait for the next change whatever the bound
+		{"stale=allow&max-lag=10&wait=true", 11, 1, http.StatusOK, ""},
+	}
+	for i, tt := range tests {
+		server := &staleLagServer{
+			resServer: resServer{res: etcdserver.Response{Event: &v2store.Event{Action: v2store.Get, Node: &v2store.NodeExtern{Key: "/foo"}}}},
+			lag:       tt.lag,
+			leader:    tt.leader,
+		}
+		h := &keysHandler{
+			lg:          zap.NewExample(),
+			timeout:     time.Hour,
+			server:      server,
+			cluster:     &fakeCluster{id: 1},
+			applyLagger: server,
+			lagReporter: server,
+		}
+		rw := httptest.NewRecorder()
+		h.ServeHTTP(rw, mustNewRequest(t, "foo?"+tt.query))
+		if rw.Code != tt.wcode {
+			t.Errorf("#%d: code = %d, want %d", i, rw.Code, tt.wcode)
+		}
+		if g := rw.Header().Get("X-Etcd-Apply-Lag"); g != tt.wlag {
+			t.Errorf("#%d: apply lag = %q, want %q", i, g, tt.wlag)
+		}
+	}
+}
+
+type overloadedServer struct {
+	resServer
+	overloaded bool
+}
+
+func (os *overloadedServer) ShedClientRequest() bool { return os.overloaded }
+
+func TestServeKeysShed(t *testing.T) {
+	tests := []struct {
+		req        *http.Request
+		overloaded bool
+
+		wcode int
+	}{
+		{mustNewRequest(t, "foo"), true, http.StatusServiceUnavailable},
+		{mustNewRequest(t, "foo?quorum=true"), true, http.StatusServiceUnavailable},
+		{mustNewMethodRequest(t, "DELETE", "foo"), true, http.StatusServiceUnavailable},
+		{mustNewMethodRequest(t, "POST", "foo?op=batchget"), true, http.StatusServiceUnavailable},
+		{mustNewRequest(t, "foo"), false, http.StatusOK},
+	}
+	for i, tt := range tests {
+		server := &overloadedServer{
+			resServer:  resServer{res: etcdserver.Response{Event: &v2store.Event{Action: v2store.Get, Node: &v2store.NodeExtern{Key: "/foo"}}}},
+			overloaded: tt.overloaded,
+		}
+		h := &keysHandler{
+			lg:      zap.NewExample(),
+			timeout: time.Hour,
+			server:  server,
+			cluster: &fakeCluster{id: 1},
+			shedder: server,
+		}
+		rw := httptest.NewRecorder()
+		h.ServeHTTP(rw, tt.req)
+		if rw.Code != tt.wcode {
+			t.Errorf("#%d: code = %d, want %d", i, rw.Code, tt.wcode)
+		}
+		if tt.wcode == http.StatusServiceUnavailable && rw.Header().Get("Retry-After") != "1" {
+			t.Errorf("#%d: Retry-After = %q, want 1", i, rw.Header().Get("Retry-After"))
+		}
+	}
+}
+
+type leaderTimeServer struct {
+	resServer
+	now time.Time
+	req etcdserverpb.Request
+}
+
+func (ls *leaderTimeServer) LeaderTime() time.Time { return ls.now }
+
+func (ls *leaderTimeServer) Do(_ context.Context, r etcdserverpb.Request) (etcdserver.Response, error) {
+	ls.req = r
+	return ls.res, nil
+}
+
+// TestServeKeysLeaderTime ensures expirations are computed from the time of
+// the leader, not from the local time.
+func TestServeKeysLeaderTime(t *testing.T) {
+	server := &leaderTimeServer{
+		resServer: resServer{res: etcdserver.Response{Event: &v2store.Event{Action: v2store.Set, Node: &v2store.NodeExtern{Key: "/foo"}}}},
+		now:       time.Unix(1000, 0),
+	}
+	h := &keysHandler{
+		lg:      zap.NewExample(),
+		timeout: time.Hour,
+		server:  server,
+		cluster: &fakeCluster{id: 1},
+		clock:   leaderClock{Clock: clockwork.NewRealClock(), lt: server},
+	}
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, mustNewForm(t, "foo", url.Values{"value": {"bar"}, "ttl": {"10"}}))
+	if rw.Code != http.StatusCreated {
+		t.Fatalf("code = %d, want %d", rw.Code, http.StatusCreated)
+	}
+	if w := time.Unix(1010, 0).UnixNano(); server.req.Expiration != w {
+		t.Errorf("expiration = %d, want %d", server.req.Expiration, w)
+	}
+}
+
+// TestServeKeysRequestID ensures the writes carrying a request ID are only
+// proposed once the whole cluster supports them.
+func TestServeKeysRequestID(t *testing.T) {
+	server := &leaderTimeServer{
+		resServer: resServer{res: etcdserver.Response{Event: &v2store.Event{Action: v2store.Create, Node: &v2store.NodeExtern{Key: "/queue/1"}}}},
+	}
+	h := &keysHandler{
+		lg:      zap.NewExample(),
+		timeout: time.Hour,
+		server:  server,
+		cluster: &fakeCluster{id: 1},
+	}
+	vals := url.Values{"value": {"bar"}, "requestId": {"c0ffee"}}
+
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, mustNewPostForm(t, "queue", vals))
+	if rw.Code != http.StatusInternalServerError {
+		t.Errorf("code = %d, want %d before the cluster supports request IDs", rw.Code, http.StatusInternalServerError)
+	}
+
+	api.EnableCapability(api.V2WriteOptionsCapability)
+	rw = httptest.NewRecorder()
+	h.ServeHTTP(rw, mustNewPostForm(t, "queue", vals))
+	if rw.Code != http.StatusCreated {
+		t.Fatalf("code = %d, want %d", rw.Code, http.StatusCreated)
+	}
+	if server.req.ClientRequestID != "c0ffee" || server.req.ClientRequestUser != "" {
+		t.Errorf("request ID = %q, user = %q, want c0ffee from a guest", server.req.ClientRequestID, server.req.ClientRequestUser)
+	}
+}
+
+// TestServeKeysExplicitDirs ensures the writes are marked to not create the
+// missing parent directories of their key by default if the member is
+// configured so, unless they set implicitDirs.
+func TestServeKeysExplicitDirs(t *testing.T) {
+	tests := []struct {
+		explicitDirs bool
+		vals         url.Values
+
+		wnoImplicitDirs bool
+	}{
+		{false, url.Values{"value": {"bar"}}, false},
+		{false, url.Values{"value": {"bar"}, "implicitDirs": {"false"}}, true},
+		{true, url.Values{"value": {"bar"}}, true},
+		{true, url.Values{"value": {"bar"}, "implicitDirs": {"true"}}, false},
+	}
+	api.EnableCapability(api.V2WriteOptionsCapability)
+	for i, tt := range tests {
+		server := &leaderTimeServer{
+			resServer: resServer{res: etcdserver.Response{Event: &v2store.Event{Action: v2store.Set, Node: &v2store.NodeExtern{Key: "/foo"}}}},
+		}
+		h := &keysHandler{
+			lg:           zap.NewExample(),
+			timeout:      time.Hour,
+			server:       server,
+			cluster:      &fakeCluster{id: 1},
+			explicitDirs: tt.explicitDirs,
+		}
+		rw := httptest.NewRecorder()
+		h.ServeHTTP(rw, mustNewForm(t, "dir/foo", tt.vals))
+		if rw.Code != http.StatusCreated {
+			t.Fatalf("#%d: code = %d, want %d", i, rw.Code, http.StatusCreated)
+		}
+		if server.req.NoImplicitDirs != tt.wnoImplicitDirs {
+			t.Errorf("#%d: noImplicitDirs = %v, want %v", i, server.req.NoImplicitDirs, tt.wnoImplicitDirs)
+		}
+		if server.req.Stream {
+			t.Errorf("#%d: write marked with stream", i)
+		}
+	}
+}
+
+type fakeLearnerReporter bool
+
+func (lr fakeLearnerReporter) IsLearner() bool { return bool(lr) }
+
+func TestServeKeysLearner(t *testing.T) {
+	voter := func(id uint64, u string) *membership.Member {
+		return &membership.Member{ID: types.ID(id), Attributes: membership.Attributes{ClientURLs: []string{u}}}
+	}
+	learner := voter(3, "http://10.0.0.3:2379")
+	learner.IsLearner = true
+	witness := voter(4, "http://10.0.0.4:2379")
+	witness.Witness = true
+
+	tests := []struct {
+		method  string
+		members map[uint64]*membership.Member
+
+		wcode     int
+		wlocation string
+	}{
+		// the writes are redirected to the leader, member 1
+		{
+			"PUT",
+			map[uint64]*membership.Member{1: voter(1, "http://10.0.0.1:2379"), 2: voter(2, "http://10.0.0.2:2379"), 3: learner},
+			http.StatusTemporaryRedirect,
+			"http://10.0.0.1:2379/v2/keys/foo?value=bar",
+		},
+		// or to another voting member
+		{
+			"DELETE",
+			map[uint64]*membership.Member{2: voter(2, "http://10.0.0.2:2379/"), 3: learner},
+			http.StatusTemporaryRedirect,
+			"http://10.0.0.2:2379/v2/keys/foo?value=bar",
+		},
+		{
+			"POST",
+			map[uint64]*membership.Member{3: learner, 4: witness},
+			http.StatusServiceUnavailable,
+			"",
+		},
+		// the reads are served
+		{
+			"GET",
+			map[uint64]*membership.Member{3: learner},
+			http.StatusOK,
+			"",
+		},
+	}
+	for i, tt := range tests {
+		server := &resServer{res: etcdserver.Response{Event: &v2store.Event{Action: v2store.Get, Node: &v2store.NodeExtern{Key: "/foo"}}}}
+		h := &keysHandler{
+			lg:      zap.NewExample(),
+			timeout: time.Hour,
+			server:  server,
+			cluster: &fakeCluster{id: 1, members: tt.members},
+			learner: fakeLearnerReporter(true),
+		}
+		req := mustNewMethodRequest(t, tt.method, "foo?value=bar")
+		rw := httptest.NewRecorder()
+		h.ServeHTTP(rw, req)
+		if rw.Code != tt.wcode {
+			t.Errorf("#%d: code = %d, want %d", i, rw.Code, tt.wcode)
+		}
+		if g := rw.Header().Get("Location"); g != tt.wlocation {
+			t.Errorf("#%d: location = %q, want %q", i, g, tt.wlocation)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v2http
+
+import (
+	"net"
+	"sync"
+)
+
+// watcherLimiter is implemented by servers that limit the number of
+// watchers every client holds.
+type watcherLimiter interface {
+	V2MaxWatchersPerClient() int
+}
+
+// clientWatchers counts the watchers held by every client, identified by
+// the host of its remote address, so that one client opening watches in a
+// loop cannot exhaust the watcher hub.
+type clientWatchers struct {
+	max int
+
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+// newClientWatchers returns the counter of the watchers limited to max per
+// client, or nil if max is not positive.
+func newClientWatchers(max int) *clientWatchers {
+	if max <= 0 {
+		return nil
+	}
+	return &clientWatchers{max: max, counts: make(map[string]int)}
+}
+
+// acquire counts a watcher of the client at the remote address addr. It
+// returns the host identifying the client, and false if the client holds
+// max watchers already.
+func (cw *clientWatchers) acquire(addr string) (string, bool) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	if cw.counts[host] >= cw.max {
+		return host, false
+	}
+	cw.counts[host]++
+	return host, true
+}
+
+// release uncounts a watcher acquired by the client host.
+func (cw *clientWatchers) release(host string) {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	if cw.counts[host]--; cw.counts[host] <= 0 {
+		delete(cw.counts, host)
+	}
+}
@@ -76,18 +76,22 @@ func allowMethod(w http.ResponseWriter, m string, ms ...string) bool {
 	return false
 }
 
+// requestLogger logs the requests once handled, so that the identity of
+// the client is only logged once authenticated.
 func requestLogger(lg *zap.Logger, handler http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r = etcdhttp.WithClientIdentity(r)
+		handler.ServeHTTP(w, r)
 		if lg != nil {
 			lg.Debug(
-				"handling HTTP request",
+				"handled HTTP request",
 				zap.String("method", r.Method),
 				zap.String("request-uri", r.RequestURI),
 				zap.String("remote-addr", r.RemoteAddr),
+				zap.String("client-identity", etcdhttp.ClientIdentity(r)),
 			)
 		} else {
-			plog.Debugf("[%s] %s remote:%s", r.Method, r.RequestURI, r.RemoteAddr)
+			plog.Debugf("[%s] %s remote:%s identity:%s", r.Method, r.RequestURI, r.RemoteAddr, etcdhttp.ClientIdentity(r))
 		}
-		handler.ServeHTTP(w, r)
 	})
 }
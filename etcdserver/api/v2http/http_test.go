@@ -21,6 +21,7 @@ import (
 	"net/http/httptest"
 	"sort"
 	"testing"
+	"time"
 
 	"go.etcd.io/etcd/etcdserver"
 	"go.etcd.io/etcd/etcdserver/api/membership"
@@ -71,6 +72,9 @@ func (fs *errServer) AddMember(ctx context.Context, m membership.Member) ([]*mem
 func (fs *errServer) RemoveMember(ctx context.Context, id uint64) ([]*membership.Member, error) {
 	return nil, fs.err
 }
+func (fs *errServer) RemoveDeadMember(ctx context.Context, id uint64, deadFor time.Duration) ([]*membership.Member, error) {
+	return nil, fs.err
+}
 func (fs *errServer) UpdateMember(ctx context.Context, m membership.Member) ([]*membership.Member, error) {
 	return nil, fs.err
 }
@@ -27,19 +27,44 @@ type Member struct {
 	Name       string   `json:"name"`
 	PeerURLs   []string `json:"peerURLs"`
 	ClientURLs []string `json:"clientURLs"`
+	IsLearner  bool     `json:"isLearner,omitempty"`
+	// Metadata holds the operator-defined key/value pairs of the member,
+	// e.g. its zone or rack.
+	Metadata map[string]string `json:"metadata,omitempty"`
 }
 
 type MemberCreateRequest struct {
 	PeerURLs types.URLs
+	// IsLearner is true to add the member as a learner, a read-only
+	// replica which does not vote.
+	IsLearner bool
 }
 
 type MemberUpdateRequest struct {
 	MemberCreateRequest
+	// Name, if not empty, renames the member. The member records it, and
+	// publishes it once restarted with it as --name.
+	Name string
+}
+
+func (m *MemberUpdateRequest) UnmarshalJSON(data []byte) error {
+	if err := m.MemberCreateRequest.UnmarshalJSON(data); err != nil {
+		return err
+	}
+	s := struct {
+		Name string `json:"name"`
+	}{}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	m.Name = s.Name
+	return nil
 }
 
 func (m *MemberCreateRequest) UnmarshalJSON(data []byte) error {
 	s := struct {
-		PeerURLs []string `json:"peerURLs"`
+		PeerURLs  []string `json:"peerURLs"`
+		IsLearner bool     `json:"isLearner"`
 	}{}
 
 	err := json.Unmarshal(data, &s)
@@ -53,6 +78,7 @@ func (m *MemberCreateRequest) UnmarshalJSON(data []byte) error {
 	}
 
 	m.PeerURLs = urls
+	m.IsLearner = s.IsLearner
 	return nil
 }
 
@@ -112,6 +112,42 @@ func TestMemberCreateRequestUnmarshal(t *testing.T) {
 	}
 }
 
+func TestMemberCreateRequestUnmarshalLearner(t *testing.T) {
+	body := []byte(`{"peerURLs": ["http://127.0.0.1:8081"], "isLearner": true}`)
+	want := MemberCreateRequest{
+		PeerURLs:  types.URLs([]url.URL{{Scheme: "http", Host: "127.0.0.1:8081"}}),
+		IsLearner: true,
+	}
+
+	var req MemberCreateRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		t.Fatalf("Unmarshal returned unexpected err=%v", err)
+	}
+
+	if !reflect.DeepEqual(want, req) {
+		t.Fatalf("Failed to unmarshal MemberCreateRequest: want=%#v, got=%#v", want, req)
+	}
+}
+
+func TestMemberUpdateRequestUnmarshal(t *testing.T) {
+	body := []byte(`{"peerURLs": ["http://127.0.0.1:8081"], "name": "node2"}`)
+	want := MemberUpdateRequest{
+		MemberCreateRequest: MemberCreateRequest{
+			PeerURLs: types.URLs([]url.URL{{Scheme: "http", Host: "127.0.0.1:8081"}}),
+		},
+		Name: "node2",
+	}
+
+	var req MemberUpdateRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		t.Fatalf("Unmarshal returned unexpected err=%v", err)
+	}
+
+	if !reflect.DeepEqual(want, req) {
+		t.Fatalf("Failed to unmarshal MemberUpdateRequest: want=%#v, got=%#v", want, req)
+	}
+}
+
 func TestMemberCreateRequestUnmarshalFail(t *testing.T) {
 	tests := [][]byte{
 		// invalid JSON
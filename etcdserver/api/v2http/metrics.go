@@ -16,10 +16,13 @@ package v2http
 
 import (
 	"strconv"
+	"strings"
 	"time"
 
 	"net/http"
 
+	"go.etcd.io/etcd/etcdserver"
+	"go.etcd.io/etcd/etcdserver/api"
 	"go.etcd.io/etcd/etcdserver/api/v2error"
 	"go.etcd.io/etcd/etcdserver/api/v2http/httptypes"
 	"go.etcd.io/etcd/etcdserver/etcdserverpb"
@@ -55,16 +58,39 @@ var (
 			// highest bucket start of 0.0005 sec * 2^12 == 2.048 sec
 			Buckets: prometheus.ExponentialBuckets(0.0005, 2, 13),
 		}, []string{"method"})
+
+	prefixIncomingEvents = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "etcd",
+			Subsystem: "http",
+			Name:      "prefix_received_total",
+			Help:      "Counter of requests received into the system, by top-level key prefix and method (GET/PUT etc.).",
+		}, []string{"prefix", "method"})
+
+	watchersRejected = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "etcd",
+			Subsystem: "http",
+			Name:      "watchers_rejected_total",
+			Help:      "Counter of watches refused by the limit (client/key) on the watchers held by a client or watching a key.",
+		}, []string{"limit"})
+
+	prefixLabeler = api.NewPrefixLabeler(api.DefaultMaxPrefixLabels)
 )
 
 func init() {
 	prometheus.MustRegister(incomingEvents)
 	prometheus.MustRegister(failedEvents)
 	prometheus.MustRegister(successfulEventsHandlingSec)
+	prometheus.MustRegister(prefixIncomingEvents)
+	prometheus.MustRegister(watchersRejected)
 }
 
 func reportRequestReceived(request etcdserverpb.Request) {
-	incomingEvents.WithLabelValues(methodFromRequest(request)).Inc()
+	method := methodFromRequest(request)
+	incomingEvents.WithLabelValues(method).Inc()
+	key := strings.TrimPrefix(request.Path, etcdserver.StoreKeysPrefix)
+	prefixIncomingEvents.WithLabelValues(prefixLabeler.Label(key), method).Inc()
 }
 
 func reportRequestCompleted(request etcdserverpb.Request, startTime time.Time) {
@@ -77,6 +103,10 @@ func reportRequestFailed(request etcdserverpb.Request, err error) {
 	failedEvents.WithLabelValues(method, strconv.Itoa(codeFromError(err))).Inc()
 }
 
+func reportWatcherRejected(limit string) {
+	watchersRejected.WithLabelValues(limit).Inc()
+}
+
 func methodFromRequest(request etcdserverpb.Request) string {
 	if request.Method == "GET" && request.Quorum {
 		return "QGET"
@@ -47,7 +47,13 @@ func NewLeaderStats(id string) *LeaderStats {
 
 func (ls *LeaderStats) JSON() []byte {
 	ls.Lock()
-	stats := ls.leaderStats
+	stats := leaderStats{Leader: ls.Leader, Followers: make(map[string]*FollowerStats, len(ls.Followers))}
+	for name, fs := range ls.Followers {
+		// copy the followers, as they are updated concurrently
+		fs.Lock()
+		stats.Followers[name] = &FollowerStats{Latency: fs.Latency, Counts: fs.Counts, Progress: fs.Progress}
+		fs.Unlock()
+	}
 	ls.Unlock()
 	b, err := json.Marshal(stats)
 	// TODO(jonboulle): appropriate error handling?
@@ -69,10 +75,25 @@ func (ls *LeaderStats) Follower(name string) *FollowerStats {
 	return fs
 }
 
+// SetProgress updates the replication progress of the followers found in
+// progress, by follower ID
+func (ls *LeaderStats) SetProgress(progress map[string]ProgressStats) {
+	ls.Lock()
+	defer ls.Unlock()
+	for name, fs := range ls.Followers {
+		if p, ok := progress[name]; ok {
+			fs.SetProgress(p)
+		}
+	}
+}
+
 // FollowerStats encapsulates various statistics about a follower in an etcd cluster
 type FollowerStats struct {
 	Latency LatencyStats `json:"latency"`
 	Counts  CountsStats  `json:"counts"`
+	// Progress is the replication progress of the follower tracked by
+	// the leader, or nil until it is known.
+	Progress *ProgressStats `json:"progress,omitempty"`
 
 	sync.Mutex
 }
@@ -91,6 +112,23 @@ type LatencyStats struct {
 type CountsStats struct {
 	Fail    uint64 `json:"fail"`
 	Success uint64 `json:"success"`
+	// Dropped counts the messages of any type dropped before being sent
+	// since the sending buffer is full.
+	Dropped uint64 `json:"dropped"`
+}
+
+// ProgressStats encapsulates the replication progress of a follower.
+type ProgressStats struct {
+	// State is either "probe", "replicate" or "snapshot".
+	State string `json:"state"`
+	Match uint64 `json:"match"`
+	Next  uint64 `json:"next"`
+	// Lag is the number of committed entries the follower does not have.
+	Lag          uint64 `json:"lag"`
+	RecentActive bool   `json:"recentActive"`
+	Paused       bool   `json:"paused"`
+	// PendingSnapshot is the index of the snapshot being sent, if any.
+	PendingSnapshot uint64 `json:"pendingSnapshot,omitempty"`
 }
 
 // Succ updates the FollowerStats with a successful send
@@ -126,3 +164,18 @@ func (fs *FollowerStats) Fail() {
 	defer fs.Unlock()
 	fs.Counts.Fail++
 }
+
+// Drop updates the FollowerStats with a message dropped before being sent
+func (fs *FollowerStats) Drop() {
+	fs.Lock()
+	defer fs.Unlock()
+	fs.Counts.Dropped++
+}
+
+// SetProgress updates the FollowerStats with the replication progress of
+// the follower
+func (fs *FollowerStats) SetProgress(p ProgressStats) {
+	fs.Lock()
+	defer fs.Unlock()
+	fs.Progress = &p
+}
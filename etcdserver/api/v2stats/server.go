@@ -67,6 +67,18 @@ type serverStats struct {
 	SendingPkgRate       float64 `json:"sendPkgRate,omitempty"`
 	SendingBandwidthRate float64 `json:"sendBandwidthRate,omitempty"`
 
+	// ApplyLag is the number of committed entries not yet applied.
+	ApplyLag uint64 `json:"applyLag"`
+
+	// FDUsage and FDLimit are the number of open file descriptors of the
+	// process and its limit. They are omitted where they are unknown.
+	FDUsage uint64 `json:"fdUsage,omitempty"`
+	FDLimit uint64 `json:"fdLimit,omitempty"`
+
+	// SnapshotRestore is the progress of the restore of the last snapshot
+	// received from the leader, if any.
+	SnapshotRestore *SnapshotProgress `json:"snapshotRestore,omitempty"`
+
 	sendRateQueue *statsQueue
 	recvRateQueue *statsQueue
 }
@@ -77,6 +89,13 @@ func (ss *ServerStats) JSON() []byte {
 	stats.SendingPkgRate, stats.SendingBandwidthRate = stats.sendRateQueue.Rate()
 	stats.RecvingPkgRate, stats.RecvingBandwidthRate = stats.recvRateQueue.Rate()
 	stats.LeaderInfo.Uptime = time.Since(stats.LeaderInfo.StartTime).String()
+	if sp := stats.SnapshotRestore; sp != nil {
+		cp := *sp
+		if eta := cp.eta(); eta > 0 {
+			cp.ETA = eta.Round(time.Second).String()
+		}
+		stats.SnapshotRestore = &cp
+	}
 	ss.Unlock()
 	b, err := json.Marshal(stats)
 	// TODO(jonboulle): appropriate error handling?
@@ -86,6 +105,20 @@ func (ss *ServerStats) JSON() []byte {
 	return b
 }
 
+// SetApplyLag updates the number of committed entries not yet applied.
+func (ss *ServerStats) SetApplyLag(lag uint64) {
+	ss.Lock()
+	defer ss.Unlock()
+	ss.ApplyLag = lag
+}
+
+// SetFDUsage updates the number of open file descriptors and their limit.
+func (ss *ServerStats) SetFDUsage(used, limit uint64) {
+	ss.Lock()
+	defer ss.Unlock()
+	ss.FDUsage, ss.FDLimit = used, limit
+}
+
 // RecvAppendReq updates the ServerStats in response to an AppendRequest
 // from the given leader being received
 func (ss *ServerStats) RecvAppendReq(leader string, reqSize int) {
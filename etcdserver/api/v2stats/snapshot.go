@@ -0,0 +1,145 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v2stats
+
+import "time"
+
+// The stages of the restore of a snapshot received from the leader.
+const (
+	SnapshotReceiving  = "receiving"
+	SnapshotInstalling = "installing"
+	SnapshotApplying   = "applying"
+	SnapshotDone       = "done"
+	SnapshotFailed     = "failed"
+)
+
+// SnapshotProgress is the progress of the restore of the last snapshot
+// received from the leader: its download, its install, and then the apply
+// of the entries committed since the snapshot.
+type SnapshotProgress struct {
+	Stage     string    `json:"stage"`
+	From      string    `json:"from"`
+	Index     uint64    `json:"index"`
+	StartTime time.Time `json:"startTime"`
+
+	// BytesTotal is zero where the sender does not tell the snapshot size.
+	BytesReceived int64 `json:"bytesReceived"`
+	BytesTotal    int64 `json:"bytesTotal,omitempty"`
+
+	EntriesApplied uint64 `json:"entriesApplied"`
+	EntriesPending uint64 `json:"entriesPending"`
+
+	// ETA is the estimated time left to the end of the current stage,
+	// from its rate so far.
+	ETA string `json:"eta,omitempty"`
+
+	stageStart time.Time
+}
+
+func (sp *SnapshotProgress) setStage(stage string) {
+	sp.Stage = stage
+	sp.stageStart = time.Now()
+}
+
+func (sp SnapshotProgress) eta() time.Duration {
+	elapsed := time.Since(sp.stageStart)
+	switch {
+	case sp.Stage == SnapshotReceiving && sp.BytesTotal > 0 && sp.BytesReceived > 0:
+		return time.Duration(float64(elapsed) * float64(sp.BytesTotal-sp.BytesReceived) / float64(sp.BytesReceived))
+	case sp.Stage == SnapshotApplying && sp.EntriesApplied > 0:
+		return time.Duration(float64(elapsed) * float64(sp.EntriesPending) / float64(sp.EntriesApplied))
+	}
+	return 0
+}
+
+// SnapshotReceiveStart records the start of the download of the snapshot at
+// the given index from the given member; total is its size in bytes, or
+// zero if unknown.
+func (ss *ServerStats) SnapshotReceiveStart(from string, index uint64, total int64) {
+	ss.Lock()
+	defer ss.Unlock()
+	ss.SnapshotRestore = &SnapshotProgress{From: from, Index: index, StartTime: time.Now(), BytesTotal: total}
+	ss.SnapshotRestore.setStage(SnapshotReceiving)
+}
+
+// SnapshotReceived adds n bytes to the snapshot being downloaded.
+func (ss *ServerStats) SnapshotReceived(n int64) {
+	ss.Lock()
+	defer ss.Unlock()
+	if sp := ss.SnapshotRestore; sp != nil && sp.Stage == SnapshotReceiving {
+		sp.BytesReceived += n
+	}
+}
+
+// SnapshotReceiveEnd records the end of the download of the snapshot; the
+// snapshot is then installed, unless the download failed.
+func (ss *ServerStats) SnapshotReceiveEnd(err error) {
+	ss.Lock()
+	defer ss.Unlock()
+	sp := ss.SnapshotRestore
+	if sp == nil || sp.Stage != SnapshotReceiving {
+		return
+	}
+	if err != nil {
+		sp.setStage(SnapshotFailed)
+		return
+	}
+	sp.setStage(SnapshotInstalling)
+}
+
+// SnapshotInstalled records the install of the snapshot at the given
+// index; the entries committed since the snapshot are then applied.
+func (ss *ServerStats) SnapshotInstalled(index uint64) {
+	ss.Lock()
+	defer ss.Unlock()
+	sp := ss.SnapshotRestore
+	if sp == nil || sp.Index != index {
+		// not received by this transport, e.g. in tests
+		sp = &SnapshotProgress{Index: index, StartTime: time.Now()}
+		ss.SnapshotRestore = sp
+	}
+	sp.setStage(SnapshotApplying)
+}
+
+// SnapshotApplyProgress updates the entries applied since the installed
+// snapshot, given the applied and committed indexes. It returns true once,
+// when all the entries committed are applied.
+func (ss *ServerStats) SnapshotApplyProgress(applied, committed uint64) bool {
+	ss.Lock()
+	defer ss.Unlock()
+	sp := ss.SnapshotRestore
+	if sp == nil || sp.Stage != SnapshotApplying || applied < sp.Index {
+		return false
+	}
+	sp.EntriesApplied = applied - sp.Index
+	sp.EntriesPending = 0
+	if committed > applied {
+		sp.EntriesPending = committed - applied
+		return false
+	}
+	sp.setStage(SnapshotDone)
+	return true
+}
+
+// SnapshotProgress returns the progress of the restore of the last snapshot
+// received, or false if none was received.
+func (ss *ServerStats) SnapshotProgress() (SnapshotProgress, bool) {
+	ss.Lock()
+	defer ss.Unlock()
+	if ss.SnapshotRestore == nil {
+		return SnapshotProgress{}, false
+	}
+	return *ss.SnapshotRestore, true
+}
@@ -0,0 +1,94 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v2store
+
+import "container/heap"
+
+// A claimQueue is a min-heap of the keys directly under a directory
+// ordered by creation index, the order in which ClaimNext claims them.
+// Hidden keys and directories are left out. A directory builds it on its
+// first claim and keeps it up to date as keys are added and removed, so
+// that the next claims do not scan the directory.
+type claimQueue struct {
+	array  []*node
+	keyMap map[*node]int
+}
+
+func newClaimQueue(dir *node) *claimQueue {
+	q := &claimQueue{keyMap: make(map[*node]int)}
+	for _, child := range dir.Children {
+		if claimable(child) {
+			q.Push(child)
+		}
+	}
+	heap.Init(q)
+	return q
+}
+
+// claimable returns true if ClaimNext may claim n.
+func claimable(n *node) bool {
+	return !n.IsDir() && !n.IsHidden()
+}
+
+func (q claimQueue) Len() int {
+	return len(q.array)
+}
+
+func (q claimQueue) Less(i, j int) bool {
+	return q.array[i].CreatedIndex < q.array[j].CreatedIndex
+}
+
+func (q claimQueue) Swap(i, j int) {
+	q.array[i], q.array[j] = q.array[j], q.array[i]
+
+	q.keyMap[q.array[i]] = i
+	q.keyMap[q.array[j]] = j
+}
+
+func (q *claimQueue) Push(x interface{}) {
+	n, _ := x.(*node)
+	q.keyMap[n] = len(q.array)
+	q.array = append(q.array, n)
+}
+
+func (q *claimQueue) Pop() interface{} {
+	old := q.array
+	n := len(old)
+	x := old[n-1]
+	old[n-1] = nil
+	q.array = old[0 : n-1]
+	delete(q.keyMap, x)
+	return x
+}
+
+func (q *claimQueue) top() *node {
+	if q.Len() != 0 {
+		return q.array[0]
+	}
+	return nil
+}
+
+// add adds n to the queue if it is claimable.
+func (q *claimQueue) add(n *node) {
+	if claimable(n) {
+		heap.Push(q, n)
+	}
+}
+
+func (q *claimQueue) remove(n *node) {
+	if index, ok := q.keyMap[n]; ok {
+		heap.Remove(q, index)
+	}
+}
@@ -14,6 +14,11 @@
 
 package v2store
 
+import (
+	"path"
+	"strings"
+)
+
 const (
 	Get              = "get"
 	Create           = "create"
@@ -22,6 +27,7 @@ const (
 	Delete           = "delete"
 	CompareAndSwap   = "compareAndSwap"
 	CompareAndDelete = "compareAndDelete"
+	Claim            = "claim"
 	Expire           = "expire"
 )
 
@@ -69,3 +75,24 @@ func (e *Event) Clone() *Event {
 func (e *Event) SetRefresh() {
 	e.Refresh = true
 }
+
+// matches returns true if a watcher of key receives e.
+func (e *Event) matches(key string, recursive bool) bool {
+	ok := e.Node.Key == key
+
+	if recursive {
+		// add tailing slash
+		nkey := path.Clean(key)
+		if nkey[len(nkey)-1] != '/' {
+			nkey = nkey + "/"
+		}
+
+		ok = ok || strings.HasPrefix(e.Node.Key, nkey)
+	}
+
+	if (e.Action == Delete || e.Action == Expire) && e.PrevNode != nil && e.PrevNode.Dir {
+		ok = ok || strings.HasPrefix(key, e.PrevNode.Key)
+	}
+
+	return ok
+}
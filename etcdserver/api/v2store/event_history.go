@@ -16,8 +16,6 @@ package v2store
 
 import (
 	"fmt"
-	"path"
-	"strings"
 	"sync"
 
 	"go.etcd.io/etcd/etcdserver/api/v2error"
@@ -78,26 +76,8 @@ func (eh *EventHistory) scan(key string, recursive bool, index uint64) (*Event,
 	for {
 		e := eh.Queue.Events[i]
 
-		if !e.Refresh {
-			ok := e.Node.Key == key
-
-			if recursive {
-				// add tailing slash
-				nkey := path.Clean(key)
-				if nkey[len(nkey)-1] != '/' {
-					nkey = nkey + "/"
-				}
-
-				ok = ok || strings.HasPrefix(e.Node.Key, nkey)
-			}
-
-			if (e.Action == Delete || e.Action == Expire) && e.PrevNode != nil && e.PrevNode.Dir {
-				ok = ok || strings.HasPrefix(key, e.PrevNode.Key)
-			}
-
-			if ok {
-				return e, nil
-			}
+		if !e.Refresh && e.matches(key, recursive) {
+			return e, nil
 		}
 
 		i = (i + 1) % eh.Queue.Capacity
@@ -0,0 +1,125 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v2store
+
+import (
+	"path"
+	"time"
+)
+
+// keyExpiration records the expiration of a node, until the events of the
+// node are pruned from the event history.
+type keyExpiration struct {
+	Key   string `json:"key"`
+	Index uint64 `json:"index"`
+	// ExpiredAt is the time of the SYNC request expiring the node.
+	ExpiredAt time.Time `json:"expiredAt"`
+}
+
+func (s *store) ExpiredHistoryRetention() time.Duration {
+	s.worldLock.RLock()
+	defer s.worldLock.RUnlock()
+	return s.historyRetention
+}
+
+func (s *store) SetExpiredHistoryRetention(retention time.Duration) {
+	s.worldLock.Lock()
+	defer s.worldLock.Unlock()
+	if retention == 0 {
+		// the history of the expired nodes is no longer pruned
+		s.Expirations = nil
+	}
+	s.historyRetention = retention
+}
+
+// addExpiration records the expiration of n by the SYNC request at cutoff,
+// if the store prunes the history of the expired nodes.
+func (s *store) addExpiration(n *node, index uint64, cutoff time.Time) {
+	if s.historyRetention != 0 {
+		s.Expirations = append(s.Expirations, &keyExpiration{Key: n.Path, Index: index, ExpiredAt: cutoff})
+	}
+}
+
+// sweepExpiredHistory prunes from the event history the events of the
+// nodes expired for historyRetention at cutoff, the time of a SYNC request.
+func (s *store) sweepExpiredHistory(cutoff time.Time) {
+	if s.historyRetention == 0 {
+		return
+	}
+	// the time of the SYNC requests never goes backwards, so the
+	// expirations are sorted by time
+	swept := 0
+	for swept < len(s.Expirations) && !s.Expirations[swept].ExpiredAt.Add(s.historyRetention).After(cutoff) {
+		swept++
+	}
+	if swept == 0 {
+		return
+	}
+	keys := make(map[string]uint64, swept)
+	for _, exp := range s.Expirations[:swept] {
+		keys[exp.Key] = exp.Index
+	}
+	if n := s.WatcherHub.EventHistory.prune(keys); n > 0 {
+		reportHistoryPruned(n)
+	}
+	s.Expirations = append([]*keyExpiration(nil), s.Expirations[swept:]...)
+}
+
+// prune removes from the history the events of the expired nodes, and of
+// their children, up to their expiration. keys maps the expired nodes to
+// the index of their expiration. The history is cleared up to the last
+// pruned event, so that the watchers from an index up to it get
+// EcodeEventIndexCleared rather than a history missing events. It returns
+// the number of removed events.
+func (eh *EventHistory) prune(keys map[string]uint64) int {
+	eh.rwl.Lock()
+	defer eh.rwl.Unlock()
+
+	expired := func(e *Event) bool {
+		for p := e.Node.Key; ; p = path.Dir(p) {
+			if index, ok := keys[p]; ok && e.Index() <= index {
+				return true
+			}
+			if p == "/" {
+				return false
+			}
+		}
+	}
+
+	q := &eh.Queue
+	var cleared uint64
+	for n, i := 0, q.Front; n < q.Size; n, i = n+1, (i+1)%q.Capacity {
+		if e := q.Events[i]; expired(e) {
+			cleared = e.Index()
+		}
+	}
+	if cleared == 0 {
+		return 0
+	}
+
+	removed := 0
+	for q.Size > 0 && q.Events[q.Front].Index() <= cleared {
+		q.Events[q.Front] = nil
+		q.Front = (q.Front + 1) % q.Capacity
+		q.Size--
+		removed++
+	}
+	if q.Size > 0 {
+		eh.StartIndex = q.Events[q.Front].Index()
+	} else {
+		eh.StartIndex = eh.LastIndex + 1
+	}
+	return removed
+}
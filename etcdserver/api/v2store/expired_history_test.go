@@ -0,0 +1,150 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v2store
+
+import (
+	"testing"
+	"time"
+
+	"go.etcd.io/etcd/etcdserver/api/v2error"
+	"go.etcd.io/etcd/pkg/testutil"
+)
+
+// TestSweepExpiredHistory ensures the SYNC requests prune the events of the
+// expired keys from the event history after the retention window.
+func TestSweepExpiredHistory(t *testing.T) {
+	s := newStore()
+	s.SetExpiredHistoryRetention(time.Minute)
+	fc := newFakeClock()
+	s.clock = fc
+	t0 := fc.Now()
+
+	ttl := TTLOptionSet{ExpireTime: t0.Add(time.Second)}
+	s.Create("/ttl", false, "v1", false, ttl)
+	s.Create("/perm", false, "p", false, TTLOptionSet{ExpireTime: Permanent})
+	s.Update("/ttl", "v2", ttl)
+	s.Create("/dir/a", false, "a", false, TTLOptionSet{ExpireTime: Permanent})
+	s.Update("/dir", "", ttl)
+
+	s.DeleteExpiredKeys(t0.Add(2 * time.Second))
+	testutil.AssertEqual(t, 2, len(s.Expirations))
+	s.DeleteExpiredKeys(t0.Add(61 * time.Second))
+	testutil.AssertEqual(t, true, s.HasTTLKeys())
+	e := nbselect(mustWatch(t, s, "/ttl", 3).EventChan())
+	if e == nil || e.Node.Value == nil || *e.Node.Value != "v2" {
+		t.Fatalf("event = %+v, want the update to v2", e)
+	}
+
+	s.DeleteExpiredKeys(t0.Add(62 * time.Second))
+	testutil.AssertEqual(t, 0, len(s.Expirations))
+	testutil.AssertEqual(t, false, s.HasTTLKeys())
+
+	// the history is cleared up to the last pruned event, the expiration
+	// of /dir, including the events of the other keys before it
+	testutil.AssertEqual(t, 0, s.WatcherHub.EventHistory.Queue.Size)
+	testutil.AssertEqual(t, uint64(8), s.WatcherHub.EventHistory.StartIndex)
+	for _, tt := range []struct {
+		key   string
+		index uint64
+	}{{"/ttl", 1}, {"/ttl", 3}, {"/dir/a", 4}, {"/perm", 2}, {"/dir", 7}} {
+		if _, err := s.Watch(tt.key, false, false, tt.index); err == nil || err.(*v2error.Error).ErrorCode != v2error.EcodeEventIndexCleared {
+			t.Fatalf("watch %s from %d: err = %v, want index cleared", tt.key, tt.index, err)
+		}
+	}
+	// the later events are kept
+	s.Update("/perm", "p2", TTLOptionSet{ExpireTime: Permanent})
+	e = nbselect(mustWatch(t, s, "/perm", 8).EventChan())
+	if e == nil || e.Node.Value == nil || *e.Node.Value != "p2" {
+		t.Fatalf("event = %+v, want the update of /perm", e)
+	}
+}
+
+// TestPruneClearsHistory ensures the events before the last pruned one are
+// removed with it, and the later ones kept whole.
+func TestPruneClearsHistory(t *testing.T) {
+	eh := newEventHistory(8)
+	eh.addEvent(newEvent(Create, "/foo", 1, 1))
+	eh.addEvent(newEvent(Create, "/bar", 2, 2))
+	eh.addEvent(newEvent(Expire, "/foo", 3, 1))
+	eh.addEvent(newEvent(Update, "/bar", 4, 2))
+	testutil.AssertEqual(t, 3, eh.prune(map[string]uint64{"/foo": 3}))
+	testutil.AssertEqual(t, uint64(4), eh.StartIndex)
+	for _, index := range []uint64{1, 2, 3} {
+		if e, err := eh.scan("/bar", false, index); err == nil || err.ErrorCode != v2error.EcodeEventIndexCleared {
+			t.Fatalf("scan from %d = %+v, %v, want index cleared", index, e, err)
+		}
+	}
+	if e, err := eh.scan("/bar", false, 4); err != nil || e == nil || e.Index() != 4 {
+		t.Fatalf("scan = %+v, %v, want the update at 4", e, err)
+	}
+	testutil.AssertEqual(t, 0, eh.prune(map[string]uint64{"/baz": 5}))
+}
+
+// TestSweepExpiredHistoryDisabled ensures the expirations are not recorded
+// without retention.
+func TestSweepExpiredHistoryDisabled(t *testing.T) {
+	s := newStore()
+	fc := newFakeClock()
+	s.clock = fc
+	s.Create("/ttl", false, "v1", false, TTLOptionSet{ExpireTime: fc.Now().Add(time.Second)})
+	s.DeleteExpiredKeys(fc.Now().Add(2 * time.Second))
+	testutil.AssertEqual(t, 0, len(s.Expirations))
+	testutil.AssertEqual(t, false, s.HasTTLKeys())
+}
+
+// TestSweepExpiredHistoryUnset ensures the expirations recorded with a
+// retention are kept until the retention is unset, and never prune the
+// history meanwhile, as when a member recovers them from a snapshot
+// before the retention is applied.
+func TestSweepExpiredHistoryUnset(t *testing.T) {
+	s := newStore()
+	fc := newFakeClock()
+	s.clock = fc
+	s.Create("/ttl", false, "v1", false, TTLOptionSet{ExpireTime: fc.Now().Add(time.Second)})
+	s.Expirations = []*keyExpiration{{Key: "/ttl", Index: 2, ExpiredAt: fc.Now()}}
+	s.DeleteExpiredKeys(fc.Now().Add(2 * time.Second))
+	testutil.AssertEqual(t, 1, len(s.Expirations))
+	testutil.AssertEqual(t, 2, s.WatcherHub.EventHistory.Queue.Size)
+
+	s.SetExpiredHistoryRetention(0)
+	testutil.AssertEqual(t, 0, len(s.Expirations))
+	testutil.AssertEqual(t, false, s.HasTTLKeys())
+}
+
+// TestPruneAllHistory ensures an emptied history reports the next index as
+// its start.
+func TestPruneAllHistory(t *testing.T) {
+	eh := newEventHistory(4)
+	eh.addEvent(newEvent(Create, "/foo", 1, 1))
+	eh.addEvent(newEvent(Expire, "/foo", 2, 1))
+	testutil.AssertEqual(t, 2, eh.prune(map[string]uint64{"/foo": 2}))
+	testutil.AssertEqual(t, 0, eh.Queue.Size)
+	testutil.AssertEqual(t, uint64(3), eh.StartIndex)
+	if e, err := eh.scan("/foo", false, 2); err == nil {
+		t.Fatalf("scan = %+v, want index cleared", e)
+	}
+	eh.addEvent(newEvent(Create, "/foo", 3, 3))
+	if e, err := eh.scan("/foo", false, 3); err != nil || e == nil || e.Index() != 3 {
+		t.Fatalf("scan = %+v, %v, want the creation at 3", e, err)
+	}
+}
+
+func mustWatch(t *testing.T, s *store, key string, index uint64) Watcher {
+	w, err := s.Watch(key, false, false, index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w
+}
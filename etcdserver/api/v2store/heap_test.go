@@ -16,6 +16,7 @@ package v2store
 
 import (
 	"fmt"
+	"reflect"
 	"testing"
 	"time"
 )
@@ -92,3 +93,66 @@ func TestHeapUpdate(t *testing.T) {
 	}
 
 }
+
+func TestHeapExpired(t *testing.T) {
+	h := newTtlKeyHeap()
+	now := time.Now()
+
+	// the path is equal to ttl from now, in random order
+	for _, i := range []int{7, 2, 9, 4, 1, 8, 3, 10, 6, 5} {
+		path := fmt.Sprintf("%v", i)
+		n := newKV(nil, path, path, 0, nil, now.Add(time.Second*time.Duration(i)))
+		h.push(n)
+	}
+	size := h.Len()
+
+	tests := []struct {
+		cutoff time.Duration
+		limit  int
+		wpaths []string
+	}{
+		{0, 0, nil},
+		{5 * time.Second, 0, []string{"1", "2", "3", "4", "5"}},
+		{5 * time.Second, 3, []string{"1", "2", "3"}},
+		{5 * time.Second, 8, []string{"1", "2", "3", "4", "5"}},
+		{time.Minute, 0, []string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "10"}},
+	}
+	for i, tt := range tests {
+		var paths []string
+		for _, n := range h.expired(now.Add(tt.cutoff), tt.limit) {
+			paths = append(paths, n.Path)
+		}
+		if !reflect.DeepEqual(paths, tt.wpaths) {
+			t.Errorf("#%d: expired = %v, want %v", i, paths, tt.wpaths)
+		}
+		if h.Len() != size {
+			t.Fatalf("#%d: heap size = %d, want %d", i, h.Len(), size)
+		}
+	}
+}
+
+func TestClaimQueue(t *testing.T) {
+	s := newStore()
+	dir := newDir(s, "/queue", 1, s.Root, Permanent)
+	for i, name := range []string{"3", "_hidden", "1", "2"} {
+		dir.Add(newKV(s, "/queue/"+name, name, uint64(10-i), dir, Permanent))
+	}
+	dir.Add(newDir(s, "/queue/dir", 1, dir, Permanent))
+
+	q := newClaimQueue(dir)
+	dir.claims = q
+	dir.Add(newKV(s, "/queue/4", "4", 20, dir, Permanent))
+	dir.Children["1"].Remove(false, false, nil)
+
+	// by creation index, without the hidden key and the directory
+	for _, want := range []string{"2", "3", "4"} {
+		n := q.top()
+		if n == nil || n.Value != want {
+			t.Fatalf("top = %+v, want %s", n, want)
+		}
+		n.Remove(false, false, nil)
+	}
+	if n := q.top(); n != nil {
+		t.Errorf("top = %+v, want none", n)
+	}
+}
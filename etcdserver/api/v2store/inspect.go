@@ -0,0 +1,115 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v2store
+
+import (
+	"path"
+	"time"
+)
+
+// KeyInternals is the internal state of the store about a key, to debug
+// the watches that did not fire.
+type KeyInternals struct {
+	Key string `json:"key"`
+	// Exists is false if no node is at the key; the other node fields are
+	// then unset.
+	Exists        bool   `json:"exists"`
+	Dir           bool   `json:"dir,omitempty"`
+	CreatedIndex  uint64 `json:"createdIndex,omitempty"`
+	ModifiedIndex uint64 `json:"modifiedIndex,omitempty"`
+	// Expiration is the TTL deadline of the node, if any. The node is
+	// deleted by the first SYNC request after it.
+	Expiration *time.Time `json:"expiration,omitempty"`
+	// Watchers are the watchers that receive the events of the key: the
+	// ones registered at the key, and the recursive ones registered at its
+	// parents.
+	Watchers []WatcherInternals `json:"watchers"`
+
+	// CurrentIndex is the index of the store.
+	CurrentIndex uint64 `json:"currentIndex"`
+	// HistoryStartIndex and HistoryLastIndex are the bounds of the event
+	// history, from which the watches with an index are answered.
+	HistoryStartIndex uint64 `json:"historyStartIndex"`
+	HistoryLastIndex  uint64 `json:"historyLastIndex"`
+}
+
+// WatcherInternals is the state of a watcher registered in the store.
+type WatcherInternals struct {
+	// Key is the key the watcher is registered at.
+	Key       string `json:"key"`
+	Recursive bool   `json:"recursive,omitempty"`
+	Stream    bool   `json:"stream,omitempty"`
+	// SinceIndex is the index of the first event the watcher receives.
+	SinceIndex uint64 `json:"sinceIndex"`
+	// StartIndex is the index of the store when the watcher was created.
+	StartIndex uint64 `json:"startIndex"`
+	// Queued is the number of events waiting to be received.
+	Queued int `json:"queued"`
+}
+
+// Inspect returns the internal state of the store about the key at
+// nodePath, which may not exist. It changes nothing.
+func (s *store) Inspect(nodePath string) (*KeyInternals, error) {
+	s.worldLock.RLock()
+	defer s.worldLock.RUnlock()
+
+	nodePath = path.Clean(path.Join("/", nodePath))
+	ki := &KeyInternals{Key: nodePath, CurrentIndex: s.CurrentIndex}
+	if n, err := s.internalGet(nodePath); err == nil {
+		ki.Exists = true
+		ki.Dir = n.IsDir()
+		ki.CreatedIndex, ki.ModifiedIndex = n.CreatedIndex, n.ModifiedIndex
+		ki.Expiration, _ = n.expirationAndTTL(s.clock)
+	}
+
+	eh := s.WatcherHub.EventHistory
+	eh.rwl.RLock()
+	ki.HistoryStartIndex, ki.HistoryLastIndex = eh.StartIndex, eh.LastIndex
+	eh.rwl.RUnlock()
+
+	ki.Watchers = s.WatcherHub.inspect(nodePath)
+	return ki, nil
+}
+
+// inspect returns the watchers receiving the events of key.
+func (wh *watcherHub) inspect(key string) []WatcherInternals {
+	wh.mutex.Lock()
+	defer wh.mutex.Unlock()
+	wh.deliveryMu.Lock()
+	defer wh.deliveryMu.Unlock()
+
+	ws := []WatcherInternals{}
+	for p := key; ; p = path.Dir(p) {
+		if l, ok := wh.watchers[p]; ok {
+			for elem := l.Front(); elem != nil; elem = elem.Next() {
+				w := elem.Value.(*watcher)
+				if p != key && !w.recursive {
+					continue
+				}
+				ws = append(ws, WatcherInternals{
+					Key:        p,
+					Recursive:  w.recursive,
+					Stream:     w.stream,
+					SinceIndex: w.sinceIndex,
+					StartIndex: w.startIndex,
+					Queued:     len(w.eventChan) + len(w.queue),
+				})
+			}
+		}
+		if p == "/" {
+			return ws
+		}
+	}
+}
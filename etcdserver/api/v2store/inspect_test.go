@@ -0,0 +1,55 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v2store
+
+import (
+	"testing"
+	"time"
+
+	"go.etcd.io/etcd/pkg/testutil"
+)
+
+// TestInspect ensures the watchers receiving the events of a key are
+// reported with it, and only them.
+func TestInspect(t *testing.T) {
+	s := newStore()
+	fc := newFakeClock()
+	s.clock = fc
+	s.Create("/dir/foo", false, "bar", false, TTLOptionSet{ExpireTime: fc.Now().Add(time.Second)})
+	s.Watch("/dir", false, false, 0)
+	s.Watch("/", true, false, 0)
+	w := mustWatch(t, s, "/dir/foo", 0)
+	s.Watch("/dir/foo/bar", true, false, 0)
+
+	ki, err := s.Inspect("dir/foo/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testutil.AssertEqual(t, "/dir/foo", ki.Key)
+	testutil.AssertEqual(t, true, ki.Exists)
+	testutil.AssertEqual(t, fc.Now().Add(time.Second).UTC(), *ki.Expiration)
+	testutil.AssertEqual(t, []WatcherInternals{
+		{Key: "/dir/foo", SinceIndex: 2, StartIndex: 1},
+		{Key: "/", Recursive: true, SinceIndex: 2, StartIndex: 1},
+	}, ki.Watchers)
+
+	// the watchers are removed once they fired
+	s.Delete("/dir/foo", false, false)
+	ki, _ = s.Inspect("/dir/foo")
+	testutil.AssertEqual(t, false, ki.Exists)
+	testutil.AssertEqual(t, 0, len(ki.Watchers))
+	testutil.AssertEqual(t, uint64(2), ki.CurrentIndex)
+	testutil.AssertEqual(t, 1, len(w.EventChan()))
+}
@@ -0,0 +1,147 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v2store
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+const (
+	// valueSizeBuckets is the number of bounded buckets of the value size
+	// histogram; the first one holds the values up to 64 bytes, and every
+	// following one is four times larger, up to 16MB.
+	valueSizeBuckets  = 10
+	firstValueSizeMax = 64
+
+	// largestValuesLen is the number of largest values reported.
+	largestValuesLen = 5
+)
+
+// KeyspaceStats describes the shape of the key space.
+type KeyspaceStats struct {
+	Keys       uint64 `json:"keys"`
+	Dirs       uint64 `json:"dirs"`
+	ValueBytes uint64 `json:"valueBytes"`
+	// ValueSizes is the distribution of the value sizes. The bounds of
+	// the buckets grow exponentially; the last bucket is unbounded.
+	ValueSizes []ValueSizeBucket `json:"valueSizes"`
+	// LargestValues are the keys holding the largest values, largest
+	// first.
+	LargestValues []KeySize `json:"largestValues"`
+	// MaxDepth is the number of path elements of the deepest node.
+	MaxDepth int `json:"maxDepth"`
+	// MaxChildren is the directory with the most children.
+	MaxChildren KeyChildren `json:"maxChildren"`
+}
+
+// ValueSizeBucket counts the values larger than the bound of the previous
+// bucket, and at most MaxBytes bytes long. MaxBytes is omitted for the
+// unbounded last bucket.
+type ValueSizeBucket struct {
+	MaxBytes uint64 `json:"maxBytes,omitempty"`
+	Count    uint64 `json:"count"`
+}
+
+type KeySize struct {
+	Key  string `json:"key"`
+	Size uint64 `json:"size"`
+}
+
+type KeyChildren struct {
+	Key      string `json:"key"`
+	Children uint64 `json:"children"`
+}
+
+func newKeyspaceStats() *KeyspaceStats {
+	ks := &KeyspaceStats{
+		ValueSizes:    make([]ValueSizeBucket, valueSizeBuckets+1),
+		LargestValues: []KeySize{},
+	}
+	max := uint64(firstValueSizeMax)
+	for i := 0; i < valueSizeBuckets; i++ {
+		ks.ValueSizes[i].MaxBytes = max
+		max *= 4
+	}
+	return ks
+}
+
+func (ks *KeyspaceStats) addValue(key string, size uint64) {
+	ks.Keys++
+	ks.ValueBytes += size
+	i := sort.Search(valueSizeBuckets, func(i int) bool { return size <= ks.ValueSizes[i].MaxBytes })
+	ks.ValueSizes[i].Count++
+
+	if len(ks.LargestValues) == largestValuesLen && size <= ks.LargestValues[largestValuesLen-1].Size {
+		return
+	}
+	i = sort.Search(len(ks.LargestValues), func(i int) bool { return ks.LargestValues[i].Size < size })
+	ks.LargestValues = append(ks.LargestValues, KeySize{})
+	copy(ks.LargestValues[i+1:], ks.LargestValues[i:])
+	ks.LargestValues[i] = KeySize{Key: key, Size: size}
+	if len(ks.LargestValues) > largestValuesLen {
+		ks.LargestValues = ks.LargestValues[:largestValuesLen]
+	}
+}
+
+// KeyspaceStats returns the shape of the key space under the directory at
+// nodePath, with the keys relative to nodePath. It walks the last published snapshot
+// so that it does not block the writes.
+func (s *store) KeyspaceStats(nodePath string) *KeyspaceStats {
+	return s.readSnapshot().keyspaceStats(path.Clean(path.Join("/", nodePath)))
+}
+
+// keyspaceStats walks the snapshot to compute the shape of the key space
+// under p. The snapshot is immutable, so the result is computed once and
+// shared by the later calls.
+func (rs *readSnapshot) keyspaceStats(p string) *KeyspaceStats {
+	rs.keyspaceMu.Lock()
+	defer rs.keyspaceMu.Unlock()
+	if ks, ok := rs.keyspace[p]; ok {
+		return ks
+	}
+
+	ks := newKeyspaceStats()
+	if root := rs.lookup(p); root != nil && root.dir {
+		prefix := strings.TrimSuffix(p, "/")
+		children := make(map[string]uint64)
+		ascendDescendants(rs.tree, p, func(rn *readNode) bool {
+			rel := rn.path[len(prefix):]
+			children[path.Dir(rel)]++
+			if depth := strings.Count(rel, "/"); depth > ks.MaxDepth {
+				ks.MaxDepth = depth
+			}
+			if rn.dir {
+				ks.Dirs++
+			} else {
+				ks.addValue(rel, uint64(len(rn.value)))
+			}
+			return true
+		})
+		for dir, n := range children {
+			// break ties on the path, so the result does not depend on
+			// the iteration order of the map
+			if mc := ks.MaxChildren; n > mc.Children || (n == mc.Children && dir < mc.Key) {
+				ks.MaxChildren = KeyChildren{Key: dir, Children: n}
+			}
+		}
+	}
+	if rs.keyspace == nil {
+		rs.keyspace = make(map[string]*KeyspaceStats)
+	}
+	rs.keyspace[p] = ks
+	return ks
+}
@@ -62,6 +62,14 @@ var (
 			Help:      "Total number of expired keys.",
 		})
 
+	historyPrunedCounter = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: "etcd_debugging",
+			Subsystem: "store",
+			Name:      "history_pruned_total",
+			Help:      "Total number of events removed from the event history with the events of expired keys.",
+		})
+
 	watchRequests = prometheus.NewCounter(
 		prometheus.CounterOpts{
 			Namespace: "etcd_debugging",
@@ -77,6 +85,22 @@ var (
 			Name:      "watchers",
 			Help:      "Count of currently active watchers.",
 		})
+
+	watchQueueDepth = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: "etcd_debugging",
+			Subsystem: "store",
+			Name:      "watch_queue_depth",
+			Help:      "Number of events queued for slow watchers, not yet received by them.",
+		})
+
+	watchersDropped = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: "etcd_debugging",
+			Subsystem: "store",
+			Name:      "watchers_dropped_total",
+			Help:      "Total number of watchers removed because they did not receive their events in time.",
+		})
 )
 
 const (
@@ -91,8 +115,11 @@ func init() {
 	}
 	prometheus.MustRegister(writeCounter)
 	prometheus.MustRegister(expireCounter)
+	prometheus.MustRegister(historyPrunedCounter)
 	prometheus.MustRegister(watchRequests)
 	prometheus.MustRegister(watcherCount)
+	prometheus.MustRegister(watchQueueDepth)
+	prometheus.MustRegister(watchersDropped)
 }
 
 func reportReadSuccess(readAction string) {
@@ -117,6 +144,10 @@ func reportExpiredKey() {
 	expireCounter.Inc()
 }
 
+func reportHistoryPruned(n int) {
+	historyPrunedCounter.Add(float64(n))
+}
+
 func reportWatchRequest() {
 	watchRequests.Inc()
 }
@@ -128,3 +159,11 @@ func reportWatcherAdded() {
 func reportWatcherRemoved() {
 	watcherCount.Dec()
 }
+
+func reportWatchQueueDepth(delta int) {
+	watchQueueDepth.Add(float64(delta))
+}
+
+func reportWatcherDropped() {
+	watchersDropped.Inc()
+}
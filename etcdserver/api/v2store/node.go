@@ -51,6 +51,9 @@ type node struct {
 
 	// A reference to the store this node is attached to.
 	store *store
+
+	// claims orders the keys of a directory claimed by ClaimNext.
+	claims *claimQueue
 }
 
 // newKV creates a Key-Value pair
@@ -122,6 +125,7 @@ func (n *node) Write(value string, index uint64) *v2error.Error {
 		return v2error.NewError(v2error.EcodeNotFile, "", n.store.CurrentIndex)
 	}
 
+	n.store.addMemory(int64(len(value) - len(n.Value)))
 	n.Value = value
 	n.ModifiedIndex = index
 
@@ -129,7 +133,11 @@ func (n *node) Write(value string, index uint64) *v2error.Error {
 }
 
 func (n *node) expirationAndTTL(clock clockwork.Clock) (*time.Time, int64) {
-	if !n.IsPermanent() {
+	return expirationAndTTL(n.ExpireTime, clock)
+}
+
+func expirationAndTTL(expireTime time.Time, clock clockwork.Clock) (*time.Time, int64) {
+	if !expireTime.IsZero() {
 		/* compute ttl as:
 		   ceiling( (expireTime - timeNow) / nanosecondsPerSecond )
 		   which ranges from 1..n
@@ -137,12 +145,12 @@ func (n *node) expirationAndTTL(clock clockwork.Clock) (*time.Time, int64) {
 		   ( (expireTime - timeNow) / nanosecondsPerSecond ) + 1
 		   which ranges 1..n+1
 		*/
-		ttlN := n.ExpireTime.Sub(clock.Now())
+		ttlN := expireTime.Sub(clock.Now())
 		ttl := ttlN / time.Second
 		if (ttlN % time.Second) > 0 {
 			ttl++
 		}
-		t := n.ExpireTime.UTC()
+		t := expireTime.UTC()
 		return &t, int64(ttl)
 	}
 	return nil, 0
@@ -198,6 +206,10 @@ func (n *node) Add(child *node) *v2error.Error {
 	}
 
 	n.Children[name] = child
+	n.store.addMemory(nodeMemory(child))
+	if n.claims != nil {
+		n.claims.add(child)
+	}
 
 	return nil
 }
@@ -210,6 +222,10 @@ func (n *node) Remove(dir, recursive bool, callback func(path string)) *v2error.
 		// find its parent and remove the node from the map
 		if n.Parent != nil && n.Parent.Children[name] == n {
 			delete(n.Parent.Children, name)
+			n.store.addMemory(-nodeMemory(n))
+			if n.Parent.claims != nil {
+				n.Parent.claims.remove(n)
+			}
 		}
 
 		if callback != nil {
@@ -242,6 +258,7 @@ func (n *node) Remove(dir, recursive bool, callback func(path string)) *v2error.
 	_, name := path.Split(n.Path)
 	if n.Parent != nil && n.Parent.Children[name] == n {
 		delete(n.Parent.Children, name)
+		n.store.addMemory(-nodeMemory(n))
 
 		if callback != nil {
 			callback(n.Path)
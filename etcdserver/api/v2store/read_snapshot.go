@@ -0,0 +1,270 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v2store
+
+import (
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.etcd.io/etcd/etcdserver/api/v2error"
+
+	"github.com/google/btree"
+	"github.com/jonboulle/clockwork"
+)
+
+// readTreeDegree is the degree of the btree mirroring the node tree.
+const readTreeDegree = 32
+
+// readNode is the immutable copy of a node kept in the read tree.
+type readNode struct {
+	path          string
+	value         string
+	dir           bool
+	createdIndex  uint64
+	modifiedIndex uint64
+	expireTime    time.Time
+}
+
+func newReadNode(n *node) *readNode {
+	return &readNode{
+		path:          n.Path,
+		value:         n.Value,
+		dir:           n.IsDir(),
+		createdIndex:  n.CreatedIndex,
+		modifiedIndex: n.ModifiedIndex,
+		expireTime:    n.ExpireTime,
+	}
+}
+
+func (rn *readNode) Less(than btree.Item) bool {
+	return rn.path < than.(*readNode).path
+}
+
+// repr returns the external representation of the node, without its
+// children.
+func (rn *readNode) repr(clock clockwork.Clock) *NodeExtern {
+	eNode := &NodeExtern{
+		Key:           rn.path,
+		Dir:           rn.dir,
+		ModifiedIndex: rn.modifiedIndex,
+		CreatedIndex:  rn.createdIndex,
+	}
+	if rn.dir {
+		eNode.Nodes = NodeExterns{}
+	} else {
+		value := rn.value
+		eNode.Value = &value
+	}
+	eNode.Expiration, eNode.TTL = expirationAndTTL(rn.expireTime, clock)
+	return eNode
+}
+
+// readSnapshot is an immutable view of the store as of index. The store
+// publishes one after every write, so that recursive gets, which may
+// walk large parts of the tree, are served without holding the world
+// lock and so without blocking the applies.
+type readSnapshot struct {
+	tree  *btree.BTree
+	index uint64
+
+	keyspaceMu sync.Mutex
+	// keyspace caches the shape of the key space by path.
+	keyspace map[string]*KeyspaceStats
+}
+
+func (rs *readSnapshot) lookup(p string) *readNode {
+	item := rs.tree.Get(&readNode{path: p})
+	if item == nil {
+		return nil
+	}
+	return item.(*readNode)
+}
+
+// get returns the same event as a recursive get on the store at the time
+// the snapshot was taken. Children are always sorted.
+func (rs *readSnapshot) get(nodePath string, clock clockwork.Clock) (*Event, *v2error.Error) {
+	p := path.Clean(path.Join("/", nodePath))
+
+	// resolve the path like store.walk does, to return the same errors
+	curr := rs.lookup("/")
+	for _, name := range strings.Split(p, "/")[1:] {
+		if len(name) == 0 {
+			break
+		}
+		if !curr.dir {
+			return nil, v2error.NewError(v2error.EcodeNotDir, curr.path, rs.index)
+		}
+		child := rs.lookup(path.Join(curr.path, name))
+		if child == nil {
+			return nil, v2error.NewError(v2error.EcodeKeyNotFound, path.Join(curr.path, name), rs.index)
+		}
+		curr = child
+	}
+
+	e := newEvent(Get, nodePath, curr.modifiedIndex, curr.createdIndex)
+	e.EtcdIndex = rs.index
+	eNode := curr.repr(clock)
+	eNode.Key = nodePath
+	e.Node = eNode
+	if !curr.dir {
+		return e, nil
+	}
+
+	// a prefix sorts before the paths it prefixes, so every directory is
+	// visited before its children; children of hidden nodes are skipped
+	// since their parent is never added to dirs.
+	dirs := map[string]*NodeExtern{curr.path: eNode}
+	ascendDescendants(rs.tree, curr.path, func(rn *readNode) bool {
+		dir, name := path.Split(rn.path)
+		parent := dirs[path.Clean(dir)]
+		if parent == nil || name[0] == '_' {
+			return true
+		}
+		child := rn.repr(clock)
+		parent.Nodes = append(parent.Nodes, child)
+		if rn.dir {
+			dirs[rn.path] = child
+		}
+		return true
+	})
+	return e, nil
+}
+
+// ascendDescendants calls f, in order, on the nodes under the directory
+// at p, until f returns false.
+func ascendDescendants(tree *btree.BTree, p string, f func(rn *readNode) bool) {
+	prefix := strings.TrimSuffix(p, "/") + "/"
+	// '0' directly follows '/', so the range holds exactly the paths
+	// starting with prefix
+	end := prefix[:len(prefix)-1] + "0"
+	tree.AscendRange(&readNode{path: prefix}, &readNode{path: end}, func(item btree.Item) bool {
+		rn := item.(*readNode)
+		if rn.path == p {
+			return true
+		}
+		return f(rn)
+	})
+}
+
+// KeyExport is the export of the nodes at or under a path, as of Index.
+// The nodes are only read as they are walked, from the snapshot the export
+// was taken from, so that exporting a large part of the tree neither
+// blocks the writes nor holds the whole export in memory.
+type KeyExport struct {
+	// Index is the index of the store the nodes are exported at.
+	Index uint64
+
+	rs    *readSnapshot
+	root  *readNode
+	clock clockwork.Clock
+}
+
+// Walk calls f on every exported node, without its children and with the
+// parents before their children, until f returns an error, which Walk
+// returns.
+func (e *KeyExport) Walk(f func(n *NodeExtern) error) error {
+	if e.root == nil {
+		return nil
+	}
+	var err error
+	export := func(rn *readNode) bool {
+		n := rn.repr(e.clock)
+		n.Nodes = nil
+		err = f(n)
+		return err == nil
+	}
+	if !export(e.root) || !e.root.dir {
+		return err
+	}
+	// a prefix sorts before the paths it prefixes, so the parents are
+	// exported before their children
+	ascendDescendants(e.rs.tree, e.root.path, export)
+	return err
+}
+
+// Export walks the last published snapshot, so that exporting a large
+// part of the tree does not block the writes.
+func (s *store) Export(nodePath string) (*KeyExport, error) {
+	rs := s.readSnapshot()
+	p := path.Clean(path.Join("/", nodePath))
+	rn := rs.lookup(p)
+	if rn == nil {
+		return nil, v2error.NewError(v2error.EcodeKeyNotFound, p, rs.index)
+	}
+	return &KeyExport{Index: rs.index, rs: rs, root: rn, clock: s.clock}, nil
+}
+
+// touch records that the node at p, or its children, changed and must be
+// copied into the read tree before the next snapshot is published.
+func (s *store) touch(p string) {
+	s.readDirty[p] = struct{}{}
+}
+
+// rebuildReadTree copies the whole node tree into a new read tree, and
+// publishes it.
+func (s *store) rebuildReadTree() {
+	s.readTree = btree.New(readTreeDegree)
+	s.readDirty = make(map[string]struct{})
+	var size int64
+	var walk func(n *node)
+	walk = func(n *node) {
+		s.readTree.ReplaceOrInsert(newReadNode(n))
+		size += nodeMemory(n)
+		for _, child := range n.Children {
+			walk(child)
+		}
+	}
+	walk(s.Root)
+	atomic.StoreInt64(&s.memBytes, size)
+	s.readSnap.Store(&readSnapshot{tree: s.readTree.Clone(), index: s.CurrentIndex})
+}
+
+// publishReadSnapshot copies the touched nodes into the read tree, and
+// publishes a snapshot of it. It must be called with the world lock held.
+func (s *store) publishReadSnapshot() {
+	if len(s.readDirty) == 0 && s.readSnapshot().index == s.CurrentIndex {
+		return
+	}
+	for p := range s.readDirty {
+		n, _ := s.internalGet(p)
+		if n == nil || !n.IsDir() || len(n.Children) == 0 {
+			// the node was removed or replaced, so were its children
+			var stale []btree.Item
+			ascendDescendants(s.readTree, p, func(rn *readNode) bool {
+				stale = append(stale, rn)
+				return true
+			})
+			for _, item := range stale {
+				s.readTree.Delete(item)
+			}
+		}
+		if n == nil {
+			s.readTree.Delete(&readNode{path: p})
+		} else {
+			s.readTree.ReplaceOrInsert(newReadNode(n))
+		}
+		delete(s.readDirty, p)
+	}
+	// cloning is cheap: both trees share their nodes until either changes
+	s.readSnap.Store(&readSnapshot{tree: s.readTree.Clone(), index: s.CurrentIndex})
+}
+
+// readSnapshot returns the last published snapshot of the store.
+func (s *store) readSnapshot() *readSnapshot {
+	return s.readSnap.Load().(*readSnapshot)
+}
@@ -0,0 +1,217 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v2store
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"go.etcd.io/etcd/etcdserver/api/v2error"
+
+	"github.com/jonboulle/clockwork"
+)
+
+// lockedRecursiveGet is the recursive get as served before read
+// snapshots, walking the node tree under the world lock.
+func lockedRecursiveGet(s *store, nodePath string) (*Event, *v2error.Error) {
+	s.worldLock.RLock()
+	defer s.worldLock.RUnlock()
+	n, err := s.internalGet(nodePath)
+	if err != nil {
+		return nil, err
+	}
+	e := newEvent(Get, nodePath, n.ModifiedIndex, n.CreatedIndex)
+	e.EtcdIndex = s.CurrentIndex
+	e.Node.loadInternalNode(n, true, true, s.clock)
+	return e, nil
+}
+
+func TestReadSnapshotGet(t *testing.T) {
+	s := newStore("/ns")
+	fc := clockwork.NewFakeClock()
+	s.clock = fc
+	ttl := TTLOptionSet{ExpireTime: fc.Now().Add(10 * time.Second)}
+	perm := TTLOptionSet{ExpireTime: Permanent}
+
+	paths := []string{"/a", "/a/b", "/a/b-x", "/a/b/c", "/a/_hidden", "/a/_hidden/d", "/ns/e", "/f"}
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 500; i++ {
+		p := paths[r.Intn(len(paths))]
+		switch r.Intn(7) {
+		case 0:
+			s.Create(p, false, fmt.Sprint(i), false, perm)
+		case 1:
+			s.Create(p, true, "", false, ttl)
+		case 2:
+			s.Set(p, false, fmt.Sprint(i), ttl)
+		case 3:
+			s.Update(p, fmt.Sprint(i), perm)
+		case 4:
+			s.Delete(p, true, true)
+		case 5:
+			s.Create(p, false, fmt.Sprint(i), true, perm)
+		case 6:
+			fc.Advance(3 * time.Second)
+			s.DeleteExpiredKeys(fc.Now())
+		}
+
+		for _, q := range append([]string{"/", "/a/b/c/d"}, paths...) {
+			we, werr := lockedRecursiveGet(s, q)
+			e, err := s.readSnapshot().get(q, s.clock)
+			if !reflect.DeepEqual(err, werr) {
+				t.Fatalf("#%d: get %s error = %v, want %v", i, q, err, werr)
+			}
+			if !reflect.DeepEqual(e, we) {
+				t.Fatalf("#%d: get %s = %+v, want %+v", i, q, e, we)
+			}
+		}
+	}
+
+	// the snapshot of a recovered store holds the recovered nodes
+	b, err := s.Save()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s2 := newStore()
+	s2.clock = s.clock
+	if err = s2.Recovery(b); err != nil {
+		t.Fatal(err)
+	}
+	we, _ := lockedRecursiveGet(s2, "/")
+	e, _ := s2.readSnapshot().get("/", s2.clock)
+	if !reflect.DeepEqual(e, we) {
+		t.Fatalf("recovered get = %+v, want %+v", e, we)
+	}
+}
+
+// TestRecursiveGetConcurrentWithWrites ensures recursive gets do not take
+// the world lock, and see consistent snapshots while the store is written.
+func TestRecursiveGetConcurrentWithWrites(t *testing.T) {
+	s := newStore()
+	s.clock = clockwork.NewFakeClock()
+	for i := 0; i < 100; i++ {
+		s.Create(fmt.Sprintf("/dir/%d", i), false, "v", false, TTLOptionSet{ExpireTime: Permanent})
+	}
+
+	// a recursive get is served while a writer holds the world lock
+	s.worldLock.Lock()
+	donec := make(chan struct{})
+	go func() {
+		defer close(donec)
+		if _, err := s.Get("/dir", true, false); err != nil {
+			t.Error(err)
+		}
+	}()
+	select {
+	case <-donec:
+	case <-time.After(5 * time.Second):
+		t.Fatal("recursive get blocked by the world lock")
+	}
+	s.worldLock.Unlock()
+
+	var wg sync.WaitGroup
+	stopc := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stopc:
+					return
+				default:
+				}
+				e, err := s.Get("/dir", true, false)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				// every write adds a key, so a consistent snapshot at
+				// index i holds exactly i keys
+				if uint64(len(e.Node.Nodes)) != e.EtcdIndex {
+					t.Errorf("got %d keys at index %d", len(e.Node.Nodes), e.EtcdIndex)
+					return
+				}
+			}
+		}()
+	}
+	for i := 100; i < 1000; i++ {
+		s.Create(fmt.Sprintf("/dir/%d", i), false, "v", false, TTLOptionSet{ExpireTime: Permanent})
+	}
+	close(stopc)
+	wg.Wait()
+}
+
+func TestExport(t *testing.T) {
+	s := newStore()
+	s.clock = newFakeClock()
+	perm := TTLOptionSet{ExpireTime: Permanent}
+	s.Create("/a/b", false, "1", false, perm)
+	s.Create("/a/_hidden", false, "2", false, perm)
+	s.Create("/a/c/d", false, "3", false, TTLOptionSet{ExpireTime: s.clock.Now().Add(time.Minute)})
+	s.Create("/a-x", false, "4", false, perm)
+
+	ex, err := s.Export("/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ex.Index != 4 {
+		t.Errorf("index = %d, want 4", ex.Index)
+	}
+	// the export is taken as of its index
+	s.Create("/a/e", false, "5", false, perm)
+
+	var nodes []*NodeExtern
+	var keys []string
+	err = ex.Walk(func(n *NodeExtern) error {
+		if n.Nodes != nil {
+			t.Errorf("%s exported with its children", n.Key)
+		}
+		nodes = append(nodes, n)
+		keys = append(keys, n.Key)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the parents come first, and the hidden nodes are exported
+	wkeys := []string{"/a", "/a/_hidden", "/a/b", "/a/c", "/a/c/d"}
+	if !reflect.DeepEqual(keys, wkeys) {
+		t.Errorf("keys = %v, want %v", keys, wkeys)
+	}
+	if n := nodes[4]; *n.Value != "3" || n.TTL != 60 {
+		t.Errorf("node = %+v, want value 3 with a TTL of 60", n)
+	}
+
+	// the walk stops at the first error
+	errStop := errors.New("stop")
+	n := 0
+	err = ex.Walk(func(*NodeExtern) error {
+		n++
+		return errStop
+	})
+	if err != errStop || n != 1 {
+		t.Errorf("walk = %d nodes, %v, want 1 node, %v", n, err, errStop)
+	}
+
+	if _, err = s.Export("/nope"); err == nil {
+		t.Fatal("exported a missing key")
+	}
+}
@@ -76,6 +76,10 @@ type Stats struct {
 	ExpireCount uint64 `json:"expireCount"`
 
 	Watchers uint64 `json:"watchers"`
+
+	// Keyspace is the shape of the key space, only set in the stats
+	// served by the members, see Store.KeyspaceStats.
+	Keyspace *KeyspaceStats `json:"keyspace,omitempty"`
 }
 
 func newStats() *Stats {
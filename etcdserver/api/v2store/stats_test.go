@@ -15,6 +15,9 @@
 package v2store
 
 import (
+	"encoding/json"
+	"fmt"
+	"strings"
 	"testing"
 	"time"
 
@@ -110,3 +113,45 @@ func TestStoreStatsExpireCount(t *testing.T) {
 	s.DeleteExpiredKeys(fc.Now())
 	testutil.AssertEqual(t, uint64(1), s.Stats.ExpireCount, "")
 }
+
+// Ensure that the shape of the key space is reported for the keys under
+// the given directory only.
+func TestStoreStatsKeyspace(t *testing.T) {
+	s := newStore("/0", "/1")
+	perm := TTLOptionSet{ExpireTime: Permanent}
+	s.Create("/1/a/b/c", false, strings.Repeat("x", 64), false, perm)
+	s.Create("/1/a/b/d", false, strings.Repeat("x", 65), false, perm)
+	s.Create("/1/a-x", false, strings.Repeat("x", 5<<20), false, perm)
+	s.Create("/1/a/e", true, "", false, perm)
+	for i := 0; i < 6; i++ {
+		s.Create(fmt.Sprintf("/1/f/%d", i), false, "", false, perm)
+	}
+	// not under /1
+	s.Create("/0/members/1/attributes", false, strings.Repeat("x", 1<<20), false, perm)
+	s.Create("/1-x", false, "", false, perm)
+
+	ks := s.KeyspaceStats("/1")
+	testutil.AssertEqual(t, uint64(9), ks.Keys, "")
+	testutil.AssertEqual(t, uint64(4), ks.Dirs, "")
+	testutil.AssertEqual(t, uint64(64+65+5<<20), ks.ValueBytes, "")
+	counts := make([]uint64, len(ks.ValueSizes))
+	for i, b := range ks.ValueSizes {
+		counts[i] = b.Count
+	}
+	testutil.AssertEqual(t, []uint64{7, 1, 0, 0, 0, 0, 0, 0, 0, 1, 0}, counts, "")
+	testutil.AssertEqual(t, uint64(16<<20), ks.ValueSizes[9].MaxBytes, "")
+	testutil.AssertEqual(t, uint64(0), ks.ValueSizes[10].MaxBytes, "")
+	wlargest := []KeySize{{"/a-x", 5 << 20}, {"/a/b/d", 65}, {"/a/b/c", 64}, {"/f/0", 0}, {"/f/1", 0}}
+	testutil.AssertEqual(t, wlargest, ks.LargestValues, "")
+	testutil.AssertEqual(t, 3, ks.MaxDepth, "")
+	testutil.AssertEqual(t, KeyChildren{Key: "/f", Children: 6}, ks.MaxChildren, "")
+
+	// the JSON stats hold no keys
+	var st Stats
+	if err := json.Unmarshal(s.JsonStats(), &st); err != nil {
+		t.Fatal(err)
+	}
+	if st.Keyspace != nil {
+		t.Errorf("keyspace = %+v, want none in the JSON stats", st.Keyspace)
+	}
+}
@@ -21,11 +21,13 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"go.etcd.io/etcd/etcdserver/api/v2error"
 	"go.etcd.io/etcd/pkg/types"
 
+	"github.com/google/btree"
 	"github.com/jonboulle/clockwork"
 )
 
@@ -38,11 +40,22 @@ func init() {
 	minExpireTime, _ = time.Parse(time.RFC3339, "2000-01-01T00:00:00Z")
 }
 
+// Store is the v2 keyspace. It is implemented by the in-memory store
+// returned by New, and by the v2v3 store, which keeps the v2 keyspace in
+// the v3 backend (see --experimental-enable-v2v3).
 type Store interface {
 	Version() int
 	Index() uint64
 
 	Get(nodePath string, recursive, sorted bool) (*Event, error)
+	// GetMulti returns the events getting the nodes at nodePaths, not
+	// recursively, all at the same index. errs[i] is the error getting
+	// the node at nodePaths[i], if any, in which case evs[i] is nil.
+	GetMulti(nodePaths []string, sorted bool) (evs []*Event, errs []error)
+	// Lookup returns the node at nodePath, not recursively, without
+	// counting it in the stats, for the lookups the members make when
+	// applying the writes.
+	Lookup(nodePath string) (*NodeExtern, error)
 	Set(nodePath string, dir bool, value string, expireOpts TTLOptionSet) (*Event, error)
 	Update(nodePath string, newValue string, expireOpts TTLOptionSet) (*Event, error)
 	Create(nodePath string, dir bool, value string, unique bool,
@@ -51,8 +64,17 @@ type Store interface {
 		value string, expireOpts TTLOptionSet) (*Event, error)
 	Delete(nodePath string, dir, recursive bool) (*Event, error)
 	CompareAndDelete(nodePath string, prevValue string, prevIndex uint64) (*Event, error)
+	// ClaimNext deletes the oldest key directly under the directory at
+	// dirPath and returns it, so that consumers of an in-order queue never
+	// claim the same item.
+	ClaimNext(dirPath string) (*Event, error)
 
 	Watch(prefix string, recursive, stream bool, sinceIndex uint64) (Watcher, error)
+	// WatchExisting returns a watcher receiving a single event once the
+	// node at key exists: a get event right away if it already exists, or
+	// the event creating it otherwise. A node created as a directory by a
+	// write below it gets a create event of its own.
+	WatchExisting(key string) (Watcher, error)
 
 	Save() ([]byte, error)
 	Recovery(state []byte) error
@@ -61,9 +83,59 @@ type Store interface {
 	SaveNoCopy() ([]byte, error)
 
 	JsonStats() []byte
+	// KeyspaceStats returns the shape of the key space under the
+	// directory at nodePath, with the keys relative to nodePath.
+	KeyspaceStats(nodePath string) *KeyspaceStats
 	DeleteExpiredKeys(cutoff time.Time)
+	// ExpiredKeys returns, by expiration time, at most limit of the nodes
+	// expired at cutoff.
+	ExpiredKeys(cutoff time.Time, limit int) []ExpiredKey
+	// ExpireKeys deletes the given nodes, if they are still expired at
+	// cutoff and were not modified since they were listed by ExpiredKeys.
+	ExpireKeys(keys []ExpiredKey, cutoff time.Time)
 
 	HasTTLKeys() bool
+
+	// TombstoneRetention returns how long the deleted nodes are retained
+	// as tombstones, see NewWithTombstones.
+	TombstoneRetention() time.Duration
+	// SetTombstoneRetention sets how long the deleted nodes are retained
+	// as tombstones. It must be called when applying a request, so that
+	// every member retains the same tombstones.
+	SetTombstoneRetention(retention time.Duration)
+	// ExpiredHistoryRetention returns how long the events of the expired
+	// nodes stay in the event history; 0 never prunes them.
+	ExpiredHistoryRetention() time.Duration
+	// SetExpiredHistoryRetention sets how long the events of the expired
+	// nodes stay in the event history. The events are pruned by the SYNC
+	// requests, so it must be called when applying a request, so that
+	// every member prunes the same events.
+	SetExpiredHistoryRetention(retention time.Duration)
+	// ListTombstones returns the retained tombstones of the nodes at or
+	// under nodePath, see NewWithTombstones.
+	ListTombstones(nodePath string) []Tombstone
+	// Undelete recreates the node at nodePath deleted at index from its
+	// tombstone, or the last deleted one if index is 0.
+	Undelete(nodePath string, index uint64) (*Event, error)
+
+	// CountKeys returns the number of keys, not counting directories, at
+	// or under nodePath. If limit is positive, counting stops as soon as
+	// the count exceeds it.
+	CountKeys(nodePath string, limit int) int
+
+	// Export returns the export of the nodes at or under nodePath,
+	// including the hidden ones.
+	Export(nodePath string) (*KeyExport, error)
+
+	// Inspect returns the internal state of the store about the key at
+	// nodePath, for debugging.
+	Inspect(nodePath string) (*KeyInternals, error)
+}
+
+// ExpiredKey identifies a node that expired.
+type ExpiredKey struct {
+	Key           string `json:"key"`
+	ModifiedIndex uint64 `json:"modifiedIndex"`
 }
 
 type TTLOptionSet struct {
@@ -81,6 +153,29 @@ type store struct {
 	worldLock      sync.RWMutex // stop the world lock
 	clock          clockwork.Clock
 	readonlySet    types.Set
+
+	// Tombstones are the deletions retained for TombstoneWindow, by
+	// deletion index. Every deletion after TombstonesSince is retained,
+	// until its TombstoneWindow ends.
+	Tombstones      []*Tombstone  `json:",omitempty"`
+	TombstoneWindow time.Duration `json:",omitempty"`
+	TombstonesSince uint64        `json:",omitempty"`
+
+	// Expirations are the expirations whose events are pruned from the
+	// event history after historyRetention, by expiration index.
+	Expirations      []*keyExpiration `json:",omitempty"`
+	historyRetention time.Duration
+
+	// readTree mirrors Root as a copy-on-write btree of the nodes by path.
+	// Writers copy the nodes they touched into it, and then publish a
+	// clone of it in readSnap.
+	readTree  *btree.BTree
+	readDirty map[string]struct{}
+	readSnap  atomic.Value // *readSnapshot
+
+	// memBytes is the estimated memory held by the nodes, kept up to date
+	// by the writes; must use atomic operations to access.
+	memBytes int64
 }
 
 // New creates a store where the given namespaces will be created as initial directories.
@@ -101,6 +196,7 @@ func newStore(namespaces ...string) *store {
 	s.WatcherHub = newWatchHub(1000)
 	s.ttlKeyHeap = newTtlKeyHeap()
 	s.readonlySet = types.NewUnsafeSet(append(namespaces, "/")...)
+	s.rebuildReadTree()
 	return s
 }
 
@@ -122,9 +218,6 @@ func (s *store) Index() uint64 {
 func (s *store) Get(nodePath string, recursive, sorted bool) (*Event, error) {
 	var err *v2error.Error
 
-	s.worldLock.RLock()
-	defer s.worldLock.RUnlock()
-
 	defer func() {
 		if err == nil {
 			s.Stats.Inc(GetSuccess)
@@ -144,6 +237,20 @@ func (s *store) Get(nodePath string, recursive, sorted bool) (*Event, error) {
 		}
 	}()
 
+	if recursive {
+		// recursive gets may copy large subtrees, so they are served from
+		// the last published snapshot to not block the writes
+		var e *Event
+		e, err = s.readSnapshot().get(nodePath, s.clock)
+		if err != nil {
+			return nil, err
+		}
+		return e, nil
+	}
+
+	s.worldLock.RLock()
+	defer s.worldLock.RUnlock()
+
 	n, err := s.internalGet(nodePath)
 	if err != nil {
 		return nil, err
@@ -156,6 +263,30 @@ func (s *store) Get(nodePath string, recursive, sorted bool) (*Event, error) {
 	return e, nil
 }
 
+func (s *store) GetMulti(nodePaths []string, sorted bool) ([]*Event, []error) {
+	s.worldLock.RLock()
+	defer s.worldLock.RUnlock()
+
+	evs, errs := make([]*Event, len(nodePaths)), make([]error, len(nodePaths))
+	for i, nodePath := range nodePaths {
+		n, err := s.internalGet(nodePath)
+		if err != nil {
+			s.Stats.Inc(GetFail)
+			reportReadFailure(Get)
+			errs[i] = err
+			continue
+		}
+		s.Stats.Inc(GetSuccess)
+		reportReadSuccess(Get)
+
+		e := newEvent(Get, nodePath, n.ModifiedIndex, n.CreatedIndex)
+		e.EtcdIndex = s.CurrentIndex
+		e.Node.loadInternalNode(n, false, sorted, s.clock)
+		evs[i] = e
+	}
+	return evs, errs
+}
+
 // Create creates the node at nodePath. Create will help to create intermediate directories with no ttl.
 // If the node has already existed, create will fail.
 // If any node on the path is a file, create will fail.
@@ -164,6 +295,7 @@ func (s *store) Create(nodePath string, dir bool, value string, unique bool, exp
 
 	s.worldLock.Lock()
 	defer s.worldLock.Unlock()
+	defer s.publishReadSnapshot()
 
 	defer func() {
 		if err == nil {
@@ -193,6 +325,7 @@ func (s *store) Set(nodePath string, dir bool, value string, expireOpts TTLOptio
 
 	s.worldLock.Lock()
 	defer s.worldLock.Unlock()
+	defer s.publishReadSnapshot()
 
 	defer func() {
 		if err == nil {
@@ -263,6 +396,7 @@ func (s *store) CompareAndSwap(nodePath string, prevValue string, prevIndex uint
 
 	s.worldLock.Lock()
 	defer s.worldLock.Unlock()
+	defer s.publishReadSnapshot()
 
 	defer func() {
 		if err == nil {
@@ -312,6 +446,7 @@ func (s *store) CompareAndSwap(nodePath string, prevValue string, prevIndex uint
 
 	// if test succeed, write the value
 	n.Write(value, s.CurrentIndex)
+	s.touch(n.Path)
 	n.UpdateTTL(expireOpts.ExpireTime)
 
 	// copy the value for safety
@@ -336,6 +471,7 @@ func (s *store) Delete(nodePath string, dir, recursive bool) (*Event, error) {
 
 	s.worldLock.Lock()
 	defer s.worldLock.Unlock()
+	defer s.publishReadSnapshot()
 
 	defer func() {
 		if err == nil {
@@ -379,10 +515,13 @@ func (s *store) Delete(nodePath string, dir, recursive bool) (*Event, error) {
 		s.WatcherHub.notifyWatchers(e, path, true)
 	}
 
+	ts := s.tombstone(e, n)
 	err = n.Remove(dir, recursive, callback)
+	s.touch(n.Path)
 	if err != nil {
 		return nil, err
 	}
+	s.addTombstone(ts)
 
 	// update etcd index
 	s.CurrentIndex++
@@ -397,6 +536,7 @@ func (s *store) CompareAndDelete(nodePath string, prevValue string, prevIndex ui
 
 	s.worldLock.Lock()
 	defer s.worldLock.Unlock()
+	defer s.publishReadSnapshot()
 
 	defer func() {
 		if err == nil {
@@ -438,10 +578,78 @@ func (s *store) CompareAndDelete(nodePath string, prevValue string, prevIndex ui
 		s.WatcherHub.notifyWatchers(e, path, true)
 	}
 
+	ts := s.tombstone(e, n)
 	err = n.Remove(false, false, callback)
+	s.touch(n.Path)
 	if err != nil {
 		return nil, err
 	}
+	s.addTombstone(ts)
+
+	s.WatcherHub.notify(e)
+
+	return e, nil
+}
+
+func (s *store) ClaimNext(dirPath string) (*Event, error) {
+	var err *v2error.Error
+
+	s.worldLock.Lock()
+	defer s.worldLock.Unlock()
+	defer s.publishReadSnapshot()
+
+	defer func() {
+		if err == nil {
+			s.Stats.Inc(DeleteSuccess)
+			reportWriteSuccess(Claim)
+			return
+		}
+
+		s.Stats.Inc(DeleteFail)
+		reportWriteFailure(Claim)
+	}()
+
+	dirPath = path.Clean(path.Join("/", dirPath))
+
+	d, err := s.internalGet(dirPath)
+	if err != nil { // if the directory does not exist, return error
+		return nil, err
+	}
+	if !d.IsDir() {
+		err = v2error.NewError(v2error.EcodeNotDir, dirPath, s.CurrentIndex)
+		return nil, err
+	}
+
+	// in-order keys are named after their creation index, so the oldest
+	// key is the head of the queue
+	if d.claims == nil {
+		d.claims = newClaimQueue(d)
+	}
+	n := d.claims.top()
+	if n == nil {
+		err = v2error.NewError(v2error.EcodeKeyNotFound, dirPath, s.CurrentIndex)
+		return nil, err
+	}
+
+	// update etcd index
+	s.CurrentIndex++
+
+	e := newEvent(Claim, n.Path, s.CurrentIndex, n.CreatedIndex)
+	e.EtcdIndex = s.CurrentIndex
+	e.PrevNode = n.Repr(false, false, s.clock)
+
+	callback := func(path string) { // notify function
+		// notify the watchers with deleted set true
+		s.WatcherHub.notifyWatchers(e, path, true)
+	}
+
+	ts := s.tombstone(e, n)
+	err = n.Remove(false, false, callback)
+	s.touch(n.Path)
+	if err != nil {
+		return nil, err
+	}
+	s.addTombstone(ts)
 
 	s.WatcherHub.notify(e)
 
@@ -459,12 +667,45 @@ func (s *store) Watch(key string, recursive, stream bool, sinceIndex uint64) (Wa
 	// WatcherHub does not know about the current index, so we need to pass it in
 	w, err := s.WatcherHub.watch(key, recursive, stream, sinceIndex, s.CurrentIndex)
 	if err != nil {
+		// the deletions cleared from the history may still be retained
+		if err.ErrorCode == v2error.EcodeEventIndexCleared && !stream {
+			if e := s.scanTombstones(key, recursive, sinceIndex); e != nil {
+				ne := e.Clone()
+				ne.EtcdIndex = s.CurrentIndex
+				return s.WatcherHub.watchEvent(ne, s.CurrentIndex), nil
+			}
+		}
 		return nil, err
 	}
 
 	return w, nil
 }
 
+func (s *store) WatchExisting(key string) (Watcher, error) {
+	// the node is looked up and the watcher registered under the same
+	// lock, so no creation can happen in between
+	s.worldLock.RLock()
+	defer s.worldLock.RUnlock()
+
+	key = path.Clean(path.Join("/", key))
+	n, err := s.internalGet(key)
+	if err == nil {
+		e := newEvent(Get, key, n.ModifiedIndex, n.CreatedIndex)
+		e.EtcdIndex = s.CurrentIndex
+		e.Node.loadInternalNode(n, false, false, s.clock)
+		return s.WatcherHub.watchEvent(e, s.CurrentIndex), nil
+	}
+	if err.ErrorCode != v2error.EcodeKeyNotFound {
+		return nil, err
+	}
+
+	w, err := s.WatcherHub.watchExisting(key, s.CurrentIndex)
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
 // walk walks all the nodePath and apply the walkFunc on each directory
 func (s *store) walk(nodePath string, walkFunc func(prev *node, component string) (*node, *v2error.Error)) (*node, *v2error.Error) {
 	components := strings.Split(nodePath, "/")
@@ -494,6 +735,7 @@ func (s *store) Update(nodePath string, newValue string, expireOpts TTLOptionSet
 
 	s.worldLock.Lock()
 	defer s.worldLock.Unlock()
+	defer s.publishReadSnapshot()
 
 	defer func() {
 		if err == nil {
@@ -533,6 +775,7 @@ func (s *store) Update(nodePath string, newValue string, expireOpts TTLOptionSet
 	eNode := e.Node
 
 	n.Write(newValue, nextIndex)
+	s.touch(n.Path)
 
 	if n.IsDir() {
 		eNode.Dir = true
@@ -627,6 +870,7 @@ func (s *store) internalCreate(nodePath string, dir bool, value string, unique,
 
 	// we are sure d is a directory and does not have the children with name n.Name
 	d.Add(n)
+	s.touch(n.Path)
 
 	// node with TTL
 	if !n.IsPermanent() {
@@ -667,39 +911,142 @@ func (s *store) internalGet(nodePath string) (*node, *v2error.Error) {
 	return f, nil
 }
 
+func (s *store) Lookup(nodePath string) (*NodeExtern, error) {
+	s.worldLock.RLock()
+	defer s.worldLock.RUnlock()
+
+	n, err := s.internalGet(nodePath)
+	if err != nil {
+		return nil, err
+	}
+	return n.Repr(false, false, s.clock), nil
+}
+
+func (s *store) CountKeys(nodePath string, limit int) int {
+	s.worldLock.RLock()
+	defer s.worldLock.RUnlock()
+
+	n, err := s.internalGet(nodePath)
+	if err != nil {
+		return 0
+	}
+	count := 0
+	var walk func(n *node) bool
+	walk = func(n *node) bool {
+		if !n.IsDir() {
+			count++
+			return limit <= 0 || count <= limit
+		}
+		for _, child := range n.Children {
+			if !walk(child) {
+				return false
+			}
+		}
+		return true
+	}
+	walk(n)
+	return count
+}
+
+// nodeOverhead is an estimate of the memory held by a node besides its
+// path and value: the node itself and its entry in the children of its
+// parent.
+const nodeOverhead = 160
+
+// nodeMemory returns the estimated memory held by n, without its children.
+func nodeMemory(n *node) int64 {
+	return nodeOverhead + int64(len(n.Path)+len(n.Value))
+}
+
+// addMemory adds delta to the estimated memory held by the nodes.
+func (s *store) addMemory(delta int64) {
+	atomic.AddInt64(&s.memBytes, delta)
+}
+
+// EstimateMemory returns an estimate of the memory held by the nodes of
+// st, hidden ones included, or 0 if st does not hold its nodes in memory.
+// The estimate is kept up to date by the writes, so it is cheap to call.
+func EstimateMemory(st Store) int64 {
+	s, ok := st.(*store)
+	if !ok {
+		return 0
+	}
+	return atomic.LoadInt64(&s.memBytes)
+}
+
 // DeleteExpiredKeys will delete all expired keys
 func (s *store) DeleteExpiredKeys(cutoff time.Time) {
 	s.worldLock.Lock()
 	defer s.worldLock.Unlock()
+	defer s.publishReadSnapshot()
 
 	for {
 		node := s.ttlKeyHeap.top()
 		if node == nil || node.ExpireTime.After(cutoff) {
 			break
 		}
+		s.ttlKeyHeap.pop()
+		s.expire(node, cutoff)
+	}
+	s.ageTombstones(cutoff)
+	s.sweepExpiredHistory(cutoff)
+}
 
-		s.CurrentIndex++
-		e := newEvent(Expire, node.Path, s.CurrentIndex, node.CreatedIndex)
-		e.EtcdIndex = s.CurrentIndex
-		e.PrevNode = node.Repr(false, false, s.clock)
-		if node.IsDir() {
-			e.Node.Dir = true
-		}
+func (s *store) ExpiredKeys(cutoff time.Time, limit int) []ExpiredKey {
+	s.worldLock.RLock()
+	defer s.worldLock.RUnlock()
 
-		callback := func(path string) { // notify function
-			// notify the watchers with deleted set true
-			s.WatcherHub.notifyWatchers(e, path, true)
-		}
+	nodes := s.ttlKeyHeap.expired(cutoff, limit)
+	keys := make([]ExpiredKey, len(nodes))
+	for i, n := range nodes {
+		keys[i] = ExpiredKey{Key: n.Path, ModifiedIndex: n.ModifiedIndex}
+	}
+	return keys
+}
 
-		s.ttlKeyHeap.pop()
-		node.Remove(true, true, callback)
+func (s *store) ExpireKeys(keys []ExpiredKey, cutoff time.Time) {
+	s.worldLock.Lock()
+	defer s.worldLock.Unlock()
+	defer s.publishReadSnapshot()
 
-		reportExpiredKey()
-		s.Stats.Inc(ExpireCount)
+	for _, k := range keys {
+		node, err := s.internalGet(k.Key)
+		if err != nil || node.IsPermanent() || node.ExpireTime.After(cutoff) || node.ModifiedIndex != k.ModifiedIndex {
+			// the node was deleted, refreshed or replaced since it was listed
+			continue
+		}
+		s.expire(node, cutoff)
+	}
+	s.ageTombstones(cutoff)
+	s.sweepExpiredHistory(cutoff)
+}
 
-		s.WatcherHub.notify(e)
+// expire deletes the node expired by the SYNC request at cutoff, and
+// notifies the watchers.
+func (s *store) expire(node *node, cutoff time.Time) {
+	s.CurrentIndex++
+	e := newEvent(Expire, node.Path, s.CurrentIndex, node.CreatedIndex)
+	e.EtcdIndex = s.CurrentIndex
+	e.PrevNode = node.Repr(false, false, s.clock)
+	if node.IsDir() {
+		e.Node.Dir = true
 	}
 
+	callback := func(path string) { // notify function
+		// notify the watchers with deleted set true
+		s.WatcherHub.notifyWatchers(e, path, true)
+	}
+
+	ts := s.tombstone(e, node)
+	node.Remove(true, true, callback)
+	s.touch(node.Path)
+	s.addTombstone(ts)
+	s.addExpiration(node, s.CurrentIndex, cutoff)
+
+	reportExpiredKey()
+	s.Stats.Inc(ExpireCount)
+
+	s.WatcherHub.notify(e)
 }
 
 // checkDir will check whether the component is a directory under parent node.
@@ -720,6 +1067,8 @@ func (s *store) checkDir(parent *node, dirName string) (*node, *v2error.Error) {
 	n := newDir(s, path.Join(parent.Path, dirName), s.CurrentIndex+1, parent, Permanent)
 
 	parent.Children[dirName] = n
+	s.addMemory(nodeMemory(n))
+	s.touch(n.Path)
 
 	return n, nil
 }
@@ -755,6 +1104,12 @@ func (s *store) Clone() Store {
 	clonedStore.WatcherHub = s.WatcherHub.clone()
 	clonedStore.Stats = s.Stats.clone()
 	clonedStore.CurrentVersion = s.CurrentVersion
+	clonedStore.Tombstones = append([]*Tombstone(nil), s.Tombstones...)
+	clonedStore.TombstoneWindow = s.TombstoneWindow
+	clonedStore.TombstonesSince = s.TombstonesSince
+	clonedStore.Expirations = append([]*keyExpiration(nil), s.Expirations...)
+	clonedStore.historyRetention = s.historyRetention
+	clonedStore.rebuildReadTree()
 
 	s.worldLock.Unlock()
 	return clonedStore
@@ -767,6 +1122,8 @@ func (s *store) Clone() Store {
 func (s *store) Recovery(state []byte) error {
 	s.worldLock.Lock()
 	defer s.worldLock.Unlock()
+	s.Tombstones, s.TombstoneWindow, s.TombstonesSince = nil, 0, 0
+	s.Expirations = nil
 	err := json.Unmarshal(state, s)
 
 	if err != nil {
@@ -776,6 +1133,7 @@ func (s *store) Recovery(state []byte) error {
 	s.ttlKeyHeap = newTtlKeyHeap()
 
 	s.Root.recoverAndclean()
+	s.rebuildReadTree()
 	return nil
 }
 
@@ -787,5 +1145,7 @@ func (s *store) JsonStats() []byte {
 func (s *store) HasTTLKeys() bool {
 	s.worldLock.RLock()
 	defer s.worldLock.RUnlock()
-	return s.ttlKeyHeap.Len() != 0
+	// the tombstones are aged, and the history of the expired keys pruned,
+	// by the SYNC requests as well
+	return s.ttlKeyHeap.Len() != 0 || len(s.Tombstones) != 0 || len(s.Expirations) != 0
 }
@@ -477,6 +477,45 @@ func TestStoreCompareAndDeleteDirectoryFail(t *testing.T) {
 	testutil.AssertEqual(t, err.ErrorCode, v2error.EcodeNotFile)
 }
 
+// Ensure that the store claims the keys of a queue in creation order.
+func TestStoreClaimNext(t *testing.T) {
+	s := newTestStore(t)
+	defer s.Close()
+
+	s.Create("/queue", true, "", false, v2store.TTLOptionSet{ExpireTime: v2store.Permanent})
+	s.Create("/queue", false, "a", true, v2store.TTLOptionSet{ExpireTime: v2store.Permanent})
+	s.Create("/queue", false, "b", true, v2store.TTLOptionSet{ExpireTime: v2store.Permanent})
+	for _, v := range []string{"a", "b"} {
+		e, err := s.ClaimNext("/queue")
+		testutil.AssertNil(t, err)
+		testutil.AssertEqual(t, e.Action, "claim")
+		testutil.AssertNotNil(t, e.PrevNode)
+		testutil.AssertEqual(t, *e.PrevNode.Value, v)
+		testutil.AssertEqual(t, e.Node.Key, e.PrevNode.Key)
+	}
+
+	// the queue is empty
+	_, err := s.ClaimNext("/queue")
+	testutil.AssertNotNil(t, err)
+	testutil.AssertEqual(t, err.(*v2error.Error).ErrorCode, v2error.EcodeKeyNotFound)
+
+	// the keys added and removed after a claim are kept in order
+	for _, v := range []string{"c", "d", "e"} {
+		s.Create("/queue", false, v, true, v2store.TTLOptionSet{ExpireTime: v2store.Permanent})
+	}
+	e, err := s.Get("/queue", false, true)
+	testutil.AssertNil(t, err)
+	_, err = s.Delete(e.Node.Nodes[1].Key, false, false)
+	testutil.AssertNil(t, err)
+	for _, v := range []string{"c", "e"} {
+		e, err := s.ClaimNext("/queue")
+		testutil.AssertNil(t, err)
+		testutil.AssertEqual(t, *e.PrevNode.Value, v)
+	}
+	_, err = s.ClaimNext("/queue")
+	testutil.AssertNotNil(t, err)
+}
+
 // Ensure that the store can conditionally update a key if it has a previous value.
 func TestStoreCompareAndSwapPrevValue(t *testing.T) {
 	s := newTestStore(t)
@@ -576,6 +615,49 @@ func TestStoreWatchCreate(t *testing.T) {
 	}
 }
 
+// Ensure that the store can wait for a key to exist.
+func TestStoreWatchExisting(t *testing.T) {
+	s := newTestStore(t)
+	defer s.Close()
+	var eidx uint64 = 0
+	w, err := s.WatchExisting("/foo")
+	testutil.AssertNil(t, err)
+	testutil.AssertEqual(t, w.StartIndex(), eidx)
+	s.Create("/foo", false, "bar", false, v2store.TTLOptionSet{ExpireTime: v2store.Permanent})
+	eidx = 1
+	e := timeoutSelect(t, w.EventChan())
+	testutil.AssertEqual(t, e.EtcdIndex, eidx)
+	testutil.AssertEqual(t, e.Action, "create")
+	testutil.AssertEqual(t, e.Node.Key, "/foo")
+
+	// an existing key is returned right away
+	s.Set("/foo", false, "baz", v2store.TTLOptionSet{ExpireTime: v2store.Permanent})
+	eidx = 2
+	w, err = s.WatchExisting("/foo")
+	testutil.AssertNil(t, err)
+	testutil.AssertEqual(t, w.StartIndex(), eidx)
+	e = timeoutSelect(t, w.EventChan())
+	testutil.AssertEqual(t, e.EtcdIndex, eidx)
+	testutil.AssertEqual(t, e.Action, "get")
+	testutil.AssertEqual(t, e.Node.Key, "/foo")
+	testutil.AssertEqual(t, *e.Node.Value, "baz")
+	testutil.AssertEqual(t, e.Node.ModifiedIndex, eidx)
+
+	// a key created as a directory by a write below it, even hidden
+	for _, tt := range []struct{ key, dir string }{{"/dir/sub/key", "/dir"}, {"/hdir/_key", "/hdir"}} {
+		w, err = s.WatchExisting(tt.dir)
+		testutil.AssertNil(t, err)
+		s.Create(tt.key, false, "bar", false, v2store.TTLOptionSet{ExpireTime: v2store.Permanent})
+		eidx++
+		e = timeoutSelect(t, w.EventChan())
+		testutil.AssertEqual(t, e.EtcdIndex, eidx)
+		testutil.AssertEqual(t, e.Action, "create")
+		testutil.AssertEqual(t, e.Node.Key, tt.dir)
+		testutil.AssertEqual(t, e.Node.Dir, true)
+		testutil.AssertEqual(t, e.Node.CreatedIndex, eidx)
+	}
+}
+
 // Ensure that the store can watch for recursive key creation.
 func TestStoreWatchRecursiveCreate(t *testing.T) {
 	s := newTestStore(t)
@@ -175,6 +175,43 @@ func TestStoreWatchExpire(t *testing.T) {
 	testutil.AssertEqual(t, e.Node.Dir, true)
 }
 
+// Ensure that the store expires the listed keys only, unless they changed
+// since they were listed.
+func TestStoreExpireKeys(t *testing.T) {
+	s := newStore()
+	fc := newFakeClock()
+	s.clock = fc
+
+	s.Create("/foo", false, "bar", false, TTLOptionSet{ExpireTime: fc.Now().Add(500 * time.Millisecond)})
+	s.Create("/foodir", true, "", false, TTLOptionSet{ExpireTime: fc.Now().Add(400 * time.Millisecond)})
+	s.Create("/refreshed", false, "bar", false, TTLOptionSet{ExpireTime: fc.Now().Add(300 * time.Millisecond)})
+	s.Create("/later", false, "bar", false, TTLOptionSet{ExpireTime: fc.Now().Add(time.Hour)})
+	s.Create("/permanent", false, "bar", false, TTLOptionSet{ExpireTime: Permanent})
+
+	fc.Advance(600 * time.Millisecond)
+	keys := s.ExpiredKeys(fc.Now(), 0)
+	wkeys := []ExpiredKey{{"/refreshed", 3}, {"/foodir", 2}, {"/foo", 1}}
+	testutil.AssertEqual(t, keys, wkeys)
+	testutil.AssertEqual(t, s.ExpiredKeys(fc.Now(), 2), wkeys[:2])
+
+	s.Update("/refreshed", "", TTLOptionSet{ExpireTime: fc.Now().Add(time.Hour), Refresh: true})
+	w, _ := s.Watch("/", true, true, 0)
+	s.ExpireKeys(keys, fc.Now())
+	for _, k := range []string{"/foodir", "/foo"} {
+		e := nbselect(w.EventChan())
+		testutil.AssertEqual(t, e.Action, "expire")
+		testutil.AssertEqual(t, e.Node.Key, k)
+	}
+	testutil.AssertNil(t, nbselect(w.EventChan()))
+	_, err := s.Get("/refreshed", false, false)
+	testutil.AssertNil(t, err)
+	testutil.AssertEqual(t, s.ttlKeyHeap.Len(), 2)
+
+	// keys are not expired twice
+	s.ExpireKeys(keys, fc.Now())
+	testutil.AssertNil(t, nbselect(w.EventChan()))
+}
+
 // Ensure that the store can watch for key expiration when refreshing.
 func TestStoreWatchExpireRefresh(t *testing.T) {
 	s := newStore()
@@ -19,6 +19,7 @@ package v2store_test
 import (
 	"testing"
 
+	"go.etcd.io/etcd/etcdserver/api/v2error"
 	"go.etcd.io/etcd/etcdserver/api/v2store"
 	"go.etcd.io/etcd/pkg/testutil"
 )
@@ -63,3 +64,68 @@ func TestStoreRecover(t *testing.T) {
 	testutil.AssertNil(t, err)
 	testutil.AssertEqual(t, *e.Node.Value, "baz")
 }
+
+// Ensure that the store counts the keys under a directory up to the limit.
+func TestStoreCountKeys(t *testing.T) {
+	s := newTestStore(t)
+	defer s.Close()
+	s.Create("/foo", true, "", false, v2store.TTLOptionSet{ExpireTime: v2store.Permanent})
+	s.Create("/foo/x", false, "bar", false, v2store.TTLOptionSet{ExpireTime: v2store.Permanent})
+	s.Create("/foo/y", true, "", false, v2store.TTLOptionSet{ExpireTime: v2store.Permanent})
+	s.Create("/foo/y/a", false, "baz", false, v2store.TTLOptionSet{ExpireTime: v2store.Permanent})
+	s.Create("/foo/y/b", false, "baz", false, v2store.TTLOptionSet{ExpireTime: v2store.Permanent})
+
+	testutil.AssertEqual(t, s.CountKeys("/foo", 0), 3)
+	testutil.AssertEqual(t, s.CountKeys("/foo/y", 0), 2)
+	testutil.AssertEqual(t, s.CountKeys("/foo/x", 0), 1)
+	testutil.AssertEqual(t, s.CountKeys("/bar", 0), 0)
+	// counting stops once the limit is exceeded
+	testutil.AssertEqual(t, s.CountKeys("/foo", 1), 2)
+}
+
+// Ensure that the memory estimate of the store follows its nodes.
+func TestStoreEstimateMemory(t *testing.T) {
+	s := v2store.New()
+	empty := v2store.EstimateMemory(s)
+	testutil.AssertTrue(t, empty > 0)
+
+	s.Create("/foo", false, string(make([]byte, 1000)), false, v2store.TTLOptionSet{ExpireTime: v2store.Permanent})
+	size := v2store.EstimateMemory(s)
+	testutil.AssertTrue(t, size >= empty+1000)
+
+	s.Update("/foo", "bar", v2store.TTLOptionSet{ExpireTime: v2store.Permanent})
+	testutil.AssertEqual(t, v2store.EstimateMemory(s), size-997)
+
+	s.Delete("/foo", false, false)
+	testutil.AssertEqual(t, v2store.EstimateMemory(s), empty)
+
+	s.Create("/dir/x", false, "bar", false, v2store.TTLOptionSet{ExpireTime: v2store.Permanent})
+	s.Create("/dir/y", false, "baz", false, v2store.TTLOptionSet{ExpireTime: v2store.Permanent})
+	size = v2store.EstimateMemory(s)
+	b, _ := s.Save()
+	s2 := v2store.New()
+	testutil.AssertNil(t, s2.Recovery(b))
+	testutil.AssertEqual(t, v2store.EstimateMemory(s2), size)
+
+	s.Delete("/dir", true, true)
+	testutil.AssertEqual(t, v2store.EstimateMemory(s), empty)
+}
+
+// Ensure that the store gets several nodes at the same index.
+func TestStoreGetMulti(t *testing.T) {
+	s := newTestStore(t)
+	defer s.Close()
+	s.Create("/foo", false, "bar", false, v2store.TTLOptionSet{ExpireTime: v2store.Permanent})
+	s.Create("/dir/x", false, "baz", false, v2store.TTLOptionSet{ExpireTime: v2store.Permanent})
+
+	evs, errs := s.GetMulti([]string{"/foo", "/nope", "/dir"}, false)
+	testutil.AssertEqual(t, len(evs), 3)
+	testutil.AssertNil(t, errs[0])
+	testutil.AssertEqual(t, *evs[0].Node.Value, "bar")
+	testutil.AssertEqual(t, evs[0].EtcdIndex, uint64(2))
+	testutil.AssertNil(t, evs[1])
+	testutil.AssertEqual(t, errs[1].(*v2error.Error).ErrorCode, v2error.EcodeKeyNotFound)
+	testutil.AssertNil(t, errs[2])
+	testutil.AssertTrue(t, evs[2].Node.Dir)
+	testutil.AssertEqual(t, len(evs[2].Node.Nodes), 1)
+}
@@ -0,0 +1,307 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v2store
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"go.etcd.io/etcd/etcdserver/api/v2error"
+)
+
+// maxTombstoneBytes is the size of the keys and values of a deleted node,
+// children included, above which its tombstone does not keep the node.
+const maxTombstoneBytes = 1 << 20
+
+// Tombstone records the deletion of a node, retained for the tombstone
+// retention window of the store.
+type Tombstone struct {
+	// Event is the event deleting the node.
+	Event *Event `json:"event"`
+	// Node is the deleted node, with its children but without the hidden
+	// ones. If the node was larger than maxTombstoneBytes, it is kept
+	// without its value and children, and Truncated is set.
+	Node      *NodeExtern `json:"node"`
+	Truncated bool        `json:"truncated,omitempty"`
+	// DeletedAt is the time of the first SYNC request applied after the
+	// deletion, from which the retention window starts. It is zero until
+	// then.
+	DeletedAt time.Time `json:"deletedAt"`
+}
+
+// NewWithTombstones creates a store like New, which retains the deleted
+// nodes as tombstones for the given window after their deletion. A zero
+// retention retains no tombstones.
+//
+// The tombstones are aged by the SYNC requests, and are part of the state
+// of the store: the retention is changed by SetTombstoneRetention, when
+// applying a request.
+func NewWithTombstones(retention time.Duration, namespaces ...string) Store {
+	s := New(namespaces...).(*store)
+	s.TombstoneWindow = retention
+	return s
+}
+
+func (s *store) TombstoneRetention() time.Duration {
+	s.worldLock.RLock()
+	defer s.worldLock.RUnlock()
+	return s.TombstoneWindow
+}
+
+func (s *store) SetTombstoneRetention(retention time.Duration) {
+	s.worldLock.Lock()
+	defer s.worldLock.Unlock()
+	if s.TombstoneWindow == 0 && retention != 0 {
+		// the deletions up to now were not retained
+		s.TombstonesSince = s.CurrentIndex
+	}
+	s.TombstoneWindow = retention
+}
+
+// tombstone returns the tombstone of the deletion of n by e, or nil if the
+// store does not retain tombstones. It must be called before n is removed.
+func (s *store) tombstone(e *Event, n *node) *Tombstone {
+	if s.TombstoneWindow == 0 {
+		return nil
+	}
+	if nodeSize(n, maxTombstoneBytes) > maxTombstoneBytes {
+		en := n.Repr(false, false, s.clock)
+		en.Value, en.Nodes, en.Expiration, en.TTL = nil, nil, nil, 0
+		return &Tombstone{Event: e.Clone(), Node: en, Truncated: true}
+	}
+	en := n.Repr(true, true, s.clock)
+	// the TTLs depend on the local clock, and are not restored anyway
+	var clearTTL func(en *NodeExtern)
+	clearTTL = func(en *NodeExtern) {
+		en.Expiration, en.TTL = nil, 0
+		for _, child := range en.Nodes {
+			clearTTL(child)
+		}
+	}
+	clearTTL(en)
+	return &Tombstone{Event: e.Clone(), Node: en}
+}
+
+// nodeSize returns the size of the keys and values of n and its children,
+// counted until it exceeds max.
+func nodeSize(n *node, max int) int {
+	size := len(n.Path) + len(n.Value)
+	for _, child := range n.Children {
+		if size > max {
+			break
+		}
+		size += nodeSize(child, max-size)
+	}
+	return size
+}
+
+func (s *store) addTombstone(ts *Tombstone) {
+	if ts != nil {
+		s.Tombstones = append(s.Tombstones, ts)
+	}
+}
+
+// ageTombstones starts the retention window of the new tombstones at
+// cutoff, the time of a SYNC request, and drops the ones whose window
+// ended by then.
+func (s *store) ageTombstones(cutoff time.Time) {
+	for i := len(s.Tombstones) - 1; i >= 0 && s.Tombstones[i].DeletedAt.IsZero(); i-- {
+		s.Tombstones[i].DeletedAt = cutoff
+	}
+	// the time of the SYNC requests never goes backwards, so the
+	// tombstones are sorted by deletion time
+	expired := 0
+	for expired < len(s.Tombstones) && !s.Tombstones[expired].DeletedAt.Add(s.TombstoneWindow).After(cutoff) {
+		expired++
+	}
+	if expired > 0 {
+		if i := s.Tombstones[expired-1].Event.Index(); i > s.TombstonesSince {
+			s.TombstonesSince = i
+		}
+		s.Tombstones = append([]*Tombstone(nil), s.Tombstones[expired:]...)
+	}
+}
+
+// scanTombstones returns the first event at or after index that a watcher
+// of key would receive, if it is a retained deletion. It returns nil if it
+// is not, or if that cannot be told from the nodes and the tombstones, in
+// which case the watcher must be told index was cleared rather than miss
+// the events before the deletion.
+func (s *store) scanTombstones(key string, recursive bool, index uint64) *Event {
+	if s.TombstoneWindow == 0 || index <= s.TombstonesSince {
+		return nil
+	}
+	var matched []*Tombstone
+	for _, ts := range s.Tombstones {
+		if ts.Event.Index() >= index && ts.Event.matches(key, recursive) {
+			if ts.Truncated {
+				return nil
+			}
+			matched = append(matched, ts)
+		}
+	}
+	if len(matched) == 0 {
+		return nil
+	}
+	first := matched[0].Event.Index()
+
+	// a watched node modified since index was modified by an event before
+	// the deletion, unless it was created after it
+	prefix := strings.TrimSuffix(key, "/") + "/"
+	watched := func(p string) bool {
+		return p == key || (recursive && strings.HasPrefix(p, prefix))
+	}
+	modifiedBefore := func(modified, created uint64) bool {
+		return modified >= index && created < first
+	}
+	var deletedBefore func(en *NodeExtern) bool
+	deletedBefore = func(en *NodeExtern) bool {
+		if watched(en.Key) && modifiedBefore(en.ModifiedIndex, en.CreatedIndex) {
+			return true
+		}
+		for _, child := range en.Nodes {
+			if deletedBefore(child) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, ts := range matched {
+		if deletedBefore(ts.Node) {
+			return nil
+		}
+	}
+	var liveBefore func(n *node) bool
+	liveBefore = func(n *node) bool {
+		if modifiedBefore(n.ModifiedIndex, n.CreatedIndex) {
+			return true
+		}
+		if recursive {
+			for _, child := range n.Children {
+				if liveBefore(child) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	if n, err := s.internalGet(key); err == nil && liveBefore(n) {
+		return nil
+	}
+	return matched[0].Event
+}
+
+// ListTombstones returns the retained tombstones of the nodes at or under
+// nodePath, by deletion index.
+func (s *store) ListTombstones(nodePath string) []Tombstone {
+	s.worldLock.RLock()
+	defer s.worldLock.RUnlock()
+
+	nodePath = path.Clean(path.Join("/", nodePath))
+	prefix := strings.TrimSuffix(nodePath, "/") + "/"
+	tss := []Tombstone{}
+	for _, ts := range s.Tombstones {
+		if ts.Node.Key == nodePath || strings.HasPrefix(ts.Node.Key, prefix) {
+			tss = append(tss, *ts)
+		}
+	}
+	return tss
+}
+
+// Undelete recreates the node at nodePath deleted at index, with its
+// children, as found in its tombstone. The recreated nodes are permanent.
+// If index is 0, the last deletion of the node is undone.
+func (s *store) Undelete(nodePath string, index uint64) (*Event, error) {
+	var err *v2error.Error
+
+	s.worldLock.Lock()
+	defer s.worldLock.Unlock()
+	defer s.publishReadSnapshot()
+
+	defer func() {
+		if err == nil {
+			s.Stats.Inc(CreateSuccess)
+			reportWriteSuccess(Create)
+			return
+		}
+
+		s.Stats.Inc(CreateFail)
+		reportWriteFailure(Create)
+	}()
+
+	nodePath = path.Clean(path.Join("/", nodePath))
+	if s.readonlySet.Contains(nodePath) {
+		err = v2error.NewError(v2error.EcodeRootROnly, "/", s.CurrentIndex)
+		return nil, err
+	}
+
+	var ts *Tombstone
+	for _, t := range s.Tombstones {
+		if t.Node.Key == nodePath && (index == 0 || t.Event.Index() == index) {
+			ts = t
+		}
+	}
+	if ts == nil {
+		err = v2error.NewError(v2error.EcodeKeyNotFound, fmt.Sprintf("no tombstone of %s [%d]", nodePath, index), s.CurrentIndex)
+		return nil, err
+	}
+	if ts.Truncated {
+		err = v2error.NewError(v2error.EcodeTooManyKeys, fmt.Sprintf("tombstone of %s [%d] too large to undelete", nodePath, ts.Event.Index()), s.CurrentIndex)
+		return nil, err
+	}
+
+	dirName, nodeName := path.Split(nodePath)
+	d, err := s.walk(dirName, s.checkDir)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := d.Children[nodeName]; ok {
+		err = v2error.NewError(v2error.EcodeNodeExist, nodePath, s.CurrentIndex)
+		return nil, err
+	}
+
+	s.CurrentIndex++
+	var restore func(parent *node, en *NodeExtern) *node
+	restore = func(parent *node, en *NodeExtern) *node {
+		var n *node
+		if en.Dir {
+			n = newDir(s, en.Key, s.CurrentIndex, parent, Permanent)
+			for _, child := range en.Nodes {
+				c := restore(n, child)
+				n.Children[path.Base(c.Path)] = c
+			}
+		} else {
+			n = newKV(s, en.Key, *en.Value, s.CurrentIndex, parent, Permanent)
+		}
+		s.addMemory(nodeMemory(n))
+		s.touch(n.Path)
+		return n
+	}
+	n := restore(d, ts.Node)
+	d.Children[nodeName] = n
+	if d.claims != nil {
+		d.claims.add(n)
+	}
+
+	e := newEvent(Create, nodePath, s.CurrentIndex, s.CurrentIndex)
+	e.EtcdIndex = s.CurrentIndex
+	e.Node.loadInternalNode(n, false, false, s.clock)
+
+	s.WatcherHub.notify(e)
+
+	return e, nil
+}
@@ -0,0 +1,255 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v2store
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"go.etcd.io/etcd/etcdserver/api/v2error"
+	"go.etcd.io/etcd/pkg/testutil"
+)
+
+// TestTombstoneWatch ensures watchers whose index was cleared from the
+// event history still receive the retained deletions.
+func TestTombstoneWatch(t *testing.T) {
+	for _, retention := range []time.Duration{0, time.Minute} {
+		s := newStore()
+		s.TombstoneWindow = retention
+		s.WatcherHub = newWatchHub(2)
+		perm := TTLOptionSet{ExpireTime: Permanent}
+		s.Create("/foo", false, "bar", false, perm)
+		s.Delete("/foo", false, false)
+		s.Create("/x", false, "", false, perm)
+		s.Create("/y", false, "", false, perm)
+
+		w, err := s.Watch("/foo", false, false, 2)
+		if retention == 0 {
+			if verr, ok := err.(*v2error.Error); !ok || verr.ErrorCode != v2error.EcodeEventIndexCleared {
+				t.Fatalf("err = %v, want index cleared", err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		e := nbselect(w.EventChan())
+		if e == nil || e.Action != Delete || e.Index() != 2 || e.EtcdIndex != 4 {
+			t.Fatalf("event = %+v, want the deletion at 2", e)
+		}
+
+		// the creation at 1 comes before the deletion, and is cleared
+		if _, err = s.Watch("/foo", false, false, 1); err == nil {
+			t.Fatal("watch from the creation succeeded, want index cleared")
+		}
+		// the deletion is not retained for the streams, which would miss the
+		// other events since their index
+		if _, err = s.Watch("/foo", false, true, 2); err == nil {
+			t.Fatal("stream watch succeeded, want index cleared")
+		}
+		// nor for the keys without retained deletion
+		if _, err = s.Watch("/x", false, false, 1); err == nil {
+			t.Fatal("watch succeeded, want index cleared")
+		}
+	}
+}
+
+// TestTombstoneWatchRecursive ensures recursive watchers whose index was
+// cleared only receive a retained deletion if no other event under their
+// key may come before it.
+func TestTombstoneWatchRecursive(t *testing.T) {
+	s := newStore()
+	s.TombstoneWindow = time.Minute
+	s.WatcherHub = newWatchHub(1)
+	perm := TTLOptionSet{ExpireTime: Permanent}
+	s.Create("/dir/a", false, "", false, perm) // 1
+	s.Create("/dir/b", false, "", false, perm) // 2
+	s.Set("/dir/a", false, "v", perm)          // 3
+	s.Delete("/dir/b", false, false)           // 4
+	s.Create("/other", false, "", false, perm) // 5
+	s.Create("/dir/c", false, "", false, perm) // 6
+	s.Create("/last", false, "", false, perm)  // 7
+
+	// /dir/a was set at 3, before the deletion at 4
+	if _, err := s.Watch("/dir", true, false, 3); err == nil {
+		t.Fatal("watch succeeded, want index cleared")
+	}
+	// /dir/c was created after the deletion
+	w, err := s.Watch("/dir", true, false, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := nbselect(w.EventChan()); e == nil || e.Action != Delete || e.Index() != 4 {
+		t.Fatalf("event = %+v, want the deletion at 4", e)
+	}
+
+	// the deletions dropped from the tombstones may be missed
+	s.Tombstones = nil
+	s.TombstonesSince = 4
+	if _, err = s.Watch("/dir", true, false, 4); err == nil {
+		t.Fatal("watch succeeded, want index cleared")
+	}
+}
+
+// TestTombstoneRetentionChange ensures the deletions are only retained
+// while the retention is set, and the watchers are not served from the
+// deletions that were not retained.
+func TestTombstoneRetentionChange(t *testing.T) {
+	s := newStore()
+	s.WatcherHub = newWatchHub(1)
+	perm := TTLOptionSet{ExpireTime: Permanent}
+	s.Create("/foo", false, "", false, perm)
+	s.Delete("/foo", false, false)
+	s.SetTombstoneRetention(time.Minute)
+	testutil.AssertEqual(t, time.Minute, s.TombstoneRetention())
+	testutil.AssertEqual(t, uint64(2), s.TombstonesSince)
+	s.Create("/bar", false, "", false, perm)
+	s.Delete("/bar", false, false)
+	s.Create("/x", false, "", false, perm)
+
+	testutil.AssertEqual(t, 1, len(s.ListTombstones("/")))
+	if _, err := s.Watch("/foo", false, false, 2); err == nil {
+		t.Fatal("watch succeeded, want index cleared")
+	}
+	if _, err := s.Watch("/bar", false, false, 4); err != nil {
+		t.Fatal(err)
+	}
+
+	// the retention is part of the snapshots
+	b, err := s.Save()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s2 := newStore()
+	if err = s2.Recovery(b); err != nil {
+		t.Fatal(err)
+	}
+	testutil.AssertEqual(t, time.Minute, s2.TombstoneRetention())
+	testutil.AssertEqual(t, uint64(2), s2.TombstonesSince)
+}
+
+// TestTombstoneTruncated ensures the large deleted nodes are not kept in
+// their tombstones, and cannot be undeleted.
+func TestTombstoneTruncated(t *testing.T) {
+	s := newStore()
+	s.TombstoneWindow = time.Minute
+	perm := TTLOptionSet{ExpireTime: Permanent}
+	s.Create("/dir/a", false, strings.Repeat("x", maxTombstoneBytes/2), false, perm)
+	s.Create("/dir/b", false, strings.Repeat("x", maxTombstoneBytes/2), false, perm)
+	s.Delete("/dir", true, true)
+	s.Create("/small", false, "v", false, perm)
+	s.Delete("/small", false, false)
+
+	tss := s.ListTombstones("/")
+	testutil.AssertEqual(t, 2, len(tss))
+	testutil.AssertEqual(t, true, tss[0].Truncated)
+	testutil.AssertEqual(t, 0, len(tss[0].Node.Nodes))
+	testutil.AssertEqual(t, false, tss[1].Truncated)
+
+	_, err := s.Undelete("/dir", 0)
+	testutil.AssertEqual(t, v2error.EcodeTooManyKeys, err.(*v2error.Error).ErrorCode)
+	if _, err = s.Undelete("/small", 0); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestTombstoneRetention ensures the tombstones are dropped by the SYNC
+// requests after the retention window.
+func TestTombstoneRetention(t *testing.T) {
+	s := newStore()
+	s.TombstoneWindow = time.Minute
+	fc := newFakeClock()
+	s.clock = fc
+	t0 := fc.Now()
+
+	s.Create("/foo", false, "bar", false, TTLOptionSet{ExpireTime: Permanent})
+	s.Create("/ttl", false, "bar", false, TTLOptionSet{ExpireTime: t0.Add(time.Second)})
+	s.Delete("/foo", false, false)
+	testutil.AssertEqual(t, 1, len(s.ListTombstones("/")))
+
+	// the expired keys are retained as well
+	s.DeleteExpiredKeys(t0.Add(2 * time.Second))
+	tss := s.ListTombstones("/")
+	testutil.AssertEqual(t, 2, len(tss))
+	testutil.AssertEqual(t, Expire, tss[1].Event.Action)
+	testutil.AssertEqual(t, t0.Add(2*time.Second), tss[1].DeletedAt)
+	testutil.AssertEqual(t, true, s.HasTTLKeys())
+
+	s.DeleteExpiredKeys(t0.Add(61 * time.Second))
+	testutil.AssertEqual(t, 2, len(s.ListTombstones("/")))
+	s.DeleteExpiredKeys(t0.Add(62 * time.Second))
+	testutil.AssertEqual(t, 0, len(s.ListTombstones("/")))
+	testutil.AssertEqual(t, false, s.HasTTLKeys())
+}
+
+func TestUndelete(t *testing.T) {
+	s := newStore()
+	s.TombstoneWindow = time.Minute
+	perm := TTLOptionSet{ExpireTime: Permanent}
+	s.Create("/dir/a", false, "1", false, perm)
+	s.Create("/dir/b/c", false, "2", false, perm)
+	s.Create("/dir/_hidden", false, "h", false, perm)
+	s.Delete("/dir", true, true)
+	s.Create("/foo", false, "v1", false, perm)
+	s.Delete("/foo", false, false)
+	s.Create("/foo", false, "v2", false, perm)
+	s.Delete("/foo", false, false)
+
+	e, err := s.Undelete("/dir", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testutil.AssertEqual(t, Create, e.Action)
+	testutil.AssertEqual(t, uint64(9), e.Index())
+	got, err := s.Get("/dir", true, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testutil.AssertEqual(t, 2, len(got.Node.Nodes))
+	testutil.AssertEqual(t, "/dir/a", got.Node.Nodes[0].Key)
+	testutil.AssertEqual(t, "2", *got.Node.Nodes[1].Nodes[0].Value)
+	if _, err = s.Get("/dir/_hidden", false, false); err == nil {
+		t.Fatal("hidden node undeleted")
+	}
+
+	// the node exists
+	_, err = s.Undelete("/dir", 0)
+	testutil.AssertEqual(t, v2error.EcodeNodeExist, err.(*v2error.Error).ErrorCode)
+
+	// the first deletion of /foo was at index 6
+	if _, err = s.Undelete("/foo", 6); err != nil {
+		t.Fatal(err)
+	}
+	got, _ = s.Get("/foo", false, false)
+	testutil.AssertEqual(t, "v1", *got.Node.Value)
+
+	_, err = s.Undelete("/foo", 7)
+	testutil.AssertEqual(t, v2error.EcodeKeyNotFound, err.(*v2error.Error).ErrorCode)
+
+	// the tombstones are part of the snapshots
+	b, err := s.Save()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s2 := newStore()
+	if err = s2.Recovery(b); err != nil {
+		t.Fatal(err)
+	}
+	wb, _ := json.Marshal(s.ListTombstones("/"))
+	gb, _ := json.Marshal(s2.ListTombstones("/"))
+	testutil.AssertEqual(t, string(wb), string(gb))
+}
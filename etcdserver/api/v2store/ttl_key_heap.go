@@ -14,7 +14,10 @@
 
 package v2store
 
-import "container/heap"
+import (
+	"container/heap"
+	"time"
+)
 
 // An TTLKeyHeap is a min-heap of TTLKeys order by expiration time
 type ttlKeyHeap struct {
@@ -95,3 +98,52 @@ func (h *ttlKeyHeap) remove(n *node) {
 		heap.Remove(h, index)
 	}
 }
+
+// expired returns, by expiration time, at most limit of the nodes expiring
+// at or before cutoff, or all of them if limit is not positive. The heap
+// is not modified: the nodes are visited from the root, each node being
+// reached after its parent, so only the expired nodes and their children
+// are looked at.
+func (h *ttlKeyHeap) expired(cutoff time.Time, limit int) []*node {
+	var nodes []*node
+	next := &nodeIndexHeap{h: h}
+	if h.Len() != 0 {
+		next.idx = append(next.idx, 0)
+	}
+	for next.Len() != 0 && (limit <= 0 || len(nodes) < limit) {
+		i := heap.Pop(next).(int)
+		n := h.array[i]
+		if n.ExpireTime.After(cutoff) {
+			// neither do its children
+			continue
+		}
+		nodes = append(nodes, n)
+		for _, c := range []int{2*i + 1, 2*i + 2} {
+			if c < h.Len() {
+				heap.Push(next, c)
+			}
+		}
+	}
+	return nodes
+}
+
+// nodeIndexHeap is a min-heap of the indexes of the nodes of a ttlKeyHeap,
+// ordered by the expiration time of the nodes.
+type nodeIndexHeap struct {
+	h   *ttlKeyHeap
+	idx []int
+}
+
+func (n nodeIndexHeap) Len() int { return len(n.idx) }
+
+func (n nodeIndexHeap) Less(i, j int) bool { return n.h.Less(n.idx[i], n.idx[j]) }
+
+func (n nodeIndexHeap) Swap(i, j int) { n.idx[i], n.idx[j] = n.idx[j], n.idx[i] }
+
+func (n *nodeIndexHeap) Push(x interface{}) { n.idx = append(n.idx, x.(int)) }
+
+func (n *nodeIndexHeap) Pop() interface{} {
+	x := n.idx[len(n.idx)-1]
+	n.idx = n.idx[:len(n.idx)-1]
+	return x
+}
@@ -29,6 +29,22 @@ type watcher struct {
 	hub        *watcherHub
 	removed    bool
 	remove     func()
+
+	// existing is true for the watchers of WatchExisting, which are also
+	// notified when their key is created as a directory by an event below.
+	existing bool
+
+	// queue holds, in order, the events that did not fit in eventChan,
+	// delivered by the delivery workers of the hub; it is protected by the
+	// deliveryMu of the hub, as are the fields below.
+	queue []*Event
+	// scheduled is true while the watcher is in the ready list of the hub.
+	scheduled bool
+	// inflight is closed once a worker is done sending the head of queue.
+	inflight chan struct{}
+	// stopped is true once the watcher must not receive events anymore.
+	stopped bool
+	stopc   chan struct{}
 }
 
 func (w *watcher) EventChan() chan *Event {
@@ -61,12 +77,9 @@ func (w *watcher) notify(e *Event, originalPath bool, deleted bool) bool {
 		// We cannot block here if the eventChan capacity is full, otherwise
 		// etcd will hang. eventChan capacity is full when the rate of
 		// notifications are higher than our send rate.
-		// If this happens, we close the channel.
-		select {
-		case w.eventChan <- e:
-		default:
-			// We have missed a notification. Remove the watcher.
-			// Removing the watcher also closes the eventChan.
+		// If this happens, the event is queued for the delivery workers,
+		// and the watcher is removed once its queue is full.
+		if !w.hub.enqueue(w, e) {
 			w.remove()
 		}
 		return true
@@ -80,6 +93,7 @@ func (w *watcher) Remove() {
 	w.hub.mutex.Lock()
 	defer w.hub.mutex.Unlock()
 
+	w.hub.stopDelivery(w)
 	close(w.eventChan)
 	if w.remove != nil {
 		w.remove()
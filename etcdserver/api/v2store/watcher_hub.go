@@ -20,10 +20,23 @@ import (
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"go.etcd.io/etcd/etcdserver/api/v2error"
 )
 
+const (
+	// watcherQueueLimit is the number of events a watcher may have queued,
+	// beyond the capacity of its event channel, before it is removed.
+	watcherQueueLimit = 1000
+	// maxDeliveryWorkers is the maximum number of goroutines delivering
+	// the queued events of the watchers.
+	maxDeliveryWorkers = 8
+	// deliveryTimeout is how long a worker waits for a watcher to receive
+	// an event before serving the next watcher.
+	deliveryTimeout = 100 * time.Millisecond
+)
+
 // A watcherHub contains all subscribed watchers
 // watchers is a map with watched path as key and watcher as value
 // EventHistory keeps the old events for watcherHub. It is used to help
@@ -39,6 +52,16 @@ type watcherHub struct {
 	mutex        sync.Mutex
 	watchers     map[string]*list.List
 	EventHistory *EventHistory
+	// maxPerKey, if not zero, is the most number of watchers of a key.
+	maxPerKey int
+
+	// deliveryMu protects the watchers queues and the fields below.
+	deliveryMu sync.Mutex
+	// ready lists the watchers with queued events. Workers serve them in
+	// turn, one event at a time, so that slow watchers share the workers
+	// fairly and never delay the notification of the others.
+	ready   *list.List
+	workers int
 }
 
 // newWatchHub creates a watcherHub. The capacity determines how many events we will
@@ -52,6 +75,18 @@ func newWatchHub(capacity int) *watcherHub {
 	}
 }
 
+// SetMaxWatchersPerKey limits the number of watchers of every key of st to
+// max, or lifts the limit if max is zero. The watches beyond the limit fail
+// with v2error.EcodeKeyWatchers; the watches answered from the event
+// history right away are not limited.
+func SetMaxWatchersPerKey(st Store, max int) {
+	if s, ok := st.(*store); ok {
+		s.WatcherHub.mutex.Lock()
+		s.WatcherHub.maxPerKey = max
+		s.WatcherHub.mutex.Unlock()
+	}
+}
+
 // Watch function returns a Watcher.
 // If recursive is true, the first change after index under key will be sent to the event channel of the watcher.
 // If recursive is false, the first change after index at key will be sent to the event channel of the watcher.
@@ -72,6 +107,7 @@ func (wh *watcherHub) watch(key string, recursive, stream bool, index, storeInde
 		sinceIndex: index,
 		startIndex: storeIndex,
 		hub:        wh,
+		stopc:      make(chan struct{}),
 	}
 
 	wh.mutex.Lock()
@@ -85,6 +121,9 @@ func (wh *watcherHub) watch(key string, recursive, stream bool, index, storeInde
 	}
 
 	l, ok := wh.watchers[key]
+	if ok && wh.maxPerKey > 0 && l.Len() >= wh.maxPerKey {
+		return nil, v2error.NewError(v2error.EcodeKeyWatchers, key, storeIndex)
+	}
 
 	var elem *list.Element
 
@@ -115,6 +154,41 @@ func (wh *watcherHub) watch(key string, recursive, stream bool, index, storeInde
 	return w, nil
 }
 
+// watchEvent returns a watcher which is not registered, and only receives e.
+// watchExisting adds a watcher for the missing node at key, notified by
+// the first event creating it, either explicitly or as a directory.
+func (wh *watcherHub) watchExisting(key string, storeIndex uint64) (Watcher, *v2error.Error) {
+	w, err := wh.watch(key, false, false, storeIndex+1, storeIndex)
+	if err != nil {
+		return nil, err
+	}
+	wh.mutex.Lock()
+	w.(*watcher).existing = true
+	wh.mutex.Unlock()
+	return w, nil
+}
+
+// implicitDirEvent returns the creation of the directory at dirPath by
+// the event e on a key below it.
+func implicitDirEvent(e *Event, dirPath string) *Event {
+	ne := newEvent(Create, dirPath, e.Index(), e.Index())
+	ne.Node.Dir = true
+	ne.EtcdIndex = e.EtcdIndex
+	return ne
+}
+
+func (wh *watcherHub) watchEvent(e *Event, storeIndex uint64) Watcher {
+	reportWatchRequest()
+	w := &watcher{
+		eventChan:  make(chan *Event, 1),
+		startIndex: storeIndex,
+		hub:        wh,
+		stopc:      make(chan struct{}),
+	}
+	w.eventChan <- e
+	return w
+}
+
 func (wh *watcherHub) add(e *Event) {
 	wh.EventHistory.addEvent(e)
 }
@@ -151,8 +225,13 @@ func (wh *watcherHub) notifyWatchers(e *Event, nodePath string, deleted bool) {
 
 			w, _ := curr.Value.(*watcher)
 
-			originalPath := e.Node.Key == nodePath
-			if (originalPath || !isHidden(nodePath, e.Node.Key)) && w.notify(e, originalPath, deleted) {
+			ev, originalPath := e, e.Node.Key == nodePath
+			if w.existing && !originalPath && !deleted {
+				// the key of the watcher did not exist, so the event
+				// created it as a directory on its way
+				ev, originalPath = implicitDirEvent(e, nodePath), true
+			}
+			if (originalPath || !isHidden(nodePath, e.Node.Key)) && w.notify(ev, originalPath, deleted) {
 				if !w.stream { // do not remove the stream watcher
 					// if we successfully notify a watcher
 					// we need to remove the watcher from the list
@@ -175,6 +254,118 @@ func (wh *watcherHub) notifyWatchers(e *Event, nodePath string, deleted bool) {
 	}
 }
 
+// enqueue sends e to w without blocking, queueing it for the delivery
+// workers if the event channel of w is full or if w already has queued
+// events. It returns false if the queue of w is full, in which case
+// the queued events are dropped and w is stopped.
+func (wh *watcherHub) enqueue(w *watcher, e *Event) bool {
+	wh.deliveryMu.Lock()
+	defer wh.deliveryMu.Unlock()
+
+	if w.stopped {
+		return false
+	}
+	if len(w.queue) == 0 {
+		select {
+		case w.eventChan <- e:
+			return true
+		default:
+		}
+	}
+	if len(w.queue) >= watcherQueueLimit {
+		wh.stopLocked(w)
+		reportWatcherDropped()
+		return false
+	}
+	w.queue = append(w.queue, e)
+	reportWatchQueueDepth(1)
+	if !w.scheduled {
+		w.scheduled = true
+		if wh.ready == nil {
+			wh.ready = list.New()
+		}
+		wh.ready.PushBack(w)
+		if wh.workers < maxDeliveryWorkers {
+			wh.workers++
+			go wh.deliver()
+		}
+	}
+	return true
+}
+
+// deliver sends the queued events to the ready watchers, one event per
+// watcher in turn, until no watcher has queued events.
+func (wh *watcherHub) deliver() {
+	for {
+		wh.deliveryMu.Lock()
+		front := wh.ready.Front()
+		if front == nil {
+			wh.workers--
+			wh.deliveryMu.Unlock()
+			return
+		}
+		w := wh.ready.Remove(front).(*watcher)
+		if w.stopped || len(w.queue) == 0 {
+			w.scheduled = false
+			wh.deliveryMu.Unlock()
+			continue
+		}
+		e := w.queue[0]
+		inflight := make(chan struct{})
+		w.inflight = inflight
+		wh.deliveryMu.Unlock()
+
+		sent := false
+		t := time.NewTimer(deliveryTimeout)
+		select {
+		case w.eventChan <- e:
+			sent = true
+		case <-w.stopc:
+		case <-t.C:
+		}
+		t.Stop()
+
+		wh.deliveryMu.Lock()
+		w.inflight = nil
+		close(inflight)
+		if sent && !w.stopped {
+			w.queue[0] = nil
+			w.queue = w.queue[1:]
+			reportWatchQueueDepth(-1)
+		}
+		if !w.stopped && len(w.queue) > 0 {
+			// back of the line, behind the other ready watchers
+			wh.ready.PushBack(w)
+		} else {
+			w.scheduled = false
+		}
+		wh.deliveryMu.Unlock()
+	}
+}
+
+// stopDelivery drops the queued events of w, and waits for any worker
+// sending to w, so that its event channel can be closed.
+func (wh *watcherHub) stopDelivery(w *watcher) {
+	wh.deliveryMu.Lock()
+	wh.stopLocked(w)
+	inflight := w.inflight
+	wh.deliveryMu.Unlock()
+
+	if inflight != nil {
+		<-inflight
+	}
+}
+
+func (wh *watcherHub) stopLocked(w *watcher) {
+	if w.stopped {
+		return
+	}
+	w.stopped = true
+	close(w.stopc)
+	reportWatchQueueDepth(-len(w.queue))
+	w.queue = nil
+}
+
 // clone function clones the watcherHub and return the cloned one.
 // only clone the static content. do not clone the current watchers.
 func (wh *watcherHub) clone() *watcherHub {
@@ -14,7 +14,12 @@
 
 package v2store
 
-import "testing"
+import (
+	"testing"
+	"time"
+
+	"go.etcd.io/etcd/etcdserver/api/v2error"
+)
 
 // TestIsHidden tests isHidden functions.
 func TestIsHidden(t *testing.T) {
@@ -62,3 +67,86 @@ func TestIsHidden(t *testing.T) {
 		t.Fatalf("%v should not be hidden to %v\n", key, watch)
 	}
 }
+
+// TestWatcherHubSlowWatcher ensures a watcher whose event channel is full
+// does not delay the others, and receives its queued events in order.
+func TestWatcherHubSlowWatcher(t *testing.T) {
+	wh := newWatchHub(100)
+	slow, _ := wh.watch("/foo", true, true, 1, 1)
+	fast, _ := wh.watch("/foo", true, true, 1, 1)
+
+	n := cap(slow.EventChan()) + 50
+	for i := 1; i <= n; i++ {
+		wh.notify(newEvent(Create, "/foo", uint64(i), uint64(i)))
+		if e := <-fast.EventChan(); e.Index() != uint64(i) {
+			t.Fatalf("fast watcher got index %d, want %d", e.Index(), i)
+		}
+	}
+	for i := 1; i <= n; i++ {
+		select {
+		case e := <-slow.EventChan():
+			if e.Index() != uint64(i) {
+				t.Fatalf("slow watcher got index %d, want %d", e.Index(), i)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("slow watcher did not get event %d", i)
+		}
+	}
+	if wh.count != 2 {
+		t.Fatalf("watcher count = %d, want 2", wh.count)
+	}
+	slow.Remove()
+	fast.Remove()
+}
+
+// TestWatcherHubDropWatcher ensures a watcher is removed once its queue is
+// full, and that removing a watcher with queued events is safe.
+func TestWatcherHubDropWatcher(t *testing.T) {
+	wh := newWatchHub(100)
+	w, _ := wh.watch("/foo", true, true, 1, 1)
+	n := cap(w.EventChan()) + watcherQueueLimit
+	for i := 1; i <= n; i++ {
+		wh.notify(newEvent(Create, "/foo", uint64(i), uint64(i)))
+	}
+	if wh.count != 1 {
+		t.Fatalf("watcher count = %d, want 1", wh.count)
+	}
+	wh.notify(newEvent(Create, "/foo", uint64(n+1), uint64(n+1)))
+	if wh.count != 0 {
+		t.Fatalf("watcher count = %d, want 0", wh.count)
+	}
+
+	w, _ = wh.watch("/foo", true, true, uint64(n+2), uint64(n+1))
+	for i := n + 2; i <= n+cap(w.EventChan())+10; i++ {
+		wh.notify(newEvent(Create, "/foo", uint64(i), uint64(i)))
+	}
+	w.Remove()
+	for range w.EventChan() {
+	}
+}
+
+// TestWatcherHubMaxPerKey ensures the watchers of a key beyond the limit are
+// refused, and that a removed watcher frees its place.
+func TestWatcherHubMaxPerKey(t *testing.T) {
+	s := newStore()
+	SetMaxWatchersPerKey(s, 2)
+	w1, err := s.Watch("/foo", false, false, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.Watch("/foo", true, true, 0); err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Watch("/foo", false, false, 0)
+	if verr, ok := err.(*v2error.Error); !ok || verr.ErrorCode != v2error.EcodeKeyWatchers {
+		t.Fatalf("error = %v, want error code %d", err, v2error.EcodeKeyWatchers)
+	}
+	// the other keys have watchers of their own
+	if _, err = s.Watch("/bar", false, false, 0); err != nil {
+		t.Fatal(err)
+	}
+	w1.Remove()
+	if _, err = s.Watch("/foo", false, false, 0); err != nil {
+		t.Fatalf("watch after remove error = %v", err)
+	}
+}
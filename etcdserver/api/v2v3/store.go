@@ -522,6 +522,57 @@ func (s *v2v3Store) CompareAndDelete(nodePath, prevValue string, prevIndex uint6
 	}, nil
 }
 
+func (s *v2v3Store) ClaimNext(dirPath string) (*v2store.Event, error) {
+	for {
+		resp, err := s.c.Txn(s.ctx).Then(
+			clientv3.OpGet(s.mkPath(dirPath)+"/"),
+			clientv3.OpGet(s.mkPathDepth(dirPath, 1), clientv3.WithPrefix(),
+				clientv3.WithSort(clientv3.SortByCreateRevision, clientv3.SortAscend)),
+		).Commit()
+		if err != nil {
+			return nil, err
+		}
+		if !isRoot(dirPath) && len(resp.Responses[0].GetResponseRange().Kvs) == 0 {
+			return nil, v2error.NewError(v2error.EcodeKeyNotFound, dirPath, mkV2Rev(resp.Header.Revision))
+		}
+		var head *mvccpb.KeyValue
+		for _, kv := range resp.Responses[1].GetResponseRange().Kvs {
+			if kv.Key[len(kv.Key)-1] != '/' {
+				head = kv
+				break
+			}
+		}
+		if head == nil {
+			return nil, v2error.NewError(v2error.EcodeKeyNotFound, dirPath, mkV2Rev(resp.Header.Revision))
+		}
+
+		// another consumer may claim the head first; retry with the next one
+		dresp, err := s.c.Txn(s.ctx).If(
+			clientv3.Compare(clientv3.ModRevision(string(head.Key)), "=", head.ModRevision),
+		).Then(
+			clientv3.OpDelete(string(head.Key), clientv3.WithPrevKV()),
+			clientv3.OpPut(s.mkActionKey(), v2store.Claim),
+		).Commit()
+		if err != nil {
+			return nil, err
+		}
+		if !dresp.Succeeded {
+			continue
+		}
+		pkv := dresp.Responses[0].GetResponseDeleteRange().PrevKvs[0]
+		return &v2store.Event{
+			Action: v2store.Claim,
+			Node: &v2store.NodeExtern{
+				Key:           s.mkNodePath(string(pkv.Key)),
+				CreatedIndex:  mkV2Rev(pkv.CreateRevision),
+				ModifiedIndex: mkV2Rev(dresp.Header.Revision),
+			},
+			PrevNode:  s.mkV2Node(pkv),
+			EtcdIndex: mkV2Rev(dresp.Header.Revision),
+		}, nil
+	}
+}
+
 func compareFail(nodePath, prevValue string, prevIndex uint64, resp *clientv3.TxnResponse) error {
 	if dkvs := resp.Responses[1].GetResponseRange().Kvs; len(dkvs) > 0 {
 		return v2error.NewError(v2error.EcodeNotFile, nodePath, mkV2Rev(resp.Header.Revision))
@@ -560,6 +611,11 @@ func (s *v2v3Store) mkCompare(nodePath, prevValue string, prevIndex uint64) []cl
 func (s *v2v3Store) JsonStats() []byte                  { panic("STUB") }
 func (s *v2v3Store) DeleteExpiredKeys(cutoff time.Time) { panic("STUB") }
 
+func (s *v2v3Store) KeyspaceStats(string) *v2store.KeyspaceStats { panic("STUB") }
+
+func (s *v2v3Store) ExpiredKeys(time.Time, int) []v2store.ExpiredKey { panic("STUB") }
+func (s *v2v3Store) ExpireKeys([]v2store.ExpiredKey, time.Time)      { panic("STUB") }
+
 func (s *v2v3Store) Version() int { return 2 }
 
 // TODO: move this out of the Store interface?
@@ -569,6 +625,19 @@ func (s *v2v3Store) Recovery(state []byte) error { panic("STUB") }
 func (s *v2v3Store) Clone() v2store.Store        { panic("STUB") }
 func (s *v2v3Store) SaveNoCopy() ([]byte, error) { panic("STUB") }
 func (s *v2v3Store) HasTTLKeys() bool            { panic("STUB") }
+func (s *v2v3Store) CountKeys(string, int) int   { panic("STUB") }
+
+func (s *v2v3Store) GetMulti([]string, bool) ([]*v2store.Event, []error) { panic("STUB") }
+func (s *v2v3Store) Lookup(string) (*v2store.NodeExtern, error)          { panic("STUB") }
+
+func (s *v2v3Store) Export(string) (*v2store.KeyExport, error)       { panic("STUB") }
+func (s *v2v3Store) ListTombstones(string) []v2store.Tombstone       { panic("STUB") }
+func (s *v2v3Store) TombstoneRetention() time.Duration               { panic("STUB") }
+func (s *v2v3Store) SetTombstoneRetention(time.Duration)             { panic("STUB") }
+func (s *v2v3Store) ExpiredHistoryRetention() time.Duration          { panic("STUB") }
+func (s *v2v3Store) SetExpiredHistoryRetention(time.Duration)        { panic("STUB") }
+func (s *v2v3Store) Undelete(string, uint64) (*v2store.Event, error) { panic("STUB") }
+func (s *v2v3Store) Inspect(string) (*v2store.KeyInternals, error)   { panic("STUB") }
 
 func (s *v2v3Store) mkPath(nodePath string) string { return s.mkPathDepth(nodePath, 0) }
 
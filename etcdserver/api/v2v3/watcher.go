@@ -24,6 +24,12 @@ import (
 )
 
 func (s *v2v3Store) Watch(prefix string, recursive, stream bool, sinceIndex uint64) (v2store.Watcher, error) {
+	return s.watch(prefix, recursive, stream, sinceIndex, false)
+}
+
+// watch watches prefix like Watch. With existing, the key at prefix is
+// missing, and the first event below it creates it as a directory.
+func (s *v2v3Store) watch(prefix string, recursive, stream bool, sinceIndex uint64, existing bool) (v2store.Watcher, error) {
 	ctx, cancel := context.WithCancel(s.ctx)
 	wch := s.c.Watch(
 		ctx,
@@ -59,6 +65,19 @@ func (s *v2v3Store) Watch(prefix string, recursive, stream bool, sinceIndex uint
 						continue
 					}
 				}
+				if existing && strings.HasPrefix(k, prefix+"/") {
+					ev = &v2store.Event{
+						Action: v2store.Create,
+						Node: &v2store.NodeExtern{
+							Key:           prefix,
+							Dir:           true,
+							CreatedIndex:  ev.Node.ModifiedIndex,
+							ModifiedIndex: ev.Node.ModifiedIndex,
+						},
+						EtcdIndex: ev.EtcdIndex,
+					}
+					k = prefix
+				}
 				if !recursive && k != prefix {
 					continue
 				}
@@ -82,6 +101,29 @@ func (s *v2v3Store) Watch(prefix string, recursive, stream bool, sinceIndex uint
 	}, nil
 }
 
+func (s *v2v3Store) WatchExisting(key string) (v2store.Watcher, error) {
+	ev, err := s.Get(key, false, false)
+	if err == nil {
+		evc, donec := make(chan *v2store.Event, 1), make(chan struct{})
+		evc <- ev
+		close(evc)
+		close(donec)
+		return &v2v3Watcher{
+			startRev: mkV3Rev(ev.EtcdIndex),
+			evc:      evc,
+			donec:    donec,
+			cancel:   func() {},
+		}, nil
+	}
+	verr, ok := err.(*v2error.Error)
+	if !ok || verr.ErrorCode != v2error.EcodeKeyNotFound {
+		return nil, err
+	}
+	// the key did not exist at the revision of the get, so watching from
+	// the next one cannot miss its creation
+	return s.watch(key, false, false, uint64(mkV3Rev(verr.Index)+1), true)
+}
+
 func (s *v2v3Store) mkV2Events(wr clientv3.WatchResponse) (evs []*v2store.Event) {
 	ak := s.mkActionKey()
 	for _, rev := range mkRevs(wr) {
@@ -36,20 +36,36 @@ const (
 )
 
 func Server(s *etcdserver.EtcdServer, tls *tls.Config, gopts ...grpc.ServerOption) *grpc.Server {
+	return newServer(s, tls, false, gopts...)
+}
+
+// NonAdminServer is like Server, but refuses the admin requests, for the
+// listeners other than the admin listeners when those are configured.
+func NonAdminServer(s *etcdserver.EtcdServer, tls *tls.Config, gopts ...grpc.ServerOption) *grpc.Server {
+	return newServer(s, tls, true, gopts...)
+}
+
+func newServer(s *etcdserver.EtcdServer, tls *tls.Config, refuseAdmin bool, gopts ...grpc.ServerOption) *grpc.Server {
 	var opts []grpc.ServerOption
 	opts = append(opts, grpc.CustomCodec(&codec{}))
 	if tls != nil {
 		opts = append(opts, grpc.Creds(credentials.NewTLS(tls)))
 	}
-	opts = append(opts, grpc.UnaryInterceptor(grpc_middleware.ChainUnaryServer(
+	unary := []grpc.UnaryServerInterceptor{
 		newLogUnaryInterceptor(s),
 		newUnaryInterceptor(s),
-		grpc_prometheus.UnaryServerInterceptor,
-	)))
-	opts = append(opts, grpc.StreamInterceptor(grpc_middleware.ChainStreamServer(
+	}
+	stream := []grpc.StreamServerInterceptor{
 		newStreamInterceptor(s),
-		grpc_prometheus.StreamServerInterceptor,
-	)))
+	}
+	if refuseAdmin {
+		unary = append(unary, refuseAdminUnaryInterceptor)
+		stream = append(stream, refuseAdminStreamInterceptor)
+	}
+	unary = append(unary, grpc_prometheus.UnaryServerInterceptor)
+	stream = append(stream, grpc_prometheus.StreamServerInterceptor)
+	opts = append(opts, grpc.UnaryInterceptor(grpc_middleware.ChainUnaryServer(unary...)))
+	opts = append(opts, grpc.StreamInterceptor(grpc_middleware.ChainStreamServer(stream...)))
 	opts = append(opts, grpc.MaxRecvMsgSize(int(s.Cfg.MaxRequestBytes+grpcOverheadBytes)))
 	opts = append(opts, grpc.MaxSendMsgSize(maxSendBytes))
 	opts = append(opts, grpc.MaxConcurrentStreams(maxStreams))
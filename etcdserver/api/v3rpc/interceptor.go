@@ -16,6 +16,7 @@ package v3rpc
 
 import (
 	"context"
+	"strings"
 	"sync"
 	"time"
 
@@ -29,8 +30,10 @@ import (
 	pb "go.etcd.io/etcd/etcdserver/etcdserverpb"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
 )
 
 const (
@@ -48,6 +51,23 @@ func newUnaryInterceptor(s *etcdserver.EtcdServer) grpc.UnaryServerInterceptor {
 			return nil, rpctypes.ErrGRPCNotCapable
 		}
 
+		if s.IsWitness() && !witnessServes(info.FullMethod) {
+			return nil, rpctypes.ErrGRPCWitness
+		}
+
+		if s.IsLearner() && !learnerServes(info.FullMethod, req) {
+			return nil, rpctypes.ErrGRPCNotSupportedForLearner
+		}
+
+		if sheddable(info.FullMethod) && s.ShedClientRequest() {
+			grpc.SetHeader(ctx, metadata.Pairs(rpctypes.MetadataRetryAfterKey, "1"))
+			return nil, rpctypes.ErrGRPCRequestTooManyRequests
+		}
+
+		if s.Cfg.ClientIdentityHeaders {
+			grpc.SetHeader(ctx, clientIdentityMD(ctx, s))
+		}
+
 		md, ok := metadata.FromIncomingContext(ctx)
 		if ok {
 			if ks := md[rpctypes.MetadataRequireLeaderKey]; len(ks) > 0 && ks[0] == rpctypes.MetadataHasLeader {
@@ -57,6 +77,12 @@ func newUnaryInterceptor(s *etcdserver.EtcdServer) grpc.UnaryServerInterceptor {
 			}
 		}
 
+		if s.RecordsMutations() && isMutation(info.FullMethod) {
+			start := time.Now()
+			resp, err := handler(ctx, req)
+			recordMutation(s, info.FullMethod, req, start, resp, err)
+			return resp, err
+		}
 		return handler(ctx, req)
 	}
 }
@@ -68,13 +94,13 @@ func newLogUnaryInterceptor(s *etcdserver.EtcdServer) grpc.UnaryServerIntercepto
 		lg := s.Logger()
 		if (lg != nil && lg.Core().Enabled(zap.DebugLevel)) || // using zap logger and debug level is enabled
 			(lg == nil && plog.LevelAt(capnslog.DEBUG)) { // or, using capnslog and debug level is enabled
-			defer logUnaryRequestStats(ctx, lg, info, startTime, req, resp)
+			defer logUnaryRequestStats(ctx, lg, info, startTime, req, resp, clientIdentity(ctx, s))
 		}
 		return resp, err
 	}
 }
 
-func logUnaryRequestStats(ctx context.Context, lg *zap.Logger, info *grpc.UnaryServerInfo, startTime time.Time, req interface{}, resp interface{}) {
+func logUnaryRequestStats(ctx context.Context, lg *zap.Logger, info *grpc.UnaryServerInfo, startTime time.Time, req interface{}, resp interface{}, identity string) {
 	duration := time.Since(startTime)
 	remote := "No remote client info."
 	peerInfo, ok := peer.FromContext(ctx)
@@ -150,28 +176,30 @@ func logUnaryRequestStats(ctx context.Context, lg *zap.Logger, info *grpc.UnaryS
 		respSize = -1
 	}
 
-	logGenericRequestStats(lg, startTime, duration, remote, responseType, reqCount, reqSize, respCount, respSize, reqContent)
+	logGenericRequestStats(lg, startTime, duration, remote, identity, responseType, reqCount, reqSize, respCount, respSize, reqContent)
 }
 
-func logGenericRequestStats(lg *zap.Logger, startTime time.Time, duration time.Duration, remote string, responseType string,
+func logGenericRequestStats(lg *zap.Logger, startTime time.Time, duration time.Duration, remote string, identity string, responseType string,
 	reqCount int64, reqSize int, respCount int64, respSize int, reqContent string) {
 	if lg == nil {
 		plog.Debugf("start time = %v, "+
 			"time spent = %v, "+
 			"remote = %s, "+
+			"client identity = %s, "+
 			"response type = %s, "+
 			"request count = %d, "+
 			"request size = %d, "+
 			"response count = %d, "+
 			"response size = %d, "+
 			"request content = %s",
-			startTime, duration, remote, responseType, reqCount, reqSize, respCount, respSize, reqContent,
+			startTime, duration, remote, identity, responseType, reqCount, reqSize, respCount, respSize, reqContent,
 		)
 	} else {
 		lg.Debug("request stats",
 			zap.Time("start time", startTime),
 			zap.Duration("time spent", duration),
 			zap.String("remote", remote),
+			zap.String("client identity", identity),
 			zap.String("response type", responseType),
 			zap.Int64("request count", reqCount),
 			zap.Int("request size", reqSize),
@@ -190,6 +218,23 @@ func newStreamInterceptor(s *etcdserver.EtcdServer) grpc.StreamServerInterceptor
 			return rpctypes.ErrGRPCNotCapable
 		}
 
+		if s.IsWitness() && !witnessServes(info.FullMethod) {
+			return rpctypes.ErrGRPCWitness
+		}
+
+		if s.IsLearner() && !learnerServes(info.FullMethod, nil) {
+			return rpctypes.ErrGRPCNotSupportedForLearner
+		}
+
+		if sheddable(info.FullMethod) && s.ShedClientRequest() {
+			ss.SetHeader(metadata.Pairs(rpctypes.MetadataRetryAfterKey, "1"))
+			return rpctypes.ErrGRPCRequestTooManyRequests
+		}
+
+		if s.Cfg.ClientIdentityHeaders {
+			ss.SetHeader(clientIdentityMD(ss.Context(), s))
+		}
+
 		md, ok := metadata.FromIncomingContext(ss.Context())
 		if ok {
 			if ks := md[rpctypes.MetadataRequireLeaderKey]; len(ks) > 0 && ks[0] == rpctypes.MetadataHasLeader {
@@ -218,6 +263,186 @@ func newStreamInterceptor(s *etcdserver.EtcdServer) grpc.StreamServerInterceptor
 	}
 }
 
+// clientIdentity returns the user of the auth token of the request or,
+// without one, the common name of the verified TLS certificate of the
+// client. It returns an empty string for an anonymous client.
+func clientIdentity(ctx context.Context, s *etcdserver.EtcdServer) string {
+	if ai, err := s.AuthStore().AuthInfoFromCtx(ctx); err == nil && ai != nil {
+		return ai.Username
+	}
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.AuthInfo == nil {
+		return ""
+	}
+	if tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+		for _, chain := range tlsInfo.State.VerifiedChains {
+			if len(chain) > 0 && chain[0].Subject.CommonName != "" {
+				return chain[0].Subject.CommonName
+			}
+		}
+	}
+	return ""
+}
+
+// clientIdentityMD returns the response headers holding the identity and
+// the remote address of the client of the request.
+func clientIdentityMD(ctx context.Context, s *etcdserver.EtcdServer) metadata.MD {
+	md := metadata.MD{}
+	if id := clientIdentity(ctx, s); id != "" {
+		md.Set(rpctypes.MetadataClientIdentityKey, id)
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		md.Set(rpctypes.MetadataClientRemoteKey, p.Addr.String())
+	}
+	return md
+}
+
+// witnessServes returns true if a witness member serves the given gRPC
+// method. Witnesses only answer cluster and maintenance requests, so that
+// clients find the cluster members through them but never read their data.
+func witnessServes(method string) bool {
+	return strings.HasPrefix(method, "/etcdserverpb.Cluster/") ||
+		strings.HasPrefix(method, "/etcdserverpb.Maintenance/")
+}
+
+// sheddable returns true if the given gRPC method is refused while the
+// member is overloaded. The maintenance and membership requests are
+// served, and so are the lease keep-alives, which would expire otherwise.
+func sheddable(method string) bool {
+	if method == "/etcdserverpb.Lease/LeaseKeepAlive" {
+		return false
+	}
+	return !strings.HasPrefix(method, "/etcdserverpb.Cluster/") &&
+		!strings.HasPrefix(method, "/etcdserverpb.Maintenance/")
+}
+
+func isMutation(method string) bool {
+	switch method {
+	case "/etcdserverpb.KV/Put", "/etcdserverpb.KV/DeleteRange", "/etcdserverpb.KV/Txn":
+		return true
+	}
+	return false
+}
+
+// recordMutation records the mutation req of the given method, received at
+// start, with its response or error.
+func recordMutation(s *etcdserver.EtcdServer, method string, req interface{}, start time.Time, resp interface{}, err error) {
+	rec := etcdserver.MutationRecord{
+		Time:   start,
+		API:    "v3",
+		Method: method[strings.LastIndex(method, "/")+1:],
+		Code:   int(status.Code(err)),
+		Took:   time.Since(start),
+	}
+	if m, ok := req.(interface{ Marshal() ([]byte, error) }); ok {
+		if b, merr := m.Marshal(); merr == nil {
+			rec.BodyHash = etcdserver.MutationBodyHash(b)
+		}
+	}
+	switch r := req.(type) {
+	case *pb.PutRequest:
+		rec.Key = string(r.Key)
+	case *pb.DeleteRangeRequest:
+		rec.Key = string(r.Key)
+	case *pb.TxnRequest:
+		// the first key the transaction compares, or writes
+		ops := r.Success
+		if len(ops) == 0 {
+			ops = r.Failure
+		}
+		if len(r.Compare) > 0 {
+			rec.Key = string(r.Compare[0].Key)
+		} else if len(ops) > 0 {
+			switch op := ops[0].Request.(type) {
+			case *pb.RequestOp_RequestPut:
+				rec.Key = string(op.RequestPut.Key)
+			case *pb.RequestOp_RequestDeleteRange:
+				rec.Key = string(op.RequestDeleteRange.Key)
+			case *pb.RequestOp_RequestRange:
+				rec.Key = string(op.RequestRange.Key)
+			}
+		}
+	}
+	if err != nil {
+		rec.Error = err.Error()
+	} else if hr, ok := resp.(interface{ GetHeader() *pb.ResponseHeader }); ok && hr.GetHeader() != nil {
+		rec.Index = uint64(hr.GetHeader().Revision)
+	}
+	s.RecordMutation(rec)
+}
+
+func refuseAdminUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if IsAdminMethod(info.FullMethod) {
+		return nil, rpctypes.ErrGRPCAdminOnly
+	}
+	return handler(ctx, req)
+}
+
+func refuseAdminStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if IsAdminMethod(info.FullMethod) {
+		return rpctypes.ErrGRPCAdminOnly
+	}
+	return handler(srv, ss)
+}
+
+// IsAdminMethod returns true if the given gRPC method changes the membership
+// or the storage of the cluster, or takes a snapshot of it, so that it is
+// only served on the admin listeners when those are configured.
+func IsAdminMethod(method string) bool {
+	switch method {
+	case "/etcdserverpb.KV/Compact",
+		"/etcdserverpb.Cluster/MemberAdd",
+		"/etcdserverpb.Cluster/MemberRemove",
+		"/etcdserverpb.Cluster/MemberUpdate",
+		"/etcdserverpb.Maintenance/Alarm",
+		"/etcdserverpb.Maintenance/Defragment",
+		"/etcdserverpb.Maintenance/MoveLeader",
+		"/etcdserverpb.Maintenance/Snapshot":
+		return true
+	}
+	return false
+}
+
+// learnerServes returns true if a learner member serves the given gRPC
+// request. Learners are read-only replicas: the requests changing the keys,
+// leases, users or members are rejected, so that clients send them to the
+// voting members instead.
+func learnerServes(method string, req interface{}) bool {
+	switch method {
+	case "/etcdserverpb.KV/Range",
+		"/etcdserverpb.Watch/Watch",
+		"/etcdserverpb.Lease/LeaseTimeToLive",
+		"/etcdserverpb.Lease/LeaseLeases",
+		"/etcdserverpb.Cluster/MemberList",
+		"/etcdserverpb.Auth/Authenticate",
+		"/etcdserverpb.Auth/UserGet",
+		"/etcdserverpb.Auth/UserList",
+		"/etcdserverpb.Auth/RoleGet",
+		"/etcdserverpb.Auth/RoleList",
+		"/v3electionpb.Election/Leader",
+		"/v3electionpb.Election/Observe":
+		return true
+	case "/etcdserverpb.KV/Txn":
+		r, ok := req.(*pb.TxnRequest)
+		return ok && isTxnReadonly(r)
+	}
+	return strings.HasPrefix(method, "/etcdserverpb.Maintenance/")
+}
+
+func isTxnReadonly(r *pb.TxnRequest) bool {
+	for _, u := range r.Success {
+		if u.GetRequestRange() == nil {
+			return false
+		}
+	}
+	for _, u := range r.Failure {
+		if u.GetRequestRange() == nil {
+			return false
+		}
+	}
+	return true
+}
+
 type serverStreamWithCtx struct {
 	grpc.ServerStream
 	ctx    context.Context
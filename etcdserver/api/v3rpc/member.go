@@ -24,6 +24,8 @@ import (
 	"go.etcd.io/etcd/etcdserver/api/v3rpc/rpctypes"
 	pb "go.etcd.io/etcd/etcdserver/etcdserverpb"
 	"go.etcd.io/etcd/pkg/types"
+
+	"google.golang.org/grpc/metadata"
 )
 
 type ClusterServer struct {
@@ -59,7 +61,17 @@ func (cs *ClusterServer) MemberAdd(ctx context.Context, r *pb.MemberAddRequest)
 }
 
 func (cs *ClusterServer) MemberRemove(ctx context.Context, r *pb.MemberRemoveRequest) (*pb.MemberRemoveResponse, error) {
-	membs, err := cs.server.RemoveMember(ctx, r.ID)
+	var membs []*membership.Member
+	var err error
+	if md, ok := metadata.FromIncomingContext(ctx); ok && len(md[rpctypes.MetadataDeadForKey]) > 0 {
+		deadFor, perr := time.ParseDuration(md[rpctypes.MetadataDeadForKey][0])
+		if perr != nil {
+			return nil, rpctypes.ErrGRPCMemberBadDeadFor
+		}
+		membs, err = cs.server.RemoveDeadMember(ctx, r.ID, deadFor)
+	} else {
+		membs, err = cs.server.RemoveMember(ctx, r.ID)
+	}
 	if err != nil {
 		return nil, togRPCError(err)
 	}
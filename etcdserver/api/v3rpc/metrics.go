@@ -14,7 +14,12 @@
 
 package v3rpc
 
-import "github.com/prometheus/client_golang/prometheus"
+import (
+	"go.etcd.io/etcd/etcdserver/api"
+	pb "go.etcd.io/etcd/etcdserver/etcdserverpb"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
 
 var (
 	sentBytes = prometheus.NewCounter(prometheus.CounterOpts{
@@ -39,10 +44,51 @@ var (
 	},
 		[]string{"Type", "API"},
 	)
+
+	prefixRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "etcd",
+		Subsystem: "server",
+		Name:      "kv_prefix_requests_total",
+		Help:      "The total number of key-value requests by top-level key prefix and type (range, put, delete).",
+	},
+		[]string{"prefix", "type"},
+	)
+
+	prefixLabeler = api.NewPrefixLabeler(api.DefaultMaxPrefixLabels)
 )
 
 func init() {
 	prometheus.MustRegister(sentBytes)
 	prometheus.MustRegister(receivedBytes)
 	prometheus.MustRegister(streamFailures)
+	prometheus.MustRegister(prefixRequests)
+}
+
+func reportPrefixRequest(key []byte, typ string) {
+	prefixRequests.WithLabelValues(prefixLabeler.Label(string(key)), typ).Inc()
+}
+
+// reportTxnPrefixRequests reports the operations of the executed branch
+// of a transaction.
+func reportTxnPrefixRequests(r *pb.TxnRequest, resp *pb.TxnResponse) {
+	ops := r.Failure
+	if resp.Succeeded {
+		ops = r.Success
+	}
+	for i, op := range ops {
+		switch tv := op.Request.(type) {
+		case *pb.RequestOp_RequestRange:
+			reportPrefixRequest(tv.RequestRange.Key, "range")
+		case *pb.RequestOp_RequestPut:
+			reportPrefixRequest(tv.RequestPut.Key, "put")
+		case *pb.RequestOp_RequestDeleteRange:
+			reportPrefixRequest(tv.RequestDeleteRange.Key, "delete")
+		case *pb.RequestOp_RequestTxn:
+			if i < len(resp.Responses) {
+				if tresp := resp.Responses[i].GetResponseTxn(); tresp != nil {
+					reportTxnPrefixRequests(tv.RequestTxn, tresp)
+				}
+			}
+		}
+	}
 }
@@ -40,9 +40,14 @@ var (
 	ErrGRPCMemberNotEnoughStarted = status.New(codes.FailedPrecondition, "etcdserver: re-configuration failed due to not enough started members").Err()
 	ErrGRPCMemberBadURLs          = status.New(codes.InvalidArgument, "etcdserver: given member URLs are invalid").Err()
 	ErrGRPCMemberNotFound         = status.New(codes.NotFound, "etcdserver: member not found").Err()
+	ErrGRPCMemberNotDead          = status.New(codes.FailedPrecondition, "etcdserver: member was seen too recently to be removed as dead").Err()
+	ErrGRPCMemberBadDeadFor       = status.New(codes.InvalidArgument, "etcdserver: given dead member duration is invalid").Err()
 
 	ErrGRPCRequestTooLarge        = status.New(codes.InvalidArgument, "etcdserver: request is too large").Err()
 	ErrGRPCRequestTooManyRequests = status.New(codes.ResourceExhausted, "etcdserver: too many requests").Err()
+	ErrGRPCKeyWriteRateExceeded   = status.New(codes.ResourceExhausted, "etcdserver: key write rate exceeded").Err()
+	ErrGRPCKeyQuotaExceeded       = status.New(codes.ResourceExhausted, "etcdserver: key quota exceeded").Err()
+	ErrGRPCMemoryLimitExceeded    = status.New(codes.ResourceExhausted, "etcdserver: memory limit exceeded").Err()
 
 	ErrGRPCRootUserNotExist     = status.New(codes.FailedPrecondition, "etcdserver: root user does not exist").Err()
 	ErrGRPCRootRoleNotExist     = status.New(codes.FailedPrecondition, "etcdserver: root user does not have root role").Err()
@@ -69,6 +74,10 @@ var (
 	ErrGRPCTimeoutDueToConnectionLost = status.New(codes.Unavailable, "etcdserver: request timed out, possibly due to connection lost").Err()
 	ErrGRPCUnhealthy                  = status.New(codes.Unavailable, "etcdserver: unhealthy cluster").Err()
 	ErrGRPCCorrupt                    = status.New(codes.DataLoss, "etcdserver: corrupt cluster").Err()
+	ErrGRPCWitness                    = status.New(codes.Unavailable, "etcdserver: member is a witness").Err()
+	ErrGRPCNotSupportedForLearner     = status.New(codes.Unavailable, "etcdserver: rpc not supported for learner").Err()
+	ErrGRPCAdminOnly                  = status.New(codes.PermissionDenied, "etcdserver: request is only served on the admin URLs").Err()
+	ErrGRPCTooManyForwarded           = status.New(codes.Unavailable, "etcdserver: too many proposals forwarded to the leader").Err()
 
 	errStringToError = map[string]error{
 		ErrorDesc(ErrGRPCEmptyKey):      ErrGRPCEmptyKey,
@@ -91,9 +100,14 @@ var (
 		ErrorDesc(ErrGRPCMemberNotEnoughStarted): ErrGRPCMemberNotEnoughStarted,
 		ErrorDesc(ErrGRPCMemberBadURLs):          ErrGRPCMemberBadURLs,
 		ErrorDesc(ErrGRPCMemberNotFound):         ErrGRPCMemberNotFound,
+		ErrorDesc(ErrGRPCMemberNotDead):          ErrGRPCMemberNotDead,
+		ErrorDesc(ErrGRPCMemberBadDeadFor):       ErrGRPCMemberBadDeadFor,
 
 		ErrorDesc(ErrGRPCRequestTooLarge):        ErrGRPCRequestTooLarge,
 		ErrorDesc(ErrGRPCRequestTooManyRequests): ErrGRPCRequestTooManyRequests,
+		ErrorDesc(ErrGRPCKeyWriteRateExceeded):   ErrGRPCKeyWriteRateExceeded,
+		ErrorDesc(ErrGRPCKeyQuotaExceeded):       ErrGRPCKeyQuotaExceeded,
+		ErrorDesc(ErrGRPCMemoryLimitExceeded):    ErrGRPCMemoryLimitExceeded,
 
 		ErrorDesc(ErrGRPCRootUserNotExist):     ErrGRPCRootUserNotExist,
 		ErrorDesc(ErrGRPCRootRoleNotExist):     ErrGRPCRootRoleNotExist,
@@ -120,6 +134,10 @@ var (
 		ErrorDesc(ErrGRPCTimeoutDueToConnectionLost): ErrGRPCTimeoutDueToConnectionLost,
 		ErrorDesc(ErrGRPCUnhealthy):                  ErrGRPCUnhealthy,
 		ErrorDesc(ErrGRPCCorrupt):                    ErrGRPCCorrupt,
+		ErrorDesc(ErrGRPCWitness):                    ErrGRPCWitness,
+		ErrorDesc(ErrGRPCNotSupportedForLearner):     ErrGRPCNotSupportedForLearner,
+		ErrorDesc(ErrGRPCAdminOnly):                  ErrGRPCAdminOnly,
+		ErrorDesc(ErrGRPCTooManyForwarded):           ErrGRPCTooManyForwarded,
 	}
 )
 
@@ -144,9 +162,14 @@ var (
 	ErrMemberNotEnoughStarted = Error(ErrGRPCMemberNotEnoughStarted)
 	ErrMemberBadURLs          = Error(ErrGRPCMemberBadURLs)
 	ErrMemberNotFound         = Error(ErrGRPCMemberNotFound)
+	ErrMemberNotDead          = Error(ErrGRPCMemberNotDead)
+	ErrMemberBadDeadFor       = Error(ErrGRPCMemberBadDeadFor)
 
-	ErrRequestTooLarge = Error(ErrGRPCRequestTooLarge)
-	ErrTooManyRequests = Error(ErrGRPCRequestTooManyRequests)
+	ErrRequestTooLarge      = Error(ErrGRPCRequestTooLarge)
+	ErrTooManyRequests      = Error(ErrGRPCRequestTooManyRequests)
+	ErrKeyWriteRateExceeded = Error(ErrGRPCKeyWriteRateExceeded)
+	ErrKeyQuotaExceeded     = Error(ErrGRPCKeyQuotaExceeded)
+	ErrMemoryLimitExceeded  = Error(ErrGRPCMemoryLimitExceeded)
 
 	ErrRootUserNotExist     = Error(ErrGRPCRootUserNotExist)
 	ErrRootRoleNotExist     = Error(ErrGRPCRootRoleNotExist)
@@ -173,6 +196,10 @@ var (
 	ErrTimeoutDueToConnectionLost = Error(ErrGRPCTimeoutDueToConnectionLost)
 	ErrUnhealthy                  = Error(ErrGRPCUnhealthy)
 	ErrCorrupt                    = Error(ErrGRPCCorrupt)
+	ErrWitness                    = Error(ErrGRPCWitness)
+	ErrNotSupportedForLearner     = Error(ErrGRPCNotSupportedForLearner)
+	ErrAdminOnly                  = Error(ErrGRPCAdminOnly)
+	ErrTooManyForwarded           = Error(ErrGRPCTooManyForwarded)
 )
 
 // EtcdError defines gRPC server errors.
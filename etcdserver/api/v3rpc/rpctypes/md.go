@@ -17,4 +17,19 @@ package rpctypes
 var (
 	MetadataRequireLeaderKey = "hasleader"
 	MetadataHasLeader        = "true"
+
+	// MetadataRetryAfterKey is the header holding the number of seconds
+	// after which a request refused by an overloaded member can be retried.
+	MetadataRetryAfterKey = "retry-after"
+
+	// MetadataDeadForKey is the header of a member remove request holding
+	// the duration the member must not have been seen for to be removed
+	// as dead.
+	MetadataDeadForKey = "dead-for"
+
+	// MetadataClientIdentityKey and MetadataClientRemoteKey are the
+	// headers holding the identity and the remote address the member
+	// attributes a request to, with client identity headers enabled.
+	MetadataClientIdentityKey = "client-identity"
+	MetadataClientRemoteKey   = "client-remote"
 )
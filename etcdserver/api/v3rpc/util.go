@@ -35,12 +35,16 @@ var toGRPCErrorMap = map[error]error{
 	membership.ErrIDExists:                rpctypes.ErrGRPCMemberExist,
 	membership.ErrPeerURLexists:           rpctypes.ErrGRPCPeerURLExist,
 	etcdserver.ErrNotEnoughStartedMembers: rpctypes.ErrMemberNotEnoughStarted,
+	etcdserver.ErrMemberNotDead:           rpctypes.ErrGRPCMemberNotDead,
 
-	mvcc.ErrCompacted:             rpctypes.ErrGRPCCompacted,
-	mvcc.ErrFutureRev:             rpctypes.ErrGRPCFutureRev,
-	etcdserver.ErrRequestTooLarge: rpctypes.ErrGRPCRequestTooLarge,
-	etcdserver.ErrNoSpace:         rpctypes.ErrGRPCNoSpace,
-	etcdserver.ErrTooManyRequests: rpctypes.ErrTooManyRequests,
+	mvcc.ErrCompacted:                  rpctypes.ErrGRPCCompacted,
+	mvcc.ErrFutureRev:                  rpctypes.ErrGRPCFutureRev,
+	etcdserver.ErrRequestTooLarge:      rpctypes.ErrGRPCRequestTooLarge,
+	etcdserver.ErrNoSpace:              rpctypes.ErrGRPCNoSpace,
+	etcdserver.ErrTooManyRequests:      rpctypes.ErrTooManyRequests,
+	etcdserver.ErrKeyWriteRateExceeded: rpctypes.ErrGRPCKeyWriteRateExceeded,
+	etcdserver.ErrKeyQuotaExceeded:     rpctypes.ErrGRPCKeyQuotaExceeded,
+	etcdserver.ErrMemoryLimitExceeded:  rpctypes.ErrGRPCMemoryLimitExceeded,
 
 	etcdserver.ErrNoLeader:                   rpctypes.ErrGRPCNoLeader,
 	etcdserver.ErrNotLeader:                  rpctypes.ErrGRPCNotLeader,
@@ -52,6 +56,7 @@ var toGRPCErrorMap = map[error]error{
 	etcdserver.ErrUnhealthy:                  rpctypes.ErrGRPCUnhealthy,
 	etcdserver.ErrKeyNotFound:                rpctypes.ErrGRPCKeyNotFound,
 	etcdserver.ErrCorrupt:                    rpctypes.ErrGRPCCorrupt,
+	etcdserver.ErrTooManyForwardedProposals:  rpctypes.ErrGRPCTooManyForwarded,
 
 	lease.ErrLeaseNotFound:    rpctypes.ErrGRPCLeaseNotFound,
 	lease.ErrLeaseExists:      rpctypes.ErrGRPCLeaseExist,
@@ -0,0 +1,232 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdserver
+
+import (
+	"encoding/binary"
+	"hash/crc64"
+	"strconv"
+	"sync"
+
+	"go.etcd.io/etcd/etcdserver/api/v2store"
+	pb "go.etcd.io/etcd/etcdserver/etcdserverpb"
+)
+
+var applyAuditTable = crc64.MakeTable(crc64.ECMA)
+
+// ApplyAuditRecord records the apply of a committed entry.
+type ApplyAuditRecord struct {
+	Index uint64 `json:"index"`
+	// Op describes the request of the entry, e.g. "put" or "v2 PUT /foo".
+	Op string `json:"op"`
+	// EntryHash hashes the index, the request and the result of the entry.
+	// Members applying the same entry to the same state get the same hash.
+	EntryHash uint64 `json:"entryHash"`
+	// Hash is the rolling hash of the entry hashes recorded so far, from
+	// the first entry applied since the member started.
+	Hash uint64 `json:"hash"`
+}
+
+// ApplyAuditDivergence is the result of the comparison of the apply
+// records of two members.
+type ApplyAuditDivergence struct {
+	// FirstIndex and LastIndex are the range of indexes recorded by both
+	// members; both are 0 if the records do not overlap.
+	FirstIndex uint64 `json:"firstIndex"`
+	LastIndex  uint64 `json:"lastIndex"`
+	// Local and Remote are the records of the first entry whose hashes
+	// differ, or nil if none differ.
+	Local  *ApplyAuditRecord `json:"local,omitempty"`
+	Remote *ApplyAuditRecord `json:"remote,omitempty"`
+}
+
+// CompareApplyAudit returns the first entry recorded by both members whose
+// results differ, given their records in index order. The rolling hashes
+// are not compared since the members may have started at different
+// indexes.
+func CompareApplyAudit(local, remote []ApplyAuditRecord) ApplyAuditDivergence {
+	var d ApplyAuditDivergence
+	i, j := 0, 0
+	for i < len(local) && j < len(remote) {
+		l, r := local[i], remote[j]
+		switch {
+		case l.Index < r.Index:
+			i++
+		case l.Index > r.Index:
+			j++
+		default:
+			if d.FirstIndex == 0 {
+				d.FirstIndex = l.Index
+			}
+			d.LastIndex = l.Index
+			if l.EntryHash != r.EntryHash {
+				d.Local, d.Remote = &l, &r
+				return d
+			}
+			i++
+			j++
+		}
+	}
+	return d
+}
+
+// applyAudit keeps the records of the last applied entries in a ring.
+type applyAudit struct {
+	mu      sync.Mutex
+	hash    uint64
+	records []ApplyAuditRecord
+	// next is the position of the next record in the ring.
+	next int
+	full bool
+}
+
+func newApplyAudit(n int) *applyAudit {
+	if n <= 0 {
+		return nil
+	}
+	return &applyAudit{records: make([]ApplyAuditRecord, n)}
+}
+
+// record hashes the apply of the entry at index, given its data and a
+// digest of its result.
+func (a *applyAudit) record(index uint64, op string, data, result []byte) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], index)
+	h := crc64.Update(0, applyAuditTable, b[:])
+	h = crc64.Update(h, applyAuditTable, data)
+	h = crc64.Update(h, applyAuditTable, result)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	binary.BigEndian.PutUint64(b[:], h)
+	a.hash = crc64.Update(a.hash, applyAuditTable, b[:])
+	a.records[a.next] = ApplyAuditRecord{Index: index, Op: op, EntryHash: h, Hash: a.hash}
+	a.next++
+	if a.next == len(a.records) {
+		a.next, a.full = 0, true
+	}
+}
+
+// list returns the records of the entries at or above index from, oldest
+// first.
+func (a *applyAudit) list(from uint64) []ApplyAuditRecord {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var rs []ApplyAuditRecord
+	if a.full {
+		rs = append(rs, a.records[a.next:]...)
+	}
+	rs = append(rs, a.records[:a.next]...)
+	for i, r := range rs {
+		if r.Index >= from {
+			return rs[i:]
+		}
+	}
+	return nil
+}
+
+// ApplyAuditor reports the hashes of the last entries applied by the
+// member, to find the entry from which two members diverged.
+type ApplyAuditor interface {
+	// ApplyAudit returns the records of the entries applied at or above
+	// index from, oldest first.
+	ApplyAudit(from uint64) ([]ApplyAuditRecord, error)
+}
+
+func (s *EtcdServer) ApplyAudit(from uint64) ([]ApplyAuditRecord, error) {
+	if s.applyAudit == nil {
+		return nil, ErrApplyAuditDisabled
+	}
+	return s.applyAudit.list(from), nil
+}
+
+// v2AuditResult digests the parts of the result of a v2 request that are
+// the same on every member; e.g. the TTL of the nodes is not.
+func v2AuditResult(resp Response) []byte {
+	var b []byte
+	if resp.Err != nil {
+		b = append(b, resp.Err.Error()...)
+	}
+	if ev := resp.Event; ev != nil {
+		b = append(b, ev.Action...)
+		b = appendAuditNode(b, ev.Node)
+		b = appendAuditNode(b, ev.PrevNode)
+	}
+	return b
+}
+
+func appendAuditNode(b []byte, n *v2store.NodeExtern) []byte {
+	if n == nil {
+		return append(b, 0)
+	}
+	b = append(b, n.Key...)
+	if n.Value != nil {
+		b = append(b, *n.Value...)
+	}
+	b = strconv.AppendUint(b, n.CreatedIndex, 10)
+	b = strconv.AppendUint(b, n.ModifiedIndex, 10)
+	return b
+}
+
+// v3AuditResult digests the parts of the result of a v3 request that are
+// the same on every member. The range results are left out since only the
+// member serving the request reads them.
+func v3AuditResult(ar *applyResult) []byte {
+	var b []byte
+	if ar == nil {
+		return b
+	}
+	if ar.err != nil {
+		b = append(b, ar.err.Error()...)
+	}
+	if hr, ok := ar.resp.(interface{ GetHeader() *pb.ResponseHeader }); ok && hr.GetHeader() != nil {
+		b = strconv.AppendInt(b, hr.GetHeader().Revision, 10)
+	}
+	switch resp := ar.resp.(type) {
+	case *pb.TxnResponse:
+		b = strconv.AppendBool(b, resp.Succeeded)
+	case *pb.DeleteRangeResponse:
+		b = strconv.AppendInt(b, resp.Deleted, 10)
+	case *pb.LeaseGrantResponse:
+		b = strconv.AppendInt(b, resp.ID, 10)
+	}
+	return b
+}
+
+// v3AuditOp names the request of a v3 entry.
+func v3AuditOp(r *pb.InternalRaftRequest) string {
+	switch {
+	case r.Range != nil:
+		return "range"
+	case r.Put != nil:
+		return "put"
+	case r.DeleteRange != nil:
+		return "delete-range"
+	case r.Txn != nil:
+		return "txn"
+	case r.Compaction != nil:
+		return "compaction"
+	case r.LeaseGrant != nil:
+		return "lease-grant"
+	case r.LeaseRevoke != nil:
+		return "lease-revoke"
+	case r.LeaseCheckpoint != nil:
+		return "lease-checkpoint"
+	case r.Alarm != nil:
+		return "alarm"
+	default:
+		return "auth"
+	}
+}
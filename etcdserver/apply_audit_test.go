@@ -0,0 +1,105 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdserver
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestApplyAuditRing(t *testing.T) {
+	if a := newApplyAudit(0); a != nil {
+		t.Fatalf("newApplyAudit(0) = %v, want nil", a)
+	}
+
+	a := newApplyAudit(3)
+	for i := uint64(1); i <= 5; i++ {
+		a.record(i, "put", []byte("data"), []byte("result"))
+	}
+
+	var idxs []uint64
+	for _, r := range a.list(0) {
+		idxs = append(idxs, r.Index)
+	}
+	if w := []uint64{3, 4, 5}; !reflect.DeepEqual(idxs, w) {
+		t.Errorf("indexes = %v, want %v", idxs, w)
+	}
+	if rs := a.list(5); len(rs) != 1 || rs[0].Index != 5 {
+		t.Errorf("list(5) = %+v, want the record of index 5", rs)
+	}
+	if rs := a.list(6); len(rs) != 0 {
+		t.Errorf("list(6) = %+v, want none", rs)
+	}
+}
+
+func TestApplyAuditHash(t *testing.T) {
+	a, b := newApplyAudit(10), newApplyAudit(10)
+	a.record(1, "put", []byte("data"), []byte("result"))
+	b.record(1, "put", []byte("data"), []byte("result"))
+	a.record(2, "put", []byte("data"), []byte("result"))
+	b.record(2, "put", []byte("data"), []byte("other"))
+
+	ra, rb := a.list(0), b.list(0)
+	if ra[0].EntryHash != rb[0].EntryHash || ra[0].Hash != rb[0].Hash {
+		t.Errorf("hashes of the same entry differ: %+v, %+v", ra[0], rb[0])
+	}
+	if ra[1].EntryHash == rb[1].EntryHash || ra[1].Hash == rb[1].Hash {
+		t.Errorf("hashes of the entries with different results are equal: %+v, %+v", ra[1], rb[1])
+	}
+	// the same entry at another index hashes differently
+	if ra[0].EntryHash == ra[1].EntryHash {
+		t.Errorf("entry hash of index 1 = entry hash of index 2")
+	}
+}
+
+func TestCompareApplyAudit(t *testing.T) {
+	recs := func(start uint64, hashes ...uint64) []ApplyAuditRecord {
+		var rs []ApplyAuditRecord
+		for i, h := range hashes {
+			rs = append(rs, ApplyAuditRecord{Index: start + uint64(i), EntryHash: h})
+		}
+		return rs
+	}
+
+	tests := []struct {
+		local, remote []ApplyAuditRecord
+
+		wfirst, wlast uint64
+		// windex is the index of the first diverging entry, or 0 if none
+		windex uint64
+	}{
+		{local: recs(1, 1, 2, 3), remote: recs(1, 1, 2, 3), wfirst: 1, wlast: 3},
+		{local: recs(1, 1, 2, 3), remote: recs(1, 1, 5, 6), wfirst: 1, wlast: 2, windex: 2},
+		{local: recs(1, 1, 2, 3, 4), remote: recs(3, 3, 9), wfirst: 3, wlast: 4, windex: 4},
+		{local: recs(3, 3, 9), remote: recs(1, 1, 2, 3, 4), wfirst: 3, wlast: 4, windex: 4},
+		{local: recs(1, 1, 2), remote: recs(5, 5, 6)},
+		{local: nil, remote: recs(1, 1)},
+	}
+	for i, tt := range tests {
+		d := CompareApplyAudit(tt.local, tt.remote)
+		if d.FirstIndex != tt.wfirst || d.LastIndex != tt.wlast {
+			t.Errorf("#%d: compared range = [%d, %d], want [%d, %d]", i, d.FirstIndex, d.LastIndex, tt.wfirst, tt.wlast)
+		}
+		if tt.windex == 0 {
+			if d.Local != nil || d.Remote != nil {
+				t.Errorf("#%d: divergence = %+v, %+v, want none", i, d.Local, d.Remote)
+			}
+			continue
+		}
+		if d.Local == nil || d.Remote == nil || d.Local.Index != tt.windex || d.Remote.Index != tt.windex {
+			t.Errorf("#%d: divergence = %+v, %+v, want at index %d", i, d.Local, d.Remote, tt.windex)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+
+	pb "go.etcd.io/etcd/etcdserver/etcdserverpb"
+	"go.etcd.io/etcd/pkg/fileutil"
+	"go.etcd.io/etcd/pkg/pbutil"
+	"go.etcd.io/etcd/raft/raftpb"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// applyQuarantineFile holds, in the member directory, the entry whose
+	// apply last failed and the number of times it failed in a row.
+	applyQuarantineFile = "apply-quarantine"
+
+	// maxApplyDumpBytes bounds the stacks written to an apply failure dump.
+	maxApplyDumpBytes = 1024 * 1024
+)
+
+// applyQuarantine is the record of an entry whose apply panicked or
+// exceeded the apply timeout.
+type applyQuarantine struct {
+	Index    uint64 `json:"index"`
+	Term     uint64 `json:"term"`
+	Op       string `json:"op"`
+	Attempts int    `json:"attempts"`
+	Dump     string `json:"dump"`
+}
+
+// applyGuard catches the entries whose apply panics or exceeds a timeout,
+// so that the member stops with the index and a dump of the entry, and
+// refuses to replay it again once it failed maxAttempts times in a row,
+// instead of crash-looping on it with no context.
+type applyGuard struct {
+	lg          *zap.Logger
+	dir         string
+	timeout     time.Duration
+	maxAttempts int
+
+	mu sync.Mutex
+	// entry is the entry being applied, nil between entries, and start the
+	// time its apply started.
+	entry *raftpb.Entry
+	start time.Time
+	// last is the record of the last failure, until its entry is applied.
+	last *applyQuarantine
+}
+
+// newApplyGuard returns a guard of the entries applied by the member with
+// the given member directory, or nil if both timeout and maxAttempts are 0.
+func newApplyGuard(lg *zap.Logger, dir string, timeout time.Duration, maxAttempts int) *applyGuard {
+	if timeout <= 0 && maxAttempts <= 0 {
+		return nil
+	}
+	return &applyGuard{lg: lg, dir: dir, timeout: timeout, maxAttempts: maxAttempts}
+}
+
+func (g *applyGuard) quarantinePath() string { return filepath.Join(g.dir, applyQuarantineFile) }
+
+// check loads the record of the last failed apply, and returns an error if
+// its entry has failed maxAttempts times and is still to be applied, the
+// snapshot being at index applied.
+func (g *applyGuard) check(applied uint64) error {
+	b, err := ioutil.ReadFile(g.quarantinePath())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var q applyQuarantine
+	if err = json.Unmarshal(b, &q); err != nil {
+		return fmt.Errorf("cannot parse %s: %v", g.quarantinePath(), err)
+	}
+	if q.Index <= applied {
+		// the data directory was restored past the entry
+		return os.Remove(g.quarantinePath())
+	}
+	if g.maxAttempts > 0 && q.Attempts >= g.maxAttempts {
+		return fmt.Errorf("replay stuck at index %d: applying the %s entry failed %d times, see %s; remove %s to retry", q.Index, q.Op, q.Attempts, q.Dump, g.quarantinePath())
+	}
+	g.last = &q
+	return nil
+}
+
+// apply applies e with f, recording the failure of f if it panics before
+// panicking again.
+func (g *applyGuard) apply(e *raftpb.Entry, f func()) {
+	if g == nil {
+		f()
+		return
+	}
+	g.mu.Lock()
+	g.entry, g.start = e, time.Now()
+	g.mu.Unlock()
+	defer func() {
+		if r := recover(); r != nil {
+			g.mu.Lock()
+			q := g.fail(fmt.Sprintf("panic: %v", r), false)
+			g.mu.Unlock()
+			if g.maxAttempts > 0 && q.Attempts >= g.maxAttempts {
+				g.stop(q)
+			}
+			panic(r)
+		}
+	}()
+	f()
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.entry = nil
+	if g.last != nil && g.last.Index <= e.Index {
+		g.last = nil
+		if err := os.Remove(g.quarantinePath()); err != nil && !os.IsNotExist(err) {
+			g.warn("failed to remove apply quarantine record", err)
+		}
+	}
+}
+
+// monitor stops the process once the apply of an entry exceeded the
+// timeout, until stopping is closed.
+func (g *applyGuard) monitor(stopping <-chan struct{}) {
+	if g == nil || g.timeout <= 0 {
+		return
+	}
+	interval := g.timeout / 4
+	if interval < 10*time.Millisecond {
+		interval = 10 * time.Millisecond
+	}
+	for {
+		select {
+		case <-time.After(interval):
+		case <-stopping:
+			return
+		}
+		g.mu.Lock()
+		if g.entry == nil || time.Since(g.start) < g.timeout {
+			g.mu.Unlock()
+			continue
+		}
+		q := g.fail(fmt.Sprintf("apply timed out after %v", time.Since(g.start)), true)
+		g.mu.Unlock()
+		// the apply goroutine cannot be interrupted; the entry is replayed
+		// on restart, until it fails maxAttempts times
+		g.stop(q)
+	}
+}
+
+// stop exits the process after the apply of the entry of q failed.
+func (g *applyGuard) stop(q applyQuarantine) {
+	if g.lg != nil {
+		g.lg.Fatal(
+			"replay stuck",
+			zap.Uint64("index", q.Index),
+			zap.Int("attempts", q.Attempts),
+			zap.String("dump", q.Dump),
+		)
+	} else {
+		plog.Fatalf("replay stuck at index %d after %d attempts, see %s", q.Index, q.Attempts, q.Dump)
+	}
+}
+
+// fail writes a dump of the failed apply of the current entry and updates
+// the quarantine record, which it returns. g.mu must be held.
+func (g *applyGuard) fail(reason string, allStacks bool) applyQuarantine {
+	index := g.entry.Index
+	q := applyQuarantine{Index: index, Term: g.entry.Term, Op: entryOp(g.entry), Attempts: 1}
+	if g.last != nil && g.last.Index == index {
+		q.Attempts = g.last.Attempts + 1
+	}
+	q.Dump = filepath.Join(g.dir, fmt.Sprintf("apply-failure-%d-%d.dump", index, q.Attempts))
+
+	stack := make([]byte, maxApplyDumpBytes)
+	stack = stack[:runtime.Stack(stack, allStacks)]
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "index: %d\nterm: %d\nop: %s\nattempt: %d\nreason: %s\ntime: %s\n\n%s",
+		index, q.Term, q.Op, q.Attempts, reason, time.Now().UTC().Format(time.RFC3339), stack)
+	if err := fileutil.TouchDirAll(g.dir); err != nil {
+		g.warn("failed to create member directory", err)
+	}
+	if err := ioutil.WriteFile(q.Dump, buf.Bytes(), fileutil.PrivateFileMode); err != nil {
+		g.warn("failed to write apply failure dump", err)
+	}
+	b, _ := json.Marshal(q)
+	if err := ioutil.WriteFile(g.quarantinePath(), b, fileutil.PrivateFileMode); err != nil {
+		g.warn("failed to write apply quarantine record", err)
+	}
+	g.last = &q
+
+	if g.lg != nil {
+		g.lg.Error(
+			"failed to apply entry",
+			zap.Uint64("index", index),
+			zap.Uint64("term", q.Term),
+			zap.String("op", q.Op),
+			zap.Int("attempt", q.Attempts),
+			zap.Int("max-attempts", g.maxAttempts),
+			zap.String("reason", reason),
+			zap.String("dump", q.Dump),
+		)
+	} else {
+		plog.Errorf("failed to apply entry at index %d (%s, attempt %d/%d): %s, see %s", index, q.Op, q.Attempts, g.maxAttempts, reason, q.Dump)
+	}
+	return q
+}
+
+func (g *applyGuard) warn(msg string, err error) {
+	if g.lg != nil {
+		g.lg.Warn(msg, zap.String("dir", g.dir), zap.Error(err))
+	} else {
+		plog.Warningf("%s (%v)", msg, err)
+	}
+}
+
+// entryOp describes the operation of e, without its keys and values.
+func entryOp(e *raftpb.Entry) string {
+	if e.Type == raftpb.EntryConfChange {
+		var cc raftpb.ConfChange
+		if err := cc.Unmarshal(e.Data); err != nil {
+			return "conf-change"
+		}
+		return "conf-change " + cc.Type.String()
+	}
+	if len(e.Data) == 0 {
+		return "empty"
+	}
+	var raftReq pb.InternalRaftRequest
+	if !pbutil.MaybeUnmarshal(&raftReq, e.Data) {
+		var r pb.Request
+		if err := r.Unmarshal(e.Data); err != nil {
+			return "unknown"
+		}
+		return "v2 " + r.Method
+	}
+	if raftReq.V2 != nil {
+		return "v2 " + raftReq.V2.Method
+	}
+	return v3AuditOp(&raftReq)
+}
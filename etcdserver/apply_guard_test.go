@@ -0,0 +1,100 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdserver
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"go.etcd.io/etcd/pkg/pbutil"
+	"go.etcd.io/etcd/raft/raftpb"
+)
+
+func TestApplyGuardQuarantine(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "applyguard")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	e := raftpb.Entry{Type: raftpb.EntryConfChange, Index: 7, Term: 2, Data: pbutil.MustMarshal(&raftpb.ConfChange{Type: raftpb.ConfChangeAddNode})}
+	applyPanicking := func(g *applyGuard) {
+		defer func() {
+			if recover() == nil {
+				t.Fatalf("expected the panic to be raised again")
+			}
+		}()
+		g.apply(&e, func() { panic("boom") })
+	}
+
+	// every restart replays the entry, which panics again
+	for attempt := 1; attempt < 3; attempt++ {
+		g := newApplyGuard(testLogger, dir, 0, 3)
+		if err = g.check(5); err != nil {
+			t.Fatalf("attempt %d: unexpected check error %v", attempt, err)
+		}
+		applyPanicking(g)
+		if g.last.Attempts != attempt {
+			t.Fatalf("attempts = %d, want %d", g.last.Attempts, attempt)
+		}
+		b, err := ioutil.ReadFile(g.last.Dump)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(b), "op: conf-change ConfChangeAddNode") || !strings.Contains(string(b), "panic: boom") {
+			t.Errorf("dump = %q, want the entry and the panic", b)
+		}
+	}
+
+	// the last attempt is the one that succeeds
+	g := newApplyGuard(testLogger, dir, 0, 3)
+	if err = g.check(5); err != nil {
+		t.Fatal(err)
+	}
+	g.apply(&e, func() {})
+	if _, err = os.Stat(g.quarantinePath()); !os.IsNotExist(err) {
+		t.Errorf("quarantine record not removed once the entry is applied (%v)", err)
+	}
+}
+
+func TestApplyGuardCheck(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "applyguard")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	g := newApplyGuard(testLogger, dir, 0, 2)
+	rec := `{"index":7,"term":2,"op":"put","attempts":2,"dump":"d"}`
+	if err = ioutil.WriteFile(g.quarantinePath(), []byte(rec), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = g.check(5); err == nil || !strings.Contains(err.Error(), "replay stuck at index 7") {
+		t.Errorf("check error = %v, want replay stuck at index 7", err)
+	}
+	// a snapshot past the entry drops the record
+	if err = g.check(7); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(g.quarantinePath()); !os.IsNotExist(err) {
+		t.Errorf("quarantine record not removed (%v)", err)
+	}
+
+	if newApplyGuard(testLogger, dir, 0, 0) != nil {
+		t.Errorf("expected no guard when disabled")
+	}
+}
@@ -16,13 +16,19 @@ package etcdserver
 
 import (
 	"encoding/json"
+	"hash/fnv"
 	"path"
+	"strconv"
+	"sync"
 	"time"
 
 	"go.etcd.io/etcd/etcdserver/api"
 	"go.etcd.io/etcd/etcdserver/api/membership"
 	"go.etcd.io/etcd/etcdserver/api/v2store"
+	pb "go.etcd.io/etcd/etcdserver/etcdserverpb"
 	"go.etcd.io/etcd/pkg/pbutil"
+	"go.etcd.io/etcd/pkg/types"
+	"go.etcd.io/etcd/raft"
 
 	"github.com/coreos/go-semver/semver"
 	"go.uber.org/zap"
@@ -33,6 +39,8 @@ type ApplierV2 interface {
 	Delete(r *RequestV2) Response
 	Post(r *RequestV2) Response
 	Put(r *RequestV2) Response
+	Claim(r *RequestV2) Response
+	Undelete(r *RequestV2) Response
 	QGet(r *RequestV2) Response
 	Sync(r *RequestV2) Response
 }
@@ -41,6 +49,38 @@ func NewApplierV2(lg *zap.Logger, s v2store.Store, c *membership.RaftCluster) Ap
 	return &applierV2store{lg: lg, store: s, cluster: c}
 }
 
+// V2Replayer applies committed v2 requests to a v2 store outside of a
+// running member, as a member applies them: the writes carrying a client
+// request ID are deduplicated and their results recorded, and the cluster
+// settings are applied.
+type V2Replayer struct {
+	s *EtcdServer
+}
+
+// NewV2Replayer returns a V2Replayer applying to st, whose members are the
+// ones of cl. The cluster settings already in st are applied.
+func NewV2Replayer(lg *zap.Logger, st v2store.Store, cl *membership.RaftCluster) *V2Replayer {
+	s := &EtcdServer{
+		lgMu:    new(sync.RWMutex),
+		lg:      lg,
+		v2store: st,
+		cluster: cl,
+		applyV2: NewApplierV2(lg, st, cl),
+	}
+	s.recoverClusterSettings()
+	return &V2Replayer{s: s}
+}
+
+// Apply applies r. The errors of r are the ones every member gets applying
+// it, and are not returned; Apply only returns ErrUnknownMethod if the
+// method of r is unknown.
+func (rp *V2Replayer) Apply(r *pb.Request) error {
+	if resp := rp.s.applyV2Request((*RequestV2)(r)); resp.Err == ErrUnknownMethod {
+		return resp.Err
+	}
+	return nil
+}
+
 type applierV2store struct {
 	lg      *zap.Logger
 	store   v2store.Store
@@ -57,6 +97,9 @@ func (a *applierV2store) Delete(r *RequestV2) Response {
 }
 
 func (a *applierV2store) Post(r *RequestV2) Response {
+	if err := a.checkParentDir(r, r.Path); err != nil {
+		return Response{Err: err}
+	}
 	return toResponse(a.store.Create(r.Path, r.Dir, r.Val, true, r.TTLOptions()))
 }
 
@@ -71,6 +114,9 @@ func (a *applierV2store) Put(r *RequestV2) Response {
 			}
 			return toResponse(a.store.CompareAndSwap(r.Path, r.PrevValue, r.PrevIndex, r.Val, ttlOptions))
 		}
+		if err := a.checkParentDir(r, path.Dir(r.Path)); err != nil {
+			return Response{Err: err}
+		}
 		return toResponse(a.store.Create(r.Path, r.Dir, r.Val, false, ttlOptions))
 	case r.PrevIndex > 0 || r.PrevValue != "":
 		return toResponse(a.store.CompareAndSwap(r.Path, r.PrevValue, r.PrevIndex, r.Val, ttlOptions))
@@ -91,6 +137,22 @@ func (a *applierV2store) Put(r *RequestV2) Response {
 			// return an empty response since there is no consumer.
 			return Response{}
 		}
+		if storeMemberMetadataRegexp.MatchString(r.Path) {
+			id := membership.MustParseMemberIDFromKey(path.Dir(r.Path))
+			var md map[string]string
+			if err := json.Unmarshal([]byte(r.Val), &md); err != nil {
+				if a.lg != nil {
+					a.lg.Panic("failed to unmarshal", zap.String("value", r.Val), zap.Error(err))
+				} else {
+					plog.Panicf("unmarshal %s should never fail: %v", r.Val, err)
+				}
+			}
+			if a.cluster != nil {
+				a.cluster.UpdateMetadata(id, md)
+			}
+			// return an empty response since there is no consumer.
+			return Response{}
+		}
 		if r.Path == membership.StoreClusterVersionKey() {
 			if a.cluster != nil {
 				a.cluster.SetVersion(semver.Must(semver.NewVersion(r.Val)), api.UpdateCapability)
@@ -98,16 +160,46 @@ func (a *applierV2store) Put(r *RequestV2) Response {
 			// return an empty response since there is no consumer.
 			return Response{}
 		}
+		if err := a.checkParentDir(r, path.Dir(r.Path)); err != nil {
+			return Response{Err: err}
+		}
 		return toResponse(a.store.Set(r.Path, r.Dir, r.Val, ttlOptions))
 	}
 }
 
+// checkParentDir returns the error of the write r if the directory dir its
+// key is created in is missing while r must not create it.
+func (a *applierV2store) checkParentDir(r *RequestV2, dir string) error {
+	if !r.NoImplicitDirs {
+		return nil
+	}
+	_, err := a.store.Lookup(dir)
+	return err
+}
+
+func (a *applierV2store) Claim(r *RequestV2) Response {
+	return toResponse(a.store.ClaimNext(r.Path))
+}
+
+func (a *applierV2store) Undelete(r *RequestV2) Response {
+	return toResponse(a.store.Undelete(r.Path, r.PrevIndex))
+}
+
 func (a *applierV2store) QGet(r *RequestV2) Response {
 	return toResponse(a.store.Get(r.Path, r.Recursive, r.Sorted))
 }
 
 func (a *applierV2store) Sync(r *RequestV2) Response {
-	a.store.DeleteExpiredKeys(time.Unix(0, r.Time))
+	if !r.ListsExpiredKeys {
+		// proposed by a leader that did not list the expired keys
+		a.store.DeleteExpiredKeys(time.Unix(0, r.Time))
+		return Response{}
+	}
+	keys := make([]v2store.ExpiredKey, len(r.ExpiredKeys))
+	for i, k := range r.ExpiredKeys {
+		keys[i] = v2store.ExpiredKey{Key: k.Key, ModifiedIndex: k.ModifiedIndex}
+	}
+	a.store.ExpireKeys(keys, time.Unix(0, r.Time))
 	return Response{}
 }
 
@@ -118,14 +210,27 @@ func (s *EtcdServer) applyV2Request(r *RequestV2) Response {
 
 	switch r.Method {
 	case "POST":
-		return s.applyV2.Post(r)
+		return s.applyV2Write(r, s.applyV2.Post)
 	case "PUT":
-		return s.applyV2.Put(r)
+		resp := s.applyV2Write(r, s.applyV2.Put)
+		if resp.Err == nil {
+			s.applyClusterSetting(r.Path, r.Val)
+		}
+		return resp
 	case "DELETE":
-		return s.applyV2.Delete(r)
+		return s.applyV2Write(r, s.applyV2.Delete)
+	case "CLAIM":
+		return s.applyV2Write(r, s.applyV2.Claim)
+	case "UNDELETE":
+		return s.applyV2Write(r, s.applyV2.Undelete)
 	case "QGET":
 		return s.applyV2.QGet(r)
 	case "SYNC":
+		// the SYNC requests applied while replaying the log at startup,
+		// before any leader is known, may be arbitrarily old
+		if s.Leader() != types.ID(raft.None) {
+			s.leaderClock.observe(time.Unix(0, r.Time))
+		}
 		return s.applyV2.Sync(r)
 	default:
 		// This should never be reached, but just in case:
@@ -133,6 +238,101 @@ func (s *EtcdServer) applyV2Request(r *RequestV2) Response {
 	}
 }
 
+// v2RequestIDWindow is how long the result of a v2 write carrying a client
+// request ID is kept, to answer the retries of the write.
+const v2RequestIDWindow = 5 * time.Minute
+
+// v2RequestResult is the result of a v2 write carrying a client request ID,
+// as kept in the store, along with the write it is the result of.
+type v2RequestResult struct {
+	RequestID string         `json:"requestId"`
+	Method    string         `json:"method"`
+	Path      string         `json:"path"`
+	User      string         `json:"user,omitempty"`
+	Event     *v2store.Event `json:"event"`
+	EtcdIndex uint64         `json:"etcdIndex"`
+}
+
+// sameRequest returns true if res is the result of the write r.
+func (res *v2RequestResult) sameRequest(r *RequestV2) bool {
+	return res.RequestID == r.ClientRequestID && res.Method == r.Method && res.Path == r.Path && res.User == r.ClientRequestUser
+}
+
+// v2RequestResultPath returns the path the result of the write r is kept
+// at, from the hash of the client request ID along with the method, the
+// path and the user of the write, so that the same request ID used for
+// another write, or by another user, is not taken for a retry.
+func v2RequestResultPath(r *RequestV2) string {
+	h := fnv.New64a()
+	for _, f := range []string{r.Method, r.Path, r.ClientRequestUser, r.ClientRequestID} {
+		h.Write([]byte(f))
+		h.Write([]byte{0})
+	}
+	return path.Join(storeRequestIDsPrefix, strconv.FormatUint(h.Sum64(), 16))
+}
+
+// applyV2Write applies the write r with apply, unless r carries a client
+// request ID that was already applied, in which case the result of the
+// first write is returned. The results are kept in the store, under
+// storeRequestIDsPrefix, so that every member, including the members
+// recovering from a snapshot, deduplicates the same writes.
+func (s *EtcdServer) applyV2Write(r *RequestV2, apply func(r *RequestV2) Response) Response {
+	if r.ClientRequestID == "" || r.ClientRequestTime == 0 {
+		return apply(r)
+	}
+
+	p := v2RequestResultPath(r)
+	if n, err := s.v2store.Lookup(p); err == nil {
+		var res v2RequestResult
+		if err = json.Unmarshal([]byte(*n.Value), &res); err != nil {
+			if lg := s.getLogger(); lg != nil {
+				lg.Panic("failed to unmarshal", zap.String("value", *n.Value), zap.Error(err))
+			} else {
+				plog.Panicf("unmarshal %s should never fail: %v", *n.Value, err)
+			}
+		}
+		if !res.sameRequest(r) {
+			// another write whose hash collides; the result of the
+			// first one is kept until it expires
+			return apply(r)
+		}
+		res.Event.EtcdIndex = res.EtcdIndex
+		v2RequestsDeduplicated.Inc()
+		return Response{Event: res.Event}
+	}
+
+	// only the successful writes are kept: the failed ones did not change
+	// the store, so applying their retries is safe
+	resp := apply(r)
+	if resp.Err != nil || resp.Event == nil {
+		return resp
+	}
+	b, err := json.Marshal(v2RequestResult{
+		RequestID: r.ClientRequestID,
+		Method:    r.Method,
+		Path:      r.Path,
+		User:      r.ClientRequestUser,
+		Event:     resp.Event,
+		EtcdIndex: resp.Event.EtcdIndex,
+	})
+	if err != nil {
+		if lg := s.getLogger(); lg != nil {
+			lg.Panic("failed to marshal", zap.Error(err))
+		} else {
+			plog.Panicf("marshal should never fail: %v", err)
+		}
+	}
+	ttl := v2store.TTLOptionSet{ExpireTime: time.Unix(0, r.ClientRequestTime).Add(v2RequestIDWindow)}
+	if _, err = s.v2store.Set(p, false, string(b), ttl); err != nil {
+		if lg := s.getLogger(); lg != nil {
+			lg.Panic("failed to keep request result", zap.String("path", p), zap.Error(err))
+		} else {
+			plog.Panicf("keeping the result of request at %s should never fail: %v", p, err)
+		}
+	}
+	return resp
+}
+
 func (r *RequestV2) TTLOptions() v2store.TTLOptionSet {
 	refresh, _ := pbutil.GetBool(r.Refresh)
 	ttlOptions := v2store.TTLOptionSet{Refresh: refresh}
@@ -0,0 +1,76 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdserver
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.etcd.io/etcd/etcdserver/api/membership"
+	"go.etcd.io/etcd/pkg/fileutil"
+)
+
+// The initial cluster state only matters to a member without a WAL: a
+// member with one restarts from it whatever the state. The checks below
+// catch the two common bootstrap mistakes before the member writes to its
+// data directory.
+
+// checkNewClusterDataDir returns an error if the data directory of a
+// member bootstrapping a new cluster holds the data of a cluster while its
+// WAL is missing, e.g. after --wal-dir changed, which would otherwise
+// bootstrap a new cluster over the data of the old one.
+func checkNewClusterDataDir(cfg ServerConfig) error {
+	var found []string
+	if fileutil.Exist(cfg.backendPath()) {
+		found = append(found, cfg.backendPath())
+	}
+	names, err := ioutil.ReadDir(cfg.SnapDir())
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, fi := range names {
+		if strings.HasSuffix(fi.Name(), ".snap") {
+			found = append(found, filepath.Join(cfg.SnapDir(), fi.Name()))
+			break
+		}
+	}
+	if len(found) == 0 {
+		return nil
+	}
+	return fmt.Errorf("--initial-cluster-state=new, but data directory %q already belongs to a cluster (found %s) and has no WAL in %q; "+
+		"point --wal-dir at its WAL to restart the member, or remove the data directory to bootstrap a new cluster",
+		cfg.DataDir, strings.Join(found, ", "), cfg.WALDir())
+}
+
+// fetchExistingCluster returns the members of the cluster a member with
+// the initial cluster cl joins, fetched from the other members of cl.
+func fetchExistingCluster(cfg ServerConfig, cl *membership.RaftCluster, rt http.RoundTripper) (*membership.RaftCluster, error) {
+	urls := getRemotePeerURLs(cl, cfg.Name)
+	if len(urls) == 0 {
+		return nil, fmt.Errorf("--initial-cluster-state=existing, but --initial-cluster lists no member other than %q to contact the cluster through; "+
+			"list the running members of the cluster, or use --initial-cluster-state=new to bootstrap a new cluster", cfg.Name)
+	}
+	existing, err := GetClusterFromRemotePeers(cfg.Logger, urls, rt)
+	if err != nil {
+		return nil, fmt.Errorf("--initial-cluster-state=existing, but no member of the cluster answered at %s (%v); "+
+			"check that --initial-cluster lists its running members, or use --initial-cluster-state=new to bootstrap a new cluster",
+			strings.Join(urls, ","), err)
+	}
+	return existing, nil
+}
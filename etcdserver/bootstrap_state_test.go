@@ -0,0 +1,86 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdserver
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.etcd.io/etcd/etcdserver/api/membership"
+	"go.etcd.io/etcd/pkg/types"
+)
+
+func TestCheckNewClusterDataDir(t *testing.T) {
+	tests := []struct {
+		files []string
+
+		werr bool
+	}{
+		{nil, false},
+		{[]string{"member/snap/db"}, true},
+		{[]string{"member/snap/0000000000000002-0000000000000010.snap"}, true},
+		{[]string{"member/snap/0000000000000002-0000000000000010.snap.broken"}, false},
+	}
+	for i, tt := range tests {
+		dir, err := ioutil.TempDir(os.TempDir(), "bootstrap")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		for _, f := range tt.files {
+			p := filepath.Join(dir, f)
+			if err = os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+				t.Fatal(err)
+			}
+			if err = ioutil.WriteFile(p, []byte("data"), 0600); err != nil {
+				t.Fatal(err)
+			}
+		}
+		err = checkNewClusterDataDir(ServerConfig{DataDir: dir})
+		if (err != nil) != tt.werr {
+			t.Errorf("#%d: err = %v, want error %v", i, err, tt.werr)
+		}
+	}
+}
+
+func TestFetchExistingClusterNoPeer(t *testing.T) {
+	tests := []struct {
+		cluster string
+
+		werr string
+	}{
+		{"node1=http://127.0.0.1:2380", "lists no member other than"},
+		// nothing listens on port 1
+		{"node1=http://127.0.0.1:2380,node2=http://127.0.0.1:1", "no member of the cluster answered at http://127.0.0.1:1"},
+	}
+	for i, tt := range tests {
+		urlsmap, err := types.NewURLsMap(tt.cluster)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cl, err := membership.NewClusterFromURLsMap(testLogger, "token", urlsmap)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = fetchExistingCluster(ServerConfig{Name: "node1", InitialPeerURLsMap: urlsmap}, cl, http.DefaultTransport)
+		if err == nil || !strings.Contains(err.Error(), tt.werr) {
+			t.Errorf("#%d: err = %v, want error containing %q", i, err, tt.werr)
+		}
+	}
+}
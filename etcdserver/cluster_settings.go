@@ -0,0 +1,201 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdserver
+
+import (
+	"context"
+	"path"
+	"sort"
+	"time"
+
+	"go.etcd.io/etcd/etcdserver/api/rafthttp"
+	pb "go.etcd.io/etcd/etcdserver/etcdserverpb"
+
+	"go.uber.org/zap"
+)
+
+// storeClusterSettingsPrefix holds the cluster settings: the settings every
+// member must apply the same, since they decide the outcome of the
+// replicated requests. A setting is set by a PUT request to its key under
+// the prefix, which every member applies; the leader proposes the settings
+// of its configuration that differ from the ones of the cluster.
+var storeClusterSettingsPrefix = path.Join(StoreClusterPrefix, "settings")
+
+type clusterSetting struct {
+	// configured returns the value of the setting in the configuration of
+	// the member, or "" if it is unset. It is nil for the settings only
+	// set at runtime, which the leader never proposes.
+	configured func(s *EtcdServer) string
+	// apply applies the value of the setting, "" if it is unset. It is
+	// called when applying the request setting it, and when recovering
+	// from a snapshot.
+	apply func(s *EtcdServer, val string)
+}
+
+var clusterSettings = map[string]clusterSetting{
+	"key-quotas": {
+		apply: func(s *EtcdServer, val string) { s.applyKeyQuotas(val) },
+	},
+	"leader-placement": {
+		configured: func(s *EtcdServer) string { return s.Cfg.LeaderPlacement.String() },
+		apply:      func(s *EtcdServer, val string) { s.applyLeaderPlacement(val) },
+	},
+	"peer-transport": {
+		configured: func(s *EtcdServer) string {
+			if s.Cfg.PeerTransport != rafthttp.ProtocolGRPC {
+				return ""
+			}
+			return s.Cfg.PeerTransport
+		},
+		apply: func(s *EtcdServer, val string) { s.applyPeerTransport(val) },
+	},
+	"v2-expired-history-retention": {
+		configured: func(s *EtcdServer) string {
+			if s.Cfg.V2ExpiredHistoryRetention == 0 {
+				return ""
+			}
+			return s.Cfg.V2ExpiredHistoryRetention.String()
+		},
+		apply: func(s *EtcdServer, val string) {
+			var d time.Duration
+			if val != "" {
+				var err error
+				if d, err = time.ParseDuration(val); err != nil {
+					s.warnClusterSetting("v2-expired-history-retention", val, err)
+					return
+				}
+			}
+			s.v2store.SetExpiredHistoryRetention(d)
+		},
+	},
+	"v2-tombstone-retention": {
+		configured: func(s *EtcdServer) string {
+			if s.Cfg.V2TombstoneRetention == 0 {
+				return ""
+			}
+			return s.Cfg.V2TombstoneRetention.String()
+		},
+		apply: func(s *EtcdServer, val string) {
+			var d time.Duration
+			if val != "" {
+				var err error
+				if d, err = time.ParseDuration(val); err != nil {
+					s.warnClusterSetting("v2-tombstone-retention", val, err)
+					return
+				}
+			}
+			s.v2store.SetTombstoneRetention(d)
+		},
+	},
+}
+
+func clusterSettingPath(name string) string {
+	return path.Join(storeClusterSettingsPrefix, name)
+}
+
+// clusterSetting returns the value of the cluster setting name, or "" if it
+// is unset.
+func (s *EtcdServer) clusterSetting(name string) string {
+	n, err := s.v2store.Lookup(clusterSettingPath(name))
+	if err != nil || n.Value == nil {
+		return ""
+	}
+	return *n.Value
+}
+
+// applyClusterSetting applies the cluster setting at p, if p is the key of
+// one, set to val by an applied request.
+func (s *EtcdServer) applyClusterSetting(p, val string) {
+	if path.Dir(p) != storeClusterSettingsPrefix {
+		return
+	}
+	if st, ok := clusterSettings[path.Base(p)]; ok {
+		st.apply(s, val)
+	}
+}
+
+// recoverClusterSettings applies the cluster settings of the v2 store.
+func (s *EtcdServer) recoverClusterSettings() {
+	for name, st := range clusterSettings {
+		st.apply(s, s.clusterSetting(name))
+	}
+}
+
+func (s *EtcdServer) warnClusterSetting(name, val string, err error) {
+	if lg := s.getLogger(); lg != nil {
+		lg.Warn(
+			"ignored invalid cluster setting",
+			zap.String("name", name),
+			zap.String("value", val),
+			zap.Error(err),
+		)
+	} else {
+		plog.Warningf("ignored invalid cluster setting %s=%q (%v)", name, val, err)
+	}
+}
+
+// monitorClusterSettings proposes, while the member is the leader, the
+// settings of its configuration that differ from the ones of the cluster.
+func (s *EtcdServer) monitorClusterSettings() {
+	names := make([]string, 0, len(clusterSettings))
+	for name, st := range clusterSettings {
+		if st.configured != nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for {
+		select {
+		case <-time.After(monitorVersionInterval):
+		case <-s.stopping:
+			return
+		}
+
+		if !s.isLeader() {
+			continue
+		}
+		for _, name := range names {
+			val := clusterSettings[name].configured(s)
+			if val == s.clusterSetting(name) {
+				continue
+			}
+			s.updateClusterSetting(name, val)
+		}
+	}
+}
+
+func (s *EtcdServer) updateClusterSetting(name, val string) {
+	lg := s.getLogger()
+	if lg != nil {
+		lg.Info(
+			"updating cluster setting",
+			zap.String("name", name),
+			zap.String("from", s.clusterSetting(name)),
+			zap.String("to", val),
+		)
+	} else {
+		plog.Infof("updating cluster setting %s from %q to %q", name, s.clusterSetting(name), val)
+	}
+	ctx, cancel := context.WithTimeout(s.ctx, s.Cfg.ReqTimeout())
+	_, err := s.Do(ctx, pb.Request{Method: "PUT", Path: clusterSettingPath(name), Val: val})
+	cancel()
+	if err != nil && err != ErrStopped {
+		if lg != nil {
+			lg.Warn("failed to update cluster setting", zap.String("name", name), zap.Error(err))
+		} else {
+			plog.Warningf("failed to update cluster setting %s (%v)", name, err)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdserver
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"go.etcd.io/etcd/etcdserver/api/rafthttp"
+	"go.etcd.io/etcd/etcdserver/api/v2store"
+	pb "go.etcd.io/etcd/etcdserver/etcdserverpb"
+	"go.etcd.io/etcd/pkg/transport"
+
+	"go.uber.org/zap"
+)
+
+// TestApplyClusterSetting ensures the cluster settings are applied from the
+// requests setting them, whatever the configuration of the member, and
+// recovered from the store.
+func TestApplyClusterSetting(t *testing.T) {
+	st := v2store.New(StoreClusterPrefix, StoreKeysPrefix)
+	srv := &EtcdServer{
+		lgMu:    new(sync.RWMutex),
+		lg:      zap.NewExample(),
+		Cfg:     ServerConfig{V2TombstoneRetention: time.Hour},
+		v2store: st,
+	}
+	srv.applyV2 = &applierV2store{store: srv.v2store, cluster: srv.cluster}
+
+	if d := st.TombstoneRetention(); d != 0 {
+		t.Fatalf("retention = %v, want 0 before the setting is applied", d)
+	}
+	put := func(val string) {
+		req := pb.Request{Method: "PUT", Path: clusterSettingPath("v2-tombstone-retention"), Val: val}
+		if resp := srv.applyV2Request((*RequestV2)(&req)); resp.Err != nil {
+			t.Fatal(resp.Err)
+		}
+	}
+	put("1m0s")
+	if d := st.TombstoneRetention(); d != time.Minute {
+		t.Errorf("retention = %v, want 1m", d)
+	}
+	if v := srv.clusterSetting("v2-tombstone-retention"); v != "1m0s" {
+		t.Errorf("setting = %q, want 1m0s", v)
+	}
+	// an invalid value is ignored
+	put("1 minute")
+	if d := st.TombstoneRetention(); d != time.Minute {
+		t.Errorf("retention = %v, want 1m", d)
+	}
+
+	put("2m0s")
+	b, err := st.Save()
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.v2store = v2store.New(StoreClusterPrefix, StoreKeysPrefix)
+	if err = srv.v2store.Recovery(b); err != nil {
+		t.Fatal(err)
+	}
+	srv.v2store.SetTombstoneRetention(0)
+	srv.recoverClusterSettings()
+	if d := srv.v2store.TombstoneRetention(); d != 2*time.Minute {
+		t.Errorf("recovered retention = %v, want 2m", d)
+	}
+
+	// unset
+	srv.applyV2 = &applierV2store{store: srv.v2store, cluster: srv.cluster}
+	put("")
+	if d := srv.v2store.TombstoneRetention(); d != 0 {
+		t.Errorf("retention = %v, want 0 once unset", d)
+	}
+}
+
+// TestApplyExpiredHistorySetting ensures the retention of the history of
+// the expired keys follows its cluster setting, and is recovered with it.
+func TestApplyExpiredHistorySetting(t *testing.T) {
+	st := v2store.New(StoreClusterPrefix, StoreKeysPrefix)
+	srv := &EtcdServer{
+		lgMu:    new(sync.RWMutex),
+		lg:      zap.NewExample(),
+		Cfg:     ServerConfig{V2ExpiredHistoryRetention: time.Hour},
+		v2store: st,
+	}
+	srv.applyV2 = &applierV2store{store: srv.v2store, cluster: srv.cluster}
+
+	if d := st.ExpiredHistoryRetention(); d != 0 {
+		t.Fatalf("retention = %v, want 0 before the setting is applied", d)
+	}
+	req := pb.Request{Method: "PUT", Path: clusterSettingPath("v2-expired-history-retention"), Val: "1m0s"}
+	if resp := srv.applyV2Request((*RequestV2)(&req)); resp.Err != nil {
+		t.Fatal(resp.Err)
+	}
+	if d := st.ExpiredHistoryRetention(); d != time.Minute {
+		t.Errorf("retention = %v, want 1m", d)
+	}
+
+	b, err := st.Save()
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.v2store = v2store.New(StoreClusterPrefix, StoreKeysPrefix)
+	if err = srv.v2store.Recovery(b); err != nil {
+		t.Fatal(err)
+	}
+	srv.recoverClusterSettings()
+	if d := srv.v2store.ExpiredHistoryRetention(); d != time.Minute {
+		t.Errorf("recovered retention = %v, want 1m", d)
+	}
+}
+
+// TestApplyPeerTransportSetting ensures the transport of the peers follows
+// the peer-transport cluster setting.
+func TestApplyPeerTransportSetting(t *testing.T) {
+	tr := &rafthttp.Transport{TLSInfo: transport.TLSInfo{ClientCertAuth: true}}
+	srv := &EtcdServer{
+		lgMu:    new(sync.RWMutex),
+		lg:      zap.NewExample(),
+		v2store: v2store.New(StoreClusterPrefix, StoreKeysPrefix),
+		r:       raftNode{raftNodeConfig: raftNodeConfig{transport: tr}},
+	}
+	srv.applyV2 = &applierV2store{store: srv.v2store, cluster: srv.cluster}
+
+	tests := []struct {
+		val       string
+		wprotocol string
+	}{
+		{"grpc", rafthttp.ProtocolGRPC},
+		// an invalid value is ignored
+		{"quic", rafthttp.ProtocolGRPC},
+		{"", rafthttp.ProtocolHTTP},
+	}
+	for i, tt := range tests {
+		req := pb.Request{Method: "PUT", Path: clusterSettingPath("peer-transport"), Val: tt.val}
+		if resp := srv.applyV2Request((*RequestV2)(&req)); resp.Err != nil {
+			t.Fatal(resp.Err)
+		}
+		if tr.Protocol != tt.wprotocol {
+			t.Errorf("#%d: protocol = %q, want %q", i, tr.Protocol, tt.wprotocol)
+		}
+	}
+}
@@ -18,6 +18,9 @@ import (
 	"reflect"
 	"testing"
 
+	"go.etcd.io/etcd/etcdserver/api"
+	"go.etcd.io/etcd/etcdserver/api/membership"
+	"go.etcd.io/etcd/etcdserver/api/v2store"
 	"go.etcd.io/etcd/pkg/types"
 	"go.etcd.io/etcd/version"
 
@@ -63,6 +66,34 @@ func TestDecideClusterVersion(t *testing.T) {
 	}
 }
 
+// TestDecideClusterVersionCapabilities ensures the capabilities of the
+// members of this tree are enabled by the cluster version they decide.
+func TestDecideClusterVersionCapabilities(t *testing.T) {
+	vers := map[string]*version.Versions{
+		"a": {Server: version.Version},
+		"b": {Server: version.Version},
+	}
+	v := decideClusterVersion(testLogger, vers)
+	if v == nil {
+		t.Fatal("no cluster version decided")
+	}
+	// as monitorVersions, which proposes the major.minor version
+	cv := &semver.Version{Major: v.Major, Minor: v.Minor}
+
+	cl := membership.NewCluster(testLogger, "")
+	cl.SetStore(v2store.New())
+	a := &applierV2store{lg: testLogger, store: v2store.New(), cluster: cl}
+	req := &RequestV2{Method: "PUT", Path: membership.StoreClusterVersionKey(), Val: cv.String()}
+	if resp := a.Put(req); resp.Err != nil {
+		t.Fatal(resp.Err)
+	}
+	for _, c := range []api.Capability{api.AuthCapability, api.V3rpcCapability, api.V2WriteOptionsCapability} {
+		if !api.IsCapabilityEnabled(c) {
+			t.Errorf("capability %q is not enabled at cluster version %s", c, cv)
+		}
+	}
+}
+
 func TestIsCompatibleWithVers(t *testing.T) {
 	tests := []struct {
 		vers       map[string]*version.Versions
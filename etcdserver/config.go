@@ -22,9 +22,12 @@ import (
 	"strings"
 	"time"
 
+	"go.etcd.io/etcd/etcdserver/api/rafthttp"
+	"go.etcd.io/etcd/etcdserver/api/snap"
 	"go.etcd.io/etcd/pkg/netutil"
 	"go.etcd.io/etcd/pkg/transport"
 	"go.etcd.io/etcd/pkg/types"
+	"go.etcd.io/etcd/wal"
 
 	bolt "go.etcd.io/bbolt"
 	"go.uber.org/zap"
@@ -44,6 +47,10 @@ type ServerConfig struct {
 	DedicatedWALDir string
 
 	SnapshotCount uint64
+	// SnapshotCountAuto is true to trigger the snapshots once replaying the
+	// entries applied since the last one would cost as much as taking a
+	// snapshot. SnapshotCount is then the most entries between snapshots.
+	SnapshotCountAuto bool
 
 	// SnapshotCatchUpEntries is the number of entries for a slow follower
 	// to catch-up after compacting the raft storage entries.
@@ -56,6 +63,11 @@ type ServerConfig struct {
 	MaxSnapFiles uint
 	MaxWALFiles  uint
 
+	// WALPreallocateBytes is the size preallocated for each WAL segment
+	// file. 0 preallocates the whole segment; a negative value disables
+	// preallocation.
+	WALPreallocateBytes int64
+
 	// BackendBatchInterval is the maximum time before commit the backend transaction.
 	BackendBatchInterval time.Duration
 	// BackendBatchLimit is the maximum operations before commit the backend transaction.
@@ -68,8 +80,29 @@ type ServerConfig struct {
 	InitialClusterToken string
 	NewCluster          bool
 	PeerTLSInfo         transport.TLSInfo
+	// PeerSharedSecret, if not empty, signs the requests sent to peers
+	// and authenticates the requests received from them.
+	PeerSharedSecret []byte
+	// PeerAllowedNetworks, if not empty, holds the networks of the peers
+	// that may be dialed and whose connections are accepted.
+	PeerAllowedNetworks transport.AllowedNetworks
+	// PeerTransport is the protocol the raft messages are sent to the
+	// peers with, rafthttp.ProtocolHTTP or rafthttp.ProtocolGRPC. It is a
+	// cluster setting: the leader proposes its own to the cluster.
+	PeerTransport string
+	// PeerDialTimeout is the timeout of the dials to the peers. 0 derives
+	// it from the election timeout.
+	PeerDialTimeout time.Duration
+	// PeerRequestTimeout is the timeout of the requests sending raft
+	// messages to the peers. 0 derives it from the election timeout.
+	PeerRequestTimeout time.Duration
 
 	CORS map[string]struct{}
+	// CORSExposeHeaders lists the response headers exposed to
+	// cross-origin scripts.
+	CORSExposeHeaders []string
+	// CORSMaxAge is how long browsers may cache preflight responses.
+	CORSMaxAge time.Duration
 
 	// HostWhitelist lists acceptable hostnames from client requests.
 	// If server is insecure (no TLS), server only accepts requests
@@ -118,11 +151,47 @@ type ServerConfig struct {
 	// MaxRequestBytes is the maximum request size to send over raft.
 	MaxRequestBytes uint
 
+	// MaxRecursiveKeys is the maximum number of keys a v2 recursive get
+	// or delete may touch. 0 means unlimited.
+	MaxRecursiveKeys uint
+	// MaxWatchersPerClient and MaxWatchersPerKey are the maximum numbers
+	// of v2 watchers a client, identified by its remote host, may hold and
+	// a key may have. 0 means unlimited.
+	MaxWatchersPerClient uint
+	MaxWatchersPerKey    uint
+
+	// MemoryLimitBytes is the estimated memory usage of the v2 store and
+	// the raft log above which the writes adding data are refused. 0
+	// means unlimited.
+	MemoryLimitBytes uint64
+
+	// V2TombstoneRetention is how long the deleted v2 keys are retained
+	// as tombstones. 0 disables the tombstones. It is proposed to the
+	// cluster by the leader, see clusterSettings.
+	V2TombstoneRetention time.Duration
+
+	// V2ExpiredHistoryRetention is how long the events of the expired v2
+	// keys stay in the event history. 0 never prunes them. It is proposed
+	// to the cluster by the leader, see clusterSettings.
+	V2ExpiredHistoryRetention time.Duration
+
 	StrictReconfigCheck bool
 
 	// ClientCertAuthEnabled is true when cert has been signed by the client CA.
 	ClientCertAuthEnabled bool
 
+	// V2CacheControl is the Cache-Control header value of v2 key GET responses.
+	V2CacheControl string
+	// V2ETag is true to set ETags on v2 key GET responses.
+	V2ETag bool
+	// V2ExplicitDirs is true if the v2 PUT and POST requests must not
+	// create the missing parent directories of their key by default.
+	V2ExplicitDirs bool
+	// V2WatchReplayEntries is the maximum number of committed entries
+	// replayed to recover the event of a v2 watch from an index cleared
+	// from the event history. 0 disables it.
+	V2WatchReplayEntries int
+
 	AuthToken  string
 	BcryptCost uint
 
@@ -131,8 +200,77 @@ type ServerConfig struct {
 	InitialCorruptCheck bool
 	CorruptCheckTime    time.Duration
 
+	// DiskLatencyThreshold is the average WAL save or backend commit
+	// latency above which the member is marked degraded. 0 disables the
+	// disk watchdog.
+	DiskLatencyThreshold time.Duration
+	// DiskDegradedTransferLeadership is true to transfer leadership away
+	// from the member while it is degraded.
+	DiskDegradedTransferLeadership bool
+
+	// MaxApplyLag is the number of committed but not yet applied entries
+	// above which the member refuses serializable v2 reads. 0 disables it.
+	MaxApplyLag uint64
+
+	// MaxPendingProposals is the number of local proposals waiting to be
+	// applied at which the member refuses client requests. 0 disables it.
+	MaxPendingProposals int
+	// MaxForwardedProposals is the number of local proposals forwarded to
+	// the leader and waiting to be applied at which a follower refuses new
+	// proposals. 0 disables it.
+	MaxForwardedProposals int
+	// MaxApplyBacklog is the number of committed but not yet applied
+	// entries above which the member refuses client requests. 0 disables it.
+	MaxApplyBacklog uint64
+	// MaxUnstableEntries is the number of entries of a raft Ready to
+	// persist above which the member refuses client requests, as the disk
+	// falls behind the proposals. 0 disables it.
+	MaxUnstableEntries int
+
+	// SnapshotCodec compresses and encrypts the written snap files.
+	SnapshotCodec snap.Codec
+
+	// LeaseRead is true if the leader serves the linearizable reads while
+	// its lease is valid, without confirming its leadership to a quorum.
+	LeaseRead bool
+
+	// ApplyAuditEntries is the number of last applied entries whose hashes
+	// are recorded, to compare them with the ones of another member. 0
+	// disables it.
+	ApplyAuditEntries int
+
+	// FlightRecorderEntries is the number of last client mutations
+	// recorded with their responses. 0 disables it.
+	FlightRecorderEntries int
+
+	// ApplyTimeout is the time after which the member stops if applying an
+	// entry has not returned. 0 disables it.
+	ApplyTimeout time.Duration
+	// ApplyMaxAttempts is the number of times in a row applying an entry
+	// may panic or time out before the member refuses to replay it. 0
+	// disables it.
+	ApplyMaxAttempts int
+
+	// JoinSeed is true for a member joining an existing cluster to seed its
+	// data directory from the latest snapshot of a member, and ask the
+	// cluster to add it only then, instead of being added beforehand.
+	JoinSeed bool
+
+	// ClientIdentityHeaders is true to return the identity and the remote
+	// address the member attributes a client request to in its response
+	// headers.
+	ClientIdentityHeaders bool
+
 	// PreVote is true to enable Raft Pre-Vote.
 	PreVote bool
+	// ElectionPriority is the initial election priority of the member.
+	ElectionPriority uint
+	// Witness is true if the member only votes and persists the log.
+	Witness bool
+	// LeaderPlacement constrains the metadata of the members that may stay
+	// leader; a leader it does not allow transfers leadership away. The
+	// leader replicates it to the cluster as a cluster setting.
+	LeaderPlacement LeaderPlacement
 
 	// Logger logs server-side operations.
 	// If not nil, it disables "capnslog" and uses the given logger.
@@ -194,7 +332,7 @@ func (c *ServerConfig) VerifyJoinExisting() error {
 // hasLocalMember checks that the cluster at least contains the local server.
 func (c *ServerConfig) hasLocalMember() error {
 	if urls := c.InitialPeerURLsMap[c.Name]; urls == nil {
-		return fmt.Errorf("couldn't find local name %q in the initial cluster configuration", c.Name)
+		return &UnknownMemberError{Name: c.Name}
 	}
 	return nil
 }
@@ -276,8 +414,20 @@ func (c *ServerConfig) electionTimeout() time.Duration {
 }
 
 func (c *ServerConfig) peerDialTimeout() time.Duration {
+	if c.PeerDialTimeout != 0 {
+		return c.PeerDialTimeout
+	}
 	// 1s for queue wait and election timeout
-	return time.Second + time.Duration(c.ElectionTicks*int(c.TickMs))*time.Millisecond
+	return time.Second + c.electionTimeout()
+}
+
+func (c *ServerConfig) peerRequestTimeout() time.Duration {
+	if c.PeerRequestTimeout != 0 {
+		return c.PeerRequestTimeout
+	}
+	// 5s for the peer to persist and answer, as the connections time
+	// out, and election timeout for the dial
+	return rafthttp.ConnWriteTimeout + c.electionTimeout()
 }
 
 func checkDuplicateURL(urlsmap types.URLsMap) bool {
@@ -302,3 +452,15 @@ func (c *ServerConfig) bootstrapTimeout() time.Duration {
 }
 
 func (c *ServerConfig) backendPath() string { return filepath.Join(c.SnapDir(), "db") }
+
+// walPreallocateBytes returns the size preallocated for each WAL segment
+// file, 0 if preallocation is disabled.
+func (c *ServerConfig) walPreallocateBytes() int64 {
+	switch {
+	case c.WALPreallocateBytes == 0:
+		return wal.SegmentSizeBytes
+	case c.WALPreallocateBytes < 0:
+		return 0
+	}
+	return c.WALPreallocateBytes
+}
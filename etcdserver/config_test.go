@@ -17,8 +17,10 @@ package etcdserver
 import (
 	"net/url"
 	"testing"
+	"time"
 
 	"go.etcd.io/etcd/pkg/types"
+	"go.etcd.io/etcd/wal"
 
 	"go.uber.org/zap"
 )
@@ -162,6 +164,24 @@ func TestConfigVerifyLocalMember(t *testing.T) {
 	}
 }
 
+func TestConfigVerifyUnknownMember(t *testing.T) {
+	cluster, err := types.NewURLsMap("node2=http://127.0.0.1:2380")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := ServerConfig{
+		Name:               "node1",
+		InitialPeerURLsMap: cluster,
+		Logger:             zap.NewExample(),
+	}
+	for i, verify := range []func() error{cfg.VerifyBootstrap, cfg.VerifyJoinExisting} {
+		err := verify()
+		if uerr, ok := err.(*UnknownMemberError); !ok || uerr.Name != "node1" {
+			t.Errorf("#%d: err = %v, want an UnknownMemberError for node1", i, err)
+		}
+	}
+}
+
 func TestSnapDir(t *testing.T) {
 	tests := map[string]string{
 		"/":            "/member/snap",
@@ -210,3 +230,45 @@ func TestShouldDiscover(t *testing.T) {
 		}
 	}
 }
+
+func TestPeerTimeouts(t *testing.T) {
+	tests := []struct {
+		dial, req   time.Duration
+		wdial, wreq time.Duration
+	}{
+		// derived from the 1s election timeout
+		{0, 0, 2 * time.Second, 6 * time.Second},
+		{time.Second, 3 * time.Second, time.Second, 3 * time.Second},
+	}
+	for i, tt := range tests {
+		cfg := ServerConfig{
+			TickMs:             100,
+			ElectionTicks:      10,
+			PeerDialTimeout:    tt.dial,
+			PeerRequestTimeout: tt.req,
+		}
+		if g := cfg.peerDialTimeout(); g != tt.wdial {
+			t.Errorf("#%d: peerDialTimeout() = %v, want %v", i, g, tt.wdial)
+		}
+		if g := cfg.peerRequestTimeout(); g != tt.wreq {
+			t.Errorf("#%d: peerRequestTimeout() = %v, want %v", i, g, tt.wreq)
+		}
+	}
+}
+
+func TestWALPreallocateBytes(t *testing.T) {
+	tests := []struct {
+		n, w int64
+	}{
+		// the zero value of ServerConfig preallocates whole segments
+		{0, wal.SegmentSizeBytes},
+		{-1, 0},
+		{4096, 4096},
+	}
+	for i, tt := range tests {
+		c := &ServerConfig{WALPreallocateBytes: tt.n}
+		if g := c.walPreallocateBytes(); g != tt.w {
+			t.Errorf("#%d: preallocate bytes = %d, want %d", i, g, tt.w)
+		}
+	}
+}
@@ -202,6 +202,7 @@ func (s *EtcdServer) checkHashKV() error {
 			return
 		}
 		alarmed = true
+		corruptionsDetected.Inc()
 		a := &pb.AlarmRequest{
 			MemberID: id,
 			Action:   pb.AlarmRequest_ACTIVATE,
@@ -0,0 +1,103 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdserver
+
+import (
+	"io/ioutil"
+	"time"
+
+	"go.etcd.io/etcd/pkg/fileutil"
+
+	"go.uber.org/zap"
+)
+
+// diskUsageInterval is the interval the sizes of the WAL and snapshot
+// files, and the space left on their filesystems, are reported at.
+const diskUsageInterval = 30 * time.Second
+
+// dirUsage returns the size of each regular file of dir, and their total.
+func dirUsage(dir string) (map[string]int64, int64, error) {
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, 0, err
+	}
+	sizes := make(map[string]int64, len(fis))
+	var total int64
+	for _, fi := range fis {
+		if !fi.Mode().IsRegular() {
+			continue
+		}
+		sizes[fi.Name()] = fi.Size()
+		total += fi.Size()
+	}
+	return sizes, total, nil
+}
+
+// monitorDiskUsage reports the sizes of the WAL and snapshot files and
+// the space left on the filesystems of the data and WAL directories.
+func (s *EtcdServer) monitorDiskUsage() {
+	for {
+		s.reportDiskUsage()
+		select {
+		case <-time.After(diskUsageInterval):
+		case <-s.stopping:
+			return
+		}
+	}
+}
+
+func (s *EtcdServer) reportDiskUsage() {
+	lg := s.getLogger()
+	if sizes, total, err := dirUsage(s.Cfg.WALDir()); err == nil {
+		// files purged since the last report are no longer reported
+		walFileBytes.Reset()
+		for name, size := range sizes {
+			walFileBytes.WithLabelValues(name).Set(float64(size))
+		}
+		walTotalBytes.Set(float64(total))
+	} else if lg != nil {
+		lg.Warn("failed to get WAL disk usage", zap.String("wal-dir", s.Cfg.WALDir()), zap.Error(err))
+	} else {
+		plog.Warningf("failed to get WAL disk usage of %s (%v)", s.Cfg.WALDir(), err)
+	}
+	if sizes, total, err := dirUsage(s.Cfg.SnapDir()); err == nil {
+		snapshotFileBytes.Reset()
+		for name, size := range sizes {
+			snapshotFileBytes.WithLabelValues(name).Set(float64(size))
+		}
+		snapshotTotalBytes.Set(float64(total))
+	} else if lg != nil {
+		lg.Warn("failed to get snapshot disk usage", zap.String("snap-dir", s.Cfg.SnapDir()), zap.Error(err))
+	} else {
+		plog.Warningf("failed to get snapshot disk usage of %s (%v)", s.Cfg.SnapDir(), err)
+	}
+
+	dirs := map[string]string{"data": s.Cfg.DataDir}
+	if s.Cfg.DedicatedWALDir != "" {
+		dirs["wal"] = s.Cfg.DedicatedWALDir
+	}
+	for label, dir := range dirs {
+		avail, size, err := fileutil.DiskSpace(dir)
+		if err != nil {
+			// not supported on this platform, or the directory is gone
+			if lg != nil {
+				lg.Debug("failed to get disk space", zap.String("dir", dir), zap.Error(err))
+			}
+			continue
+		}
+		diskAvailableBytes.WithLabelValues(label).Set(float64(avail))
+		diskSizeBytes.WithLabelValues(label).Set(float64(size))
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdserver
+
+import (
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// diskWatchdogInterval is the period over which disk latencies are averaged.
+	diskWatchdogInterval = time.Second
+	// diskDegradedIntervals is the number of consecutive periods the average
+	// latency must stay above, or below, the threshold to change the disk state.
+	diskDegradedIntervals = 5
+)
+
+// commitStater reports the commits done by a backend.
+type commitStater interface {
+	Commits() int64
+	CommitDuration() time.Duration
+}
+
+// diskWatchdog marks the member degraded while the average latency of WAL
+// saves or backend commits stays above a threshold.
+type diskWatchdog struct {
+	// walSaves and walNanos are used with atomic operations so they must
+	// be 64-bit aligned, otherwise 32-bit tests will crash
+	walSaves int64
+	walNanos int64
+
+	threshold time.Duration
+	degraded  int32
+
+	// the fields below are only accessed by the monitoring goroutine
+	prevWALSaves, prevWALNanos int64
+	prevCommits                int64
+	prevCommitDuration         time.Duration
+	// slow and fast count the consecutive periods above and below threshold
+	slow, fast int
+}
+
+func newDiskWatchdog(threshold time.Duration) *diskWatchdog {
+	if threshold <= 0 {
+		return nil
+	}
+	return &diskWatchdog{threshold: threshold}
+}
+
+// observeWAL records the latency of a WAL save that synced entries.
+func (d *diskWatchdog) observeWAL(took time.Duration) {
+	if d == nil {
+		return
+	}
+	atomic.AddInt64(&d.walSaves, 1)
+	atomic.AddInt64(&d.walNanos, int64(took))
+}
+
+func (d *diskWatchdog) isDegraded() bool {
+	return d != nil && atomic.LoadInt32(&d.degraded) == 1
+}
+
+// update averages the latencies observed since the last update and returns
+// true if it changed the disk state. Periods without disk writes are not
+// counted.
+func (d *diskWatchdog) update(commits int64, commitDuration time.Duration) (walAvg, commitAvg time.Duration, changed bool) {
+	saves, nanos := atomic.LoadInt64(&d.walSaves), atomic.LoadInt64(&d.walNanos)
+	if n := saves - d.prevWALSaves; n > 0 {
+		walAvg = time.Duration((nanos - d.prevWALNanos) / n)
+	}
+	d.prevWALSaves, d.prevWALNanos = saves, nanos
+
+	// the backend is replaced when a snapshot is restored, restarting its counters
+	if n := commits - d.prevCommits; n > 0 && commitDuration >= d.prevCommitDuration {
+		commitAvg = (commitDuration - d.prevCommitDuration) / time.Duration(n)
+	}
+	d.prevCommits, d.prevCommitDuration = commits, commitDuration
+
+	switch {
+	case walAvg > d.threshold || commitAvg > d.threshold:
+		d.slow, d.fast = d.slow+1, 0
+	case walAvg > 0 || commitAvg > 0:
+		d.slow, d.fast = 0, d.fast+1
+	}
+
+	degraded := d.isDegraded()
+	if !degraded && d.slow >= diskDegradedIntervals {
+		atomic.StoreInt32(&d.degraded, 1)
+		return walAvg, commitAvg, true
+	}
+	if degraded && d.fast >= diskDegradedIntervals {
+		atomic.StoreInt32(&d.degraded, 0)
+		return walAvg, commitAvg, true
+	}
+	return walAvg, commitAvg, false
+}
+
+// DiskDegraded returns true if the disk latency of the member has stayed
+// above the configured threshold. It is always false when no threshold is
+// configured.
+func (s *EtcdServer) DiskDegraded() bool { return s.dw.isDegraded() }
+
+// monitorDisk periodically updates the disk state of the member from the
+// latencies of WAL saves and backend commits.
+func (s *EtcdServer) monitorDisk() {
+	if s.dw == nil {
+		return
+	}
+	for {
+		select {
+		case <-time.After(diskWatchdogInterval):
+		case <-s.stopping:
+			return
+		}
+
+		var (
+			commits        int64
+			commitDuration time.Duration
+		)
+		if cs, ok := s.Backend().(commitStater); ok {
+			commits, commitDuration = cs.Commits(), cs.CommitDuration()
+		}
+		walAvg, commitAvg, changed := s.dw.update(commits, commitDuration)
+		if changed {
+			s.reportDiskState(walAvg, commitAvg)
+		}
+
+		if s.Cfg.DiskDegradedTransferLeadership && s.dw.isDegraded() && s.isLeader() {
+			if err := s.TransferLeadership(); err != nil {
+				if lg := s.getLogger(); lg != nil {
+					lg.Warn("failed to transfer leadership from degraded member", zap.Error(err))
+				} else {
+					plog.Warningf("failed to transfer leadership from degraded member %s (%v)", s.ID(), err)
+				}
+			}
+		}
+	}
+}
+
+func (s *EtcdServer) reportDiskState(walAvg, commitAvg time.Duration) {
+	lg := s.getLogger()
+	if !s.dw.isDegraded() {
+		diskDegraded.Set(0)
+		if lg != nil {
+			lg.Info(
+				"disk latency recovered",
+				zap.String("local-member-id", s.ID().String()),
+				zap.Duration("wal-save-latency", walAvg),
+				zap.Duration("backend-commit-latency", commitAvg),
+				zap.Duration("threshold", s.dw.threshold),
+			)
+		} else {
+			plog.Infof("disk latency of %s recovered below %v", s.ID(), s.dw.threshold)
+		}
+		return
+	}
+
+	diskDegraded.Set(1)
+	if lg != nil {
+		lg.Warn(
+			"disk latency is above threshold; member is degraded",
+			zap.String("local-member-id", s.ID().String()),
+			zap.Duration("wal-save-latency", walAvg),
+			zap.Duration("backend-commit-latency", commitAvg),
+			zap.Duration("threshold", s.dw.threshold),
+			zap.Duration("sustained", diskDegradedIntervals*diskWatchdogInterval),
+		)
+	} else {
+		plog.Warningf("disk latency of %s is above %v (wal save %v, backend commit %v); member is degraded", s.ID(), s.dw.threshold, walAvg, commitAvg)
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdserver
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDiskWatchdog(t *testing.T) {
+	if dw := newDiskWatchdog(0); dw != nil || dw.isDegraded() {
+		t.Fatalf("watchdog = %+v, want disabled", dw)
+	}
+
+	dw := newDiskWatchdog(100 * time.Millisecond)
+	var (
+		commits        int64
+		commitDuration time.Duration
+	)
+	// period adds n commits and WAL saves taking took each
+	period := func(n int, took time.Duration) bool {
+		for i := 0; i < n; i++ {
+			dw.observeWAL(took)
+		}
+		commits += int64(n)
+		commitDuration += time.Duration(n) * took
+		_, _, changed := dw.update(commits, commitDuration)
+		return changed
+	}
+
+	for i := 0; i < diskDegradedIntervals-1; i++ {
+		if period(3, time.Second) {
+			t.Fatalf("#%d: degraded before latency was sustained", i)
+		}
+	}
+	// idle periods neither degrade nor recover the disk
+	if period(0, 0) || dw.isDegraded() {
+		t.Fatal("idle period changed disk state")
+	}
+	if !period(1, 200*time.Millisecond) || !dw.isDegraded() {
+		t.Fatal("disk not degraded after sustained latency")
+	}
+
+	// the backend is replaced on snapshot restore
+	commits, commitDuration = 0, 0
+	for i := 0; i < diskDegradedIntervals-1; i++ {
+		if period(5, time.Millisecond) {
+			t.Fatalf("#%d: recovered before latency was sustained", i)
+		}
+	}
+	if period(1, time.Second) || !dw.isDegraded() {
+		t.Fatal("disk recovered after a slow period")
+	}
+	for i := 0; i < diskDegradedIntervals-1; i++ {
+		period(5, time.Millisecond)
+	}
+	if !period(5, time.Millisecond) || dw.isDegraded() {
+		t.Fatal("disk still degraded after sustained low latency")
+	}
+}
@@ -29,14 +29,27 @@ var (
 	ErrTimeoutLeaderTransfer      = errors.New("etcdserver: request timed out, leader transfer took too long")
 	ErrLeaderChanged              = errors.New("etcdserver: leader changed")
 	ErrNotEnoughStartedMembers    = errors.New("etcdserver: re-configuration failed due to not enough started members")
+	ErrMemberNotDead              = errors.New("etcdserver: member was seen too recently to be removed as dead")
 	ErrNoLeader                   = errors.New("etcdserver: no leader")
 	ErrNotLeader                  = errors.New("etcdserver: not leader")
 	ErrRequestTooLarge            = errors.New("etcdserver: request is too large")
 	ErrNoSpace                    = errors.New("etcdserver: no space")
 	ErrTooManyRequests            = errors.New("etcdserver: too many requests")
+	ErrTooManyForwardedProposals  = errors.New("etcdserver: too many proposals forwarded to the leader")
 	ErrUnhealthy                  = errors.New("etcdserver: unhealthy cluster")
 	ErrKeyNotFound                = errors.New("etcdserver: key not found")
 	ErrCorrupt                    = errors.New("etcdserver: corrupt cluster")
+	ErrUnknownLogLevel            = errors.New("etcdserver: unknown log level")
+	ErrTombstonesDisabled         = errors.New("etcdserver: v2 tombstones are disabled")
+	ErrKeyWriteRateExceeded       = errors.New("etcdserver: key write rate exceeded")
+	ErrInvalidKeyRateLimit        = errors.New("etcdserver: invalid key rate limit")
+	ErrApplyAuditDisabled         = errors.New("etcdserver: apply audit is disabled")
+	ErrKeyQuotaExceeded           = errors.New("etcdserver: key quota exceeded")
+	ErrInvalidKeyQuota            = errors.New("etcdserver: invalid key quota")
+	ErrOperationNotFound          = errors.New("etcdserver: operation not found")
+	ErrOperationNotCancelable     = errors.New("etcdserver: operation already proposed, not cancelable")
+	ErrFlightRecorderDisabled     = errors.New("etcdserver: flight recorder is disabled")
+	ErrMemoryLimitExceeded        = errors.New("etcdserver: memory limit exceeded")
 )
 
 type DiscoveryError struct {
@@ -47,3 +60,13 @@ type DiscoveryError struct {
 func (e DiscoveryError) Error() string {
 	return fmt.Sprintf("failed to %s discovery cluster (%v)", e.Op, e.Err)
 }
+
+// UnknownMemberError is returned when the local name is not found in the
+// initial cluster configuration.
+type UnknownMemberError struct {
+	Name string
+}
+
+func (e UnknownMemberError) Error() string {
+	return fmt.Sprintf("couldn't find local name %q in the initial cluster configuration", e.Name)
+}
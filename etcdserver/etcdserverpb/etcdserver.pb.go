@@ -2,109 +2,112 @@
 // source: etcdserver.proto
 
 /*
-	Package etcdserverpb is a generated protocol buffer package.
+Package etcdserverpb is a generated protocol buffer package.
 
-	It is generated from these files:
-		etcdserver.proto
-		raft_internal.proto
-		rpc.proto
+It is generated from these files:
 
-	It has these top-level messages:
-		Request
-		Metadata
-		RequestHeader
-		InternalRaftRequest
-		EmptyResponse
-		InternalAuthenticateRequest
-		ResponseHeader
-		RangeRequest
-		RangeResponse
-		PutRequest
-		PutResponse
-		DeleteRangeRequest
-		DeleteRangeResponse
-		RequestOp
-		ResponseOp
-		Compare
-		TxnRequest
-		TxnResponse
-		CompactionRequest
-		CompactionResponse
-		HashRequest
-		HashKVRequest
-		HashKVResponse
-		HashResponse
-		SnapshotRequest
-		SnapshotResponse
-		WatchRequest
-		WatchCreateRequest
-		WatchCancelRequest
-		WatchProgressRequest
-		WatchResponse
-		LeaseGrantRequest
-		LeaseGrantResponse
-		LeaseRevokeRequest
-		LeaseRevokeResponse
-		LeaseCheckpoint
-		LeaseCheckpointRequest
-		LeaseCheckpointResponse
-		LeaseKeepAliveRequest
-		LeaseKeepAliveResponse
-		LeaseTimeToLiveRequest
-		LeaseTimeToLiveResponse
-		LeaseLeasesRequest
-		LeaseStatus
-		LeaseLeasesResponse
-		Member
-		MemberAddRequest
-		MemberAddResponse
-		MemberRemoveRequest
-		MemberRemoveResponse
-		MemberUpdateRequest
-		MemberUpdateResponse
-		MemberListRequest
-		MemberListResponse
-		DefragmentRequest
-		DefragmentResponse
-		MoveLeaderRequest
-		MoveLeaderResponse
-		AlarmRequest
-		AlarmMember
-		AlarmResponse
-		StatusRequest
-		StatusResponse
-		AuthEnableRequest
-		AuthDisableRequest
-		AuthenticateRequest
-		AuthUserAddRequest
-		AuthUserGetRequest
-		AuthUserDeleteRequest
-		AuthUserChangePasswordRequest
-		AuthUserGrantRoleRequest
-		AuthUserRevokeRoleRequest
-		AuthRoleAddRequest
-		AuthRoleGetRequest
-		AuthUserListRequest
-		AuthRoleListRequest
-		AuthRoleDeleteRequest
-		AuthRoleGrantPermissionRequest
-		AuthRoleRevokePermissionRequest
-		AuthEnableResponse
-		AuthDisableResponse
-		AuthenticateResponse
-		AuthUserAddResponse
-		AuthUserGetResponse
-		AuthUserDeleteResponse
-		AuthUserChangePasswordResponse
-		AuthUserGrantRoleResponse
-		AuthUserRevokeRoleResponse
-		AuthRoleAddResponse
-		AuthRoleGetResponse
-		AuthRoleListResponse
-		AuthUserListResponse
-		AuthRoleDeleteResponse
-		AuthRoleGrantPermissionResponse
-		AuthRoleRevokePermissionResponse
+	etcdserver.proto
+	raft_internal.proto
+	rpc.proto
+
+It has these top-level messages:
+
+	Request
+	Metadata
+	ExpiredKey
+	RequestHeader
+	InternalRaftRequest
+	EmptyResponse
+	InternalAuthenticateRequest
+	ResponseHeader
+	RangeRequest
+	RangeResponse
+	PutRequest
+	PutResponse
+	DeleteRangeRequest
+	DeleteRangeResponse
+	RequestOp
+	ResponseOp
+	Compare
+	TxnRequest
+	TxnResponse
+	CompactionRequest
+	CompactionResponse
+	HashRequest
+	HashKVRequest
+	HashKVResponse
+	HashResponse
+	SnapshotRequest
+	SnapshotResponse
+	WatchRequest
+	WatchCreateRequest
+	WatchCancelRequest
+	WatchProgressRequest
+	WatchResponse
+	LeaseGrantRequest
+	LeaseGrantResponse
+	LeaseRevokeRequest
+	LeaseRevokeResponse
+	LeaseCheckpoint
+	LeaseCheckpointRequest
+	LeaseCheckpointResponse
+	LeaseKeepAliveRequest
+	LeaseKeepAliveResponse
+	LeaseTimeToLiveRequest
+	LeaseTimeToLiveResponse
+	LeaseLeasesRequest
+	LeaseStatus
+	LeaseLeasesResponse
+	Member
+	MemberAddRequest
+	MemberAddResponse
+	MemberRemoveRequest
+	MemberRemoveResponse
+	MemberUpdateRequest
+	MemberUpdateResponse
+	MemberListRequest
+	MemberListResponse
+	DefragmentRequest
+	DefragmentResponse
+	MoveLeaderRequest
+	MoveLeaderResponse
+	AlarmRequest
+	AlarmMember
+	AlarmResponse
+	StatusRequest
+	StatusResponse
+	AuthEnableRequest
+	AuthDisableRequest
+	AuthenticateRequest
+	AuthUserAddRequest
+	AuthUserGetRequest
+	AuthUserDeleteRequest
+	AuthUserChangePasswordRequest
+	AuthUserGrantRoleRequest
+	AuthUserRevokeRoleRequest
+	AuthRoleAddRequest
+	AuthRoleGetRequest
+	AuthUserListRequest
+	AuthRoleListRequest
+	AuthRoleDeleteRequest
+	AuthRoleGrantPermissionRequest
+	AuthRoleRevokePermissionRequest
+	AuthEnableResponse
+	AuthDisableResponse
+	AuthenticateResponse
+	AuthUserAddResponse
+	AuthUserGetResponse
+	AuthUserDeleteResponse
+	AuthUserChangePasswordResponse
+	AuthUserGrantRoleResponse
+	AuthUserRevokeRoleResponse
+	AuthRoleAddResponse
+	AuthRoleGetResponse
+	AuthRoleListResponse
+	AuthUserListResponse
+	AuthRoleDeleteResponse
+	AuthRoleGrantPermissionResponse
+	AuthRoleRevokePermissionResponse
 */
 package etcdserverpb
 
@@ -132,24 +135,31 @@ var _ = math.Inf
 const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
 
 type Request struct {
-	ID               uint64 `protobuf:"varint,1,opt,name=ID" json:"ID"`
-	Method           string `protobuf:"bytes,2,opt,name=Method" json:"Method"`
-	Path             string `protobuf:"bytes,3,opt,name=Path" json:"Path"`
-	Val              string `protobuf:"bytes,4,opt,name=Val" json:"Val"`
-	Dir              bool   `protobuf:"varint,5,opt,name=Dir" json:"Dir"`
-	PrevValue        string `protobuf:"bytes,6,opt,name=PrevValue" json:"PrevValue"`
-	PrevIndex        uint64 `protobuf:"varint,7,opt,name=PrevIndex" json:"PrevIndex"`
-	PrevExist        *bool  `protobuf:"varint,8,opt,name=PrevExist" json:"PrevExist,omitempty"`
-	Expiration       int64  `protobuf:"varint,9,opt,name=Expiration" json:"Expiration"`
-	Wait             bool   `protobuf:"varint,10,opt,name=Wait" json:"Wait"`
-	Since            uint64 `protobuf:"varint,11,opt,name=Since" json:"Since"`
-	Recursive        bool   `protobuf:"varint,12,opt,name=Recursive" json:"Recursive"`
-	Sorted           bool   `protobuf:"varint,13,opt,name=Sorted" json:"Sorted"`
-	Quorum           bool   `protobuf:"varint,14,opt,name=Quorum" json:"Quorum"`
-	Time             int64  `protobuf:"varint,15,opt,name=Time" json:"Time"`
-	Stream           bool   `protobuf:"varint,16,opt,name=Stream" json:"Stream"`
-	Refresh          *bool  `protobuf:"varint,17,opt,name=Refresh" json:"Refresh,omitempty"`
-	XXX_unrecognized []byte `json:"-"`
+	ID                uint64       `protobuf:"varint,1,opt,name=ID" json:"ID"`
+	Method            string       `protobuf:"bytes,2,opt,name=Method" json:"Method"`
+	Path              string       `protobuf:"bytes,3,opt,name=Path" json:"Path"`
+	Val               string       `protobuf:"bytes,4,opt,name=Val" json:"Val"`
+	Dir               bool         `protobuf:"varint,5,opt,name=Dir" json:"Dir"`
+	PrevValue         string       `protobuf:"bytes,6,opt,name=PrevValue" json:"PrevValue"`
+	PrevIndex         uint64       `protobuf:"varint,7,opt,name=PrevIndex" json:"PrevIndex"`
+	PrevExist         *bool        `protobuf:"varint,8,opt,name=PrevExist" json:"PrevExist,omitempty"`
+	Expiration        int64        `protobuf:"varint,9,opt,name=Expiration" json:"Expiration"`
+	Wait              bool         `protobuf:"varint,10,opt,name=Wait" json:"Wait"`
+	Since             uint64       `protobuf:"varint,11,opt,name=Since" json:"Since"`
+	Recursive         bool         `protobuf:"varint,12,opt,name=Recursive" json:"Recursive"`
+	Sorted            bool         `protobuf:"varint,13,opt,name=Sorted" json:"Sorted"`
+	Quorum            bool         `protobuf:"varint,14,opt,name=Quorum" json:"Quorum"`
+	Time              int64        `protobuf:"varint,15,opt,name=Time" json:"Time"`
+	Stream            bool         `protobuf:"varint,16,opt,name=Stream" json:"Stream"`
+	Refresh           *bool        `protobuf:"varint,17,opt,name=Refresh" json:"Refresh,omitempty"`
+	WaitExisting      bool         `protobuf:"varint,18,opt,name=WaitExisting" json:"WaitExisting"`
+	ClientRequestID   string       `protobuf:"bytes,19,opt,name=ClientRequestID" json:"ClientRequestID"`
+	ClientRequestUser string       `protobuf:"bytes,20,opt,name=ClientRequestUser" json:"ClientRequestUser"`
+	ClientRequestTime int64        `protobuf:"varint,21,opt,name=ClientRequestTime" json:"ClientRequestTime"`
+	NoImplicitDirs    bool         `protobuf:"varint,22,opt,name=NoImplicitDirs" json:"NoImplicitDirs"`
+	ExpiredKeys       []ExpiredKey `protobuf:"bytes,23,rep,name=ExpiredKeys" json:"ExpiredKeys"`
+	ListsExpiredKeys  bool         `protobuf:"varint,24,opt,name=ListsExpiredKeys" json:"ListsExpiredKeys"`
+	XXX_unrecognized  []byte       `json:"-"`
 }
 
 func (m *Request) Reset()                    { *m = Request{} }
@@ -168,9 +178,21 @@ func (m *Metadata) String() string            { return proto.CompactTextString(m
 func (*Metadata) ProtoMessage()               {}
 func (*Metadata) Descriptor() ([]byte, []int) { return fileDescriptorEtcdserver, []int{1} }
 
+type ExpiredKey struct {
+	Key              string `protobuf:"bytes,1,opt,name=Key" json:"Key"`
+	ModifiedIndex    uint64 `protobuf:"varint,2,opt,name=ModifiedIndex" json:"ModifiedIndex"`
+	XXX_unrecognized []byte `json:"-"`
+}
+
+func (m *ExpiredKey) Reset()                    { *m = ExpiredKey{} }
+func (m *ExpiredKey) String() string            { return proto.CompactTextString(m) }
+func (*ExpiredKey) ProtoMessage()               {}
+func (*ExpiredKey) Descriptor() ([]byte, []int) { return fileDescriptorEtcdserver, []int{2} }
+
 func init() {
 	proto.RegisterType((*Request)(nil), "etcdserverpb.Request")
 	proto.RegisterType((*Metadata)(nil), "etcdserverpb.Metadata")
+	proto.RegisterType((*ExpiredKey)(nil), "etcdserverpb.ExpiredKey")
 }
 func (m *Request) Marshal() (dAtA []byte, err error) {
 	size := m.Size()
@@ -290,6 +312,67 @@ func (m *Request) MarshalTo(dAtA []byte) (int, error) {
 		}
 		i++
 	}
+	dAtA[i] = 0x90
+	i++
+	dAtA[i] = 0x1
+	i++
+	if m.WaitExisting {
+		dAtA[i] = 1
+	} else {
+		dAtA[i] = 0
+	}
+	i++
+	dAtA[i] = 0x9a
+	i++
+	dAtA[i] = 0x1
+	i++
+	i = encodeVarintEtcdserver(dAtA, i, uint64(len(m.ClientRequestID)))
+	i += copy(dAtA[i:], m.ClientRequestID)
+	dAtA[i] = 0xa2
+	i++
+	dAtA[i] = 0x1
+	i++
+	i = encodeVarintEtcdserver(dAtA, i, uint64(len(m.ClientRequestUser)))
+	i += copy(dAtA[i:], m.ClientRequestUser)
+	dAtA[i] = 0xa8
+	i++
+	dAtA[i] = 0x1
+	i++
+	i = encodeVarintEtcdserver(dAtA, i, uint64(m.ClientRequestTime))
+	dAtA[i] = 0xb0
+	i++
+	dAtA[i] = 0x1
+	i++
+	if m.NoImplicitDirs {
+		dAtA[i] = 1
+	} else {
+		dAtA[i] = 0
+	}
+	i++
+	if len(m.ExpiredKeys) > 0 {
+		for _, msg := range m.ExpiredKeys {
+			dAtA[i] = 0xba
+			i++
+			dAtA[i] = 0x1
+			i++
+			i = encodeVarintEtcdserver(dAtA, i, uint64(msg.Size()))
+			n, err := msg.MarshalTo(dAtA[i:])
+			if err != nil {
+				return 0, err
+			}
+			i += n
+		}
+	}
+	dAtA[i] = 0xc0
+	i++
+	dAtA[i] = 0x1
+	i++
+	if m.ListsExpiredKeys {
+		dAtA[i] = 1
+	} else {
+		dAtA[i] = 0
+	}
+	i++
 	if m.XXX_unrecognized != nil {
 		i += copy(dAtA[i:], m.XXX_unrecognized)
 	}
@@ -323,6 +406,34 @@ func (m *Metadata) MarshalTo(dAtA []byte) (int, error) {
 	return i, nil
 }
 
+func (m *ExpiredKey) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *ExpiredKey) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	dAtA[i] = 0xa
+	i++
+	i = encodeVarintEtcdserver(dAtA, i, uint64(len(m.Key)))
+	i += copy(dAtA[i:], m.Key)
+	dAtA[i] = 0x10
+	i++
+	i = encodeVarintEtcdserver(dAtA, i, uint64(m.ModifiedIndex))
+	if m.XXX_unrecognized != nil {
+		i += copy(dAtA[i:], m.XXX_unrecognized)
+	}
+	return i, nil
+}
+
 func encodeVarintEtcdserver(dAtA []byte, offset int, v uint64) int {
 	for v >= 1<<7 {
 		dAtA[offset] = uint8(v&0x7f | 0x80)
@@ -360,6 +471,20 @@ func (m *Request) Size() (n int) {
 	if m.Refresh != nil {
 		n += 3
 	}
+	n += 3
+	l = len(m.ClientRequestID)
+	n += 2 + l + sovEtcdserver(uint64(l))
+	l = len(m.ClientRequestUser)
+	n += 2 + l + sovEtcdserver(uint64(l))
+	n += 2 + sovEtcdserver(uint64(m.ClientRequestTime))
+	n += 3
+	if len(m.ExpiredKeys) > 0 {
+		for _, e := range m.ExpiredKeys {
+			l = e.Size()
+			n += 2 + l + sovEtcdserver(uint64(l))
+		}
+	}
+	n += 3
 	if m.XXX_unrecognized != nil {
 		n += len(m.XXX_unrecognized)
 	}
@@ -377,6 +502,18 @@ func (m *Metadata) Size() (n int) {
 	return n
 }
 
+func (m *ExpiredKey) Size() (n int) {
+	var l int
+	_ = l
+	l = len(m.Key)
+	n += 1 + l + sovEtcdserver(uint64(l))
+	n += 1 + sovEtcdserver(uint64(m.ModifiedIndex))
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
 func sovEtcdserver(x uint64) (n int) {
 	for {
 		n++
@@ -792,6 +929,174 @@ func (m *Request) Unmarshal(dAtA []byte) error {
 			}
 			b := bool(v != 0)
 			m.Refresh = &b
+		case 18:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field WaitExisting", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowEtcdserver
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.WaitExisting = bool(v != 0)
+		case 19:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ClientRequestID", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowEtcdserver
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthEtcdserver
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ClientRequestID = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 20:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ClientRequestUser", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowEtcdserver
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthEtcdserver
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ClientRequestUser = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 21:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ClientRequestTime", wireType)
+			}
+			m.ClientRequestTime = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowEtcdserver
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.ClientRequestTime |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 22:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field NoImplicitDirs", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowEtcdserver
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.NoImplicitDirs = bool(v != 0)
+		case 23:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ExpiredKeys", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowEtcdserver
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthEtcdserver
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ExpiredKeys = append(m.ExpiredKeys, ExpiredKey{})
+			if err := m.ExpiredKeys[len(m.ExpiredKeys)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 24:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ListsExpiredKeys", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowEtcdserver
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.ListsExpiredKeys = bool(v != 0)
 		default:
 			iNdEx = preIndex
 			skippy, err := skipEtcdserver(dAtA[iNdEx:])
@@ -903,6 +1208,105 @@ func (m *Metadata) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
+func (m *ExpiredKey) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowEtcdserver
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: ExpiredKey: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: ExpiredKey: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Key", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowEtcdserver
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthEtcdserver
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Key = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ModifiedIndex", wireType)
+			}
+			m.ModifiedIndex = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowEtcdserver
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.ModifiedIndex |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipEtcdserver(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthEtcdserver
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
 func skipEtcdserver(dAtA []byte) (n int, err error) {
 	l := len(dAtA)
 	iNdEx := 0
@@ -1011,29 +1415,38 @@ var (
 func init() { proto.RegisterFile("etcdserver.proto", fileDescriptorEtcdserver) }
 
 var fileDescriptorEtcdserver = []byte{
-	// 380 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x5c, 0xd2, 0xdd, 0x6e, 0xda, 0x30,
-	0x14, 0x07, 0x70, 0x0c, 0xe1, 0xcb, 0x63, 0x1b, 0xb3, 0xd0, 0x74, 0x84, 0xa6, 0x2c, 0x42, 0xbb,
-	0xc8, 0xd5, 0xf6, 0x0e, 0x2c, 0x5c, 0x44, 0x2a, 0x15, 0x0d, 0x15, 0xbd, 0x76, 0xc9, 0x29, 0x58,
-	0x02, 0x4c, 0x1d, 0x07, 0xf1, 0x06, 0x7d, 0x85, 0x3e, 0x12, 0x97, 0x7d, 0x82, 0xaa, 0xa5, 0x2f,
-	0x52, 0x39, 0x24, 0xc4, 0xed, 0x5d, 0xf4, 0xfb, 0x9f, 0x1c, 0x1f, 0x7f, 0xd0, 0x2e, 0xea, 0x79,
-	0x9c, 0xa0, 0xda, 0xa1, 0xfa, 0xbb, 0x55, 0x52, 0x4b, 0xd6, 0x29, 0x65, 0x7b, 0xdb, 0xef, 0x2d,
-	0xe4, 0x42, 0x66, 0xc1, 0x3f, 0xf3, 0x75, 0xaa, 0x19, 0x3c, 0x38, 0xb4, 0x19, 0xe1, 0x7d, 0x8a,
-	0x89, 0x66, 0x3d, 0x5a, 0x0d, 0x03, 0x20, 0x1e, 0xf1, 0x9d, 0xa1, 0x73, 0x78, 0xfe, 0x5d, 0x89,
-	0xaa, 0x61, 0xc0, 0x7e, 0xd1, 0xc6, 0x18, 0xf5, 0x52, 0xc6, 0x50, 0xf5, 0x88, 0xdf, 0xce, 0x93,
-	0xdc, 0x18, 0x50, 0x67, 0xc2, 0xf5, 0x12, 0x6a, 0x56, 0x96, 0x09, 0xfb, 0x49, 0x6b, 0x33, 0xbe,
-	0x02, 0xc7, 0x0a, 0x0c, 0x18, 0x0f, 0x84, 0x82, 0xba, 0x47, 0xfc, 0x56, 0xe1, 0x81, 0x50, 0x6c,
-	0x40, 0xdb, 0x13, 0x85, 0xbb, 0x19, 0x5f, 0xa5, 0x08, 0x0d, 0xeb, 0xaf, 0x92, 0x8b, 0x9a, 0x70,
-	0x13, 0xe3, 0x1e, 0x9a, 0xd6, 0xa0, 0x25, 0x17, 0x35, 0xa3, 0xbd, 0x48, 0x34, 0xb4, 0xce, 0xab,
-	0x90, 0xa8, 0x64, 0xf6, 0x87, 0xd2, 0xd1, 0x7e, 0x2b, 0x14, 0xd7, 0x42, 0x6e, 0xa0, 0xed, 0x11,
-	0xbf, 0x96, 0x37, 0xb2, 0xdc, 0xec, 0xed, 0x86, 0x0b, 0x0d, 0xd4, 0x1a, 0x35, 0x13, 0xd6, 0xa7,
-	0xf5, 0xa9, 0xd8, 0xcc, 0x11, 0xbe, 0x58, 0x33, 0x9c, 0xc8, 0xac, 0x1f, 0xe1, 0x3c, 0x55, 0x89,
-	0xd8, 0x21, 0x74, 0xac, 0x5f, 0x4b, 0x36, 0x67, 0x3a, 0x95, 0x4a, 0x63, 0x0c, 0x5f, 0xad, 0x82,
-	0xdc, 0x4c, 0x7a, 0x95, 0x4a, 0x95, 0xae, 0xe1, 0x9b, 0x9d, 0x9e, 0xcc, 0x4c, 0x75, 0x2d, 0xd6,
-	0x08, 0xdf, 0xad, 0xa9, 0x33, 0xc9, 0xba, 0x6a, 0x85, 0x7c, 0x0d, 0xdd, 0x0f, 0x5d, 0x33, 0x63,
-	0xae, 0xb9, 0xe8, 0x3b, 0x85, 0xc9, 0x12, 0x7e, 0x58, 0xa7, 0x52, 0xe0, 0xe0, 0x82, 0xb6, 0xc6,
-	0xa8, 0x79, 0xcc, 0x35, 0x37, 0x9d, 0x2e, 0x65, 0x8c, 0x9f, 0x5e, 0x43, 0x6e, 0x66, 0x87, 0xff,
-	0x57, 0x69, 0xa2, 0x51, 0x85, 0x41, 0xf6, 0x28, 0xce, 0xb7, 0x70, 0xe6, 0x61, 0xef, 0xf0, 0xea,
-	0x56, 0x0e, 0x47, 0x97, 0x3c, 0x1d, 0x5d, 0xf2, 0x72, 0x74, 0xc9, 0xe3, 0x9b, 0x5b, 0x79, 0x0f,
-	0x00, 0x00, 0xff, 0xff, 0xee, 0x40, 0xba, 0xd6, 0xa4, 0x02, 0x00, 0x00,
+	// 521 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x64, 0x93, 0xcf, 0x6e, 0xda, 0x40,
+	0x10, 0xc6, 0x31, 0x10, 0x02, 0x0b, 0x49, 0xc8, 0x96, 0xa6, 0xa3, 0xa8, 0x72, 0x11, 0xea, 0xc1,
+	0xaa, 0x2a, 0x5a, 0xe5, 0x09, 0xaa, 0xc4, 0x39, 0x58, 0x09, 0x11, 0x25, 0x6d, 0x7a, 0x76, 0xf1,
+	0x04, 0x56, 0x02, 0x2f, 0xdd, 0x5d, 0x23, 0xf2, 0x26, 0x7d, 0x24, 0x8e, 0x7d, 0x82, 0xaa, 0xa5,
+	0xc7, 0xbe, 0x44, 0xb5, 0xc6, 0xd4, 0x03, 0xdc, 0xac, 0xdf, 0x37, 0xf3, 0xed, 0xfc, 0xf1, 0xb0,
+	0x26, 0x9a, 0x61, 0xa4, 0x51, 0xcd, 0x51, 0x75, 0x67, 0x4a, 0x1a, 0xc9, 0x1b, 0x39, 0x99, 0x7d,
+	0x3d, 0x6f, 0x8d, 0xe4, 0x48, 0xa6, 0xc2, 0x3b, 0xfb, 0xb5, 0x8e, 0xe9, 0xfc, 0xad, 0xb0, 0xc3,
+	0x01, 0x7e, 0x4b, 0x50, 0x1b, 0xde, 0x62, 0xc5, 0xc0, 0x07, 0xa7, 0xed, 0x78, 0xe5, 0xcb, 0xf2,
+	0xf2, 0xe7, 0xab, 0xc2, 0xa0, 0x18, 0xf8, 0xfc, 0x25, 0xab, 0xf4, 0xd0, 0x8c, 0x65, 0x04, 0xc5,
+	0xb6, 0xe3, 0xd5, 0x32, 0x25, 0x63, 0x1c, 0x58, 0xb9, 0x1f, 0x9a, 0x31, 0x94, 0x88, 0x96, 0x12,
+	0x7e, 0xc6, 0x4a, 0x0f, 0xe1, 0x04, 0xca, 0x44, 0xb0, 0xc0, 0x72, 0x5f, 0x28, 0x38, 0x68, 0x3b,
+	0x5e, 0x75, 0xc3, 0x7d, 0xa1, 0x78, 0x87, 0xd5, 0xfa, 0x0a, 0xe7, 0x0f, 0xe1, 0x24, 0x41, 0xa8,
+	0x90, 0xac, 0x1c, 0x6f, 0x62, 0x82, 0x38, 0xc2, 0x05, 0x1c, 0x92, 0x42, 0x73, 0xbc, 0x89, 0xb9,
+	0x5e, 0x08, 0x6d, 0xa0, 0xfa, 0xff, 0x15, 0x67, 0x90, 0x63, 0xfe, 0x9a, 0xb1, 0xeb, 0xc5, 0x4c,
+	0xa8, 0xd0, 0x08, 0x19, 0x43, 0xad, 0xed, 0x78, 0xa5, 0xcc, 0x88, 0x70, 0xdb, 0xdb, 0x97, 0x50,
+	0x18, 0x60, 0xa4, 0xd4, 0x94, 0xf0, 0x73, 0x76, 0x70, 0x2f, 0xe2, 0x21, 0x42, 0x9d, 0xd4, 0xb0,
+	0x46, 0xf6, 0xfd, 0x01, 0x0e, 0x13, 0xa5, 0xc5, 0x1c, 0xa1, 0x41, 0x52, 0x73, 0x6c, 0x67, 0x7a,
+	0x2f, 0x95, 0xc1, 0x08, 0x8e, 0x48, 0x40, 0xc6, 0xac, 0xfa, 0x31, 0x91, 0x2a, 0x99, 0xc2, 0x31,
+	0x55, 0xd7, 0xcc, 0x56, 0xf5, 0x49, 0x4c, 0x11, 0x4e, 0x48, 0xd5, 0x29, 0x49, 0x5d, 0x8d, 0xc2,
+	0x70, 0x0a, 0xcd, 0x2d, 0xd7, 0x94, 0x71, 0xd7, 0x2e, 0xfa, 0x51, 0xa1, 0x1e, 0xc3, 0x29, 0x99,
+	0xca, 0x06, 0x72, 0x8f, 0x35, 0x6c, 0x6f, 0xe9, 0x80, 0x44, 0x3c, 0x02, 0x4e, 0x3c, 0xb6, 0x14,
+	0xde, 0x65, 0x27, 0x57, 0x13, 0x81, 0xb1, 0xc9, 0x7e, 0x9c, 0xc0, 0x87, 0x67, 0x64, 0x5f, 0xbb,
+	0x22, 0xbf, 0x60, 0xa7, 0x5b, 0xe8, 0xb3, 0x46, 0x05, 0x2d, 0x92, 0xb1, 0x2f, 0xef, 0xe5, 0xa4,
+	0x2d, 0x3f, 0x27, 0x2d, 0xef, 0xcb, 0xfc, 0x2d, 0x3b, 0xbe, 0x93, 0xc1, 0x74, 0x36, 0x11, 0x43,
+	0x61, 0x7c, 0xa1, 0x34, 0x9c, 0x91, 0x1e, 0x76, 0x34, 0xfe, 0x81, 0xd5, 0xd3, 0x5d, 0x63, 0x74,
+	0x83, 0x4f, 0x1a, 0x5e, 0xb4, 0x4b, 0x5e, 0xfd, 0x02, 0xba, 0xf4, 0x66, 0xba, 0x79, 0x40, 0x66,
+	0x42, 0x53, 0xf8, 0x7b, 0xd6, 0xbc, 0x15, 0xda, 0x68, 0x6a, 0x03, 0xe4, 0xc5, 0x3d, 0xb5, 0x73,
+	0xcb, 0xaa, 0x3d, 0x34, 0x61, 0x14, 0x9a, 0xd0, 0x6e, 0xeb, 0x4e, 0x46, 0xb8, 0x73, 0x71, 0x19,
+	0xb3, 0x7f, 0xd1, 0xd5, 0x24, 0xd1, 0x06, 0x55, 0xe0, 0x43, 0x91, 0x04, 0xe4, 0xb8, 0xd3, 0x67,
+	0x2c, 0x37, 0xb7, 0x77, 0x75, 0x83, 0x4f, 0xe0, 0x90, 0xb9, 0x5a, 0xc0, 0xdf, 0xb0, 0xa3, 0x9e,
+	0x8c, 0xc4, 0xa3, 0xc0, 0x68, 0x7d, 0x37, 0xd4, 0x6d, 0x5b, 0xba, 0x6c, 0x2d, 0x7f, 0xbb, 0x85,
+	0xe5, 0xca, 0x75, 0x7e, 0xac, 0x5c, 0xe7, 0xd7, 0xca, 0x75, 0xbe, 0xff, 0x71, 0x0b, 0xff, 0x06,
+	0x00, 0x71, 0x34, 0x90, 0x2e, 0x5a, 0x04, 0x00, 0x00,
 }
@@ -0,0 +1,114 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdserver
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// MutationRecord records a mutation a client requested from the member,
+// and its response.
+type MutationRecord struct {
+	Time time.Time `json:"time"`
+	// API is "v2" or "v3".
+	API string `json:"api"`
+	// Method is the v2 method, e.g. "PUT", or the v3 RPC, e.g. "Put".
+	Method string `json:"method"`
+	Key    string `json:"key"`
+	// BodyHash hashes the request, so that the records can be matched
+	// with the requests the clients logged without holding the values.
+	BodyHash string `json:"bodyHash"`
+	// Code is the HTTP status code of a v2 response, or the gRPC status
+	// code of a v3 one.
+	Code int `json:"code"`
+	// Index is the index of a v2 response, or the revision of a v3 one.
+	Index uint64        `json:"index,omitempty"`
+	Error string        `json:"error,omitempty"`
+	Took  time.Duration `json:"took"`
+}
+
+// MutationBodyHash returns the hash of the request body b recorded in a
+// MutationRecord.
+func MutationBodyHash(b []byte) string {
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:8])
+}
+
+// flightRecorder keeps the records of the last mutations in a ring.
+type flightRecorder struct {
+	mu      sync.Mutex
+	records []MutationRecord
+	// next is the position of the next record in the ring.
+	next int
+	full bool
+}
+
+func newFlightRecorder(n int) *flightRecorder {
+	if n <= 0 {
+		return nil
+	}
+	return &flightRecorder{records: make([]MutationRecord, n)}
+}
+
+func (fr *flightRecorder) record(r MutationRecord) {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	fr.records[fr.next] = r
+	fr.next++
+	if fr.next == len(fr.records) {
+		fr.next, fr.full = 0, true
+	}
+}
+
+// list returns the records, oldest first.
+func (fr *flightRecorder) list() []MutationRecord {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	rs := []MutationRecord{}
+	if fr.full {
+		rs = append(rs, fr.records[fr.next:]...)
+	}
+	return append(rs, fr.records[:fr.next]...)
+}
+
+// FlightRecorder records the last mutations clients requested from the
+// member, to reconstruct what happened right before an incident.
+type FlightRecorder interface {
+	// RecordsMutations returns true if the member records the mutations.
+	RecordsMutations() bool
+	// RecordMutation records a mutation once it is responded to. It does
+	// nothing if the member does not record the mutations.
+	RecordMutation(r MutationRecord)
+	// Mutations returns the records of the last mutations, oldest first.
+	Mutations() ([]MutationRecord, error)
+}
+
+func (s *EtcdServer) RecordsMutations() bool { return s.flightRecorder != nil }
+
+func (s *EtcdServer) RecordMutation(r MutationRecord) {
+	if s.flightRecorder != nil {
+		s.flightRecorder.record(r)
+	}
+}
+
+func (s *EtcdServer) Mutations() ([]MutationRecord, error) {
+	if s.flightRecorder == nil {
+		return nil, ErrFlightRecorderDisabled
+	}
+	return s.flightRecorder.list(), nil
+}
@@ -0,0 +1,49 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdserver
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFlightRecorder(t *testing.T) {
+	if newFlightRecorder(0) != nil {
+		t.Fatalf("flight recorder of 0 entries is not nil")
+	}
+	fr := newFlightRecorder(3)
+	tests := []struct {
+		index uint64
+
+		windexes []uint64
+	}{
+		{1, []uint64{1}},
+		{2, []uint64{1, 2}},
+		{3, []uint64{1, 2, 3}},
+		// the oldest record is overwritten
+		{4, []uint64{2, 3, 4}},
+		{5, []uint64{3, 4, 5}},
+	}
+	for i, tt := range tests {
+		fr.record(MutationRecord{Index: tt.index})
+		indexes := []uint64{}
+		for _, r := range fr.list() {
+			indexes = append(indexes, r.Index)
+		}
+		if !reflect.DeepEqual(indexes, tt.windexes) {
+			t.Errorf("#%d: indexes = %v, want %v", i, indexes, tt.windexes)
+		}
+	}
+}
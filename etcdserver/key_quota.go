@@ -0,0 +1,260 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdserver
+
+import (
+	"context"
+	"encoding/json"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"go.etcd.io/etcd/etcdserver/api/v2error"
+	pb "go.etcd.io/etcd/etcdserver/etcdserverpb"
+	"go.etcd.io/etcd/mvcc"
+)
+
+// KeyQuota limits the number of keys under Prefix.
+type KeyQuota struct {
+	// Prefix is the prefix of the v3 keys, and the directory of the v2
+	// keys, counted against the quota, e.g. "/registry/pods".
+	Prefix string `json:"prefix"`
+	// MaxKeys is the number of keys under Prefix at which the writes
+	// creating more keys are refused.
+	MaxKeys int `json:"max-keys"`
+}
+
+func (q KeyQuota) validate() error {
+	if q.Prefix == "" || q.MaxKeys <= 0 {
+		return ErrInvalidKeyQuota
+	}
+	return nil
+}
+
+// keyQuotaCountInterval is the interval the keys under the prefix of a
+// quota are counted at. In between, the count only grows by the keys
+// created through the member.
+const keyQuotaCountInterval = time.Second
+
+// keyQuotas holds the key quotas. The zero value has no quota.
+type keyQuotas struct {
+	mu     sync.Mutex
+	quotas []KeyQuota
+	// counts holds the number of keys under the prefix of every quota
+	// checked, by index of the quota.
+	counts map[int]*keyCount
+}
+
+type keyCount struct {
+	n       int
+	counted time.Time
+}
+
+func (kq *keyQuotas) setQuotas(quotas []KeyQuota) {
+	kq.mu.Lock()
+	defer kq.mu.Unlock()
+	kq.quotas = append([]KeyQuota(nil), quotas...)
+	kq.counts = nil
+}
+
+func (kq *keyQuotas) getQuotas() []KeyQuota {
+	kq.mu.Lock()
+	defer kq.mu.Unlock()
+	return append([]KeyQuota(nil), kq.quotas...)
+}
+
+// check returns the first quota exceeded at now by creating the given new
+// keys, if any, given the number of keys matching a prefix, counted up to
+// limit. The keys under a prefix are counted at most once per
+// keyQuotaCountInterval; the keys allowed are added to the count until
+// then, so that the writes in between do not count the keys again.
+func (kq *keyQuotas) check(keys []string, now time.Time, match func(prefix, key string) bool, count func(prefix string, limit int) int) *KeyQuota {
+	kq.mu.Lock()
+	defer kq.mu.Unlock()
+	ns := make([]int, len(kq.quotas))
+	for i, q := range kq.quotas {
+		for _, key := range keys {
+			if match(q.Prefix, key) {
+				ns[i]++
+			}
+		}
+		if ns[i] == 0 {
+			continue
+		}
+		c := kq.counts[i]
+		if c == nil || now.Sub(c.counted) >= keyQuotaCountInterval {
+			if kq.counts == nil {
+				kq.counts = make(map[int]*keyCount)
+			}
+			c = &keyCount{n: count(q.Prefix, q.MaxKeys), counted: now}
+			kq.counts[i] = c
+		}
+		if c.n+ns[i] > q.MaxKeys {
+			keyWritesQuotaExceeded.Inc()
+			return &q
+		}
+	}
+	for i, n := range ns {
+		if n != 0 {
+			kq.counts[i].n += n
+		}
+	}
+	return nil
+}
+
+// setKeyQuotas sets the key quotas of the cluster, by proposing the
+// request setting them; every member applies them once it applies the
+// request. An empty list removes them.
+func (s *EtcdServer) setKeyQuotas(quotas []KeyQuota) error {
+	var val string
+	if len(quotas) != 0 {
+		b, err := json.Marshal(quotas)
+		if err != nil {
+			return err
+		}
+		val = string(b)
+	}
+	ctx, cancel := context.WithTimeout(s.ctx, s.Cfg.ReqTimeout())
+	defer cancel()
+	_, err := s.Do(ctx, pb.Request{Method: "PUT", Path: clusterSettingPath("key-quotas"), Val: val})
+	return err
+}
+
+// applyKeyQuotas applies the key quotas of the cluster, as set by
+// setKeyQuotas.
+func (s *EtcdServer) applyKeyQuotas(val string) {
+	var quotas []KeyQuota
+	if val != "" {
+		if err := json.Unmarshal([]byte(val), &quotas); err != nil {
+			s.warnClusterSetting("key-quotas", val, err)
+			return
+		}
+	}
+	s.keyQuotas.setQuotas(quotas)
+}
+
+// checkV2KeyQuota checks the quotas of the key created by the v2 write r,
+// if any. The keys are matched without the store prefix, by directory; the
+// cause of the error is the directory of the exceeded quota.
+func (s *EtcdServer) checkV2KeyQuota(r *pb.Request) error {
+	var key string
+	switch {
+	case r.Dir || !strings.HasPrefix(r.Path, StoreKeysPrefix+"/"):
+		return nil
+	case r.Method == "POST":
+		// the key is created in the directory at r.Path
+		key = path.Join(r.Path[len(StoreKeysPrefix):], "_")
+	case r.Method == "PUT":
+		if _, err := s.v2store.Lookup(r.Path); err == nil {
+			return nil
+		}
+		key = r.Path[len(StoreKeysPrefix):]
+	default:
+		return nil
+	}
+	match := func(prefix, key string) bool {
+		return strings.HasPrefix(key, strings.TrimSuffix(path.Clean(prefix), "/")+"/")
+	}
+	count := func(prefix string, limit int) int {
+		return s.v2store.CountKeys(path.Join(StoreKeysPrefix, prefix), limit)
+	}
+	if q := s.keyQuotas.check([]string{key}, time.Now(), match, count); q != nil {
+		return v2error.NewError(v2error.EcodeKeyQuotaFull, q.Prefix, s.v2store.Index())
+	}
+	return nil
+}
+
+// checkV3KeyQuota returns ErrKeyQuotaExceeded if putting the given keys
+// creates more keys than a quota allows. The keys are matched by prefix.
+func (s *EtcdServer) checkV3KeyQuota(keys ...[]byte) error {
+	if len(s.keyQuotas.getQuotas()) == 0 {
+		return nil
+	}
+	var newKeys []string
+	for _, key := range keys {
+		if rr, err := s.KV().Range(key, nil, mvcc.RangeOptions{Count: true}); err == nil && rr.Count > 0 {
+			continue
+		}
+		newKeys = append(newKeys, string(key))
+	}
+	if len(newKeys) == 0 {
+		return nil
+	}
+	match := func(prefix, key string) bool {
+		return strings.HasPrefix(key, prefix)
+	}
+	count := func(prefix string, limit int) int {
+		rr, err := s.KV().Range([]byte(prefix), prefixEnd([]byte(prefix)), mvcc.RangeOptions{Count: true})
+		if err != nil {
+			return 0
+		}
+		return rr.Count
+	}
+	if s.keyQuotas.check(newKeys, time.Now(), match, count) != nil {
+		return ErrKeyQuotaExceeded
+	}
+	return nil
+}
+
+// checkTxnKeyQuota checks the quotas of the keys put by either branch of
+// the transaction, nested ones included.
+func (s *EtcdServer) checkTxnKeyQuota(r *pb.TxnRequest) error {
+	if len(s.keyQuotas.getQuotas()) == 0 {
+		return nil
+	}
+	keys := make(map[string]struct{})
+	txnPutKeys(r, keys)
+	var bkeys [][]byte
+	for key := range keys {
+		bkeys = append(bkeys, []byte(key))
+	}
+	return s.checkV3KeyQuota(bkeys...)
+}
+
+// txnHasPuts returns true if either branch of the transaction, nested ones
+// included, puts a key.
+func txnHasPuts(r *pb.TxnRequest) bool {
+	keys := make(map[string]struct{})
+	txnPutKeys(r, keys)
+	return len(keys) > 0
+}
+
+func txnPutKeys(r *pb.TxnRequest, keys map[string]struct{}) {
+	for _, ops := range [][]*pb.RequestOp{r.Success, r.Failure} {
+		for _, op := range ops {
+			switch tv := op.Request.(type) {
+			case *pb.RequestOp_RequestPut:
+				keys[string(tv.RequestPut.Key)] = struct{}{}
+			case *pb.RequestOp_RequestTxn:
+				txnPutKeys(tv.RequestTxn, keys)
+			}
+		}
+	}
+}
+
+// prefixEnd returns the end of the range of the keys starting with prefix.
+func prefixEnd(prefix []byte) []byte {
+	end := make([]byte, len(prefix))
+	copy(end, prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	// every key is after the prefix
+	return []byte{0}
+}
@@ -0,0 +1,232 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdserver
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go.etcd.io/etcd/etcdserver/api/v2error"
+	"go.etcd.io/etcd/etcdserver/api/v2store"
+	pb "go.etcd.io/etcd/etcdserver/etcdserverpb"
+	"go.etcd.io/etcd/lease"
+	"go.etcd.io/etcd/mvcc"
+	"go.etcd.io/etcd/mvcc/backend"
+	"go.etcd.io/etcd/pkg/idutil"
+	"go.etcd.io/etcd/pkg/mock/mockstorage"
+	"go.etcd.io/etcd/raft"
+
+	"go.uber.org/zap"
+)
+
+func TestCheckV2KeyQuota(t *testing.T) {
+	st := v2store.New(StoreClusterPrefix, StoreKeysPrefix)
+	s := &EtcdServer{v2store: st}
+	for i := 0; i < 2; i++ {
+		st.Create(fmt.Sprintf("/1/pods/%d", i), false, "", false, v2store.TTLOptionSet{ExpireTime: v2store.Permanent})
+	}
+	s.keyQuotas.setQuotas([]KeyQuota{{Prefix: "/pods/", MaxKeys: 2}})
+
+	tests := []struct {
+		req pb.Request
+
+		wquota bool
+	}{
+		{pb.Request{Method: "PUT", Path: "/1/pods/2"}, true},
+		{pb.Request{Method: "POST", Path: "/1/pods"}, true},
+		{pb.Request{Method: "POST", Path: "/1/pods/sub"}, true},
+		// updates do not create keys
+		{pb.Request{Method: "PUT", Path: "/1/pods/0"}, false},
+		// nor do directories count as keys
+		{pb.Request{Method: "PUT", Path: "/1/pods/dir", Dir: true}, false},
+		{pb.Request{Method: "DELETE", Path: "/1/pods/0"}, false},
+		// the quota applies to the directory, not to the prefix
+		{pb.Request{Method: "PUT", Path: "/1/podsX"}, false},
+		{pb.Request{Method: "PUT", Path: "/1/pods"}, false},
+	}
+	for i, tt := range tests {
+		err := s.checkV2KeyQuota(&tt.req)
+		if !tt.wquota {
+			if err != nil {
+				t.Errorf("#%d: err = %v, want nil", i, err)
+			}
+			continue
+		}
+		if e, ok := err.(*v2error.Error); !ok || e.ErrorCode != v2error.EcodeKeyQuotaFull || e.Cause != "/pods/" {
+			t.Errorf("#%d: err = %v, want quota error of /pods/", i, err)
+		}
+	}
+
+	s.keyQuotas.setQuotas([]KeyQuota{{Prefix: "/pods", MaxKeys: 3}})
+	if err := s.checkV2KeyQuota(&pb.Request{Method: "PUT", Path: "/1/pods/2"}); err != nil {
+		t.Errorf("err = %v, want nil under the quota", err)
+	}
+}
+
+func TestCheckV3KeyQuota(t *testing.T) {
+	be, tmpPath := backend.NewDefaultTmpBackend()
+	defer os.RemoveAll(tmpPath)
+	s := &EtcdServer{}
+	s.kv = mvcc.New(zap.NewExample(), be, &lease.FakeLessor{}, &s.consistIndex)
+	defer s.kv.Close()
+
+	if err := s.checkV3KeyQuota([]byte("pods/0")); err != nil {
+		t.Fatalf("err = %v without quota", err)
+	}
+	s.kv.Put([]byte("pods/0"), nil, lease.NoLease)
+	s.kv.Put([]byte("pods/1"), nil, lease.NoLease)
+	s.keyQuotas.setQuotas([]KeyQuota{{Prefix: "pods/", MaxKeys: 3}})
+
+	if err := s.checkV3KeyQuota([]byte("pods/0")); err != nil {
+		t.Errorf("err = %v, want nil on update", err)
+	}
+	if err := s.checkV3KeyQuota([]byte("pods/2")); err != nil {
+		t.Errorf("err = %v, want nil under the quota", err)
+	}
+	if err := s.checkV3KeyQuota([]byte("other")); err != nil {
+		t.Errorf("err = %v, want nil outside the prefix", err)
+	}
+	txn := &pb.TxnRequest{
+		Success: []*pb.RequestOp{{Request: &pb.RequestOp_RequestPut{RequestPut: &pb.PutRequest{Key: []byte("pods/2")}}}},
+		Failure: []*pb.RequestOp{{Request: &pb.RequestOp_RequestTxn{RequestTxn: &pb.TxnRequest{
+			Success: []*pb.RequestOp{{Request: &pb.RequestOp_RequestPut{RequestPut: &pb.PutRequest{Key: []byte("pods/3")}}}},
+		}}}},
+	}
+	if err := s.checkTxnKeyQuota(txn); err != ErrKeyQuotaExceeded {
+		t.Errorf("err = %v, want %v", err, ErrKeyQuotaExceeded)
+	}
+}
+
+// TestKeyQuotasCount ensures the keys under the prefix of a quota are only
+// counted once per interval, the keys allowed in between being added to
+// the count.
+func TestKeyQuotasCount(t *testing.T) {
+	var kq keyQuotas
+	kq.setQuotas([]KeyQuota{{Prefix: "a", MaxKeys: 3}})
+	counted := 0
+	keys := 1
+	match := func(prefix, key string) bool { return strings.HasPrefix(key, prefix) }
+	count := func(string, int) int {
+		counted++
+		return keys
+	}
+
+	now := time.Now()
+	for i := 0; i < 2; i++ {
+		if q := kq.check([]string{"a1"}, now, match, count); q != nil {
+			t.Fatalf("#%d: quota exceeded under the quota", i)
+		}
+	}
+	if q := kq.check([]string{"a1"}, now, match, count); q == nil {
+		t.Fatal("quota not exceeded by the keys allowed")
+	}
+	if q := kq.check([]string{"b"}, now, match, count); q != nil {
+		t.Fatal("quota exceeded outside of its prefix")
+	}
+	if counted != 1 {
+		t.Errorf("counted %d times, want once", counted)
+	}
+
+	// the keys are counted again once the interval elapsed
+	if q := kq.check([]string{"a1"}, now.Add(keyQuotaCountInterval), match, count); q != nil {
+		t.Fatal("quota exceeded once counted again")
+	}
+	if counted != 2 {
+		t.Errorf("counted %d times, want twice", counted)
+	}
+}
+
+// TestUpdateRuntimeConfigKeyQuotas ensures the key quotas are set through
+// consensus, and not persisted with the runtime configuration.
+func TestUpdateRuntimeConfigKeyQuotas(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "keyquotas")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	st := v2store.New(StoreClusterPrefix, StoreKeysPrefix)
+	srv := newRuntimeConfigTestServer(t, dir)
+	srv.Cfg.TickMs = 1
+	srv.Cfg.SnapshotCatchUpEntries = DefaultSnapshotCatchUpEntries
+	srv.r = *newRaftNode(raftNodeConfig{
+		lg:          zap.NewExample(),
+		Node:        newNodeCommitter(),
+		storage:     mockstorage.NewStorageRecorder(""),
+		raftStorage: raft.NewMemoryStorage(),
+		transport:   newNopTransporter(),
+	})
+	srv.v2store = st
+	srv.reqIDGen = idutil.NewGenerator(0, time.Time{})
+	srv.SyncTicker = &time.Ticker{}
+	srv.applyV2 = &applierV2store{store: srv.v2store, cluster: srv.cluster}
+	srv.start()
+	defer srv.Stop()
+
+	quotas := []KeyQuota{{Prefix: "/registry/pods", MaxKeys: 10000}}
+	rc, err := srv.UpdateRuntimeConfig(RuntimeConfig{KeyQuotas: quotas})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(rc.KeyQuotas, quotas) {
+		t.Errorf("quotas = %+v, want %+v", rc.KeyQuotas, quotas)
+	}
+	if v := srv.clusterSetting("key-quotas"); v != `[{"prefix":"/registry/pods","max-keys":10000}]` {
+		t.Errorf("setting = %q, want the quotas", v)
+	}
+	prc, err := readRuntimeConfig(srv.Cfg.runtimeConfigPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prc.KeyQuotas != nil {
+		t.Errorf("persisted quotas = %+v, want none", prc.KeyQuotas)
+	}
+
+	// the quotas are recovered from the store
+	srv.keyQuotas.setQuotas(nil)
+	srv.recoverClusterSettings()
+	if q := srv.keyQuotas.getQuotas(); !reflect.DeepEqual(q, quotas) {
+		t.Errorf("recovered quotas = %+v, want %+v", q, quotas)
+	}
+
+	if _, err = srv.UpdateRuntimeConfig(RuntimeConfig{KeyQuotas: []KeyQuota{}}); err != nil {
+		t.Fatal(err)
+	}
+	if q := srv.keyQuotas.getQuotas(); len(q) != 0 {
+		t.Errorf("quotas = %+v, want none once removed", q)
+	}
+}
+
+func TestPrefixEnd(t *testing.T) {
+	tests := []struct {
+		prefix, wend []byte
+	}{
+		{[]byte("a"), []byte("b")},
+		{[]byte("a/"), []byte("a0")},
+		{[]byte{'a', 0xff}, []byte("b")},
+		{[]byte{0xff}, []byte{0}},
+	}
+	for i, tt := range tests {
+		if end := prefixEnd(tt.prefix); !bytes.Equal(end, tt.wend) {
+			t.Errorf("#%d: end = %q, want %q", i, end, tt.wend)
+		}
+	}
+}
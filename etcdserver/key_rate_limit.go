@@ -0,0 +1,202 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdserver
+
+import (
+	"container/list"
+	"path"
+	"sync"
+	"time"
+
+	pb "go.etcd.io/etcd/etcdserver/etcdserverpb"
+
+	"golang.org/x/time/rate"
+)
+
+// maxRateLimitedKeys is the most number of keys whose write rate is tracked
+// at once. Once reached, the least recently written key is forgotten to
+// track a new one.
+const maxRateLimitedKeys = 10000
+
+// KeyRateLimit limits the rate of the writes to every key matching Pattern.
+// The limits are per member: every member counts the writes it receives
+// against its own limits, so a client spreading its writes over n members
+// may write up to n times the rate.
+type KeyRateLimit struct {
+	// Pattern matches the whole key, with the syntax of path.Match, e.g.
+	// "/config/*". A key is limited by the first pattern it matches.
+	Pattern string `json:"pattern"`
+	// Rate is the number of writes per second allowed to each key.
+	Rate float64 `json:"rate"`
+	// Burst is the number of writes allowed at once to each key. It
+	// defaults to the rate, and is at least one.
+	Burst int `json:"burst,omitempty"`
+}
+
+func (l KeyRateLimit) burst() int {
+	b := l.Burst
+	if b == 0 {
+		b = int(l.Rate)
+	}
+	if b < 1 {
+		b = 1
+	}
+	return b
+}
+
+func (l KeyRateLimit) validate() error {
+	if l.Pattern == "" || l.Rate <= 0 || l.Burst < 0 {
+		return ErrInvalidKeyRateLimit
+	}
+	if _, err := path.Match(l.Pattern, ""); err != nil {
+		return ErrInvalidKeyRateLimit
+	}
+	return nil
+}
+
+// keyRateLimiter tracks the writes to the keys matching its limits. The
+// zero value allows every write.
+type keyRateLimiter struct {
+	mu     sync.Mutex
+	limits []KeyRateLimit
+	// keys maps the tracked keys to their element in lru, which holds
+	// their keyRate from the most to the least recently written.
+	keys map[string]*list.Element
+	lru  *list.List
+}
+
+type keyRate struct {
+	key  string
+	lim  *rate.Limiter
+	last time.Time
+	// idle is the time without writes after which the key is allowed a
+	// full burst again, and so is no longer worth tracking.
+	idle time.Duration
+}
+
+// setLimits replaces the limits, and forgets the writes tracked so far.
+func (l *keyRateLimiter) setLimits(limits []KeyRateLimit) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limits = append([]KeyRateLimit(nil), limits...)
+	l.keys, l.lru = nil, nil
+}
+
+func (l *keyRateLimiter) getLimits() []KeyRateLimit {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]KeyRateLimit(nil), l.limits...)
+}
+
+// allow returns false if a write to key at now exceeds the rate limit of
+// the key. The allowed writes are counted against the limit.
+func (l *keyRateLimiter) allow(key string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.limits) == 0 {
+		return true
+	}
+	var limit *KeyRateLimit
+	for i := range l.limits {
+		if ok, _ := path.Match(l.limits[i].Pattern, key); ok {
+			limit = &l.limits[i]
+			break
+		}
+	}
+	if limit == nil {
+		return true
+	}
+
+	var kr *keyRate
+	if e, ok := l.keys[key]; ok {
+		kr = e.Value.(*keyRate)
+		l.lru.MoveToFront(e)
+	} else {
+		if l.keys == nil {
+			l.keys, l.lru = make(map[string]*list.Element), list.New()
+		}
+		l.prune(now)
+		if len(l.keys) >= maxRateLimitedKeys {
+			// the key is allowed a full burst again if written next
+			l.remove(l.lru.Back())
+		}
+		b := limit.burst()
+		kr = &keyRate{
+			key:  key,
+			lim:  rate.NewLimiter(rate.Limit(limit.Rate), b),
+			idle: time.Duration(float64(b) / limit.Rate * float64(time.Second)),
+		}
+		l.keys[key] = l.lru.PushFront(kr)
+	}
+	kr.last = now
+	return kr.lim.AllowN(now, 1)
+}
+
+// prune forgets the least recently written keys as long as they are idle
+// at now. Every key is pruned at most once after being tracked, so the
+// cost is spread over the writes.
+func (l *keyRateLimiter) prune(now time.Time) {
+	for e := l.lru.Back(); e != nil; e = l.lru.Back() {
+		if kr := e.Value.(*keyRate); now.Sub(kr.last) < kr.idle {
+			return
+		}
+		l.remove(e)
+	}
+}
+
+func (l *keyRateLimiter) remove(e *list.Element) {
+	delete(l.keys, e.Value.(*keyRate).key)
+	l.lru.Remove(e)
+}
+
+// checkKeyWriteRate returns ErrKeyWriteRateExceeded if a write to key
+// exceeds its rate limit on this member.
+func (s *EtcdServer) checkKeyWriteRate(key string) error {
+	if s.keyRates.allow(key, time.Now()) {
+		return nil
+	}
+	keyWritesRateLimited.Inc()
+	return ErrKeyWriteRateExceeded
+}
+
+// checkTxnWriteRate checks the write rate of the keys put or deleted by
+// either branch of the transaction, nested ones included, once per key.
+func (s *EtcdServer) checkTxnWriteRate(r *pb.TxnRequest) error {
+	keys := make(map[string]struct{})
+	txnWrittenKeys(r, keys)
+	for key := range keys {
+		if err := s.checkKeyWriteRate(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func txnWrittenKeys(r *pb.TxnRequest, keys map[string]struct{}) {
+	for _, ops := range [][]*pb.RequestOp{r.Success, r.Failure} {
+		for _, op := range ops {
+			switch tv := op.Request.(type) {
+			case *pb.RequestOp_RequestPut:
+				keys[string(tv.RequestPut.Key)] = struct{}{}
+			case *pb.RequestOp_RequestDeleteRange:
+				if len(tv.RequestDeleteRange.RangeEnd) == 0 {
+					keys[string(tv.RequestDeleteRange.Key)] = struct{}{}
+				}
+			case *pb.RequestOp_RequestTxn:
+				txnWrittenKeys(tv.RequestTxn, keys)
+			}
+		}
+	}
+}
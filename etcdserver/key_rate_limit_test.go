@@ -0,0 +1,104 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdserver
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestKeyRateLimiter(t *testing.T) {
+	var l keyRateLimiter
+	now := time.Now()
+	if !l.allow("/config/a", now) {
+		t.Fatal("write refused without limits")
+	}
+
+	l.setLimits([]KeyRateLimit{{Pattern: "/config/*", Rate: 1, Burst: 2}, {Pattern: "/*", Rate: 100}})
+	for i := 0; i < 2; i++ {
+		if !l.allow("/config/a", now) {
+			t.Fatalf("#%d: write refused within burst", i)
+		}
+	}
+	if l.allow("/config/a", now) {
+		t.Fatal("write allowed beyond burst")
+	}
+	// the keys are limited apart
+	if !l.allow("/config/b", now) {
+		t.Fatal("write to another key refused")
+	}
+	// the first matching pattern applies
+	for i := 0; i < 3; i++ {
+		if !l.allow("/other", now) {
+			t.Fatalf("#%d: write refused within the rate of the second pattern", i)
+		}
+	}
+	// keys matching no pattern are not limited
+	for i := 0; i < 3; i++ {
+		if !l.allow("/config/a/b", now) {
+			t.Fatalf("#%d: write to unlimited key refused", i)
+		}
+	}
+	if !l.allow("/config/a", now.Add(time.Second)) {
+		t.Fatal("write refused after refill")
+	}
+}
+
+func TestKeyRateLimiterPrune(t *testing.T) {
+	var l keyRateLimiter
+	l.setLimits([]KeyRateLimit{{Pattern: "*", Rate: 1}})
+	now := time.Now()
+	for i := 0; i < maxRateLimitedKeys; i++ {
+		l.allow(fmt.Sprint(i), now)
+	}
+	// the least recently written key is forgotten to track a new one
+	l.allow("1", now)
+	if !l.allow("busy", now) || l.allow("busy", now) {
+		t.Fatal("new key not limited while the tracked ones are busy")
+	}
+	if len(l.keys) != maxRateLimitedKeys {
+		t.Fatalf("tracked keys = %d, want %d", len(l.keys), maxRateLimitedKeys)
+	}
+	if _, ok := l.keys["0"]; ok {
+		t.Fatal("least recently written key still tracked")
+	}
+	if _, ok := l.keys["1"]; !ok {
+		t.Fatal("recently written key forgotten")
+	}
+	// idle keys are pruned to track new ones
+	if l.allow("idle", now.Add(time.Second)); len(l.keys) != 1 {
+		t.Fatalf("tracked keys = %d, want 1", len(l.keys))
+	}
+}
+
+func TestKeyRateLimitValidate(t *testing.T) {
+	tests := []struct {
+		l    KeyRateLimit
+		werr error
+	}{
+		{KeyRateLimit{Pattern: "/a/*", Rate: 1}, nil},
+		{KeyRateLimit{Pattern: "/a/*", Rate: 0.5, Burst: 3}, nil},
+		{KeyRateLimit{Pattern: "", Rate: 1}, ErrInvalidKeyRateLimit},
+		{KeyRateLimit{Pattern: "/a/[", Rate: 1}, ErrInvalidKeyRateLimit},
+		{KeyRateLimit{Pattern: "/a", Rate: 0}, ErrInvalidKeyRateLimit},
+		{KeyRateLimit{Pattern: "/a", Rate: 1, Burst: -1}, ErrInvalidKeyRateLimit},
+	}
+	for i, tt := range tests {
+		if err := tt.l.validate(); err != tt.werr {
+			t.Errorf("#%d: err = %v, want %v", i, err, tt.werr)
+		}
+	}
+}
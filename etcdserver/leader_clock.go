@@ -0,0 +1,72 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdserver
+
+import (
+	"sync"
+	"time"
+)
+
+// leaderClock estimates the wall clock of the leader from the SYNC requests
+// it proposes, which expire the v2 keys. The expiration of a key is set by
+// the member accepting the request; computing it from the leader time
+// rather than from the local wall clock keeps skewed members from setting
+// TTLs that are shorter or longer than requested.
+//
+// The zero value is a leaderClock that has not observed any SYNC request,
+// and returns the local time.
+type leaderClock struct {
+	mu sync.RWMutex
+	// syncTime is the time of the last observed SYNC request.
+	syncTime time.Time
+	// syncAt is the local time syncTime was observed at. Only its
+	// monotonic reading is used, so that changes to the local wall clock
+	// do not affect the estimate.
+	syncAt time.Time
+}
+
+// observe records that a SYNC request proposed at t was applied. A time
+// before the last observed one is ignored, so that replaying the SYNC
+// requests of the log, e.g. on restart, never moves the clock backwards.
+func (c *leaderClock) observe(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !t.After(c.syncTime) {
+		return
+	}
+	c.syncTime, c.syncAt = t, time.Now()
+}
+
+// Now returns the estimated time of the leader.
+func (c *leaderClock) Now() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.syncAt.IsZero() {
+		return time.Now()
+	}
+	return c.syncTime.Add(time.Since(c.syncAt))
+}
+
+// proposeTime returns the time to propose a SYNC request at. A new leader
+// never proposes a time before the one of the previous leader, so the
+// time of the SYNC requests, and so the expiration of the keys, never
+// goes backwards.
+func (c *leaderClock) proposeTime() time.Time {
+	now, lnow := time.Now(), c.Now()
+	if lnow.After(now) {
+		return lnow
+	}
+	return now
+}
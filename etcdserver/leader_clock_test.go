@@ -0,0 +1,100 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdserver
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	pb "go.etcd.io/etcd/etcdserver/etcdserverpb"
+	"go.etcd.io/etcd/pkg/mock/mockstore"
+
+	"go.uber.org/zap"
+)
+
+func TestLeaderClock(t *testing.T) {
+	var c leaderClock
+	if d := time.Since(c.Now()); d < 0 || d > time.Second {
+		t.Fatalf("unsynced leader time is %v from the local time, want the local time", d)
+	}
+
+	// the leader clock is an hour ahead
+	ahead := time.Now().Add(time.Hour)
+	c.observe(ahead)
+	time.Sleep(10 * time.Millisecond)
+	now := c.Now()
+	if d := now.Sub(ahead); d < 10*time.Millisecond || d > time.Second {
+		t.Fatalf("leader time advanced by %v, want about 10ms", d)
+	}
+	// a leader behind the previous one continues from its time
+	if pt := c.proposeTime(); pt.Before(now) {
+		t.Fatalf("proposed time %v before the leader time %v", pt, now)
+	}
+
+	// the leader clock is an hour behind
+	var bc leaderClock
+	bc.observe(time.Now().Add(-time.Hour))
+	if d := time.Until(bc.Now()); d > -59*time.Minute {
+		t.Fatalf("leader time is %v from the local time, want about -1h", d)
+	}
+	if d := time.Since(bc.proposeTime()); d < 0 || d > time.Second {
+		t.Fatalf("proposed time is %v from the local time, want the local time", d)
+	}
+}
+
+// TestLeaderClockReplay ensures that the SYNC requests replayed out of
+// order do not move the leader time backwards.
+func TestLeaderClockReplay(t *testing.T) {
+	var c leaderClock
+	last := time.Now().Add(time.Hour)
+	for _, st := range []time.Time{
+		last.Add(-2 * time.Second),
+		last.Add(-time.Second),
+		last,
+		// replayed from the log after the last one was observed
+		last.Add(-2 * time.Second),
+		last.Add(-time.Second),
+	} {
+		c.observe(st)
+	}
+	if now := c.Now(); now.Before(last) {
+		t.Fatalf("leader time %v before the last observed time %v", now, last)
+	}
+}
+
+// TestApplySyncLeaderTime ensures the SYNC requests set the leader time,
+// except when no leader is known.
+func TestApplySyncLeaderTime(t *testing.T) {
+	srv := &EtcdServer{
+		lgMu:    new(sync.RWMutex),
+		lg:      zap.NewExample(),
+		v2store: mockstore.NewRecorder(),
+	}
+	srv.applyV2 = &applierV2store{store: srv.v2store, cluster: srv.cluster}
+
+	ahead := time.Now().Add(time.Hour)
+	req := pb.Request{Method: "SYNC", ID: 1, Time: ahead.UnixNano()}
+	srv.applyV2Request((*RequestV2)(&req))
+	if d := time.Until(srv.LeaderTime()); d > time.Minute {
+		t.Fatalf("leader time is %v ahead with no leader, want the local time", d)
+	}
+
+	srv.setLead(1)
+	srv.applyV2Request((*RequestV2)(&req))
+	if d := time.Until(srv.LeaderTime()); d < 59*time.Minute {
+		t.Fatalf("leader time is %v ahead, want about 1h", d)
+	}
+}
@@ -0,0 +1,197 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdserver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.etcd.io/etcd/etcdserver/api/membership"
+	"go.etcd.io/etcd/pkg/types"
+
+	"go.uber.org/zap"
+)
+
+// LeaderConstraint constrains the value of a member metadata key for the
+// member to be leader.
+type LeaderConstraint struct {
+	Key    string
+	Values []string
+	// Exclude is true if the member must not have one of Values, and
+	// false if it must have one of them.
+	Exclude bool
+}
+
+// LeaderPlacement holds the constraints on the metadata of the members
+// that may stay leader. A member may be leader if it satisfies every
+// constraint.
+type LeaderPlacement []LeaderConstraint
+
+// ParseLeaderPlacement parses a comma-separated list of constraints
+// "key=v1|v2", the member must have one of the values for key, and
+// "key!=v1|v2", the member must not have any of them. For instance,
+// "zone=a|b" keeps leadership in zones a and b, and "zone!=c" keeps it
+// out of zone c.
+func ParseLeaderPlacement(s string) (LeaderPlacement, error) {
+	var p LeaderPlacement
+	if strings.TrimSpace(s) == "" {
+		return p, nil
+	}
+	for _, c := range strings.Split(s, ",") {
+		c = strings.TrimSpace(c)
+		var lc LeaderConstraint
+		var vals string
+		if i := strings.Index(c, "!="); i >= 0 {
+			lc.Key, vals, lc.Exclude = c[:i], c[i+2:], true
+		} else if i = strings.Index(c, "="); i >= 0 {
+			lc.Key, vals = c[:i], c[i+1:]
+		} else {
+			return nil, fmt.Errorf("leader placement constraint %q must be \"key=values\" or \"key!=values\"", c)
+		}
+		lc.Key = strings.TrimSpace(lc.Key)
+		if err := membership.ValidateMetadata(map[string]string{lc.Key: ""}); err != nil {
+			return nil, fmt.Errorf("leader placement constraint %q has an invalid key (%v)", c, err)
+		}
+		for _, v := range strings.Split(vals, "|") {
+			lc.Values = append(lc.Values, strings.TrimSpace(v))
+		}
+		p = append(p, lc)
+	}
+	return p, nil
+}
+
+func (p LeaderPlacement) String() string {
+	cs := make([]string, len(p))
+	for i, c := range p {
+		op := "="
+		if c.Exclude {
+			op = "!="
+		}
+		cs[i] = c.Key + op + strings.Join(c.Values, "|")
+	}
+	return strings.Join(cs, ",")
+}
+
+// Allows returns true if a member of metadata md may be leader. A member
+// without the key of a constraint has none of its values.
+func (p LeaderPlacement) Allows(md map[string]string) bool {
+	for _, c := range p {
+		v, ok := md[c.Key]
+		match := false
+		for _, cv := range c.Values {
+			if ok && v == cv {
+				match = true
+				break
+			}
+		}
+		if match == c.Exclude {
+			return false
+		}
+	}
+	return true
+}
+
+// getLeaderPlacement returns the leader placement of the cluster. Every
+// member enforces the same one, as replicated by the "leader-placement"
+// cluster setting, rather than the one of its own configuration.
+func (s *EtcdServer) getLeaderPlacement() LeaderPlacement {
+	s.leaderPlacementMu.RLock()
+	defer s.leaderPlacementMu.RUnlock()
+	return s.leaderPlacement
+}
+
+// applyLeaderPlacement applies the "leader-placement" cluster setting.
+func (s *EtcdServer) applyLeaderPlacement(val string) {
+	p, err := ParseLeaderPlacement(val)
+	if err != nil {
+		s.warnClusterSetting("leader-placement", val, err)
+		return
+	}
+	s.leaderPlacementMu.Lock()
+	s.leaderPlacement = p
+	s.leaderPlacementMu.Unlock()
+}
+
+// placementCandidateIDs returns the IDs of the members that may be leader
+// and satisfy the leader placement.
+func (s *EtcdServer) placementCandidateIDs() []types.ID {
+	lp := s.getLeaderPlacement()
+	var ids []types.ID
+	for _, m := range s.cluster.Members() {
+		if !m.Witness && !m.IsLearner && lp.Allows(m.Metadata) {
+			ids = append(ids, m.ID)
+		}
+	}
+	return ids
+}
+
+// placementAllowsLocal returns true if the leader placement allows the
+// local member to be leader.
+func (s *EtcdServer) placementAllowsLocal() bool {
+	lp := s.getLeaderPlacement()
+	if len(lp) == 0 {
+		return true
+	}
+	m := s.cluster.Member(s.ID())
+	return m == nil || lp.Allows(m.Metadata)
+}
+
+// transferPlacementLeadership transfers leadership away from the local
+// leader if the leader placement does not allow it, to the member
+// satisfying the placement that was active for the longest time. The
+// leader keeps leadership if no such member is active, as a cluster with
+// a leader out of place is better than one without a leader.
+func (s *EtcdServer) transferPlacementLeadership() {
+	if !s.isLeader() || s.placementAllowsLocal() {
+		return
+	}
+	lg := s.getLogger()
+	lp := s.getLeaderPlacement()
+	transferee, ok := longestConnected(s.r.transport, s.placementCandidateIDs())
+	if !ok {
+		if lg != nil {
+			lg.Warn(
+				"keeping leadership out of leader placement; no active member satisfies it",
+				zap.String("local-member-id", s.ID().String()),
+				zap.String("leader-placement", lp.String()),
+			)
+		} else {
+			plog.Warningf("%s keeps leadership out of leader placement %q; no active member satisfies it", s.ID(), lp)
+		}
+		return
+	}
+
+	if lg != nil {
+		lg.Info(
+			"transferring leadership to satisfy leader placement",
+			zap.String("local-member-id", s.ID().String()),
+			zap.String("leader-placement", lp.String()),
+			zap.String("transferee-member-id", transferee.String()),
+		)
+	} else {
+		plog.Infof("%s transferring leadership to %s to satisfy leader placement %q", s.ID(), transferee, lp)
+	}
+	ctx, cancel := context.WithTimeout(s.ctx, s.Cfg.ReqTimeout())
+	err := s.MoveLeader(ctx, s.Lead(), uint64(transferee))
+	cancel()
+	if err != nil {
+		if lg != nil {
+			lg.Warn("failed to transfer leadership", zap.String("transferee-member-id", transferee.String()), zap.Error(err))
+		} else {
+			plog.Warningf("failed to transfer leadership to %s (%v)", transferee, err)
+		}
+	}
+}
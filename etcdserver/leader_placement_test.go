@@ -0,0 +1,130 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdserver
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"go.etcd.io/etcd/etcdserver/api/membership"
+	"go.etcd.io/etcd/etcdserver/api/v2store"
+	pb "go.etcd.io/etcd/etcdserver/etcdserverpb"
+	"go.etcd.io/etcd/pkg/types"
+
+	"go.uber.org/zap"
+)
+
+func TestParseLeaderPlacement(t *testing.T) {
+	tests := []struct {
+		s string
+
+		wp   LeaderPlacement
+		werr bool
+	}{
+		{"", nil, false},
+		{"zone=a|b", LeaderPlacement{{Key: "zone", Values: []string{"a", "b"}}}, false},
+		{
+			"zone=a|b, zone!=c",
+			LeaderPlacement{{Key: "zone", Values: []string{"a", "b"}}, {Key: "zone", Values: []string{"c"}, Exclude: true}},
+			false,
+		},
+		{"role!=", LeaderPlacement{{Key: "role", Values: []string{""}, Exclude: true}}, false},
+		{"zone", nil, true},
+		{"=a", nil, true},
+		{"zone a=b", nil, true},
+	}
+	for i, tt := range tests {
+		p, err := ParseLeaderPlacement(tt.s)
+		if (err != nil) != tt.werr {
+			t.Errorf("#%d: err = %v, want error %v", i, err, tt.werr)
+		}
+		if !reflect.DeepEqual(p, tt.wp) {
+			t.Errorf("#%d: placement = %+v, want %+v", i, p, tt.wp)
+		}
+	}
+}
+
+func TestLeaderPlacementAllows(t *testing.T) {
+	p, err := ParseLeaderPlacement("zone=a|b,role!=witness-site")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		md map[string]string
+
+		w bool
+	}{
+		{map[string]string{"zone": "a"}, true},
+		{map[string]string{"zone": "b", "role": "main"}, true},
+		{map[string]string{"zone": "c"}, false},
+		{map[string]string{"zone": "a", "role": "witness-site"}, false},
+		{nil, false},
+	}
+	for i, tt := range tests {
+		if g := p.Allows(tt.md); g != tt.w {
+			t.Errorf("#%d: allows %v = %v, want %v", i, tt.md, g, tt.w)
+		}
+	}
+	if !LeaderPlacement(nil).Allows(nil) {
+		t.Errorf("empty placement does not allow a member")
+	}
+}
+
+// TestApplyLeaderPlacement ensures the members enforce the leader placement
+// replicated to the cluster, rather than the one of their configuration.
+func TestApplyLeaderPlacement(t *testing.T) {
+	lp, err := ParseLeaderPlacement("zone=b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := membership.NewMember("infra1", types.MustNewURLs([]string{"http://10.0.0.1:2380"}), "", nil)
+	m.Metadata = map[string]string{"zone": "a"}
+	srv := &EtcdServer{
+		lgMu:    new(sync.RWMutex),
+		lg:      zap.NewExample(),
+		id:      m.ID,
+		Cfg:     ServerConfig{LeaderPlacement: lp},
+		cluster: membership.NewClusterFromMembers(zap.NewExample(), "", types.ID(1), []*membership.Member{m}),
+		v2store: v2store.New(StoreClusterPrefix, StoreKeysPrefix),
+	}
+	srv.applyV2 = &applierV2store{store: srv.v2store, cluster: srv.cluster}
+	if !srv.placementAllowsLocal() {
+		t.Errorf("configured placement is enforced before it is replicated")
+	}
+	if v := clusterSettings["leader-placement"].configured(srv); v != "zone=b" {
+		t.Errorf("configured placement = %q, want zone=b", v)
+	}
+
+	put := func(val string) {
+		req := pb.Request{Method: "PUT", Path: clusterSettingPath("leader-placement"), Val: val}
+		if resp := srv.applyV2Request((*RequestV2)(&req)); resp.Err != nil {
+			t.Fatal(resp.Err)
+		}
+	}
+	put("zone=b")
+	if srv.placementAllowsLocal() {
+		t.Errorf("replicated placement zone=b allows a member of zone a")
+	}
+	// an invalid value is ignored
+	put("zone")
+	if srv.placementAllowsLocal() {
+		t.Errorf("invalid placement replaced zone=b")
+	}
+	put("zone=a|b")
+	if !srv.placementAllowsLocal() || len(srv.placementCandidateIDs()) != 1 {
+		t.Errorf("replicated placement zone=a|b does not allow a member of zone a")
+	}
+}
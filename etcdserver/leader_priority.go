@@ -0,0 +1,126 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdserver
+
+import (
+	"context"
+	"encoding/json"
+	"sync/atomic"
+	"time"
+
+	"go.etcd.io/etcd/etcdserver/api/membership"
+	pb "go.etcd.io/etcd/etcdserver/etcdserverpb"
+
+	"go.uber.org/zap"
+)
+
+// leaderPriorityInterval is the interval at which the leader looks for
+// an active member with a higher election priority.
+const leaderPriorityInterval = 5 * time.Second
+
+func (s *EtcdServer) getElectionPriority() uint {
+	return uint(atomic.LoadUint64(&s.electionPriority))
+}
+
+// memberAttributes returns the attributes the member publishes to the cluster.
+func (s *EtcdServer) memberAttributes() membership.Attributes {
+	attrs := s.attributes
+	attrs.ElectionPriority = s.getElectionPriority()
+	return attrs
+}
+
+// publishAttributes proposes the current member attributes to the
+// cluster once, so that changes made at runtime reach the leader.
+func (s *EtcdServer) publishAttributes(timeout time.Duration) {
+	attrs := s.memberAttributes()
+	b, err := json.Marshal(attrs)
+	if err != nil {
+		if lg := s.getLogger(); lg != nil {
+			lg.Panic("failed to marshal JSON", zap.Error(err))
+		} else {
+			plog.Panicf("json marshal error: %v", err)
+		}
+		return
+	}
+	req := pb.Request{
+		Method: "PUT",
+		Path:   membership.MemberAttributesStorePath(s.id),
+		Val:    string(b),
+	}
+	ctx, cancel := context.WithTimeout(s.ctx, timeout)
+	_, err = s.Do(ctx, req)
+	cancel()
+	if err != nil {
+		if lg := s.getLogger(); lg != nil {
+			lg.Warn(
+				"failed to publish local member attributes",
+				zap.String("local-member-id", s.ID().String()),
+				zap.Uint("election-priority", attrs.ElectionPriority),
+				zap.Error(err),
+			)
+		} else {
+			plog.Warningf("failed to publish attributes %+v of %s (%v)", attrs, s.ID(), err)
+		}
+	}
+}
+
+// monitorLeaderPriority transfers leadership to the active member with
+// the highest election priority, when that priority is higher than the
+// one of the local leader. It first transfers leadership away from a
+// leader the leader placement does not allow, e.g. after its metadata
+// changed.
+func (s *EtcdServer) monitorLeaderPriority() {
+	for {
+		select {
+		case <-time.After(leaderPriorityInterval):
+		case <-s.stopping:
+			return
+		}
+
+		if !s.isLeader() {
+			continue
+		}
+		if !s.placementAllowsLocal() {
+			s.transferPlacementLeadership()
+			continue
+		}
+		transferee, ok := preferredLeader(s.r.transport, s.cluster.Members(), s.ID(), s.getElectionPriority(), s.getLeaderPlacement())
+		if !ok {
+			continue
+		}
+
+		lg := s.getLogger()
+		if lg != nil {
+			lg.Info(
+				"transferring leadership to member with higher election priority",
+				zap.String("local-member-id", s.ID().String()),
+				zap.Uint("local-member-election-priority", s.getElectionPriority()),
+				zap.String("transferee-member-id", transferee.String()),
+			)
+		} else {
+			plog.Infof("%s transferring leadership to %s with higher election priority", s.ID(), transferee)
+		}
+		ctx, cancel := context.WithTimeout(s.ctx, s.Cfg.ReqTimeout())
+		err := s.MoveLeader(ctx, s.Lead(), uint64(transferee))
+		cancel()
+		if err != nil {
+			if lg != nil {
+				lg.Warn("failed to transfer leadership", zap.String("transferee-member-id", transferee.String()), zap.Error(err))
+			} else {
+				plog.Warningf("failed to transfer leadership to %s (%v)", transferee, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"go.etcd.io/etcd/etcdserver/api/membership"
+	"go.etcd.io/etcd/pkg/fileutil"
+	"go.etcd.io/etcd/pkg/types"
+)
+
+// memberIdentityFile holds, in the member directory, the identity the
+// member was bootstrapped with.
+const memberIdentityFile = "identity"
+
+// memberIdentity is the identity of a member, persisted in its data
+// directory so that a restart with conflicting flags fails instead of
+// silently bootstrapping a new member.
+type memberIdentity struct {
+	Name         string   `json:"name"`
+	MemberID     string   `json:"member-id"`
+	ClusterID    string   `json:"cluster-id"`
+	ClusterToken string   `json:"cluster-token"`
+	PeerURLs     []string `json:"peer-urls"`
+	ClientURLs   []string `json:"client-urls"`
+}
+
+func memberIdentityPath(cfg ServerConfig) string {
+	return filepath.Join(cfg.MemberDir(), memberIdentityFile)
+}
+
+// readMemberIdentity returns the identity persisted in the member
+// directory, or nil if there is none.
+func readMemberIdentity(cfg ServerConfig) (*memberIdentity, error) {
+	b, err := ioutil.ReadFile(memberIdentityPath(cfg))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var mi memberIdentity
+	if err = json.Unmarshal(b, &mi); err != nil {
+		return nil, fmt.Errorf("cannot parse member identity file %s: %v", memberIdentityPath(cfg), err)
+	}
+	return &mi, nil
+}
+
+// writeMemberIdentity persists mi in the member directory.
+func writeMemberIdentity(cfg ServerConfig, mi memberIdentity) error {
+	b, err := json.MarshalIndent(mi, "", "  ")
+	if err != nil {
+		return err
+	}
+	p := memberIdentityPath(cfg)
+	tmp := p + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fileutil.PrivateFileMode)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(append(b, '\n')); err == nil {
+		err = fileutil.Fsync(f)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, p)
+}
+
+// checkMemberIdentityWAL returns an error if the member directory holds
+// the identity of a bootstrapped member while its WAL is missing, as
+// starting would then bootstrap a new member with new IDs.
+func checkMemberIdentityWAL(cfg ServerConfig, mi *memberIdentity, haveWAL bool) error {
+	if mi == nil || haveWAL {
+		return nil
+	}
+	return fmt.Errorf("member %s (%s) of cluster %s was bootstrapped in %s, but no WAL is found in %s; restore the WAL, or remove %s to bootstrap a new member",
+		mi.MemberID, mi.Name, mi.ClusterID, cfg.DataDir, cfg.WALDir(), memberIdentityPath(cfg))
+}
+
+// verifyMemberIdentity returns an error if the member and cluster IDs
+// recovered from the WAL, or the name, cluster token and advertised peer
+// URLs of cfg conflict with the persisted identity mi, and otherwise
+// returns the identity to persist. A member is renamed or moved by
+// updating it through the member API first, which rewrites mi, see
+// updateMemberIdentity. The client URLs are recorded for reference only.
+func verifyMemberIdentity(cfg ServerConfig, mi *memberIdentity, id types.ID, cl *membership.RaftCluster) (memberIdentity, error) {
+	cur := memberIdentity{
+		Name:         cfg.Name,
+		MemberID:     id.String(),
+		ClusterID:    cl.ID().String(),
+		ClusterToken: cfg.InitialClusterToken,
+		PeerURLs:     cfg.PeerURLs.StringSlice(),
+		ClientURLs:   cfg.ClientURLs.StringSlice(),
+	}
+	if mi == nil {
+		return cur, nil
+	}
+	if mi.MemberID != cur.MemberID || mi.ClusterID != cur.ClusterID {
+		return cur, fmt.Errorf("member identity file %s is of member %s of cluster %s, but the WAL in %s is of member %s of cluster %s",
+			memberIdentityPath(cfg), mi.MemberID, mi.ClusterID, cfg.WALDir(), cur.MemberID, cur.ClusterID)
+	}
+	// the files written before the token was recorded have none
+	if mi.ClusterToken != "" && mi.ClusterToken != cur.ClusterToken {
+		return cur, fmt.Errorf("--initial-cluster-token %q conflicts with the token %q cluster %s was bootstrapped with",
+			cur.ClusterToken, mi.ClusterToken, mi.ClusterID)
+	}
+	if mi.Name != cur.Name {
+		return cur, fmt.Errorf("--name %q conflicts with the name %q of member %s; restore the name, or rename the member through the member API while it runs first",
+			cur.Name, mi.Name, mi.MemberID)
+	}
+	if !samePeerURLs(mi.PeerURLs, cur.PeerURLs) {
+		return cur, fmt.Errorf("--initial-advertise-peer-urls %v conflict with the peer URLs %v of member %s; restore them, or update the member with the new peer URLs through the member API while it runs first",
+			cur.PeerURLs, mi.PeerURLs, mi.MemberID)
+	}
+	return cur, nil
+}
+
+// updateMemberIdentity records in the persisted identity the peer URLs,
+// and the name unless empty, the member was updated with through the
+// member API, so that it may restart with them. It does nothing if no
+// identity is persisted.
+func updateMemberIdentity(cfg ServerConfig, name string, peerURLs []string) error {
+	mi, err := readMemberIdentity(cfg)
+	if err != nil || mi == nil {
+		return err
+	}
+	if name != "" {
+		mi.Name = name
+	}
+	mi.PeerURLs = peerURLs
+	return writeMemberIdentity(cfg, *mi)
+}
@@ -0,0 +1,105 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdserver
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"go.etcd.io/etcd/etcdserver/api/membership"
+	"go.etcd.io/etcd/pkg/types"
+)
+
+func TestMemberIdentity(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "identity")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err = os.MkdirAll(dir+"/member", 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := ServerConfig{
+		Name:                "infra1",
+		DataDir:             dir,
+		InitialClusterToken: "token",
+		PeerURLs:            types.MustNewURLs([]string{"http://10.0.0.1:2380"}),
+	}
+	mi, err := readMemberIdentity(cfg)
+	if err != nil || mi != nil {
+		t.Fatalf("readMemberIdentity() = %v, %v, want no identity", mi, err)
+	}
+
+	m := membership.NewMember("infra1", cfg.PeerURLs, "", nil)
+	cl := membership.NewClusterFromMembers(testLogger, "", types.ID(0x1000), []*membership.Member{m})
+	cur, err := verifyMemberIdentity(cfg, nil, m.ID, cl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = writeMemberIdentity(cfg, cur); err != nil {
+		t.Fatal(err)
+	}
+	if mi, err = readMemberIdentity(cfg); err != nil || !reflect.DeepEqual(*mi, cur) {
+		t.Fatalf("readMemberIdentity() = %+v, %v, want %+v", mi, err, cur)
+	}
+	if err = checkMemberIdentityWAL(cfg, mi, false); err == nil {
+		t.Errorf("expected error starting without the WAL of a bootstrapped member")
+	}
+
+	tests := []struct {
+		name     string
+		token    string
+		peerURLs []string
+		id       types.ID
+		cid      types.ID
+
+		werr bool
+	}{
+		{"infra1", "token", []string{"http://10.0.0.1:2380"}, m.ID, cl.ID(), false},
+		{"infra1", "token", []string{"http://10.0.0.1:2380"}, m.ID + 1, cl.ID(), true},
+		{"infra1", "token", []string{"http://10.0.0.1:2380"}, m.ID, cl.ID() + 1, true},
+		{"infra1", "other", []string{"http://10.0.0.1:2380"}, m.ID, cl.ID(), true},
+		// renamed or moved without updating the member first
+		{"infra2", "token", []string{"http://10.0.0.1:2380"}, m.ID, cl.ID(), true},
+		{"infra1", "token", []string{"http://10.0.0.2:2380"}, m.ID, cl.ID(), true},
+	}
+	for i, tt := range tests {
+		c := cfg
+		c.Name, c.InitialClusterToken, c.PeerURLs = tt.name, tt.token, types.MustNewURLs(tt.peerURLs)
+		ucl := membership.NewClusterFromMembers(testLogger, "", tt.cid, []*membership.Member{m})
+		if _, err = verifyMemberIdentity(c, mi, tt.id, ucl); (err != nil) != tt.werr {
+			t.Errorf("#%d: err = %v, want error %v", i, err, tt.werr)
+		}
+	}
+
+	// updating the member through the member API overrides its identity
+	if err = updateMemberIdentity(cfg, "infra2", []string{"http://10.0.0.2:2380"}); err != nil {
+		t.Fatal(err)
+	}
+	if mi, err = readMemberIdentity(cfg); err != nil {
+		t.Fatal(err)
+	}
+	c := cfg
+	c.Name, c.PeerURLs = "infra2", types.MustNewURLs([]string{"http://10.0.0.2:2380"})
+	if _, err = verifyMemberIdentity(c, mi, m.ID, cl); err != nil {
+		t.Errorf("err = %v after updating the member", err)
+	}
+	if _, err = verifyMemberIdentity(cfg, mi, m.ID, cl); err == nil {
+		t.Errorf("expected error restarting with the former name and peer URLs")
+	}
+}
@@ -0,0 +1,317 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"go.etcd.io/etcd/etcdserver/api/membership"
+	"go.etcd.io/etcd/etcdserver/api/snap"
+	"go.etcd.io/etcd/etcdserver/api/v2store"
+	pb "go.etcd.io/etcd/etcdserver/etcdserverpb"
+	"go.etcd.io/etcd/lease"
+	"go.etcd.io/etcd/mvcc"
+	"go.etcd.io/etcd/mvcc/backend"
+	"go.etcd.io/etcd/pkg/pbutil"
+	"go.etcd.io/etcd/pkg/types"
+	"go.etcd.io/etcd/raft"
+	"go.etcd.io/etcd/raft/raftpb"
+	"go.etcd.io/etcd/wal"
+	"go.etcd.io/etcd/wal/walpb"
+
+	humanize "github.com/dustin/go-humanize"
+	"go.uber.org/zap"
+)
+
+const (
+	// PeerSnapshotPath is the path of the peer endpoint serving the
+	// snapshots of the member to the members seeding their data directory.
+	PeerSnapshotPath = "/v2/admin/snapshot"
+	// PeerJoinPath is the path of the peer endpoint adding the seeded
+	// members to the cluster.
+	PeerJoinPath = "/v2/admin/join"
+
+	// maxSeedMessageBytes bounds the size of the MsgSnap message of a seed,
+	// which holds the v2 store.
+	maxSeedMessageBytes = 512 * 1024 * 1024
+)
+
+// JoinMember adds memb, a member whose data directory was seeded from a
+// snapshot of the cluster, on the request of the member itself. The peer
+// transport authenticated the member, so the auth permissions of the
+// client requests are not checked; the strict reconfiguration checks are.
+func (s *EtcdServer) JoinMember(ctx context.Context, memb membership.Member) ([]*membership.Member, error) {
+	return s.addMember(ctx, memb)
+}
+
+// seedMember prepares the data directory of a member joining an existing
+// cluster, in two phases. It first fetches the latest snapshot of a member
+// of the cluster and verifies it; only then does it ask the cluster to add
+// the member, and writes the snapshot, the v3 backend and a hard state at
+// the snapshot index to the data directory. The cluster thus never counts
+// a member that cannot start yet against its fault tolerance, and the
+// member catches up from the snapshot index rather than from an empty log.
+//
+// The WAL is created last: an attempt failing before leaves no WAL, and is
+// retried on the next start, reusing the ID of the member if it was added.
+func seedMember(cfg ServerConfig, ss *snap.Snapshotter, rt http.RoundTripper) error {
+	if err := cfg.VerifyJoinExisting(); err != nil {
+		return err
+	}
+	cl, err := membership.NewClusterFromURLsMap(cfg.Logger, cfg.InitialClusterToken, cfg.InitialPeerURLsMap)
+	if err != nil {
+		return err
+	}
+	existing, err := fetchExistingCluster(cfg, cl, rt)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	m, added := membership.NewMember("", cfg.PeerURLs, "", &now), false
+	for _, em := range existing.Members() {
+		if !samePeerURLs(em.PeerURLs, m.PeerURLs) {
+			continue
+		}
+		if em.IsStarted() {
+			return fmt.Errorf("member %s with peer URLs %v has already been started", em.ID, em.PeerURLs)
+		}
+		// a previous attempt added the member, but did not create the WAL
+		m, added = em, true
+	}
+
+	tmp := cfg.backendPath() + ".seed"
+	defer os.Remove(tmp)
+	var sn raftpb.Snapshot
+	err = fmt.Errorf("no remote peer")
+	for _, u := range getRemotePeerURLs(existing, cfg.Name) {
+		if sn, err = fetchSeed(cfg.Logger, u, existing.ID(), m.ID, tmp, rt); err == nil {
+			break
+		}
+		if cfg.Logger != nil {
+			cfg.Logger.Warn("failed to fetch seed snapshot", zap.String("address", u), zap.Error(err))
+		} else {
+			plog.Warningf("could not fetch seed snapshot from %s: %v", u, err)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("cannot fetch seed snapshot from peer urls: %v", err)
+	}
+	if err = verifySeed(cfg.Logger, sn, tmp); err != nil {
+		return fmt.Errorf("invalid seed snapshot: %v", err)
+	}
+
+	if !added {
+		if err = joinCluster(cfg.Logger, getRemotePeerURLs(existing, cfg.Name), existing.ID(), *m, rt); err != nil {
+			return fmt.Errorf("cannot add member %s: %v", m.ID, err)
+		}
+	}
+
+	if err = os.Rename(tmp, cfg.backendPath()); err != nil {
+		return err
+	}
+	if err = ss.SaveSnap(sn); err != nil {
+		return err
+	}
+	w, err := wal.Create(cfg.Logger, cfg.WALDir(), pbutil.MustMarshal(&pb.Metadata{NodeID: uint64(m.ID), ClusterID: uint64(existing.ID())}))
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	if err = w.SaveSnapshot(walpb.Snapshot{Index: sn.Metadata.Index, Term: sn.Metadata.Term}); err != nil {
+		return err
+	}
+	// the member has voted for no one yet, and everything up to the
+	// snapshot index is committed
+	if err = w.Save(raftpb.HardState{Term: sn.Metadata.Term, Commit: sn.Metadata.Index}, nil); err != nil {
+		return err
+	}
+
+	if cfg.Logger != nil {
+		cfg.Logger.Info(
+			"seeded data directory of joining member",
+			zap.String("local-member-id", m.ID.String()),
+			zap.String("cluster-id", existing.ID().String()),
+			zap.Uint64("snapshot-index", sn.Metadata.Index),
+			zap.Uint64("snapshot-term", sn.Metadata.Term),
+		)
+	} else {
+		plog.Infof("seeded data directory of joining member %s [cluster: %s, snapshot index: %d]", m.ID, existing.ID(), sn.Metadata.Index)
+	}
+	return nil
+}
+
+// fetchSeed fetches the latest snapshot of the member at the peer URL u,
+// as served on PeerSnapshotPath, writes its v3 backend to dbPath and
+// returns its raft snapshot.
+func fetchSeed(lg *zap.Logger, u string, cid, id types.ID, dbPath string, rt http.RoundTripper) (raftpb.Snapshot, error) {
+	req, err := http.NewRequest("GET", u+PeerSnapshotPath, nil)
+	if err != nil {
+		return raftpb.Snapshot{}, err
+	}
+	req.Header.Set("X-Etcd-Cluster-ID", cid.String())
+	req.Header.Set("X-Server-From", id.String())
+	resp, err := (&http.Client{Transport: rt}).Do(req)
+	if err != nil {
+		return raftpb.Snapshot{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return raftpb.Snapshot{}, fmt.Errorf("unexpected response %q (%s)", resp.Status, strings.TrimSpace(string(b)))
+	}
+	if gcid := resp.Header.Get("X-Etcd-Cluster-ID"); gcid != cid.String() {
+		return raftpb.Snapshot{}, fmt.Errorf("cluster ID mismatch (got %s, want %s)", gcid, cid)
+	}
+
+	var n uint64
+	if err = binary.Read(resp.Body, binary.BigEndian, &n); err != nil {
+		return raftpb.Snapshot{}, err
+	}
+	if n > maxSeedMessageBytes {
+		return raftpb.Snapshot{}, fmt.Errorf("snapshot message of %d bytes exceeds %d bytes", n, maxSeedMessageBytes)
+	}
+	b := make([]byte, n)
+	if _, err = io.ReadFull(resp.Body, b); err != nil {
+		return raftpb.Snapshot{}, err
+	}
+	var m raftpb.Message
+	if err = m.Unmarshal(b); err != nil {
+		return raftpb.Snapshot{}, err
+	}
+	if m.Type != raftpb.MsgSnap {
+		return raftpb.Snapshot{}, fmt.Errorf("unexpected message type %s", m.Type)
+	}
+
+	f, err := os.Create(dbPath)
+	if err != nil {
+		return raftpb.Snapshot{}, err
+	}
+	size, err := io.Copy(f, resp.Body)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return raftpb.Snapshot{}, err
+	}
+
+	if lg != nil {
+		lg.Info(
+			"fetched seed snapshot",
+			zap.String("address", u),
+			zap.Uint64("snapshot-index", m.Snapshot.Metadata.Index),
+			zap.Int64("db-bytes", size),
+			zap.String("db-size", humanize.Bytes(uint64(size))),
+		)
+	} else {
+		plog.Infof("fetched seed snapshot [index: %d, db size: %s] from %s", m.Snapshot.Metadata.Index, humanize.Bytes(uint64(size)), u)
+	}
+	return m.Snapshot, nil
+}
+
+// verifySeed checks that the raft snapshot sn holds a v2 store and the
+// members of the cluster, and that the v3 backend at dbPath was taken at
+// the snapshot index or later.
+func verifySeed(lg *zap.Logger, sn raftpb.Snapshot, dbPath string) error {
+	if raft.IsEmptySnap(sn) {
+		return fmt.Errorf("empty snapshot")
+	}
+	if len(sn.Metadata.ConfState.Nodes) == 0 {
+		return fmt.Errorf("snapshot at index %d has no voting member", sn.Metadata.Index)
+	}
+	if err := v2store.New(StoreClusterPrefix, StoreKeysPrefix).Recovery(sn.Data); err != nil {
+		return fmt.Errorf("cannot recover v2 store: %v", err)
+	}
+
+	be := backend.NewDefaultBackend(dbPath)
+	defer be.Close()
+	var cIndex consistentIndex
+	kv := mvcc.New(lg, be, &lease.FakeLessor{}, &cIndex)
+	defer kv.Close()
+	if ci := kv.ConsistentIndex(); ci < sn.Metadata.Index {
+		return fmt.Errorf("database at index %d is older than snapshot at index %d", ci, sn.Metadata.Index)
+	}
+	return nil
+}
+
+// joinCluster asks the members at the given peer URLs to add m, until one
+// of them does or refuses to.
+func joinCluster(lg *zap.Logger, urls []string, cid types.ID, m membership.Member, rt http.RoundTripper) error {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	cc := &http.Client{Transport: rt}
+	err = fmt.Errorf("no remote peer")
+	for _, u := range urls {
+		req, rerr := http.NewRequest("POST", u+PeerJoinPath, bytes.NewReader(b))
+		if rerr != nil {
+			return rerr
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Etcd-Cluster-ID", cid.String())
+		resp, derr := cc.Do(req)
+		if derr != nil {
+			err = derr
+			continue
+		}
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		resp.Body.Close()
+		switch resp.StatusCode {
+		case http.StatusCreated:
+			if lg != nil {
+				lg.Info("added joining member", zap.String("address", u), zap.String("local-member-id", m.ID.String()))
+			} else {
+				plog.Infof("added joining member %s through %s", m.ID, u)
+			}
+			return nil
+		case http.StatusConflict, http.StatusForbidden, http.StatusBadRequest:
+			// every member would refuse it the same way
+			return fmt.Errorf("%s refused the member (%s)", u, strings.TrimSpace(string(body)))
+		}
+		err = fmt.Errorf("unexpected response %q from %s (%s)", resp.Status, u, strings.TrimSpace(string(body)))
+	}
+	return err
+}
+
+// samePeerURLs returns true if a and b hold the same URLs, in any order.
+func samePeerURLs(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	sa, sb := append([]string(nil), a...), append([]string(nil), b...)
+	sort.Strings(sa)
+	sort.Strings(sb)
+	for i := range sa {
+		if sa[i] != sb[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,124 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdserver
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.etcd.io/etcd/etcdserver/api/membership"
+	"go.etcd.io/etcd/pkg/pbutil"
+	"go.etcd.io/etcd/pkg/types"
+	"go.etcd.io/etcd/raft/raftpb"
+)
+
+func TestFetchSeed(t *testing.T) {
+	m := raftpb.Message{
+		Type:     raftpb.MsgSnap,
+		Snapshot: raftpb.Snapshot{Metadata: raftpb.SnapshotMetadata{Index: 10, Term: 2}, Data: []byte("v2")},
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != PeerSnapshotPath || r.Header.Get("X-Server-From") != "3" {
+			http.Error(w, "not a member of the cluster", http.StatusForbidden)
+			return
+		}
+		w.Header().Set("X-Etcd-Cluster-ID", r.Header.Get("X-Etcd-Cluster-ID"))
+		binary.Write(w, binary.BigEndian, uint64(m.Size()))
+		w.Write(pbutil.MustMarshal(&m))
+		w.Write([]byte("database"))
+	}))
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir(os.TempDir(), "seed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	dbPath := filepath.Join(dir, "db")
+
+	sn, err := fetchSeed(testLogger, srv.URL, 1, 3, dbPath, http.DefaultTransport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sn.Metadata.Index != 10 || sn.Metadata.Term != 2 || string(sn.Data) != "v2" {
+		t.Errorf("snapshot = %+v, want the one of %+v", sn, m.Snapshot)
+	}
+	if b, _ := ioutil.ReadFile(dbPath); string(b) != "database" {
+		t.Errorf("db = %q, want %q", b, "database")
+	}
+
+	if _, err = fetchSeed(testLogger, srv.URL, 1, 4, dbPath, http.DefaultTransport); err == nil {
+		t.Errorf("expected error fetching the seed of a refused member")
+	}
+}
+
+func TestJoinCluster(t *testing.T) {
+	var joined membership.Member
+	codes := map[string]int{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if code := codes[r.Header.Get("X-Etcd-Cluster-ID")]; code != http.StatusCreated {
+			http.Error(w, "refused", code)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&joined)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	m := membership.Member{ID: 3, RaftAttributes: membership.RaftAttributes{PeerURLs: []string{"https://10.0.0.3:2380"}}}
+	tests := []struct {
+		code int
+
+		werr bool
+	}{
+		{http.StatusCreated, false},
+		{http.StatusConflict, true},
+		{http.StatusServiceUnavailable, true},
+	}
+	for i, tt := range tests {
+		joined = membership.Member{}
+		codes[types.ID(1).String()] = tt.code
+		err := joinCluster(testLogger, []string{srv.URL}, 1, m, http.DefaultTransport)
+		if (err != nil) != tt.werr {
+			t.Errorf("#%d: err = %v, want error %v", i, err, tt.werr)
+		}
+		if !tt.werr && joined.ID != m.ID {
+			t.Errorf("#%d: joined member ID = %s, want %s", i, joined.ID, m.ID)
+		}
+	}
+}
+
+func TestSamePeerURLs(t *testing.T) {
+	tests := []struct {
+		a, b []string
+
+		w bool
+	}{
+		{[]string{"http://a:2380", "http://b:2380"}, []string{"http://b:2380", "http://a:2380"}, true},
+		{[]string{"http://a:2380"}, []string{"http://a:2380", "http://b:2380"}, false},
+		{[]string{"http://a:2380"}, []string{"http://b:2380"}, false},
+	}
+	for i, tt := range tests {
+		if g := samePeerURLs(tt.a, tt.b); g != tt.w {
+			t.Errorf("#%d: samePeerURLs = %v, want %v", i, g, tt.w)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdserver
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+
+	"go.etcd.io/etcd/etcdserver/api/v2error"
+	"go.etcd.io/etcd/etcdserver/api/v2store"
+	pb "go.etcd.io/etcd/etcdserver/etcdserverpb"
+	"go.etcd.io/etcd/raft"
+
+	humanize "github.com/dustin/go-humanize"
+	"go.uber.org/zap"
+)
+
+const (
+	// memoryLimitInterval is the interval the memory usage is estimated at.
+	memoryLimitInterval = 5 * time.Second
+	// memoryWarnPercent is the percentage of the memory limit above which
+	// the member warns, and snapshots to compact its raft log.
+	memoryWarnPercent = 80
+)
+
+// The states of the estimated memory usage against the memory limit.
+const (
+	memoryOK int32 = iota
+	// memoryHigh is above memoryWarnPercent of the limit.
+	memoryHigh
+	// memoryExceeded refuses the writes adding data.
+	memoryExceeded
+)
+
+var memoryStateNames = map[int32]string{
+	memoryOK:       "ok",
+	memoryHigh:     "high",
+	memoryExceeded: "exceeded",
+}
+
+// raftLogBytes returns the size of the entries held by the raft log.
+func raftLogBytes(rs *raft.MemoryStorage) int64 {
+	first, _ := rs.FirstIndex()
+	last, _ := rs.LastIndex()
+	if last < first {
+		return 0
+	}
+	ents, err := rs.Entries(first, last+1, math.MaxUint64)
+	if err != nil {
+		return 0
+	}
+	var size int64
+	for i := range ents {
+		size += int64(ents[i].Size())
+	}
+	return size
+}
+
+// memoryStateOf returns the state of the usage against the limit.
+func memoryStateOf(usage, limit int64) int32 {
+	switch {
+	case usage >= limit:
+		return memoryExceeded
+	case usage >= limit/100*memoryWarnPercent:
+		return memoryHigh
+	}
+	return memoryOK
+}
+
+// monitorMemory estimates the memory held by the v2 store and the raft log
+// against the memory limit. Above memoryWarnPercent of the limit, it warns,
+// and requests a snapshot if the raft log holds a tenth of the limit, so
+// that the log is compacted; above the limit, the writes adding data are
+// refused until the usage goes back below it.
+func (s *EtcdServer) monitorMemory() {
+	if s.Cfg.MemoryLimitBytes == 0 {
+		return
+	}
+	limit := int64(s.Cfg.MemoryLimitBytes)
+	memoryLimitBytes.Set(float64(limit))
+	for {
+		select {
+		case <-time.After(memoryLimitInterval):
+		case <-s.stopping:
+			return
+		}
+
+		storeBytes, logBytes := v2store.EstimateMemory(s.v2store), raftLogBytes(s.r.raftStorage)
+		memoryEstimatedBytes.WithLabelValues("v2-store").Set(float64(storeBytes))
+		memoryEstimatedBytes.WithLabelValues("raft-log").Set(float64(logBytes))
+
+		state := memoryStateOf(storeBytes+logBytes, limit)
+		if state != memoryOK && logBytes >= limit/10 {
+			atomic.StoreInt32(&s.memorySnapshot, 1)
+		}
+		if prev := atomic.SwapInt32(&s.memoryState, state); prev != state {
+			s.reportMemoryState(prev, state, storeBytes, logBytes)
+		}
+	}
+}
+
+func (s *EtcdServer) reportMemoryState(prev, state int32, storeBytes, logBytes int64) {
+	memoryExceededGauge.Set(0)
+	if state == memoryExceeded {
+		memoryExceededGauge.Set(1)
+	}
+	lg := s.getLogger()
+	if state > prev {
+		if lg != nil {
+			lg.Warn(
+				"estimated memory usage is close to or above the memory limit",
+				zap.String("local-member-id", s.ID().String()),
+				zap.String("state", memoryStateNames[state]),
+				zap.String("v2-store-size", humanize.Bytes(uint64(storeBytes))),
+				zap.String("raft-log-size", humanize.Bytes(uint64(logBytes))),
+				zap.String("memory-limit", humanize.Bytes(s.Cfg.MemoryLimitBytes)),
+				zap.Bool("refusing-writes", state == memoryExceeded),
+			)
+		} else {
+			plog.Warningf("estimated memory usage of %s (v2 store %s, raft log %s) is %s against the limit %s",
+				s.ID(), humanize.Bytes(uint64(storeBytes)), humanize.Bytes(uint64(logBytes)), memoryStateNames[state], humanize.Bytes(s.Cfg.MemoryLimitBytes))
+		}
+		return
+	}
+	if lg != nil {
+		lg.Info(
+			"estimated memory usage decreased",
+			zap.String("local-member-id", s.ID().String()),
+			zap.String("state", memoryStateNames[state]),
+			zap.String("v2-store-size", humanize.Bytes(uint64(storeBytes))),
+			zap.String("raft-log-size", humanize.Bytes(uint64(logBytes))),
+			zap.String("memory-limit", humanize.Bytes(s.Cfg.MemoryLimitBytes)),
+		)
+	} else {
+		plog.Infof("estimated memory usage of %s (v2 store %s, raft log %s) decreased to %s against the limit %s",
+			s.ID(), humanize.Bytes(uint64(storeBytes)), humanize.Bytes(uint64(logBytes)), memoryStateNames[state], humanize.Bytes(s.Cfg.MemoryLimitBytes))
+	}
+}
+
+// memorySnapshotRequested returns true, once, if a snapshot was requested
+// to compact the raft log since the last call.
+func (s *EtcdServer) memorySnapshotRequested() bool {
+	return atomic.CompareAndSwapInt32(&s.memorySnapshot, 1, 0)
+}
+
+// checkMemoryLimit returns ErrMemoryLimitExceeded if the estimated memory
+// usage is above the memory limit.
+func (s *EtcdServer) checkMemoryLimit() error {
+	if atomic.LoadInt32(&s.memoryState) == memoryExceeded {
+		memoryWritesRefused.Inc()
+		return ErrMemoryLimitExceeded
+	}
+	return nil
+}
+
+// checkV2MemoryLimit refuses the v2 writes setting or creating a key while
+// the memory limit is exceeded. The deletes and the TTL refreshes, which
+// do not add data, are still accepted.
+func (s *EtcdServer) checkV2MemoryLimit(r *pb.Request) error {
+	if (r.Method != "PUT" && r.Method != "POST") || (r.Refresh != nil && *r.Refresh) {
+		return nil
+	}
+	if err := s.checkMemoryLimit(); err != nil {
+		return v2error.NewError(v2error.EcodeMemoryLimit, "", s.v2store.Index())
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdserver
+
+import (
+	"testing"
+
+	"go.etcd.io/etcd/etcdserver/api/v2error"
+	"go.etcd.io/etcd/etcdserver/api/v2store"
+	pb "go.etcd.io/etcd/etcdserver/etcdserverpb"
+	"go.etcd.io/etcd/raft"
+	"go.etcd.io/etcd/raft/raftpb"
+)
+
+func TestMemoryStateOf(t *testing.T) {
+	tests := []struct {
+		usage int64
+		w     int32
+	}{
+		{0, memoryOK},
+		{799, memoryOK},
+		{800, memoryHigh},
+		{999, memoryHigh},
+		{1000, memoryExceeded},
+		{2000, memoryExceeded},
+	}
+	for i, tt := range tests {
+		if g := memoryStateOf(tt.usage, 1000); g != tt.w {
+			t.Errorf("#%d: state = %s, want %s", i, memoryStateNames[g], memoryStateNames[tt.w])
+		}
+	}
+}
+
+func TestRaftLogBytes(t *testing.T) {
+	rs := raft.NewMemoryStorage()
+	if n := raftLogBytes(rs); n != 0 {
+		t.Errorf("size = %d, want 0", n)
+	}
+	ents := []raftpb.Entry{
+		{Index: 1, Term: 1, Data: make([]byte, 100)},
+		{Index: 2, Term: 1, Data: make([]byte, 200)},
+	}
+	if err := rs.Append(ents); err != nil {
+		t.Fatal(err)
+	}
+	w := int64(ents[0].Size() + ents[1].Size())
+	if n := raftLogBytes(rs); n != w {
+		t.Errorf("size = %d, want %d", n, w)
+	}
+
+	// the compacted entries are no longer held
+	if err := rs.Compact(1); err != nil {
+		t.Fatal(err)
+	}
+	if n := raftLogBytes(rs); n != int64(ents[1].Size()) {
+		t.Errorf("size = %d, want %d", n, ents[1].Size())
+	}
+}
+
+func TestCheckV2MemoryLimit(t *testing.T) {
+	s := &EtcdServer{v2store: v2store.New(StoreClusterPrefix, StoreKeysPrefix)}
+	refresh := true
+	tests := []struct {
+		r       pb.Request
+		wrefuse bool
+	}{
+		{pb.Request{Method: "PUT", Path: "/foo"}, true},
+		{pb.Request{Method: "POST", Path: "/foo"}, true},
+		{pb.Request{Method: "PUT", Path: "/foo", Refresh: &refresh}, false},
+		{pb.Request{Method: "DELETE", Path: "/foo"}, false},
+		{pb.Request{Method: "GET", Path: "/foo"}, false},
+	}
+	for i, tt := range tests {
+		s.memoryState = memoryOK
+		if err := s.checkV2MemoryLimit(&tt.r); err != nil {
+			t.Errorf("#%d: unexpected error %v below the limit", i, err)
+		}
+
+		s.memoryState = memoryExceeded
+		err := s.checkV2MemoryLimit(&tt.r)
+		if !tt.wrefuse {
+			if err != nil {
+				t.Errorf("#%d: unexpected error %v", i, err)
+			}
+			continue
+		}
+		if e, ok := err.(*v2error.Error); !ok || e.ErrorCode != v2error.EcodeMemoryLimit {
+			t.Errorf("#%d: err = %v, want code %d", i, err, v2error.EcodeMemoryLimit)
+		}
+	}
+}
+
+func TestCheckMemoryLimit(t *testing.T) {
+	s := &EtcdServer{}
+	for _, state := range []int32{memoryOK, memoryHigh} {
+		s.memoryState = state
+		if err := s.checkMemoryLimit(); err != nil {
+			t.Errorf("state %s: unexpected error %v", memoryStateNames[state], err)
+		}
+	}
+	s.memoryState = memoryExceeded
+	if err := s.checkMemoryLimit(); err != ErrMemoryLimitExceeded {
+		t.Errorf("err = %v, want %v", err, ErrMemoryLimitExceeded)
+	}
+}
+
+func TestShouldSnapshotMemoryRequested(t *testing.T) {
+	s := &EtcdServer{Cfg: ServerConfig{SnapshotCount: 100000}, memorySnapshot: 1}
+	ep := &etcdProgress{snapi: 5, appliedi: 5}
+	// nothing was applied since the last snapshot
+	if s.shouldSnapshot(ep) {
+		t.Error("shouldSnapshot = true, want false without applied entries")
+	}
+
+	ep.appliedi = 6
+	if !s.shouldSnapshot(ep) {
+		t.Error("shouldSnapshot = false, want true when requested")
+	}
+	// the request is consumed
+	if s.shouldSnapshot(ep) {
+		t.Error("shouldSnapshot = true, want false once the request is consumed")
+	}
+}
+
+func TestTxnHasPuts(t *testing.T) {
+	put := &pb.RequestOp{Request: &pb.RequestOp_RequestPut{RequestPut: &pb.PutRequest{Key: []byte("foo")}}}
+	del := &pb.RequestOp{Request: &pb.RequestOp_RequestDeleteRange{RequestDeleteRange: &pb.DeleteRangeRequest{Key: []byte("foo")}}}
+	nested := &pb.RequestOp{Request: &pb.RequestOp_RequestTxn{RequestTxn: &pb.TxnRequest{Failure: []*pb.RequestOp{put}}}}
+	tests := []struct {
+		r *pb.TxnRequest
+		w bool
+	}{
+		{&pb.TxnRequest{}, false},
+		{&pb.TxnRequest{Success: []*pb.RequestOp{del}}, false},
+		{&pb.TxnRequest{Failure: []*pb.RequestOp{del, put}}, true},
+		{&pb.TxnRequest{Success: []*pb.RequestOp{nested}}, true},
+	}
+	for i, tt := range tests {
+		if g := txnHasPuts(tt.r); g != tt.w {
+			t.Errorf("#%d: txnHasPuts = %v, want %v", i, g, tt.w)
+		}
+	}
+}
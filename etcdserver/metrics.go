@@ -50,12 +50,49 @@ var (
 		Name:      "heartbeat_send_failures_total",
 		Help:      "The total number of leader heartbeat send failures (likely overloaded from slow disk).",
 	})
+	raftTicksMissed = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "etcd",
+		Subsystem: "server",
+		Name:      "raft_ticks_missed_total",
+		Help:      "The total number of raft ticks missed (likely starved of CPU), which are not replayed.",
+	})
 	slowApplies = prometheus.NewCounter(prometheus.CounterOpts{
 		Namespace: "etcd",
 		Subsystem: "server",
 		Name:      "slow_apply_total",
 		Help:      "The total number of slow apply requests (likely overloaded from slow disk).",
 	})
+	diskDegraded = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "etcd",
+		Subsystem: "server",
+		Name:      "disk_degraded",
+		Help:      "Whether or not the disk latency of this member is above the degraded threshold. 1 if is, 0 otherwise.",
+	})
+	corruptionsDetected = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "etcd",
+		Subsystem: "server",
+		Name:      "corruptions_detected_total",
+		Help:      "The total number of periodic corruption checks that found mismatched hashes or revisions between members.",
+	})
+	recursiveKeyLimit = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "etcd",
+		Subsystem: "server",
+		Name:      "v2_recursive_key_limit_total",
+		Help:      "The total number of v2 recursive requests rejected for touching more keys than the maximum, or within 20% of it.",
+	},
+		[]string{"method", "result"})
+	v2RequestsDeduplicated = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "etcd",
+		Subsystem: "server",
+		Name:      "v2_requests_deduplicated_total",
+		Help:      "The total number of v2 writes not applied since a write with the same client request ID already was.",
+	})
+	v2WatchEventsReplayed = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "etcd",
+		Subsystem: "server",
+		Name:      "v2_watch_events_replayed_total",
+		Help:      "The total number of v2 watches from a cleared index served with an event replayed from the committed entries.",
+	})
 	proposalsCommitted = prometheus.NewGauge(prometheus.GaugeOpts{
 		Namespace: "etcd",
 		Subsystem: "server",
@@ -74,6 +111,80 @@ var (
 		Name:      "proposals_pending",
 		Help:      "The current number of pending proposals to commit.",
 	})
+	proposalsForwardedPending = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "etcd",
+		Subsystem: "server",
+		Name:      "proposals_forwarded_pending",
+		Help:      "The current number of local proposals forwarded to the leader waiting to be applied.",
+	})
+	proposalsForwardedShed = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "etcd",
+		Subsystem: "server",
+		Name:      "proposals_forwarded_shed_total",
+		Help:      "The total number of proposals refused while the queue of the proposals forwarded to the leader is full.",
+	})
+	raftReadyUnstableEntries = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "etcd",
+		Subsystem: "server",
+		Name:      "raft_ready_unstable_entries",
+		Help:      "The number of entries of the last raft Ready to persist.",
+	})
+	raftReadyUnstableBytes = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "etcd",
+		Subsystem: "server",
+		Name:      "raft_ready_unstable_bytes",
+		Help:      "The size of the entries of the last raft Ready to persist.",
+	})
+	raftUncommittedEntries = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "etcd",
+		Subsystem: "server",
+		Name:      "raft_uncommitted_entries",
+		Help:      "The number of entries appended to the local raft log and not yet committed.",
+	})
+	raftProposeToCommitSec = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "etcd",
+		Subsystem: "server",
+		Name:      "raft_propose_to_commit_duration_seconds",
+		Help:      "The latency distributions of the commit of the entries appended by the leader.",
+
+		// lowest bucket start of upper bound 0.0001 sec (0.1 ms) with factor 2
+		// highest bucket start of 0.0001 sec * 2^15 == 3.2768 sec
+		Buckets: prometheus.ExponentialBuckets(0.0001, 2, 16),
+	})
+	raftProposalsQueued = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "etcd",
+		Subsystem: "server",
+		Name:      "raft_proposals_queued",
+		Help:      "The current number of proposals waiting for the raft node to accept them.",
+	})
+	raftCommitToApplySec = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "etcd",
+		Subsystem: "server",
+		Name:      "raft_commit_to_apply_duration_seconds",
+		Help:      "The latency distributions of the apply of the committed entries, from the raft Ready holding them.",
+
+		// lowest bucket start of upper bound 0.0001 sec (0.1 ms) with factor 2
+		// highest bucket start of 0.0001 sec * 2^15 == 3.2768 sec
+		Buckets: prometheus.ExponentialBuckets(0.0001, 2, 16),
+	})
+	clientRequestsShed = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "etcd",
+		Subsystem: "server",
+		Name:      "client_requests_shed_total",
+		Help:      "The total number of client requests refused while the member is overloaded.",
+	})
+	keyWritesRateLimited = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "etcd",
+		Subsystem: "server",
+		Name:      "key_writes_rate_limited_total",
+		Help:      "The total number of client writes refused for exceeding the write rate limit of their key.",
+	})
+	keyWritesQuotaExceeded = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "etcd",
+		Subsystem: "server",
+		Name:      "key_writes_quota_exceeded_total",
+		Help:      "The total number of client writes refused for creating more keys than the quota of their prefix.",
+	})
 	proposalsFailed = prometheus.NewCounter(prometheus.CounterOpts{
 		Namespace: "etcd",
 		Subsystem: "server",
@@ -125,6 +236,90 @@ var (
 		Help:      "Server or member ID in hexadecimal format. 1 for 'server_id' label with current ID.",
 	},
 		[]string{"server_id"})
+	walFileBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "etcd",
+		Subsystem: "disk",
+		Name:      "wal_file_bytes",
+		Help:      "The size of each file of the WAL directory.",
+	}, []string{"file"})
+	walTotalBytes = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "etcd",
+		Subsystem: "disk",
+		Name:      "wal_total_bytes",
+		Help:      "The total size of the files of the WAL directory.",
+	})
+	snapshotFileBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "etcd",
+		Subsystem: "disk",
+		Name:      "snapshot_file_bytes",
+		Help:      "The size of each file of the snapshot directory.",
+	}, []string{"file"})
+	snapshotTotalBytes = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "etcd",
+		Subsystem: "disk",
+		Name:      "snapshot_total_bytes",
+		Help:      "The total size of the files of the snapshot directory.",
+	})
+	diskAvailableBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "etcd",
+		Subsystem: "disk",
+		Name:      "available_bytes",
+		Help:      "The space available on the filesystem of the data directory, or of the WAL directory.",
+	}, []string{"dir"})
+	diskSizeBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "etcd",
+		Subsystem: "disk",
+		Name:      "size_bytes",
+		Help:      "The size of the filesystem of the data directory, or of the WAL directory.",
+	}, []string{"dir"})
+	memoryLimitBytes = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "etcd",
+		Subsystem: "server",
+		Name:      "memory_limit_bytes",
+		Help:      "The memory limit of the estimated memory usage.",
+	})
+	memoryEstimatedBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "etcd",
+		Subsystem: "server",
+		Name:      "memory_estimated_bytes",
+		Help:      "The estimated memory usage of the v2 store and of the raft log.",
+	}, []string{"part"})
+	memoryExceededGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "etcd",
+		Subsystem: "server",
+		Name:      "memory_limit_exceeded",
+		Help:      "Whether or not the estimated memory usage is above the memory limit. 1 if is, 0 otherwise.",
+	})
+	memoryWritesRefused = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "etcd",
+		Subsystem: "server",
+		Name:      "memory_limit_writes_refused_total",
+		Help:      "The total number of client writes refused for the estimated memory usage being above the memory limit.",
+	})
+	peerProgressState = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "etcd",
+		Subsystem: "server",
+		Name:      "peer_progress_state",
+		Help:      "The replication state the leader tracks each follower in. 1 for the current state of the follower, 0 otherwise.",
+	}, []string{"To", "State"})
+	peerReplicationLag = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "etcd",
+		Subsystem: "server",
+		Name:      "peer_replication_lag_entries",
+		Help:      "The number of committed entries each follower does not have, as tracked by the leader.",
+	}, []string{"To"})
+	pendingWaits = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "etcd",
+		Subsystem: "server",
+		Name:      "pending_waits",
+		Help:      "The current number of proposals and configuration changes waited for to be applied.",
+	})
+	pendingWaitOldestSeconds = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "etcd",
+		Subsystem: "server",
+		Name:      "pending_wait_oldest_seconds",
+		Help:      "The age of the oldest wait of a proposal or configuration change for its apply.",
+	})
 )
 
 func init() {
@@ -132,11 +327,28 @@ func init() {
 	prometheus.MustRegister(isLeader)
 	prometheus.MustRegister(leaderChanges)
 	prometheus.MustRegister(heartbeatSendFailures)
+	prometheus.MustRegister(raftTicksMissed)
 	prometheus.MustRegister(slowApplies)
+	prometheus.MustRegister(diskDegraded)
+	prometheus.MustRegister(corruptionsDetected)
+	prometheus.MustRegister(recursiveKeyLimit)
+	prometheus.MustRegister(v2RequestsDeduplicated)
 	prometheus.MustRegister(proposalsCommitted)
 	prometheus.MustRegister(proposalsApplied)
 	prometheus.MustRegister(proposalsPending)
+	prometheus.MustRegister(v2WatchEventsReplayed)
+	prometheus.MustRegister(proposalsForwardedPending)
+	prometheus.MustRegister(proposalsForwardedShed)
 	prometheus.MustRegister(proposalsFailed)
+	prometheus.MustRegister(raftReadyUnstableEntries)
+	prometheus.MustRegister(raftReadyUnstableBytes)
+	prometheus.MustRegister(raftUncommittedEntries)
+	prometheus.MustRegister(raftProposeToCommitSec)
+	prometheus.MustRegister(raftProposalsQueued)
+	prometheus.MustRegister(raftCommitToApplySec)
+	prometheus.MustRegister(clientRequestsShed)
+	prometheus.MustRegister(keyWritesRateLimited)
+	prometheus.MustRegister(keyWritesQuotaExceeded)
 	prometheus.MustRegister(slowReadIndex)
 	prometheus.MustRegister(readIndexFailed)
 	prometheus.MustRegister(leaseExpired)
@@ -144,6 +356,20 @@ func init() {
 	prometheus.MustRegister(currentVersion)
 	prometheus.MustRegister(currentGoVersion)
 	prometheus.MustRegister(serverID)
+	prometheus.MustRegister(walFileBytes)
+	prometheus.MustRegister(walTotalBytes)
+	prometheus.MustRegister(snapshotFileBytes)
+	prometheus.MustRegister(snapshotTotalBytes)
+	prometheus.MustRegister(diskAvailableBytes)
+	prometheus.MustRegister(diskSizeBytes)
+	prometheus.MustRegister(memoryLimitBytes)
+	prometheus.MustRegister(memoryEstimatedBytes)
+	prometheus.MustRegister(memoryExceededGauge)
+	prometheus.MustRegister(memoryWritesRefused)
+	prometheus.MustRegister(peerProgressState)
+	prometheus.MustRegister(peerReplicationLag)
+	prometheus.MustRegister(pendingWaits)
+	prometheus.MustRegister(pendingWaitOldestSeconds)
 
 	currentVersion.With(prometheus.Labels{
 		"server_version": version.Version,
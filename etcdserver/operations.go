@@ -0,0 +1,202 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdserver
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// OperationRecursiveDelete is the kind of the v2 recursive deletes.
+	OperationRecursiveDelete = "recursive-delete"
+	// OperationRangeDelete is the kind of the v3 deletes of a key range.
+	OperationRangeDelete = "range-delete"
+	// OperationCompaction is the kind of the scheduled compaction deleting
+	// the compacted revisions from the backend.
+	OperationCompaction = "compaction"
+)
+
+// Operation is an expensive operation in flight on the member.
+type Operation struct {
+	ID      string    `json:"id"`
+	Kind    string    `json:"kind"`
+	Detail  string    `json:"detail"`
+	Started time.Time `json:"started"`
+}
+
+// scheduledCompactor is implemented by the mvcc stores whose scheduled
+// compaction can be canceled.
+type scheduledCompactor interface {
+	ScheduledCompaction() (rev int64, started time.Time, ok bool)
+	CancelScheduledCompaction() bool
+}
+
+// trackedOperation is an operation whose client is canceled by cancel,
+// until the operation is proposed.
+type trackedOperation struct {
+	Operation
+	cancel   context.CancelFunc
+	proposed bool
+}
+
+// operationKey is the context key of the ID of the tracked operation.
+type operationKey struct{}
+
+// operations tracks the deletes in flight. The zero value tracks none.
+type operations struct {
+	mu  sync.Mutex
+	ops map[string]*trackedOperation
+}
+
+// start tracks the operation of the given id until the returned done
+// function is called, and returns the context canceled on its cancel.
+func (o *operations) start(ctx context.Context, id, kind, detail string) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.WithValue(ctx, operationKey{}, id))
+	o.mu.Lock()
+	if o.ops == nil {
+		o.ops = make(map[string]*trackedOperation)
+	}
+	o.ops[id] = &trackedOperation{
+		Operation: Operation{ID: id, Kind: kind, Detail: detail, Started: time.Now()},
+		cancel:    cancel,
+	}
+	o.mu.Unlock()
+	return ctx, func() {
+		o.mu.Lock()
+		delete(o.ops, id)
+		o.mu.Unlock()
+		cancel()
+	}
+}
+
+func (o *operations) list() []Operation {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	ops := make([]Operation, 0, len(o.ops))
+	for _, op := range o.ops {
+		ops = append(ops, op.Operation)
+	}
+	return ops
+}
+
+// propose marks the operation tracked in ctx, if any, as proposed, so
+// that it can no longer be canceled. It returns the error of ctx if the
+// operation was canceled first.
+func (o *operations) propose(ctx context.Context) error {
+	id, ok := ctx.Value(operationKey{}).(string)
+	if !ok {
+		return nil
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if op, ok := o.ops[id]; ok {
+		op.proposed = true
+	}
+	return nil
+}
+
+func (o *operations) cancel(id string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	op, ok := o.ops[id]
+	if !ok {
+		return ErrOperationNotFound
+	}
+	if op.proposed {
+		return ErrOperationNotCancelable
+	}
+	op.cancel()
+	return nil
+}
+
+// OperationCanceler lists and cancels the expensive operations in flight
+// on the member.
+type OperationCanceler interface {
+	// Operations returns the operations in flight, oldest first.
+	Operations() []Operation
+	// CancelOperation cancels the operation of the given id. A canceled
+	// compaction stops, and its remaining revisions are deleted by the
+	// next one. A delete is only canceled before it is proposed, and its
+	// client gets ErrCanceled; once proposed, every member applies it,
+	// so ErrOperationNotCancelable is returned.
+	CancelOperation(id string) error
+}
+
+func (s *EtcdServer) Operations() []Operation {
+	ops := s.ops.list()
+	if sc, ok := s.kv.(scheduledCompactor); ok {
+		if rev, started, ok := sc.ScheduledCompaction(); ok {
+			ops = append(ops, Operation{
+				ID:      compactionOperationID(rev),
+				Kind:    OperationCompaction,
+				Detail:  fmt.Sprintf("revision %d", rev),
+				Started: started,
+			})
+		}
+	}
+	sort.Slice(ops, func(i, j int) bool {
+		if ops[i].Started.Equal(ops[j].Started) {
+			return ops[i].ID < ops[j].ID
+		}
+		return ops[i].Started.Before(ops[j].Started)
+	})
+	return ops
+}
+
+func (s *EtcdServer) CancelOperation(id string) error {
+	if strings.HasPrefix(id, OperationCompaction+"-") {
+		sc, ok := s.kv.(scheduledCompactor)
+		if !ok {
+			return ErrOperationNotFound
+		}
+		rev, _, ok := sc.ScheduledCompaction()
+		if !ok || compactionOperationID(rev) != id || !sc.CancelScheduledCompaction() {
+			return ErrOperationNotFound
+		}
+		if lg := s.getLogger(); lg != nil {
+			lg.Warn("canceling scheduled compaction", zap.String("operation-id", id))
+		} else {
+			plog.Warningf("canceling scheduled compaction %s", id)
+		}
+		return nil
+	}
+	if err := s.ops.cancel(id); err != nil {
+		return err
+	}
+	if lg := s.getLogger(); lg != nil {
+		lg.Warn("canceled operation", zap.String("operation-id", id))
+	} else {
+		plog.Warningf("canceled operation %s", id)
+	}
+	return nil
+}
+
+func compactionOperationID(rev int64) string {
+	return fmt.Sprintf("%s-%d", OperationCompaction, rev)
+}
+
+func deleteOperationID(kind string, reqID uint64) string {
+	return fmt.Sprintf("%s-%x", kind, reqID)
+}
@@ -0,0 +1,109 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdserver
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"go.etcd.io/etcd/etcdserver/api/v2store"
+	"go.etcd.io/etcd/pkg/idutil"
+	"go.etcd.io/etcd/pkg/mock/mockwait"
+)
+
+func TestCancelOperation(t *testing.T) {
+	s := &EtcdServer{lgMu: new(sync.RWMutex), lg: testLogger}
+
+	ctx1, done1 := s.ops.start(context.Background(), deleteOperationID(OperationRecursiveDelete, 1), OperationRecursiveDelete, "/foo")
+	defer done1()
+	ctx2, done2 := s.ops.start(context.Background(), deleteOperationID(OperationRangeDelete, 2), OperationRangeDelete, `["a", "b")`)
+
+	ops := s.Operations()
+	if len(ops) != 2 || ops[0].ID != "recursive-delete-1" || ops[1].ID != "range-delete-2" {
+		t.Fatalf("operations = %+v, want recursive-delete-1 and range-delete-2", ops)
+	}
+
+	if err := s.CancelOperation("recursive-delete-1"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-ctx1.Done():
+	default:
+		t.Errorf("context of the canceled operation is not done")
+	}
+	if ctx2.Err() != nil {
+		t.Errorf("context of the other operation = %v, want not done", ctx2.Err())
+	}
+
+	done2()
+	if ops = s.Operations(); len(ops) != 1 || ops[0].ID != "recursive-delete-1" {
+		t.Errorf("operations = %+v, want recursive-delete-1", ops)
+	}
+	for _, id := range []string{"range-delete-2", "compaction-10", "unknown"} {
+		if err := s.CancelOperation(id); err != ErrOperationNotFound {
+			t.Errorf("cancel %s: err = %v, want %v", id, err, ErrOperationNotFound)
+		}
+	}
+}
+
+func TestCancelOperationProposed(t *testing.T) {
+	n := newNodeRecorder()
+	st := v2store.New()
+	if _, err := st.Create(StoreKeysPrefix+"/foo/a", false, "1", false, v2store.TTLOptionSet{ExpireTime: v2store.Permanent}); err != nil {
+		t.Fatal(err)
+	}
+	s := &EtcdServer{
+		lgMu:     new(sync.RWMutex),
+		lg:       testLogger,
+		Cfg:      ServerConfig{Logger: testLogger, TickMs: 1},
+		r:        *newRaftNode(raftNodeConfig{lg: testLogger, Node: n}),
+		w:        mockwait.NewNop(),
+		v2store:  st,
+		reqIDGen: idutil.NewGenerator(0, time.Time{}),
+	}
+	h := &reqV2HandlerEtcdServer{s: s}
+
+	// canceled before its proposal, the delete leaves the keys
+	ctx, done := s.ops.start(context.Background(), deleteOperationID(OperationRecursiveDelete, 1), OperationRecursiveDelete, "/foo")
+	if err := s.CancelOperation("recursive-delete-1"); err != nil {
+		t.Fatal(err)
+	}
+	r := &RequestV2{ID: 1, Method: "DELETE", Path: StoreKeysPrefix + "/foo", Recursive: true}
+	if _, err := h.processRaftRequest(ctx, r); err != ErrCanceled {
+		t.Errorf("err = %v, want %v", err, ErrCanceled)
+	}
+	done()
+	if acts := n.Action(); len(acts) != 0 {
+		t.Errorf("actions = %+v, want no proposal", acts)
+	}
+	if _, err := st.Get(StoreKeysPrefix+"/foo/a", false, false); err != nil {
+		t.Errorf("key of the canceled delete: %v", err)
+	}
+
+	// once proposed, it is not cancelable
+	ctx, done = s.ops.start(context.Background(), deleteOperationID(OperationRecursiveDelete, 2), OperationRecursiveDelete, "/foo")
+	defer done()
+	if err := s.ops.propose(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CancelOperation("recursive-delete-2"); err != ErrOperationNotCancelable {
+		t.Errorf("err = %v, want %v", err, ErrOperationNotCancelable)
+	}
+	if ctx.Err() != nil {
+		t.Errorf("context of the proposed operation = %v, want not done", ctx.Err())
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdserver
+
+import (
+	"time"
+
+	stats "go.etcd.io/etcd/etcdserver/api/v2stats"
+	"go.etcd.io/etcd/pkg/types"
+	"go.etcd.io/etcd/raft"
+)
+
+// peerProgressInterval is the interval the replication progress of the
+// followers is reported at.
+const peerProgressInterval = time.Second
+
+var progressStateNames = map[raft.ProgressStateType]string{
+	raft.ProgressStateProbe:     "probe",
+	raft.ProgressStateReplicate: "replicate",
+	raft.ProgressStateSnapshot:  "snapshot",
+}
+
+// followerProgress returns the replication progress of the followers by
+// member ID, or nil if st is not the status of a leader.
+func followerProgress(st raft.Status) map[string]stats.ProgressStats {
+	if st.RaftState != raft.StateLeader {
+		return nil
+	}
+	progress := make(map[string]stats.ProgressStats, len(st.Progress))
+	for id, pr := range st.Progress {
+		if id == st.ID {
+			continue
+		}
+		p := stats.ProgressStats{
+			State:           progressStateNames[pr.State],
+			Match:           pr.Match,
+			Next:            pr.Next,
+			RecentActive:    pr.RecentActive,
+			Paused:          pr.Paused,
+			PendingSnapshot: pr.PendingSnapshot,
+		}
+		if st.Commit > pr.Match {
+			p.Lag = st.Commit - pr.Match
+		}
+		progress[types.ID(id).String()] = p
+	}
+	return progress
+}
+
+// monitorPeerProgress reports the replication progress of the followers
+// in the leader stats and the metrics while the member is the leader, so
+// that a follower lagging behind, and why, can be told from them.
+func (s *EtcdServer) monitorPeerProgress() {
+	var reported map[string]stats.ProgressStats
+	for {
+		select {
+		case <-time.After(peerProgressInterval):
+		case <-s.stopping:
+			return
+		}
+
+		progress := followerProgress(s.r.Status())
+		for id := range reported {
+			if _, ok := progress[id]; !ok {
+				for _, state := range progressStateNames {
+					peerProgressState.DeleteLabelValues(id, state)
+				}
+				peerReplicationLag.DeleteLabelValues(id)
+			}
+		}
+		for id, p := range progress {
+			for _, state := range progressStateNames {
+				v := 0.0
+				if state == p.State {
+					v = 1
+				}
+				peerProgressState.WithLabelValues(id, state).Set(v)
+			}
+			peerReplicationLag.WithLabelValues(id).Set(float64(p.Lag))
+		}
+		s.lstats.SetProgress(progress)
+		reported = progress
+	}
+}
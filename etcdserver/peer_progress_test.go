@@ -0,0 +1,78 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdserver
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	stats "go.etcd.io/etcd/etcdserver/api/v2stats"
+	"go.etcd.io/etcd/raft"
+	"go.etcd.io/etcd/raft/raftpb"
+)
+
+func TestFollowerProgress(t *testing.T) {
+	st := raft.Status{
+		ID:        1,
+		HardState: raftpb.HardState{Commit: 10},
+		SoftState: raft.SoftState{RaftState: raft.StateLeader},
+		Progress: map[uint64]raft.Progress{
+			1: {Match: 10, Next: 11, State: raft.ProgressStateReplicate},
+			2: {Match: 10, Next: 11, State: raft.ProgressStateReplicate, RecentActive: true},
+			3: {Match: 4, Next: 5, State: raft.ProgressStateProbe, Paused: true},
+			4: {Match: 0, Next: 1, State: raft.ProgressStateSnapshot, PendingSnapshot: 8},
+		},
+	}
+	w := map[string]stats.ProgressStats{
+		"2": {State: "replicate", Match: 10, Next: 11, RecentActive: true},
+		"3": {State: "probe", Match: 4, Next: 5, Lag: 6, Paused: true},
+		"4": {State: "snapshot", Next: 1, Lag: 10, PendingSnapshot: 8},
+	}
+	if g := followerProgress(st); !reflect.DeepEqual(g, w) {
+		t.Errorf("progress = %+v, want %+v", g, w)
+	}
+
+	st.RaftState = raft.StateFollower
+	if g := followerProgress(st); g != nil {
+		t.Errorf("progress = %+v, want nil on a follower", g)
+	}
+}
+
+func TestLeaderStatsProgress(t *testing.T) {
+	ls := stats.NewLeaderStats("1")
+	ls.Follower("2")
+	ls.SetProgress(map[string]stats.ProgressStats{
+		"2": {State: "probe", Match: 4, Next: 5, Lag: 6},
+		// not a follower known to the transport
+		"3": {State: "replicate"},
+	})
+
+	var g struct {
+		Followers map[string]struct {
+			Progress *stats.ProgressStats `json:"progress"`
+		} `json:"followers"`
+	}
+	if err := json.Unmarshal(ls.JSON(), &g); err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Followers) != 1 {
+		t.Fatalf("len(followers) = %d, want 1", len(g.Followers))
+	}
+	w := &stats.ProgressStats{State: "probe", Match: 4, Next: 5, Lag: 6}
+	if p := g.Followers["2"].Progress; !reflect.DeepEqual(p, w) {
+		t.Errorf("progress = %+v, want %+v", p, w)
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdserver
+
+import (
+	"time"
+
+	"go.etcd.io/etcd/pkg/types"
+	"go.etcd.io/etcd/pkg/wait"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// pendingProposalsInterval is the interval the waits of the proposals
+	// are checked at.
+	pendingProposalsInterval = 5 * time.Second
+	// stalePendingProposalAge is the age above which the wait of a proposal
+	// is likely leaked, the client requests timing out well before.
+	stalePendingProposalAge = time.Minute
+)
+
+// PendingProposal is a proposal whose proposer waits for its apply.
+type PendingProposal struct {
+	// ID is the ID of the request proposed, in hexadecimal.
+	ID         string    `json:"id"`
+	Registered time.Time `json:"registered"`
+	Age        string    `json:"age"`
+}
+
+// ProposalLister lists the proposals waited for.
+type ProposalLister interface {
+	// PendingProposals returns, oldest first, at most limit proposals
+	// waited for, or all of them if limit is not positive.
+	PendingProposals(limit int) []PendingProposal
+}
+
+func (s *EtcdServer) PendingProposals(limit int) []PendingProposal {
+	return pendingProposals(s.w.Pending(), limit, time.Now())
+}
+
+func pendingProposals(ps []wait.Pending, limit int, now time.Time) []PendingProposal {
+	if limit > 0 && len(ps) > limit {
+		ps = ps[:limit]
+	}
+	pps := make([]PendingProposal, len(ps))
+	for i, p := range ps {
+		pps[i] = PendingProposal{
+			ID:         types.ID(p.ID).String(),
+			Registered: p.Registered,
+			Age:        now.Sub(p.Registered).String(),
+		}
+	}
+	return pps
+}
+
+// monitorPendingProposals reports the waits of the proposals, and warns
+// when more of them are older than stalePendingProposalAge, since they are
+// likely left behind by proposers that gave up.
+func (s *EtcdServer) monitorPendingProposals() {
+	reported := 0
+	for {
+		select {
+		case <-time.After(pendingProposalsInterval):
+		case <-s.stopping:
+			return
+		}
+
+		now := time.Now()
+		ps := s.w.Pending()
+		pendingWaits.Set(float64(len(ps)))
+		oldest := time.Duration(0)
+		if len(ps) > 0 {
+			oldest = now.Sub(ps[0].Registered)
+		}
+		pendingWaitOldestSeconds.Set(oldest.Seconds())
+
+		stale := 0
+		for stale < len(ps) && now.Sub(ps[stale].Registered) >= stalePendingProposalAge {
+			stale++
+		}
+		if stale > reported {
+			if lg := s.getLogger(); lg != nil {
+				lg.Warn(
+					"proposals waited for longer than expected; their waits may be leaked",
+					zap.String("local-member-id", s.ID().String()),
+					zap.Int("stale-waits", stale),
+					zap.Int("pending-waits", len(ps)),
+					zap.String("oldest-request-id", types.ID(ps[0].ID).String()),
+					zap.Duration("oldest-age", oldest),
+				)
+			} else {
+				plog.Warningf("%d of the %d proposals waited for on %s are older than %v; the oldest, %s, is %v old", stale, len(ps), s.ID(), stalePendingProposalAge, types.ID(ps[0].ID), oldest)
+			}
+		}
+		reported = stale
+	}
+}
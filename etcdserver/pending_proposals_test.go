@@ -0,0 +1,66 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdserver
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"go.etcd.io/etcd/pkg/wait"
+)
+
+func TestPendingProposals(t *testing.T) {
+	now := time.Now()
+	ps := []wait.Pending{
+		{ID: 0x1a, Registered: now.Add(-time.Minute)},
+		{ID: 0x2b, Registered: now.Add(-time.Second)},
+	}
+	all := []PendingProposal{
+		{ID: "1a", Registered: ps[0].Registered, Age: "1m0s"},
+		{ID: "2b", Registered: ps[1].Registered, Age: "1s"},
+	}
+	tests := []struct {
+		limit int
+		w     []PendingProposal
+	}{
+		{0, all},
+		{2, all},
+		{3, all},
+		{1, all[:1]},
+	}
+	for i, tt := range tests {
+		if g := pendingProposals(ps, tt.limit, now); !reflect.DeepEqual(g, tt.w) {
+			t.Errorf("#%d: proposals = %+v, want %+v", i, g, tt.w)
+		}
+	}
+}
+
+// TestPendingProposalsCanceled ensures that the proposals given up by
+// their proposers are no longer listed.
+func TestPendingProposalsCanceled(t *testing.T) {
+	s := &EtcdServer{w: wait.New()}
+	s.w.Register(1)
+	s.w.Register(2)
+	if pps := s.PendingProposals(0); len(pps) != 2 {
+		t.Fatalf("proposals = %+v, want 2", pps)
+	}
+
+	// a proposer giving up triggers its wait with nil
+	s.w.Trigger(1, nil)
+	if pps := s.PendingProposals(0); len(pps) != 1 || pps[0].ID != "2" {
+		t.Errorf("proposals = %+v, want only the proposal 2", pps)
+	}
+}
@@ -15,6 +15,7 @@
 package etcdserver
 
 import (
+	"context"
 	"encoding/json"
 	"expvar"
 	"log"
@@ -81,6 +82,8 @@ type apply struct {
 	snapshot raftpb.Snapshot
 	// notifyc synchronizes etcd server applies with the raft node
 	notifyc chan struct{}
+	// committed is the time the raft node received the entries.
+	committed time.Time
 }
 
 type raftNode struct {
@@ -99,9 +102,12 @@ type raftNode struct {
 	readStateC chan raft.ReadState
 
 	// utility
-	ticker *time.Ticker
+	ticker raftTicker
+	tt     tickTimer
 	// contention detectors for raft heartbeat message
 	td *contention.TimeoutDetector
+	// ready instruments the Ready loop
+	ready readyTracker
 
 	stopped chan struct{}
 	done    chan struct{}
@@ -121,6 +127,10 @@ type raftNodeConfig struct {
 	// clients should timeout and reissue their messages.
 	// If transport is nil, server will panic.
 	transport rafthttp.Transporter
+	// dw is notified of the latency of each WAL save; may be nil.
+	dw *diskWatchdog
+	// clock drives the ticks; the monotonic clock of the process if nil.
+	clock raftClock
 }
 
 func newRaftNode(cfg raftNodeConfig) *raftNode {
@@ -137,11 +147,15 @@ func newRaftNode(cfg raftNodeConfig) *raftNode {
 		stopped:    make(chan struct{}),
 		done:       make(chan struct{}),
 	}
+	if r.clock == nil {
+		r.clock = monotonicClock{}
+	}
 	if r.heartbeat == 0 {
-		r.ticker = &time.Ticker{}
+		r.ticker = nopTicker{}
 	} else {
-		r.ticker = time.NewTicker(r.heartbeat)
+		r.ticker = r.clock.NewTicker(r.heartbeat)
 	}
+	r.tt.interval = r.heartbeat
 	return r
 }
 
@@ -152,6 +166,33 @@ func (r *raftNode) tick() {
 	r.tickMu.Unlock()
 }
 
+// onTick ticks the raft node on a tick of its ticker.
+//
+// The ticks missed while the process was starved of CPU are counted, and
+// warned about at most once per missedTicksWarnInterval, but not replayed:
+// a burst of ticks would expire the election timeout of a follower before
+// it processes the heartbeats the leader sent meanwhile, and so start an
+// election while the leader is healthy.
+func (r *raftNode) onTick() {
+	now := r.clock.Now()
+	missed := r.tt.observe(now)
+	if missed > 0 {
+		raftTicksMissed.Add(float64(missed))
+	}
+	if missed = r.tt.warn(now, missed); missed > 0 {
+		if r.lg != nil {
+			r.lg.Warn(
+				"missed raft ticks since the last warning; the process is likely starved of CPU",
+				zap.Int("missed-ticks", missed),
+				zap.Duration("tick-interval", r.heartbeat),
+			)
+		} else {
+			plog.Warningf("missed %d raft ticks of %v since the last warning (the process is likely starved of CPU)", missed, r.heartbeat)
+		}
+	}
+	r.tick()
+}
+
 // start prepares and starts raftNode in a new goroutine. It is no longer safe
 // to modify the fields after it has been started.
 func (r *raftNode) start(rh *raftReadyHandler) {
@@ -163,9 +204,11 @@ func (r *raftNode) start(rh *raftReadyHandler) {
 
 		for {
 			select {
-			case <-r.ticker.C:
-				r.tick()
+			case <-r.ticker.C():
+				r.onTick()
+				r.ready.tick()
 			case rd := <-r.Ready():
+				now := time.Now()
 				if rd.SoftState != nil {
 					newLeader := rd.SoftState.Lead != raft.None && rh.getLead() != rd.SoftState.Lead
 					if newLeader {
@@ -203,11 +246,14 @@ func (r *raftNode) start(rh *raftReadyHandler) {
 					}
 				}
 
+				r.ready.observe(rd, islead, now)
+
 				notifyc := make(chan struct{}, 1)
 				ap := apply{
-					entries:  rd.CommittedEntries,
-					snapshot: rd.Snapshot,
-					notifyc:  notifyc,
+					entries:   rd.CommittedEntries,
+					snapshot:  rd.Snapshot,
+					notifyc:   notifyc,
+					committed: now,
 				}
 
 				updateCommittedIndex(&ap, rh)
@@ -227,6 +273,7 @@ func (r *raftNode) start(rh *raftReadyHandler) {
 				}
 
 				// gofail: var raftBeforeSave struct{}
+				start := time.Now()
 				if err := r.storage.Save(rd.HardState, rd.Entries); err != nil {
 					if r.lg != nil {
 						r.lg.Fatal("failed to save Raft hard state and entries", zap.Error(err))
@@ -234,6 +281,9 @@ func (r *raftNode) start(rh *raftReadyHandler) {
 						plog.Fatalf("raft save state and entries error: %v", err)
 					}
 				}
+				if len(rd.Entries) > 0 {
+					r.dw.observeWAL(time.Since(start))
+				}
 				if !raft.IsEmptyHardState(rd.HardState) {
 					proposalsCommitted.Set(float64(rd.HardState.Commit))
 				}
@@ -372,6 +422,14 @@ func (r *raftNode) processMessages(ms []raftpb.Message) []raftpb.Message {
 	return ms
 }
 
+// Propose proposes data to the raft node, counting the proposals waiting
+// for the node to accept them.
+func (r *raftNode) Propose(ctx context.Context, data []byte) error {
+	raftProposalsQueued.Inc()
+	defer raftProposalsQueued.Dec()
+	return r.Node.Propose(ctx, data)
+}
+
 func (r *raftNode) apply() chan apply {
 	return r.applyc
 }
@@ -465,6 +523,7 @@ func startNode(cfg ServerConfig, cl *membership.RaftCluster, ids []types.ID) (id
 		MaxInflightMsgs: maxInflightMsgs,
 		CheckQuorum:     true,
 		PreVote:         cfg.PreVote,
+		ReadOnlyOption:  readOnlyOption(cfg),
 	}
 	if cfg.Logger != nil {
 		// called after capnslog setting in "init" function
@@ -485,6 +544,17 @@ func startNode(cfg ServerConfig, cl *membership.RaftCluster, ids []types.ID) (id
 	return id, n, s, w
 }
 
+// readOnlyOption returns how raft confirms the leadership for the
+// linearizable reads: by a heartbeat round to a quorum by default, or from
+// the lease of the leader, which relies on CheckQuorum and on bounded clock
+// drift between the members.
+func readOnlyOption(cfg ServerConfig) raft.ReadOnlyOption {
+	if cfg.LeaseRead {
+		return raft.ReadOnlyLeaseBased
+	}
+	return raft.ReadOnlySafe
+}
+
 func restartNode(cfg ServerConfig, snapshot *raftpb.Snapshot) (types.ID, *membership.RaftCluster, raft.Node, *raft.MemoryStorage, *wal.WAL) {
 	var walsnap walpb.Snapshot
 	if snapshot != nil {
@@ -519,6 +589,7 @@ func restartNode(cfg ServerConfig, snapshot *raftpb.Snapshot) (types.ID, *member
 		MaxInflightMsgs: maxInflightMsgs,
 		CheckQuorum:     true,
 		PreVote:         cfg.PreVote,
+		ReadOnlyOption:  readOnlyOption(cfg),
 	}
 	if cfg.Logger != nil {
 		// called after capnslog setting in "init" function
@@ -616,6 +687,7 @@ func restartAsStandaloneNode(cfg ServerConfig, snapshot *raftpb.Snapshot) (types
 		MaxInflightMsgs: maxInflightMsgs,
 		CheckQuorum:     true,
 		PreVote:         cfg.PreVote,
+		ReadOnlyOption:  readOnlyOption(cfg),
 	}
 	if cfg.Logger != nil {
 		// called after capnslog setting in "init" function
@@ -637,7 +709,7 @@ func restartAsStandaloneNode(cfg ServerConfig, snapshot *raftpb.Snapshot) (types
 // getIDs returns an ordered set of IDs included in the given snapshot and
 // the entries. The given snapshot/entries can contain two kinds of
 // ID-related entry:
-// - ConfChangeAddNode, in which case the contained ID will be added into the set.
+// - ConfChangeAddNode or ConfChangeAddLearnerNode, in which case the contained ID will be added into the set.
 // - ConfChangeRemoveNode, in which case the contained ID will be removed from the set.
 func getIDs(lg *zap.Logger, snap *raftpb.Snapshot, ents []raftpb.Entry) []uint64 {
 	ids := make(map[uint64]bool)
@@ -645,6 +717,9 @@ func getIDs(lg *zap.Logger, snap *raftpb.Snapshot, ents []raftpb.Entry) []uint64
 		for _, id := range snap.Metadata.ConfState.Nodes {
 			ids[id] = true
 		}
+		for _, id := range snap.Metadata.ConfState.Learners {
+			ids[id] = true
+		}
 	}
 	for _, e := range ents {
 		if e.Type != raftpb.EntryConfChange {
@@ -653,7 +728,7 @@ func getIDs(lg *zap.Logger, snap *raftpb.Snapshot, ents []raftpb.Entry) []uint64
 		var cc raftpb.ConfChange
 		pbutil.MustUnmarshal(&cc, e.Data)
 		switch cc.Type {
-		case raftpb.ConfChangeAddNode:
+		case raftpb.ConfChangeAddNode, raftpb.ConfChangeAddLearnerNode:
 			ids[cc.NodeID] = true
 		case raftpb.ConfChangeRemoveNode:
 			delete(ids, cc.NodeID)
@@ -663,7 +738,7 @@ func getIDs(lg *zap.Logger, snap *raftpb.Snapshot, ents []raftpb.Entry) []uint64
 			if lg != nil {
 				lg.Panic("unknown ConfChange Type", zap.String("type", cc.Type.String()))
 			} else {
-				plog.Panicf("ConfChange Type should be either ConfChangeAddNode or ConfChangeRemoveNode!")
+				plog.Panicf("ConfChange Type should be either ConfChangeAddNode, ConfChangeAddLearnerNode or ConfChangeRemoveNode!")
 			}
 		}
 	}
@@ -0,0 +1,97 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdserver
+
+import "time"
+
+// raftClock is the clock driving the ticks of the raft node. Tests replace
+// it to tick the node deterministically.
+type raftClock interface {
+	// NewTicker returns a ticker delivering a tick every d.
+	NewTicker(d time.Duration) raftTicker
+	// Now returns the current time. Only the differences between its
+	// results are used, so it must be monotonic.
+	Now() time.Time
+}
+
+type raftTicker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// monotonicClock is the raftClock of the process. The times returned by
+// time.Now carry a monotonic reading, which their differences are computed
+// from, so the ticks are not affected by changes to the wall clock.
+type monotonicClock struct{}
+
+func (monotonicClock) NewTicker(d time.Duration) raftTicker {
+	return &timeTicker{time.NewTicker(d)}
+}
+
+func (monotonicClock) Now() time.Time { return time.Now() }
+
+type timeTicker struct{ *time.Ticker }
+
+func (t *timeTicker) C() <-chan time.Time { return t.Ticker.C }
+
+// nopTicker never ticks; it is the ticker of raft nodes without a heartbeat
+// interval, which are ticked by hand.
+type nopTicker struct{}
+
+func (nopTicker) C() <-chan time.Time { return nil }
+func (nopTicker) Stop()               {}
+
+// missedTicksWarnInterval is the minimum interval between two warnings
+// about missed ticks, a starved process missing ticks over and over.
+const missedTicksWarnInterval = 10 * time.Second
+
+// tickTimer tracks the time between the ticks of the raft node, to detect
+// the ticks missed while the process was starved of CPU.
+type tickTimer struct {
+	interval time.Duration
+	last     time.Time
+
+	// unwarned is the number of missed ticks not warned about yet, and
+	// warned the time of the last warning.
+	unwarned int
+	warned   time.Time
+}
+
+// observe records a tick at now, and returns the number of ticks missed
+// since the previous one.
+func (tt *tickTimer) observe(now time.Time) (missed int) {
+	if !tt.last.IsZero() && tt.interval > 0 {
+		// a tick up to an interval late is a scheduling jitter
+		missed = int(now.Sub(tt.last)/tt.interval) - 1
+		if missed < 0 {
+			missed = 0
+		}
+	}
+	tt.last = now
+	return missed
+}
+
+// warn adds missed to the ticks missed since the last warning, and returns
+// them if a warning is due at now, or 0 if the last warning was less than
+// missedTicksWarnInterval ago.
+func (tt *tickTimer) warn(now time.Time, missed int) int {
+	tt.unwarned += missed
+	if tt.unwarned == 0 || (!tt.warned.IsZero() && now.Sub(tt.warned) < missedTicksWarnInterval) {
+		return 0
+	}
+	n := tt.unwarned
+	tt.unwarned, tt.warned = 0, now
+	return n
+}
@@ -0,0 +1,106 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdserver
+
+import (
+	"sync/atomic"
+	"time"
+
+	"go.etcd.io/etcd/raft"
+)
+
+// maxTrackedEntries bounds the number of appended but not yet committed
+// entries whose append time is tracked, e.g. while the leader has no
+// quorum; the oldest ones are dropped past it.
+const maxTrackedEntries = 8192
+
+type appendedEntry struct {
+	index uint64
+	time  time.Time
+}
+
+// readyTracker instruments the Ready loop of the raft node: the entries
+// of each Ready to persist, and the time from the append of an entry to
+// the local log to its commit. It is only used by the Ready loop, except
+// for unstable, which is read atomically.
+type readyTracker struct {
+	// appended are the entries appended to the local log and not yet
+	// committed, in index order.
+	appended []appendedEntry
+	// unstable is the number of entries of the last Ready to persist.
+	unstable int32
+	// observed is true if a Ready was observed since the last tick.
+	observed bool
+}
+
+// observe records the Ready rd received at now. On the leader, the entries
+// are appended in the Ready following their proposal, so that the time
+// from their append to their commit is the time from their proposal.
+func (t *readyTracker) observe(rd raft.Ready, islead bool, now time.Time) {
+	size := 0
+	for i := range rd.Entries {
+		size += rd.Entries[i].Size()
+	}
+	atomic.StoreInt32(&t.unstable, int32(len(rd.Entries)))
+	t.observed = true
+	raftReadyUnstableEntries.Set(float64(len(rd.Entries)))
+	raftReadyUnstableBytes.Set(float64(size))
+
+	if len(rd.Entries) > 0 {
+		// the appended entries replace the tracked ones from their index,
+		// which a new leader may have truncated
+		first := rd.Entries[0].Index
+		i := len(t.appended)
+		for i > 0 && t.appended[i-1].index >= first {
+			i--
+		}
+		t.appended = t.appended[:i]
+		for _, e := range rd.Entries {
+			t.appended = append(t.appended, appendedEntry{index: e.Index, time: now})
+		}
+		if n := len(t.appended) - maxTrackedEntries; n > 0 {
+			t.appended = append(t.appended[:0], t.appended[n:]...)
+		}
+	}
+
+	if l := len(rd.CommittedEntries); l > 0 {
+		committed := rd.CommittedEntries[l-1].Index
+		i := 0
+		for ; i < len(t.appended) && t.appended[i].index <= committed; i++ {
+			if islead {
+				raftProposeToCommitSec.Observe(now.Sub(t.appended[i].time).Seconds())
+			}
+		}
+		t.appended = append(t.appended[:0], t.appended[i:]...)
+	}
+	raftUncommittedEntries.Set(float64(len(t.appended)))
+}
+
+// tick clears the entries to persist once a tick passed without a Ready,
+// as none are left to persist then.
+func (t *readyTracker) tick() {
+	if !t.observed {
+		atomic.StoreInt32(&t.unstable, 0)
+		raftReadyUnstableEntries.Set(0)
+		raftReadyUnstableBytes.Set(0)
+	}
+	t.observed = false
+}
+
+// unstableEntries returns the number of entries of the last Ready to
+// persist. It is safe to call concurrently with observe.
+func (t *readyTracker) unstableEntries() int {
+	return int(atomic.LoadInt32(&t.unstable))
+}
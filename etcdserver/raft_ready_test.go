@@ -0,0 +1,73 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdserver
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"go.etcd.io/etcd/raft"
+	"go.etcd.io/etcd/raft/raftpb"
+)
+
+func readyEntries(indexes ...uint64) []raftpb.Entry {
+	ents := make([]raftpb.Entry, len(indexes))
+	for i, index := range indexes {
+		ents[i] = raftpb.Entry{Index: index}
+	}
+	return ents
+}
+
+func TestReadyTracker(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		rd raft.Ready
+
+		windexes  []uint64
+		wunstable int
+	}{
+		{raft.Ready{Entries: readyEntries(1, 2, 3)}, []uint64{1, 2, 3}, 3},
+		{raft.Ready{CommittedEntries: readyEntries(1, 2)}, []uint64{3}, 0},
+		{raft.Ready{Entries: readyEntries(4, 5)}, []uint64{3, 4, 5}, 2},
+		// a new leader truncates the uncommitted entries from index 4
+		{raft.Ready{Entries: readyEntries(4)}, []uint64{3, 4}, 1},
+		{raft.Ready{Entries: readyEntries(5), CommittedEntries: readyEntries(3, 4, 5)}, []uint64{}, 1},
+	}
+	var rt readyTracker
+	for i, tt := range tests {
+		rt.observe(tt.rd, true, now)
+		indexes := []uint64{}
+		for _, e := range rt.appended {
+			indexes = append(indexes, e.index)
+		}
+		if !reflect.DeepEqual(indexes, tt.windexes) {
+			t.Errorf("#%d: tracked indexes = %v, want %v", i, indexes, tt.windexes)
+		}
+		if g := rt.unstableEntries(); g != tt.wunstable {
+			t.Errorf("#%d: unstable entries = %d, want %d", i, g, tt.wunstable)
+		}
+	}
+
+	// no entries are left to persist once a tick passed without a Ready
+	rt.tick()
+	if g := rt.unstableEntries(); g != 1 {
+		t.Errorf("unstable entries = %d after a tick following a Ready, want 1", g)
+	}
+	rt.tick()
+	if g := rt.unstableEntries(); g != 0 {
+		t.Errorf("unstable entries = %d after an idle tick, want 0", g)
+	}
+}
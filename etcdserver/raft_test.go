@@ -39,6 +39,8 @@ func TestGetIDs(t *testing.T) {
 	normalEntry := raftpb.Entry{Type: raftpb.EntryNormal}
 	updatecc := &raftpb.ConfChange{Type: raftpb.ConfChangeUpdateNode, NodeID: 2}
 	updateEntry := raftpb.Entry{Type: raftpb.EntryConfChange, Data: pbutil.MustMarshal(updatecc)}
+	addLearnercc := &raftpb.ConfChange{Type: raftpb.ConfChangeAddLearnerNode, NodeID: 3}
+	addLearnerEntry := raftpb.Entry{Type: raftpb.EntryConfChange, Data: pbutil.MustMarshal(addLearnercc)}
 
 	tests := []struct {
 		confState *raftpb.ConfState
@@ -59,6 +61,8 @@ func TestGetIDs(t *testing.T) {
 			[]raftpb.Entry{addEntry, normalEntry, updateEntry}, []uint64{1, 2}},
 		{&raftpb.ConfState{Nodes: []uint64{1}},
 			[]raftpb.Entry{addEntry, removeEntry, normalEntry}, []uint64{1}},
+		{&raftpb.ConfState{Nodes: []uint64{1}, Learners: []uint64{4}},
+			[]raftpb.Entry{addLearnerEntry}, []uint64{1, 3, 4}},
 	}
 
 	for i, tt := range tests {
@@ -268,3 +272,124 @@ func TestProcessDuplicatedAppRespMessage(t *testing.T) {
 		t.Errorf("count = %d, want %d", got, want)
 	}
 }
+
+type fakeRaftClock struct {
+	mu  sync.Mutex
+	now time.Time
+	c   chan time.Time
+}
+
+func (c *fakeRaftClock) NewTicker(time.Duration) raftTicker { return c }
+func (c *fakeRaftClock) C() <-chan time.Time                { return c.c }
+func (c *fakeRaftClock) Stop()                              {}
+func (c *fakeRaftClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// tick advances the clock by d, and delivers a tick.
+func (c *fakeRaftClock) tick(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+	c.c <- c.now
+}
+
+// TestRaftMissedTicks ensures the raft node is ticked once per tick of its
+// clock, even when ticks were missed.
+func TestRaftMissedTicks(t *testing.T) {
+	n := newReadyNode()
+	clock := &fakeRaftClock{now: time.Unix(0, 0), c: make(chan time.Time)}
+	r := newRaftNode(raftNodeConfig{
+		lg:          zap.NewExample(),
+		Node:        n,
+		heartbeat:   100 * time.Millisecond,
+		clock:       clock,
+		storage:     mockstorage.NewStorageRecorder(""),
+		raftStorage: raft.NewMemoryStorage(),
+		transport:   newNopTransporter(),
+	})
+	r.start(&raftReadyHandler{})
+	defer func() {
+		go r.stop()
+		// the stop of the node is recorded as well
+		n.Wait(1)
+	}()
+
+	// the process was starved for a second before the third tick
+	for i, d := range []time.Duration{100 * time.Millisecond, 150 * time.Millisecond, time.Second, 100 * time.Millisecond} {
+		clock.tick(d)
+		action, err := n.Wait(1)
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		// the missed ticks are not replayed
+		if len(action) != 1 || action[0].Name != "Tick" {
+			t.Fatalf("#%d: actions = %v, want a single Tick", i, action)
+		}
+	}
+}
+
+func TestTickTimer(t *testing.T) {
+	tt := tickTimer{interval: 100 * time.Millisecond}
+	start := time.Unix(0, 0)
+	tests := []struct {
+		at      time.Duration
+		wmissed int
+	}{
+		{0, 0},
+		{100 * time.Millisecond, 0},
+		// late, but within an interval
+		{290 * time.Millisecond, 0},
+		{400 * time.Millisecond, 0},
+		{1 * time.Second, 5},
+		{1100 * time.Millisecond, 0},
+	}
+	for i, test := range tests {
+		if missed := tt.observe(start.Add(test.at)); missed != test.wmissed {
+			t.Errorf("#%d: missed = %d, want %d", i, missed, test.wmissed)
+		}
+	}
+}
+
+func TestTickTimerWarn(t *testing.T) {
+	var tt tickTimer
+	start := time.Unix(0, 0)
+	tests := []struct {
+		at      time.Duration
+		missed  int
+		wwarned int
+	}{
+		{0, 0, 0},
+		{time.Second, 3, 3},
+		// within the warn interval of the last warning
+		{2 * time.Second, 2, 0},
+		{3 * time.Second, 1, 0},
+		{missedTicksWarnInterval, 0, 0},
+		// the ticks missed meanwhile are warned about once it elapsed
+		{missedTicksWarnInterval + time.Second, 0, 3},
+		{2 * missedTicksWarnInterval, 0, 0},
+		{3 * missedTicksWarnInterval, 4, 4},
+	}
+	for i, test := range tests {
+		if warned := tt.warn(start.Add(test.at), test.missed); warned != test.wwarned {
+			t.Errorf("#%d: warned = %d, want %d", i, warned, test.wwarned)
+		}
+	}
+}
+
+func TestReadOnlyOption(t *testing.T) {
+	tests := []struct {
+		leaseRead bool
+		wopt      raft.ReadOnlyOption
+	}{
+		{false, raft.ReadOnlySafe},
+		{true, raft.ReadOnlyLeaseBased},
+	}
+	for i, tt := range tests {
+		if opt := readOnlyOption(ServerConfig{LeaseRead: tt.leaseRead}); opt != tt.wopt {
+			t.Errorf("#%d: read only option = %v, want %v", i, opt, tt.wopt)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdserver
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// replayProgressInterval is the interval between two logs of the progress
+// of the replay of the committed entries on restart.
+const replayProgressInterval = 10 * time.Second
+
+// replayProgress tracks the replay, on restart, of the entries committed
+// in the WAL after the snapshot, up to the commit index of the WAL.
+type replayProgress struct {
+	first  uint64
+	target uint64
+
+	start   time.Time
+	lastLog time.Time
+}
+
+// newReplayProgress returns the progress of the replay from the applied
+// index to the commit index, or nil if there is nothing to replay.
+func newReplayProgress(lg *zap.Logger, applied, commit uint64) *replayProgress {
+	if commit <= applied {
+		return nil
+	}
+	now := time.Now()
+	rp := &replayProgress{first: applied, target: commit, start: now, lastLog: now}
+	if lg != nil {
+		lg.Info(
+			"replaying committed entries of WAL",
+			zap.Uint64("applied-index", applied),
+			zap.Uint64("commit-index", commit),
+			zap.Uint64("entries", commit-applied),
+		)
+	} else {
+		plog.Infof("replaying %d committed entries of WAL from index %d to %d", commit-applied, applied, commit)
+	}
+	return rp
+}
+
+// report logs the progress of the replay every replayProgressInterval, given
+// the applied index, and its end. It returns true once the replay is done.
+func (rp *replayProgress) report(lg *zap.Logger, applied uint64) bool {
+	now := time.Now()
+	took := now.Sub(rp.start)
+	done, total := applied-rp.first, rp.target-rp.first
+	if applied >= rp.target {
+		if lg != nil {
+			lg.Info(
+				"replayed committed entries of WAL",
+				zap.Uint64("applied-index", applied),
+				zap.Uint64("entries", total),
+				zap.Duration("took", took),
+			)
+		} else {
+			plog.Infof("replayed %d committed entries of WAL up to index %d in %v", total, applied, took)
+		}
+		return true
+	}
+	if now.Sub(rp.lastLog) < replayProgressInterval {
+		return false
+	}
+	rp.lastLog = now
+
+	rate := float64(done) / took.Seconds()
+	var eta time.Duration
+	if done > 0 {
+		eta = time.Duration(float64(took) * float64(total-done) / float64(done))
+	}
+	if lg != nil {
+		lg.Info(
+			"replaying committed entries of WAL in progress",
+			zap.Uint64("applied-index", applied),
+			zap.Uint64("commit-index", rp.target),
+			zap.Uint64("replayed-entries", done),
+			zap.Uint64("total-entries", total),
+			zap.Float64("entries-per-second", rate),
+			zap.Duration("took", took),
+			zap.Duration("eta", eta),
+		)
+	} else {
+		plog.Infof("replaying committed entries of WAL: %d/%d at index %d (%.0f entries/s, about %v left)", done, total, applied, rate, eta.Round(time.Second))
+	}
+	return false
+}
@@ -0,0 +1,46 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdserver
+
+import (
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestReplayProgress(t *testing.T) {
+	lg := zap.NewExample()
+	if rp := newReplayProgress(lg, 10, 10); rp != nil {
+		t.Fatalf("replay progress = %+v, want nil without entries to replay", rp)
+	}
+
+	rp := newReplayProgress(lg, 10, 20)
+	if rp.report(lg, 15) {
+		t.Fatal("replay done at index 15, want 20")
+	}
+	// a progress older than the interval is logged
+	rp.start = rp.start.Add(-2 * replayProgressInterval)
+	rp.lastLog = rp.start
+	if rp.report(lg, 15) {
+		t.Fatal("replay done at index 15, want 20")
+	}
+	if time.Since(rp.lastLog) > replayProgressInterval {
+		t.Error("progress not logged")
+	}
+	if !rp.report(lg, 20) {
+		t.Fatal("replay not done at index 20")
+	}
+}
@@ -0,0 +1,279 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync/atomic"
+
+	"go.etcd.io/etcd/pkg/fileutil"
+	pioutil "go.etcd.io/etcd/pkg/ioutil"
+
+	"github.com/coreos/pkg/capnslog"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// runtimeConfigFileName is the name of the file, under the member
+// directory, that persists runtime configuration overrides.
+const runtimeConfigFileName = "runtime_config.json"
+
+// RuntimeConfig holds the subset of server settings that are safe to
+// change while the server is running. Zero values leave the current
+// setting unchanged when passed to UpdateRuntimeConfig.
+type RuntimeConfig struct {
+	// LogLevel is one of "debug", "info", "warn" or "error".
+	LogLevel      string   `json:"log-level,omitempty"`
+	SnapshotCount uint64   `json:"snapshot-count,omitempty"`
+	CORS          []string `json:"cors,omitempty"`
+	// ElectionPriority is a pointer since 0 is a valid priority.
+	ElectionPriority *uint `json:"election-priority,omitempty"`
+	// KeyWriteRateLimits replaces the write rate limits of the keys when
+	// not nil; an empty list removes them. The limits are those of this
+	// member only: they apply to the writes it receives, before they are
+	// proposed, and are not replicated to the other members.
+	KeyWriteRateLimits []KeyRateLimit `json:"key-write-rate-limits,omitempty"`
+	// KeyQuotas replaces the quotas of the number of keys under prefixes
+	// when not nil; an empty list removes them. Unlike the other settings,
+	// the quotas are those of the whole cluster: they are set through
+	// consensus, and not persisted with the configuration of the member.
+	KeyQuotas []KeyQuota `json:"key-quotas,omitempty"`
+}
+
+// RuntimeConfigurer reads and updates server settings at runtime.
+type RuntimeConfigurer interface {
+	// RuntimeConfig returns the current runtime configuration.
+	RuntimeConfig() RuntimeConfig
+	// UpdateRuntimeConfig applies the non-zero fields of the given
+	// configuration, persists the result to the member directory so
+	// that it survives restarts, and returns the resulting configuration.
+	UpdateRuntimeConfig(rc RuntimeConfig) (RuntimeConfig, error)
+}
+
+func (c *ServerConfig) runtimeConfigPath() string {
+	return filepath.Join(c.MemberDir(), runtimeConfigFileName)
+}
+
+// RuntimeConfig implements RuntimeConfigurer.
+func (s *EtcdServer) RuntimeConfig() RuntimeConfig {
+	s.rcMu.Lock()
+	defer s.rcMu.Unlock()
+	return s.runtimeConfigLocked()
+}
+
+func (s *EtcdServer) runtimeConfigLocked() RuntimeConfig {
+	priority := s.getElectionPriority()
+	rc := RuntimeConfig{
+		LogLevel:           s.logLevel(),
+		SnapshotCount:      s.getSnapshotCount(),
+		ElectionPriority:   &priority,
+		KeyWriteRateLimits: s.keyRates.getLimits(),
+		KeyQuotas:          s.keyQuotas.getQuotas(),
+	}
+	s.AccessController.corsMu.RLock()
+	for origin := range s.AccessController.CORS {
+		rc.CORS = append(rc.CORS, origin)
+	}
+	s.AccessController.corsMu.RUnlock()
+	sort.Strings(rc.CORS)
+	return rc
+}
+
+// UpdateRuntimeConfig implements RuntimeConfigurer.
+func (s *EtcdServer) UpdateRuntimeConfig(rc RuntimeConfig) (RuntimeConfig, error) {
+	s.rcMu.Lock()
+	defer s.rcMu.Unlock()
+
+	if err := rc.validate(); err != nil {
+		return RuntimeConfig{}, err
+	}
+	if rc.KeyQuotas != nil {
+		if err := s.setKeyQuotas(rc.KeyQuotas); err != nil {
+			return RuntimeConfig{}, err
+		}
+	}
+	if err := s.applyRuntimeConfig(rc); err != nil {
+		return RuntimeConfig{}, err
+	}
+	cur := s.runtimeConfigLocked()
+	persisted := cur
+	persisted.KeyQuotas = nil
+	if err := writeRuntimeConfig(s.Cfg.runtimeConfigPath(), persisted); err != nil {
+		return RuntimeConfig{}, err
+	}
+	if rc.ElectionPriority != nil {
+		s.goAttach(func() { s.publishAttributes(s.Cfg.ReqTimeout()) })
+	}
+
+	if lg := s.getLogger(); lg != nil {
+		lg.Info(
+			"updated runtime configuration",
+			zap.String("log-level", cur.LogLevel),
+			zap.Uint64("snapshot-count", cur.SnapshotCount),
+			zap.Strings("cors", cur.CORS),
+			zap.Uint("election-priority", *cur.ElectionPriority),
+			zap.Int("key-write-rate-limits", len(cur.KeyWriteRateLimits)),
+			zap.Int("key-quotas", len(cur.KeyQuotas)),
+		)
+	} else {
+		plog.Noticef("updated runtime configuration (log-level %q, snapshot-count %d, cors %q, election-priority %d, %d key write rate limits, %d key quotas)", cur.LogLevel, cur.SnapshotCount, cur.CORS, *cur.ElectionPriority, len(cur.KeyWriteRateLimits), len(cur.KeyQuotas))
+	}
+	return cur, nil
+}
+
+// validate validates every field of rc, so that a bad request is refused
+// before any field is applied.
+func (rc RuntimeConfig) validate() error {
+	if rc.LogLevel != "" {
+		if _, ok := capnslogLevels[rc.LogLevel]; !ok {
+			return ErrUnknownLogLevel
+		}
+		var zl zapcore.Level
+		if err := zl.UnmarshalText([]byte(rc.LogLevel)); err != nil {
+			return err
+		}
+	}
+	for _, l := range rc.KeyWriteRateLimits {
+		if err := l.validate(); err != nil {
+			return err
+		}
+	}
+	for _, q := range rc.KeyQuotas {
+		if err := q.validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyRuntimeConfig validates every field of rc before applying any,
+// so that a bad request leaves the configuration untouched. The key
+// quotas are not applied, since they are set through consensus, see
+// setKeyQuotas.
+func (s *EtcdServer) applyRuntimeConfig(rc RuntimeConfig) error {
+	if err := rc.validate(); err != nil {
+		return err
+	}
+
+	if rc.LogLevel != "" {
+		var zl zapcore.Level
+		zl.UnmarshalText([]byte(rc.LogLevel))
+		cl := capnslogLevels[rc.LogLevel]
+		if s.Cfg.LoggerConfig != nil {
+			s.Cfg.LoggerConfig.Level.SetLevel(zl)
+		} else {
+			capnslog.SetGlobalLogLevel(cl)
+		}
+	}
+	if rc.SnapshotCount != 0 {
+		atomic.StoreUint64(&s.snapshotCount, rc.SnapshotCount)
+	}
+	if len(rc.CORS) != 0 {
+		cors := make(map[string]struct{}, len(rc.CORS))
+		for _, origin := range rc.CORS {
+			cors[origin] = struct{}{}
+		}
+		s.AccessController.SetCORS(cors)
+	}
+	if rc.ElectionPriority != nil {
+		atomic.StoreUint64(&s.electionPriority, uint64(*rc.ElectionPriority))
+	}
+	if rc.KeyWriteRateLimits != nil {
+		s.keyRates.setLimits(rc.KeyWriteRateLimits)
+	}
+	return nil
+}
+
+var capnslogLevels = map[string]capnslog.LogLevel{
+	"debug": capnslog.DEBUG,
+	"info":  capnslog.INFO,
+	"warn":  capnslog.WARNING,
+	"error": capnslog.ERROR,
+}
+
+func (s *EtcdServer) logLevel() string {
+	if s.Cfg.LoggerConfig != nil {
+		return s.Cfg.LoggerConfig.Level.String()
+	}
+	// report the most verbose level that is enabled
+	for _, name := range []string{"debug", "info", "warn", "error"} {
+		if plog.LevelAt(capnslogLevels[name]) {
+			return name
+		}
+	}
+	return ""
+}
+
+// getSnapshotCount returns the number of applied entries that
+// triggers a snapshot, taking runtime overrides into account.
+func (s *EtcdServer) getSnapshotCount() uint64 {
+	if n := atomic.LoadUint64(&s.snapshotCount); n != 0 {
+		return n
+	}
+	return s.Cfg.SnapshotCount
+}
+
+// recoverRuntimeConfig re-applies runtime configuration overrides
+// persisted by a previous UpdateRuntimeConfig call, if any.
+func (s *EtcdServer) recoverRuntimeConfig() error {
+	rc, err := readRuntimeConfig(s.Cfg.runtimeConfigPath())
+	if err != nil || rc == nil {
+		return err
+	}
+	if lg := s.getLogger(); lg != nil {
+		lg.Info(
+			"recovering runtime configuration; overriding command line flags",
+			zap.String("path", s.Cfg.runtimeConfigPath()),
+			zap.String("log-level", rc.LogLevel),
+			zap.Uint64("snapshot-count", rc.SnapshotCount),
+			zap.Strings("cors", rc.CORS),
+		)
+	} else {
+		plog.Infof("recovering runtime configuration from %q; overriding command line flags", s.Cfg.runtimeConfigPath())
+	}
+	return s.applyRuntimeConfig(*rc)
+}
+
+func readRuntimeConfig(p string) (*RuntimeConfig, error) {
+	if !fileutil.Exist(p) {
+		return nil, nil
+	}
+	b, err := ioutil.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	rc := &RuntimeConfig{}
+	if err = json.Unmarshal(b, rc); err != nil {
+		return nil, fmt.Errorf("cannot parse runtime configuration %q (%v)", p, err)
+	}
+	return rc, nil
+}
+
+func writeRuntimeConfig(p string, rc RuntimeConfig) error {
+	b, err := json.Marshal(rc)
+	if err != nil {
+		return err
+	}
+	tmp := p + ".tmp"
+	if err = pioutil.WriteAndSyncFile(tmp, b, fileutil.PrivateFileMode); err != nil {
+		return err
+	}
+	return os.Rename(tmp, p)
+}
@@ -0,0 +1,89 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdserver
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func newRuntimeConfigTestServer(t *testing.T, dir string) *EtcdServer {
+	lcfg := zap.NewDevelopmentConfig()
+	cfg := ServerConfig{
+		Logger:        zap.NewExample(),
+		LoggerConfig:  &lcfg,
+		DataDir:       dir,
+		SnapshotCount: 100,
+	}
+	if err := os.MkdirAll(cfg.MemberDir(), 0700); err != nil {
+		t.Fatal(err)
+	}
+	return &EtcdServer{
+		Cfg:              cfg,
+		lgMu:             new(sync.RWMutex),
+		lg:               cfg.Logger,
+		AccessController: &AccessController{CORS: map[string]struct{}{"*": {}}},
+	}
+}
+
+func TestUpdateRuntimeConfig(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "runtimeconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := newRuntimeConfigTestServer(t, dir)
+	if _, err = s.UpdateRuntimeConfig(RuntimeConfig{LogLevel: "verbose"}); err != ErrUnknownLogLevel {
+		t.Fatalf("err = %v, want %v", err, ErrUnknownLogLevel)
+	}
+	if _, err = s.UpdateRuntimeConfig(RuntimeConfig{KeyWriteRateLimits: []KeyRateLimit{{Pattern: "/a/[", Rate: 1}}}); err != ErrInvalidKeyRateLimit {
+		t.Fatalf("err = %v, want %v", err, ErrInvalidKeyRateLimit)
+	}
+	if _, err = s.UpdateRuntimeConfig(RuntimeConfig{KeyQuotas: []KeyQuota{{Prefix: "/a"}}}); err != ErrInvalidKeyQuota {
+		t.Fatalf("err = %v, want %v", err, ErrInvalidKeyQuota)
+	}
+
+	var priority uint
+	limits := []KeyRateLimit{{Pattern: "/config/*", Rate: 10, Burst: 20}}
+	wrc := RuntimeConfig{LogLevel: "warn", SnapshotCount: 500, CORS: []string{"http://a.com", "http://b.com"}, ElectionPriority: &priority, KeyWriteRateLimits: limits}
+	rc, err := s.UpdateRuntimeConfig(RuntimeConfig{SnapshotCount: 500, LogLevel: "warn", CORS: []string{"http://b.com", "http://a.com"}, KeyWriteRateLimits: limits})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(rc, wrc) {
+		t.Errorf("runtime config = %+v, want %+v", rc, wrc)
+	}
+	if n := s.getSnapshotCount(); n != 500 {
+		t.Errorf("snapshot count = %d, want 500", n)
+	}
+	if !s.OriginAllowed("http://a.com") || s.OriginAllowed("http://c.com") {
+		t.Errorf("CORS = %v, want %v", s.CORS, wrc.CORS)
+	}
+
+	// a restarted server recovers the persisted overrides
+	s = newRuntimeConfigTestServer(t, dir)
+	if err = s.recoverRuntimeConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if rc = s.RuntimeConfig(); !reflect.DeepEqual(rc, wrc) {
+		t.Errorf("recovered runtime config = %+v, want %+v", rc, wrc)
+	}
+}
@@ -62,6 +62,7 @@ import (
 	humanize "github.com/dustin/go-humanize"
 	"github.com/prometheus/client_golang/prometheus"
 	"go.uber.org/zap"
+	"google.golang.org/grpc"
 )
 
 const (
@@ -96,6 +97,10 @@ const (
 	// maxPendingRevokes is the maximum number of outstanding expired lease revocations.
 	maxPendingRevokes = 16
 
+	// maxExpiredKeysPerSync is the maximum number of v2 keys expired by a
+	// SYNC request; the others are expired by the next ones.
+	maxExpiredKeysPerSync = 1000
+
 	recommendedMaxRequestBytes = 10 * 1024 * 1024
 )
 
@@ -103,6 +108,10 @@ var (
 	plog = capnslog.NewPackageLogger("go.etcd.io/etcd", "etcdserver")
 
 	storeMemberAttributeRegexp = regexp.MustCompile(path.Join(membership.StoreMembersPrefix, "[[:xdigit:]]{1,16}", "attributes"))
+	storeMemberMetadataRegexp  = regexp.MustCompile(path.Join(membership.StoreMembersPrefix, "[[:xdigit:]]{1,16}", "metadata"))
+	// storeRequestIDsPrefix holds the results of the v2 writes carrying a
+	// client request ID, see applyV2Write.
+	storeRequestIDsPrefix = path.Join(StoreClusterPrefix, "requests")
 )
 
 func init() {
@@ -144,6 +153,20 @@ type ServerV3 interface {
 
 func (s *EtcdServer) ClientCertAuthEnabled() bool { return s.Cfg.ClientCertAuthEnabled }
 
+// V2CacheHeaders returns the Cache-Control header value and whether to
+// set ETags on v2 key GET responses.
+func (s *EtcdServer) V2CacheHeaders() (cacheControl string, etag bool) {
+	return s.Cfg.V2CacheControl, s.Cfg.V2ETag
+}
+
+// V2ImplicitDirs returns whether the v2 PUT and POST requests create the
+// missing parent directories of their key by default.
+func (s *EtcdServer) V2ImplicitDirs() bool { return !s.Cfg.V2ExplicitDirs }
+
+// V2MaxWatchersPerClient returns the maximum number of v2 watchers a client
+// may hold, or 0 if unlimited.
+func (s *EtcdServer) V2MaxWatchersPerClient() int { return int(s.Cfg.MaxWatchersPerClient) }
+
 type Server interface {
 	// AddMember attempts to add a member into the cluster. It will return
 	// ErrIDRemoved if member ID is removed from the cluster, or return
@@ -153,6 +176,11 @@ type Server interface {
 	// return ErrIDRemoved if member ID is removed from the cluster, or return
 	// ErrIDNotFound if member ID is not in the cluster.
 	RemoveMember(ctx context.Context, id uint64) ([]*membership.Member, error)
+	// RemoveDeadMember attempts to remove a member not seen for deadFor
+	// from the cluster, regardless of the strict reconfiguration check. It
+	// returns ErrMemberNotDead if the member was seen since, or
+	// ErrUnhealthy if the remaining members would not have an active quorum.
+	RemoveDeadMember(ctx context.Context, id uint64, deadFor time.Duration) ([]*membership.Member, error)
 	// UpdateMember attempts to update an existing member in the cluster. It will
 	// return ErrIDNotFound if the member ID does not exist.
 	UpdateMember(ctx context.Context, updateMemb membership.Member) ([]*membership.Member, error)
@@ -184,6 +212,20 @@ type EtcdServer struct {
 	committedIndex    uint64 // must use atomic operations to access; keep 64-bit aligned.
 	term              uint64 // must use atomic operations to access; keep 64-bit aligned.
 	lead              uint64 // must use atomic operations to access; keep 64-bit aligned.
+	// leaderCommittedIndex is the highest commit index of the leader
+	// received with its appends and heartbeats.
+	leaderCommittedIndex uint64 // must use atomic operations to access; keep 64-bit aligned.
+	// snapshotCount overrides Cfg.SnapshotCount when non-zero.
+	snapshotCount uint64 // must use atomic operations to access; keep 64-bit aligned.
+	// electionPriority is the election priority published for the member.
+	electionPriority uint64 // must use atomic operations to access; keep 64-bit aligned.
+	// pendingProposals is the number of local proposals waiting to be applied.
+	pendingProposals int64 // must use atomic operations to access; keep 64-bit aligned.
+	// forwardedProposals is the number of local proposals forwarded to the
+	// leader waiting to be applied.
+	forwardedProposals int64 // must use atomic operations to access; keep 64-bit aligned.
+	// snapshotCost is the duration of the last snapshot, in nanoseconds.
+	snapshotCost int64 // must use atomic operations to access; keep 64-bit aligned.
 
 	// consistIndex used to hold the offset of current executing entry
 	// It is initialized to 0 before executing any entry.
@@ -244,6 +286,9 @@ type EtcdServer struct {
 	lstats *stats.LeaderStats
 
 	SyncTicker *time.Ticker
+	// leaderClock estimates the time of the leader from the applied SYNC
+	// requests.
+	leaderClock leaderClock
 	// compactor is used to auto-compact the KV.
 	compactor v3compactor.Compactor
 
@@ -255,6 +300,15 @@ type EtcdServer struct {
 	// to detect the cluster version immediately.
 	forceVersionC chan struct{}
 
+	// dw tracks disk latencies; nil if the watchdog is disabled.
+	dw *diskWatchdog
+
+	// memoryState is the state of the estimated memory usage against
+	// Cfg.MemoryLimitBytes, and memorySnapshot is 1 while a snapshot is
+	// requested to compact the raft log; both are accessed atomically.
+	memoryState    int32
+	memorySnapshot int32
+
 	// wgMu blocks concurrent waitgroup mutation while server stopping
 	wgMu sync.RWMutex
 	// wg is used to wait for the go routines that depends on the server state
@@ -269,13 +323,42 @@ type EtcdServer struct {
 	leadTimeMu      sync.RWMutex
 	leadElectedTime time.Time
 
+	// rcMu serializes runtime configuration updates.
+	rcMu sync.Mutex
+	// keyRates limits the rate of the writes to the keys, as set at runtime.
+	keyRates keyRateLimiter
+	// keyQuotas limits the number of keys under prefixes, as set at runtime.
+	keyQuotas keyQuotas
+
+	// leaderPlacement is the leader placement of the cluster, replicated
+	// as a cluster setting.
+	leaderPlacementMu sync.RWMutex
+	leaderPlacement   LeaderPlacement
+
+	// applyAudit records the hashes of the applied entries, or is nil if
+	// the apply audit is disabled.
+	applyAudit *applyAudit
+	// flightRecorder records the last client mutations, or is nil if
+	// disabled.
+	flightRecorder *flightRecorder
+	// applyGuard stops the member on the entries whose apply panics or
+	// exceeds the apply timeout, or is nil if disabled.
+	applyGuard *applyGuard
+	// v2Replaying is 1 while a v2 watch replays the committed entries.
+	v2Replaying int32
+	// ops tracks the recursive and range deletes in flight.
+	ops operations
+
 	*AccessController
 }
 
 // NewServer creates a new EtcdServer from the supplied configuration. The
 // configuration is considered static for the lifetime of the EtcdServer.
 func NewServer(cfg ServerConfig) (srv *EtcdServer, err error) {
+	// the tombstone and expired history retentions are cluster settings,
+	// see clusterSettings
 	st := v2store.New(StoreClusterPrefix, StoreKeysPrefix)
+	v2store.SetMaxWatchersPerKey(st, int(cfg.MaxWatchersPerKey))
 
 	var (
 		w  *wal.WAL
@@ -316,7 +399,36 @@ func NewServer(cfg ServerConfig) (srv *EtcdServer, err error) {
 			plog.Fatalf("create snapshot directory error: %v", err)
 		}
 	}
-	ss := snap.New(cfg.Logger, cfg.SnapDir())
+	ss := snap.NewWithCodec(cfg.Logger, cfg.SnapDir(), cfg.SnapshotCodec)
+
+	prt, err := rafthttp.NewRoundTripper(cfg.PeerTLSInfo, cfg.peerDialTimeout())
+	if err != nil {
+		return nil, err
+	}
+	rafthttp.RestrictRoundTripper(prt, cfg.PeerAllowedNetworks)
+	prt = rafthttp.NewSharedSecretRoundTripper(prt, cfg.PeerSharedSecret)
+
+	if !haveWAL && !cfg.NewCluster && cfg.JoinSeed {
+		// the member is added once its data directory holds a verified
+		// snapshot of the cluster, then restarts from it
+		if err = seedMember(cfg, ss, prt); err != nil {
+			return nil, err
+		}
+		haveWAL = true
+	}
+
+	mi, err := readMemberIdentity(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkMemberIdentityWAL(cfg, mi, haveWAL); err != nil {
+		return nil, err
+	}
+	if !haveWAL && cfg.NewCluster {
+		if err = checkNewClusterDataDir(cfg); err != nil {
+			return nil, err
+		}
+	}
 
 	bepath := cfg.backendPath()
 	beExist := fileutil.Exist(bepath)
@@ -325,13 +437,13 @@ func NewServer(cfg ServerConfig) (srv *EtcdServer, err error) {
 	defer func() {
 		if err != nil {
 			be.Close()
+			if !beExist && !wal.Exist(cfg.WALDir()) {
+				// the backend of a failed bootstrap holds no data; left
+				// behind, it would refuse the next bootstrap attempt
+				os.Remove(bepath)
+			}
 		}
 	}()
-
-	prt, err := rafthttp.NewRoundTripper(cfg.PeerTLSInfo, cfg.peerDialTimeout())
-	if err != nil {
-		return nil, err
-	}
 	var (
 		remotes  []*membership.Member
 		snapshot *raftpb.Snapshot
@@ -346,9 +458,9 @@ func NewServer(cfg ServerConfig) (srv *EtcdServer, err error) {
 		if err != nil {
 			return nil, err
 		}
-		existingCluster, gerr := GetClusterFromRemotePeers(cfg.Logger, getRemotePeerURLs(cl, cfg.Name), prt)
+		existingCluster, gerr := fetchExistingCluster(cfg, cl, prt)
 		if gerr != nil {
-			return nil, fmt.Errorf("cannot fetch cluster info from peer urls: %v", gerr)
+			return nil, gerr
 		}
 		if err = membership.ValidateClusterAndAssignIDs(cfg.Logger, cl, existingCluster); err != nil {
 			return nil, fmt.Errorf("error validating peerURLs %s: %v", existingCluster, err)
@@ -418,6 +530,7 @@ func NewServer(cfg ServerConfig) (srv *EtcdServer, err error) {
 				plog.Warningf("discovery token ignored since a cluster has already been initialized. Valid log found at %q", cfg.WALDir())
 			}
 		}
+		loadStart := time.Now()
 		snapshot, err = ss.Load()
 		if err != nil && err != snap.ErrNoSnapshot {
 			return nil, err
@@ -436,11 +549,13 @@ func NewServer(cfg ServerConfig) (srv *EtcdServer, err error) {
 					"recovered v2 store from snapshot",
 					zap.Uint64("snapshot-index", snapshot.Metadata.Index),
 					zap.String("snapshot-size", humanize.Bytes(uint64(snapshot.Size()))),
+					zap.Duration("took", time.Since(loadStart)),
 				)
 			} else {
-				plog.Infof("recovered store from snapshot at index %d", snapshot.Metadata.Index)
+				plog.Infof("recovered store from snapshot at index %d in %v", snapshot.Metadata.Index, time.Since(loadStart))
 			}
 
+			beStart := time.Now()
 			if be, err = recoverSnapshotBackend(cfg, be, *snapshot); err != nil {
 				if cfg.Logger != nil {
 					cfg.Logger.Panic("failed to recover v3 backend from snapshot", zap.Error(err))
@@ -456,7 +571,10 @@ func NewServer(cfg ServerConfig) (srv *EtcdServer, err error) {
 					zap.String("backend-size", humanize.Bytes(uint64(s1))),
 					zap.Int64("backend-size-in-use-bytes", s2),
 					zap.String("backend-size-in-use", humanize.Bytes(uint64(s2))),
+					zap.Duration("took", time.Since(beStart)),
 				)
+			} else {
+				plog.Infof("recovered backend from snapshot in %v", time.Since(beStart))
 			}
 		}
 
@@ -481,11 +599,33 @@ func NewServer(cfg ServerConfig) (srv *EtcdServer, err error) {
 	if terr := fileutil.TouchDirAll(cfg.MemberDir()); terr != nil {
 		return nil, fmt.Errorf("cannot access member directory: %v", terr)
 	}
+	cur, err := verifyMemberIdentity(cfg, mi, id, cl)
+	if err != nil {
+		return nil, err
+	}
+	if err = writeMemberIdentity(cfg, cur); err != nil {
+		return nil, fmt.Errorf("cannot write member identity file: %v", err)
+	}
+	if err = w.SetPreallocateBytes(cfg.walPreallocateBytes()); err != nil {
+		return nil, fmt.Errorf("cannot set WAL preallocation size: %v", err)
+	}
+
+	ag := newApplyGuard(cfg.Logger, cfg.MemberDir(), cfg.ApplyTimeout, cfg.ApplyMaxAttempts)
+	if ag != nil {
+		var applied uint64
+		if snapshot != nil {
+			applied = snapshot.Metadata.Index
+		}
+		if err = ag.check(applied); err != nil {
+			return nil, err
+		}
+	}
 
 	sstats := stats.NewServerStats(cfg.Name, id.String())
 	lstats := stats.NewLeaderStats(id.String())
 
 	heartbeat := time.Duration(cfg.TickMs) * time.Millisecond
+	dw := newDiskWatchdog(cfg.DiskLatencyThreshold)
 	srv = &EtcdServer{
 		readych:     make(chan struct{}),
 		Cfg:         cfg,
@@ -502,21 +642,36 @@ func NewServer(cfg ServerConfig) (srv *EtcdServer, err error) {
 				heartbeat:   heartbeat,
 				raftStorage: s,
 				storage:     NewStorage(w, ss),
+				dw:          dw,
 			},
 		),
 		id:               id,
-		attributes:       membership.Attributes{Name: cfg.Name, ClientURLs: cfg.ClientURLs.StringSlice()},
+		attributes:       membership.Attributes{Name: cfg.Name, ClientURLs: cfg.ClientURLs.StringSlice(), Witness: cfg.Witness},
+		electionPriority: uint64(cfg.ElectionPriority),
 		cluster:          cl,
 		stats:            sstats,
 		lstats:           lstats,
+		applyAudit:       newApplyAudit(cfg.ApplyAuditEntries),
+		flightRecorder:   newFlightRecorder(cfg.FlightRecorderEntries),
+		applyGuard:       ag,
 		SyncTicker:       time.NewTicker(500 * time.Millisecond),
 		peerRt:           prt,
 		reqIDGen:         idutil.NewGenerator(uint16(id), time.Now()),
 		forceVersionC:    make(chan struct{}),
-		AccessController: &AccessController{CORS: cfg.CORS, HostWhitelist: cfg.HostWhitelist},
+		dw:               dw,
+		AccessController: &AccessController{
+			CORS:              cfg.CORS,
+			HostWhitelist:     cfg.HostWhitelist,
+			CORSExposeHeaders: cfg.CORSExposeHeaders,
+			CORSMaxAge:        cfg.CORSMaxAge,
+		},
 	}
 	serverID.With(prometheus.Labels{"server_id": id.String()}).Set(1)
 
+	if err = srv.recoverRuntimeConfig(); err != nil {
+		return nil, err
+	}
+
 	srv.applyV2 = &applierV2store{store: srv.v2store, cluster: srv.cluster}
 
 	srv.be = be
@@ -576,6 +731,8 @@ func NewServer(cfg ServerConfig) (srv *EtcdServer, err error) {
 		srv.compactor.Run()
 	}
 
+	srv.recoverClusterSettings()
+
 	srv.applyV3Base = srv.newApplierV3Backend()
 	if err = srv.restoreAlarms(); err != nil {
 		return nil, err
@@ -587,21 +744,27 @@ func NewServer(cfg ServerConfig) (srv *EtcdServer, err error) {
 
 	// TODO: move transport initialization near the definition of remote
 	tr := &rafthttp.Transport{
-		Logger:      cfg.Logger,
-		TLSInfo:     cfg.PeerTLSInfo,
-		DialTimeout: cfg.peerDialTimeout(),
-		ID:          id,
-		URLs:        cfg.PeerURLs,
-		ClusterID:   cl.ID(),
-		Raft:        srv,
-		Snapshotter: ss,
-		ServerStats: sstats,
-		LeaderStats: lstats,
-		ErrorC:      srv.errorc,
+		Logger:          cfg.Logger,
+		TLSInfo:         cfg.PeerTLSInfo,
+		SharedSecret:    cfg.PeerSharedSecret,
+		AllowedNetworks: cfg.PeerAllowedNetworks,
+		DialTimeout:     cfg.peerDialTimeout(),
+		RequestTimeout:  cfg.peerRequestTimeout(),
+		ID:              id,
+		URLs:            cfg.PeerURLs,
+		ClusterID:       cl.ID(),
+		Raft:            srv,
+		Snapshotter:     ss,
+		ServerStats:     sstats,
+		LeaderStats:     lstats,
+		ErrorC:          srv.errorc,
 	}
 	if err = tr.Start(); err != nil {
 		return nil, err
 	}
+	srv.r.transport = tr
+	// the peers are started with the transport protocol of the cluster
+	srv.applyPeerTransport(srv.clusterSetting("peer-transport"))
 	// add all remotes into transport
 	for _, m := range remotes {
 		if m.ID != id {
@@ -613,7 +776,6 @@ func NewServer(cfg ServerConfig) (srv *EtcdServer, err error) {
 			tr.AddPeer(m.ID, m.PeerURLs)
 		}
 	}
-	srv.r.transport = tr
 
 	return srv, nil
 }
@@ -714,8 +876,16 @@ func (s *EtcdServer) Start() {
 	s.goAttach(s.purgeFile)
 	s.goAttach(func() { monitorFileDescriptor(s.getLogger(), s.stopping) })
 	s.goAttach(s.monitorVersions)
+	s.goAttach(s.monitorClusterSettings)
 	s.goAttach(s.linearizableReadLoop)
 	s.goAttach(s.monitorKVHash)
+	s.goAttach(s.monitorDisk)
+	s.goAttach(s.monitorDiskUsage)
+	s.goAttach(s.monitorMemory)
+	s.goAttach(s.monitorPeerProgress)
+	s.goAttach(s.monitorPendingProposals)
+	s.goAttach(s.monitorLeaderPriority)
+	s.goAttach(func() { s.applyGuard.monitor(s.stopping) })
 }
 
 // start prepares and starts server in a new goroutine. It is no longer safe to
@@ -832,6 +1002,22 @@ type ServerPeer interface {
 	LeaseHandler() http.Handler
 }
 
+// PeerSnapshotter is implemented by peers streaming their latest snapshot
+// to the other members, for instance to bootstrap a new member.
+type PeerSnapshotter interface {
+	// LatestSnapshot returns the latest raft snapshot of the member in a
+	// MsgSnap message, merged with a snapshot of its v3 backend.
+	LatestSnapshot() (*snap.Message, error)
+}
+
+// PeerJoiner is implemented by peers adding the members that join the
+// cluster on their own, once their data directory is seeded.
+type PeerJoiner interface {
+	// JoinMember adds the given member to the cluster, on the request of
+	// the member itself, authenticated by the peer transport.
+	JoinMember(ctx context.Context, memb membership.Member) ([]*membership.Member, error)
+}
+
 func (s *EtcdServer) LeaseHandler() http.Handler {
 	if s.lessor == nil {
 		return nil
@@ -841,6 +1027,32 @@ func (s *EtcdServer) LeaseHandler() http.Handler {
 
 func (s *EtcdServer) RaftHandler() http.Handler { return s.r.transport.Handler() }
 
+// RaftGRPCServer returns the gRPC server receiving the raft messages the
+// peers stream over gRPC, or nil if the peers cannot use the gRPC
+// transport. It is served whatever the transport of the cluster, which may
+// change at runtime.
+func (s *EtcdServer) RaftGRPCServer() *grpc.Server {
+	tr, ok := s.r.transport.(*rafthttp.Transport)
+	if !ok {
+		return nil
+	}
+	return tr.GRPCServer()
+}
+
+// applyPeerTransport sets the protocol the raft messages are sent to the
+// peers with, from val, the value of the peer-transport cluster setting.
+func (s *EtcdServer) applyPeerTransport(val string) {
+	switch val {
+	case "", rafthttp.ProtocolHTTP, rafthttp.ProtocolGRPC:
+	default:
+		s.warnClusterSetting("peer-transport", val, fmt.Errorf("unknown protocol"))
+		return
+	}
+	if tr, ok := s.r.transport.(*rafthttp.Transport); ok {
+		tr.SetProtocol(val)
+	}
+}
+
 // Process takes a raft message and applies it to the server's raft state
 // machine, respecting any timeout of the given context.
 func (s *EtcdServer) Process(ctx context.Context, m raftpb.Message) error {
@@ -859,6 +1071,9 @@ func (s *EtcdServer) Process(ctx context.Context, m raftpb.Message) error {
 	if m.Type == raftpb.MsgApp {
 		s.stats.RecvAppendReq(types.ID(m.From).String(), m.Size())
 	}
+	if m.Type == raftpb.MsgApp || m.Type == raftpb.MsgHeartbeat {
+		s.setLeaderCommittedIndex(m.Commit)
+	}
 	return s.r.Step(ctx, m)
 }
 
@@ -877,6 +1092,15 @@ type etcdProgress struct {
 	snapi     uint64
 	appliedt  uint64
 	appliedi  uint64
+
+	// snapBytes and snapApplyTime are the size and the apply time of the
+	// entries applied since the last snapshot.
+	snapBytes     uint64
+	snapApplyTime time.Duration
+
+	// replay is the progress of the replay of the committed entries on
+	// restart, nil once done.
+	replay *replayProgress
 }
 
 // raftReadyHandler contains a set of EtcdServer operations to be called by raftNode,
@@ -937,6 +1161,11 @@ func (s *EtcdServer) run() {
 					s.leadTimeMu.Lock()
 					s.leadElectedTime = t
 					s.leadTimeMu.Unlock()
+					if s.IsWitness() {
+						s.goAttach(s.transferWitnessLeadership)
+					} else if !s.placementAllowsLocal() {
+						s.goAttach(s.transferPlacementLeadership)
+					}
 				}
 				setSyncC(s.SyncTicker.C)
 				if s.compactor != nil {
@@ -971,6 +1200,9 @@ func (s *EtcdServer) run() {
 		appliedt:  sn.Metadata.Term,
 		appliedi:  sn.Metadata.Index,
 	}
+	if hs, _, err := s.r.raftStorage.InitialState(); err == nil {
+		ep.replay = newReplayProgress(s.getLogger(), ep.appliedi, hs.Commit)
+	}
 
 	defer func() {
 		s.wgMu.Lock() // block concurrent waitgroup adds in goAttach while stopping
@@ -1073,6 +1305,13 @@ func (s *EtcdServer) run() {
 func (s *EtcdServer) applyAll(ep *etcdProgress, apply *apply) {
 	s.applySnapshot(ep, apply)
 	s.applyEntries(ep, apply)
+	if len(apply.entries) != 0 && !apply.committed.IsZero() {
+		raftCommitToApplySec.Observe(time.Since(apply.committed).Seconds())
+	}
+	s.reportSnapshotApply(ep)
+	if ep.replay != nil && ep.replay.report(s.getLogger(), ep.appliedi) {
+		ep.replay = nil
+	}
 
 	proposalsApplied.Set(float64(ep.appliedi))
 	s.applyWait.Trigger(ep.appliedi)
@@ -1092,6 +1331,28 @@ func (s *EtcdServer) applyAll(ep *etcdProgress, apply *apply) {
 	}
 }
 
+// reportSnapshotApply updates the progress of the apply of the entries
+// committed since the last snapshot received from the leader, and logs once
+// they are all applied.
+func (s *EtcdServer) reportSnapshotApply(ep *etcdProgress) {
+	if s.stats == nil || !s.stats.SnapshotApplyProgress(ep.appliedi, s.getCommittedIndex()) {
+		return
+	}
+	sp, _ := s.stats.SnapshotProgress()
+	if lg := s.getLogger(); lg != nil {
+		lg.Info(
+			"caught up with leader after restoring snapshot",
+			zap.String("local-member-id", s.ID().String()),
+			zap.Uint64("snapshot-index", sp.Index),
+			zap.Uint64("applied-index", ep.appliedi),
+			zap.Uint64("entries-applied", sp.EntriesApplied),
+			zap.Duration("took", time.Since(sp.StartTime)),
+		)
+	} else {
+		plog.Infof("caught up with leader after restoring snapshot at index %d (applied %d entries since, took %v)", sp.Index, sp.EntriesApplied, time.Since(sp.StartTime))
+	}
+}
+
 func (s *EtcdServer) applySnapshot(ep *etcdProgress, apply *apply) {
 	if raft.IsEmptySnap(apply.snapshot) {
 		return
@@ -1283,6 +1544,7 @@ func (s *EtcdServer) applySnapshot(ep *etcdProgress, apply *apply) {
 	}
 
 	s.cluster.Recover(api.UpdateCapability)
+	s.recoverClusterSettings()
 
 	if lg != nil {
 		lg.Info("restored cluster configuration")
@@ -1319,7 +1581,11 @@ func (s *EtcdServer) applySnapshot(ep *etcdProgress, apply *apply) {
 	ep.appliedt = apply.snapshot.Metadata.Term
 	ep.appliedi = apply.snapshot.Metadata.Index
 	ep.snapi = ep.appliedi
+	ep.snapBytes, ep.snapApplyTime = 0, 0
 	ep.confState = apply.snapshot.Metadata.ConfState
+	if s.stats != nil {
+		s.stats.SnapshotInstalled(ep.snapi)
+	}
 }
 
 func (s *EtcdServer) applyEntries(ep *etcdProgress, apply *apply) {
@@ -1346,13 +1612,18 @@ func (s *EtcdServer) applyEntries(ep *etcdProgress, apply *apply) {
 		return
 	}
 	var shouldstop bool
+	start := time.Now()
 	if ep.appliedt, ep.appliedi, shouldstop = s.apply(ents, &ep.confState); shouldstop {
 		go s.stopWithDelay(10*100*time.Millisecond, fmt.Errorf("the member has been permanently removed from the cluster"))
 	}
+	ep.snapApplyTime += time.Since(start)
+	for i := range ents {
+		ep.snapBytes += uint64(ents[i].Size())
+	}
 }
 
 func (s *EtcdServer) triggerSnapshot(ep *etcdProgress) {
-	if ep.appliedi-ep.snapi <= s.Cfg.SnapshotCount {
+	if !s.shouldSnapshot(ep) {
 		return
 	}
 
@@ -1362,7 +1633,10 @@ func (s *EtcdServer) triggerSnapshot(ep *etcdProgress) {
 			zap.String("local-member-id", s.ID().String()),
 			zap.Uint64("local-member-applied-index", ep.appliedi),
 			zap.Uint64("local-member-snapshot-index", ep.snapi),
-			zap.Uint64("local-member-snapshot-count", s.Cfg.SnapshotCount),
+			zap.Uint64("local-member-snapshot-count", s.getSnapshotCount()),
+			zap.Bool("local-member-snapshot-count-auto", s.snapshotCountAuto()),
+			zap.String("entries-size", humanize.Bytes(ep.snapBytes)),
+			zap.Duration("entries-apply-time", ep.snapApplyTime),
 		)
 	} else {
 		plog.Infof("start to snapshot (applied: %d, lastsnap: %d)", ep.appliedi, ep.snapi)
@@ -1370,6 +1644,7 @@ func (s *EtcdServer) triggerSnapshot(ep *etcdProgress) {
 
 	s.snapshot(ep.appliedi, ep.confState)
 	ep.snapi = ep.appliedi
+	ep.snapBytes, ep.snapApplyTime = 0, 0
 }
 
 func (s *EtcdServer) isMultiNode() bool {
@@ -1448,7 +1723,7 @@ func (s *EtcdServer) TransferLeadership() error {
 		return nil
 	}
 
-	transferee, ok := longestConnected(s.r.transport, s.cluster.MemberIDs())
+	transferee, ok := longestConnected(s.r.transport, s.leaderCandidateIDs())
 	if !ok {
 		return ErrUnhealthy
 	}
@@ -1506,17 +1781,46 @@ func (s *EtcdServer) stopWithDelay(d time.Duration, err error) {
 // when the server is stopped.
 func (s *EtcdServer) StopNotify() <-chan struct{} { return s.done }
 
-func (s *EtcdServer) SelfStats() []byte { return s.stats.JSON() }
+func (s *EtcdServer) SelfStats() []byte {
+	s.stats.SetApplyLag(s.ApplyLag())
+	// zero, and so omitted, where unknown
+	used, _ := runtime.FDUsage()
+	limit, _ := runtime.FDLimit()
+	s.stats.SetFDUsage(used, limit)
+	return s.stats.JSON()
+}
 
 func (s *EtcdServer) LeaderStats() []byte {
 	lead := s.getLead()
 	if lead != uint64(s.id) {
 		return nil
 	}
+	s.lstats.SetProgress(followerProgress(s.r.Status()))
 	return s.lstats.JSON()
 }
 
-func (s *EtcdServer) StoreStats() []byte { return s.v2store.JsonStats() }
+// StoreStats returns the stats of the v2 store, along with the shape of
+// the key space of the v2 API, which leaves out the internal keys.
+func (s *EtcdServer) StoreStats() []byte {
+	var st v2store.Stats
+	if err := json.Unmarshal(s.v2store.JsonStats(), &st); err != nil {
+		if lg := s.getLogger(); lg != nil {
+			lg.Panic("failed to unmarshal store stats", zap.Error(err))
+		} else {
+			plog.Panicf("unmarshal store stats should never fail: %v", err)
+		}
+	}
+	st.Keyspace = s.v2store.KeyspaceStats(StoreKeysPrefix)
+	b, err := json.Marshal(st)
+	if err != nil {
+		if lg := s.getLogger(); lg != nil {
+			lg.Panic("failed to marshal store stats", zap.Error(err))
+		} else {
+			plog.Panicf("marshal store stats should never fail: %v", err)
+		}
+	}
+	return b
+}
 
 func (s *EtcdServer) checkMembershipOperationPermission(ctx context.Context) error {
 	if s.authStore == nil {
@@ -1543,8 +1847,12 @@ func (s *EtcdServer) AddMember(ctx context.Context, memb membership.Member) ([]*
 	if err := s.checkMembershipOperationPermission(ctx); err != nil {
 		return nil, err
 	}
+	return s.addMember(ctx, memb)
+}
 
-	if s.Cfg.StrictReconfigCheck {
+func (s *EtcdServer) addMember(ctx context.Context, memb membership.Member) ([]*membership.Member, error) {
+	// learners do not count toward the quorum, so adding one never breaks it
+	if s.Cfg.StrictReconfigCheck && !memb.IsLearner {
 		// by default StrictReconfigCheck is enabled; reject new members if unhealthy
 		if !s.cluster.IsReadyToAddNewMember() {
 			if lg := s.getLogger(); lg != nil {
@@ -1560,7 +1868,7 @@ func (s *EtcdServer) AddMember(ctx context.Context, memb membership.Member) ([]*
 			return nil, ErrNotEnoughStartedMembers
 		}
 
-		if !isConnectedFullySince(s.r.transport, time.Now().Add(-HealthInterval), s.ID(), s.cluster.Members()) {
+		if !isConnectedFullySince(s.r.transport, time.Now().Add(-HealthInterval), s.ID(), s.cluster.VotingMembers()) {
 			if lg := s.getLogger(); lg != nil {
 				lg.Warn(
 					"rejecting member add request; local member has not been connected to all peers, reconfigure breaks active quorum",
@@ -1585,6 +1893,9 @@ func (s *EtcdServer) AddMember(ctx context.Context, memb membership.Member) ([]*
 		NodeID:  uint64(memb.ID),
 		Context: b,
 	}
+	if memb.IsLearner {
+		cc.Type = raftpb.ConfChangeAddLearnerNode
+	}
 	return s.configure(ctx, cc)
 }
 
@@ -1628,9 +1939,13 @@ func (s *EtcdServer) mayRemoveMember(id types.ID) error {
 	if t := s.r.transport.ActiveSince(id); id != s.ID() && t.IsZero() {
 		return nil
 	}
+	// neither is a learner
+	if m := s.cluster.Member(id); m != nil && m.IsLearner {
+		return nil
+	}
 
 	// protect quorum if some members are down
-	m := s.cluster.Members()
+	m := s.cluster.VotingMembers()
 	active := numConnectedSince(s.r.transport, time.Now().Add(-HealthInterval), s.ID(), m)
 	if (active - 1) < 1+((len(m)-1)/2) {
 		if lg := s.getLogger(); lg != nil {
@@ -1650,7 +1965,99 @@ func (s *EtcdServer) mayRemoveMember(id types.ID) error {
 	return nil
 }
 
+func (s *EtcdServer) RemoveDeadMember(ctx context.Context, id uint64, deadFor time.Duration) ([]*membership.Member, error) {
+	if err := s.checkMembershipOperationPermission(ctx); err != nil {
+		return nil, err
+	}
+
+	if err := s.mayRemoveDeadMember(types.ID(id), deadFor); err != nil {
+		return nil, err
+	}
+
+	cc := raftpb.ConfChange{
+		Type:   raftpb.ConfChangeRemoveNode,
+		NodeID: id,
+	}
+	return s.configure(ctx, cc)
+}
+
+// mayRemoveDeadMember checks that the local member has not seen the member
+// for deadFor, HealthInterval at least, and that the remaining voting
+// members keep an active quorum, that is more than half of them are
+// connected to the local member.
+func (s *EtcdServer) mayRemoveDeadMember(id types.ID, deadFor time.Duration) error {
+	if s.cluster.Member(id) == nil {
+		// the removal reports the unknown or removed member
+		return nil
+	}
+	if deadFor < HealthInterval {
+		deadFor = HealthInterval
+	}
+	seen := s.r.transport.LastSeen(id)
+	if id == s.ID() || seen.IsZero() || time.Since(seen) < deadFor {
+		if lg := s.getLogger(); lg != nil {
+			lg.Warn(
+				"rejecting dead member remove request; member was seen recently",
+				zap.String("local-member-id", s.ID().String()),
+				zap.String("requested-member-remove-id", id.String()),
+				zap.Time("last-seen", seen),
+				zap.Duration("dead-for", deadFor),
+				zap.Error(ErrMemberNotDead),
+			)
+		} else {
+			plog.Warningf("member %s was seen within %v, rejecting dead member remove", id, deadFor)
+		}
+		return ErrMemberNotDead
+	}
+
+	var remaining []*membership.Member
+	for _, m := range s.cluster.VotingMembers() {
+		if m.ID != id {
+			remaining = append(remaining, m)
+		}
+	}
+	quorum := len(remaining)/2 + 1
+	active := numConnectedSince(s.r.transport, time.Now().Add(-HealthInterval), s.ID(), remaining)
+	if active < quorum {
+		if lg := s.getLogger(); lg != nil {
+			lg.Warn(
+				"rejecting dead member remove request; remaining members have no active quorum",
+				zap.String("local-member-id", s.ID().String()),
+				zap.String("requested-member-remove-id", id.String()),
+				zap.Int("remaining-voting-members", len(remaining)),
+				zap.Int("quorum", quorum),
+				zap.Int("active-members", active),
+				zap.Error(ErrUnhealthy),
+			)
+		} else {
+			plog.Warningf("%d of the %d remaining members are active, below the quorum of %d, rejecting dead member remove %s", active, len(remaining), quorum, id)
+		}
+		return ErrUnhealthy
+	}
+
+	if lg := s.getLogger(); lg != nil {
+		lg.Warn(
+			"removing dead member",
+			zap.String("local-member-id", s.ID().String()),
+			zap.String("removed-member-id", id.String()),
+			zap.Time("last-seen", seen),
+			zap.Int("remaining-voting-members", len(remaining)),
+			zap.Int("quorum", quorum),
+			zap.Int("active-members", active),
+		)
+	} else {
+		plog.Warningf("removing dead member %s, last seen at %v (%d of the %d remaining members are active, quorum %d)", id, seen, active, len(remaining), quorum)
+	}
+	return nil
+}
+
 func (s *EtcdServer) UpdateMember(ctx context.Context, memb membership.Member) ([]*membership.Member, error) {
+	// the updates only change the peer URLs; a learner stays a learner.
+	// A name is only recorded by the updated member, which publishes it
+	// once restarted with it.
+	if m := s.cluster.Member(memb.ID); m != nil {
+		memb.IsLearner = m.IsLearner
+	}
 	b, merr := json.Marshal(memb)
 	if merr != nil {
 		return nil, merr
@@ -1667,6 +2074,44 @@ func (s *EtcdServer) UpdateMember(ctx context.Context, memb membership.Member) (
 	return s.configure(ctx, cc)
 }
 
+// MemberMetadataUpdater replaces the metadata of the members.
+type MemberMetadataUpdater interface {
+	// UpdateMemberMetadata replaces the metadata of the member of the given
+	// id with md, so that it is replicated to every member. It returns
+	// membership.ErrIDNotFound if there is no such member, and
+	// membership.ErrInvalidMetadata if md is invalid. The callers must
+	// check api.MemberMetadataCapability first.
+	UpdateMemberMetadata(ctx context.Context, id types.ID, md map[string]string) ([]*membership.Member, error)
+}
+
+// UpdateMemberMetadata proposes the metadata alone, like the member
+// attributes, so that it does not race with the updates of the peer URLs.
+func (s *EtcdServer) UpdateMemberMetadata(ctx context.Context, id types.ID, md map[string]string) ([]*membership.Member, error) {
+	if s.cluster.Member(id) == nil {
+		return nil, membership.ErrIDNotFound
+	}
+	if err := membership.ValidateMetadata(md); err != nil {
+		return nil, membership.ErrInvalidMetadata
+	}
+	b, err := json.Marshal(md)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.checkMembershipOperationPermission(ctx); err != nil {
+		return nil, err
+	}
+	req := pb.Request{
+		Method: "PUT",
+		Path:   membership.MemberMetadataStorePath(id),
+		Val:    string(b),
+	}
+	if _, err = s.Do(ctx, req); err != nil {
+		return nil, err
+	}
+	return s.cluster.Members(), nil
+}
+
 func (s *EtcdServer) setCommittedIndex(v uint64) {
 	atomic.StoreUint64(&s.committedIndex, v)
 }
@@ -1675,6 +2120,16 @@ func (s *EtcdServer) getCommittedIndex() uint64 {
 	return atomic.LoadUint64(&s.committedIndex)
 }
 
+// setLeaderCommittedIndex raises the known commit index of the leader to v.
+func (s *EtcdServer) setLeaderCommittedIndex(v uint64) {
+	for {
+		cur := atomic.LoadUint64(&s.leaderCommittedIndex)
+		if v <= cur || atomic.CompareAndSwapUint64(&s.leaderCommittedIndex, cur, v) {
+			return
+		}
+	}
+}
+
 func (s *EtcdServer) setAppliedIndex(v uint64) {
 	atomic.StoreUint64(&s.appliedIndex, v)
 }
@@ -1718,14 +2173,200 @@ func (s *EtcdServer) ID() types.ID { return s.id }
 
 func (s *EtcdServer) Leader() types.ID { return types.ID(s.getLead()) }
 
+// IsLearner returns true if the local member is a learner: a read-only
+// replica which applies the log without voting, and rejects client writes.
+func (s *EtcdServer) IsLearner() bool {
+	m := s.cluster.Member(s.ID())
+	return m != nil && m.IsLearner
+}
+
 func (s *EtcdServer) Lead() uint64 { return s.getLead() }
 
+// LeaderTime returns the estimated wall clock time of the leader, from
+// which the expiration of v2 keys is computed. It is the local time until
+// the member applies a SYNC request proposed by the leader.
+func (s *EtcdServer) LeaderTime() time.Time { return s.leaderClock.Now() }
+
 func (s *EtcdServer) CommittedIndex() uint64 { return s.getCommittedIndex() }
 
 func (s *EtcdServer) AppliedIndex() uint64 { return s.getAppliedIndex() }
 
 func (s *EtcdServer) Term() uint64 { return s.getTerm() }
 
+// ApplyLag returns the number of committed entries not yet applied.
+func (s *EtcdServer) ApplyLag() uint64 {
+	ci, ai := s.getCommittedIndex(), s.getAppliedIndex()
+	if ai >= ci {
+		return 0
+	}
+	return ci - ai
+}
+
+// LeaderLag returns the number of entries the applied index lags behind
+// the commit index of the leader, as last received from the leader, or as
+// committed locally if higher. Unlike ApplyLag, it counts the entries
+// committed by the leader that the local log does not hold yet.
+func (s *EtcdServer) LeaderLag() uint64 {
+	ci, ai := s.getCommittedIndex(), s.getAppliedIndex()
+	if lci := atomic.LoadUint64(&s.leaderCommittedIndex); lci > ci {
+		ci = lci
+	}
+	if ai >= ci {
+		return 0
+	}
+	return ci - ai
+}
+
+// ApplyLagging returns true if the member has more committed entries left
+// to apply than the configured maximum, so that local reads would return
+// stale data.
+func (s *EtcdServer) ApplyLagging() bool {
+	return s.Cfg.MaxApplyLag > 0 && s.ApplyLag() > s.Cfg.MaxApplyLag
+}
+
+// The reasons of the backpressure of an overloaded member.
+const (
+	BackpressurePendingProposals = "pending-proposals"
+	BackpressureApplyBacklog     = "apply-backlog"
+	BackpressureUnstableEntries  = "unstable-entries"
+)
+
+// Backpressure returns the reason the member is overloaded, or an empty
+// string if it is not: more pending proposals, committed entries left to
+// apply, or entries of the last raft Ready to persist than the configured
+// maximums.
+func (s *EtcdServer) Backpressure() string {
+	if n := s.Cfg.MaxPendingProposals; n > 0 && atomic.LoadInt64(&s.pendingProposals) >= int64(n) {
+		return BackpressurePendingProposals
+	}
+	if s.Cfg.MaxApplyBacklog > 0 && s.ApplyLag() > s.Cfg.MaxApplyBacklog {
+		return BackpressureApplyBacklog
+	}
+	if n := s.Cfg.MaxUnstableEntries; n > 0 && s.r.ready.unstableEntries() > n {
+		return BackpressureUnstableEntries
+	}
+	return ""
+}
+
+// Overloaded returns true if the member signals backpressure.
+func (s *EtcdServer) Overloaded() bool { return s.Backpressure() != "" }
+
+// forwardProposal queues a local proposal forwarded to the leader, if the
+// member is not the leader, and returns the function removing it from the
+// queue once applied or failed. It returns ErrTooManyForwardedProposals if
+// the queue is full, so that the requests fail fast instead of piling up
+// while the leader is unavailable.
+func (s *EtcdServer) forwardProposal() (func(), error) {
+	if s.isLeader() {
+		return func() {}, nil
+	}
+	n := atomic.AddInt64(&s.forwardedProposals, 1)
+	if max := s.Cfg.MaxForwardedProposals; max > 0 && n > int64(max) {
+		atomic.AddInt64(&s.forwardedProposals, -1)
+		proposalsForwardedShed.Inc()
+		return nil, ErrTooManyForwardedProposals
+	}
+	proposalsForwardedPending.Inc()
+	return func() {
+		atomic.AddInt64(&s.forwardedProposals, -1)
+		proposalsForwardedPending.Dec()
+	}, nil
+}
+
+// ShedClientRequest returns true if a client request must be refused
+// because the member is overloaded, so that the raft messages of the peers
+// are processed first. The messages of the peers are never refused.
+func (s *EtcdServer) ShedClientRequest() bool {
+	if !s.Overloaded() {
+		return false
+	}
+	clientRequestsShed.Inc()
+	return true
+}
+
+// RaftStatusReporter reports the detailed status of the local raft node.
+type RaftStatusReporter interface {
+	// RaftStatus returns a copy of the local raft status. Replication
+	// progress of the followers is only known on the leader.
+	RaftStatus() raft.Status
+}
+
+func (s *EtcdServer) RaftStatus() raft.Status { return s.r.Status() }
+
+// AlarmManager lists and disarms the alarms raised in the cluster.
+// Alarms are stored through raft, so every member reports the same ones.
+type AlarmManager interface {
+	// Alarms returns the active alarms of every member.
+	Alarms() []*pb.AlarmMember
+	// Alarm activates, deactivates or lists alarms through consensus.
+	Alarm(ctx context.Context, r *pb.AlarmRequest) (*pb.AlarmResponse, error)
+}
+
+// KeyspaceExporter exports the v2 keys of the member.
+type KeyspaceExporter interface {
+	// ExportKeys returns the v2 keys at or under key as of the local
+	// applied index, see v2store.Store.Export.
+	ExportKeys(key string) (*v2store.KeyExport, error)
+}
+
+func (s *EtcdServer) ExportKeys(key string) (*v2store.KeyExport, error) {
+	return s.v2store.Export(key)
+}
+
+// KeyInspector exposes the internal state of the v2 store, for debugging.
+type KeyInspector interface {
+	// InspectKey returns the internal state of the v2 store about key as
+	// of the local applied index, see v2store.Store.Inspect.
+	InspectKey(key string) (*v2store.KeyInternals, error)
+}
+
+func (s *EtcdServer) InspectKey(key string) (*v2store.KeyInternals, error) {
+	return s.v2store.Inspect(key)
+}
+
+// BatchGetter reads several v2 keys at once.
+type BatchGetter interface {
+	// BatchGet returns the events getting the v2 keys at paths, all at the
+	// same index, and the error getting each key, if any. The read is
+	// linearizable if quorum is true.
+	BatchGet(ctx context.Context, paths []string, quorum bool) ([]*v2store.Event, []error, error)
+}
+
+func (s *EtcdServer) BatchGet(ctx context.Context, paths []string, quorum bool) ([]*v2store.Event, []error, error) {
+	if quorum {
+		if err := s.linearizableReadNotify(ctx); err != nil {
+			return nil, nil, err
+		}
+	}
+	evs, errs := s.v2store.GetMulti(paths, false)
+	return evs, errs, nil
+}
+
+// TombstoneKeeper lists and restores the v2 keys deleted within the
+// tombstone retention window.
+type TombstoneKeeper interface {
+	// Tombstones returns the retained tombstones of the v2 keys at or
+	// under key.
+	Tombstones(key string) ([]v2store.Tombstone, error)
+	// Undelete restores the v2 key deleted at index through consensus, or
+	// its last deleted version if index is 0.
+	Undelete(ctx context.Context, key string, index uint64) (Response, error)
+}
+
+func (s *EtcdServer) Tombstones(key string) ([]v2store.Tombstone, error) {
+	if s.v2store.TombstoneRetention() == 0 {
+		return nil, ErrTombstonesDisabled
+	}
+	return s.v2store.ListTombstones(key), nil
+}
+
+func (s *EtcdServer) Undelete(ctx context.Context, key string, index uint64) (Response, error) {
+	if s.v2store.TombstoneRetention() == 0 {
+		return Response{}, ErrTombstonesDisabled
+	}
+	return s.Do(ctx, pb.Request{Method: "UNDELETE", Path: key, PrevIndex: index})
+}
+
 type confChangeResponse struct {
 	membs []*membership.Member
 	err   error
@@ -1776,11 +2417,23 @@ func (s *EtcdServer) configure(ctx context.Context, cc raftpb.ConfChange) ([]*me
 // sync proposes a SYNC request and is non-blocking.
 // This makes no guarantee that the request will be proposed or performed.
 // The request will be canceled after the given timeout.
+//
+// Once the whole cluster supports it, the request lists the keys expired
+// at its time, so that every member deletes the same keys at the same index.
 func (s *EtcdServer) sync(timeout time.Duration) {
+	now := s.leaderClock.proposeTime()
 	req := pb.Request{
 		Method: "SYNC",
 		ID:     s.reqIDGen.Next(),
-		Time:   time.Now().UnixNano(),
+		Time:   now.UnixNano(),
+	}
+	if api.IsCapabilityEnabled(api.V2ExpiredKeysCapability) {
+		// an empty list expires nothing, while an unlisted request
+		// expires all the keys expired at its time
+		req.ListsExpiredKeys = true
+		for _, k := range s.v2store.ExpiredKeys(now, maxExpiredKeysPerSync) {
+			req.ExpiredKeys = append(req.ExpiredKeys, pb.ExpiredKey{Key: k.Key, ModifiedIndex: k.ModifiedIndex})
+		}
 	}
 	data := pbutil.MustMarshal(&req)
 	// There is no promise that node has leader when do SYNC request,
@@ -1798,7 +2451,8 @@ func (s *EtcdServer) sync(timeout time.Duration) {
 // The function keeps attempting to register until it succeeds,
 // or its server is stopped.
 func (s *EtcdServer) publish(timeout time.Duration) {
-	b, err := json.Marshal(s.attributes)
+	attrs := s.memberAttributes()
+	b, err := json.Marshal(attrs)
 	if err != nil {
 		if lg := s.getLogger(); lg != nil {
 			lg.Panic("failed to marshal JSON", zap.Error(err))
@@ -1824,13 +2478,13 @@ func (s *EtcdServer) publish(timeout time.Duration) {
 				lg.Info(
 					"published local member to cluster through raft",
 					zap.String("local-member-id", s.ID().String()),
-					zap.String("local-member-attributes", fmt.Sprintf("%+v", s.attributes)),
+					zap.String("local-member-attributes", fmt.Sprintf("%+v", attrs)),
 					zap.String("request-path", req.Path),
 					zap.String("cluster-id", s.cluster.ID().String()),
 					zap.Duration("publish-timeout", timeout),
 				)
 			} else {
-				plog.Infof("published %+v to cluster %s", s.attributes, s.cluster.ID())
+				plog.Infof("published %+v to cluster %s", attrs, s.cluster.ID())
 			}
 			return
 
@@ -1839,7 +2493,7 @@ func (s *EtcdServer) publish(timeout time.Duration) {
 				lg.Warn(
 					"stopped publish because server is stopped",
 					zap.String("local-member-id", s.ID().String()),
-					zap.String("local-member-attributes", fmt.Sprintf("%+v", s.attributes)),
+					zap.String("local-member-attributes", fmt.Sprintf("%+v", attrs)),
 					zap.Duration("publish-timeout", timeout),
 					zap.Error(err),
 				)
@@ -1853,7 +2507,7 @@ func (s *EtcdServer) publish(timeout time.Duration) {
 				lg.Warn(
 					"failed to publish local member to cluster through raft",
 					zap.String("local-member-id", s.ID().String()),
-					zap.String("local-member-attributes", fmt.Sprintf("%+v", s.attributes)),
+					zap.String("local-member-attributes", fmt.Sprintf("%+v", attrs)),
 					zap.String("request-path", req.Path),
 					zap.Duration("publish-timeout", timeout),
 					zap.Error(err),
@@ -1920,35 +2574,45 @@ func (s *EtcdServer) apply(
 ) (appliedt uint64, appliedi uint64, shouldStop bool) {
 	for i := range es {
 		e := es[i]
-		switch e.Type {
-		case raftpb.EntryNormal:
-			s.applyEntryNormal(&e)
-			s.setAppliedIndex(e.Index)
-			s.setTerm(e.Term)
-
-		case raftpb.EntryConfChange:
-			// set the consistent index of current executing entry
-			if e.Index > s.consistIndex.ConsistentIndex() {
-				s.consistIndex.setConsistentIndex(e.Index)
-			}
-			var cc raftpb.ConfChange
-			pbutil.MustUnmarshal(&cc, e.Data)
-			removedSelf, err := s.applyConfChange(cc, confState)
-			s.setAppliedIndex(e.Index)
-			s.setTerm(e.Term)
-			shouldStop = shouldStop || removedSelf
-			s.w.Trigger(cc.ID, &confChangeResponse{s.cluster.Members(), err})
-
-		default:
-			if lg := s.getLogger(); lg != nil {
-				lg.Panic(
-					"unknown entry type; must be either EntryNormal or EntryConfChange",
-					zap.String("type", e.Type.String()),
-				)
-			} else {
-				plog.Panicf("entry type should be either EntryNormal or EntryConfChange")
+		// the guard records the entries whose apply panics or hangs
+		s.applyGuard.apply(&e, func() {
+			switch e.Type {
+			case raftpb.EntryNormal:
+				s.applyEntryNormal(&e)
+				s.setAppliedIndex(e.Index)
+				s.setTerm(e.Term)
+
+			case raftpb.EntryConfChange:
+				// set the consistent index of current executing entry
+				if e.Index > s.consistIndex.ConsistentIndex() {
+					s.consistIndex.setConsistentIndex(e.Index)
+				}
+				var cc raftpb.ConfChange
+				pbutil.MustUnmarshal(&cc, e.Data)
+				removedSelf, err := s.applyConfChange(cc, confState)
+				if s.applyAudit != nil {
+					var result []byte
+					if err != nil {
+						result = []byte(err.Error())
+					}
+					s.applyAudit.record(e.Index, "conf-change "+cc.Type.String(), e.Data, result)
+				}
+				s.setAppliedIndex(e.Index)
+				s.setTerm(e.Term)
+				shouldStop = shouldStop || removedSelf
+				s.w.Trigger(cc.ID, &confChangeResponse{s.cluster.Members(), err})
+
+			default:
+				if lg := s.getLogger(); lg != nil {
+					lg.Panic(
+						"unknown entry type; must be either EntryNormal or EntryConfChange",
+						zap.String("type", e.Type.String()),
+					)
+				} else {
+					plog.Panicf("entry type should be either EntryNormal or EntryConfChange")
+				}
 			}
-		}
+		})
 		appliedi, appliedt = e.Index, e.Term
 	}
 	return appliedt, appliedi, shouldStop
@@ -1983,12 +2647,20 @@ func (s *EtcdServer) applyEntryNormal(e *raftpb.Entry) {
 		var r pb.Request
 		rp := &r
 		pbutil.MustUnmarshal(rp, e.Data)
-		s.w.Trigger(r.ID, s.applyV2Request((*RequestV2)(rp)))
+		resp := s.applyV2Request((*RequestV2)(rp))
+		if s.applyAudit != nil {
+			s.applyAudit.record(e.Index, "v2 "+r.Method+" "+r.Path, e.Data, v2AuditResult(resp))
+		}
+		s.w.Trigger(r.ID, resp)
 		return
 	}
 	if raftReq.V2 != nil {
 		req := (*RequestV2)(raftReq.V2)
-		s.w.Trigger(req.ID, s.applyV2Request(req))
+		resp := s.applyV2Request(req)
+		if s.applyAudit != nil {
+			s.applyAudit.record(e.Index, "v2 "+req.Method+" "+req.Path, e.Data, v2AuditResult(resp))
+		}
+		s.w.Trigger(req.ID, resp)
 		return
 	}
 
@@ -2011,6 +2683,15 @@ func (s *EtcdServer) applyEntryNormal(e *raftpb.Entry) {
 		ar = s.applyV3.Apply(&raftReq)
 	}
 
+	if s.applyAudit != nil {
+		var result []byte
+		// only the member serving the read applies it
+		if !noSideEffect(&raftReq) {
+			result = v3AuditResult(ar)
+		}
+		s.applyAudit.record(e.Index, v3AuditOp(&raftReq), e.Data, result)
+	}
+
 	if ar == nil {
 		return
 	}
@@ -2054,7 +2735,7 @@ func (s *EtcdServer) applyConfChange(cc raftpb.ConfChange, confState *raftpb.Con
 	lg := s.getLogger()
 	*confState = *s.r.ApplyConfChange(cc)
 	switch cc.Type {
-	case raftpb.ConfChangeAddNode:
+	case raftpb.ConfChangeAddNode, raftpb.ConfChangeAddLearnerNode:
 		m := new(membership.Member)
 		if err := json.Unmarshal(cc.Context, m); err != nil {
 			if lg != nil {
@@ -2110,6 +2791,15 @@ func (s *EtcdServer) applyConfChange(cc raftpb.ConfChange, confState *raftpb.Con
 		s.cluster.UpdateRaftAttributes(m.ID, m.RaftAttributes)
 		if m.ID != s.id {
 			s.r.transport.UpdatePeer(m.ID, m.PeerURLs)
+			break
+		}
+		// the update overrides the identity the member restarts with
+		if err := updateMemberIdentity(s.Cfg, m.Name, m.PeerURLs); err != nil {
+			if lg != nil {
+				lg.Warn("failed to update member identity file", zap.String("path", memberIdentityPath(s.Cfg)), zap.Error(err))
+			} else {
+				plog.Warningf("failed to update member identity file %s (%v)", memberIdentityPath(s.Cfg), err)
+			}
 		}
 	}
 	return false, nil
@@ -2117,6 +2807,7 @@ func (s *EtcdServer) applyConfChange(cc raftpb.ConfChange, confState *raftpb.Con
 
 // TODO: non-blocking snapshot
 func (s *EtcdServer) snapshot(snapi uint64, confState raftpb.ConfState) {
+	start := time.Now()
 	clone := s.v2store.Clone()
 	// commit kv to write metadata (for example: consistent index) to disk.
 	// KV().commit() updates the consistent index in backend.
@@ -2161,10 +2852,13 @@ func (s *EtcdServer) snapshot(snapi uint64, confState raftpb.ConfState) {
 				plog.Fatalf("save snapshot error: %v", err)
 			}
 		}
+		took := time.Since(start)
+		atomic.StoreInt64(&s.snapshotCost, int64(took))
 		if lg != nil {
 			lg.Info(
 				"saved snapshot",
 				zap.Uint64("snapshot-index", snap.Metadata.Index),
+				zap.Duration("took", took),
 			)
 		} else {
 			plog.Infof("saved snapshot at index %d", snap.Metadata.Index)
@@ -2363,7 +3057,7 @@ func (s *EtcdServer) parseProposeCtxErr(err error, start time.Time) error {
 		case types.ID(raft.None):
 			// TODO: return error to specify it happens because the cluster does not have leader now
 		case s.ID():
-			if !isConnectedToQuorumSince(s.r.transport, start, s.ID(), s.cluster.Members()) {
+			if !isConnectedToQuorumSince(s.r.transport, start, s.ID(), s.cluster.VotingMembers()) {
 				return ErrTimeoutDueToConnectionLost
 			}
 		default:
@@ -14,7 +14,13 @@
 
 package etcdserver
 
-import "sync"
+import (
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
 
 // AccessController controls etcd server HTTP request access.
 type AccessController struct {
@@ -22,6 +28,13 @@ type AccessController struct {
 	CORS            map[string]struct{}
 	hostWhitelistMu sync.RWMutex
 	HostWhitelist   map[string]struct{}
+
+	// CORSExposeHeaders lists the response headers browsers may expose
+	// to cross-origin scripts.
+	CORSExposeHeaders []string
+	// CORSMaxAge is how long browsers may cache preflight responses.
+	// It is not advertised if 0.
+	CORSMaxAge time.Duration
 }
 
 // NewAccessController returns a new "AccessController" with default "*" values.
@@ -33,7 +46,8 @@ func NewAccessController() *AccessController {
 }
 
 // OriginAllowed determines whether the server will allow a given CORS origin.
-// If CORS is empty, allow all.
+// If CORS is empty, allow all. Origins with a wildcard host such as
+// "https://*.example.com:443" allow any subdomain of the host.
 func (ac *AccessController) OriginAllowed(origin string) bool {
 	ac.corsMu.RLock()
 	defer ac.corsMu.RUnlock()
@@ -45,7 +59,58 @@ func (ac *AccessController) OriginAllowed(origin string) bool {
 		return true
 	}
 	_, ok = ac.CORS[origin]
-	return ok
+	if ok {
+		return true
+	}
+	for pattern := range ac.CORS {
+		if strings.Contains(pattern, "://*.") && wildcardOriginMatch(pattern, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// wildcardOriginMatch returns true if origin has the scheme and port of
+// pattern, and a host under the wildcard domain of pattern. Origins
+// without a port use the default port of their scheme.
+func wildcardOriginMatch(pattern, origin string) bool {
+	pu, err := url.Parse(pattern)
+	if err != nil {
+		return false
+	}
+	ou, err := url.Parse(origin)
+	if err != nil || ou.Scheme != pu.Scheme {
+		return false
+	}
+	phost, pport := splitOriginHost(pu)
+	ohost, oport := splitOriginHost(ou)
+	if pport != oport {
+		return false
+	}
+	// "*." matches one or more labels, but never the bare domain
+	domain := strings.TrimPrefix(phost, "*")
+	return len(ohost) > len(domain) && strings.HasSuffix(ohost, domain)
+}
+
+func splitOriginHost(u *url.URL) (host, port string) {
+	host, port, err := net.SplitHostPort(u.Host)
+	if err != nil {
+		host = u.Host
+		switch u.Scheme {
+		case "http":
+			port = "80"
+		case "https":
+			port = "443"
+		}
+	}
+	return strings.ToLower(host), port
+}
+
+// SetCORS replaces the set of allowed CORS origins.
+func (ac *AccessController) SetCORS(cors map[string]struct{}) {
+	ac.corsMu.Lock()
+	ac.CORS = cors
+	ac.corsMu.Unlock()
 }
 
 // IsHostWhitelisted returns true if the host is whitelisted.
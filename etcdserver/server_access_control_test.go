@@ -0,0 +1,49 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdserver
+
+import "testing"
+
+func TestOriginAllowed(t *testing.T) {
+	ac := &AccessController{CORS: map[string]struct{}{
+		"http://a.com:8080":         {},
+		"https://*.example.com:443": {},
+		"http://*.example.org:2379": {},
+	}}
+	tests := []struct {
+		origin string
+		w      bool
+	}{
+		{"http://a.com:8080", true},
+		{"http://b.com:8080", false},
+
+		{"https://www.example.com", true},
+		{"https://a.b.example.com:443", true},
+		{"https://WWW.Example.com", true},
+		{"https://example.com", false},
+		{"https://badexample.com", false},
+		{"http://www.example.com", false},
+		{"https://www.example.com:8443", false},
+		{"https://www.example.com.evil.com", false},
+
+		{"http://www.example.org:2379", true},
+		{"http://www.example.org", false},
+	}
+	for i, tt := range tests {
+		if g := ac.OriginAllowed(tt.origin); g != tt.w {
+			t.Errorf("#%d: OriginAllowed(%q) = %v, want %v", i, tt.origin, g, tt.w)
+		}
+	}
+}
@@ -30,9 +30,12 @@ import (
 
 	"go.uber.org/zap"
 
+	"go.etcd.io/etcd/etcdserver/api"
 	"go.etcd.io/etcd/etcdserver/api/membership"
 	"go.etcd.io/etcd/etcdserver/api/rafthttp"
 	"go.etcd.io/etcd/etcdserver/api/snap"
+	"go.etcd.io/etcd/etcdserver/api/v2error"
+	stats "go.etcd.io/etcd/etcdserver/api/v2stats"
 	"go.etcd.io/etcd/etcdserver/api/v2store"
 	pb "go.etcd.io/etcd/etcdserver/etcdserverpb"
 	"go.etcd.io/etcd/lease"
@@ -65,6 +68,10 @@ func TestDoLocalAction(t *testing.T) {
 			pb.Request{Method: "GET", ID: 1, Wait: true},
 			Response{Watcher: v2store.NewNopWatcher()}, nil, []testutil.Action{{Name: "Watch"}},
 		},
+		{
+			pb.Request{Method: "GET", ID: 1, Wait: true, WaitExisting: true},
+			Response{Watcher: v2store.NewNopWatcher()}, nil, []testutil.Action{{Name: "WatchExisting"}},
+		},
 		{
 			pb.Request{Method: "GET", ID: 1},
 			Response{Event: &v2store.Event{}}, nil,
@@ -113,6 +120,47 @@ func TestDoLocalAction(t *testing.T) {
 	}
 }
 
+// TestDoRecursiveKeyLimit ensures that recursive reads and deletes touching
+// more keys than MaxRecursiveKeys are rejected before being proposed.
+func TestDoRecursiveKeyLimit(t *testing.T) {
+	st := v2store.New()
+	for _, k := range []string{"/foo/a", "/foo/b", "/foo/c", "/bar/a"} {
+		if _, err := st.Create(k, false, "v", false, v2store.TTLOptionSet{ExpireTime: v2store.Permanent}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	srv := &EtcdServer{
+		lgMu:     new(sync.RWMutex),
+		lg:       zap.NewExample(),
+		Cfg:      ServerConfig{MaxRecursiveKeys: 2},
+		v2store:  st,
+		reqIDGen: idutil.NewGenerator(0, time.Time{}),
+	}
+
+	tests := []struct {
+		req      pb.Request
+		rejected bool
+	}{
+		{pb.Request{Method: "GET", Path: "/foo", Recursive: true}, true},
+		{pb.Request{Method: "DELETE", Path: "/foo", Recursive: true}, true},
+		{pb.Request{Method: "GET", Path: "/bar", Recursive: true}, false},
+		{pb.Request{Method: "GET", Path: "/foo"}, false},
+		{pb.Request{Method: "GET", Path: "/foo", Recursive: true, Wait: true}, false},
+	}
+	for i, tt := range tests {
+		err := srv.checkRecursiveKeys(&tt.req)
+		if tt.rejected {
+			if e, ok := err.(*v2error.Error); !ok || e.ErrorCode != v2error.EcodeTooManyKeys {
+				t.Errorf("#%d: err = %v, want EcodeTooManyKeys", i, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: err = %v, want nil", i, err)
+		}
+	}
+}
+
 // TestDoBadLocalAction tests server requests which do not need to go through consensus,
 // and return errors when they fetch from local data.
 func TestDoBadLocalAction(t *testing.T) {
@@ -417,6 +465,17 @@ func TestApplyRequest(t *testing.T) {
 				},
 			},
 		},
+		// CLAIM ==> ClaimNext
+		{
+			pb.Request{Method: "CLAIM", ID: 1, Path: "/queue"},
+			Response{Event: &v2store.Event{}},
+			[]testutil.Action{
+				{
+					Name:   "ClaimNext",
+					Params: []interface{}{"/queue"},
+				},
+			},
+		},
 		// QGET ==> Get
 		{
 			pb.Request{Method: "QGET", ID: 1},
@@ -449,6 +508,27 @@ func TestApplyRequest(t *testing.T) {
 				},
 			},
 		},
+		// SYNC listing the expired keys ==> ExpireKeys
+		{
+			pb.Request{Method: "SYNC", ID: 1, Time: 12345, ListsExpiredKeys: true, ExpiredKeys: []pb.ExpiredKey{{Key: "/foo", ModifiedIndex: 3}}},
+			Response{},
+			[]testutil.Action{
+				{
+					Name:   "ExpireKeys",
+					Params: []interface{}{[]v2store.ExpiredKey{{Key: "/foo", ModifiedIndex: 3}}, time.Unix(0, 12345)},
+				},
+			},
+		},
+		{
+			pb.Request{Method: "SYNC", ID: 1, Time: 12345, ListsExpiredKeys: true},
+			Response{},
+			[]testutil.Action{
+				{
+					Name:   "ExpireKeys",
+					Params: []interface{}{[]v2store.ExpiredKey{}, time.Unix(0, 12345)},
+				},
+			},
+		},
 		// Unknown method - error
 		{
 			pb.Request{Method: "BADMETHOD", ID: 1},
@@ -500,6 +580,218 @@ func TestApplyRequestOnAdminMemberAttributes(t *testing.T) {
 	}
 }
 
+// TestApplyRequestID ensures the writes carrying a request ID are applied
+// once within the request ID window.
+func TestApplyRequestID(t *testing.T) {
+	st := v2store.New(StoreClusterPrefix, StoreKeysPrefix)
+	srv := &EtcdServer{
+		lgMu:    new(sync.RWMutex),
+		lg:      zap.NewExample(),
+		v2store: st,
+	}
+	srv.applyV2 = &applierV2store{store: srv.v2store, cluster: srv.cluster}
+
+	now := time.Now()
+	write := func(id uint64, method, p, user, reqID string, at time.Time) Response {
+		req := pb.Request{Method: method, ID: id, Path: p, Val: "v", ClientRequestID: reqID, ClientRequestUser: user}
+		if reqID != "" {
+			req.ClientRequestTime = at.UnixNano()
+		}
+		return srv.applyV2Request((*RequestV2)(&req))
+	}
+	post := func(id uint64, reqID string, at time.Time) Response {
+		return write(id, "POST", "/1/queue", "", reqID, at)
+	}
+	queueLen := func() int {
+		ev, err := st.Get("/1/queue", true, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(ev.Node.Nodes)
+	}
+
+	resp := post(1, "c0ffee", now)
+	if resp.Err != nil {
+		t.Fatal(resp.Err)
+	}
+	// a retry returns the result of the first request
+	retry := post(2, "c0ffee", now.Add(time.Second))
+	if retry.Err != nil {
+		t.Fatal(retry.Err)
+	}
+	if !reflect.DeepEqual(retry.Event, resp.Event) {
+		t.Errorf("retry event = %+v, want %+v", retry.Event, resp.Event)
+	}
+	// writes without request ID are all applied
+	post(3, "", now)
+	post(4, "", now)
+	if n := queueLen(); n != 3 {
+		t.Errorf("queue length = %d, want 3", n)
+	}
+
+	// the same request ID from another user, or for another write, is not
+	// taken for a retry
+	if other := write(5, "POST", "/1/queue", "alice", "c0ffee", now); reflect.DeepEqual(other.Event, resp.Event) {
+		t.Errorf("write of another user was deduplicated")
+	}
+	if other := write(6, "PUT", "/1/foo", "", "c0ffee", now); other.Err != nil || other.Event.Action != v2store.Set {
+		t.Errorf("write to another key = %+v, want it applied", other)
+	}
+	if n := queueLen(); n != 4 {
+		t.Errorf("queue length = %d, want 4", n)
+	}
+
+	// the result kept is confirmed by the full request ID, not its hash
+	r := &RequestV2{Method: "POST", Path: "/1/queue", ClientRequestID: "c0ffee"}
+	n, err := st.Lookup(v2RequestResultPath(r))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res v2RequestResult
+	if err = json.Unmarshal([]byte(*n.Value), &res); err != nil {
+		t.Fatal(err)
+	}
+	if !res.sameRequest(r) {
+		t.Errorf("result = %+v, want the result of %+v", res, r)
+	}
+	if r.ClientRequestID = "c0ffe"; res.sameRequest(r) {
+		t.Errorf("result of request ID c0ffee taken for the request ID c0ffe")
+	}
+
+	// the request ID is forgotten once the window elapsed
+	st.DeleteExpiredKeys(now.Add(v2RequestIDWindow + time.Second))
+	if retry = post(7, "c0ffee", now.Add(v2RequestIDWindow+time.Second)); reflect.DeepEqual(retry.Event, resp.Event) {
+		t.Errorf("retry after the window was deduplicated")
+	}
+}
+
+// TestV2Replayer ensures the replayed requests are applied as a member
+// applies them: the retries are deduplicated, the claims applied, and the
+// unknown methods reported.
+func TestV2Replayer(t *testing.T) {
+	st := v2store.New(StoreClusterPrefix, StoreKeysPrefix)
+	cl := membership.NewCluster(zap.NewExample(), "")
+	cl.SetStore(st)
+	rp := NewV2Replayer(zap.NewExample(), st, cl)
+
+	now := time.Now().UnixNano()
+	reqs := []pb.Request{
+		{Method: "POST", ID: 1, Path: "/1/queue", Val: "a"},
+		{Method: "POST", ID: 2, Path: "/1/queue", Val: "b"},
+		{Method: "PUT", ID: 3, Path: "/1/foo", Val: "v", ClientRequestID: "c0ffee", ClientRequestTime: now},
+		// a retry of the write above
+		{Method: "PUT", ID: 4, Path: "/1/foo", Val: "v", ClientRequestID: "c0ffee", ClientRequestTime: now},
+		{Method: "CLAIM", ID: 5, Path: "/1/queue", Recursive: true},
+	}
+	for i := range reqs {
+		if err := rp.Apply(&reqs[i]); err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+	}
+
+	ev, err := st.Get("/1/foo", false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev.Node.ModifiedIndex != 3 {
+		t.Errorf("modified index = %d, want 3", ev.Node.ModifiedIndex)
+	}
+	if _, err = st.Lookup(v2RequestResultPath((*RequestV2)(&reqs[2]))); err != nil {
+		t.Errorf("result of the write with a request ID not kept (%v)", err)
+	}
+	ev, err = st.Get("/1/queue", true, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ev.Node.Nodes) != 1 || *ev.Node.Nodes[0].Value != "b" {
+		t.Errorf("queue = %+v, want the key b left", ev.Node.Nodes)
+	}
+
+	if err = rp.Apply(&pb.Request{Method: "BOGUS", ID: 6, Path: "/1/foo"}); err != ErrUnknownMethod {
+		t.Errorf("err = %v, want %v", err, ErrUnknownMethod)
+	}
+}
+
+// TestStoreStatsKeyspace ensures the store stats only describe the keys of
+// the v2 API.
+func TestStoreStatsKeyspace(t *testing.T) {
+	st := v2store.New(StoreClusterPrefix, StoreKeysPrefix)
+	perm := v2store.TTLOptionSet{ExpireTime: v2store.Permanent}
+	st.Create("/0/members/1/attributes", false, "internal", false, perm)
+	st.Create("/1/foo", false, "bar", false, perm)
+	srv := &EtcdServer{lgMu: new(sync.RWMutex), lg: zap.NewExample(), v2store: st}
+
+	var stats v2store.Stats
+	if err := json.Unmarshal(srv.StoreStats(), &stats); err != nil {
+		t.Fatal(err)
+	}
+	ks := stats.Keyspace
+	if ks == nil {
+		t.Fatal("no keyspace stats")
+	}
+	w := []v2store.KeySize{{Key: "/foo", Size: 3}}
+	if ks.Keys != 1 || !reflect.DeepEqual(ks.LargestValues, w) {
+		t.Errorf("keys = %d, largest values = %+v, want 1 and %+v", ks.Keys, ks.LargestValues, w)
+	}
+	if stats.CreateSuccess != 2 {
+		t.Errorf("create success = %d, want 2", stats.CreateSuccess)
+	}
+}
+
+func TestApplyRequestParentDir(t *testing.T) {
+	tests := []struct {
+		req pb.Request
+
+		wcode int
+	}{
+		// the writes create the missing directories by default
+		{pb.Request{Method: "PUT", Path: "/1/dir/foo", Val: "bar"}, 0},
+		{pb.Request{Method: "POST", Path: "/1/queue", Val: "bar"}, 0},
+		// unless marked by NoImplicitDirs
+		{pb.Request{Method: "PUT", Path: "/1/dir/foo", Val: "bar", NoImplicitDirs: true}, v2error.EcodeKeyNotFound},
+		{pb.Request{Method: "PUT", Path: "/1/dir/foo", Val: "bar", PrevExist: pbutil.Boolp(false), NoImplicitDirs: true}, v2error.EcodeKeyNotFound},
+		{pb.Request{Method: "POST", Path: "/1/queue", Val: "bar", NoImplicitDirs: true}, v2error.EcodeKeyNotFound},
+		{pb.Request{Method: "PUT", Path: "/1/foo", Val: "bar", NoImplicitDirs: true}, 0},
+		{pb.Request{Method: "PUT", Path: "/1/exist/foo", Val: "bar", NoImplicitDirs: true}, 0},
+		{pb.Request{Method: "POST", Path: "/1/exist", Val: "bar", NoImplicitDirs: true}, 0},
+	}
+	for i, tt := range tests {
+		st := v2store.New(StoreClusterPrefix, StoreKeysPrefix)
+		st.Create("/1/exist", true, "", false, v2store.TTLOptionSet{ExpireTime: v2store.Permanent})
+		srv := &EtcdServer{
+			lgMu:    new(sync.RWMutex),
+			lg:      zap.NewExample(),
+			v2store: st,
+		}
+		srv.applyV2 = &applierV2store{store: srv.v2store, cluster: srv.cluster}
+
+		resp := srv.applyV2Request((*RequestV2)(&tt.req))
+		var code int
+		if err, ok := resp.Err.(*v2error.Error); ok {
+			code = err.ErrorCode
+		} else if resp.Err != nil {
+			t.Fatalf("#%d: unexpected error %v", i, resp.Err)
+		}
+		if code != tt.wcode {
+			t.Errorf("#%d: error code = %d, want %d", i, code, tt.wcode)
+		}
+		if code == 0 && resp.Event == nil {
+			t.Errorf("#%d: no event", i)
+		}
+		// the parent is looked up without counting a get
+		var stats struct {
+			GetSuccess uint64 `json:"getsSuccess"`
+			GetFail    uint64 `json:"getsFail"`
+		}
+		if err := json.Unmarshal(st.JsonStats(), &stats); err != nil {
+			t.Fatal(err)
+		}
+		if g := stats.GetSuccess + stats.GetFail; g != 0 {
+			t.Errorf("#%d: gets = %d, want 0", i, g)
+		}
+	}
+}
+
 func TestApplyConfChangeError(t *testing.T) {
 	cl := membership.NewCluster(zap.NewExample(), "")
 	cl.SetStore(v2store.New())
@@ -805,12 +1097,14 @@ func TestDoProposalStopped(t *testing.T) {
 
 // TestSync tests sync 1. is nonblocking 2. proposes SYNC request.
 func TestSync(t *testing.T) {
+	api.EnableCapability(api.V2ExpiredKeysCapability)
 	n := newNodeRecorder()
 	ctx, cancel := context.WithCancel(context.TODO())
 	srv := &EtcdServer{
 		lgMu:     new(sync.RWMutex),
 		lg:       zap.NewExample(),
 		r:        *newRaftNode(raftNodeConfig{lg: zap.NewExample(), Node: n}),
+		v2store:  mockstore.NewNop(),
 		reqIDGen: idutil.NewGenerator(0, time.Time{}),
 		ctx:      ctx,
 		cancel:   cancel,
@@ -845,6 +1139,9 @@ func TestSync(t *testing.T) {
 	if r.Method != "SYNC" {
 		t.Errorf("method = %s, want SYNC", r.Method)
 	}
+	if !r.ListsExpiredKeys || len(r.ExpiredKeys) != 0 {
+		t.Errorf("expired keys = %v (listed %v), want an empty list", r.ExpiredKeys, r.ListsExpiredKeys)
+	}
 }
 
 // TestSyncTimeout tests the case that sync 1. is non-blocking 2. cancel request
@@ -856,6 +1153,7 @@ func TestSyncTimeout(t *testing.T) {
 		lgMu:     new(sync.RWMutex),
 		lg:       zap.NewExample(),
 		r:        *newRaftNode(raftNodeConfig{lg: zap.NewExample(), Node: n}),
+		v2store:  mockstore.NewNop(),
 		reqIDGen: idutil.NewGenerator(0, time.Time{}),
 		ctx:      ctx,
 		cancel:   cancel,
@@ -1318,6 +1616,52 @@ func TestRemoveMember(t *testing.T) {
 	}
 }
 
+func TestMayRemoveDeadMember(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		active  []types.ID
+		seen    time.Time
+		id      types.ID
+		deadFor time.Duration
+		werr    error
+	}{
+		// 5 was not seen for 2 hours; 1, 2 and 3 are a quorum of the 4 others
+		{[]types.ID{2, 3}, now.Add(-2 * time.Hour), 5, time.Hour, nil},
+		{[]types.ID{2, 3}, now.Add(-2 * time.Hour), 5, 3 * time.Hour, ErrMemberNotDead},
+		{[]types.ID{2, 3, 5}, now.Add(-2 * time.Hour), 5, time.Hour, ErrMemberNotDead},
+		{[]types.ID{2, 3}, now.Add(-2 * time.Hour), 1, time.Hour, ErrMemberNotDead},
+		// 1 and 2 are not a quorum of the 4 others
+		{[]types.ID{2}, now.Add(-2 * time.Hour), 5, time.Hour, ErrUnhealthy},
+		// 5 was seen within the health interval at least
+		{[]types.ID{2, 3}, now.Add(-time.Second), 5, 0, ErrMemberNotDead},
+		// unknown members are left to the removal
+		{[]types.ID{2}, time.Time{}, 9, time.Hour, nil},
+	}
+	for i, tt := range tests {
+		var membs []*membership.Member
+		for id := types.ID(1); id <= 5; id++ {
+			membs = append(membs, &membership.Member{ID: id})
+		}
+		tr := &nopTransporterWithActiveTime{activeMap: make(map[types.ID]time.Time), seenMap: make(map[types.ID]time.Time)}
+		for _, id := range tt.active {
+			tr.activeMap[id] = now.Add(-time.Minute)
+		}
+		for id := types.ID(2); id <= 5; id++ {
+			tr.seenMap[id] = tt.seen
+		}
+		s := &EtcdServer{
+			lgMu:    new(sync.RWMutex),
+			lg:      zap.NewExample(),
+			id:      1,
+			r:       raftNode{raftNodeConfig: raftNodeConfig{transport: tr}},
+			cluster: newTestCluster(membs),
+		}
+		if err := s.mayRemoveDeadMember(tt.id, tt.deadFor); err != tt.werr {
+			t.Errorf("#%d: err = %v, want %v", i, err, tt.werr)
+		}
+	}
+}
+
 // TestUpdateMember tests RemoveMember can propose and perform node update.
 func TestUpdateMember(t *testing.T) {
 	n := newNodeConfChangeCommitterRecorder()
@@ -1745,6 +2089,7 @@ func (s *nopTransporter) RemovePeer(id types.ID)              {}
 func (s *nopTransporter) RemoveAllPeers()                     {}
 func (s *nopTransporter) UpdatePeer(id types.ID, us []string) {}
 func (s *nopTransporter) ActiveSince(id types.ID) time.Time   { return time.Time{} }
+func (s *nopTransporter) LastSeen(id types.ID) time.Time      { return time.Time{} }
 func (s *nopTransporter) ActivePeers() int                    { return 0 }
 func (s *nopTransporter) Stop()                               {}
 func (s *nopTransporter) Pause()                              {}
@@ -1789,3 +2134,138 @@ func (s *sendMsgAppRespTransporter) Send(m []raftpb.Message) {
 	}
 	s.sendC <- send
 }
+
+func TestOverloaded(t *testing.T) {
+	tests := []struct {
+		cfg       ServerConfig
+		pending   int64
+		committed uint64
+		unstable  int32
+
+		w       bool
+		wreason string
+	}{
+		{ServerConfig{}, 100, 1000, 1000, false, ""},
+		{ServerConfig{MaxPendingProposals: 2}, 1, 1000, 0, false, ""},
+		{ServerConfig{MaxPendingProposals: 2}, 2, 0, 0, true, BackpressurePendingProposals},
+		{ServerConfig{MaxApplyBacklog: 10}, 100, 10, 0, false, ""},
+		{ServerConfig{MaxApplyBacklog: 10}, 0, 11, 0, true, BackpressureApplyBacklog},
+		{ServerConfig{MaxUnstableEntries: 100}, 0, 0, 100, false, ""},
+		{ServerConfig{MaxUnstableEntries: 100}, 0, 0, 101, true, BackpressureUnstableEntries},
+	}
+	for i, tt := range tests {
+		s := &EtcdServer{Cfg: tt.cfg, pendingProposals: tt.pending, committedIndex: tt.committed}
+		s.r.ready.unstable = tt.unstable
+		if g := s.Backpressure(); g != tt.wreason {
+			t.Errorf("#%d: backpressure = %q, want %q", i, g, tt.wreason)
+		}
+		if g := s.Overloaded(); g != tt.w {
+			t.Errorf("#%d: overloaded = %v, want %v", i, g, tt.w)
+		}
+		if g := s.ShedClientRequest(); g != tt.w {
+			t.Errorf("#%d: shed = %v, want %v", i, g, tt.w)
+		}
+	}
+}
+
+// TestLeaderLag ensures that the lag behind the leader counts the entries
+// the leader committed but the local log of the follower does not hold.
+func TestLeaderLag(t *testing.T) {
+	s := &EtcdServer{
+		lgMu:           new(sync.RWMutex),
+		lg:             zap.NewExample(),
+		r:              *newRaftNode(raftNodeConfig{lg: zap.NewExample(), Node: newNodeNop()}),
+		cluster:        membership.NewCluster(zap.NewExample(), ""),
+		stats:          stats.NewServerStats("", ""),
+		committedIndex: 10,
+		appliedIndex:   8,
+	}
+	tests := []struct {
+		m raftpb.Message
+
+		wlag uint64
+	}{
+		// the local commit index bounds the lag until the leader reports
+		{raftpb.Message{Type: raftpb.MsgVote, Commit: 100}, 2},
+		{raftpb.Message{Type: raftpb.MsgApp, Commit: 50}, 42},
+		// heartbeats carry at most the match index of the follower
+		{raftpb.Message{Type: raftpb.MsgHeartbeat, Commit: 20}, 42},
+		{raftpb.Message{Type: raftpb.MsgHeartbeat, Commit: 60}, 52},
+	}
+	for i, tt := range tests {
+		tt.m.From = 2
+		if err := s.Process(context.TODO(), tt.m); err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if g := s.LeaderLag(); g != tt.wlag {
+			t.Errorf("#%d: leader lag = %d, want %d", i, g, tt.wlag)
+		}
+		if g := s.ApplyLag(); g != 2 {
+			t.Errorf("#%d: apply lag = %d, want 2", i, g)
+		}
+	}
+}
+
+func TestForwardProposal(t *testing.T) {
+	s := &EtcdServer{id: 1, lead: 2, Cfg: ServerConfig{MaxForwardedProposals: 2}}
+	var dones []func()
+	for i := 0; i < 2; i++ {
+		done, err := s.forwardProposal()
+		if err != nil {
+			t.Fatalf("#%d: err = %v, want nil", i, err)
+		}
+		dones = append(dones, done)
+	}
+	if _, err := s.forwardProposal(); err != ErrTooManyForwardedProposals {
+		t.Fatalf("err = %v, want %v", err, ErrTooManyForwardedProposals)
+	}
+	dones[0]()
+	done, err := s.forwardProposal()
+	if err != nil {
+		t.Fatalf("err = %v after a forwarded proposal is done, want nil", err)
+	}
+	done()
+	dones[1]()
+	if s.forwardedProposals != 0 {
+		t.Errorf("forwarded proposals = %d, want 0", s.forwardedProposals)
+	}
+
+	// the proposals of the leader are not forwarded
+	s.lead = 1
+	for i := 0; i < 3; i++ {
+		if _, err := s.forwardProposal(); err != nil {
+			t.Fatalf("#%d: err = %v on the leader, want nil", i, err)
+		}
+	}
+}
+
+func TestShouldSnapshot(t *testing.T) {
+	tests := []struct {
+		cfg      ServerConfig
+		override uint64
+		cost     time.Duration
+		ep       etcdProgress
+
+		w bool
+	}{
+		{ServerConfig{SnapshotCount: 10}, 0, 0, etcdProgress{snapi: 5, appliedi: 15}, false},
+		{ServerConfig{SnapshotCount: 10}, 0, 0, etcdProgress{snapi: 5, appliedi: 16}, true},
+		{ServerConfig{SnapshotCount: 10}, 20, 0, etcdProgress{snapi: 5, appliedi: 16}, false},
+		// auto mode
+		{ServerConfig{SnapshotCount: 100000, SnapshotCountAuto: true}, 0, 0, etcdProgress{appliedi: minAutoSnapshotCount}, false},
+		{ServerConfig{SnapshotCount: 100000, SnapshotCountAuto: true}, 0, 0, etcdProgress{appliedi: minAutoSnapshotCount + 1}, true},
+		{ServerConfig{SnapshotCount: 100000, SnapshotCountAuto: true}, 0, time.Second, etcdProgress{appliedi: 5000, snapApplyTime: time.Second / 2}, false},
+		{ServerConfig{SnapshotCount: 100000, SnapshotCountAuto: true}, 0, time.Second, etcdProgress{appliedi: 5000, snapApplyTime: time.Second}, true},
+		{ServerConfig{SnapshotCount: 100000, SnapshotCountAuto: true}, 0, time.Hour, etcdProgress{appliedi: 5000, snapApplyTime: maxAutoSnapshotReplay}, true},
+		{ServerConfig{SnapshotCount: 100000, SnapshotCountAuto: true}, 0, time.Hour, etcdProgress{appliedi: 5000, snapBytes: maxAutoSnapshotEntryBytes}, true},
+		{ServerConfig{SnapshotCount: 100000, SnapshotCountAuto: true}, 0, time.Hour, etcdProgress{appliedi: 100001}, true},
+		// a count set at runtime disables the auto mode
+		{ServerConfig{SnapshotCount: 100000, SnapshotCountAuto: true}, 20000, time.Hour, etcdProgress{appliedi: 20001}, true},
+	}
+	for i, tt := range tests {
+		s := &EtcdServer{Cfg: tt.cfg, snapshotCount: tt.override, snapshotCost: int64(tt.cost)}
+		if g := s.shouldSnapshot(&tt.ep); g != tt.w {
+			t.Errorf("#%d: should snapshot = %v, want %v", i, g, tt.w)
+		}
+	}
+}
@@ -19,6 +19,7 @@ import (
 
 	"go.etcd.io/etcd/etcdserver/api/snap"
 	"go.etcd.io/etcd/mvcc/backend"
+	"go.etcd.io/etcd/raft"
 	"go.etcd.io/etcd/raft/raftpb"
 
 	humanize "github.com/dustin/go-humanize"
@@ -61,6 +62,32 @@ func (s *EtcdServer) createMergedSnapshotMessage(m raftpb.Message, snapt, snapi
 	return *snap.NewMessage(m, rc, dbsnap.Size())
 }
 
+// LatestSnapshot returns the raft snapshot last taken by the member, which
+// holds the v2 store, merged with a snapshot of the v3 backend. The backend
+// is taken after the raft snapshot, so it is at the same index or later; a
+// member loading both replays the raft log from the snapshot index, and
+// skips the entries already applied to the backend, as it does on restart.
+func (s *EtcdServer) LatestSnapshot() (*snap.Message, error) {
+	sn, err := s.r.raftStorage.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	if raft.IsEmptySnap(sn) {
+		return nil, snap.ErrNoSnapshot
+	}
+
+	s.KV().Commit()
+	dbsnap := s.be.Snapshot()
+	rc := newSnapshotReaderCloser(s.getLogger(), dbsnap)
+	m := raftpb.Message{
+		Type:     raftpb.MsgSnap,
+		From:     uint64(s.ID()),
+		Term:     sn.Metadata.Term,
+		Snapshot: sn,
+	}
+	return snap.NewMessage(m, rc, dbsnap.Size()), nil
+}
+
 func newSnapshotReaderCloser(lg *zap.Logger, snapshot backend.Snapshot) io.ReadCloser {
 	pr, pw := io.Pipe()
 	go func() {
@@ -0,0 +1,73 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdserver
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// minAutoSnapshotCount is the least number of entries between two
+	// snapshots triggered in auto mode.
+	minAutoSnapshotCount = 1000
+	// maxAutoSnapshotReplay is the most apply time of the entries left to
+	// replay after the last snapshot in auto mode, whatever the cost of
+	// the snapshots, so that the recovery time is bounded.
+	maxAutoSnapshotReplay = 10 * time.Second
+	// maxAutoSnapshotEntryBytes is the most size of the entries applied
+	// since the last snapshot in auto mode; they are kept in memory until
+	// the raft log is compacted after a snapshot.
+	maxAutoSnapshotEntryBytes = 256 * 1024 * 1024
+)
+
+// snapshotCountAuto returns true if the snapshots are triggered in auto
+// mode. A snapshot count set at runtime takes precedence.
+func (s *EtcdServer) snapshotCountAuto() bool {
+	return s.Cfg.SnapshotCountAuto && atomic.LoadUint64(&s.snapshotCount) == 0
+}
+
+// shouldSnapshot returns true if a snapshot must be taken at the applied
+// index of ep.
+//
+// In auto mode, a snapshot is taken once applying the entries since the last
+// one took as long as that snapshot, which the restore of the next one is
+// expected to cost as well: the recovery time stays within about twice the
+// cost of a snapshot. Cheap snapshots are taken often, and costly ones
+// only after many entries, up to maxAutoSnapshotReplay of apply time.
+//
+// Whatever the mode, a snapshot is taken when requested by the memory
+// monitor, to compact the raft log.
+func (s *EtcdServer) shouldSnapshot(ep *etcdProgress) bool {
+	n := ep.appliedi - ep.snapi
+	if n > 0 && s.memorySnapshotRequested() {
+		// compact the raft log held in memory
+		return true
+	}
+	if !s.snapshotCountAuto() {
+		return n > s.getSnapshotCount()
+	}
+	switch {
+	case n <= minAutoSnapshotCount:
+		return false
+	case n > s.Cfg.SnapshotCount, ep.snapBytes >= maxAutoSnapshotEntryBytes:
+		return true
+	}
+	target := time.Duration(atomic.LoadInt64(&s.snapshotCost))
+	if target > maxAutoSnapshotReplay {
+		target = maxAutoSnapshotReplay
+	}
+	return ep.snapApplyTime >= target
+}
@@ -87,6 +87,23 @@ func longestConnected(tp rafthttp.Transporter, membs []types.ID) (types.ID, bool
 	return longest, true
 }
 
+// preferredLeader returns the active member with the highest election
+// priority, if that priority is higher than the given priority of the
+// leader. Ties are broken by the lowest member ID. Witnesses, learners and
+// members the leader placement lp does not allow are never preferred.
+func preferredLeader(tp rafthttp.Transporter, membs []*membership.Member, lead types.ID, priority uint, lp LeaderPlacement) (types.ID, bool) {
+	var preferred types.ID
+	for _, m := range membs {
+		if m.ID == lead || m.Witness || m.IsLearner || !lp.Allows(m.Metadata) || tp.ActiveSince(m.ID).IsZero() {
+			continue
+		}
+		if m.ElectionPriority > priority || preferred != 0 && m.ElectionPriority == priority && m.ID < preferred {
+			preferred, priority = m.ID, m.ElectionPriority
+		}
+	}
+	return preferred, preferred != 0
+}
+
 type notifier struct {
 	c   chan struct{}
 	err error
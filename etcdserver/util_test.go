@@ -63,6 +63,7 @@ func TestLongestConnected(t *testing.T) {
 
 type nopTransporterWithActiveTime struct {
 	activeMap map[types.ID]time.Time
+	seenMap   map[types.ID]time.Time
 }
 
 // newNopTransporterWithActiveTime creates nopTransporterWithActiveTime with the first member
@@ -90,3 +91,63 @@ func (s *nopTransporterWithActiveTime) Stop()                               {}
 func (s *nopTransporterWithActiveTime) Pause()                              {}
 func (s *nopTransporterWithActiveTime) Resume()                             {}
 func (s *nopTransporterWithActiveTime) reset(am map[types.ID]time.Time)     { s.activeMap = am }
+
+func (s *nopTransporterWithActiveTime) LastSeen(id types.ID) time.Time {
+	if !s.activeMap[id].IsZero() {
+		return time.Now()
+	}
+	return s.seenMap[id]
+}
+
+func TestPreferredLeader(t *testing.T) {
+	membs := []*membership.Member{
+		{ID: 1, Attributes: membership.Attributes{ElectionPriority: 1}},
+		{ID: 2, Attributes: membership.Attributes{ElectionPriority: 3}},
+		{ID: 3, Attributes: membership.Attributes{ElectionPriority: 3}},
+		{ID: 4, Attributes: membership.Attributes{ElectionPriority: 5}},
+		{ID: 5, Attributes: membership.Attributes{ElectionPriority: 9, Witness: true}},
+	}
+	now := time.Now()
+	tests := []struct {
+		active   map[types.ID]time.Time
+		lead     types.ID
+		priority uint
+
+		wid types.ID
+		wok bool
+	}{
+		{map[types.ID]time.Time{1: now, 2: now, 3: now, 4: now, 5: now}, 1, 1, 4, true},
+		// member 4 is not connected; ties go to the lowest ID
+		{map[types.ID]time.Time{1: now, 2: now, 3: now}, 1, 1, 2, true},
+		// witnesses are never preferred
+		{map[types.ID]time.Time{1: now, 3: now, 4: now, 5: now}, 4, 5, 0, false},
+		// equal priority does not move leadership
+		{map[types.ID]time.Time{1: now, 2: now, 3: now}, 3, 3, 0, false},
+		{map[types.ID]time.Time{}, 1, 1, 0, false},
+	}
+	for i, tt := range tests {
+		tr := &nopTransporterWithActiveTime{activeMap: tt.active}
+		id, ok := preferredLeader(tr, membs, tt.lead, tt.priority, nil)
+		if id != tt.wid || ok != tt.wok {
+			t.Errorf("#%d: preferred leader = %s, %v, want %s, %v", i, id, ok, tt.wid, tt.wok)
+		}
+	}
+}
+
+func TestPreferredLeaderPlacement(t *testing.T) {
+	membs := []*membership.Member{
+		{ID: 1, Metadata: map[string]string{"zone": "a"}},
+		{ID: 2, Metadata: map[string]string{"zone": "c"}, Attributes: membership.Attributes{ElectionPriority: 9}},
+		{ID: 3, Metadata: map[string]string{"zone": "b"}, Attributes: membership.Attributes{ElectionPriority: 5}},
+	}
+	lp, err := ParseLeaderPlacement("zone!=c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	tr := &nopTransporterWithActiveTime{activeMap: map[types.ID]time.Time{1: now, 2: now, 3: now}}
+	// member 2 has the highest priority, but is out of place
+	if id, ok := preferredLeader(tr, membs, 1, 0, lp); id != 3 || !ok {
+		t.Errorf("preferred leader = %s, %v, want %s, true", id, ok, types.ID(3))
+	}
+}
@@ -16,8 +16,11 @@ package etcdserver
 
 import (
 	"context"
+	"strings"
+	"sync/atomic"
 	"time"
 
+	"go.etcd.io/etcd/etcdserver/api/v2error"
 	"go.etcd.io/etcd/etcdserver/api/v2store"
 	pb "go.etcd.io/etcd/etcdserver/etcdserverpb"
 )
@@ -28,6 +31,8 @@ type RequestV2Handler interface {
 	Post(ctx context.Context, r *RequestV2) (Response, error)
 	Put(ctx context.Context, r *RequestV2) (Response, error)
 	Delete(ctx context.Context, r *RequestV2) (Response, error)
+	Claim(ctx context.Context, r *RequestV2) (Response, error)
+	Undelete(ctx context.Context, r *RequestV2) (Response, error)
 	QGet(ctx context.Context, r *RequestV2) (Response, error)
 	Get(ctx context.Context, r *RequestV2) (Response, error)
 	Head(ctx context.Context, r *RequestV2) (Response, error)
@@ -59,12 +64,25 @@ func (a *reqV2HandlerStore) Delete(ctx context.Context, r *RequestV2) (Response,
 	return a.applier.Delete(r), nil
 }
 
+func (a *reqV2HandlerStore) Claim(ctx context.Context, r *RequestV2) (Response, error) {
+	return a.applier.Claim(r), nil
+}
+
+func (a *reqV2HandlerStore) Undelete(ctx context.Context, r *RequestV2) (Response, error) {
+	return a.applier.Undelete(r), nil
+}
+
 func (a *reqV2HandlerStore) QGet(ctx context.Context, r *RequestV2) (Response, error) {
 	return a.applier.QGet(r), nil
 }
 
 func (a *reqV2HandlerStore) Get(ctx context.Context, r *RequestV2) (Response, error) {
 	if r.Wait {
+		if r.WaitExisting {
+			// the key is returned as soon as it exists
+			wc, err := a.store.WatchExisting(r.Path)
+			return Response{Watcher: wc}, err
+		}
 		wc, err := a.store.Watch(r.Path, r.Recursive, r.Stream, r.Since)
 		return Response{Watcher: wc}, err
 	}
@@ -77,6 +95,19 @@ func (a *reqV2HandlerStore) Head(ctx context.Context, r *RequestV2) (Response, e
 	return Response{Event: ev}, err
 }
 
+// Get serves the watches from an index cleared from the event history with
+// the event replayed from the committed entries, if possible.
+func (a *reqV2HandlerEtcdServer) Get(ctx context.Context, r *RequestV2) (Response, error) {
+	resp, err := a.reqV2HandlerStore.Get(ctx, r)
+	if r.Wait && !r.Stream && isEventIndexCleared(err) {
+		if ev := a.s.replayV2Event(r.Path, r.Recursive, r.Since); ev != nil {
+			v2WatchEventsReplayed.Inc()
+			return Response{Watcher: newEventWatcher(ev, ev.EtcdIndex)}, nil
+		}
+	}
+	return resp, err
+}
+
 func (a *reqV2HandlerEtcdServer) Post(ctx context.Context, r *RequestV2) (Response, error) {
 	return a.processRaftRequest(ctx, r)
 }
@@ -89,6 +120,14 @@ func (a *reqV2HandlerEtcdServer) Delete(ctx context.Context, r *RequestV2) (Resp
 	return a.processRaftRequest(ctx, r)
 }
 
+func (a *reqV2HandlerEtcdServer) Claim(ctx context.Context, r *RequestV2) (Response, error) {
+	return a.processRaftRequest(ctx, r)
+}
+
+func (a *reqV2HandlerEtcdServer) Undelete(ctx context.Context, r *RequestV2) (Response, error) {
+	return a.processRaftRequest(ctx, r)
+}
+
 func (a *reqV2HandlerEtcdServer) QGet(ctx context.Context, r *RequestV2) (Response, error) {
 	return a.processRaftRequest(ctx, r)
 }
@@ -98,12 +137,22 @@ func (a *reqV2HandlerEtcdServer) processRaftRequest(ctx context.Context, r *Requ
 	if err != nil {
 		return Response{}, err
 	}
+	done, err := a.s.forwardProposal()
+	if err != nil {
+		return Response{}, err
+	}
+	defer done()
+	start := time.Now()
+	if err = a.s.ops.propose(ctx); err != nil {
+		return Response{}, a.s.parseProposeCtxErr(err, start)
+	}
 	ch := a.s.w.Register(r.ID)
 
-	start := time.Now()
 	a.s.r.Propose(ctx, data)
 	proposalsPending.Inc()
 	defer proposalsPending.Dec()
+	atomic.AddInt64(&a.s.pendingProposals, 1)
+	defer atomic.AddInt64(&a.s.pendingProposals, -1)
 
 	select {
 	case x := <-ch:
@@ -119,7 +168,29 @@ func (a *reqV2HandlerEtcdServer) processRaftRequest(ctx context.Context, r *Requ
 }
 
 func (s *EtcdServer) Do(ctx context.Context, r pb.Request) (Response, error) {
+	if err := s.checkRecursiveKeys(&r); err != nil {
+		return Response{}, err
+	}
+	if err := s.checkV2WriteRate(&r); err != nil {
+		return Response{}, err
+	}
+	if err := s.checkV2KeyQuota(&r); err != nil {
+		return Response{}, err
+	}
+	if err := s.checkV2MemoryLimit(&r); err != nil {
+		return Response{}, err
+	}
 	r.ID = s.reqIDGen.Next()
+	if r.ClientRequestID != "" {
+		// the results of the writes carrying a request ID expire from
+		// their time, so that every member expires them alike
+		r.ClientRequestTime = s.LeaderTime().UnixNano()
+	}
+	if r.Method == "DELETE" && r.Recursive {
+		var done func()
+		ctx, done = s.ops.start(ctx, deleteOperationID(OperationRecursiveDelete, r.ID), OperationRecursiveDelete, strings.TrimPrefix(r.Path, StoreKeysPrefix))
+		defer done()
+	}
 	h := &reqV2HandlerEtcdServer{
 		reqV2HandlerStore: reqV2HandlerStore{
 			store:   s.v2store,
@@ -133,8 +204,53 @@ func (s *EtcdServer) Do(ctx context.Context, r pb.Request) (Response, error) {
 	return resp, err
 }
 
+// checkRecursiveKeys rejects recursive reads and deletes that touch more
+// keys than the configured maximum. The keys are counted in the local store
+// before the request is proposed, so that members configured with different
+// limits never disagree on applying it.
+func (s *EtcdServer) checkRecursiveKeys(r *pb.Request) error {
+	max := int(s.Cfg.MaxRecursiveKeys)
+	if max == 0 || !r.Recursive || r.Wait {
+		return nil
+	}
+	switch r.Method {
+	case "GET", "HEAD", "DELETE":
+	default:
+		return nil
+	}
+
+	n := s.v2store.CountKeys(r.Path, max)
+	switch {
+	case n > max:
+		recursiveKeyLimit.WithLabelValues(r.Method, "rejected").Inc()
+		return v2error.NewError(v2error.EcodeTooManyKeys, r.Path, s.v2store.Index())
+	case n >= max-max/5:
+		recursiveKeyLimit.WithLabelValues(r.Method, "near").Inc()
+	}
+	return nil
+}
+
+// checkV2WriteRate rejects the writes to the keys exceeding their write
+// rate limit. The keys are matched without the store prefix, as the clients
+// see them; the internal writes to the cluster metadata are never limited.
+func (s *EtcdServer) checkV2WriteRate(r *pb.Request) error {
+	switch r.Method {
+	case "POST", "PUT", "DELETE", "CLAIM", "UNDELETE":
+	default:
+		return nil
+	}
+	if !strings.HasPrefix(r.Path, StoreKeysPrefix+"/") {
+		return nil
+	}
+	key := r.Path[len(StoreKeysPrefix):]
+	if err := s.checkKeyWriteRate(key); err != nil {
+		return v2error.NewError(v2error.EcodeKeyRateLimited, key, s.v2store.Index())
+	}
+	return nil
+}
+
 // Handle interprets r and performs an operation on s.store according to r.Method
-// and other fields. If r.Method is "POST", "PUT", "DELETE", or a "GET" with
+// and other fields. If r.Method is "POST", "PUT", "DELETE", "CLAIM", "UNDELETE", or a "GET" with
 // Quorum == true, r will be sent through consensus before performing its
 // respective operation. Do will block until an action is performed or there is
 // an error.
@@ -149,6 +265,10 @@ func (r *RequestV2) Handle(ctx context.Context, v2api RequestV2Handler) (Respons
 		return v2api.Put(ctx, r)
 	case "DELETE":
 		return v2api.Delete(ctx, r)
+	case "CLAIM":
+		return v2api.Claim(ctx, r)
+	case "UNDELETE":
+		return v2api.Undelete(ctx, r)
 	case "QGET":
 		return v2api.QGet(ctx, r)
 	case "GET":
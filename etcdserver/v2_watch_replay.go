@@ -0,0 +1,200 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdserver
+
+import (
+	"encoding/json"
+	"math"
+	"sync/atomic"
+
+	"go.etcd.io/etcd/etcdserver/api/membership"
+	"go.etcd.io/etcd/etcdserver/api/v2error"
+	"go.etcd.io/etcd/etcdserver/api/v2store"
+	pb "go.etcd.io/etcd/etcdserver/etcdserverpb"
+	"go.etcd.io/etcd/pkg/pbutil"
+	"go.etcd.io/etcd/pkg/types"
+	"go.etcd.io/etcd/raft"
+	"go.etcd.io/etcd/raft/raftpb"
+
+	"go.uber.org/zap"
+)
+
+// replayV2Event returns the first event at or after sinceIndex matching key,
+// for a watch from an index cleared from the event history of the store. The
+// event is recovered by replaying the committed entries kept in memory since
+// the last snapshot onto the store of the snapshot. It returns nil if the
+// replay is disabled, busy, longer than Cfg.V2WatchReplayEntries, or does not
+// reach the state of the store.
+func (s *EtcdServer) replayV2Event(key string, recursive bool, sinceIndex uint64) *v2store.Event {
+	if s.Cfg.V2WatchReplayEntries <= 0 || s.r.raftStorage == nil {
+		return nil
+	}
+	// one replay at a time, the watches failing meanwhile
+	if !atomic.CompareAndSwapInt32(&s.v2Replaying, 0, 1) {
+		return nil
+	}
+	defer atomic.StoreInt32(&s.v2Replaying, 0)
+
+	snap, err := s.r.raftStorage.Snapshot()
+	if err != nil {
+		return nil
+	}
+	ai := s.getAppliedIndex()
+	if ai <= snap.Metadata.Index || ai-snap.Metadata.Index > uint64(s.Cfg.V2WatchReplayEntries) {
+		return nil
+	}
+	ents, err := s.r.raftStorage.Entries(snap.Metadata.Index+1, ai+1, math.MaxUint64)
+	if err != nil {
+		return nil
+	}
+
+	st := v2store.New(StoreClusterPrefix, StoreKeysPrefix)
+	if !raft.IsEmptySnap(snap) {
+		if err = st.Recovery(snap.Data); err != nil {
+			return nil
+		}
+	}
+	// the events before the snapshot are not recorded anywhere
+	if st.Index() >= sinceIndex {
+		return nil
+	}
+	w, err := st.Watch(key, recursive, false, sinceIndex)
+	if err != nil {
+		return nil
+	}
+	defer w.Remove()
+
+	// the members write their attributes and the cluster version to the
+	// store through the cluster
+	cl := membership.NewClusterFromMembers(zap.NewNop(), "", s.cluster.ID(), s.cluster.Members())
+	cl.SetStore(st)
+	r := NewV2Replayer(s.getLogger(), st, cl).s
+	var found, last *v2store.Event
+	for i := range ents {
+		e := &ents[i]
+		switch e.Type {
+		case raftpb.EntryNormal:
+			// only the new events are in the history, not the events of
+			// reads or of deduplicated writes
+			idx := st.Index()
+			if ev := r.replayV2Entry(e); ev != nil && ev.Index() > idx && !ev.Refresh {
+				last = ev
+			}
+		case raftpb.EntryConfChange:
+			if !replayConfChange(cl, e) {
+				return nil
+			}
+		}
+		if found == nil {
+			select {
+			case found = <-w.EventChan():
+			default:
+			}
+		}
+	}
+	if found == nil || last == nil || !s.sameV2Event(last) {
+		return nil
+	}
+	found.EtcdIndex = s.v2store.Index()
+	return found
+}
+
+// replayV2Entry applies the v2 request of e, if any, and returns its event.
+func (s *EtcdServer) replayV2Entry(e *raftpb.Entry) *v2store.Event {
+	if len(e.Data) == 0 {
+		return nil
+	}
+	var req *RequestV2
+	var raftReq pb.InternalRaftRequest
+	if !pbutil.MaybeUnmarshal(&raftReq, e.Data) { // backward compatible
+		var r pb.Request
+		pbutil.MustUnmarshal(&r, e.Data)
+		req = (*RequestV2)(&r)
+	} else if raftReq.V2 != nil {
+		req = (*RequestV2)(raftReq.V2)
+	}
+	if req == nil {
+		return nil
+	}
+	return s.applyV2Request(req).Event
+}
+
+// replayConfChange applies the changes of the store made by the
+// configuration change of e to the members of cl. It returns false if the
+// change cannot be replayed.
+func replayConfChange(cl *membership.RaftCluster, e *raftpb.Entry) bool {
+	var cc raftpb.ConfChange
+	pbutil.MustUnmarshal(&cc, e.Data)
+	if cl.ValidateConfigurationChange(cc) != nil {
+		// refused changes do not change the store
+		return true
+	}
+	switch cc.Type {
+	case raftpb.ConfChangeAddNode, raftpb.ConfChangeAddLearnerNode:
+		m := new(membership.Member)
+		if json.Unmarshal(cc.Context, m) != nil {
+			return false
+		}
+		cl.AddMember(m)
+	case raftpb.ConfChangeRemoveNode:
+		cl.RemoveMember(types.ID(cc.NodeID))
+	case raftpb.ConfChangeUpdateNode:
+		m := new(membership.Member)
+		if json.Unmarshal(cc.Context, m) != nil || cl.Member(m.ID) == nil {
+			return false
+		}
+		cl.UpdateRaftAttributes(m.ID, m.RaftAttributes)
+	}
+	return true
+}
+
+// sameV2Event returns true if the event history of the store holds e, the
+// last event of a replay, so that the replay reached the state of the store.
+func (s *EtcdServer) sameV2Event(e *v2store.Event) bool {
+	w, err := s.v2store.Watch("/", true, false, e.Index())
+	if err != nil {
+		return false
+	}
+	defer w.Remove()
+	select {
+	case ev := <-w.EventChan():
+		return ev.Index() == e.Index() && ev.Action == e.Action && ev.Node.Key == e.Node.Key
+	default:
+		return false
+	}
+}
+
+// isEventIndexCleared returns true if err reports a watch from an index
+// cleared from the event history.
+func isEventIndexCleared(err error) bool {
+	e, ok := err.(*v2error.Error)
+	return ok && e.ErrorCode == v2error.EcodeEventIndexCleared
+}
+
+// eventWatcher is a watcher receiving a single given event.
+type eventWatcher struct {
+	c          chan *v2store.Event
+	startIndex uint64
+}
+
+func newEventWatcher(e *v2store.Event, startIndex uint64) v2store.Watcher {
+	w := &eventWatcher{c: make(chan *v2store.Event, 1), startIndex: startIndex}
+	w.c <- e
+	return w
+}
+
+func (w *eventWatcher) EventChan() chan *v2store.Event { return w.c }
+func (w *eventWatcher) StartIndex() uint64             { return w.startIndex }
+func (w *eventWatcher) Remove()                        {}
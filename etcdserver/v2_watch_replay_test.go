@@ -0,0 +1,124 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdserver
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"go.etcd.io/etcd/etcdserver/api/membership"
+	"go.etcd.io/etcd/etcdserver/api/v2store"
+	pb "go.etcd.io/etcd/etcdserver/etcdserverpb"
+	"go.etcd.io/etcd/pkg/pbutil"
+	"go.etcd.io/etcd/raft"
+	"go.etcd.io/etcd/raft/raftpb"
+
+	"go.uber.org/zap"
+)
+
+// newReplayTestServer returns a server which applied a member addition, a
+// write of /foo and then enough writes of /other, along with a publish of
+// the attributes of the member, to clear the write of /foo from the event
+// history. If diverged is set, the last write applied is not the one of the
+// log.
+func newReplayTestServer(t *testing.T, replayEntries int, diverged bool) *EtcdServer {
+	st := v2store.New(StoreClusterPrefix, StoreKeysPrefix)
+	cl := membership.NewCluster(zap.NewExample(), "")
+	cl.SetStore(st)
+	ms := raft.NewMemoryStorage()
+	srv := &EtcdServer{
+		lgMu:    new(sync.RWMutex),
+		lg:      zap.NewExample(),
+		Cfg:     ServerConfig{V2WatchReplayEntries: replayEntries},
+		r:       raftNode{raftNodeConfig: raftNodeConfig{raftStorage: ms}},
+		v2store: st,
+		cluster: cl,
+	}
+	srv.applyV2 = &applierV2store{store: st, cluster: cl}
+
+	m := &membership.Member{ID: 1, RaftAttributes: membership.RaftAttributes{PeerURLs: []string{"http://127.0.0.1:2380"}}}
+	ctx, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cc := raftpb.ConfChange{Type: raftpb.ConfChangeAddNode, NodeID: 1, Context: ctx}
+	ents := []raftpb.Entry{{Type: raftpb.EntryConfChange, Index: 1, Term: 1, Data: pbutil.MustMarshal(&cc)}}
+	cl.AddMember(m)
+
+	for i := uint64(2); i <= 1102; i++ {
+		r := pb.Request{Method: "PUT", Path: "/1/other", Val: "bar"}
+		switch i {
+		case 2:
+			r.Path = "/1/foo"
+		case 500:
+			r.Path, r.Val = membership.MemberAttributesStorePath(1), `{"name":"m1"}`
+		}
+		ents = append(ents, raftpb.Entry{Type: raftpb.EntryNormal, Index: i, Term: 1, Data: pbutil.MustMarshal(&r)})
+		if diverged && i == 1102 {
+			r.Path = "/1/diverged"
+		}
+		if resp := srv.applyV2Request((*RequestV2)(&r)); resp.Err != nil {
+			t.Fatal(resp.Err)
+		}
+	}
+	if err = ms.Append(ents); err != nil {
+		t.Fatal(err)
+	}
+	srv.setAppliedIndex(1102)
+	return srv
+}
+
+func TestV2WatchReplay(t *testing.T) {
+	srv := newReplayTestServer(t, 2000, false)
+	h := &reqV2HandlerEtcdServer{reqV2HandlerStore{store: srv.v2store, applier: srv.applyV2}, srv}
+
+	resp, err := h.Get(context.TODO(), &RequestV2{Method: "GET", Path: "/1/foo", Wait: true, Since: 2})
+	if err != nil {
+		t.Fatalf("err = %v, want nil", err)
+	}
+	ev := <-resp.Watcher.EventChan()
+	if ev.Node.Key != "/1/foo" || ev.Index() != 2 || ev.Action != v2store.Set {
+		t.Errorf("event = %+v, want the set of /1/foo at 2", ev)
+	}
+	if ev.EtcdIndex != srv.v2store.Index() {
+		t.Errorf("etcd index = %d, want %d", ev.EtcdIndex, srv.v2store.Index())
+	}
+
+	// streaming watches are not replayed
+	_, err = h.Get(context.TODO(), &RequestV2{Method: "GET", Path: "/1/foo", Wait: true, Stream: true, Since: 2})
+	if !isEventIndexCleared(err) {
+		t.Errorf("stream err = %v, want event index cleared", err)
+	}
+}
+
+func TestV2WatchReplayBounded(t *testing.T) {
+	for _, n := range []int{0, 1000} {
+		srv := newReplayTestServer(t, n, false)
+		h := &reqV2HandlerEtcdServer{reqV2HandlerStore{store: srv.v2store, applier: srv.applyV2}, srv}
+		_, err := h.Get(context.TODO(), &RequestV2{Method: "GET", Path: "/1/foo", Wait: true, Since: 2})
+		if !isEventIndexCleared(err) {
+			t.Errorf("replay entries %d: err = %v, want event index cleared", n, err)
+		}
+	}
+}
+
+func TestV2WatchReplayDiverged(t *testing.T) {
+	srv := newReplayTestServer(t, 2000, true)
+	if ev := srv.replayV2Event("/1/foo", false, 2); ev != nil {
+		t.Errorf("event = %+v, want none once the store diverged from the log", ev)
+	}
+}
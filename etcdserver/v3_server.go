@@ -18,6 +18,8 @@ import (
 	"bytes"
 	"context"
 	"encoding/binary"
+	"fmt"
+	"sync/atomic"
 	"time"
 
 	"go.etcd.io/etcd/auth"
@@ -110,6 +112,15 @@ func (s *EtcdServer) Range(ctx context.Context, r *pb.RangeRequest) (*pb.RangeRe
 }
 
 func (s *EtcdServer) Put(ctx context.Context, r *pb.PutRequest) (*pb.PutResponse, error) {
+	if err := s.checkKeyWriteRate(string(r.Key)); err != nil {
+		return nil, err
+	}
+	if err := s.checkV3KeyQuota(r.Key); err != nil {
+		return nil, err
+	}
+	if err := s.checkMemoryLimit(); err != nil {
+		return nil, err
+	}
 	resp, err := s.raftRequest(ctx, pb.InternalRaftRequest{Put: r})
 	if err != nil {
 		return nil, err
@@ -118,6 +129,15 @@ func (s *EtcdServer) Put(ctx context.Context, r *pb.PutRequest) (*pb.PutResponse
 }
 
 func (s *EtcdServer) DeleteRange(ctx context.Context, r *pb.DeleteRangeRequest) (*pb.DeleteRangeResponse, error) {
+	if len(r.RangeEnd) == 0 {
+		if err := s.checkKeyWriteRate(string(r.Key)); err != nil {
+			return nil, err
+		}
+	} else {
+		var done func()
+		ctx, done = s.ops.start(ctx, deleteOperationID(OperationRangeDelete, s.reqIDGen.Next()), OperationRangeDelete, fmt.Sprintf("[%q, %q)", r.Key, r.RangeEnd))
+		defer done()
+	}
 	resp, err := s.raftRequest(ctx, pb.InternalRaftRequest{DeleteRange: r})
 	if err != nil {
 		return nil, err
@@ -150,6 +170,17 @@ func (s *EtcdServer) Txn(ctx context.Context, r *pb.TxnRequest) (*pb.TxnResponse
 		return resp, err
 	}
 
+	if err := s.checkTxnWriteRate(r); err != nil {
+		return nil, err
+	}
+	if err := s.checkTxnKeyQuota(r); err != nil {
+		return nil, err
+	}
+	if txnHasPuts(r) {
+		if err := s.checkMemoryLimit(); err != nil {
+			return nil, err
+		}
+	}
 	resp, err := s.raftRequest(ctx, pb.InternalRaftRequest{Txn: r})
 	if err != nil {
 		return nil, err
@@ -214,6 +245,9 @@ func (s *EtcdServer) Compact(ctx context.Context, r *pb.CompactionRequest) (*pb.
 }
 
 func (s *EtcdServer) LeaseGrant(ctx context.Context, r *pb.LeaseGrantRequest) (*pb.LeaseGrantResponse, error) {
+	if err := s.checkMemoryLimit(); err != nil {
+		return nil, err
+	}
 	// no id given? choose one
 	for r.ID == int64(lease.NoLease) {
 		// only use positive int64 id's
@@ -589,16 +623,24 @@ func (s *EtcdServer) processInternalRaftRequestOnce(ctx context.Context, r pb.In
 		return nil, ErrRequestTooLarge
 	}
 
+	done, err := s.forwardProposal()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
 	id := r.ID
 	if id == 0 {
 		id = r.Header.ID
 	}
-	ch := s.w.Register(id)
-
 	cctx, cancel := context.WithTimeout(ctx, s.Cfg.ReqTimeout())
 	defer cancel()
-
 	start := time.Now()
+	if err = s.ops.propose(cctx); err != nil {
+		return nil, s.parseProposeCtxErr(err, start)
+	}
+	ch := s.w.Register(id)
+
 	err = s.r.Propose(cctx, data)
 	if err != nil {
 		proposalsFailed.Inc()
@@ -607,6 +649,8 @@ func (s *EtcdServer) processInternalRaftRequestOnce(ctx context.Context, r pb.In
 	}
 	proposalsPending.Inc()
 	defer proposalsPending.Dec()
+	atomic.AddInt64(&s.pendingProposals, 1)
+	defer atomic.AddInt64(&s.pendingProposals, -1)
 
 	select {
 	case x := <-ch:
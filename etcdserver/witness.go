@@ -0,0 +1,72 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdserver
+
+import (
+	"time"
+
+	"go.etcd.io/etcd/pkg/types"
+
+	"go.uber.org/zap"
+)
+
+// witnessTransferRetryInterval is the interval between attempts of a
+// witness to hand over leadership it won in an election.
+const witnessTransferRetryInterval = 500 * time.Millisecond
+
+// IsWitness returns true if the member is a witness. A witness votes and
+// persists the raft log like any other member, but does not serve client
+// requests and hands over leadership as soon as it wins an election.
+func (s *EtcdServer) IsWitness() bool { return s.Cfg.Witness }
+
+// leaderCandidateIDs returns the IDs of the members that may be leader,
+// restricted to the ones satisfying the leader placement if any does.
+func (s *EtcdServer) leaderCandidateIDs() []types.ID {
+	if ids := s.placementCandidateIDs(); len(ids) > 0 {
+		return ids
+	}
+	var ids []types.ID
+	for _, m := range s.cluster.Members() {
+		if !m.Witness && !m.IsLearner {
+			ids = append(ids, m.ID)
+		}
+	}
+	return ids
+}
+
+// transferWitnessLeadership transfers leadership away from the local
+// witness member until it is no longer leader.
+func (s *EtcdServer) transferWitnessLeadership() {
+	for s.isLeader() {
+		err := s.TransferLeadership()
+		if err == nil {
+			return
+		}
+		if lg := s.getLogger(); lg != nil {
+			lg.Warn(
+				"failed to transfer leadership from witness member",
+				zap.String("local-member-id", s.ID().String()),
+				zap.Error(err),
+			)
+		} else {
+			plog.Warningf("failed to transfer leadership from witness %s (%v)", s.ID(), err)
+		}
+		select {
+		case <-time.After(witnessTransferRetryInterval):
+		case <-s.stopping:
+			return
+		}
+	}
+}
@@ -0,0 +1,39 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdserver
+
+import (
+	"reflect"
+	"testing"
+
+	"go.etcd.io/etcd/etcdserver/api/membership"
+	"go.etcd.io/etcd/pkg/types"
+
+	"go.uber.org/zap"
+)
+
+// TestLeaderCandidateIDsWitness ensures the witnesses of the cluster are
+// never candidates for leadership.
+func TestLeaderCandidateIDsWitness(t *testing.T) {
+	membs := []*membership.Member{
+		{ID: 1, Attributes: membership.Attributes{Name: "node1"}},
+		{ID: 2, Attributes: membership.Attributes{Name: "node2", Witness: true}},
+		{ID: 3, Attributes: membership.Attributes{Name: "node3"}},
+	}
+	s := &EtcdServer{cluster: membership.NewClusterFromMembers(zap.NewExample(), "", types.ID(1), membs)}
+	if ids := s.leaderCandidateIDs(); !reflect.DeepEqual(ids, []types.ID{1, 3}) {
+		t.Errorf("candidates = %v, want [1 3]", ids)
+	}
+}
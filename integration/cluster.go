@@ -52,6 +52,7 @@ import (
 	"go.etcd.io/etcd/pkg/tlsutil"
 	"go.etcd.io/etcd/pkg/transport"
 	"go.etcd.io/etcd/pkg/types"
+	"go.etcd.io/etcd/wal"
 
 	"github.com/soheilhy/cmux"
 	"go.uber.org/zap"
@@ -644,6 +645,7 @@ func mustNewMember(t testing.TB, mcfg memberConfig) *member {
 	if mcfg.snapshotCatchUpEntries != 0 {
 		m.SnapshotCatchUpEntries = mcfg.snapshotCatchUpEntries
 	}
+	m.WALPreallocateBytes = wal.SegmentSizeBytes
 
 	// for the purpose of integration testing, simple token is enough
 	m.AuthToken = "simple"
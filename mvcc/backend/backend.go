@@ -48,6 +48,10 @@ var (
 	minSnapshotWarningTimeout = 30 * time.Second
 )
 
+// Backend is the storage engine of the v3 keyspace. The mvcc store only
+// accesses its data through Backend, ReadTx and BatchTx, so an engine
+// other than the bolt backend returned by New can be used by implementing
+// these interfaces.
 type Backend interface {
 	ReadTx() ReadTx
 	BatchTx() BatchTx
@@ -87,6 +91,8 @@ type backend struct {
 	sizeInUse int64
 	// commits counts number of commits since start
 	commits int64
+	// commitNanos is the total time spent committing since start
+	commitNanos int64
 
 	mu sync.RWMutex
 	db *bolt.DB
@@ -318,6 +324,11 @@ func (b *backend) Commits() int64 {
 	return atomic.LoadInt64(&b.commits)
 }
 
+// CommitDuration returns the total time spent committing since start
+func (b *backend) CommitDuration() time.Duration {
+	return time.Duration(atomic.LoadInt64(&b.commitNanos))
+}
+
 func (b *backend) Defrag() error {
 	return b.defrag()
 }
@@ -83,6 +83,52 @@ func TestBackendSnapshot(t *testing.T) {
 	newTx.Unlock()
 }
 
+// TestBackendSnapshotConsistent ensures a snapshot is a view of the backend
+// at the time it was taken, unaffected by writes committed afterwards.
+func TestBackendSnapshotConsistent(t *testing.T) {
+	b, tmpPath := NewTmpBackend(time.Hour, 10000)
+	defer cleanup(b, tmpPath)
+
+	tx := b.BatchTx()
+	tx.Lock()
+	tx.UnsafeCreateBucket([]byte("test"))
+	tx.UnsafePut([]byte("test"), []byte("foo"), []byte("bar"))
+	tx.Unlock()
+	b.ForceCommit()
+
+	snap := b.Snapshot()
+	defer snap.Close()
+
+	tx.Lock()
+	tx.UnsafePut([]byte("test"), []byte("foo"), []byte("baz"))
+	tx.UnsafePut([]byte("test"), []byte("foo1"), []byte("bar1"))
+	tx.Unlock()
+	b.ForceCommit()
+
+	f, err := ioutil.TempFile(os.TempDir(), "etcd_backend_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = snap.WriteTo(f); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	bcfg := DefaultBackendConfig()
+	bcfg.Path, bcfg.BatchInterval, bcfg.BatchLimit = f.Name(), time.Hour, 10000
+	nb := New(bcfg)
+	defer cleanup(nb, f.Name())
+
+	newTx := nb.BatchTx()
+	newTx.Lock()
+	ks, vs := newTx.UnsafeRange([]byte("test"), []byte("foo"), []byte("goo"), 0)
+	newTx.Unlock()
+	wks, wvs := [][]byte{[]byte("foo")}, [][]byte{[]byte("bar")}
+	if !reflect.DeepEqual(ks, wks) || !reflect.DeepEqual(vs, wvs) {
+		t.Errorf("snapshot keys, values = %q, %q, want %q, %q", ks, vs, wks, wvs)
+	}
+}
+
 func TestBackendBatchIntervalCommit(t *testing.T) {
 	// start backend with super short batch interval so
 	// we do not need to wait long before commit to happen.
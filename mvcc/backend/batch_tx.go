@@ -239,8 +239,10 @@ func (t *batchTx) commit(stop bool) {
 		rebalanceSec.Observe(t.tx.Stats().RebalanceTime.Seconds())
 		spillSec.Observe(t.tx.Stats().SpillTime.Seconds())
 		writeSec.Observe(t.tx.Stats().WriteTime.Seconds())
-		commitSec.Observe(time.Since(start).Seconds())
+		took := time.Since(start)
+		commitSec.Observe(took.Seconds())
 		atomic.AddInt64(&t.backend.commits, 1)
+		atomic.AddInt64(&t.backend.commitNanos, int64(took))
 
 		t.pending = 0
 		if err != nil {
@@ -101,6 +101,12 @@ type store struct {
 
 	fifoSched schedule.Scheduler
 
+	// compactingMu protects compacting.
+	compactingMu sync.Mutex
+	// compacting is the scheduled compaction deleting the compacted
+	// revisions from the backend, or nil.
+	compacting *scheduledCompaction
+
 	stopc chan struct{}
 
 	lg *zap.Logger
@@ -21,8 +21,54 @@ import (
 	"go.uber.org/zap"
 )
 
+// scheduledCompaction is a scheduled compaction in progress.
+type scheduledCompaction struct {
+	rev     int64
+	started time.Time
+	cancelc chan struct{}
+}
+
+// ScheduledCompaction returns the revision of the scheduled compaction
+// deleting the compacted revisions from the backend, and the time it
+// started, if one is in progress.
+func (s *store) ScheduledCompaction() (rev int64, started time.Time, ok bool) {
+	s.compactingMu.Lock()
+	defer s.compactingMu.Unlock()
+	if s.compacting == nil {
+		return 0, time.Time{}, false
+	}
+	return s.compacting.rev, s.compacting.started, true
+}
+
+// CancelScheduledCompaction stops the scheduled compaction in progress,
+// if any, and returns true if there was one. The compacted revisions it
+// left in the backend are deleted by the next compaction, or when the
+// store is restored.
+func (s *store) CancelScheduledCompaction() bool {
+	s.compactingMu.Lock()
+	defer s.compactingMu.Unlock()
+	if s.compacting == nil {
+		return false
+	}
+	select {
+	case <-s.compacting.cancelc:
+	default:
+		close(s.compacting.cancelc)
+	}
+	return true
+}
+
 func (s *store) scheduleCompaction(compactMainRev int64, keep map[revision]struct{}) bool {
 	totalStart := time.Now()
+	sc := &scheduledCompaction{rev: compactMainRev, started: totalStart, cancelc: make(chan struct{})}
+	s.compactingMu.Lock()
+	s.compacting = sc
+	s.compactingMu.Unlock()
+	defer func() {
+		s.compactingMu.Lock()
+		s.compacting = nil
+		s.compactingMu.Unlock()
+	}()
 	defer dbCompactionTotalMs.Observe(float64(time.Since(totalStart) / time.Millisecond))
 	keyCompactions := 0
 	defer func() { dbCompactionKeysCounter.Add(float64(keyCompactions)) }()
@@ -72,6 +118,18 @@ func (s *store) scheduleCompaction(compactMainRev int64, keep map[revision]struc
 
 		select {
 		case <-time.After(100 * time.Millisecond):
+		case <-sc.cancelc:
+			if s.lg != nil {
+				s.lg.Warn(
+					"canceled scheduled compaction",
+					zap.Int64("compact-revision", compactMainRev),
+					zap.Int("deleted-keys", keyCompactions),
+					zap.Duration("took", time.Since(totalStart)),
+				)
+			} else {
+				plog.Warningf("canceled scheduled compaction at %d after deleting %d keys (took %v)", compactMainRev, keyCompactions, time.Since(totalStart))
+			}
+			return false
 		case <-s.stopc:
 			return false
 		}
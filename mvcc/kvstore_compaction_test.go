@@ -97,6 +97,59 @@ func TestScheduleCompaction(t *testing.T) {
 	}
 }
 
+func TestCancelScheduledCompaction(t *testing.T) {
+	b, tmpPath := backend.NewDefaultTmpBackend()
+	s := NewStore(zap.NewExample(), b, &lease.FakeLessor{}, nil)
+	defer cleanup(s, b, tmpPath)
+
+	// more revisions than deleted by a batch, so that the compaction
+	// pauses before the next one
+	tx := s.b.BatchTx()
+	tx.Lock()
+	ibytes := newRevBytes()
+	for i := int64(1); i <= 10001; i++ {
+		revToBytes(revision{main: i}, ibytes)
+		tx.UnsafePut(keyBucketName, ibytes, []byte("bar"))
+	}
+	tx.Unlock()
+
+	if s.CancelScheduledCompaction() {
+		t.Fatalf("canceled a compaction while none is in progress")
+	}
+	donec := make(chan bool)
+	go func() { donec <- s.scheduleCompaction(10001, nil) }()
+	for {
+		if rev, _, ok := s.ScheduledCompaction(); ok {
+			if rev != 10001 {
+				t.Fatalf("compaction revision = %d, want %d", rev, 10001)
+			}
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if !s.CancelScheduledCompaction() {
+		t.Fatalf("failed to cancel the compaction in progress")
+	}
+	select {
+	case finished := <-donec:
+		if finished {
+			t.Fatalf("canceled compaction finished")
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("timeout waiting for the canceled compaction to stop")
+	}
+	if _, _, ok := s.ScheduledCompaction(); ok {
+		t.Errorf("canceled compaction still in progress")
+	}
+
+	tx.Lock()
+	_, vals := tx.UnsafeRange(metaBucketName, finishedCompactKeyName, nil, 0)
+	tx.Unlock()
+	if len(vals) != 0 {
+		t.Errorf("canceled compaction recorded as finished")
+	}
+}
+
 func TestCompactAllAndRestore(t *testing.T) {
 	b, tmpPath := backend.NewDefaultTmpBackend()
 	s0 := NewStore(zap.NewExample(), b, &lease.FakeLessor{}, nil)
@@ -0,0 +1,28 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build !linux
+
+package fileutil
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// DiskSpace returns the bytes available to unprivileged users and the
+// total size of the filesystem holding path.
+func DiskSpace(path string) (avail, total uint64, err error) {
+	return 0, 0, fmt.Errorf("cannot get disk space on %s", runtime.GOOS)
+}
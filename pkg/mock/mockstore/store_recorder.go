@@ -96,10 +96,21 @@ func (s *storeRecorder) CompareAndDelete(path, prevVal string, prevIdx uint64) (
 	})
 	return &v2store.Event{}, nil
 }
+func (s *storeRecorder) ClaimNext(path string) (*v2store.Event, error) {
+	s.Record(testutil.Action{
+		Name:   "ClaimNext",
+		Params: []interface{}{path},
+	})
+	return &v2store.Event{}, nil
+}
 func (s *storeRecorder) Watch(_ string, _, _ bool, _ uint64) (v2store.Watcher, error) {
 	s.Record(testutil.Action{Name: "Watch"})
 	return v2store.NewNopWatcher(), nil
 }
+func (s *storeRecorder) WatchExisting(_ string) (v2store.Watcher, error) {
+	s.Record(testutil.Action{Name: "WatchExisting"})
+	return v2store.NewNopWatcher(), nil
+}
 func (s *storeRecorder) Save() ([]byte, error) {
 	s.Record(testutil.Action{Name: "Save"})
 	return nil, nil
@@ -120,12 +131,33 @@ func (s *storeRecorder) Clone() v2store.Store {
 }
 
 func (s *storeRecorder) JsonStats() []byte { return nil }
+
+func (s *storeRecorder) KeyspaceStats(nodePath string) *v2store.KeyspaceStats {
+	s.Record(testutil.Action{
+		Name:   "KeyspaceStats",
+		Params: []interface{}{nodePath},
+	})
+	return &v2store.KeyspaceStats{}
+}
 func (s *storeRecorder) DeleteExpiredKeys(cutoff time.Time) {
 	s.Record(testutil.Action{
 		Name:   "DeleteExpiredKeys",
 		Params: []interface{}{cutoff},
 	})
 }
+func (s *storeRecorder) ExpiredKeys(cutoff time.Time, limit int) []v2store.ExpiredKey {
+	s.Record(testutil.Action{
+		Name:   "ExpiredKeys",
+		Params: []interface{}{cutoff, limit},
+	})
+	return nil
+}
+func (s *storeRecorder) ExpireKeys(keys []v2store.ExpiredKey, cutoff time.Time) {
+	s.Record(testutil.Action{
+		Name:   "ExpireKeys",
+		Params: []interface{}{keys, cutoff},
+	})
+}
 
 func (s *storeRecorder) HasTTLKeys() bool {
 	s.Record(testutil.Action{
@@ -134,6 +166,70 @@ func (s *storeRecorder) HasTTLKeys() bool {
 	return true
 }
 
+func (s *storeRecorder) GetMulti(nodePaths []string, sorted bool) ([]*v2store.Event, []error) {
+	s.Record(testutil.Action{
+		Name:   "GetMulti",
+		Params: []interface{}{nodePaths, sorted},
+	})
+	return make([]*v2store.Event, len(nodePaths)), make([]error, len(nodePaths))
+}
+
+func (s *storeRecorder) Lookup(nodePath string) (*v2store.NodeExtern, error) {
+	s.Record(testutil.Action{
+		Name:   "Lookup",
+		Params: []interface{}{nodePath},
+	})
+	return &v2store.NodeExtern{}, nil
+}
+
+func (s *storeRecorder) CountKeys(nodePath string, limit int) int {
+	s.Record(testutil.Action{
+		Name:   "CountKeys",
+		Params: []interface{}{nodePath, limit},
+	})
+	return 0
+}
+
+func (s *storeRecorder) Export(nodePath string) (*v2store.KeyExport, error) {
+	s.Record(testutil.Action{
+		Name:   "Export",
+		Params: []interface{}{nodePath},
+	})
+	return &v2store.KeyExport{}, nil
+}
+
+func (s *storeRecorder) Inspect(nodePath string) (*v2store.KeyInternals, error) {
+	s.Record(testutil.Action{
+		Name:   "Inspect",
+		Params: []interface{}{nodePath},
+	})
+	return &v2store.KeyInternals{Key: nodePath}, nil
+}
+
+func (s *storeRecorder) TombstoneRetention() time.Duration { return 0 }
+func (s *storeRecorder) SetTombstoneRetention(retention time.Duration) {
+	s.Record(testutil.Action{
+		Name:   "SetTombstoneRetention",
+		Params: []interface{}{retention},
+	})
+}
+
+func (s *storeRecorder) ListTombstones(nodePath string) []v2store.Tombstone {
+	s.Record(testutil.Action{
+		Name:   "ListTombstones",
+		Params: []interface{}{nodePath},
+	})
+	return nil
+}
+
+func (s *storeRecorder) Undelete(nodePath string, index uint64) (*v2store.Event, error) {
+	s.Record(testutil.Action{
+		Name:   "Undelete",
+		Params: []interface{}{nodePath, index},
+	})
+	return &v2store.Event{}, nil
+}
+
 // errStoreRecorder is a storeRecorder, but returns the given error on
 // Get, Watch methods.
 type errStoreRecorder struct {
@@ -45,3 +45,5 @@ func (w *waitRecorder) Trigger(id uint64, x interface{}) {
 func (w *waitRecorder) IsRegistered(id uint64) bool {
 	panic("waitRecorder.IsRegistered() shouldn't be called")
 }
+
+func (w *waitRecorder) Pending() []wait.Pending { return nil }
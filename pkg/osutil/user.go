@@ -0,0 +1,70 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package osutil
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+)
+
+// LookupUserGroup returns the numeric user and group IDs of the given
+// user and group, each given by name or ID. If grp is empty, the primary
+// group of usr is returned. If usr is empty, the current user is used.
+func LookupUserGroup(usr, grp string) (uid, gid int, err error) {
+	var u *user.User
+	switch {
+	case usr == "":
+		u, err = user.Current()
+	default:
+		if u, err = user.Lookup(usr); err != nil {
+			u, err = user.LookupId(usr)
+		}
+	}
+	if err != nil {
+		return 0, 0, fmt.Errorf("unknown user %q", usr)
+	}
+	if uid, err = strconv.Atoi(u.Uid); err != nil {
+		return 0, 0, fmt.Errorf("non-numeric uid %q of user %q", u.Uid, usr)
+	}
+
+	gidstr := u.Gid
+	if grp != "" {
+		g, gerr := user.LookupGroup(grp)
+		if gerr != nil {
+			if g, gerr = user.LookupGroupId(grp); gerr != nil {
+				return 0, 0, fmt.Errorf("unknown group %q", grp)
+			}
+		}
+		gidstr = g.Gid
+	}
+	if gid, err = strconv.Atoi(gidstr); err != nil {
+		return 0, 0, fmt.Errorf("non-numeric gid %q of group %q", gidstr, grp)
+	}
+	return uid, gid, nil
+}
+
+// ChownAll changes the owner of dir and everything under it, without
+// following symbolic links.
+func ChownAll(dir string, uid, gid int) error {
+	return filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		return os.Lchown(p, uid, gid)
+	})
+}
@@ -0,0 +1,27 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build plan9
+
+package osutil
+
+import (
+	"errors"
+	"os/exec"
+)
+
+// RunAsUserGroup is not supported on plan9.
+func RunAsUserGroup(cmd *exec.Cmd, uid, gid int) error {
+	return errors.New("osutil: setting user and group is not supported on plan9")
+}
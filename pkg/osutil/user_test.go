@@ -0,0 +1,55 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package osutil
+
+import (
+	"os/user"
+	"strconv"
+	"testing"
+)
+
+func TestLookupUserGroup(t *testing.T) {
+	u, err := user.Current()
+	if err != nil {
+		t.Skipf("cannot look up current user: %v", err)
+	}
+	wuid, _ := strconv.Atoi(u.Uid)
+	wgid, _ := strconv.Atoi(u.Gid)
+
+	tests := []struct {
+		usr, grp string
+	}{
+		{"", ""},
+		{u.Username, ""},
+		{u.Uid, ""},
+		{u.Username, u.Gid},
+	}
+	for i, tt := range tests {
+		uid, gid, err := LookupUserGroup(tt.usr, tt.grp)
+		if err != nil {
+			t.Fatalf("#%d: unexpected error: %v", i, err)
+		}
+		if uid != wuid || gid != wgid {
+			t.Errorf("#%d: uid, gid = %d, %d, want %d, %d", i, uid, gid, wuid, wgid)
+		}
+	}
+
+	if _, _, err = LookupUserGroup("etcd-no-such-user", ""); err == nil {
+		t.Errorf("expected error on unknown user")
+	}
+	if _, _, err = LookupUserGroup(u.Username, "etcd-no-such-group"); err == nil {
+		t.Errorf("expected error on unknown group")
+	}
+}
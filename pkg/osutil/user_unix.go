@@ -0,0 +1,60 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build !windows,!plan9
+
+package osutil
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+)
+
+// RunAsUserGroup runs cmd as the given user and group IDs, without
+// supplementary groups, and waits for it to exit. SIGINT, SIGTERM and
+// SIGHUP are forwarded to it meanwhile. Only a privileged process can run
+// a command as another user. The IDs are set in the child between fork
+// and exec, since a Go process cannot switch all of its own threads.
+func RunAsUserGroup(cmd *exec.Cmd, uid, gid int) error {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Credential = &syscall.Credential{
+		Uid:    uint32(uid),
+		Gid:    uint32(gid),
+		Groups: []uint32{},
+	}
+
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(sigc)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	donec := make(chan struct{})
+	defer close(donec)
+	go func() {
+		for {
+			select {
+			case sig := <-sigc:
+				cmd.Process.Signal(sig)
+			case <-donec:
+				return
+			}
+		}
+	}()
+	return cmd.Wait()
+}
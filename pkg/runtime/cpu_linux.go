@@ -0,0 +1,82 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var (
+	cgroupV2CPUMax    = "/sys/fs/cgroup/cpu.max"
+	cgroupV1CPUQuota  = "/sys/fs/cgroup/cpu/cpu.cfs_quota_us"
+	cgroupV1CPUPeriod = "/sys/fs/cgroup/cpu/cpu.cfs_period_us"
+)
+
+// CPUQuota returns the number of CPUs the CFS quota of the cgroup of the
+// process allows it to use, as mounted in its cgroup namespace, or 0 if
+// the CPU usage is not limited.
+func CPUQuota() (float64, error) {
+	b, err := ioutil.ReadFile(cgroupV2CPUMax)
+	if err == nil {
+		// "$MAX $PERIOD", where $MAX is "max" when unlimited
+		fields := strings.Fields(string(b))
+		if len(fields) != 2 {
+			return 0, fmt.Errorf("unexpected format of %s: %q", cgroupV2CPUMax, b)
+		}
+		if fields[0] == "max" {
+			return 0, nil
+		}
+		return parseCPUQuota(fields[0], fields[1])
+	}
+	if !os.IsNotExist(err) {
+		return 0, err
+	}
+
+	quota, err := ioutil.ReadFile(cgroupV1CPUQuota)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	period, err := ioutil.ReadFile(cgroupV1CPUPeriod)
+	if err != nil {
+		return 0, err
+	}
+	// the quota is -1 when unlimited
+	if strings.TrimSpace(string(quota)) == "-1" {
+		return 0, nil
+	}
+	return parseCPUQuota(strings.TrimSpace(string(quota)), strings.TrimSpace(string(period)))
+}
+
+func parseCPUQuota(quota, period string) (float64, error) {
+	q, err := strconv.ParseUint(quota, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	p, err := strconv.ParseUint(period, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if p == 0 {
+		return 0, fmt.Errorf("invalid CFS period %q", period)
+	}
+	return float64(q) / float64(p), nil
+}
@@ -0,0 +1,65 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCPUQuota(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "cpuquota")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	defer func(v2, v1q, v1p string) {
+		cgroupV2CPUMax, cgroupV1CPUQuota, cgroupV1CPUPeriod = v2, v1q, v1p
+	}(cgroupV2CPUMax, cgroupV1CPUQuota, cgroupV1CPUPeriod)
+	cgroupV2CPUMax = filepath.Join(dir, "cpu.max")
+	cgroupV1CPUQuota = filepath.Join(dir, "cpu.cfs_quota_us")
+	cgroupV1CPUPeriod = filepath.Join(dir, "cpu.cfs_period_us")
+
+	tests := []struct {
+		files map[string]string
+		w     float64
+	}{
+		{nil, 0},
+		{map[string]string{cgroupV2CPUMax: "max 100000\n"}, 0},
+		{map[string]string{cgroupV2CPUMax: "250000 100000\n"}, 2.5},
+		{map[string]string{cgroupV1CPUQuota: "-1\n", cgroupV1CPUPeriod: "100000\n"}, 0},
+		{map[string]string{cgroupV1CPUQuota: "50000\n", cgroupV1CPUPeriod: "100000\n"}, 0.5},
+	}
+	for i, tt := range tests {
+		for _, f := range []string{cgroupV2CPUMax, cgroupV1CPUQuota, cgroupV1CPUPeriod} {
+			os.Remove(f)
+		}
+		for f, data := range tt.files {
+			if err = ioutil.WriteFile(f, []byte(data), 0600); err != nil {
+				t.Fatal(err)
+			}
+		}
+		quota, err := CPUQuota()
+		if err != nil {
+			t.Fatalf("#%d: unexpected error %v", i, err)
+		}
+		if quota != tt.w {
+			t.Errorf("#%d: quota = %v, want %v", i, quota, tt.w)
+		}
+	}
+}
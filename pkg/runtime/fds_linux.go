@@ -17,9 +17,15 @@ package runtime
 
 import (
 	"io/ioutil"
+	"strconv"
+	"strings"
 	"syscall"
 )
 
+// nrOpen holds the maximum the limit of the number of open file
+// descriptors can be set to.
+var nrOpen = "/proc/sys/fs/nr_open"
+
 func FDLimit() (uint64, error) {
 	var rlimit syscall.Rlimit
 	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rlimit); err != nil {
@@ -35,3 +41,44 @@ func FDUsage() (uint64, error) {
 	}
 	return uint64(len(fds)), nil
 }
+
+// RaiseFDLimit raises the soft limit of the number of open file descriptors
+// to the hard limit, or to the maximum allowed by the kernel if lower, and
+// returns the new soft limit.
+func RaiseFDLimit() (uint64, error) {
+	var rlimit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rlimit); err != nil {
+		return 0, err
+	}
+	max, err := fdLimitMax(rlimit.Max)
+	if err != nil {
+		return 0, err
+	}
+	if rlimit.Cur >= max {
+		return rlimit.Cur, nil
+	}
+	rlimit.Cur = max
+	if err := syscall.Setrlimit(syscall.RLIMIT_NOFILE, &rlimit); err != nil {
+		return 0, err
+	}
+	return rlimit.Cur, nil
+}
+
+// fdLimitMax returns the highest soft limit of the number of open file
+// descriptors that can be set under the hard limit hard. The kernel
+// rejects the limits above nr_open, which the hard limit exceeds when it
+// is unlimited.
+func fdLimitMax(hard uint64) (uint64, error) {
+	b, err := ioutil.ReadFile(nrOpen)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.ParseUint(strings.TrimSpace(string(b)), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if n < hard {
+		return n, nil
+	}
+	return hard, nil
+}
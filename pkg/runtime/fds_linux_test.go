@@ -0,0 +1,56 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFDLimitMax(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "fdlimit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	defer func(p string) { nrOpen = p }(nrOpen)
+	nrOpen = filepath.Join(dir, "nr_open")
+	if err = ioutil.WriteFile(nrOpen, []byte("1048576\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		hard uint64
+		w    uint64
+	}{
+		{4096, 4096},
+		{1048576, 1048576},
+		{1 << 21, 1048576},
+		// an unlimited hard limit is capped at nr_open
+		{^uint64(0), 1048576},
+	}
+	for i, tt := range tests {
+		max, err := fdLimitMax(tt.hard)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if max != tt.w {
+			t.Errorf("#%d: max = %d, want %d", i, max, tt.w)
+		}
+	}
+}
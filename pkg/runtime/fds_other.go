@@ -28,3 +28,7 @@ func FDLimit() (uint64, error) {
 func FDUsage() (uint64, error) {
 	return 0, fmt.Errorf("cannot get FDUsage on %s", runtime.GOOS)
 }
+
+func RaiseFDLimit() (uint64, error) {
+	return 0, fmt.Errorf("cannot raise FDLimit on %s", runtime.GOOS)
+}
@@ -0,0 +1,286 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statsd
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coreos/pkg/capnslog"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"go.uber.org/zap"
+)
+
+var plog = capnslog.NewPackageLogger("go.etcd.io/etcd", "pkg/statsd")
+
+const (
+	// FormatStatsd sends the labels of a metric in its name, as
+	// "name.value1.value2".
+	FormatStatsd = "statsd"
+	// FormatDogStatsD sends the labels of a metric as DogStatsD tags.
+	FormatDogStatsD = "dogstatsd"
+
+	// maxPacketSize keeps the packets within the MTU of most networks.
+	maxPacketSize = 1432
+)
+
+// ValidateFormat returns an error if f is not a supported format.
+func ValidateFormat(f string) error {
+	switch f {
+	case FormatStatsd, FormatDogStatsD:
+		return nil
+	}
+	return fmt.Errorf("unknown statsd format %q (expected %q or %q)", f, FormatStatsd, FormatDogStatsD)
+}
+
+// Exporter pushes the metrics of a Gatherer to a statsd server over UDP.
+// Gauges are sent as gauges, and counters as the increment since the
+// previous push. Histograms and summaries are sent as the counters of
+// their count, sum and buckets, and the gauges of their quantiles.
+type Exporter struct {
+	lg       *zap.Logger
+	g        prometheus.Gatherer
+	format   string
+	interval time.Duration
+	conn     net.Conn
+
+	// counters are the values of the counters at the previous push, by
+	// series.
+	counters map[string]float64
+	failing  bool
+
+	stopc chan struct{}
+	donec chan struct{}
+}
+
+// NewExporter returns an Exporter pushing the metrics of g to the statsd
+// server at addr every interval, once started.
+func NewExporter(lg *zap.Logger, addr, format string, interval time.Duration, g prometheus.Gatherer) (*Exporter, error) {
+	if err := ValidateFormat(format); err != nil {
+		return nil, err
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("invalid statsd push interval %v", interval)
+	}
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &Exporter{
+		lg:       lg,
+		g:        g,
+		format:   format,
+		interval: interval,
+		conn:     conn,
+		counters: make(map[string]float64),
+		stopc:    make(chan struct{}),
+		donec:    make(chan struct{}),
+	}, nil
+}
+
+// Start pushes the metrics every interval until Stop is called.
+func (e *Exporter) Start() {
+	go e.run()
+}
+
+// Stop stops pushing the metrics, and closes the connection.
+func (e *Exporter) Stop() {
+	close(e.stopc)
+	<-e.donec
+	e.conn.Close()
+}
+
+func (e *Exporter) run() {
+	defer close(e.donec)
+	t := time.NewTicker(e.interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			e.report(e.push())
+		case <-e.stopc:
+			return
+		}
+	}
+}
+
+// report logs when the pushes start failing, and when they recover, so
+// that an unreachable server does not log at every push.
+func (e *Exporter) report(err error) {
+	switch {
+	case err != nil && !e.failing:
+		if e.lg != nil {
+			e.lg.Warn("failed to push metrics to statsd", zap.String("address", e.conn.RemoteAddr().String()), zap.Error(err))
+		} else {
+			plog.Warningf("failed to push metrics to statsd at %s (%v)", e.conn.RemoteAddr(), err)
+		}
+	case err == nil && e.failing:
+		if e.lg != nil {
+			e.lg.Info("pushed metrics to statsd", zap.String("address", e.conn.RemoteAddr().String()))
+		} else {
+			plog.Infof("pushed metrics to statsd at %s", e.conn.RemoteAddr())
+		}
+	}
+	e.failing = err != nil
+}
+
+// push gathers the metrics and sends them, packing as many lines as fit
+// in each packet.
+func (e *Exporter) push() error {
+	mfs, err := e.g.Gather()
+	if err != nil && len(mfs) == 0 {
+		return err
+	}
+	var buf bytes.Buffer
+	for _, l := range e.lines(mfs) {
+		if buf.Len() > 0 && buf.Len()+1+len(l) > maxPacketSize {
+			if _, err = e.conn.Write(buf.Bytes()); err != nil {
+				return err
+			}
+			buf.Reset()
+		}
+		if buf.Len() > 0 {
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(l)
+	}
+	if buf.Len() > 0 {
+		_, err = e.conn.Write(buf.Bytes())
+	}
+	return err
+}
+
+// lines returns the statsd lines of the metric families, and records the
+// values of their counters.
+func (e *Exporter) lines(mfs []*dto.MetricFamily) []string {
+	var ls []string
+	gauge := func(name string, labels []*dto.LabelPair, extra *dto.LabelPair, v float64) {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return
+		}
+		series := e.series(name, labels, extra)
+		if v < 0 && e.format == FormatStatsd {
+			// a signed value changes a statsd gauge instead of setting it
+			ls = append(ls, e.line(series, 0, "g"))
+		}
+		ls = append(ls, e.line(series, v, "g"))
+	}
+	counter := func(name string, labels []*dto.LabelPair, extra *dto.LabelPair, v float64) {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return
+		}
+		series := e.series(name, labels, extra)
+		d := v - e.counters[series]
+		if d < 0 {
+			// the counter was reset
+			d = v
+		}
+		e.counters[series] = v
+		if d != 0 {
+			ls = append(ls, e.line(series, d, "c"))
+		}
+	}
+
+	for _, mf := range mfs {
+		name := mf.GetName()
+		for _, m := range mf.Metric {
+			labels := m.GetLabel()
+			switch mf.GetType() {
+			case dto.MetricType_COUNTER:
+				counter(name, labels, nil, m.GetCounter().GetValue())
+			case dto.MetricType_GAUGE:
+				gauge(name, labels, nil, m.GetGauge().GetValue())
+			case dto.MetricType_UNTYPED:
+				gauge(name, labels, nil, m.GetUntyped().GetValue())
+			case dto.MetricType_HISTOGRAM:
+				h := m.GetHistogram()
+				counter(name+"_count", labels, nil, float64(h.GetSampleCount()))
+				counter(name+"_sum", labels, nil, h.GetSampleSum())
+				for _, b := range h.Bucket {
+					le := strconv.FormatFloat(b.GetUpperBound(), 'g', -1, 64)
+					counter(name+"_bucket", labels, labelPair("le", le), float64(b.GetCumulativeCount()))
+				}
+			case dto.MetricType_SUMMARY:
+				s := m.GetSummary()
+				counter(name+"_count", labels, nil, float64(s.GetSampleCount()))
+				counter(name+"_sum", labels, nil, s.GetSampleSum())
+				for _, q := range s.Quantile {
+					qs := strconv.FormatFloat(q.GetQuantile(), 'g', -1, 64)
+					gauge(name, labels, labelPair("quantile", qs), q.GetValue())
+				}
+			}
+		}
+	}
+	return ls
+}
+
+func labelPair(name, value string) *dto.LabelPair {
+	return &dto.LabelPair{Name: &name, Value: &value}
+}
+
+// series returns the metric name and labels of a line, formatted as the
+// line starts.
+func (e *Exporter) series(name string, labels []*dto.LabelPair, extra *dto.LabelPair) string {
+	if extra != nil {
+		labels = append(labels[:len(labels):len(labels)], extra)
+	}
+	var sb strings.Builder
+	sb.WriteString(name)
+	if e.format == FormatStatsd {
+		for _, l := range labels {
+			sb.WriteByte('.')
+			sb.WriteString(sanitize(l.GetValue(), "_-"))
+		}
+		return sb.String()
+	}
+	for i, l := range labels {
+		if i == 0 {
+			sb.WriteString("|#")
+		} else {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(l.GetName())
+		sb.WriteByte(':')
+		sb.WriteString(sanitize(l.GetValue(), "_-.:/"))
+	}
+	return sb.String()
+}
+
+// line formats the value of a series. The DogStatsD tags follow the value
+// and type.
+func (e *Exporter) line(series string, v float64, typ string) string {
+	name, tags := series, ""
+	if i := strings.Index(series, "|#"); i >= 0 {
+		name, tags = series[:i], series[i:]
+	}
+	return name + ":" + strconv.FormatFloat(v, 'f', -1, 64) + "|" + typ + tags
+}
+
+// sanitize replaces the characters of s that are not alphanumeric or in
+// allowed by underscores.
+func sanitize(s, allowed string) string {
+	return strings.Map(func(r rune) rune {
+		if ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') || ('0' <= r && r <= '9') || strings.ContainsRune(allowed, r) {
+			return r
+		}
+		return '_'
+	}, s)
+}
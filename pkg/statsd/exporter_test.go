@@ -0,0 +1,148 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statsd
+
+import (
+	"net"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+func newTestRegistry() (*prometheus.Registry, *prometheus.CounterVec, prometheus.Gauge, prometheus.Histogram) {
+	reg := prometheus.NewRegistry()
+	c := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "requests_total", Help: "Requests."}, []string{"method"})
+	g := prometheus.NewGauge(prometheus.GaugeOpts{Name: "lag", Help: "Lag."})
+	h := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "latency_seconds", Help: "Latency.", Buckets: []float64{0.1, 1}})
+	reg.MustRegister(c, g, h)
+	return reg, c, g, h
+}
+
+func TestExporterLines(t *testing.T) {
+	tests := []struct {
+		format string
+		w1, w2 []string
+	}{
+		{
+			FormatStatsd,
+			[]string{
+				"lag:-2|g",
+				"lag:0|g",
+				"latency_seconds_bucket.1:1|c",
+				"latency_seconds_count:1|c",
+				"latency_seconds_sum:0.5|c",
+				"requests_total.GET:3|c",
+				"requests_total.get_range_:1|c",
+			},
+			[]string{
+				"lag:5|g",
+				"latency_seconds_bucket.0_1:1|c",
+				"latency_seconds_bucket.1:1|c",
+				"latency_seconds_count:1|c",
+				"latency_seconds_sum:0.0625|c",
+				"requests_total.GET:2|c",
+			},
+		},
+		{
+			FormatDogStatsD,
+			[]string{
+				"lag:-2|g",
+				"latency_seconds_bucket:1|c|#le:1",
+				"latency_seconds_count:1|c",
+				"latency_seconds_sum:0.5|c",
+				"requests_total:1|c|#method:get_range_",
+				"requests_total:3|c|#method:GET",
+			},
+			[]string{
+				"lag:5|g",
+				"latency_seconds_bucket:1|c|#le:0.1",
+				"latency_seconds_bucket:1|c|#le:1",
+				"latency_seconds_count:1|c",
+				"latency_seconds_sum:0.0625|c",
+				"requests_total:2|c|#method:GET",
+			},
+		},
+	}
+	for i, tt := range tests {
+		reg, c, g, h := newTestRegistry()
+		e := &Exporter{g: reg, format: tt.format, counters: make(map[string]float64)}
+		lines := func() []string {
+			mfs, err := reg.Gather()
+			if err != nil {
+				t.Fatal(err)
+			}
+			ls := e.lines(mfs)
+			sort.Strings(ls)
+			return ls
+		}
+
+		c.WithLabelValues("GET").Add(3)
+		c.WithLabelValues("get|range#").Inc()
+		g.Set(-2)
+		h.Observe(0.5)
+		if ls := lines(); !reflect.DeepEqual(ls, tt.w1) {
+			t.Errorf("#%d: lines = %q, want %q", i, ls, tt.w1)
+		}
+
+		// the counters are sent as the increment since the previous push
+		c.WithLabelValues("GET").Add(2)
+		g.Set(5)
+		h.Observe(0.0625)
+		if ls := lines(); !reflect.DeepEqual(ls, tt.w2) {
+			t.Errorf("#%d: lines = %q, want %q", i, ls, tt.w2)
+		}
+	}
+}
+
+func TestExporterPush(t *testing.T) {
+	ln, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	reg, c, _, _ := newTestRegistry()
+	c.WithLabelValues("GET").Inc()
+	e, err := NewExporter(zap.NewExample(), ln.LocalAddr().String(), FormatDogStatsD, 10*time.Millisecond, reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.Start()
+	defer e.Stop()
+
+	ln.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, maxPacketSize)
+	n, _, err := ln.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := string(buf[:n]); !strings.Contains(p, "requests_total:1|c|#method:GET") {
+		t.Errorf("packet = %q, want the requests_total counter", p)
+	}
+}
+
+func TestNewExporterValidate(t *testing.T) {
+	if _, err := NewExporter(nil, "127.0.0.1:8125", "graphite", time.Second, prometheus.NewRegistry()); err == nil {
+		t.Error("expected error on unknown format")
+	}
+	if _, err := NewExporter(nil, "127.0.0.1:8125", FormatStatsd, 0, prometheus.NewRegistry()); err == nil {
+		t.Error("expected error on zero interval")
+	}
+}
@@ -0,0 +1,31 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tlsutil
+
+import "crypto/tls"
+
+// TLS versions accepted as the minimum version; older versions are
+// not allowed. "TLS1.3" is added by versions_go112.go when built with
+// Go 1.12 or later.
+var versions = map[string]uint16{
+	"TLS1.2": tls.VersionTLS12,
+}
+
+// GetTLSVersion returns the TLS version of the given name, "TLS1.2" or
+// "TLS1.3", and boolean value if it is supported by this build.
+func GetTLSVersion(s string) (uint16, bool) {
+	v, ok := versions[s]
+	return v, ok
+}
@@ -0,0 +1,38 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tlsutil
+
+import (
+	"crypto/tls"
+	"testing"
+)
+
+func TestGetTLSVersion(t *testing.T) {
+	tests := []struct {
+		name string
+		w    uint16
+		wok  bool
+	}{
+		{"TLS1.2", tls.VersionTLS12, true},
+		{"TLS1.1", 0, false},
+		{"", 0, false},
+	}
+	for i, tt := range tests {
+		v, ok := GetTLSVersion(tt.name)
+		if v != tt.w || ok != tt.wok {
+			t.Errorf("#%d: GetTLSVersion(%q) = %#04x, %v, want %#04x, %v", i, tt.name, v, ok, tt.w, tt.wok)
+		}
+	}
+}
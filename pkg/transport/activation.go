@@ -0,0 +1,169 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transport
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+)
+
+// listenFdsStart is the first file descriptor passed by systemd socket
+// activation; see sd_listen_fds(3).
+const listenFdsStart = 3
+
+// inheritedListenFdsEnv holds the number of listeners passed by
+// PassListeners. Unlike LISTEN_FDS, it is not tied to a process ID,
+// which the parent cannot know before starting the child.
+const inheritedListenFdsEnv = "INHERITED_LISTEN_FDS"
+
+var (
+	activatedOnce sync.Once
+	activatedMu   sync.Mutex
+	// activated holds the socket activated listeners not yet taken.
+	activated []net.Listener
+)
+
+// Listen announces on the local network address like net.Listen. If
+// the process was started by systemd socket activation and one of the
+// passed sockets is bound to the same address, that listener is used
+// instead, so that the socket stays open across restarts and does not
+// need to be bound by this process.
+func Listen(network, addr string) (net.Listener, error) {
+	if l := takeActivatedListener(network, addr); l != nil {
+		return l, nil
+	}
+	return net.Listen(network, addr)
+}
+
+// takeActivatedListener removes and returns the socket activated
+// listener bound to addr, or nil if there is none.
+func takeActivatedListener(network, addr string) net.Listener {
+	activatedOnce.Do(loadActivatedListeners)
+
+	activatedMu.Lock()
+	defer activatedMu.Unlock()
+	for i, l := range activated {
+		if sameAddr(l.Addr(), network, addr) {
+			activated = append(activated[:i], activated[i+1:]...)
+			return l
+		}
+	}
+	return nil
+}
+
+// PassListeners makes cmd pass the listeners to the process it starts,
+// where Listen takes them like socket activated listeners. It must be
+// called before any other ExtraFiles are added to cmd.
+func PassListeners(cmd *exec.Cmd, ls []net.Listener) error {
+	if len(cmd.ExtraFiles) != 0 {
+		return fmt.Errorf("transport: cannot pass listeners after other files")
+	}
+	for _, l := range ls {
+		fl, ok := l.(interface {
+			File() (*os.File, error)
+		})
+		if !ok {
+			return fmt.Errorf("transport: cannot pass listener on %s", l.Addr())
+		}
+		f, err := fl.File()
+		if err != nil {
+			return err
+		}
+		cmd.ExtraFiles = append(cmd.ExtraFiles, f)
+	}
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	cmd.Env = append(cmd.Env, inheritedListenFdsEnv+"="+strconv.Itoa(len(ls)))
+	return nil
+}
+
+// loadActivatedListeners creates listeners from the file descriptors
+// passed by systemd or PassListeners, and unsets the activation
+// environment variables so that child processes do not inherit them.
+// Passed sockets that are not listening stream sockets are ignored.
+func loadActivatedListeners() {
+	defer func() {
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+		os.Unsetenv(inheritedListenFdsEnv)
+	}()
+
+	nfds, err := strconv.Atoi(os.Getenv(inheritedListenFdsEnv))
+	if err != nil {
+		pid, perr := strconv.Atoi(os.Getenv("LISTEN_PID"))
+		if perr != nil || pid != os.Getpid() {
+			return
+		}
+		nfds, err = strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	}
+	if err != nil || nfds <= 0 {
+		return
+	}
+
+	activatedMu.Lock()
+	defer activatedMu.Unlock()
+	for fd := listenFdsStart; fd < listenFdsStart+nfds; fd++ {
+		f := os.NewFile(uintptr(fd), "LISTEN_FD_"+strconv.Itoa(fd))
+		// FileListener duplicates the descriptor with close-on-exec set
+		l, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			continue
+		}
+		activated = append(activated, l)
+	}
+}
+
+// sameAddr reports whether la is the address addr on network, as given
+// to net.Listen.
+func sameAddr(la net.Addr, network, addr string) bool {
+	switch a := la.(type) {
+	case *net.UnixAddr:
+		return network == "unix" && a.Name == addr
+	case *net.TCPAddr:
+		if network != "tcp" {
+			return false
+		}
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return false
+		}
+		if p, err := net.LookupPort("tcp", port); err != nil || p != a.Port {
+			return false
+		}
+		if host == "" {
+			return a.IP.IsUnspecified()
+		}
+		ips := []net.IP{net.ParseIP(host)}
+		if ips[0] == nil {
+			if ips, err = net.LookupIP(host); err != nil {
+				return false
+			}
+		}
+		for _, ip := range ips {
+			// an IPv4 wildcard socket may be reported as IPv6 wildcard
+			if ip.Equal(a.IP) || (ip.IsUnspecified() && a.IP.IsUnspecified()) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transport
+
+import (
+	"net"
+	"os"
+	"os/exec"
+	"testing"
+)
+
+func TestSameAddr(t *testing.T) {
+	tests := []struct {
+		la      net.Addr
+		network string
+		addr    string
+
+		w bool
+	}{
+		{&net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 2379}, "tcp", "127.0.0.1:2379", true},
+		{&net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 2379}, "tcp", "localhost:2379", true},
+		{&net.TCPAddr{IP: net.IPv6unspecified, Port: 2379}, "tcp", "0.0.0.0:2379", true},
+		{&net.TCPAddr{IP: net.IPv6unspecified, Port: 2379}, "tcp", ":2379", true},
+		{&net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 2379}, "tcp", "127.0.0.1:2380", false},
+		{&net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 2379}, "tcp", "10.0.0.1:2379", false},
+		{&net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 2379}, "unix", "127.0.0.1:2379", false},
+		{&net.UnixAddr{Name: "/tmp/etcd.sock", Net: "unix"}, "unix", "/tmp/etcd.sock", true},
+		{&net.UnixAddr{Name: "/tmp/etcd.sock", Net: "unix"}, "unix", "/tmp/other.sock", false},
+	}
+	for i, tt := range tests {
+		if g := sameAddr(tt.la, tt.network, tt.addr); g != tt.w {
+			t.Errorf("#%d: sameAddr(%v, %q, %q) = %v, want %v", i, tt.la, tt.network, tt.addr, g, tt.w)
+		}
+	}
+}
+
+func TestNewListenerActivated(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	activatedOnce.Do(func() {})
+	activatedMu.Lock()
+	activated = append(activated, l)
+	activatedMu.Unlock()
+
+	// the address is in use, so binding it again would fail
+	al, err := NewListener(l.Addr().String(), "http", nil)
+	if err != nil {
+		t.Fatalf("unexpected NewListener error: %v", err)
+	}
+	if al != l {
+		t.Errorf("listener = %v, want activated listener %v", al, l)
+	}
+	if l := takeActivatedListener("tcp", l.Addr().String()); l != nil {
+		t.Errorf("activated listener was taken twice")
+	}
+}
+
+func TestPassListeners(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	cmd := exec.Command(os.Args[0], "-test.run=TestPassListenersHelper")
+	cmd.Env = append(os.Environ(), "ETCD_TEST_PASSED_ADDR="+l.Addr().String())
+	if err = PassListeners(cmd, []net.Listener{l}); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("helper failed: %v\n%s", err, out)
+	}
+
+	if err = PassListeners(cmd, []net.Listener{l}); err == nil {
+		t.Errorf("expected error passing listeners after other files")
+	}
+}
+
+// TestPassListenersHelper runs in the process started by
+// TestPassListeners, and takes the listener passed to it.
+func TestPassListenersHelper(t *testing.T) {
+	addr := os.Getenv("ETCD_TEST_PASSED_ADDR")
+	if addr == "" {
+		t.Skip("not started by TestPassListeners")
+	}
+	// the address is in use, so binding it again would fail
+	l, err := Listen("tcp", addr)
+	if err != nil {
+		t.Fatalf("listener was not passed: %v", err)
+	}
+	l.Close()
+}
@@ -0,0 +1,118 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transport
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// AllowedNetworks restricts the hosts connections are accepted from and
+// dialed to. An empty list allows every host.
+type AllowedNetworks []*net.IPNet
+
+// ParseAllowedNetworks parses the CIDRs of the allowed networks. An IP
+// stands for the network of that IP alone.
+func ParseAllowedNetworks(ss []string) (AllowedNetworks, error) {
+	var ns AllowedNetworks
+	for _, s := range ss {
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid allowed host %q", s)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			ns = append(ns, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid allowed network %q (%v)", s, err)
+		}
+		ns = append(ns, n)
+	}
+	return ns, nil
+}
+
+// Allows returns true if addr is in one of the networks. The addresses
+// other than TCP ones, e.g. of unix sockets, are always allowed.
+func (ns AllowedNetworks) Allows(addr net.Addr) bool {
+	ta, ok := addr.(*net.TCPAddr)
+	if len(ns) == 0 || !ok {
+		return true
+	}
+	for _, n := range ns {
+		if n.Contains(ta.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check closes conn, dialed with the given error, and returns an error if
+// its remote host is not allowed; it returns conn and err otherwise. It is
+// meant to wrap a dial, e.g. ns.Check(net.Dial(network, addr)), so the
+// resolved address is checked.
+func (ns AllowedNetworks) Check(conn net.Conn, err error) (net.Conn, error) {
+	if err != nil || ns.Allows(conn.RemoteAddr()) {
+		return conn, err
+	}
+	conn.Close()
+	return nil, fmt.Errorf("host %s is not in the allowed networks", conn.RemoteAddr())
+}
+
+// RestrictTransport makes tr only dial the allowed hosts.
+func (ns AllowedNetworks) RestrictTransport(tr *http.Transport) {
+	if len(ns) == 0 {
+		return
+	}
+	dial := tr.Dial
+	if dial == nil {
+		dial = net.Dial
+	}
+	tr.Dial = func(network, addr string) (net.Conn, error) {
+		return ns.Check(dial(network, addr))
+	}
+}
+
+// NewListener returns a listener closing the connections accepted by l
+// from the hosts that are not allowed. It returns l if every host is
+// allowed.
+func (ns AllowedNetworks) NewListener(l net.Listener) net.Listener {
+	if len(ns) == 0 {
+		return l
+	}
+	return &allowedNetworksListener{Listener: l, ns: ns}
+}
+
+type allowedNetworksListener struct {
+	net.Listener
+	ns AllowedNetworks
+}
+
+func (l *allowedNetworksListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil || l.ns.Allows(conn.RemoteAddr()) {
+			return conn, err
+		}
+		conn.Close()
+	}
+}
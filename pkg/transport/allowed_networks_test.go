@@ -0,0 +1,103 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transport
+
+import (
+	"net"
+	"net/http"
+	"testing"
+)
+
+func TestParseAllowedNetworks(t *testing.T) {
+	ns, err := ParseAllowedNetworks([]string{"10.0.0.0/24", "192.168.1.5", "::1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		addr   net.Addr
+		wallow bool
+	}{
+		{&net.TCPAddr{IP: net.ParseIP("10.0.0.7")}, true},
+		{&net.TCPAddr{IP: net.ParseIP("10.0.1.7")}, false},
+		{&net.TCPAddr{IP: net.ParseIP("192.168.1.5")}, true},
+		{&net.TCPAddr{IP: net.ParseIP("192.168.1.6")}, false},
+		{&net.TCPAddr{IP: net.ParseIP("::1")}, true},
+		{&net.UnixAddr{Name: "/tmp/etcd.sock", Net: "unix"}, true},
+	}
+	for i, tt := range tests {
+		if allow := ns.Allows(tt.addr); allow != tt.wallow {
+			t.Errorf("#%d: Allows(%v) = %v, want %v", i, tt.addr, allow, tt.wallow)
+		}
+	}
+	if !AllowedNetworks(nil).Allows(&net.TCPAddr{IP: net.ParseIP("10.0.1.7")}) {
+		t.Errorf("empty networks do not allow every host")
+	}
+
+	for _, s := range []string{"10.0.0.0/33", "localhost", ""} {
+		if _, err := ParseAllowedNetworks([]string{s}); err == nil {
+			t.Errorf("ParseAllowedNetworks(%q) error = nil, want error", s)
+		}
+	}
+}
+
+func TestAllowedNetworksListener(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	denied, _ := ParseAllowedNetworks([]string{"10.0.0.0/8"})
+	l := denied.NewListener(ln)
+	donec := make(chan struct{})
+	go func() {
+		defer close(donec)
+		if conn, err := l.Accept(); err == nil {
+			t.Errorf("accepted connection from %v", conn.RemoteAddr())
+			conn.Close()
+		}
+	}()
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the connection is closed by the listener
+	if _, err = conn.Read(make([]byte, 1)); err == nil {
+		t.Errorf("read on the denied connection succeeded")
+	}
+	conn.Close()
+	ln.Close()
+	<-donec
+
+	// dialing a denied host fails
+	ln2, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln2.Close()
+	tr := &http.Transport{}
+	denied.RestrictTransport(tr)
+	if _, err = tr.Dial("tcp", ln2.Addr().String()); err == nil {
+		t.Errorf("dialed a denied host")
+	}
+	allowed, _ := ParseAllowedNetworks([]string{"127.0.0.0/8"})
+	tr = &http.Transport{}
+	allowed.RestrictTransport(tr)
+	conn, err = tr.Dial("tcp", ln2.Addr().String())
+	if err != nil {
+		t.Fatalf("dial of an allowed host error = %v", err)
+	}
+	conn.Close()
+}
@@ -49,7 +49,7 @@ func newListener(addr string, scheme string) (net.Listener, error) {
 		// unix sockets via unix://laddr
 		return NewUnixListener(addr)
 	}
-	return net.Listen("tcp", addr)
+	return Listen("tcp", addr)
 }
 
 func wrapTLS(scheme string, tlsinfo *TLSInfo, l net.Listener) (net.Listener, error) {
@@ -79,6 +79,19 @@ type TLSInfo struct {
 	// Note that cipher suites are prioritized in the given order.
 	CipherSuites []uint16
 
+	// MinVersion is the minimum TLS version accepted, e.g. tls.VersionTLS12.
+	// If 0, TLS 1.2 is the minimum.
+	MinVersion uint16
+
+	// SessionTicketsDisabled disables the resumption of TLS sessions with
+	// session tickets, on both the server and the client side.
+	SessionTicketsDisabled bool
+
+	// ClientSessionCacheSize is the number of TLS sessions the clients
+	// keep to resume them when reconnecting, with an abbreviated handshake.
+	// If 0, the sessions are not cached.
+	ClientSessionCacheSize int
+
 	selfCert bool
 
 	// parseFunc exists to simplify testing. Typically, parseFunc
@@ -248,8 +261,15 @@ func (info TLSInfo) baseConfig() (*tls.Config, error) {
 	}
 
 	cfg := &tls.Config{
-		MinVersion: tls.VersionTLS12,
-		ServerName: info.ServerName,
+		MinVersion:             tls.VersionTLS12,
+		ServerName:             info.ServerName,
+		SessionTicketsDisabled: info.SessionTicketsDisabled,
+	}
+	if info.MinVersion != 0 {
+		if info.MinVersion < tls.VersionTLS12 {
+			return nil, fmt.Errorf("TLS version %#04x is older than TLS 1.2", info.MinVersion)
+		}
+		cfg.MinVersion = info.MinVersion
 	}
 
 	if len(info.CipherSuites) > 0 {
@@ -367,9 +387,16 @@ func (info TLSInfo) ClientConfig() (*tls.Config, error) {
 			return nil, err
 		}
 	} else {
-		cfg = &tls.Config{ServerName: info.ServerName}
+		cfg = &tls.Config{
+			MinVersion:             info.MinVersion,
+			ServerName:             info.ServerName,
+			SessionTicketsDisabled: info.SessionTicketsDisabled,
+		}
 	}
 	cfg.InsecureSkipVerify = info.InsecureSkipVerify
+	if info.ClientSessionCacheSize > 0 && !info.SessionTicketsDisabled {
+		cfg.ClientSessionCache = tls.NewLRUClientSessionCache(info.ClientSessionCacheSize)
+	}
 
 	cs := info.cafiles()
 	if len(cs) > 0 {
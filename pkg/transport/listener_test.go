@@ -246,6 +246,57 @@ func TestTLSInfoConfigFuncs(t *testing.T) {
 	}
 }
 
+func TestTLSInfoTuning(t *testing.T) {
+	tlsinfo, del, err := createSelfCert()
+	if err != nil {
+		t.Fatalf("unable to create cert: %v", err)
+	}
+	defer del()
+
+	tests := []struct {
+		minVersion    uint16
+		ticketsOff    bool
+		cacheSize     int
+		wMinVersion   uint16
+		wSessionCache bool
+		wErr          bool
+	}{
+		{0, false, 0, tls.VersionTLS12, false, false},
+		{tls.VersionTLS12, false, 16, tls.VersionTLS12, true, false},
+		{0, true, 16, tls.VersionTLS12, false, false},
+		{tls.VersionTLS11, false, 0, 0, false, true},
+	}
+	for i, tt := range tests {
+		info := TLSInfo{
+			CertFile:               tlsinfo.CertFile,
+			KeyFile:                tlsinfo.KeyFile,
+			MinVersion:             tt.minVersion,
+			SessionTicketsDisabled: tt.ticketsOff,
+			ClientSessionCacheSize: tt.cacheSize,
+		}
+		sCfg, err := info.ServerConfig()
+		if (err != nil) != tt.wErr {
+			t.Fatalf("#%d: ServerConfig() error = %v, want error %v", i, err, tt.wErr)
+		}
+		if tt.wErr {
+			continue
+		}
+		if sCfg.MinVersion != tt.wMinVersion || sCfg.SessionTicketsDisabled != tt.ticketsOff {
+			t.Errorf("#%d: server MinVersion = %#04x, SessionTicketsDisabled = %v, want %#04x, %v", i, sCfg.MinVersion, sCfg.SessionTicketsDisabled, tt.wMinVersion, tt.ticketsOff)
+		}
+		cCfg, err := info.ClientConfig()
+		if err != nil {
+			t.Fatalf("#%d: ClientConfig() error = %v", i, err)
+		}
+		if cCfg.MinVersion != tt.wMinVersion {
+			t.Errorf("#%d: client MinVersion = %#04x, want %#04x", i, cCfg.MinVersion, tt.wMinVersion)
+		}
+		if (cCfg.ClientSessionCache != nil) != tt.wSessionCache {
+			t.Errorf("#%d: client session cache = %v, want %v", i, cCfg.ClientSessionCache != nil, tt.wSessionCache)
+		}
+	}
+}
+
 func TestNewListenerUnixSocket(t *testing.T) {
 	l, err := NewListener("testsocket", "unix", nil)
 	if err != nil {
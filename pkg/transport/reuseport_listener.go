@@ -0,0 +1,57 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transport
+
+import (
+	"context"
+	"net"
+)
+
+// ListenReusePort announces on the local TCP address addr with n sockets
+// bound with SO_REUSEPORT, and returns a listener for each of them. The
+// kernel spreads the incoming connections over the sockets, so that each
+// can be served by its own goroutine and accepting many connections is
+// not bound to a single one. With n < 2, or if the address is a socket
+// activated one, it returns the single listener of Listen.
+func ListenReusePort(network, addr string, n int) ([]net.Listener, error) {
+	if n < 2 || network != "tcp" {
+		l, err := Listen(network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return []net.Listener{l}, nil
+	}
+	if l := takeActivatedListener(network, addr); l != nil {
+		return []net.Listener{l}, nil
+	}
+
+	lc := net.ListenConfig{Control: setReusePort}
+	ls := make([]net.Listener, 0, n)
+	for i := 0; i < n; i++ {
+		l, err := lc.Listen(context.Background(), network, addr)
+		if err != nil {
+			for _, l := range ls {
+				l.Close()
+			}
+			return nil, err
+		}
+		if i == 0 {
+			// bind the other sockets to the port picked for the first one
+			addr = l.Addr().String()
+		}
+		ls = append(ls, l)
+	}
+	return ls, nil
+}
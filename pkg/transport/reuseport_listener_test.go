@@ -0,0 +1,68 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build linux
+
+package transport
+
+import (
+	"net"
+	"testing"
+)
+
+func TestListenReusePort(t *testing.T) {
+	ls, err := ListenReusePort("tcp", "127.0.0.1:0", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ls) != 4 {
+		t.Fatalf("listeners = %d, want 4", len(ls))
+	}
+	addr := ls[0].Addr().String()
+	for _, l := range ls {
+		if l.Addr().String() != addr {
+			t.Fatalf("socket bound to %s, want %s", l.Addr(), addr)
+		}
+	}
+
+	// every connection is accepted by one of the sockets
+	acceptc := make(chan net.Conn)
+	for _, l := range ls {
+		go func(l net.Listener) {
+			for {
+				c, err := l.Accept()
+				if err != nil {
+					return
+				}
+				acceptc <- c
+			}
+		}(l)
+	}
+	for i := 0; i < 8; i++ {
+		c, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ac := <-acceptc
+		ac.Close()
+		c.Close()
+	}
+
+	for _, l := range ls {
+		l.Close()
+	}
+	if _, err = net.Dial("tcp", addr); err == nil {
+		t.Errorf("expected the sockets to be closed")
+	}
+}
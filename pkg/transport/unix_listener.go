@@ -22,6 +22,10 @@ import (
 type unixListener struct{ net.Listener }
 
 func NewUnixListener(addr string) (net.Listener, error) {
+	// the socket file of an activated listener belongs to the supervisor
+	if l := takeActivatedListener("unix", addr); l != nil {
+		return l, nil
+	}
 	if err := os.Remove(addr); err != nil && !os.IsNotExist(err) {
 		return nil, err
 	}
@@ -18,7 +18,9 @@ package wait
 
 import (
 	"log"
+	"sort"
 	"sync"
+	"time"
 )
 
 // Wait is an interface that provides the ability to wait and trigger events that
@@ -31,39 +33,50 @@ type Wait interface {
 	// Trigger triggers the waiting chans with the given ID.
 	Trigger(id uint64, x interface{})
 	IsRegistered(id uint64) bool
+	// Pending returns the registered waits, oldest first.
+	Pending() []Pending
+}
+
+// Pending is a registered wait not triggered yet.
+type Pending struct {
+	ID         uint64
+	Registered time.Time
+}
+
+type entry struct {
+	ch         chan interface{}
+	registered time.Time
 }
 
 type list struct {
 	l sync.RWMutex
-	m map[uint64]chan interface{}
+	m map[uint64]*entry
 }
 
 // New creates a Wait.
 func New() Wait {
-	return &list{m: make(map[uint64]chan interface{})}
+	return &list{m: make(map[uint64]*entry)}
 }
 
 func (w *list) Register(id uint64) <-chan interface{} {
 	w.l.Lock()
 	defer w.l.Unlock()
-	ch := w.m[id]
-	if ch == nil {
-		ch = make(chan interface{}, 1)
-		w.m[id] = ch
-	} else {
+	if w.m[id] != nil {
 		log.Panicf("dup id %x", id)
 	}
-	return ch
+	e := &entry{ch: make(chan interface{}, 1), registered: time.Now()}
+	w.m[id] = e
+	return e.ch
 }
 
 func (w *list) Trigger(id uint64, x interface{}) {
 	w.l.Lock()
-	ch := w.m[id]
+	e := w.m[id]
 	delete(w.m, id)
 	w.l.Unlock()
-	if ch != nil {
-		ch <- x
-		close(ch)
+	if e != nil {
+		e.ch <- x
+		close(e.ch)
 	}
 }
 
@@ -74,6 +87,22 @@ func (w *list) IsRegistered(id uint64) bool {
 	return ok
 }
 
+func (w *list) Pending() []Pending {
+	w.l.RLock()
+	ps := make([]Pending, 0, len(w.m))
+	for id, e := range w.m {
+		ps = append(ps, Pending{ID: id, Registered: e.registered})
+	}
+	w.l.RUnlock()
+	sort.Slice(ps, func(i, j int) bool {
+		if !ps[i].Registered.Equal(ps[j].Registered) {
+			return ps[i].Registered.Before(ps[j].Registered)
+		}
+		return ps[i].ID < ps[j].ID
+	})
+	return ps
+}
+
 type waitWithResponse struct {
 	ch <-chan interface{}
 }
@@ -89,3 +118,4 @@ func (w *waitWithResponse) Trigger(id uint64, x interface{}) {}
 func (w *waitWithResponse) IsRegistered(id uint64) bool {
 	panic("waitWithResponse.IsRegistered() shouldn't be called")
 }
+func (w *waitWithResponse) Pending() []Pending { return nil }
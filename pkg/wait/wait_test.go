@@ -100,3 +100,20 @@ func TestIsRegistered(t *testing.T) {
 		t.Errorf("event ID 0 is already triggered, shouldn't be registered")
 	}
 }
+
+func TestPending(t *testing.T) {
+	wt := New()
+	for _, id := range []uint64{3, 1, 2} {
+		wt.Register(id)
+		time.Sleep(time.Millisecond)
+	}
+	wt.Trigger(1, nil)
+
+	ps := wt.Pending()
+	if len(ps) != 2 || ps[0].ID != 3 || ps[1].ID != 2 {
+		t.Fatalf("pending = %+v, want IDs 3 and 2", ps)
+	}
+	if ps[0].Registered.After(ps[1].Registered) {
+		t.Errorf("pending = %+v, want oldest first", ps)
+	}
+}
@@ -86,17 +86,8 @@ func (c *cache) Add(req *pb.RangeRequest, resp *pb.RangeResponse) {
 		return
 	}
 
-	var (
-		iv  *adt.IntervalValue
-		ivl adt.Interval
-	)
-	if len(req.RangeEnd) != 0 {
-		ivl = adt.NewStringAffineInterval(string(req.Key), string(req.RangeEnd))
-	} else {
-		ivl = adt.NewStringAffinePoint(string(req.Key))
-	}
-
-	iv = c.cachedRanges.Find(ivl)
+	ivl := keyInterval(req.Key, req.RangeEnd)
+	iv := c.cachedRanges.Find(ivl)
 
 	if iv == nil {
 		val := map[string]struct{}{key: {}}
@@ -132,17 +123,8 @@ func (c *cache) Invalidate(key, endkey []byte) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	var (
-		ivs []*adt.IntervalValue
-		ivl adt.Interval
-	)
-	if len(endkey) == 0 {
-		ivl = adt.NewStringAffinePoint(string(key))
-	} else {
-		ivl = adt.NewStringAffineInterval(string(key), string(endkey))
-	}
-
-	ivs = c.cachedRanges.Stab(ivl)
+	ivl := keyInterval(key, endkey)
+	ivs := c.cachedRanges.Stab(ivl)
 	for _, iv := range ivs {
 		keys := iv.Val.(map[string]struct{})
 		for key := range keys {
@@ -153,6 +135,20 @@ func (c *cache) Invalidate(key, endkey []byte) {
 	c.cachedRanges.Delete(ivl)
 }
 
+// keyInterval returns the interval of keys covered by key and end, with
+// the same meaning as in a RangeRequest: an empty end covers key only and
+// an end of "\x00" covers every key greater than or equal to key.
+func keyInterval(key, end []byte) adt.Interval {
+	switch {
+	case len(end) == 0:
+		return adt.NewStringAffinePoint(string(key))
+	case len(end) == 1 && end[0] == 0:
+		// the empty string compares greater than all other strings
+		return adt.NewStringAffineInterval(string(key), "")
+	}
+	return adt.NewStringAffineInterval(string(key), string(end))
+}
+
 // Compact invalidate all caching response before the given rev.
 // Replace with the invalidation is lazy. The actual removal happens when the entries is accessed.
 func (c *cache) Compact(revision int64) {
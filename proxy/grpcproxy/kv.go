@@ -16,10 +16,13 @@ package grpcproxy
 
 import (
 	"context"
+	"sync"
 
 	"go.etcd.io/etcd/clientv3"
 	pb "go.etcd.io/etcd/etcdserver/etcdserverpb"
 	"go.etcd.io/etcd/proxy/grpcproxy/cache"
+
+	"golang.org/x/time/rate"
 )
 
 type kvProxy struct {
@@ -27,16 +30,60 @@ type kvProxy struct {
 	cache cache.Cache
 }
 
-func NewKvProxy(c *clientv3.Client) (pb.KVServer, <-chan struct{}) {
+// NewKvProxy creates a KV proxy that caches serializable ranges.
+// Cached ranges are invalidated by writes made through the proxy. If
+// watchPrefixes are given, the proxy also keeps a watch on each prefix
+// and invalidates cached ranges as soon as other clients change them;
+// an empty prefix watches the whole keyspace. The returned channel is
+// closed once the watches stop with the client.
+func NewKvProxy(c *clientv3.Client, watchPrefixes ...string) (pb.KVServer, <-chan struct{}) {
 	kv := &kvProxy{
 		kv:    c.KV,
 		cache: cache.NewCache(cache.DefaultMaxEntries),
 	}
 	donec := make(chan struct{})
-	close(donec)
+	if len(watchPrefixes) == 0 {
+		close(donec)
+		return kv, donec
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(len(watchPrefixes))
+	for _, pfx := range watchPrefixes {
+		go func(pfx string) {
+			defer wg.Done()
+			kv.invalidateOnWatch(c, pfx)
+		}(pfx)
+	}
+	go func() {
+		wg.Wait()
+		close(donec)
+	}()
 	return kv, donec
 }
 
+// invalidateOnWatch invalidates the cached ranges overlapping every
+// key changed under the prefix until the client is closed.
+func (p *kvProxy) invalidateOnWatch(c *clientv3.Client, pfx string) {
+	key, end := []byte(pfx), []byte(clientv3.GetPrefixRangeEnd(pfx))
+	if len(pfx) == 0 {
+		key, end = []byte{0}, []byte{0}
+	}
+	limiter := rate.NewLimiter(rate.Limit(retryPerSecond), retryPerSecond)
+	for limiter.Wait(c.Ctx()) == nil {
+		for wr := range c.Watch(c.Ctx(), pfx, clientv3.WithPrefix()) {
+			for _, ev := range wr.Events {
+				p.cache.Invalidate(ev.Kv.Key, nil)
+			}
+			cacheKeys.Set(float64(p.cache.Size()))
+		}
+		// the watch was canceled or compacted and may have missed
+		// events; drop everything under the prefix before rewatching
+		p.cache.Invalidate(key, end)
+		cacheKeys.Set(float64(p.cache.Size()))
+	}
+}
+
 func (p *kvProxy) Range(ctx context.Context, r *pb.RangeRequest) (*pb.RangeResponse, error) {
 	if r.Serializable {
 		resp, err := p.cache.Get(r)
@@ -53,6 +53,47 @@ func TestKVProxyRange(t *testing.T) {
 	client.Close()
 }
 
+func TestKVProxyWatchInvalidate(t *testing.T) {
+	defer testutil.AfterTest(t)
+
+	clus := integration.NewClusterV3(t, &integration.ClusterConfig{Size: 1})
+	defer clus.Terminate(t)
+
+	kvts := newKVProxyServer([]string{clus.Members[0].GRPCAddr()}, t, "")
+	defer kvts.close()
+
+	cfg := clientv3.Config{
+		Endpoints:   []string{kvts.l.Addr().String()},
+		DialTimeout: 5 * time.Second,
+	}
+	client, err := clientv3.New(cfg)
+	if err != nil {
+		t.Fatalf("err = %v, want nil", err)
+	}
+	defer client.Close()
+
+	// cache a serializable range through the proxy
+	if _, err = client.Get(context.TODO(), "foo", clientv3.WithSerializable()); err != nil {
+		t.Fatal(err)
+	}
+	// write directly to the cluster, bypassing the proxy
+	if _, err = clus.Client(0).Put(context.TODO(), "foo", "bar"); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 10; i++ {
+		resp, gerr := client.Get(context.TODO(), "foo", clientv3.WithSerializable())
+		if gerr != nil {
+			t.Fatal(gerr)
+		}
+		if len(resp.Kvs) == 1 && string(resp.Kvs[0].Value) == "bar" {
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Fatal("cached range was not invalidated by watch")
+}
+
 type kvproxyTestServer struct {
 	kp     pb.KVServer
 	c      *clientv3.Client
@@ -66,7 +107,7 @@ func (kts *kvproxyTestServer) close() {
 	kts.c.Close()
 }
 
-func newKVProxyServer(endpoints []string, t *testing.T) *kvproxyTestServer {
+func newKVProxyServer(endpoints []string, t *testing.T, watchPrefixes ...string) *kvproxyTestServer {
 	cfg := clientv3.Config{
 		Endpoints:   endpoints,
 		DialTimeout: 5 * time.Second,
@@ -76,7 +117,7 @@ func newKVProxyServer(endpoints []string, t *testing.T) *kvproxyTestServer {
 		t.Fatal(err)
 	}
 
-	kvp, _ := NewKvProxy(client)
+	kvp, _ := NewKvProxy(client, watchPrefixes...)
 
 	kvts := &kvproxyTestServer{
 		kp: kvp,
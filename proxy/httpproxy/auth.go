@@ -0,0 +1,112 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpproxy
+
+import (
+	"crypto/sha256"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxRejectedCredentials bounds the number of rejected credentials the
+// proxy remembers, so that a client trying many passwords does not grow
+// the cache without bound.
+const maxRejectedCredentials = 4096
+
+// requiresCredentials returns true if the request must carry credentials
+// when the proxy requires them. The version and health endpoints are
+// served to anonymous clients by the members too.
+func requiresCredentials(req *http.Request) bool {
+	return strings.HasPrefix(req.URL.Path, "/v2/") || strings.HasPrefix(req.URL.Path, "/v3")
+}
+
+// hasCredentials returns true if the request carries an Authorization
+// header, or a verified client certificate.
+func hasCredentials(req *http.Request) bool {
+	if req.Header.Get("Authorization") != "" {
+		return true
+	}
+	return req.TLS != nil && len(req.TLS.VerifiedChains) > 0
+}
+
+// authCache remembers the requests whose credentials the cluster rejected,
+// to reject them without sending them to the cluster, where checking a
+// password is slow by design. The cluster answers a wrong password and a
+// user lacking the permission alike, so the credentials are only rejected
+// for the method and path they were rejected for. They are kept hashed.
+type authCache struct {
+	ttl time.Duration
+
+	mu       sync.Mutex
+	rejected map[[sha256.Size]byte]time.Time
+}
+
+func newAuthCache(ttl time.Duration) *authCache {
+	if ttl <= 0 {
+		return nil
+	}
+	return &authCache{ttl: ttl, rejected: make(map[[sha256.Size]byte]time.Time)}
+}
+
+func authCacheKey(authz, method, path string) [sha256.Size]byte {
+	return sha256.Sum256([]byte(authz + "\n" + method + " " + path))
+}
+
+// isRejected returns true if the cluster rejected the Authorization header
+// authz for the method and path within the TTL.
+func (c *authCache) isRejected(authz, method, path string, now time.Time) bool {
+	if authz == "" {
+		return false
+	}
+	k := authCacheKey(authz, method, path)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t, ok := c.rejected[k]
+	if ok && now.Sub(t) >= c.ttl {
+		delete(c.rejected, k)
+		return false
+	}
+	return ok
+}
+
+// observe records the response code the cluster answered a request to the
+// method and path carrying the Authorization header authz with. The
+// credentials are rejected on 401 Unauthorized, and forgotten on any other
+// code, e.g. once the user is created or its password changed.
+func (c *authCache) observe(authz, method, path string, code int, now time.Time) {
+	if authz == "" {
+		return
+	}
+	k := authCacheKey(authz, method, path)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if code != http.StatusUnauthorized {
+		delete(c.rejected, k)
+		return
+	}
+	if len(c.rejected) >= maxRejectedCredentials {
+		for rk, t := range c.rejected {
+			if now.Sub(t) >= c.ttl {
+				delete(c.rejected, rk)
+			}
+		}
+		if len(c.rejected) >= maxRejectedCredentials {
+			return
+		}
+	}
+	c.rejected[k] = now
+}
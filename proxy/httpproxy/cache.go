@@ -0,0 +1,137 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpproxy
+
+import (
+	"container/list"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// StaleSinceHeader is set on responses served from the last-known-good
+// cache of the proxy. Its value is the time the response was received
+// from the cluster.
+const StaleSinceHeader = "X-Etcd-Stale-Since"
+
+type cachedResponse struct {
+	key    string
+	code   int
+	header http.Header
+	body   []byte
+	stored time.Time
+}
+
+// size estimates the memory held by the cached response.
+func (cr *cachedResponse) size() int {
+	n := len(cr.key) + len(cr.body)
+	for k, vs := range cr.header {
+		n += len(k)
+		for _, v := range vs {
+			n += len(v)
+		}
+	}
+	return n
+}
+
+// responseCache keeps the last successful responses to GET requests, so
+// they can be served while the cluster is unreachable. The least recently
+// used responses are evicted once they hold more than maxBytes.
+type responseCache struct {
+	maxBytes int
+
+	mu      sync.Mutex
+	bytes   int
+	lru     *list.List
+	entries map[string]*list.Element
+}
+
+func newResponseCache(maxBytes int) *responseCache {
+	if maxBytes <= 0 {
+		return nil
+	}
+	return &responseCache{
+		maxBytes: maxBytes,
+		lru:      list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+// cacheKey returns the key of the request in the cache, or false if the
+// response to the request must not be cached. Watches and streams are
+// never cached, and quorum reads are never answered with stale data.
+func cacheKey(req *http.Request) (string, bool) {
+	if req.Method != "GET" {
+		return "", false
+	}
+	q := req.URL.Query()
+	for _, name := range []string{"wait", "stream", "quorum"} {
+		if v, ok := q[name]; ok {
+			// the members read the flags with strconv.ParseBool, and
+			// refuse the invalid ones
+			if b, err := strconv.ParseBool(v[0]); err != nil || b {
+				return "", false
+			}
+		}
+	}
+	// responses depend on the credentials of the client
+	return req.Header.Get("Authorization") + " " + req.URL.RequestURI(), true
+}
+
+// add caches the response, unless it is larger than the whole cache.
+func (c *responseCache) add(key string, code int, header http.Header, body []byte) {
+	cr := &cachedResponse{key: key, code: code, header: header, body: body, stored: time.Now()}
+	size := cr.size()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok {
+		c.remove(e)
+	}
+	if size > c.maxBytes {
+		return
+	}
+	c.entries[key] = c.lru.PushFront(cr)
+	c.bytes += size
+	for c.bytes > c.maxBytes {
+		c.remove(c.lru.Back())
+	}
+}
+
+func (c *responseCache) remove(e *list.Element) {
+	cr := c.lru.Remove(e).(*cachedResponse)
+	delete(c.entries, cr.key)
+	c.bytes -= cr.size()
+}
+
+func (c *responseCache) get(key string) (*cachedResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.lru.MoveToFront(e)
+	return e.Value.(*cachedResponse), true
+}
+
+// writeTo writes the cached response to w, marked with StaleSinceHeader.
+func (cr *cachedResponse) writeTo(w http.ResponseWriter) {
+	copyHeader(w.Header(), cr.header)
+	w.Header().Set(StaleSinceHeader, cr.stored.UTC().Format(http.TimeFormat))
+	w.WriteHeader(cr.code)
+	w.Write(cr.body)
+}
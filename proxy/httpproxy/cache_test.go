@@ -0,0 +1,87 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpproxy
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestCacheKey(t *testing.T) {
+	tests := []struct {
+		method string
+		url    string
+
+		wcacheable bool
+	}{
+		{"GET", "/v2/keys/foo", true},
+		{"GET", "/v2/keys/foo?wait=false&quorum=0", true},
+		{"GET", "/v2/keys/foo?wait=true", false},
+		{"GET", "/v2/keys/foo?wait=1", false},
+		{"GET", "/v2/keys/foo?wait=T", false},
+		{"GET", "/v2/keys/foo?quorum=TRUE", false},
+		{"GET", "/v2/keys/foo?stream=true", false},
+		{"GET", "/v2/keys/foo?wait=true&stream=1", false},
+		// the members refuse the request
+		{"GET", "/v2/keys/foo?quorum=yes", false},
+		{"PUT", "/v2/keys/foo", false},
+	}
+	for i, tt := range tests {
+		req, err := http.NewRequest(tt.method, "http://192.0.2.2:2379"+tt.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := cacheKey(req); ok != tt.wcacheable {
+			t.Errorf("#%d: cacheable = %v, want %v", i, ok, tt.wcacheable)
+		}
+	}
+}
+
+// TestResponseCacheBytes ensures the cache evicts the least recently used
+// responses to stay within its bytes, and does not keep larger ones.
+func TestResponseCacheBytes(t *testing.T) {
+	c := newResponseCache(100)
+	body := []byte(strings.Repeat("v", 40))
+	c.add("a", http.StatusOK, nil, body)
+	c.add("b", http.StatusOK, nil, body)
+	c.get("a")
+	c.add("c", http.StatusOK, nil, body)
+	for _, tt := range []struct {
+		key string
+		ok  bool
+	}{{"a", true}, {"b", false}, {"c", true}} {
+		if _, ok := c.get(tt.key); ok != tt.ok {
+			t.Errorf("%s cached = %v, want %v", tt.key, ok, tt.ok)
+		}
+	}
+	if c.bytes != 82 {
+		t.Errorf("bytes = %d, want 82", c.bytes)
+	}
+
+	// replacing a response replaces its bytes
+	c.add("a", http.StatusOK, nil, body[:10])
+	if c.bytes != 52 {
+		t.Errorf("bytes = %d, want 52", c.bytes)
+	}
+
+	c.add("d", http.StatusOK, nil, []byte(strings.Repeat("v", 100)))
+	if _, ok := c.get("d"); ok {
+		t.Errorf("response larger than the cache was cached")
+	}
+	if _, ok := c.get("c"); !ok {
+		t.Errorf("response evicted by a response larger than the cache")
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright 2019 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpproxy
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type hedgeResult struct {
+	i   int
+	res *http.Response
+	err error
+}
+
+// hedgeable returns true if req can be sent to a second endpoint while
+// the first one is still to respond. Only reads are hedged, and watches,
+// which are expected to wait, never are.
+func (p *reverseProxy) hedgeable(req *http.Request, eps []*endpoint) bool {
+	if p.hedgeDelay <= 0 || len(eps) < 2 || req.Method != "GET" {
+		return false
+	}
+	if p.retryBudget == 1 {
+		return false
+	}
+	// the members read the flag with strconv.ParseBool; an invalid one
+	// is refused anyway
+	wait, err := strconv.ParseBool(req.URL.Query().Get("wait"))
+	return err != nil || !wait
+}
+
+// roundTripHedged sends req to the first of the two endpoints eps, and to
+// the second one if the first did not respond within the hedge delay or
+// failed, and returns the first response received. The other request is
+// canceled. res is nil if both requests failed.
+func (p *reverseProxy) roundTripHedged(req *http.Request, body []byte, eps []*endpoint) (res *http.Response, attempted []string, timedOut bool) {
+	resc := make(chan hedgeResult, len(eps))
+	cancels := make([]context.CancelFunc, 0, len(eps))
+	send := func(i int) {
+		ctx, cancel := context.WithCancel(req.Context())
+		cancels = append(cancels, cancel)
+		r := req.WithContext(ctx)
+		u := *req.URL
+		r.URL = &u
+		if body != nil {
+			r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+		redirectRequest(r, eps[i].URL)
+		attempted = append(attempted, eps[i].URL.String())
+		go func() {
+			res, err := p.transport.RoundTrip(r)
+			resc <- hedgeResult{i: i, res: res, err: err}
+		}()
+	}
+
+	send(0)
+	sent, pending := 1, 1
+	timer := time.NewTimer(p.hedgeDelay)
+	defer timer.Stop()
+	for pending > 0 {
+		select {
+		case <-timer.C:
+			if sent < len(eps) {
+				reportRequestHedged(req)
+				send(sent)
+				sent, pending = sent+1, pending+1
+			}
+		case hr := <-resc:
+			pending--
+			if hr.err != nil {
+				reportRequestDropped(req, failedSendingRequest)
+				plog.Printf("failed to direct request to %s: %v", eps[hr.i].URL.String(), hr.err)
+				if ne, ok := hr.err.(net.Error); ok && ne.Timeout() {
+					timedOut = true
+				}
+				eps[hr.i].Failed()
+				if sent < len(eps) {
+					// no need to wait for the hedge delay
+					send(sent)
+					sent, pending = sent+1, pending+1
+				}
+				continue
+			}
+			for i, cancel := range cancels {
+				if i != hr.i {
+					cancel()
+				}
+			}
+			go func(n int) {
+				for ; n > 0; n-- {
+					if lr := <-resc; lr.err == nil {
+						lr.res.Body.Close()
+					}
+				}
+			}(pending)
+			return hr.res, attempted, timedOut
+		}
+	}
+	for _, cancel := range cancels {
+		cancel()
+	}
+	return nil, attempted, timedOut
+}
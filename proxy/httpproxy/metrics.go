@@ -47,6 +47,22 @@ var (
 			Help:      "Counter of requests dropped on the proxy.",
 		}, []string{"method", "proxying_error"})
 
+	requestsServedStale = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "etcd",
+			Subsystem: "proxy",
+			Name:      "stale_total",
+			Help:      "Counter of requests answered from the cache while the cluster was unreachable.",
+		}, []string{"method"})
+
+	requestsHedged = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "etcd",
+			Subsystem: "proxy",
+			Name:      "hedged_total",
+			Help:      "Counter of reads sent to a second endpoint after the first one did not respond within the hedge delay.",
+		}, []string{"method"})
+
 	requestsHandlingSec = prometheus.NewHistogramVec(
 		prometheus.HistogramOpts{
 			Namespace: "etcd",
@@ -66,12 +82,16 @@ const (
 	zeroEndpoints         forwardingError = "zero_endpoints"
 	failedSendingRequest  forwardingError = "failed_sending_request"
 	failedGettingResponse forwardingError = "failed_getting_response"
+	missingCredentials    forwardingError = "missing_credentials"
+	rejectedCredentials   forwardingError = "rejected_credentials"
 )
 
 func init() {
 	prometheus.MustRegister(requestsIncoming)
 	prometheus.MustRegister(requestsHandled)
 	prometheus.MustRegister(requestsDropped)
+	prometheus.MustRegister(requestsServedStale)
+	prometheus.MustRegister(requestsHedged)
 	prometheus.MustRegister(requestsHandlingSec)
 }
 
@@ -88,3 +108,11 @@ func reportRequestHandled(request *http.Request, response *http.Response, startT
 func reportRequestDropped(request *http.Request, err forwardingError) {
 	requestsDropped.WithLabelValues(request.Method, string(err)).Inc()
 }
+
+func reportRequestServedStale(request *http.Request) {
+	requestsServedStale.WithLabelValues(request.Method).Inc()
+}
+
+func reportRequestHedged(request *http.Request) {
+	requestsHedged.WithLabelValues(request.Method).Inc()
+}
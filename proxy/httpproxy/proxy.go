@@ -43,7 +43,18 @@ type GetProxyURLs func() []string
 // NewHandler creates a new HTTP handler, listening on the given transport,
 // which will proxy requests to an etcd cluster.
 // The handler will periodically update its view of the cluster.
-func NewHandler(t *http.Transport, urlsFunc GetProxyURLs, failureWait time.Duration, refreshInterval time.Duration) http.Handler {
+// Each request is tried on at most retryBudget endpoints, or on all
+// available endpoints if retryBudget is 0.
+// If staleCacheSize is positive, the last successful responses to GET
+// requests, up to staleCacheSize bytes, are served, marked with
+// StaleSinceHeader, when no endpoint responds.
+// If hedgeDelay is positive, a read not answered within hedgeDelay is also
+// sent to a second endpoint, and the first response is returned.
+// The Authorization headers are forwarded untouched. If requireAuth is
+// true, the API requests without credentials are rejected by the proxy.
+// If authCacheTTL is positive, the credentials the cluster rejected are
+// rejected by the proxy for authCacheTTL.
+func NewHandler(t *http.Transport, urlsFunc GetProxyURLs, failureWait time.Duration, refreshInterval time.Duration, retryBudget int, staleCacheSize int, hedgeDelay time.Duration, requireAuth bool, authCacheTTL time.Duration) http.Handler {
 	if t.TLSClientConfig != nil {
 		// Enable http2, see Issue 5033.
 		err := http2.ConfigureTransport(t)
@@ -53,8 +64,13 @@ func NewHandler(t *http.Transport, urlsFunc GetProxyURLs, failureWait time.Durat
 	}
 
 	p := &reverseProxy{
-		director:  newDirector(urlsFunc, failureWait, refreshInterval),
-		transport: t,
+		director:    newDirector(urlsFunc, failureWait, refreshInterval),
+		transport:   t,
+		retryBudget: retryBudget,
+		cache:       newResponseCache(staleCacheSize),
+		hedgeDelay:  hedgeDelay,
+		requireAuth: requireAuth,
+		authCache:   newAuthCache(authCacheTTL),
 	}
 
 	mux := http.NewServeMux()
@@ -58,10 +58,38 @@ func removeSingleHopHeaders(hdrs *http.Header) {
 type reverseProxy struct {
 	director  *director
 	transport http.RoundTripper
+	// retryBudget is the maximum number of endpoints a request is sent
+	// to. 0 means all available endpoints.
+	retryBudget int
+	// cache holds the last-known-good responses to GET requests, or is
+	// nil if stale responses are never served.
+	cache *responseCache
+	// hedgeDelay is the time after which a read not yet answered by an
+	// endpoint is also sent to the next one, or 0 to never hedge reads.
+	hedgeDelay time.Duration
+	// requireAuth is true to reject the API requests without credentials
+	// instead of sending them to the cluster.
+	requireAuth bool
+	// authCache holds the credentials the cluster rejected, or is nil if
+	// the proxy sends every request to the cluster.
+	authCache *authCache
 }
 
 func (p *reverseProxy) ServeHTTP(rw http.ResponseWriter, clientreq *http.Request) {
 	reportIncomingRequest(clientreq)
+	// the Authorization header is forwarded untouched; the members check
+	// the credentials
+	authz := clientreq.Header.Get("Authorization")
+	if p.requireAuth && requiresCredentials(clientreq) && !hasCredentials(clientreq) {
+		reportRequestDropped(clientreq, missingCredentials)
+		writeUnauthorized(rw, clientreq)
+		return
+	}
+	if p.authCache != nil && p.authCache.isRejected(authz, clientreq.Method, clientreq.URL.Path, time.Now()) {
+		reportRequestDropped(clientreq, rejectedCredentials)
+		writeUnauthorized(rw, clientreq)
+		return
+	}
 	proxyreq := new(http.Request)
 	*proxyreq = *clientreq
 	startTime := time.Now()
@@ -92,9 +120,17 @@ func (p *reverseProxy) ServeHTTP(rw http.ResponseWriter, clientreq *http.Request
 	removeSingleHopHeaders(&proxyreq.Header)
 	maybeSetForwardedFor(proxyreq)
 
+	key, cacheable := "", false
+	if p.cache != nil {
+		key, cacheable = cacheKey(clientreq)
+	}
+
 	endpoints := p.director.endpoints()
 	if len(endpoints) == 0 {
 		msg := "zero endpoints currently available"
+		if cacheable && p.serveStale(rw, clientreq, key) {
+			return
+		}
 		reportRequestDropped(clientreq, zeroEndpoints)
 
 		// TODO: limit the rate of the error logging.
@@ -129,9 +165,31 @@ func (p *reverseProxy) ServeHTTP(rw http.ResponseWriter, clientreq *http.Request
 		}()
 	}
 
-	var res *http.Response
+	var (
+		res       *http.Response
+		attempted []string
+		timedOut  bool
+	)
+
+	if p.hedgeable(clientreq, endpoints) {
+		res, attempted, timedOut = p.roundTripHedged(proxyreq, proxybody, endpoints[:2])
+		if atomic.LoadInt32(&requestClosed) == 1 {
+			if res != nil {
+				res.Body.Close()
+			}
+			return
+		}
+		endpoints = endpoints[len(attempted):]
+		if res != nil {
+			endpoints = nil
+		}
+	}
 
 	for _, ep := range endpoints {
+		if p.retryBudget > 0 && len(attempted) == p.retryBudget {
+			break
+		}
+		attempted = append(attempted, ep.URL.String())
 		if proxybody != nil {
 			proxyreq.Body = ioutil.NopCloser(bytes.NewBuffer(proxybody))
 		}
@@ -144,6 +202,9 @@ func (p *reverseProxy) ServeHTTP(rw http.ResponseWriter, clientreq *http.Request
 		if err != nil {
 			reportRequestDropped(clientreq, failedSendingRequest)
 			plog.Printf("failed to direct request to %s: %v", ep.URL.String(), err)
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				timedOut = true
+			}
 			ep.Failed()
 			continue
 		}
@@ -153,10 +214,18 @@ func (p *reverseProxy) ServeHTTP(rw http.ResponseWriter, clientreq *http.Request
 
 	if res == nil {
 		// TODO: limit the rate of the error logging.
-		msg := fmt.Sprintf("unable to get response from %d endpoint(s)", len(endpoints))
+		code := http.StatusBadGateway
+		msg := fmt.Sprintf("unable to get response from %d endpoint(s) [%s]", len(attempted), strings.Join(attempted, ", "))
+		if timedOut {
+			code = http.StatusGatewayTimeout
+			msg = fmt.Sprintf("timed out waiting for response from %d endpoint(s) [%s]", len(attempted), strings.Join(attempted, ", "))
+		}
+		if cacheable && p.serveStale(rw, clientreq, key) {
+			return
+		}
 		reportRequestDropped(clientreq, failedGettingResponse)
 		plog.Println(msg)
-		e := httptypes.NewHTTPError(http.StatusBadGateway, "httpproxy: "+msg)
+		e := httptypes.NewHTTPError(code, "httpproxy: "+msg)
 		if we := e.WriteTo(rw); we != nil {
 			plog.Debugf("error writing HTTPError (%v) to %s", we, clientreq.RemoteAddr)
 		}
@@ -165,13 +234,55 @@ func (p *reverseProxy) ServeHTTP(rw http.ResponseWriter, clientreq *http.Request
 
 	defer res.Body.Close()
 	reportRequestHandled(clientreq, res, startTime)
+	if p.authCache != nil {
+		p.authCache.observe(authz, clientreq.Method, clientreq.URL.Path, res.StatusCode, time.Now())
+	}
 	removeSingleHopHeaders(&res.Header)
 	copyHeader(rw.Header(), res.Header)
 
+	if cacheable && res.StatusCode == http.StatusOK {
+		// a body larger than the cache is streamed without being cached
+		body, err := ioutil.ReadAll(io.LimitReader(res.Body, int64(p.cache.maxBytes)+1))
+		if err != nil {
+			plog.Printf("failed to read response body for %s: %v", clientreq.URL.Path, err)
+			rw.WriteHeader(res.StatusCode)
+			rw.Write(body)
+			return
+		}
+		if len(body) <= p.cache.maxBytes {
+			p.cache.add(key, res.StatusCode, res.Header, body)
+		}
+		rw.WriteHeader(res.StatusCode)
+		rw.Write(body)
+		io.Copy(rw, res.Body)
+		return
+	}
+
 	rw.WriteHeader(res.StatusCode)
 	io.Copy(rw, res.Body)
 }
 
+// writeUnauthorized answers the request like a member refusing its
+// credentials.
+func writeUnauthorized(rw http.ResponseWriter, clientreq *http.Request) {
+	e := httptypes.NewHTTPError(http.StatusUnauthorized, "Insufficient credentials")
+	if we := e.WriteTo(rw); we != nil {
+		plog.Debugf("error writing HTTPError (%v) to %s", we, clientreq.RemoteAddr)
+	}
+}
+
+// serveStale writes the last-known-good response to the request, if any.
+func (p *reverseProxy) serveStale(rw http.ResponseWriter, clientreq *http.Request, key string) bool {
+	cr, ok := p.cache.get(key)
+	if !ok {
+		return false
+	}
+	plog.Printf("cluster unreachable; serving %s from cache (stale since %v)", clientreq.URL.Path, cr.stored)
+	reportRequestServedStale(clientreq)
+	cr.writeTo(rw)
+	return true
+}
+
 func copyHeader(dst, src http.Header) {
 	for k, vv := range src {
 		for _, v := range vv {
@@ -22,7 +22,12 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 )
 
 type staticRoundTripper struct {
@@ -61,6 +66,13 @@ func TestReverseProxyServe(t *testing.T) {
 			want: http.StatusBadGateway,
 		},
 
+		// endpoint times out
+		{
+			eps:  []*endpoint{{URL: u, Available: true}},
+			rt:   &staticRoundTripper{err: timeoutError{}},
+			want: http.StatusGatewayTimeout,
+		},
+
 		// endpoint is available and returns success
 		{
 			eps: []*endpoint{{URL: u, Available: true}},
@@ -94,6 +106,127 @@ func TestReverseProxyServe(t *testing.T) {
 	}
 }
 
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+type countingRoundTripper struct {
+	n int
+}
+
+func (crt *countingRoundTripper) RoundTrip(*http.Request) (*http.Response, error) {
+	crt.n++
+	return nil, errors.New("what a bad trip")
+}
+
+func TestReverseProxyRetryBudget(t *testing.T) {
+	eps := []*endpoint{
+		{URL: url.URL{Scheme: "http", Host: "192.0.2.3:4040"}, Available: true},
+		{URL: url.URL{Scheme: "http", Host: "192.0.2.4:4040"}, Available: true},
+		{URL: url.URL{Scheme: "http", Host: "192.0.2.5:4040"}, Available: true},
+	}
+	tests := []struct {
+		budget int
+		wn     int
+	}{
+		{0, 3},
+		{1, 1},
+		{2, 2},
+		{5, 3},
+	}
+	for i, tt := range tests {
+		for _, ep := range eps {
+			ep.Available = true
+		}
+		rt := &countingRoundTripper{}
+		rp := reverseProxy{
+			director:    &director{ep: eps},
+			transport:   rt,
+			retryBudget: tt.budget,
+		}
+
+		req, _ := http.NewRequest("GET", "http://192.0.2.2:2379", nil)
+		rr := httptest.NewRecorder()
+		rp.ServeHTTP(rr, req)
+
+		if rt.n != tt.wn {
+			t.Errorf("#%d: attempts = %d, want %d", i, rt.n, tt.wn)
+		}
+		if rr.Code != http.StatusBadGateway {
+			t.Errorf("#%d: code = %d, want %d", i, rr.Code, http.StatusBadGateway)
+		}
+		if !strings.Contains(rr.Body.String(), eps[0].URL.String()) {
+			t.Errorf("#%d: body %q does not list the attempted endpoints", i, rr.Body.String())
+		}
+	}
+}
+
+func TestReverseProxyServeStale(t *testing.T) {
+	ep := &endpoint{URL: url.URL{Scheme: "http", Host: "192.0.2.3:4040"}, Available: true}
+	srt := &staticRoundTripper{
+		res: &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(strings.NewReader("bar")),
+			Header:     map[string][]string{"X-Etcd-Index": {"7"}},
+		},
+	}
+	rp := reverseProxy{
+		director:  &director{ep: []*endpoint{ep}},
+		transport: srt,
+		cache:     newResponseCache(1024),
+	}
+	serve := func(u string) *httptest.ResponseRecorder {
+		ep.Available = true
+		req, _ := http.NewRequest("GET", u, nil)
+		rr := httptest.NewRecorder()
+		rp.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := serve("http://192.0.2.2:2379/v2/keys/foo"); rr.Header().Get(StaleSinceHeader) != "" {
+		t.Fatalf("fresh response marked stale")
+	}
+	serve("http://192.0.2.2:2379/v2/keys/foo?wait=true")
+
+	srt.res, srt.err = nil, errors.New("what a bad trip")
+	dropped := counterValue(requestsDropped.WithLabelValues("GET", string(failedGettingResponse)))
+	rr := serve("http://192.0.2.2:2379/v2/keys/foo")
+	if rr.Code != http.StatusOK || rr.Body.String() != "bar" {
+		t.Errorf("stale response = %d %q, want %d %q", rr.Code, rr.Body.String(), http.StatusOK, "bar")
+	}
+	if g := counterValue(requestsDropped.WithLabelValues("GET", string(failedGettingResponse))); g != dropped {
+		t.Errorf("dropped = %v, want %v; a request served stale is not dropped", g, dropped)
+	}
+	if rr.Header().Get(StaleSinceHeader) == "" {
+		t.Errorf("stale response not marked with %s", StaleSinceHeader)
+	}
+	if g := rr.Header().Get("X-Etcd-Index"); g != "7" {
+		t.Errorf("X-Etcd-Index = %q, want %q", g, "7")
+	}
+
+	for i, u := range []string{
+		"http://192.0.2.2:2379/v2/keys/bar",
+		"http://192.0.2.2:2379/v2/keys/foo?wait=true",
+		"http://192.0.2.2:2379/v2/keys/foo?quorum=true",
+	} {
+		if rr := serve(u); rr.Code != http.StatusBadGateway {
+			t.Errorf("#%d: code = %d, want %d", i, rr.Code, http.StatusBadGateway)
+		}
+		dropped++
+		if g := counterValue(requestsDropped.WithLabelValues("GET", string(failedGettingResponse))); g != dropped {
+			t.Errorf("#%d: dropped = %v, want %v", i, g, dropped)
+		}
+	}
+}
+
+func counterValue(c prometheus.Counter) float64 {
+	m := &dto.Metric{}
+	c.Write(m)
+	return m.GetCounter().GetValue()
+}
+
 func TestRedirectRequest(t *testing.T) {
 	loc := url.URL{
 		Scheme: "http",
@@ -243,3 +376,170 @@ func TestCopyHeader(t *testing.T) {
 		}
 	}
 }
+
+// slowRoundTripper answers the requests to the slow host only once they
+// are canceled, and the others at once.
+type slowRoundTripper struct {
+	slow     string
+	canceled chan struct{}
+}
+
+func (srt *slowRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Host == srt.slow {
+		<-req.Context().Done()
+		close(srt.canceled)
+		return nil, req.Context().Err()
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       ioutil.NopCloser(strings.NewReader(req.URL.Host)),
+	}, nil
+}
+
+func TestReverseProxyHedge(t *testing.T) {
+	eps := []*endpoint{
+		{URL: url.URL{Scheme: "http", Host: "192.0.2.3:4040"}, Available: true},
+		{URL: url.URL{Scheme: "http", Host: "192.0.2.4:4040"}, Available: true},
+	}
+	rt := &slowRoundTripper{slow: eps[0].URL.Host, canceled: make(chan struct{})}
+	rp := reverseProxy{
+		director:   &director{ep: eps},
+		transport:  rt,
+		hedgeDelay: 10 * time.Millisecond,
+	}
+
+	req, _ := http.NewRequest("GET", "http://192.0.2.2:2379/v2/keys/foo", nil)
+	rr := httptest.NewRecorder()
+	rp.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || rr.Body.String() != eps[1].URL.Host {
+		t.Fatalf("response = %d %q, want %d %q", rr.Code, rr.Body.String(), http.StatusOK, eps[1].URL.Host)
+	}
+	select {
+	case <-rt.canceled:
+	case <-time.After(time.Second):
+		t.Fatalf("request to the slow endpoint not canceled")
+	}
+
+	for _, wait := range []string{"true", "1", "T", "TRUE"} {
+		req, _ = http.NewRequest("GET", "http://192.0.2.2:2379/v2/keys/foo?wait="+wait, nil)
+		if rp.hedgeable(req, eps) {
+			t.Errorf("watch with wait=%s is hedged, want not hedged", wait)
+		}
+	}
+	req, _ = http.NewRequest("GET", "http://192.0.2.2:2379/v2/keys/foo?wait=false", nil)
+	if !rp.hedgeable(req, eps) {
+		t.Errorf("read with wait=false is not hedged, want hedged")
+	}
+	req, _ = http.NewRequest("PUT", "http://192.0.2.2:2379/v2/keys/foo", nil)
+	if rp.hedgeable(req, eps) {
+		t.Errorf("PUT is hedged, want not hedged")
+	}
+}
+
+type authRoundTripper struct {
+	code int
+	reqs []*http.Request
+}
+
+func (art *authRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	art.reqs = append(art.reqs, req)
+	return &http.Response{StatusCode: art.code, Body: ioutil.NopCloser(&bytes.Reader{})}, nil
+}
+
+func TestReverseProxyAuth(t *testing.T) {
+	ep := &endpoint{URL: url.URL{Scheme: "http", Host: "192.0.2.3:4040"}, Available: true}
+	art := &authRoundTripper{code: http.StatusUnauthorized}
+	rp := reverseProxy{
+		director:    &director{ep: []*endpoint{ep}},
+		transport:   art,
+		requireAuth: true,
+		authCache:   newAuthCache(time.Minute),
+	}
+	serve := func(u, authz string) int {
+		req, _ := http.NewRequest("GET", u, nil)
+		if strings.HasSuffix(u, "/forbidden") {
+			art.code = http.StatusUnauthorized
+		}
+		if authz != "" {
+			req.Header.Set("Authorization", authz)
+			req.Header.Set("Proxy-Authorization", "secret")
+		}
+		rr := httptest.NewRecorder()
+		rp.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	// missing credentials are rejected, except by the version endpoint
+	if code := serve("http://192.0.2.2:2379/v2/keys/foo", ""); code != http.StatusUnauthorized {
+		t.Errorf("code = %d, want %d", code, http.StatusUnauthorized)
+	}
+	if len(art.reqs) != 0 {
+		t.Fatalf("forwarded %d requests without credentials, want 0", len(art.reqs))
+	}
+	serve("http://192.0.2.2:2379/version", "")
+	if len(art.reqs) != 1 {
+		t.Fatalf("forwarded %d requests, want 1", len(art.reqs))
+	}
+
+	// the credentials are forwarded, then rejected by the proxy once the
+	// cluster rejected them
+	for i := 0; i < 2; i++ {
+		if code := serve("http://192.0.2.2:2379/v2/keys/foo", "Basic d3Jvbmc="); code != http.StatusUnauthorized {
+			t.Errorf("#%d: code = %d, want %d", i, code, http.StatusUnauthorized)
+		}
+	}
+	if len(art.reqs) != 2 {
+		t.Fatalf("forwarded %d requests, want 2", len(art.reqs))
+	}
+	h := art.reqs[1].Header
+	if g := h.Get("Authorization"); g != "Basic d3Jvbmc=" {
+		t.Errorf("Authorization = %q, want %q", g, "Basic d3Jvbmc=")
+	}
+	if g := h.Get("Proxy-Authorization"); g != "" {
+		t.Errorf("Proxy-Authorization = %q, want it removed", g)
+	}
+
+	// other credentials still reach the cluster
+	art.code = http.StatusOK
+	if code := serve("http://192.0.2.2:2379/v2/keys/foo", "Basic cmlnaHQ="); code != http.StatusOK {
+		t.Errorf("code = %d, want %d", code, http.StatusOK)
+	}
+
+	// a path the user lacks the permission for does not block the others
+	n := len(art.reqs)
+	for i := 0; i < 2; i++ {
+		if code := serve("http://192.0.2.2:2379/v2/keys/forbidden", "Basic cmlnaHQ="); code != http.StatusUnauthorized {
+			t.Errorf("#%d: code = %d, want %d", i, code, http.StatusUnauthorized)
+		}
+	}
+	art.code = http.StatusOK
+	if code := serve("http://192.0.2.2:2379/v2/keys/foo", "Basic cmlnaHQ="); code != http.StatusOK {
+		t.Errorf("code = %d, want %d", code, http.StatusOK)
+	}
+	if g := len(art.reqs) - n; g != 2 {
+		t.Errorf("forwarded %d requests, want 2", g)
+	}
+}
+
+func TestAuthCache(t *testing.T) {
+	c := newAuthCache(time.Second)
+	now := time.Now()
+	c.observe("Basic d3Jvbmc=", "GET", "/v2/keys/foo", http.StatusUnauthorized, now)
+	if !c.isRejected("Basic d3Jvbmc=", "GET", "/v2/keys/foo", now) {
+		t.Errorf("rejected credentials not cached")
+	}
+	if c.isRejected("Basic d3Jvbmc=", "GET", "/v2/keys/bar", now) || c.isRejected("Basic d3Jvbmc=", "PUT", "/v2/keys/foo", now) {
+		t.Errorf("rejected credentials cached for another request")
+	}
+	if c.isRejected("Basic d3Jvbmc=", "GET", "/v2/keys/foo", now.Add(time.Second)) {
+		t.Errorf("rejected credentials cached past the TTL")
+	}
+	c.observe("Basic d3Jvbmc=", "GET", "/v2/keys/foo", http.StatusUnauthorized, now)
+	c.observe("Basic d3Jvbmc=", "GET", "/v2/keys/foo", http.StatusOK, now)
+	if c.isRejected("Basic d3Jvbmc=", "GET", "/v2/keys/foo", now) {
+		t.Errorf("accepted credentials still rejected")
+	}
+	if newAuthCache(0) != nil {
+		t.Errorf("cache with zero TTL is not nil")
+	}
+}
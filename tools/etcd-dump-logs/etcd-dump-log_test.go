@@ -16,11 +16,13 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"path"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -135,6 +137,70 @@ func TestEtcdDumpLogEntryType(t *testing.T) {
 
 }
 
+func TestFilterEntries(t *testing.T) {
+	ents := []raftpb.Entry{
+		{Term: 1, Index: 1},
+		{Term: 1, Index: 2},
+		{Term: 2, Index: 3},
+		{Term: 2, Index: 4},
+		{Term: 3, Index: 5},
+	}
+	tests := []struct {
+		endIndex, term uint64
+		windexes       []uint64
+	}{
+		{0, 0, []uint64{1, 2, 3, 4, 5}},
+		{3, 0, []uint64{1, 2, 3}},
+		{0, 2, []uint64{3, 4}},
+		{3, 2, []uint64{3}},
+		{0, 4, nil},
+	}
+	for i, tt := range tests {
+		var indexes []uint64
+		for _, e := range filterEntries(ents, tt.endIndex, tt.term) {
+			indexes = append(indexes, e.Index)
+		}
+		if !reflect.DeepEqual(indexes, tt.windexes) {
+			t.Errorf("#%d: indexes = %v, want %v", i, indexes, tt.windexes)
+		}
+	}
+}
+
+func TestNewJSONEntry(t *testing.T) {
+	cc := raftpb.ConfChange{Type: raftpb.ConfChangeAddNode, NodeID: 2, Context: []byte(`{"id":2}`)}
+	req := etcdserverpb.Request{ID: 1, Method: "PUT", Path: "/foo", Val: "bar"}
+	tests := []struct {
+		entry     raftpb.Entry
+		entrytype string
+		wdata     string
+	}{
+		{
+			raftpb.Entry{Type: raftpb.EntryConfChange, Data: pbutil.MustMarshal(&cc)},
+			"ConfigChange",
+			`{"method":"ConfChangeAddNode","id":"2","context":"{\"id\":2}"}`,
+		},
+		{
+			raftpb.Entry{Data: pbutil.MustMarshal(&req)},
+			"Request",
+			`"Method":"PUT","Path":"/foo","Val":"bar"`,
+		},
+		{
+			raftpb.Entry{Data: []byte{0xab}},
+			"UnknownNormal",
+			`"ab"`,
+		},
+	}
+	for i, tt := range tests {
+		b, err := json.Marshal(newJSONEntry(tt.entry, tt.entrytype))
+		if err != nil {
+			t.Fatalf("#%d: unexpected error: %v", i, err)
+		}
+		if !strings.Contains(string(b), tt.wdata) {
+			t.Errorf("#%d: json = %s, want data %s", i, b, tt.wdata)
+		}
+	}
+}
+
 func appendConfigChangeEnts(ents *[]raftpb.Entry) {
 	configChangeData := []raftpb.ConfChange{
 		{ID: 1, Type: raftpb.ConfChangeAddNode, NodeID: 2, Context: []byte("")},
@@ -18,6 +18,7 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/hex"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
@@ -42,10 +43,14 @@ import (
 func main() {
 	snapfile := flag.String("start-snap", "", "The base name of snapshot file to start dumping")
 	index := flag.Uint64("start-index", 0, "The index to start dumping")
+	endindex := flag.Uint64("end-index", 0, "The index to stop dumping at, inclusive. 0 to dump all entries")
+	term := flag.Uint64("term", 0, "If set, only dumps entries of the given term")
+	jsonOutput := flag.Bool("json", false, "Prints one JSON object per entry to stdout, and the snapshot and WAL metadata to stderr")
 	entrytype := flag.String("entry-type", "", `If set, filters output by entry type. Must be one or more than one of:
 	ConfigChange, Normal, Request, InternalRaftRequest,
 	IRRRange, IRRPut, IRRDeleteRange, IRRTxn,
 	IRRCompaction, IRRLeaseGrant, IRRLeaseRevoke, IRRLeaseCheckpoint`)
+	keyFiles := flag.String("snapshot-encryption-key-files", "", "Comma-separated paths to the key files decrypting the snap files, if encrypted")
 	streamdecoder := flag.String("stream-decoder", "", `The name of an executable decoding tool, the executable must process
 	hex encoded lines of binary input (from etcd-dump-logs)
 	and output a hex encoded line of binary for each input line`)
@@ -60,6 +65,15 @@ func main() {
 	if *snapfile != "" && *index != 0 {
 		log.Fatal("start-snap and start-index flags cannot be used together.")
 	}
+	if *jsonOutput && *streamdecoder != "" {
+		log.Fatal("json and stream-decoder flags cannot be used together.")
+	}
+
+	// in JSON mode stdout only holds the entries
+	var hdr io.Writer = os.Stdout
+	if *jsonOutput {
+		hdr = os.Stderr
+	}
 
 	var (
 		walsnap  walpb.Snapshot
@@ -70,28 +84,34 @@ func main() {
 	isIndex := *index != 0
 
 	if isIndex {
-		fmt.Printf("Start dumping log entries from index %d.\n", *index)
+		fmt.Fprintf(hdr, "Start dumping log entries from index %d.\n", *index)
 		walsnap.Index = *index
 	} else {
+		var codec snap.Codec
+		if *keyFiles != "" {
+			if codec.Keys, err = snap.NewFileKeyProvider(strings.Split(*keyFiles, ",")); err != nil {
+				log.Fatalf("Failed reading snapshot encryption keys: %v", err)
+			}
+		}
 		if *snapfile == "" {
-			ss := snap.New(zap.NewExample(), snapDir(dataDir))
+			ss := snap.NewWithCodec(zap.NewExample(), snapDir(dataDir), codec)
 			snapshot, err = ss.Load()
 		} else {
-			snapshot, err = snap.Read(zap.NewExample(), filepath.Join(snapDir(dataDir), *snapfile))
+			snapshot, err = snap.ReadWithKeys(zap.NewExample(), filepath.Join(snapDir(dataDir), *snapfile), codec.Keys)
 		}
 
 		switch err {
 		case nil:
 			walsnap.Index, walsnap.Term = snapshot.Metadata.Index, snapshot.Metadata.Term
 			nodes := genIDSlice(snapshot.Metadata.ConfState.Nodes)
-			fmt.Printf("Snapshot:\nterm=%d index=%d nodes=%s\n",
+			fmt.Fprintf(hdr, "Snapshot:\nterm=%d index=%d nodes=%s\n",
 				walsnap.Term, walsnap.Index, nodes)
 		case snap.ErrNoSnapshot:
-			fmt.Printf("Snapshot:\nempty\n")
+			fmt.Fprintf(hdr, "Snapshot:\nempty\n")
 		default:
 			log.Fatalf("Failed loading snapshot: %v", err)
 		}
-		fmt.Println("Start dupmping log entries from snapshot.")
+		fmt.Fprintln(hdr, "Start dupmping log entries from snapshot.")
 	}
 
 	w, err := wal.OpenForRead(zap.NewExample(), walDir(dataDir), walsnap)
@@ -105,11 +125,17 @@ func main() {
 	}
 	id, cid := parseWALMetadata(wmetadata)
 	vid := types.ID(state.Vote)
-	fmt.Printf("WAL metadata:\nnodeID=%s clusterID=%s term=%d commitIndex=%d vote=%s\n",
+	fmt.Fprintf(hdr, "WAL metadata:\nnodeID=%s clusterID=%s term=%d commitIndex=%d vote=%s\n",
 		id, cid, state.Term, state.Commit, vid)
 
-	fmt.Printf("WAL entries:\n")
-	fmt.Printf("lastIndex=%d\n", ents[len(ents)-1].Index)
+	fmt.Fprintf(hdr, "WAL entries:\n")
+	fmt.Fprintf(hdr, "lastIndex=%d\n", ents[len(ents)-1].Index)
+
+	ents = filterEntries(ents, *endindex, *term)
+	if *jsonOutput {
+		listEntriesJSON(*entrytype, ents)
+		return
+	}
 
 	fmt.Printf("%4s\t%10s\ttype\tdata", "term", "index")
 	if *streamdecoder != "" {
@@ -239,6 +265,10 @@ func printConfChange(entry raftpb.Entry) {
 		fmt.Printf("\t???")
 	} else {
 		fmt.Printf("\tmethod=%s id=%s", r.Type, types.ID(r.NodeID))
+		// membership changes carry the JSON encoded member
+		if len(r.Context) > 0 {
+			fmt.Printf(" context=%s", excerpt(string(r.Context), 128, 0))
+		}
 	}
 }
 
@@ -377,6 +407,74 @@ func listEntriesType(entrytype string, streamdecoder string, ents []raftpb.Entry
 	fmt.Printf("\nEntry types (%s) count is : %d", entrytype, cnt)
 }
 
+// filterEntries returns the entries up to endIndex, or all entries if
+// endIndex is 0, of the given term, or of any term if term is 0.
+func filterEntries(ents []raftpb.Entry, endIndex, term uint64) []raftpb.Entry {
+	var filtered []raftpb.Entry
+	for _, e := range ents {
+		if endIndex != 0 && e.Index > endIndex {
+			break
+		}
+		if term != 0 && e.Term != term {
+			continue
+		}
+		filtered = append(filtered, e)
+	}
+	return filtered
+}
+
+// jsonEntry is the JSON form of an entry. Data holds the decoded entry,
+// or its hex encoding if it cannot be decoded.
+type jsonEntry struct {
+	Term  uint64      `json:"term"`
+	Index uint64      `json:"index"`
+	Type  string      `json:"type"`
+	Data  interface{} `json:"data"`
+}
+
+func newJSONEntry(entry raftpb.Entry, entrytype string) jsonEntry {
+	je := jsonEntry{Term: entry.Term, Index: entry.Index, Type: entrytype, Data: hex.EncodeToString(entry.Data)}
+	switch entrytype {
+	case "ConfigChange":
+		var r raftpb.ConfChange
+		if err := r.Unmarshal(entry.Data); err == nil {
+			je.Data = struct {
+				Method  string `json:"method"`
+				ID      string `json:"id"`
+				Context string `json:"context,omitempty"`
+			}{r.Type.String(), types.ID(r.NodeID).String(), string(r.Context)}
+		}
+	case "Request":
+		var r etcdserverpb.Request
+		if err := r.Unmarshal(entry.Data); err == nil {
+			je.Data = r
+		}
+	case "InternalRaftRequest":
+		var rr etcdserverpb.InternalRaftRequest
+		if err := rr.Unmarshal(entry.Data); err == nil {
+			je.Data = rr
+		}
+	}
+	return je
+}
+
+// listEntriesJSON filters entries based on the entry-type flag and prints
+// each of them as a JSON object on its own line.
+func listEntriesJSON(entrytype string, ents []raftpb.Entry) {
+	entryFilters := evaluateEntrytypeFlag(entrytype)
+	enc := json.NewEncoder(os.Stdout)
+	for _, e := range ents {
+		for _, filter := range entryFilters {
+			if passed, currtype := filter(e); passed {
+				if err := enc.Encode(newJSONEntry(e, currtype)); err != nil {
+					log.Fatalf("Failed encoding entry %d: %v", e.Index, err)
+				}
+				break
+			}
+		}
+	}
+}
+
 func parseDecoderOutput(decoderoutput string) (string, string) {
 	var decoder_status string
 	var decoded_data string
@@ -27,8 +27,20 @@ var (
 		// highest bucket start of 0.001 sec * 2^13 == 8.192 sec
 		Buckets: prometheus.ExponentialBuckets(0.001, 2, 14),
 	})
+
+	walSyncEntries = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "etcd",
+		Subsystem: "disk",
+		Name:      "wal_fsync_entries",
+		Help:      "The distributions of the number of entries made durable by each WAL fsync.",
+
+		// lowest bucket start of upper bound 1 with factor 2
+		// highest bucket start of 1 * 2^13 == 8192
+		Buckets: prometheus.ExponentialBuckets(1, 2, 14),
+	})
 )
 
 func init() {
 	prometheus.MustRegister(walFsyncSec)
+	prometheus.MustRegister(walSyncEntries)
 }
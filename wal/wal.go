@@ -45,6 +45,11 @@ const (
 	// warnSyncDuration is the amount of time allotted to an fsync before
 	// logging a warning
 	warnSyncDuration = time.Second
+
+	// readProgressInterval is the interval between two logs of the
+	// progress of ReadAll, checked every readProgressRecords records.
+	readProgressInterval = 10 * time.Second
+	readProgressRecords  = 4096
 )
 
 var (
@@ -88,6 +93,10 @@ type WAL struct {
 	enti    uint64   // index of the last entry saved to the wal
 	encoder *encoder // encoder to encode records
 
+	// unsynced is the number of entries written since the last sync;
+	// they are all made durable by a single fsync.
+	unsynced int
+
 	locks []*fileutil.LockedFile // the locked files the WAL holds (the name is increasing)
 	fp    *filePipeline
 }
@@ -396,7 +405,16 @@ func (w *WAL) ReadAll() (metadata []byte, state raftpb.HardState, ents []raftpb.
 	decoder := w.decoder
 
 	var match bool
+	var nrec int
+	start := time.Now()
+	lastLog := start
 	for err = decoder.decode(rec); err == nil; err = decoder.decode(rec) {
+		if nrec++; nrec%readProgressRecords == 0 {
+			if now := time.Now(); now.Sub(lastLog) >= readProgressInterval {
+				lastLog = now
+				w.logReadProgress(nrec, w.enti, now.Sub(start))
+			}
+		}
 		switch rec.Type {
 		case entryType:
 			e := mustUnmarshalEntry(rec.Data)
@@ -494,9 +512,41 @@ func (w *WAL) ReadAll() (metadata []byte, state raftpb.HardState, ents []raftpb.
 	}
 	w.decoder = nil
 
+	if took := time.Since(start); took >= readProgressInterval {
+		if w.lg != nil {
+			w.lg.Info(
+				"read WAL",
+				zap.String("wal-dir", w.dir),
+				zap.Int("records", nrec),
+				zap.Uint64("last-index", w.enti),
+				zap.Duration("took", took),
+			)
+		} else {
+			plog.Infof("read %d records of WAL up to index %d in %v", nrec, w.enti, took)
+		}
+	}
 	return metadata, state, ents, err
 }
 
+// logReadProgress logs the progress of ReadAll, as the number of records
+// read and the index of the last entry read. The total is only known once
+// read, since the segment files are preallocated.
+func (w *WAL) logReadProgress(nrec int, index uint64, took time.Duration) {
+	rate := float64(nrec) / took.Seconds()
+	if w.lg != nil {
+		w.lg.Info(
+			"reading WAL in progress",
+			zap.String("wal-dir", w.dir),
+			zap.Int("records", nrec),
+			zap.Uint64("last-index", index),
+			zap.Float64("records-per-second", rate),
+			zap.Duration("took", took),
+		)
+	} else {
+		plog.Infof("reading WAL: %d records up to index %d (%.0f records/s, %v so far)", nrec, index, rate, took)
+	}
+}
+
 // Verify reads through the given WAL and verifies that it is not corrupted.
 // It creates a new decoder to read through the records of the given WAL.
 // It does not conflict with any open WAL, but it is recommended not to
@@ -687,6 +737,8 @@ func (w *WAL) sync() error {
 		}
 	}
 	walFsyncSec.Observe(took.Seconds())
+	walSyncEntries.Observe(float64(w.unsynced))
+	w.unsynced = 0
 
 	return err
 }
@@ -777,6 +829,7 @@ func (w *WAL) saveEntry(e *raftpb.Entry) error {
 		return err
 	}
 	w.enti = e.Index
+	w.unsynced++
 	return nil
 }
 
@@ -825,6 +878,23 @@ func (w *WAL) Save(st raftpb.HardState, ents []raftpb.Entry) error {
 	return w.cut()
 }
 
+// SetPreallocateBytes sets the size of the space preallocated for the
+// segment files the WAL cuts from now on. Preallocating a whole segment
+// keeps appends from updating file metadata; 0 disables preallocation.
+func (w *WAL) SetPreallocateBytes(n int64) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.fp == nil || w.fp.size == n {
+		return nil
+	}
+	if err := w.fp.Close(); err != nil {
+		return err
+	}
+	w.fp = newFilePipeline(w.lg, w.dir, n)
+	return nil
+}
+
 func (w *WAL) SaveSnapshot(e walpb.Snapshot) error {
 	b := pbutil.MustMarshal(&e)
 
@@ -626,6 +626,70 @@ func TestSaveEmpty(t *testing.T) {
 	}
 }
 
+// TestSaveBatchSync ensures entries saved together are made durable by
+// a single sync.
+func TestSaveBatchSync(t *testing.T) {
+	p, err := ioutil.TempDir(os.TempDir(), "waltest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(p)
+
+	w, err := Create(zap.NewExample(), p, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	ents := []raftpb.Entry{{Index: 1, Term: 1}, {Index: 2, Term: 1}, {Index: 3, Term: 1}}
+	for i := range ents {
+		if err = w.saveEntry(&ents[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if w.unsynced != len(ents) {
+		t.Errorf("unsynced = %d, want %d", w.unsynced, len(ents))
+	}
+	if err = w.Save(raftpb.HardState{Term: 1, Commit: 3}, []raftpb.Entry{{Index: 4, Term: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	if w.unsynced != 0 {
+		t.Errorf("unsynced = %d, want 0", w.unsynced)
+	}
+}
+
+func TestSetPreallocateBytes(t *testing.T) {
+	p, err := ioutil.TempDir(os.TempDir(), "waltest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(p)
+
+	w, err := Create(zap.NewExample(), p, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	tests := []int64{0, 4096, SegmentSizeBytes}
+	for i, n := range tests {
+		if err = w.SetPreallocateBytes(n); err != nil {
+			t.Fatal(err)
+		}
+		if err = w.cut(); err != nil {
+			t.Fatal(err)
+		}
+		fi, err := w.tail().Stat()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the new segment starts with its crc and metadata records
+		if n == 0 && fi.Size() >= 4096 || n != 0 && fi.Size() != n {
+			t.Errorf("#%d: segment size = %d, want preallocated %d", i, fi.Size(), n)
+		}
+	}
+}
+
 func TestReleaseLockTo(t *testing.T) {
 	p, err := ioutil.TempDir(os.TempDir(), "waltest")
 	if err != nil {